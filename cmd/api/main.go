@@ -1,7 +1,10 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
+	"net/http"
 	"os"
 	"os/signal"
 	"runtime"
@@ -37,13 +40,18 @@ func main() {
 	if err != nil {
 		log.Fatal("Failed to connect to PostgreSQL:", err)
 	}
-	defer database.Close(db)
+	shutdown := services.GetShutdownService()
+	shutdown.Register(services.ShutdownCloseStores, "database", func(ctx context.Context) (int, error) {
+		return 0, database.Close(db)
+	})
 
 	redisClient, err := cache.ConnectRedis(cfg)
 	if err != nil {
 		log.Fatal("Failed to connect to Redis:", err)
 	}
-	defer cache.CloseRedis(redisClient)
+	shutdown.Register(services.ShutdownCloseStores, "redis", func(ctx context.Context) (int, error) {
+		return 0, cache.CloseRedis(redisClient)
+	})
 
 	// Skip AutoMigrate - tables already exist in production
 	// This prevents "insufficient arguments" error from schema conflicts
@@ -56,16 +64,6 @@ func main() {
 		log.Println("⏭️ Skipping database migration (SKIP_MIGRATION=true)")
 	}
 
-	// Graceful shutdown handler
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-	go func() {
-		<-sigChan
-		log.Println("🛑 Shutting down gracefully...")
-		services.StopAllPools()
-		os.Exit(0)
-	}()
-
 	if cfg.IsProduction() {
 		gin.SetMode(gin.ReleaseMode)
 	}
@@ -98,6 +96,8 @@ func main() {
 	authHandler := handlers.NewAuthHandler(db)
 	userHandler := handlers.NewUserHandler(db)
 	offerHandler := handlers.NewOfferHandler(db)
+	deepLinkHandler := handlers.NewDeepLinkHandler(db)
+	promoterPostbackHandler := handlers.NewPromoterPostbackHandler(db)
 	networkHandler := handlers.NewNetworkHandler(db)
 	postbackHandler := handlers.NewPostbackHandler(db)
 	teamHandler := handlers.NewTeamHandler(db)
@@ -107,6 +107,8 @@ func main() {
 	promoterHandler := handlers.NewPromoterHandler(db)
 	inviteHandler := handlers.NewInviteHandler(db)
 	advertiserHandler := handlers.NewAdvertiserHandler(db)
+	invoiceHandler := handlers.NewInvoiceHandler(db)
+	offerRoutingHandler := handlers.NewOfferRoutingHandler(db)
 	observabilityHandler := handlers.NewObservabilityHandler()
 	
 	// Phase 7: Admin Observability Handlers
@@ -132,6 +134,7 @@ func main() {
 	apiKeyService := services.NewAPIKeyService(db)
 	observabilityService := services.NewObservabilityService()
 	adminAPIKeysHandler := handlers.NewAdminAPIKeysHandler(apiKeyService)
+	adminPlatformWebhooksHandler := handlers.NewAdminPlatformWebhooksHandler(db)
 	
 	// Initialize API Key Middleware with services
 	middleware.InitAPIKeyMiddleware(apiKeyService, observabilityService)
@@ -146,6 +149,17 @@ func main() {
 	// Set Geo Rule service on handlers
 	clickHandler.SetGeoRuleService(geoRuleService)
 	postbackHandler.SetGeoRuleService(geoRuleService)
+	handlers.GeoEnforceOnPostback = os.Getenv("GEO_ENFORCE_ON_POSTBACK") == "true"
+
+	// GeoIP resolution (MMDB at GEOIP_DB_PATH, hot reloaded; header fallback)
+	geoIPService := services.GetGeoIPService()
+	geoIPService.Start()
+	shutdown.Register(services.ShutdownDrainWorkers, "geoip", services.StopFunc(geoIPService.Stop))
+	if geoIPService.HasResolver() {
+		log.Println("✅ GeoIP database loaded")
+	} else {
+		log.Println("⚠️ GEOIP_DB_PATH not set or unreadable, using geo headers only (retried on each reload check)")
+	}
 
 	// Phase 8.4: Link Signing System
 	linkSigningService := services.NewLinkSigningService()
@@ -155,6 +169,7 @@ func main() {
 	// Set Link Signing service on handlers
 	clickHandler.SetLinkSigningService(linkSigningService)
 	offerHandler.SetLinkSigningService(linkSigningService)
+	deepLinkHandler.SetLinkSigningService(linkSigningService)
 
 	// Phase 8.5: Advanced Webhooks Engine
 	webhookService := services.GetWebhookService(db)
@@ -162,7 +177,7 @@ func main() {
 	
 	// Start webhook workers
 	webhookService.Start()
-	defer webhookService.Stop()
+	shutdown.Register(services.ShutdownStopConsumers, "webhooks", webhookService.Drain)
 
 	// Phase 8.6: Multi-Tenant System
 	middleware.InitTenantMiddleware(db)
@@ -176,6 +191,9 @@ func main() {
 		log.Println("✅ Default tenant created")
 	}
 
+	// Tenant egress allowlists for webhooks and postbacks
+	services.GetOutboundClient().SetTenantService(tenantService)
+
 	// Phase 8.7: Edge CDN Layer
 	edgeIngestHandler := handlers.NewEdgeIngestHandler(db)
 	edgeIngestHandler.SetLinkService(linkService)
@@ -185,7 +203,7 @@ func main() {
 	edgeIngestService := services.GetEdgeIngestService(db)
 	edgeIngestService.SetLinkService(linkService)
 	edgeIngestService.Start(4) // 4 workers
-	defer edgeIngestService.Stop()
+	shutdown.Register(services.ShutdownDrainWorkers, "edge_ingest", edgeIngestService.Drain)
 	log.Println("✅ Edge ingest workers started")
 
 	// Phase 8.8: Zero-Drop Tracking Mode
@@ -197,12 +215,12 @@ func main() {
 	} else {
 		log.Println("✅ Zero-Drop system initialized")
 	}
-	defer services.ShutdownZeroDrop()
+	shutdown.Register(services.ShutdownFlush, "zero_drop", services.StopFunc(services.ShutdownZeroDrop))
 	
 	// Start Postback Queue service
 	postbackQueueService := services.GetPostbackQueueService()
 	postbackQueueService.Start()
-	defer postbackQueueService.Stop()
+	shutdown.Register(services.ShutdownDrainWorkers, "postback_queue", postbackQueueService.Drain)
 	log.Println("✅ Postback queue service started")
 
 	// Phase 8.9: Launch Mode - Production Hardening
@@ -223,28 +241,59 @@ func main() {
 	_ = loggingModeService // Used in handlers
 	log.Println("✅ Logging mode service initialized")
 
+	// Start contest engine (progress, ranks, lifecycle)
+	contestService := services.GetContestService(db)
+	contestService.Start()
+	shutdown.Register(services.ShutdownStopConsumers, "contests", services.StopFunc(contestService.Stop))
+	log.Println("✅ Contest engine started")
+
+	// Start leaderboards (Redis sorted sets, nightly reconciliation)
+	leaderboardService := services.GetLeaderboardService(db)
+	leaderboardService.Start()
+	shutdown.Register(services.ShutdownStopConsumers, "leaderboards", services.StopFunc(leaderboardService.Stop))
+	log.Println("✅ Leaderboards started")
+
+	// Start badge engine (event-driven badge awards)
+	badgeEngine := services.GetBadgeEngine(db)
+	badgeEngine.Start()
+	shutdown.Register(services.ShutdownStopConsumers, "badges", services.StopFunc(badgeEngine.Stop))
+	log.Println("✅ Badge engine started")
+
+	// Start invoice scheduler (monthly generation, overdue marking)
+	invoiceService := services.GetInvoiceService(db)
+	invoiceService.Start()
+	shutdown.Register(services.ShutdownDrainWorkers, "invoices", services.StopFunc(invoiceService.Stop))
+	log.Println("✅ Invoice scheduler started")
+
 	// Initialize worker pools for async processing
 	services.StartAllPools()
+	shutdown.Register(services.ShutdownDrainWorkers, "worker_pools", services.DrainAllPools)
+
+	// Buffered clicks and write-behind cache writes (no-ops if never started)
+	shutdown.Register(services.ShutdownDrainWorkers, "clicks", services.DrainClickServiceV2)
+	shutdown.Register(services.ShutdownDrainWorkers, "cache", services.DrainCacheService)
 
 	// Public health endpoint
 	router.GET("/health", observabilityHandler.GetHealth)
+	router.GET("/ready", observabilityHandler.GetReadiness)
 
 	api := router.Group("/api")
 	{
 		auth := api.Group("/auth")
-		auth.Use(middleware.AuthRateLimitMiddleware())
+		auth.Use(middleware.ThreatEnforcementMiddleware("auth"), middleware.AuthRateLimitMiddleware())
 		{
 			auth.POST("/register", authHandler.Register)
 			auth.POST("/login", authHandler.Login)
 			auth.POST("/refresh", authHandler.RefreshToken)
 			auth.POST("/logout", authHandler.Logout)
+			auth.POST("/logout-all", middleware.AuthMiddleware(), authHandler.LogoutAll)
 		}
 
 		// Advertiser Registration (public - no auth required)
 		api.POST("/advertiser/register", advertiserHandler.RegisterAdvertiser)
 
 		// Click tracking with bot detection and rate limiting
-		api.GET("/c/:id", middleware.BotDetectionMiddleware(), clickHandler.TrackClick)
+		api.GET("/c/:id", middleware.ThreatEnforcementMiddleware(middleware.ThreatScopeClick), middleware.BotDetectionMiddleware(), clickHandler.TrackClick)
 		api.GET("/promoter/:id", promoterHandler.GetPromoterPage)
 		api.GET("/promoter/user/:username", promoterHandler.GetPromoterPageByUsername) // Public - landing page by username
 		api.GET("/r/:code", promoterHandler.GetPromoterPageByCode)                     // Public - landing page by unique code
@@ -256,7 +305,8 @@ func main() {
 		api.GET("/invite/:code", inviteHandler.GetInviteInfo) // Beautiful HTML landing page
 
 		// Postback with security validation + API Key or JWT auth
-		api.POST("/postback", middleware.PostbackSecurityMiddleware(), middleware.APIKeyOrJWTMiddleware(), postbackHandler.HandlePostback)
+		postbackThreats := middleware.ThreatEnforcementMiddleware("postback")
+		api.POST("/postback", middleware.PostbackSecurityMiddleware(), middleware.APIKeyOrJWTMiddleware(), postbackThreats, postbackHandler.HandlePostback)
 
 		// ============================================
 		// CONVERSION TRACKING WEBHOOKS
@@ -264,8 +314,8 @@ func main() {
 		conversionWebhookHandler := handlers.NewConversionWebhookHandler(db)
 		
 		// Generic postback endpoint (for custom integrations)
-		api.GET("/postback", conversionWebhookHandler.HandlePostback)
-		api.POST("/conversion/postback", conversionWebhookHandler.HandlePostback)
+		api.GET("/postback", postbackThreats, conversionWebhookHandler.HandlePostback)
+		api.POST("/conversion/postback", postbackThreats, conversionWebhookHandler.HandlePostback)
 		
 		// Platform-specific webhooks
 		api.POST("/webhook/shopify/:advertiser_id", conversionWebhookHandler.HandleShopifyWebhook)
@@ -276,8 +326,18 @@ func main() {
 		api.POST("/pixel/convert", conversionWebhookHandler.HandlePixelConversion)
 		api.GET("/pixel/convert", conversionWebhookHandler.HandlePixelConversion) // For image pixel
 
-		api.GET("/offers", offerHandler.GetAllOffers)
-		api.GET("/offers/:id", offerHandler.GetOffer)
+		// ============================================
+		// SDK INGESTION (web + mobile SDKs)
+		// ============================================
+		sdkHandler := handlers.NewSDKHandler(db)
+		// Threats are evaluated once the key is resolved, to track it per key
+		apiKeyThreats := middleware.ThreatEnforcementMiddleware("api_key")
+		api.POST("/sdk/click", middleware.APIKeyRequiredMiddleware(), apiKeyThreats, sdkHandler.TrackClick)
+		api.POST("/sdk/conversion", middleware.APIKeyRequiredMiddleware(), apiKeyThreats, sdkHandler.TrackConversion)
+		api.POST("/convert", middleware.APIKeyRequiredMiddleware(), apiKeyThreats, sdkHandler.TrackConversion) // SDK fallback
+
+		api.GET("/offers", middleware.OptionalAuthMiddleware(), offerHandler.GetAllOffers)
+		api.GET("/offers/:id", middleware.OptionalAuthMiddleware(), offerHandler.GetOffer)
 
 		protected := api.Group("")
 		protected.Use(middleware.AuthMiddleware())
@@ -296,8 +356,22 @@ func main() {
 			protected.GET("/leaderboard", userHandler.GetLeaderboard)
 
 			protected.POST("/offers/:id/join", offerHandler.JoinOffer)
+			protected.POST("/offers/:id/apply", offerHandler.ApplyToOffer)
+			protected.GET("/offers/applications", offerHandler.GetMyApplications)
+			protected.POST("/deep-links", deepLinkHandler.CreateDeepLink)
+			protected.GET("/deep-links", deepLinkHandler.GetDeepLinkReport)
 			protected.GET("/offers/my", offerHandler.GetMyOffers)
 
+			// Promoter postbacks to their own trackers
+			promoterPostbacks := protected.Group("/postbacks/mine")
+			{
+				promoterPostbacks.GET("", promoterPostbackHandler.GetMyPostbacks)
+				promoterPostbacks.POST("", promoterPostbackHandler.CreatePostback)
+				promoterPostbacks.GET("/deliveries", promoterPostbackHandler.GetDeliveries)
+				promoterPostbacks.PUT("/:id", promoterPostbackHandler.UpdatePostback)
+				promoterPostbacks.DELETE("/:id", promoterPostbackHandler.DeletePostback)
+			}
+
 			networks := protected.Group("/networks")
 			{
 				networks.GET("", networkHandler.GetAllNetworks)
@@ -325,6 +399,8 @@ func main() {
 			{
 				badges.GET("", badgeHandler.GetAllBadges)
 				badges.GET("/my", badgeHandler.GetMyBadges)
+				badges.GET("/notifications", badgeHandler.GetBadgeNotifications)
+				badges.POST("/notifications/seen", badgeHandler.MarkBadgeNotificationsSeen)
 			}
 
 			// Contests / Challenges
@@ -342,6 +418,7 @@ func main() {
 				clicks.GET("/my", clickHandler.GetMyClicks)
 				clicks.GET("/:id/stats", clickHandler.GetClickStats)
 				clicks.GET("/by-offer", clickHandler.GetClicksByOffer)
+				clicks.GET("/sub-ids", clickHandler.GetSubIDReport)
 			}
 
 			// ========== Advertiser Routes ==========
@@ -354,7 +431,25 @@ func main() {
 			advertiser.DELETE("/offers/:id", advertiserHandler.DeleteOffer)
 			advertiser.POST("/offers/:id/pause", advertiserHandler.PauseOffer)
 			advertiser.GET("/offers/:id/stats", advertiserHandler.GetOfferStats)
+			advertiser.GET("/offers/:id/sub-ids", advertiserHandler.GetOfferSubIDReport)
+			advertiser.GET("/offers/:id/deep-links", advertiserHandler.GetOfferDeepLinkReport)
+			advertiser.PUT("/offers/:id/visibility", advertiserHandler.SetOfferVisibility)
+			advertiser.GET("/offers/:id/applications", advertiserHandler.GetOfferApplications)
+			advertiser.POST("/offers/:id/invite", advertiserHandler.InvitePromoter)
+			advertiser.POST("/offers/:id/promoters/:userId/revoke", advertiserHandler.RevokePromoter)
+			advertiser.POST("/applications/:id/approve", advertiserHandler.ApproveApplication)
+			advertiser.POST("/applications/:id/reject", advertiserHandler.RejectApplication)
+			advertiser.PUT("/applications/:id/payout", advertiserHandler.SetApplicationPayout)
+			advertiser.GET("/offers/:id/routing", offerRoutingHandler.GetOfferRouting)
+			advertiser.PUT("/offers/:id/routing", offerRoutingHandler.SaveOfferRouting)
+			advertiser.DELETE("/offers/:id/routing", offerRoutingHandler.DeleteOfferRouting)
+			advertiser.GET("/user-offers/:id/routing", offerRoutingHandler.GetUserOfferRouting)
+			advertiser.PUT("/user-offers/:id/routing", offerRoutingHandler.SaveUserOfferRouting)
+			advertiser.DELETE("/user-offers/:id/routing", offerRoutingHandler.DeleteUserOfferRouting)
 			advertiser.GET("/promoters", advertiserHandler.GetPromoters)
+			advertiser.GET("/invoices", invoiceHandler.GetMyInvoices)
+			advertiser.GET("/invoices/:id", invoiceHandler.GetInvoice)
+			advertiser.POST("/invoices/:id/pay", invoiceHandler.ConfirmPayment)
 			}
 
 			admin := protected.Group("/admin")
@@ -374,12 +469,23 @@ func main() {
 				admin.GET("/conversions", postbackHandler.GetConversions)
 				admin.POST("/conversions/:id/approve", postbackHandler.ApproveConversion)
 				admin.POST("/conversions/:id/reject", postbackHandler.RejectConversion)
+				admin.POST("/conversions/:id/reverse", postbackHandler.ReverseConversion)
+				admin.POST("/conversions/:id/paid", postbackHandler.MarkConversionPaid)
+				admin.GET("/conversions/:id/history", postbackHandler.GetConversionHistory)
 
 				// Pending Offers Management (for advertiser submissions)
 				admin.GET("/offers/pending", advertiserHandler.GetPendingOffers)
 				admin.POST("/offers/:id/approve", advertiserHandler.ApproveOffer)
 				admin.POST("/offers/:id/reject", advertiserHandler.RejectOffer)
 
+				// Offer caps, routing rules, A/B tests and rotation (served to the edge)
+				admin.GET("/offers/:id/routing", offerRoutingHandler.GetOfferRouting)
+				admin.PUT("/offers/:id/routing", offerRoutingHandler.SaveOfferRouting)
+				admin.DELETE("/offers/:id/routing", offerRoutingHandler.DeleteOfferRouting)
+				admin.GET("/user-offers/:id/routing", offerRoutingHandler.GetUserOfferRouting)
+				admin.PUT("/user-offers/:id/routing", offerRoutingHandler.SaveUserOfferRouting)
+				admin.DELETE("/user-offers/:id/routing", offerRoutingHandler.DeleteUserOfferRouting)
+
 				// Contests / Challenges Management
 				admin.GET("/contests", contestHandler.AdminGetAllContests)
 				admin.POST("/contests", contestHandler.AdminCreateContest)
@@ -387,10 +493,21 @@ func main() {
 				admin.PUT("/contests/:id", contestHandler.AdminUpdateContest)
 				admin.DELETE("/contests/:id", contestHandler.AdminDeleteContest)
 				admin.GET("/contests/:id/participants", contestHandler.AdminGetContestParticipants)
+				admin.POST("/contests/:id/recompute", contestHandler.AdminRecomputeContest)
+
+				// Invoices / Billing
+				admin.GET("/invoices", invoiceHandler.AdminGetAllInvoices)
+				admin.GET("/invoices/summary", invoiceHandler.AdminGetInvoiceSummary)
+				admin.POST("/invoices/generate", invoiceHandler.AdminGenerateMonthlyInvoices)
+				admin.POST("/invoices/:id/confirm", invoiceHandler.AdminConfirmPayment)
+				admin.POST("/invoices/:id/reject", invoiceHandler.AdminRejectPayment)
+				admin.PUT("/advertisers/:id/platform-rate", invoiceHandler.AdminSetPlatformRate)
 
 				admin.POST("/badges", badgeHandler.CreateBadge)
 			admin.PUT("/badges/:id", badgeHandler.UpdateBadge)
 			admin.DELETE("/badges/:id", badgeHandler.DeleteBadge)
+			admin.GET("/badges/awards", badgeHandler.GetBadgeAwards)
+			admin.POST("/badges/evaluate/:userId", badgeHandler.EvaluateUserBadges)
 
 			// ============================================
 			// PHASE 7: SYSTEM OBSERVABILITY API LAYER
@@ -498,6 +615,11 @@ func main() {
 			// 8. API Key stats report
 			admin.GET("/security/api-keys/report", adminAPIKeysHandler.GetAPIKeyStats)
 
+			// 9. Shopify/Salla/Zid webhook secrets (register, rotate, delete)
+			admin.GET("/advertisers/:id/webhook-secrets", adminPlatformWebhooksHandler.GetWebhookSecrets)
+			admin.PUT("/advertisers/:id/webhook-secrets/:platform", adminPlatformWebhooksHandler.SetWebhookSecret)
+			admin.DELETE("/advertisers/:id/webhook-secrets/:platform", adminPlatformWebhooksHandler.DeleteWebhookSecret)
+
 			// ============================================
 			// PHASE 8.3: GEO RULES
 			// ============================================
@@ -648,6 +770,12 @@ func main() {
 			// 4. Edge Cache
 			admin.POST("/edge/cache/refresh", adminEdgeHandler.RefreshEdgeCache)
 
+			// 5. Edge Credentials (per-location signing keys)
+			admin.GET("/edge/credentials", adminEdgeHandler.GetEdgeCredentials)
+			admin.POST("/edge/credentials", adminEdgeHandler.CreateEdgeCredential)
+			admin.POST("/edge/credentials/:id/rotate", adminEdgeHandler.RotateEdgeCredential)
+			admin.DELETE("/edge/credentials/:id", adminEdgeHandler.RevokeEdgeCredential)
+
 			// ============================================
 			// PHASE 8.8: ZERO-DROP TRACKING MODE
 			// ============================================
@@ -760,18 +888,37 @@ func main() {
 			internal.GET("/health/full", adminLaunchHandler.GetFullHealth)
 			internal.GET("/metrics", adminMetricsHandler.GetMetrics)
 			
-			// Edge ingestion endpoints (no auth - called by edge workers)
-			internal.POST("/edge-click", edgeIngestHandler.IngestEdgeClick)
-			internal.GET("/edge/offer/:trackingCode", edgeIngestHandler.GetOfferConfig)
-			internal.GET("/edge/stats", edgeIngestHandler.GetEdgeStats)
+			// Edge ingestion endpoints (called by edge workers, signed with per-edge credentials)
+			edgeAuth := middleware.EdgeAuthMiddleware(services.GetEdgeAuthService(db))
+			internal.POST("/edge-click", edgeAuth, edgeIngestHandler.IngestEdgeClick)
+			internal.GET("/edge/offer/:trackingCode", edgeAuth, edgeIngestHandler.GetOfferConfig)
+			internal.GET("/edge/stats", edgeAuth, edgeIngestHandler.GetEdgeStats)
 		}
 	}
 
 	port := cfg.Port
-	log.Printf("🚀 Server starting on port %s in %s mode", port, cfg.Environment)
-	if err := router.Run(":" + port); err != nil {
-		log.Fatal("Failed to start server:", err)
+	server := &http.Server{
+		Addr:    ":" + port,
+		Handler: router,
 	}
+	shutdown.Register(services.ShutdownStopIntake, "http", func(ctx context.Context) (int, error) {
+		return 0, server.Shutdown(ctx)
+	})
+
+	go func() {
+		log.Printf("🚀 Server starting on port %s in %s mode", port, cfg.Environment)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal("Failed to start server:", err)
+		}
+	}()
+
+	// Graceful shutdown: flip readiness, stop intake, drain, flush, close stores
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	sig := <-sigChan
+	log.Printf("🛑 Received %v, shutting down gracefully (deadline %v)...", sig, shutdown.Timeout())
+	report := shutdown.Shutdown()
+	log.Printf("👋 Shutdown complete: %d undrained, timed out: %v", report.Undrained, report.TimedOut)
 }
 // Force redeploy Sat Dec  6 18:47:13 +03 2025
 // Rebuild trigger 1765036804
@@ -3,6 +3,7 @@
  */
 
 import type { Env, EdgeClickEvent } from './index';
+import { edgeAuthHeaders } from './edge-auth';
 
 export interface QueuedClick {
   event: EdgeClickEvent;
@@ -167,6 +168,7 @@ export class ClickQueue {
 
     try {
       const events = clicks.map(c => c.event);
+      const body = JSON.stringify({ events });
       
       const response = await fetch(endpoint, {
         method: 'POST',
@@ -174,9 +176,9 @@ export class ClickQueue {
           'Content-Type': 'application/json',
           'X-Edge-Batch': 'true',
           'X-Edge-Count': String(events.length),
-          'X-Edge-Location': 'cloudflare'
+          ...(await edgeAuthHeaders(this.env, 'POST', endpoint, body))
         },
-        body: JSON.stringify({ events })
+        body
       });
 
       if (response.ok) {
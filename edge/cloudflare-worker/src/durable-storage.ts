@@ -4,6 +4,7 @@
  */
 
 import type { Env, EdgeClickEvent } from './index';
+import { edgeAuthHeaders } from './edge-auth';
 
 // ============================================
 // DURABLE OBJECT: CLICK AGGREGATOR
@@ -207,7 +208,8 @@ export class ClickAggregator {
           'Content-Type': 'application/json',
           'X-Edge-Batch': 'true',
           'X-Edge-Count': String(batch.length),
-          'X-Edge-Source': 'durable-object'
+          'X-Edge-Source': 'durable-object',
+          ...(await edgeAuthHeaders(this.env, 'POST', endpoint, body))
         },
         body
       });
@@ -487,14 +489,16 @@ export class OfflineQueueManager {
     const endpoint = `${backendUrl}/api/internal/edge-click`;
 
     try {
+      const body = JSON.stringify({ events });
       const response = await fetch(endpoint, {
         method: 'POST',
         headers: {
           'Content-Type': 'application/json',
           'X-Edge-Batch': 'true',
-          'X-Edge-Count': String(events.length)
+          'X-Edge-Count': String(events.length),
+          ...(await edgeAuthHeaders(this.env, 'POST', endpoint, body))
         },
-        body: JSON.stringify({ events })
+        body
       });
 
       return { success: response.ok };
@@ -0,0 +1,63 @@
+/**
+ * Edge Auth - Signs calls to the backend's internal edge endpoints
+ *
+ * Signature: hex(HMAC-SHA256(EDGE_SECRET,
+ *   METHOD \n PATH \n TIMESTAMP \n NONCE \n hex(SHA256(body))))
+ *
+ * Test vector (also checked by the backend's edge auth tests):
+ *   secret     edge_test_secret_0001
+ *   request    POST /api/internal/edge-click
+ *   timestamp  1767225600
+ *   nonce      0f8e7d6c5b4a39281706f5e4d3c2b1a0
+ *   body       {"events":[{"tracking_code":"abc123"}]}
+ *   signature  e3028dde24e0a31df133efdc503c32e05af7d571b9d1908536c8422595554413
+ */
+
+import type { Env } from './index';
+
+const encoder = new TextEncoder();
+
+/**
+ * Build the auth headers for a backend request
+ */
+export async function edgeAuthHeaders(
+  env: Env,
+  method: string,
+  url: string,
+  body: string = ''
+): Promise<Record<string, string>> {
+  const location = env.EDGE_LOCATION || 'cloudflare';
+  if (!env.EDGE_KEY_ID || !env.EDGE_SECRET) {
+    return { 'X-Edge-Location': location };
+  }
+
+  const timestamp = String(Math.floor(Date.now() / 1000));
+  const nonce = crypto.randomUUID().replace(/-/g, '');
+  const path = new URL(url).pathname;
+
+  const bodyHash = toHex(await crypto.subtle.digest('SHA-256', encoder.encode(body)));
+  const message = `${method.toUpperCase()}\n${path}\n${timestamp}\n${nonce}\n${bodyHash}`;
+
+  const key = await crypto.subtle.importKey(
+    'raw',
+    encoder.encode(env.EDGE_SECRET),
+    { name: 'HMAC', hash: 'SHA-256' },
+    false,
+    ['sign']
+  );
+  const signature = await crypto.subtle.sign('HMAC', key, encoder.encode(message));
+
+  return {
+    'X-Edge-Key-Id': env.EDGE_KEY_ID,
+    'X-Edge-Timestamp': timestamp,
+    'X-Edge-Nonce': nonce,
+    'X-Edge-Signature': toHex(signature),
+    'X-Edge-Location': location
+  };
+}
+
+function toHex(buffer: ArrayBuffer): string {
+  return Array.from(new Uint8Array(buffer))
+    .map(b => b.toString(16).padStart(2, '0'))
+    .join('');
+}
@@ -4,6 +4,7 @@
 
 import type { Env } from './index';
 import type { OfferConfig } from './smart-router';
+import { edgeAuthHeaders } from './edge-auth';
 
 export interface FailoverConfig {
   enabled: boolean;
@@ -260,9 +261,13 @@ export class FailoverManager {
           const data = await this.env.CLICK_QUEUE.get(key.name);
           if (data) {
             // Send to backend
-            const response = await fetch(`${this.env.BACKEND_URL}/api/internal/edge-click`, {
+            const endpoint = `${this.env.BACKEND_URL}/api/internal/edge-click`;
+            const response = await fetch(endpoint, {
               method: 'POST',
-              headers: { 'Content-Type': 'application/json' },
+              headers: {
+                'Content-Type': 'application/json',
+                ...(await edgeAuthHeaders(this.env, 'POST', endpoint, data))
+              },
               body: data
             });
 
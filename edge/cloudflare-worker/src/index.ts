@@ -25,6 +25,9 @@ export interface Env {
   
   // Secrets
   SIGNING_SECRET: string;
+  EDGE_KEY_ID: string;   // Edge credential for the backend's internal endpoints
+  EDGE_SECRET: string;
+  EDGE_LOCATION: string;
   
   // Configuration
   BACKEND_URL: string;
@@ -59,6 +62,11 @@ export interface EdgeClickEvent {
   latency_ms: number;
   router_decision: string;
   final_destination: string;
+  sub1?: string;
+  sub2?: string;
+  sub3?: string;
+  sub4?: string;
+  sub5?: string;
   meta: Record<string, any>;
 }
 
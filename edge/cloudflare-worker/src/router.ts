@@ -12,6 +12,7 @@ import type { SmartRouter, OfferConfig, RoutingDecision } from './smart-router';
 import type { FailoverManager } from './failover';
 import type { EdgeMetrics } from './metrics';
 import type { EdgeConfig } from './config';
+import { edgeAuthHeaders } from './edge-auth';
 
 export interface RouterDependencies {
   linkValidator: LinkValidator;
@@ -145,6 +146,7 @@ export class EdgeRouter {
     }
 
     // 7. Create click event
+    const query = new URL(request.url).searchParams;
     const clickEvent: EdgeClickEvent = {
       tracking_code: trackingCode,
       tenant_id: offerConfig.tenant_id,
@@ -165,6 +167,11 @@ export class EdgeRouter {
       latency_ms: Date.now() - startTime,
       router_decision: routingDecision.rule_applied,
       final_destination: routingDecision.final_destination,
+      sub1: query.get('sub1') || undefined,
+      sub2: query.get('sub2') || undefined,
+      sub3: query.get('sub3') || undefined,
+      sub4: query.get('sub4') || undefined,
+      sub5: query.get('sub5') || undefined,
       meta: {
         variant_id: routingDecision.variant_id,
         rotation_index: routingDecision.rotation_index,
@@ -210,11 +217,12 @@ export class EdgeRouter {
   private async fetchOfferConfig(trackingCode: string): Promise<OfferConfig | null> {
     try {
       const backendUrl = this.deps.config.backendUrl;
-      const response = await fetch(`${backendUrl}/api/internal/edge/offer/${trackingCode}`, {
+      const endpoint = `${backendUrl}/api/internal/edge/offer/${trackingCode}`;
+      const response = await fetch(endpoint, {
         method: 'GET',
         headers: {
           'X-Edge-Request': 'true',
-          'X-Edge-Location': 'cloudflare'
+          ...(await edgeAuthHeaders(this.env, 'GET', endpoint))
         }
       });
 
@@ -2,6 +2,7 @@ package alerting
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -10,6 +11,8 @@ import (
 	"sync"
 	"sync/atomic"
 	"time"
+
+	"github.com/aljapah/afftok-backend-prod/internal/services"
 )
 
 // ============================================
@@ -507,7 +510,7 @@ func (s *SlackChannel) Send(alert *Alert) error {
 	}
 
 	body, _ := json.Marshal(payload)
-	resp, err := http.Post(s.webhookURL, "application/json", bytes.NewBuffer(body))
+	resp, err := services.GetOutboundClient().Post(context.Background(), s.webhookURL, "application/json", bytes.NewBuffer(body))
 	if err != nil {
 		return err
 	}
@@ -607,7 +610,7 @@ func (t *TelegramChannel) Send(alert *Alert) error {
 	}
 
 	body, _ := json.Marshal(payload)
-	resp, err := http.Post(url, "application/json", bytes.NewBuffer(body))
+	resp, err := services.GetOutboundClient().Post(context.Background(), url, "application/json", bytes.NewBuffer(body))
 	if err != nil {
 		return err
 	}
@@ -244,6 +244,47 @@ func GetSet(ctx context.Context, key string, value interface{}) (string, error)
 	return RedisClient.GetSet(ctx, key, value).Result()
 }
 
+// GetDel atomically gets a value and deletes the key
+func GetDel(ctx context.Context, key string) (string, error) {
+	if RedisClient == nil {
+		return "", fmt.Errorf("Redis client not initialized")
+	}
+	return RedisClient.GetDel(ctx, key).Result()
+}
+
+// IsNil reports whether err means the key does not exist
+func IsNil(err error) bool {
+	return err == redis.Nil
+}
+
+// ============================================
+// SET OPERATIONS
+// ============================================
+
+// SAdd adds members to a set
+func SAdd(ctx context.Context, key string, members ...interface{}) (int64, error) {
+	if RedisClient == nil {
+		return 0, fmt.Errorf("Redis client not initialized")
+	}
+	return RedisClient.SAdd(ctx, key, members...).Result()
+}
+
+// SRem removes members from a set
+func SRem(ctx context.Context, key string, members ...interface{}) (int64, error) {
+	if RedisClient == nil {
+		return 0, fmt.Errorf("Redis client not initialized")
+	}
+	return RedisClient.SRem(ctx, key, members...).Result()
+}
+
+// SMembers returns all members of a set
+func SMembers(ctx context.Context, key string) ([]string, error) {
+	if RedisClient == nil {
+		return nil, fmt.Errorf("Redis client not initialized")
+	}
+	return RedisClient.SMembers(ctx, key).Result()
+}
+
 // ============================================
 // LIST OPERATIONS (for queues)
 // ============================================
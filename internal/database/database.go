@@ -128,6 +128,23 @@ func AutoMigrate(db *gorm.DB) error {
 		// Invoices
 		&models.Invoice{},
 		&models.InvoiceItem{},
+		&models.PlatformWebhookSecret{},
+		// Conversion lifecycle
+		&models.ConversionStatusHistory{},
+		// Edge routing
+		&models.OfferRoutingConfig{},
+		&models.EdgeCredential{},
+		// Offer access
+		&models.OfferApplication{},
+		// Deep links
+		&models.DeepLink{},
+
+		// Promoter postbacks
+		&models.PromoterPostback{},
+		&models.PromoterPostbackDelivery{},
+
+		// Badge engine
+		&models.BadgeAward{},
 	)
 
 	if err != nil {
@@ -231,6 +248,10 @@ func createIndexes(db *gorm.DB) {
 		// Network for filtering
 		"CREATE INDEX IF NOT EXISTS idx_offers_network ON offers(network_id)",
 		
+		// Catalog: bilingual full-text search and newest-first cursor pages
+		"CREATE INDEX IF NOT EXISTS idx_offers_search ON offers USING GIN (" + models.OfferSearchDocument + ")",
+		"CREATE INDEX IF NOT EXISTS idx_offers_status_created ON offers(status, created_at DESC, id DESC)",
+		
 		// ============================================
 		// TRACKING EVENTS TABLE
 		// ============================================
@@ -174,6 +174,15 @@ func (h *AdminAPIKeysHandler) CreateAPIKey(c *gin.Context) {
 		return
 	}
 
+	if _, err := models.NormalizeAllowedIPs(req.AllowedIPs); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          "Invalid allowed_ips: " + err.Error(),
+		})
+		return
+	}
+
 	response, err := h.apiKeyService.GenerateAPIKey(advertiserID, &req)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -273,9 +282,9 @@ func (h *AdminAPIKeysHandler) RevokeAPIKey(c *gin.Context) {
 // IP MANAGEMENT
 // ============================================
 
-// AddAllowedIP adds an IP to the API key's allowlist
+// AddAllowedIP adds an IP or CIDR range to the API key's allowlist
 // POST /api/admin/api-keys/:id/allow-ip
-// Body: { "ip": "192.168.1.1" }
+// Body: { "ip": "192.168.1.1" } or { "ip": "10.0.0.0/8" } or { "ip": "2001:db8::/32" }
 func (h *AdminAPIKeysHandler) AddAllowedIP(c *gin.Context) {
 	correlationID := uuid.New().String()[:8]
 
@@ -302,7 +311,17 @@ func (h *AdminAPIKeysHandler) AddAllowedIP(c *gin.Context) {
 		return
 	}
 
-	if err := h.apiKeyService.AddAllowedIP(id, req.IP); err != nil {
+	ip, err := models.NormalizeAllowedIP(req.IP)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          err.Error(),
+		})
+		return
+	}
+
+	if err := h.apiKeyService.AddAllowedIP(id, ip); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success":        false,
 			"correlation_id": correlationID,
@@ -315,7 +334,7 @@ func (h *AdminAPIKeysHandler) AddAllowedIP(c *gin.Context) {
 		"success":        true,
 		"correlation_id": correlationID,
 		"message":        "IP added to allowlist",
-		"ip":             req.IP,
+		"ip":             ip,
 		"timestamp":      time.Now().UTC(),
 	})
 }
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/aljapah/afftok-backend-prod/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ============================================
+// ADMIN EDGE CREDENTIALS
+// ============================================
+
+// CreateEdgeCredentialRequest is the body of an edge credential creation
+type CreateEdgeCredentialRequest struct {
+	Name              string `json:"name"`
+	EdgeLocation      string `json:"edge_location" binding:"required"`
+	RequestsPerMinute int    `json:"requests_per_minute"`
+	EventsPerMinute   int    `json:"events_per_minute"`
+}
+
+// RotateEdgeCredentialRequest is the body of an edge credential rotation
+type RotateEdgeCredentialRequest struct {
+	OverlapHours int `json:"overlap_hours"` // How long the old key stays valid (default 24)
+}
+
+// GetEdgeCredentials lists edge credentials
+// GET /api/admin/edge/credentials
+func (h *AdminEdgeHandler) GetEdgeCredentials(c *gin.Context) {
+	correlationID := uuid.New().String()[:8]
+
+	credentials, err := h.authService.ListCredentials()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          "Failed to fetch edge credentials: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":        true,
+		"correlation_id": correlationID,
+		"data": gin.H{
+			"credentials": credentials,
+			"count":       len(credentials),
+		},
+		"timestamp": time.Now().UTC(),
+	})
+}
+
+// CreateEdgeCredential issues a key for an edge location. The secret is
+// only returned here.
+// POST /api/admin/edge/credentials
+func (h *AdminEdgeHandler) CreateEdgeCredential(c *gin.Context) {
+	correlationID := uuid.New().String()[:8]
+
+	var req CreateEdgeCredentialRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          "Invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	credential, secret, err := h.authService.CreateCredential(req.Name, req.EdgeLocation, req.RequestsPerMinute, req.EventsPerMinute)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success":        true,
+		"correlation_id": correlationID,
+		"data": gin.H{
+			"credential": credential,
+			"secret":     secret,
+		},
+		"message":   "Store the secret now; it won't be shown again",
+		"timestamp": time.Now().UTC(),
+	})
+}
+
+// RotateEdgeCredential issues a replacement key; the old one stays valid for
+// the overlap period
+// POST /api/admin/edge/credentials/:id/rotate
+func (h *AdminEdgeHandler) RotateEdgeCredential(c *gin.Context) {
+	correlationID := uuid.New().String()[:8]
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          "Invalid credential ID",
+		})
+		return
+	}
+
+	var req RotateEdgeCredentialRequest
+	_ = c.ShouldBindJSON(&req) // Body is optional
+
+	credential, secret, err := h.authService.RotateCredential(id, time.Duration(req.OverlapHours)*time.Hour)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, services.ErrEdgeCredentialNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":        true,
+		"correlation_id": correlationID,
+		"data": gin.H{
+			"credential":      credential,
+			"secret":          secret,
+			"replaces_key_id": id,
+		},
+		"message":   "Store the secret now; it won't be shown again",
+		"timestamp": time.Now().UTC(),
+	})
+}
+
+// RevokeEdgeCredential stops accepting a key immediately
+// DELETE /api/admin/edge/credentials/:id
+func (h *AdminEdgeHandler) RevokeEdgeCredential(c *gin.Context) {
+	correlationID := uuid.New().String()[:8]
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          "Invalid credential ID",
+		})
+		return
+	}
+
+	if err := h.authService.RevokeCredential(id); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrEdgeCredentialNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":        true,
+		"correlation_id": correlationID,
+		"message":        "Edge credential revoked",
+		"timestamp":      time.Now().UTC(),
+	})
+}
@@ -277,12 +277,8 @@ func (h *AdminFraudHandler) UnblockIP(c *gin.Context) {
 		return
 	}
 
-	// Remove blocked IP from Redis
-	if cache.RedisClient != nil {
-		ctx := context.Background()
-		key := services.NSFraud + "blocked_ip:" + req.IP
-		cache.RedisClient.Del(ctx, key)
-	}
+	// Remove the block from Redis (both block stores)
+	services.GetThreatDetector().UnblockIP(req.IP)
 
 	c.JSON(http.StatusOK, gin.H{
 		"success":        true,
@@ -425,6 +425,7 @@ func (h *AdminGeoRulesHandler) GetGeoRuleStats(c *gin.Context) {
 				"cache_hits":      metrics.CacheHits,
 				"cache_misses":    metrics.CacheMisses,
 			},
+			"geoip": services.GetGeoIPService().GetStatus(),
 		},
 		"timestamp": time.Now().UTC(),
 	})
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/aljapah/afftok-backend-prod/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ============================================
+// ADMIN PLATFORM WEBHOOK SECRETS HANDLER
+// ============================================
+
+// AdminPlatformWebhooksHandler manages advertisers' Shopify/Salla/Zid webhook secrets
+type AdminPlatformWebhooksHandler struct {
+	db              *gorm.DB
+	platformService *services.PlatformWebhookService
+}
+
+// NewAdminPlatformWebhooksHandler creates a new admin platform webhooks handler
+func NewAdminPlatformWebhooksHandler(db *gorm.DB) *AdminPlatformWebhooksHandler {
+	return &AdminPlatformWebhooksHandler{
+		db:              db,
+		platformService: services.NewPlatformWebhookService(db),
+	}
+}
+
+// GetWebhookSecrets lists an advertiser's platform webhook secrets (hints only)
+// GET /api/admin/advertisers/:id/webhook-secrets
+func (h *AdminPlatformWebhooksHandler) GetWebhookSecrets(c *gin.Context) {
+	correlationID := uuid.New().String()[:8]
+
+	advertiserID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          "Invalid advertiser ID",
+		})
+		return
+	}
+
+	secrets, err := h.platformService.ListSecrets(advertiserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          "Failed to fetch webhook secrets: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":        true,
+		"correlation_id": correlationID,
+		"data":           secrets,
+		"metrics":        services.GetPlatformWebhookMetrics(),
+		"timestamp":      time.Now().UTC(),
+	})
+}
+
+// SetWebhookSecret registers or rotates an advertiser's secret for a platform.
+// The secret is returned once; omit it to have one generated.
+// PUT /api/admin/advertisers/:id/webhook-secrets/:platform
+func (h *AdminPlatformWebhooksHandler) SetWebhookSecret(c *gin.Context) {
+	correlationID := uuid.New().String()[:8]
+
+	advertiserID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          "Invalid advertiser ID",
+		})
+		return
+	}
+
+	platform := c.Param("platform")
+	if !models.IsValidWebhookPlatform(platform) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          "Unsupported platform (use shopify, salla or zid)",
+		})
+		return
+	}
+
+	var req struct {
+		Secret     string `json:"secret"`
+		GraceHours int    `json:"grace_hours"` // How long the previous secret stays valid (default 24)
+	}
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          "Invalid request: " + err.Error(),
+		})
+		return
+	}
+	if req.GraceHours < 0 || req.GraceHours > 24*30 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          "grace_hours must be between 0 and 720",
+		})
+		return
+	}
+
+	var advertiser models.AfftokUser
+	if err := h.db.Where("id = ? AND role = ?", advertiserID, "advertiser").First(&advertiser).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          "Advertiser not found",
+		})
+		return
+	}
+
+	record, secret, err := h.platformService.SetSecret(advertiserID, platform, req.Secret, time.Duration(req.GraceHours)*time.Hour)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          "Failed to save webhook secret: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":        true,
+		"correlation_id": correlationID,
+		"data": gin.H{
+			"secret":  record,
+			"value":   secret,
+			"rotated": record.RotatedAt != nil,
+		},
+		"message":   "Webhook secret saved. Store the value now; it will not be shown again.",
+		"timestamp": time.Now().UTC(),
+	})
+}
+
+// DeleteWebhookSecret removes an advertiser's secret for a platform
+// DELETE /api/admin/advertisers/:id/webhook-secrets/:platform
+func (h *AdminPlatformWebhooksHandler) DeleteWebhookSecret(c *gin.Context) {
+	correlationID := uuid.New().String()[:8]
+
+	advertiserID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          "Invalid advertiser ID",
+		})
+		return
+	}
+
+	if err := h.platformService.DeleteSecret(advertiserID, c.Param("platform")); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrWebhookSecretNotConfigured) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":        true,
+		"correlation_id": correlationID,
+		"message":        "Webhook secret deleted",
+		"timestamp":      time.Now().UTC(),
+	})
+}
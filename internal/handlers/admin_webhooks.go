@@ -153,6 +153,15 @@ func (h *AdminWebhooksHandler) CreatePipeline(c *gin.Context) {
 		return
 	}
 
+	if err := services.ValidateWebhookSteps(req.Steps, h.webhookService.TenantID(req.AdvertiserID)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          err.Error(),
+		})
+		return
+	}
+
 	// Set defaults
 	if req.Status == "" {
 		req.Status = models.WebhookPipelineStatusDraft
@@ -224,6 +233,15 @@ func (h *AdminWebhooksHandler) UpdatePipeline(c *gin.Context) {
 
 	pipeline.ID = pipelineID
 
+	if err := services.ValidateWebhookSteps(pipeline.Steps, h.webhookService.TenantID(pipeline.AdvertiserID)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          err.Error(),
+		})
+		return
+	}
+
 	if err := h.webhookService.UpdatePipeline(&pipeline); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success":        false,
@@ -568,6 +586,15 @@ func (h *AdminWebhooksHandler) TestStep(c *gin.Context) {
 		return
 	}
 
+	if err := services.ValidateWebhookConditions(req.Step.Conditions); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          "Invalid conditions: " + err.Error(),
+		})
+		return
+	}
+
 	// Use default test payload if not provided
 	if req.Payload == nil {
 		req.Payload = map[string]interface{}{
@@ -596,6 +623,8 @@ func (h *AdminWebhooksHandler) TestStep(c *gin.Context) {
 			"response_body": result.ResponseBody,
 			"error":         result.Error,
 			"duration_ms":   result.DurationMs,
+			"skipped":       result.Skipped,
+			"skip_reason":   result.SkipReason,
 		},
 		"timestamp": time.Now().UTC(),
 	})
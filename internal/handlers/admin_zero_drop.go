@@ -97,6 +97,7 @@ func (h *AdminZeroDropHandler) GetStatus(c *gin.Context) {
 				"file_count":       walStats["file_count"],
 				"is_running":       walStats["is_running"],
 			},
+			"pipeline": services.GetZeroDropPipelineMetrics(),
 			"failover_queue": gin.H{
 				"buffer_size":     queueStats["buffer_size"],
 				"max_buffer_size": queueStats["max_buffer_size"],
@@ -2,9 +2,11 @@ package handlers
 
 import (
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/aljapah/afftok-backend-prod/internal/services"
 	"github.com/aljapah/afftok-backend-prod/pkg/utils"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -13,12 +15,16 @@ import (
 
 // AdvertiserHandler handles advertiser-specific operations
 type AdvertiserHandler struct {
-	db *gorm.DB
+	db            *gorm.DB
+	accessService *services.OfferAccessService
 }
 
 // NewAdvertiserHandler creates a new advertiser handler
 func NewAdvertiserHandler(db *gorm.DB) *AdvertiserHandler {
-	return &AdvertiserHandler{db: db}
+	return &AdvertiserHandler{
+		db:            db,
+		accessService: services.NewOfferAccessService(db),
+	}
 }
 
 // AdvertiserRegisterRequest represents the advertiser registration request
@@ -84,8 +90,8 @@ func (h *AdvertiserHandler) RegisterAdvertiser(c *gin.Context) {
 		return
 	}
 
-	// Generate JWT token
-	token, err := utils.GenerateToken(user.ID, user.Username, user.Email, user.Role)
+	// Start a login session
+	tokens, err := services.GetTokenStore().CreateSession(user.ID, user.Username, user.Email, user.Role)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
@@ -101,7 +107,8 @@ func (h *AdvertiserHandler) RegisterAdvertiser(c *gin.Context) {
 			"company_name": user.CompanyName,
 			"role":         user.Role,
 		},
-		"access_token": token,
+		"access_token":  tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
 	})
 }
 
@@ -253,6 +260,21 @@ type CreateOfferRequest struct {
 	Payout         int    `json:"payout"`
 	Commission     int    `json:"commission"`
 	PayoutType     string `json:"payout_type"`
+
+	// Attribution window in days (default 30) and policy for late conversions (flag, reject)
+	AttributionWindowDays int    `json:"attribution_window_days"`
+	AttributionPolicy     string `json:"attribution_policy"`
+
+	// Query string added to the destination ({click_id}, {sub1}..{sub5},
+	// {country}, ...) and extra query parameters promoters may pass through
+	TrackingTemplate  string   `json:"tracking_template"`
+	PassthroughParams []string `json:"passthrough_params"`
+
+	// Who may join: public (default), approval or invite_only
+	Visibility string `json:"visibility"`
+
+	// Domains promoters may deep link to, e.g. the store's product pages
+	DeepLinkDomains []string `json:"deep_link_domains"`
 }
 
 // CreateOffer creates a new offer for the advertiser (pending approval)
@@ -301,24 +323,64 @@ func (h *AdvertiserHandler) CreateOffer(c *gin.Context) {
 		payoutType = "cpa"
 	}
 
+	if err := validateAttributionSettings(req.AttributionWindowDays, req.AttributionPolicy); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	attributionWindowDays := req.AttributionWindowDays
+	if attributionWindowDays == 0 {
+		attributionWindowDays = models.DefaultAttributionWindowDays
+	}
+	attributionPolicy := req.AttributionPolicy
+	if attributionPolicy == "" {
+		attributionPolicy = models.AttributionPolicyFlag
+	}
+
+	passthroughParams, err := normalizeTrackingSettings(req.TrackingTemplate, req.PassthroughParams)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	deepLinkDomains, err := services.NormalizeDeepLinkDomains(req.DeepLinkDomains)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	visibility := req.Visibility
+	if visibility == "" {
+		visibility = models.OfferVisibilityPublic
+	}
+	if !models.IsValidOfferVisibility(visibility) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "visibility must be 'public', 'approval' or 'invite_only'"})
+		return
+	}
+
 	// Create offer with pending status
 	offer := models.Offer{
-		AdvertiserID:   &advertiserID,
-		Title:          req.Title,
-		TitleAr:        req.TitleAr,
-		Description:    req.Description,
-		DescriptionAr:  req.DescriptionAr,
-		TermsAr:        req.TermsAr,
-		ImageURL:       req.ImageURL,
-		LogoURL:        req.LogoURL,
-		DestinationURL: req.DestinationURL,
-		Category:       req.Category,
-		Payout:         req.Payout,
-		Commission:     req.Commission,
-		PayoutType:     payoutType,
-		Status:         "pending", // Always pending for advertiser-created offers
-		CreatedAt:      time.Now(),
-		UpdatedAt:      time.Now(),
+		AdvertiserID:          &advertiserID,
+		Title:                 req.Title,
+		TitleAr:               req.TitleAr,
+		Description:           req.Description,
+		DescriptionAr:         req.DescriptionAr,
+		TermsAr:               req.TermsAr,
+		ImageURL:              req.ImageURL,
+		LogoURL:               req.LogoURL,
+		DestinationURL:        req.DestinationURL,
+		Category:              req.Category,
+		Payout:                req.Payout,
+		Commission:            req.Commission,
+		PayoutType:            payoutType,
+		AttributionWindowDays: attributionWindowDays,
+		AttributionPolicy:     attributionPolicy,
+		TrackingTemplate:      req.TrackingTemplate,
+		PassthroughParams:     passthroughParams,
+		Visibility:            visibility,
+		DeepLinkDomains:       deepLinkDomains,
+		Status:                "pending", // Always pending for advertiser-created offers
+		CreatedAt:             time.Now(),
+		UpdatedAt:             time.Now(),
 	}
 
 	if err := h.db.Create(&offer).Error; err != nil {
@@ -454,6 +516,58 @@ func (h *AdvertiserHandler) GetOfferStats(c *gin.Context) {
 	})
 }
 
+// GetOfferSubIDReport breaks an offer's clicks and conversions down by a
+// sub-ID across all its promoters
+// GET /api/advertiser/offers/:id/sub-ids?group_by=sub1&days=30
+func (h *AdvertiserHandler) GetOfferSubIDReport(c *gin.Context) {
+	advertiserID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	offerID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid offer ID"})
+		return
+	}
+
+	// Verify ownership
+	var offer models.Offer
+	if err := h.db.Select("id").First(&offer, "id = ? AND advertiser_id = ?", offerID, advertiserID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Offer not found or not owned by you"})
+		return
+	}
+
+	report, err := services.NewSubIDReportService(h.db).ForOffer(offer.ID, subIDReportQuery(c))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// GetOfferDeepLinkReport breaks an offer's deep link clicks and conversions
+// down by target URL across all its promoters
+// GET /api/advertiser/offers/:id/deep-links?days=30
+func (h *AdvertiserHandler) GetOfferDeepLinkReport(c *gin.Context) {
+	offer, ok := h.ownedOffer(c)
+	if !ok {
+		return
+	}
+
+	days, _ := strconv.Atoi(c.Query("days"))
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	report, err := services.NewDeepLinkService(h.db).ReportForOffer(offer.ID, days, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build deep link report"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
 // UpdateOffer updates an advertiser's offer (only if pending)
 // PUT /api/advertiser/offers/:id
 func (h *AdvertiserHandler) UpdateOffer(c *gin.Context) {
@@ -517,6 +631,40 @@ func (h *AdvertiserHandler) UpdateOffer(c *gin.Context) {
 		updates["payout_type"] = req.PayoutType
 	}
 
+	if err := validateAttributionSettings(req.AttributionWindowDays, req.AttributionPolicy); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.AttributionWindowDays > 0 {
+		updates["attribution_window_days"] = req.AttributionWindowDays
+	}
+	if req.AttributionPolicy != "" {
+		updates["attribution_policy"] = req.AttributionPolicy
+	}
+
+	passthroughParams, err := normalizeTrackingSettings(req.TrackingTemplate, req.PassthroughParams)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	updates["tracking_template"] = req.TrackingTemplate
+	updates["passthrough_params"] = passthroughParams
+
+	deepLinkDomains, err := services.NormalizeDeepLinkDomains(req.DeepLinkDomains)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	updates["deep_link_domains"] = deepLinkDomains
+
+	if req.Visibility != "" {
+		if !models.IsValidOfferVisibility(req.Visibility) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "visibility must be 'public', 'approval' or 'invite_only'"})
+			return
+		}
+		updates["visibility"] = req.Visibility
+	}
+
 	if err := h.db.Model(&offer).Updates(updates).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update offer"})
 		return
@@ -805,14 +953,19 @@ func (h *AdvertiserHandler) GetPromoters(c *gin.Context) {
 
 	// Build response
 	type PromoterResponse struct {
-		Username      string    `json:"username"`
-		FullName      string    `json:"full_name"`
-		Email         string    `json:"email"`
-		Clicks        int       `json:"clicks"`
-		Conversions   int       `json:"conversions"`
-		PaymentMethod string    `json:"payment_method"`
-		OfferTitle    string    `json:"offer_title"`
-		JoinedAt      time.Time `json:"joined_at"`
+		UserID         uuid.UUID `json:"user_id"`
+		OfferID        uuid.UUID `json:"offer_id"`
+		UserOfferID    uuid.UUID `json:"user_offer_id"`
+		Status         string    `json:"status"`
+		PayoutOverride *int      `json:"payout_override,omitempty"`
+		Username       string    `json:"username"`
+		FullName       string    `json:"full_name"`
+		Email          string    `json:"email"`
+		Clicks         int       `json:"clicks"`
+		Conversions    int       `json:"conversions"`
+		PaymentMethod  string    `json:"payment_method"`
+		OfferTitle     string    `json:"offer_title"`
+		JoinedAt       time.Time `json:"joined_at"`
 	}
 
 	var promoters []PromoterResponse
@@ -821,14 +974,19 @@ func (h *AdvertiserHandler) GetPromoters(c *gin.Context) {
 			continue
 		}
 		promoters = append(promoters, PromoterResponse{
-			Username:      uo.User.Username,
-			FullName:      uo.User.FullName,
-			Email:         uo.User.Email,
-			Clicks:        uo.TotalClicks,
-			Conversions:   uo.TotalConversions,
-			PaymentMethod: uo.User.PaymentMethod,
-			OfferTitle:    offerMap[uo.OfferID],
-			JoinedAt:      uo.JoinedAt,
+			UserID:         uo.UserID,
+			OfferID:        uo.OfferID,
+			UserOfferID:    uo.ID,
+			Status:         uo.Status,
+			PayoutOverride: uo.PayoutOverride,
+			Username:       uo.User.Username,
+			FullName:       uo.User.FullName,
+			Email:          uo.User.Email,
+			Clicks:         uo.TotalClicks,
+			Conversions:    uo.TotalConversions,
+			PaymentMethod:  uo.User.PaymentMethod,
+			OfferTitle:     offerMap[uo.OfferID],
+			JoinedAt:       uo.JoinedAt,
 		})
 	}
 
@@ -0,0 +1,284 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/aljapah/afftok-backend-prod/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ============================================
+// OFFER ACCESS (ADVERTISER)
+// ============================================
+
+// GetOfferApplications lists the applications and invites of an offer
+// GET /api/advertiser/offers/:id/applications?status=pending
+func (h *AdvertiserHandler) GetOfferApplications(c *gin.Context) {
+	offer, ok := h.ownedOffer(c)
+	if !ok {
+		return
+	}
+
+	applications, err := h.accessService.ListForOffer(offer.ID, c.Query("status"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch applications"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"visibility":   offer.Visibility,
+		"applications": applications,
+		"total":        len(applications),
+	})
+}
+
+// SetOfferVisibility changes who may join an offer. Unlike UpdateOffer it
+// works on active offers and doesn't send the offer back for review.
+// PUT /api/advertiser/offers/:id/visibility
+func (h *AdvertiserHandler) SetOfferVisibility(c *gin.Context) {
+	offer, ok := h.ownedOffer(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Visibility string `json:"visibility" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !models.IsValidOfferVisibility(req.Visibility) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "visibility must be 'public', 'approval' or 'invite_only'"})
+		return
+	}
+
+	if err := h.db.Model(offer).Update("visibility", req.Visibility).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update offer"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Offer visibility updated",
+		"visibility": req.Visibility,
+	})
+}
+
+// InvitePromoter lets a promoter join an offer, optionally with a payout
+// override. Inviting a revoked promoter restores their access.
+// POST /api/advertiser/offers/:id/invite
+func (h *AdvertiserHandler) InvitePromoter(c *gin.Context) {
+	offer, ok := h.ownedOffer(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		UserID         string `json:"user_id"`
+		Username       string `json:"username"`
+		PayoutOverride *int   `json:"payout_override"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var promoter models.AfftokUser
+	query := h.db.Select("id", "username", "role")
+	var err error
+	switch {
+	case req.UserID != "":
+		err = query.First(&promoter, "id = ?", req.UserID).Error
+	case req.Username != "":
+		err = query.First(&promoter, "username = ?", req.Username).Error
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "user_id or username is required"})
+		return
+	}
+	if err != nil || promoter.Role == "advertiser" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Promoter not found"})
+		return
+	}
+
+	application, err := h.accessService.Invite(offer, promoter.ID, *offer.AdvertiserID, req.PayoutOverride)
+	if err != nil {
+		h.respondAccessError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":     "Promoter invited",
+		"application": application,
+	})
+}
+
+// ApproveApplication approves an application, optionally with a payout override
+// POST /api/advertiser/applications/:id/approve
+func (h *AdvertiserHandler) ApproveApplication(c *gin.Context) {
+	application, advertiserID, ok := h.ownedApplication(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		PayoutOverride *int   `json:"payout_override"`
+		Note           string `json:"note"`
+	}
+	// Body is optional
+	_ = c.ShouldBindJSON(&req)
+
+	if err := h.accessService.Approve(application, advertiserID, req.PayoutOverride, req.Note); err != nil {
+		h.respondAccessError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "Application approved",
+		"application": application,
+	})
+}
+
+// RejectApplication rejects a pending application
+// POST /api/advertiser/applications/:id/reject
+func (h *AdvertiserHandler) RejectApplication(c *gin.Context) {
+	application, advertiserID, ok := h.ownedApplication(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	_ = c.ShouldBindJSON(&req)
+
+	if err := h.accessService.Reject(application, advertiserID, req.Reason); err != nil {
+		h.respondAccessError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "Application rejected",
+		"application": application,
+	})
+}
+
+// SetApplicationPayout sets or clears (null) an approved promoter's payout
+// override
+// PUT /api/advertiser/applications/:id/payout
+func (h *AdvertiserHandler) SetApplicationPayout(c *gin.Context) {
+	application, _, ok := h.ownedApplication(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		PayoutOverride *int `json:"payout_override"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.accessService.SetPayoutOverride(application, req.PayoutOverride); err != nil {
+		h.respondAccessError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "Payout override updated",
+		"application": application,
+	})
+}
+
+// RevokePromoter removes a promoter from an offer and deactivates their
+// user offer and its tracking links
+// POST /api/advertiser/offers/:id/promoters/:userId/revoke
+func (h *AdvertiserHandler) RevokePromoter(c *gin.Context) {
+	offer, ok := h.ownedOffer(c)
+	if !ok {
+		return
+	}
+
+	promoterID, err := uuid.Parse(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	_ = c.ShouldBindJSON(&req)
+
+	application, err := h.accessService.Revoke(offer, promoterID, *offer.AdvertiserID, req.Reason)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke promoter"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "Promoter revoked",
+		"application": application,
+	})
+}
+
+// ownedOffer loads the :id offer if the current advertiser owns it, otherwise
+// responds with an error
+func (h *AdvertiserHandler) ownedOffer(c *gin.Context) (*models.Offer, bool) {
+	advertiserID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return nil, false
+	}
+
+	offerID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid offer ID"})
+		return nil, false
+	}
+
+	var offer models.Offer
+	if err := h.db.First(&offer, "id = ? AND advertiser_id = ?", offerID, advertiserID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Offer not found or not owned by you"})
+		return nil, false
+	}
+	return &offer, true
+}
+
+// ownedApplication loads the :id application if it belongs to one of the
+// current advertiser's offers, otherwise responds with an error
+func (h *AdvertiserHandler) ownedApplication(c *gin.Context) (*models.OfferApplication, uuid.UUID, bool) {
+	advertiserID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return nil, uuid.Nil, false
+	}
+
+	applicationID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid application ID"})
+		return nil, uuid.Nil, false
+	}
+
+	application, err := h.accessService.GetForAdvertiser(applicationID, advertiserID)
+	if err != nil {
+		if errors.Is(err, services.ErrOfferApplicationNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch application"})
+		}
+		return nil, uuid.Nil, false
+	}
+	return application, advertiserID, true
+}
+
+func (h *AdvertiserHandler) respondAccessError(c *gin.Context, err error) {
+	if errors.Is(err, services.ErrOfferApplicationState) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+}
@@ -19,6 +19,8 @@ import (
 type AuthHandler struct {
 	db                   *gorm.DB
 	observabilityService *services.ObservabilityService
+	tokenStore           *services.TokenStore
+	threatDetector       *services.ThreatDetector
 }
 
 type GoogleClaims struct {
@@ -31,6 +33,8 @@ func NewAuthHandler(db *gorm.DB) *AuthHandler {
 	return &AuthHandler{
 		db:                   db,
 		observabilityService: services.NewObservabilityService(),
+		tokenStore:           services.GetTokenStore(),
+		threatDetector:       services.GetThreatDetector(),
 	}
 }
 
@@ -126,18 +130,12 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
-	accessToken, err := utils.GenerateToken(user.ID, user.Username, user.Email, user.Role)
+	tokens, err := h.tokenStore.CreateSession(user.ID, user.Username, user.Email, user.Role)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
 
-	refreshToken, err := utils.GenerateRefreshToken(user.ID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate refresh token"})
-		return
-	}
-
 	user.PasswordHash = ""
 
 	// Log successful registration
@@ -146,8 +144,8 @@ func (h *AuthHandler) Register(c *gin.Context) {
 	c.JSON(http.StatusCreated, gin.H{
 		"message":       "User registered successfully",
 		"user":          user,
-		"access_token":  accessToken,
-		"refresh_token": refreshToken,
+		"access_token":  tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
 	})
 }
 
@@ -166,7 +164,7 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	var user models.AfftokUser
 	if err := h.db.Where("username = ? OR email = ?", req.Username, req.Username).First(&user).Error; err != nil {
 		h.observabilityService.LogAuth("", req.Username, c.ClientIP(), "login", false, "user_not_found")
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		h.respondLoginFailure(c, "")
 		return
 	}
 
@@ -178,35 +176,40 @@ func (h *AuthHandler) Login(c *gin.Context) {
 
 	if !utils.CheckPassword(user.PasswordHash, req.Password) {
 		h.observabilityService.LogAuth(user.ID.String(), user.Username, c.ClientIP(), "login", false, "invalid_password")
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		h.respondLoginFailure(c, user.ID.String())
 		return
 	}
 
-	accessToken, err := utils.GenerateToken(user.ID, user.Username, user.Email, user.Role)
+	tokens, err := h.tokenStore.CreateSession(user.ID, user.Username, user.Email, user.Role)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
 
-	refreshToken, err := utils.GenerateRefreshToken(user.ID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate refresh token"})
-		return
-	}
-
 	user.PasswordHash = ""
 
 	// Log successful login
 	h.observabilityService.LogAuth(user.ID.String(), user.Username, c.ClientIP(), "login", true, "")
+	h.threatDetector.DetectLoginAnomaly(c.ClientIP(), user.ID.String(), true)
 
 	c.JSON(http.StatusOK, gin.H{
 		"message":       "Login successful",
 		"user":          user,
-		"access_token":  accessToken,
-		"refresh_token": refreshToken,
+		"access_token":  tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
 	})
 }
 
+// respondLoginFailure feeds a failed login to the threat detector, which
+// blocks the IP once it has too many failures
+func (h *AuthHandler) respondLoginFailure(c *gin.Context, userID string) {
+	if threat := h.threatDetector.DetectLoginAnomaly(c.ClientIP(), userID, false); threat != nil {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many failed login attempts, please try again later"})
+		return
+	}
+	c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+}
+
 func (h *AuthHandler) GoogleSignIn(c *gin.Context) {
 	type GoogleSignInRequest struct {
 		IDToken string `json:"idToken" binding:"required"`
@@ -231,25 +234,19 @@ func (h *AuthHandler) GoogleSignIn(c *gin.Context) {
 			return
 		}
 
-		accessToken, err := utils.GenerateToken(user.ID, user.Username, user.Email, user.Role)
+		tokens, err := h.tokenStore.CreateSession(user.ID, user.Username, user.Email, user.Role)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 			return
 		}
 
-		refreshToken, err := utils.GenerateRefreshToken(user.ID)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate refresh token"})
-			return
-		}
-
 		user.PasswordHash = ""
 
 		c.JSON(http.StatusOK, gin.H{
 			"message":       "Login successful",
 			"user":          user,
-			"access_token":  accessToken,
-			"refresh_token": refreshToken,
+			"access_token":  tokens.AccessToken,
+			"refresh_token": tokens.RefreshToken,
 		})
 		return
 	}
@@ -280,25 +277,19 @@ func (h *AuthHandler) GoogleSignIn(c *gin.Context) {
 		return
 	}
 
-	accessToken, err := utils.GenerateToken(newUser.ID, newUser.Username, newUser.Email, newUser.Role)
+	tokens, err := h.tokenStore.CreateSession(newUser.ID, newUser.Username, newUser.Email, newUser.Role)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
 
-	refreshToken, err := utils.GenerateRefreshToken(newUser.ID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate refresh token"})
-		return
-	}
-
 	newUser.PasswordHash = ""
 
 	c.JSON(http.StatusCreated, gin.H{
 		"message":       "User created and logged in successfully",
 		"user":          newUser,
-		"access_token":  accessToken,
-		"refresh_token": refreshToken,
+		"access_token":  tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
 	})
 }
 
@@ -405,7 +396,7 @@ func (h *AuthHandler) RefreshToken(c *gin.Context) {
 	}
 
 	claims, err := utils.ValidateToken(req.RefreshToken)
-	if err != nil {
+	if err != nil || !utils.IsRefreshToken(claims) {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
 		return
 	}
@@ -416,19 +407,100 @@ func (h *AuthHandler) RefreshToken(c *gin.Context) {
 		return
 	}
 
-	accessToken, err := utils.GenerateToken(user.ID, user.Username, user.Email, user.Role)
+	if user.Status == "suspended" {
+		h.tokenStore.RevokeSession(user.ID, claims.SessionID)
+		c.JSON(http.StatusForbidden, gin.H{"error": "Account is suspended"})
+		return
+	}
+
+	tokens, err := h.tokenStore.RotateRefreshToken(claims, user.Username, user.Email, user.Role)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		switch err {
+		case services.ErrTokenReused:
+			h.observabilityService.LogAuth(user.ID.String(), user.Username, c.ClientIP(), "refresh", false, "refresh_token_reused")
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token already used, please log in again"})
+		case services.ErrTokenRevoked:
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has been revoked, please log in again"})
+		default:
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Failed to refresh session"})
+		}
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"access_token": accessToken,
+		"access_token":  tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
 	})
 }
 
+// Logout revokes the current session. The access token is taken from the
+// Authorization header and/or the refresh token from the body; either is enough.
 func (h *AuthHandler) Logout(c *gin.Context) {
+	var req struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	c.ShouldBindJSON(&req)
+
+	revoked := false
+
+	if accessClaims := bearerClaims(c); accessClaims != nil && !utils.IsRefreshToken(accessClaims) {
+		h.tokenStore.RevokeAccessToken(accessClaims)
+		h.tokenStore.RevokeSession(accessClaims.UserID, accessClaims.SessionID)
+		revoked = true
+	}
+
+	if req.RefreshToken != "" {
+		if refreshClaims, err := utils.ValidateToken(req.RefreshToken); err == nil && utils.IsRefreshToken(refreshClaims) {
+			h.tokenStore.RevokeSession(refreshClaims.UserID, refreshClaims.SessionID)
+			revoked = true
+		}
+	}
+
+	if !revoked {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A valid access or refresh token is required"})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Logout successful",
 	})
-}
\ No newline at end of file
+}
+
+// LogoutAll revokes every session of the authenticated user ("log out all devices")
+func (h *AuthHandler) LogoutAll(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	if claims := bearerClaims(c); claims != nil {
+		h.tokenStore.RevokeAccessToken(claims)
+	}
+
+	count, err := h.tokenStore.RevokeAllSessions(userID.(uuid.UUID))
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Failed to revoke sessions"})
+		return
+	}
+
+	h.observabilityService.LogAuth(userID.(uuid.UUID).String(), "", c.ClientIP(), "logout_all", true, "")
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":          "Logged out from all devices",
+		"revoked_sessions": count,
+	})
+}
+
+// bearerClaims returns the validated claims of the Authorization bearer token, if any
+func bearerClaims(c *gin.Context) *utils.Claims {
+	parts := strings.SplitN(c.GetHeader("Authorization"), " ", 2)
+	if len(parts) != 2 || parts[0] != "Bearer" {
+		return nil
+	}
+	claims, err := utils.ValidateToken(strings.TrimSpace(parts[1]))
+	if err != nil {
+		return nil
+	}
+	return claims
+}
@@ -2,8 +2,11 @@ package handlers
 
 import (
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/aljapah/afftok-backend-prod/internal/services"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
@@ -44,54 +47,126 @@ func (h *BadgeHandler) GetMyBadges(c *gin.Context) {
 	})
 }
 
+// CheckAndAwardBadges evaluates every badge for a user and awards those earned
 func (h *BadgeHandler) CheckAndAwardBadges(userID uuid.UUID) error {
-	var user models.AfftokUser
-	if err := h.db.First(&user, "id = ?", userID).Error; err != nil {
-		return err
+	_, err := services.GetBadgeEngine(h.db).EvaluateUser(userID)
+	return err
+}
+
+// GetBadgeNotifications returns the badges awarded to the user that they
+// haven't seen yet
+// GET /api/badges/notifications
+func (h *BadgeHandler) GetBadgeNotifications(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
 	}
 
-	var badges []models.Badge
-	h.db.Find(&badges)
+	var userBadges []models.UserBadge
+	if err := h.db.Preload("Badge").
+		Where("user_id = ? AND seen_at IS NULL", userID).
+		Order("earned_at DESC").
+		Find(&userBadges).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch badge notifications"})
+		return
+	}
 
-	for _, badge := range badges {
-		var existingUserBadge models.UserBadge
-		if err := h.db.Where("user_id = ? AND badge_id = ?", userID, badge.ID).First(&existingUserBadge).Error; err == nil {
-			continue
-		}
+	c.JSON(http.StatusOK, gin.H{
+		"notifications": userBadges,
+		"count":         len(userBadges),
+	})
+}
+
+// MarkBadgeNotificationsSeen marks the user's badge notifications as seen,
+// either the given ones or all of them
+// POST /api/badges/notifications/seen
+func (h *BadgeHandler) MarkBadgeNotificationsSeen(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
 
-		earned := false
-		switch badge.Criteria {
-		case "conversions":
-			if user.TotalConversions >= badge.RequiredValue {
-				earned = true
-			}
-		case "clicks":
-			if user.TotalClicks >= badge.RequiredValue {
-				earned = true
-			}
-		case "earnings":
-			if user.TotalEarnings >= badge.RequiredValue {
-				earned = true
-			}
-		case "points":
-			if user.Points >= badge.RequiredValue {
-				earned = true
-			}
+	var req struct {
+		IDs []uuid.UUID `json:"ids"` // User badge IDs, empty = all
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
 		}
+	}
+
+	query := h.db.Model(&models.UserBadge{}).Where("user_id = ? AND seen_at IS NULL", userID)
+	if len(req.IDs) > 0 {
+		query = query.Where("id IN ?", req.IDs)
+	}
+	result := query.UpdateColumn("seen_at", time.Now().UTC())
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update badge notifications"})
+		return
+	}
 
-		if earned {
-			userBadge := models.UserBadge{
-				ID:      uuid.New(),
-				UserID:  userID,
-				BadgeID: badge.ID,
-			}
-			h.db.Create(&userBadge)
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Badge notifications marked as seen",
+		"updated": result.RowsAffected,
+	})
+}
 
-			h.db.Model(&user).UpdateColumn("points", h.db.Raw("points + ?", badge.Points))
+// GetBadgeAwards returns the badge award audit trail, newest first
+// GET /api/admin/badges/awards?user_id=&badge_id=&limit=100
+func (h *BadgeHandler) GetBadgeAwards(c *gin.Context) {
+	query := h.db.Model(&models.BadgeAward{})
+	for _, param := range []string{"user_id", "badge_id"} {
+		raw := c.Query(param)
+		if raw == "" {
+			continue
 		}
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + param})
+			return
+		}
+		query = query.Where(param+" = ?", id)
+	}
+
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	if limit <= 0 || limit > 500 {
+		limit = 100
 	}
 
-	return nil
+	var awards []models.BadgeAward
+	if err := query.Order("created_at DESC").Limit(limit).Find(&awards).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch badge awards"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"awards": awards,
+		"count":  len(awards),
+	})
+}
+
+// EvaluateUserBadges evaluates every badge for a user right away
+// POST /api/admin/badges/evaluate/:userId
+func (h *BadgeHandler) EvaluateUserBadges(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	awarded, err := services.GetBadgeEngine(h.db).EvaluateUser(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to evaluate badges"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"awarded": awarded,
+		"count":   len(awarded),
+	})
 }
 
 func (h *BadgeHandler) CreateBadge(c *gin.Context) {
@@ -100,7 +175,7 @@ func (h *BadgeHandler) CreateBadge(c *gin.Context) {
 		Description   string `json:"description"`
 		IconURL       string `json:"icon_url"`
 		Criteria      string `json:"criteria" binding:"required"`
-		RequiredValue int    `json:"required_value" binding:"required"`
+		RequiredValue int    `json:"required_value"` // For plain criteria like "conversions"
 		Points        int    `json:"points"`
 	}
 
@@ -110,6 +185,11 @@ func (h *BadgeHandler) CreateBadge(c *gin.Context) {
 		return
 	}
 
+	if _, err := models.ParseBadgeCriteria(req.Criteria, req.RequiredValue); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	badge := models.Badge{
 		ID:            uuid.New(),
 		Name:          req.Name,
@@ -169,6 +249,25 @@ func (h *BadgeHandler) UpdateBadge(c *gin.Context) {
 		updates["points"] = req.Points
 	}
 
+	if req.Criteria != "" || req.RequiredValue > 0 {
+		var existing models.Badge
+		if err := h.db.First(&existing, "id = ?", badgeID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Badge not found"})
+			return
+		}
+		criteria, requiredValue := existing.Criteria, existing.RequiredValue
+		if req.Criteria != "" {
+			criteria = req.Criteria
+		}
+		if req.RequiredValue > 0 {
+			requiredValue = req.RequiredValue
+		}
+		if _, err := models.ParseBadgeCriteria(criteria, requiredValue); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
 	if err := h.db.Model(&models.Badge{}).Where("id = ?", badgeID).Updates(updates).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update badge"})
 		return
@@ -4,9 +4,11 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/aljapah/afftok-backend-prod/internal/middleware"
 	"github.com/aljapah/afftok-backend-prod/internal/models"
 	"github.com/aljapah/afftok-backend-prod/internal/services"
 	"github.com/gin-gonic/gin"
@@ -22,6 +24,12 @@ type ClickHandler struct {
 	observabilityService *services.ObservabilityService
 	geoRuleService       *services.GeoRuleService
 	linkSigningService   *services.LinkSigningService
+	contestService       *services.ContestService
+	geoIPService         *services.GeoIPService
+	webhookService       *services.WebhookService
+	routingService       *services.OfferRoutingService
+	accessService        *services.OfferAccessService
+	deepLinkService      *services.DeepLinkService
 }
 
 func NewClickHandler(db *gorm.DB) *ClickHandler {
@@ -32,7 +40,13 @@ func NewClickHandler(db *gorm.DB) *ClickHandler {
 		securityService:      services.NewSecurityService(),
 		observabilityService: services.NewObservabilityService(),
 		geoRuleService:       services.NewGeoRuleService(db),
+		accessService:        services.NewOfferAccessService(db),
+		deepLinkService:      services.NewDeepLinkService(db),
 		linkSigningService:   services.NewLinkSigningService(),
+		contestService:       services.GetContestService(db),
+		geoIPService:         services.GetGeoIPService(),
+		webhookService:       services.GetWebhookService(db),
+		routingService:       services.GetOfferRoutingService(db),
 	}
 }
 
@@ -132,6 +146,10 @@ func (h *ClickHandler) TrackClick(c *gin.Context) {
 
 	var userOffer models.UserOffer
 	var offer models.Offer
+	var trackedClick *models.Click
+	var routing *services.RoutingDecision
+	var trackingParams *services.TrackingParams
+	var deepLink *models.DeepLink
 
 	// Try to resolve as tracking code first
 	if strings.Contains(idOrCode, "-") {
@@ -165,6 +183,14 @@ func (h *ClickHandler) TrackClick(c *gin.Context) {
 			if err == nil {
 				// Try to find existing user offer
 				if err := h.db.Where("user_id = ? AND offer_id = ?", promoterUUID, offerID).First(&userOffer).Error; err != nil {
+					// Only public offers can be joined by clicking; the rest
+					// need an application or invite
+					if _, err := h.accessService.CheckJoinAccess(&offer, promoterUUID); err != nil ||
+						(offer.Visibility != "" && offer.Visibility != models.OfferVisibilityPublic) {
+						fmt.Printf("[Click] Promoter %s can't auto-join offer %s (%s)\n", promoterUUID, offerID, offer.Visibility)
+						goto trackAndRedirect
+					}
+
 					// Create new user offer with secure tracking code
 					affiliateLink, shortLink, err := h.linkService.GenerateAffiliateLink(
 						offer.DestinationURL, 
@@ -190,6 +216,7 @@ func (h *ClickHandler) TrackClick(c *gin.Context) {
 					} else {
 						// Update users_count on offer
 						h.db.Model(&offer).UpdateColumn("users_count", gorm.Expr("users_count + 1"))
+						h.webhookService.EmitJoinOffer(userOffer, offer)
 					}
 				}
 			}
@@ -197,10 +224,40 @@ func (h *ClickHandler) TrackClick(c *gin.Context) {
 	}
 
 trackAndRedirect:
+	// Revoked or paused promoters: send the visitor on without tracking or a
+	// click_id, so nothing is attributed to them
+	if userOffer.ID != uuid.Nil && !userOffer.IsActive() {
+		fmt.Printf("[Click] User offer %s is %s, redirecting without tracking\n", userOffer.ID.String(), userOffer.Status)
+		if offer.DestinationURL == "" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Offer not available"})
+			return
+		}
+		c.Redirect(http.StatusFound, offer.DestinationURL)
+		return
+	}
+
+	// Deep link: a promoter-chosen page on the offer's allowed domains
+	deepLink = h.resolveDeepLink(c, &offer, &userOffer, idOrCode)
+
+	// Sub-IDs and the offer's whitelisted passthrough parameters
+	trackingParams = services.ExtractTrackingParams(c.Request.URL.Query(), offer.PassthroughParamList())
+	if deepLink != nil && deepLink.ID != uuid.Nil {
+		trackingParams.DeepLinkID = &deepLink.ID
+	}
+
+	// Caps and routing rules, evaluated like the edge SmartRouter so origin
+	// and edge traffic land on the same destination
+	routing = h.routeClick(c, &offer, &userOffer)
+	if routing != nil && (routing.Capped || routing.Blocked) {
+		fmt.Printf("[Click] Not tracked (%s): offer=%s, redirecting to %s\n",
+			routing.RuleApplied, offer.ID.String(), routing.Destination)
+		goto redirectOnly
+	}
+
 	// Track the click if we have a valid user offer
 	if userOffer.ID != uuid.Nil {
 		// Security Check 4: Geo Rule Check
-		// Get country from IP (using existing click service or header)
+		// Get country from IP (GeoIP database, falling back to CDN headers)
 		countryCode := h.getCountryFromRequest(c)
 		
 		// Check geo rules
@@ -236,11 +293,14 @@ trackAndRedirect:
 		fingerprint := h.securityService.GenerateClickFingerprint(userOffer.ID, ip, c.Request.UserAgent())
 		if h.securityService.IsClickDuplicate(fingerprint, 5*time.Minute) {
 			fmt.Printf("[Click] Duplicate click detected for user offer %s\n", userOffer.ID.String())
-			// Still redirect, but don't count the click
+			// Still redirect, but don't count the click - reuse the original click_id
+			if recent, err := h.clickService.FindRecentClick(userOffer.ID, ip, 5*time.Minute); err == nil {
+				trackedClick = recent
+			}
 			goto redirectOnly
 		}
 
-		click, err := h.clickService.TrackClick(c, userOffer.ID)
+		click, err := h.clickService.TrackClickForTenant(c, userOffer.ID, middleware.GetTenantID(c).String(), trackingParams)
 		durationMs := time.Since(startTime).Milliseconds()
 		
 		if err != nil {
@@ -256,6 +316,8 @@ trackAndRedirect:
 			)
 			// Continue with redirect even if tracking fails
 		} else {
+			trackedClick = click
+			h.contestService.RecordActivity(userOffer.ID)
 			fmt.Printf("[Click] Click tracked: %s for user offer %s\n", click.ID.String(), userOffer.ID.String())
 			
 			// Log successful click with full observability
@@ -280,14 +342,24 @@ redirectOnly:
 
 	// Redirect to destination
 	destinationURL := offer.DestinationURL
+	if routing != nil {
+		destinationURL = routing.Destination
+	}
+	// A deep link replaces the destination unless caps or rules stopped tracking
+	if deepLink != nil && (routing == nil || !(routing.Capped || routing.Blocked)) {
+		destinationURL = deepLink.TargetURL
+	}
 	if destinationURL == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Offer has no destination URL"})
 		return
 	}
 
-	// Generate click_id for tracking conversions
+	// click_id is the recorded Click UUID so conversions can be attributed to it.
+	// Fall back to the user offer (legacy attribution) when no click was recorded.
 	clickID := idOrCode
-	if userOffer.ID != uuid.Nil {
+	if trackedClick != nil {
+		clickID = trackedClick.ID.String()
+	} else if userOffer.ID != uuid.Nil {
 		clickID = userOffer.ID.String()
 	}
 
@@ -313,13 +385,52 @@ redirectOnly:
 		false,
 	)
 
-	// Append click_id to destination URL for server-side tracking
-	finalURL := appendClickID(destinationURL, clickID)
+	// Render the offer's tracking template (click_id, sub-IDs, passthrough
+	// parameters) onto the destination URL for server-side tracking
+	macros := services.RedirectMacros(c, clickID, &offer, &userOffer, trackedClick, trackingParams)
+	finalURL := services.BuildDestinationURL(destinationURL, offer.TrackingTemplate, macros)
 
 	fmt.Printf("[Click] Redirecting to: %s (click_id: %s)\n", finalURL, clickID)
 	c.Redirect(http.StatusFound, finalURL)
 }
 
+// routeClick applies the offer's (or user offer's) routing config; nil when
+// there is none
+func (h *ClickHandler) routeClick(c *gin.Context, offer *models.Offer, userOffer *models.UserOffer) *services.RoutingDecision {
+	config := h.routingService.ResolveConfig(offer.ID, userOffer.ID)
+	if config == nil {
+		return nil
+	}
+	return h.routingService.Route(config, offer, userOffer, h.routingService.RequestContext(c))
+}
+
+// resolveDeepLink returns the deep link carried by the request's dl parameter,
+// or nil if there is none or its signature doesn't match the tracking code
+func (h *ClickHandler) resolveDeepLink(c *gin.Context, offer *models.Offer, userOffer *models.UserOffer, trackingCode string) *models.DeepLink {
+	if userOffer.ID == uuid.Nil {
+		return nil
+	}
+	target, ok := h.deepLinkTarget(c, trackingCode)
+	if !ok {
+		return nil
+	}
+	return h.deepLinkService.Resolve(offer, userOffer, target)
+}
+
+// deepLinkTarget returns the target of the request's signed dl parameter
+func (h *ClickHandler) deepLinkTarget(c *gin.Context, trackingCode string) (string, bool) {
+	token := c.Query(services.DeepLinkQueryParam)
+	if token == "" {
+		return "", false
+	}
+	target, ok := h.linkSigningService.ValidateDeepLinkTarget(trackingCode, token)
+	if !ok {
+		fmt.Printf("[Click] Invalid deep link signature for %s\n", trackingCode)
+		return "", false
+	}
+	return target, true
+}
+
 // handleInvalidLink handles invalid/tampered links
 // It tries to redirect to the destination anyway (but doesn't count the click)
 func (h *ClickHandler) handleInvalidLink(c *gin.Context, trackingCode string) {
@@ -330,6 +441,14 @@ func (h *ClickHandler) handleInvalidLink(c *gin.Context, trackingCode string) {
 		if err == nil {
 			var uo models.UserOffer
 			if h.db.Preload("Offer").First(&uo, "id = ?", userOfferID).Error == nil && uo.Offer != nil {
+				// Deep links still land on their page, untracked (and unrecorded)
+				if target, ok := h.deepLinkTarget(c, trackingCode); ok && uo.IsActive() {
+					if target, err := h.deepLinkService.ValidateTarget(uo.Offer, target); err == nil {
+						fmt.Printf("[Click] Invalid link, redirecting to deep link anyway: %s\n", target)
+						c.Redirect(http.StatusFound, target)
+						return
+					}
+				}
 				if uo.Offer.DestinationURL != "" {
 					fmt.Printf("[Click] Invalid link, redirecting anyway: %s\n", uo.Offer.DestinationURL)
 					c.Redirect(http.StatusFound, uo.Offer.DestinationURL)
@@ -471,32 +590,54 @@ func (h *ClickHandler) GetClicksByOffer(c *gin.Context) {
 	})
 }
 
+// GetSubIDReport breaks the current user's clicks and conversions down by a
+// sub-ID, across all their user offers or one (user_offer_id)
+// GET /api/clicks/sub-ids?group_by=sub1&days=30
+func (h *ClickHandler) GetSubIDReport(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var userOfferID *uuid.UUID
+	if raw := c.Query("user_offer_id"); raw != "" {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user_offer_id"})
+			return
+		}
+		userOfferID = &id
+	}
+
+	report, err := services.NewSubIDReportService(h.db).ForPromoter(userID, userOfferID, subIDReportQuery(c))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
 // ============================================
 // HELPER FUNCTIONS
 // ============================================
 
-// getCountryFromRequest extracts country code from request
-func (h *ClickHandler) getCountryFromRequest(c *gin.Context) string {
-	// 1. Try CF-IPCountry header (Cloudflare)
-	if country := c.GetHeader("CF-IPCountry"); country != "" {
-		return strings.ToUpper(country)
-	}
-	
-	// 2. Try X-Country header (custom)
-	if country := c.GetHeader("X-Country"); country != "" {
-		return strings.ToUpper(country)
+// subIDReportQuery reads group_by (default sub1), days and limit
+func subIDReportQuery(c *gin.Context) services.SubIDReportQuery {
+	days, _ := strconv.Atoi(c.Query("days"))
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	return services.SubIDReportQuery{
+		GroupBy: c.DefaultQuery("group_by", "sub1"),
+		Days:    days,
+		Limit:   limit,
 	}
-	
-	// 3. Try X-Geo-Country header
-	if country := c.GetHeader("X-Geo-Country"); country != "" {
-		return strings.ToUpper(country)
-	}
-	
-	// 4. Try to get from click data (if available)
-	// This would require a GeoIP service, for now return empty
-	// In production, integrate with MaxMind or similar
-	
-	return ""
+}
+
+// getCountryFromRequest resolves the client country: GeoIP database first,
+// then CF-IPCountry / X-Country / X-Geo-Country headers
+func (h *ClickHandler) getCountryFromRequest(c *gin.Context) string {
+	return h.geoIPService.ResolveRequest(c).CountryCode
 }
 
 // getRuleID safely gets rule ID
@@ -515,15 +656,3 @@ func getRuleMode(rule *models.GeoRule) string {
 	return string(rule.Mode)
 }
 
-// appendClickID appends click_id parameter to destination URL
-func appendClickID(destinationURL, clickID string) string {
-	if clickID == "" {
-		return destinationURL
-	}
-	
-	// Check if URL already has query parameters
-	if strings.Contains(destinationURL, "?") {
-		return destinationURL + "&click_id=" + clickID + "&aff_id=" + clickID
-	}
-	return destinationURL + "?click_id=" + clickID + "&aff_id=" + clickID
-}
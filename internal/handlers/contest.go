@@ -5,17 +5,22 @@ import (
 	"time"
 
 	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/aljapah/afftok-backend-prod/internal/services"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
 type ContestHandler struct {
-	db *gorm.DB
+	db             *gorm.DB
+	contestService *services.ContestService
 }
 
 func NewContestHandler(db *gorm.DB) *ContestHandler {
-	return &ContestHandler{db: db}
+	return &ContestHandler{
+		db:             db,
+		contestService: services.GetContestService(db),
+	}
 }
 
 // ========== Public Endpoints ==========
@@ -62,18 +67,13 @@ func (h *ContestHandler) GetContestLeaderboard(c *gin.Context) {
 	var participants []models.ContestParticipant
 	if err := h.db.Preload("Team").Preload("User").
 		Where("contest_id = ?", contestID).
-		Order("progress DESC, current_clicks DESC").
+		Order("CASE WHEN rank = 0 THEN 1 ELSE 0 END, rank ASC, joined_at ASC").
 		Limit(50).
 		Find(&participants).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch leaderboard"})
 		return
 	}
 
-	// Update ranks
-	for i := range participants {
-		participants[i].Rank = i + 1
-	}
-
 	c.JSON(http.StatusOK, gin.H{
 		"leaderboard": participants,
 	})
@@ -149,6 +149,9 @@ func (h *ContestHandler) JoinContest(c *gin.Context) {
 	// Increment participants count
 	h.db.Model(&contest).UpdateColumn("participants_count", gorm.Expr("participants_count + 1"))
 
+	// Credit activity already made during the contest and place the new participant
+	go h.contestService.RecomputeContest(contest.ID)
+
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "Successfully joined the contest",
@@ -399,8 +402,15 @@ func (h *ContestHandler) AdminUpdateContest(c *gin.Context) {
 		}
 		contest.EndDate = endDate
 	}
+	// Ending a running contest goes through settlement so winners are marked
+	settle := false
 	if req.Status != nil {
-		contest.Status = *req.Status
+		if *req.Status == models.ContestStatusEnded &&
+			(contest.Status == models.ContestStatusActive || contest.Status == models.ContestStatusDraft) {
+			settle = true
+		} else {
+			contest.Status = *req.Status
+		}
 	}
 
 	contest.UpdatedAt = time.Now()
@@ -410,6 +420,16 @@ func (h *ContestHandler) AdminUpdateContest(c *gin.Context) {
 		return
 	}
 
+	if settle {
+		if err := h.contestService.SettleContest(&contest); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to settle contest"})
+			return
+		}
+	} else if contest.Status == models.ContestStatusActive {
+		// Target or conditions may have changed
+		go h.contestService.RecomputeContest(contest.ID)
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "Contest updated successfully",
@@ -449,7 +469,7 @@ func (h *ContestHandler) AdminGetContestParticipants(c *gin.Context) {
 	var participants []models.ContestParticipant
 	if err := h.db.Preload("Team").Preload("User").
 		Where("contest_id = ?", contestID).
-		Order("progress DESC").
+		Order("CASE WHEN rank = 0 THEN 1 ELSE 0 END, rank ASC, joined_at ASC").
 		Find(&participants).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch participants"})
 		return
@@ -461,3 +481,21 @@ func (h *ContestHandler) AdminGetContestParticipants(c *gin.Context) {
 	})
 }
 
+// AdminRecomputeContest rebuilds participant progress and ranks on demand
+func (h *ContestHandler) AdminRecomputeContest(c *gin.Context) {
+	contestID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid contest ID"})
+		return
+	}
+
+	if err := h.contestService.RecomputeContest(contestID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to recompute contest"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Contest progress recomputed",
+	})
+}
@@ -1,9 +1,6 @@
 package handlers
 
 import (
-	"crypto/hmac"
-	"crypto/sha256"
-	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -13,6 +10,7 @@ import (
 	"time"
 
 	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/aljapah/afftok-backend-prod/internal/services"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
@@ -20,12 +18,22 @@ import (
 
 // ConversionWebhookHandler handles incoming conversion webhooks from various platforms
 type ConversionWebhookHandler struct {
-	db *gorm.DB
+	db                 *gorm.DB
+	attributionService *services.AttributionService
+	platformService    *services.PlatformWebhookService
+	lifecycleService   *services.ConversionLifecycleService
+	webhookService     *services.WebhookService
 }
 
 // NewConversionWebhookHandler creates a new conversion webhook handler
 func NewConversionWebhookHandler(db *gorm.DB) *ConversionWebhookHandler {
-	return &ConversionWebhookHandler{db: db}
+	return &ConversionWebhookHandler{
+		db:                 db,
+		attributionService: services.NewAttributionService(db),
+		platformService:    services.NewPlatformWebhookService(db),
+		lifecycleService:   services.NewConversionLifecycleService(db),
+		webhookService:     services.GetWebhookService(db),
+	}
 }
 
 // ============================================
@@ -52,13 +60,25 @@ func (h *ConversionWebhookHandler) HandlePostback(c *gin.Context) {
 
 	amountFloat, _ := strconv.ParseFloat(amount, 64)
 
-	// Find user offer by click_id
-	userOffer, err := h.findUserOfferByClickID(clickID)
+	if status != models.ConversionStatusPending && status != models.ConversionStatusApproved && status != models.ConversionStatusRejected {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status for a new conversion: " + status})
+		return
+	}
+
+	// Find the click (and its user offer) by click_id
+	attribution, err := h.resolveAttribution(clickID)
 	if err != nil {
 		fmt.Printf("[Postback] UserOffer not found for click_id: %s\n", clickID)
 		c.JSON(http.StatusNotFound, gin.H{"error": "Invalid click_id"})
 		return
 	}
+	userOffer := attribution.UserOffer
+
+	if attribution.IsRejected() {
+		fmt.Printf("[Postback] Outside attribution window: click_id=%s, %s\n", clickID, attribution.Reason)
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Conversion is outside the offer's attribution window"})
+		return
+	}
 
 	// Create conversion record
 	conversion := models.Conversion{
@@ -71,17 +91,22 @@ func (h *ConversionWebhookHandler) HandlePostback(c *gin.Context) {
 		PostbackData:         fmt.Sprintf(`{"order_id":"%s","raw_amount":"%s","source":"postback"}`, orderID, amount),
 		ConvertedAt:          time.Now(),
 	}
+	applyAttribution(&conversion, attribution)
 
-	if err := h.db.Create(&conversion).Error; err != nil {
+	if err := h.lifecycleService.CreateConversion(&conversion, models.ConversionSourcePostback); err != nil {
 		fmt.Printf("[Postback] Failed to create conversion: %v\n", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record conversion"})
 		return
 	}
 
-	// Update stats
-	h.updateConversionStats(userOffer.ID, userOffer.OfferID)
-
 	fmt.Printf("[Postback] Conversion recorded: click_id=%s, amount=%s, order_id=%s\n", clickID, amount, orderID)
+	h.webhookService.EmitPostback(map[string]interface{}{
+		"click_id": clickID,
+		"order_id": orderID,
+		"amount":   amount,
+		"status":   status,
+		"currency": currency,
+	}, conversion.ID, userOffer.ID)
 	c.JSON(http.StatusOK, gin.H{
 		"success":       true,
 		"conversion_id": conversion.ID.String(),
@@ -110,15 +135,8 @@ type ShopifyOrder struct {
 // HandleShopifyWebhook handles Shopify order webhooks
 // POST /api/webhook/shopify/:advertiser_id
 func (h *ConversionWebhookHandler) HandleShopifyWebhook(c *gin.Context) {
-	advertiserID := c.Param("advertiser_id")
-	
-	// Verify webhook signature (if HMAC secret is set)
-	// shopifyHMAC := c.GetHeader("X-Shopify-Hmac-SHA256")
-	// TODO: Verify signature with advertiser's webhook secret
-
-	body, err := io.ReadAll(c.Request.Body)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read body"})
+	advertiserID, body, ok := h.verifyPlatformWebhook(c, models.WebhookPlatformShopify)
+	if !ok {
 		return
 	}
 
@@ -148,23 +166,38 @@ func (h *ConversionWebhookHandler) HandleShopifyWebhook(c *gin.Context) {
 		return
 	}
 
-	// Find user offer
-	userOffer, err := h.findUserOfferByClickID(clickID)
+	// Find the click (and its user offer)
+	attribution, err := h.resolveAttribution(clickID)
 	if err != nil {
 		fmt.Printf("[Shopify] UserOffer not found for click_id: %s\n", clickID)
 		c.JSON(http.StatusOK, gin.H{"message": "Affiliate not found"})
 		return
 	}
+	userOffer := attribution.UserOffer
 
 	// Verify advertiser owns this offer
-	var offer models.Offer
-	if err := h.db.First(&offer, "id = ?", userOffer.OfferID).Error; err != nil {
-		c.JSON(http.StatusOK, gin.H{"message": "Offer not found"})
+	if !h.offerBelongsTo(userOffer.OfferID, advertiserID) {
+		fmt.Printf("[Shopify] Advertiser mismatch: offer %s does not belong to %s\n", userOffer.OfferID, advertiserID)
+		c.JSON(http.StatusOK, gin.H{"message": "Offer does not belong to this advertiser"})
+		return
+	}
+
+	// Drop replays: Shopify's webhook ID identifies a delivery; fall back to order + status
+	externalID := fmt.Sprintf("shopify_%d", order.ID)
+	deliveryID := c.GetHeader(services.ShopifyWebhookIDHeader)
+	if deliveryID == "" {
+		deliveryID = fmt.Sprintf("order:%d:%s", order.ID, order.FinancialStatus)
+	}
+	if !h.claimDelivery(c, models.WebhookPlatformShopify, advertiserID, deliveryID, externalID, userOffer.ID, mapShopifyStatus(order.FinancialStatus)) {
 		return
 	}
 
-	if offer.AdvertiserID != nil && offer.AdvertiserID.String() != advertiserID {
-		fmt.Printf("[Shopify] Advertiser mismatch: expected %s, got %s\n", advertiserID, offer.AdvertiserID.String())
+	// Only new conversions are held to the attribution window; claimDelivery
+	// has applied refunds and cancellations to stored ones, however late
+	if attribution.IsRejected() {
+		fmt.Printf("[Shopify] Outside attribution window: order=%d, %s\n", order.OrderNumber, attribution.Reason)
+		c.JSON(http.StatusOK, gin.H{"message": "Conversion outside attribution window"})
+		return
 	}
 
 	// Parse amount
@@ -174,22 +207,22 @@ func (h *ConversionWebhookHandler) HandleShopifyWebhook(c *gin.Context) {
 	conversion := models.Conversion{
 		ID:                   uuid.New(),
 		UserOfferID:          userOffer.ID,
-		ExternalConversionID: fmt.Sprintf("shopify_%d", order.ID),
+		ExternalConversionID: externalID,
 		Amount:               int(amountFloat * 100),
 		Currency:             order.Currency,
 		Status:               mapShopifyStatus(order.FinancialStatus),
 		PostbackData:         string(body),
 		ConvertedAt:          time.Now(),
 	}
+	applyAttribution(&conversion, attribution)
 
-	if err := h.db.Create(&conversion).Error; err != nil {
+	if err := h.lifecycleService.CreateConversion(&conversion, models.WebhookPlatformShopify); err != nil {
 		fmt.Printf("[Shopify] Failed to create conversion: %v\n", err)
+		h.platformService.ReleaseDelivery(models.WebhookPlatformShopify, advertiserID, deliveryID)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record conversion"})
 		return
 	}
 
-	h.updateConversionStats(userOffer.ID, userOffer.OfferID)
-
 	fmt.Printf("[Shopify] Conversion recorded: order=%d, click_id=%s, amount=%s\n", order.OrderNumber, clickID, order.TotalPrice)
 	c.JSON(http.StatusOK, gin.H{"success": true})
 }
@@ -200,8 +233,9 @@ func (h *ConversionWebhookHandler) HandleShopifyWebhook(c *gin.Context) {
 
 // SallaOrder represents a Salla order webhook payload
 type SallaOrder struct {
-	Event string `json:"event"`
-	Data  struct {
+	Event     string `json:"event"`
+	CreatedAt string `json:"created_at"`
+	Data      struct {
 		ID          int    `json:"id"`
 		ReferenceID string `json:"reference_id"`
 		Total       struct {
@@ -222,12 +256,8 @@ type SallaOrder struct {
 // HandleSallaWebhook handles Salla order webhooks
 // POST /api/webhook/salla/:advertiser_id
 func (h *ConversionWebhookHandler) HandleSallaWebhook(c *gin.Context) {
-	advertiserID := c.Param("advertiser_id")
-	_ = advertiserID // Used for verification
-
-	body, err := io.ReadAll(c.Request.Body)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read body"})
+	advertiserID, body, ok := h.verifyPlatformWebhook(c, models.WebhookPlatformSalla)
+	if !ok {
 		return
 	}
 
@@ -263,34 +293,56 @@ func (h *ConversionWebhookHandler) HandleSallaWebhook(c *gin.Context) {
 		return
 	}
 
-	// Find user offer
-	userOffer, err := h.findUserOfferByClickID(clickID)
+	// Find the click (and its user offer)
+	attribution, err := h.resolveAttribution(clickID)
 	if err != nil {
 		fmt.Printf("[Salla] UserOffer not found for click_id: %s\n", clickID)
 		c.JSON(http.StatusOK, gin.H{"message": "Affiliate not found"})
 		return
 	}
+	userOffer := attribution.UserOffer
+
+	if !h.offerBelongsTo(userOffer.OfferID, advertiserID) {
+		fmt.Printf("[Salla] Advertiser mismatch: offer %s does not belong to %s\n", userOffer.OfferID, advertiserID)
+		c.JSON(http.StatusOK, gin.H{"message": "Offer does not belong to this advertiser"})
+		return
+	}
+
+	// Drop replays: Salla has no delivery ID, so use event + order + status + event time
+	externalID := fmt.Sprintf("salla_%d", order.Data.ID)
+	deliveryID := fmt.Sprintf("%s:%d:%s:%s", order.Event, order.Data.ID, order.Data.Status, order.CreatedAt)
+	if !h.claimDelivery(c, models.WebhookPlatformSalla, advertiserID, deliveryID, externalID, userOffer.ID, mapSallaStatus(order.Data.Status)) {
+		return
+	}
+
+	// Only new conversions are held to the attribution window; claimDelivery
+	// has applied refunds and cancellations to stored ones, however late
+	if attribution.IsRejected() {
+		fmt.Printf("[Salla] Outside attribution window: order=%s, %s\n", order.Data.ReferenceID, attribution.Reason)
+		c.JSON(http.StatusOK, gin.H{"message": "Conversion outside attribution window"})
+		return
+	}
 
 	// Create conversion
 	conversion := models.Conversion{
 		ID:                   uuid.New(),
 		UserOfferID:          userOffer.ID,
-		ExternalConversionID: fmt.Sprintf("salla_%d", order.Data.ID),
+		ExternalConversionID: externalID,
 		Amount:               int(order.Data.Total.Amount * 100),
 		Currency:             order.Data.Total.Currency,
 		Status:               mapSallaStatus(order.Data.Status),
 		PostbackData:         string(body),
 		ConvertedAt:          time.Now(),
 	}
+	applyAttribution(&conversion, attribution)
 
-	if err := h.db.Create(&conversion).Error; err != nil {
+	if err := h.lifecycleService.CreateConversion(&conversion, models.WebhookPlatformSalla); err != nil {
 		fmt.Printf("[Salla] Failed to create conversion: %v\n", err)
+		h.platformService.ReleaseDelivery(models.WebhookPlatformSalla, advertiserID, deliveryID)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record conversion"})
 		return
 	}
 
-	h.updateConversionStats(userOffer.ID, userOffer.OfferID)
-
 	fmt.Printf("[Salla] Conversion recorded: order=%s, click_id=%s, amount=%.2f\n", order.Data.ReferenceID, clickID, order.Data.Total.Amount)
 	c.JSON(http.StatusOK, gin.H{"success": true})
 }
@@ -313,12 +365,8 @@ type ZidOrder struct {
 // HandleZidWebhook handles Zid order webhooks
 // POST /api/webhook/zid/:advertiser_id
 func (h *ConversionWebhookHandler) HandleZidWebhook(c *gin.Context) {
-	advertiserID := c.Param("advertiser_id")
-	_ = advertiserID
-
-	body, err := io.ReadAll(c.Request.Body)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read body"})
+	advertiserID, body, ok := h.verifyPlatformWebhook(c, models.WebhookPlatformZid)
+	if !ok {
 		return
 	}
 
@@ -348,32 +396,54 @@ func (h *ConversionWebhookHandler) HandleZidWebhook(c *gin.Context) {
 		return
 	}
 
-	userOffer, err := h.findUserOfferByClickID(clickID)
+	attribution, err := h.resolveAttribution(clickID)
 	if err != nil {
 		fmt.Printf("[Zid] UserOffer not found for click_id: %s\n", clickID)
 		c.JSON(http.StatusOK, gin.H{"message": "Affiliate not found"})
 		return
 	}
+	userOffer := attribution.UserOffer
+
+	if !h.offerBelongsTo(userOffer.OfferID, advertiserID) {
+		fmt.Printf("[Zid] Advertiser mismatch: offer %s does not belong to %s\n", userOffer.OfferID, advertiserID)
+		c.JSON(http.StatusOK, gin.H{"message": "Offer does not belong to this advertiser"})
+		return
+	}
+
+	// Drop replays by order + status
+	externalID := fmt.Sprintf("zid_%s", order.OrderID)
+	deliveryID := fmt.Sprintf("order:%s:%s", order.OrderID, order.Status)
+	if !h.claimDelivery(c, models.WebhookPlatformZid, advertiserID, deliveryID, externalID, userOffer.ID, mapZidStatus(order.Status)) {
+		return
+	}
+
+	// Only new conversions are held to the attribution window; claimDelivery
+	// has applied refunds and cancellations to stored ones, however late
+	if attribution.IsRejected() {
+		fmt.Printf("[Zid] Outside attribution window: order=%s, %s\n", order.OrderID, attribution.Reason)
+		c.JSON(http.StatusOK, gin.H{"message": "Conversion outside attribution window"})
+		return
+	}
 
 	conversion := models.Conversion{
 		ID:                   uuid.New(),
 		UserOfferID:          userOffer.ID,
-		ExternalConversionID: fmt.Sprintf("zid_%s", order.OrderID),
+		ExternalConversionID: externalID,
 		Amount:               int(order.TotalPrice * 100),
 		Currency:             order.Currency,
 		Status:               mapZidStatus(order.Status),
 		PostbackData:         string(body),
 		ConvertedAt:          time.Now(),
 	}
+	applyAttribution(&conversion, attribution)
 
-	if err := h.db.Create(&conversion).Error; err != nil {
+	if err := h.lifecycleService.CreateConversion(&conversion, models.WebhookPlatformZid); err != nil {
 		fmt.Printf("[Zid] Failed to create conversion: %v\n", err)
+		h.platformService.ReleaseDelivery(models.WebhookPlatformZid, advertiserID, deliveryID)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record conversion"})
 		return
 	}
 
-	h.updateConversionStats(userOffer.ID, userOffer.OfferID)
-
 	fmt.Printf("[Zid] Conversion recorded: order=%s, click_id=%s, amount=%.2f\n", order.OrderID, clickID, order.TotalPrice)
 	c.JSON(http.StatusOK, gin.H{"success": true})
 }
@@ -411,12 +481,19 @@ func (h *ConversionWebhookHandler) HandlePixelConversion(c *gin.Context) {
 		return
 	}
 
-	userOffer, err := h.findUserOfferByClickID(req.ClickID)
+	attribution, err := h.resolveAttribution(req.ClickID)
 	if err != nil {
 		fmt.Printf("[Pixel] UserOffer not found for click_id: %s\n", req.ClickID)
 		c.JSON(http.StatusNotFound, gin.H{"error": "Invalid click_id"})
 		return
 	}
+	userOffer := attribution.UserOffer
+
+	if attribution.IsRejected() {
+		fmt.Printf("[Pixel] Outside attribution window: click_id=%s, %s\n", req.ClickID, attribution.Reason)
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Conversion is outside the offer's attribution window"})
+		return
+	}
 
 	conversion := models.Conversion{
 		ID:                   uuid.New(),
@@ -428,15 +505,14 @@ func (h *ConversionWebhookHandler) HandlePixelConversion(c *gin.Context) {
 		PostbackData:         fmt.Sprintf(`{"order_id":"%s","ip":"%s","source":"pixel"}`, req.OrderID, c.ClientIP()),
 		ConvertedAt:          time.Now(),
 	}
+	applyAttribution(&conversion, attribution)
 
-	if err := h.db.Create(&conversion).Error; err != nil {
+	if err := h.lifecycleService.CreateConversion(&conversion, models.ConversionSourcePixel); err != nil {
 		fmt.Printf("[Pixel] Failed to create conversion: %v\n", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record conversion"})
 		return
 	}
 
-	h.updateConversionStats(userOffer.ID, userOffer.OfferID)
-
 	fmt.Printf("[Pixel] Conversion recorded: click_id=%s, amount=%.2f\n", req.ClickID, req.Amount)
 	
 	// Return 1x1 transparent GIF for img pixel
@@ -459,35 +535,92 @@ func (h *ConversionWebhookHandler) HandlePixelConversion(c *gin.Context) {
 // HELPER FUNCTIONS
 // ============================================
 
-// findUserOfferByClickID finds user offer by click_id (which is the UserOffer ID)
-func (h *ConversionWebhookHandler) findUserOfferByClickID(clickID string) (*models.UserOffer, error) {
-	var userOffer models.UserOffer
+// verifyPlatformWebhook reads the raw body and verifies the platform signature
+// against the advertiser's secret. On failure it writes the response and
+// returns ok=false.
+func (h *ConversionWebhookHandler) verifyPlatformWebhook(c *gin.Context, platform string) (uuid.UUID, []byte, bool) {
+	advertiserID, err := uuid.Parse(c.Param("advertiser_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid advertiser ID"})
+		return uuid.Nil, nil, false
+	}
 
-	// Try as UUID first
-	userOfferID, err := uuid.Parse(clickID)
-	if err == nil {
-		if err := h.db.First(&userOffer, "id = ?", userOfferID).Error; err == nil {
-			return &userOffer, nil
-		}
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read body"})
+		return uuid.Nil, nil, false
 	}
 
-	// Try as tracking code
-	if err := h.db.Where("tracking_code = ? OR short_link = ?", clickID, clickID).First(&userOffer).Error; err == nil {
-		return &userOffer, nil
+	if err := h.platformService.VerifySignature(advertiserID, platform, c.Request.Header, body); err != nil {
+		fmt.Printf("[%s] Webhook rejected for advertiser %s from %s: %v\n", platform, advertiserID, c.ClientIP(), err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Webhook signature verification failed"})
+		return uuid.Nil, nil, false
 	}
 
-	return nil, fmt.Errorf("user offer not found for click_id: %s", clickID)
+	return advertiserID, body, true
 }
 
-// updateConversionStats updates conversion counts on user_offer and offer
-func (h *ConversionWebhookHandler) updateConversionStats(userOfferID, offerID uuid.UUID) {
-	// Update user_offer stats
-	h.db.Model(&models.UserOffer{}).Where("id = ?", userOfferID).
-		UpdateColumn("total_conversions", gorm.Expr("total_conversions + 1"))
+// claimDelivery drops replayed deliveries, and applies status changes (e.g. a
+// refund reversing an approved order) to orders that already have a
+// conversion, writing the response. Returns true if a new conversion should be
+// created.
+func (h *ConversionWebhookHandler) claimDelivery(c *gin.Context, platform string, advertiserID uuid.UUID, deliveryID, externalID string, userOfferID uuid.UUID, status string) bool {
+	if !h.platformService.ClaimDelivery(platform, advertiserID, deliveryID) {
+		c.JSON(http.StatusOK, gin.H{"message": "Duplicate delivery ignored", "duplicate": true})
+		return false
+	}
 
-	// Update offer stats
-	h.db.Model(&models.Offer{}).Where("id = ?", offerID).
-		UpdateColumn("total_conversions", gorm.Expr("total_conversions + 1"))
+	var existing models.Conversion
+	if err := h.db.Where("external_conversion_id = ?", externalID).First(&existing).Error; err != nil {
+		return true
+	}
+
+	target := existing.ResolveStatusUpdate(status)
+	if existing.UserOfferID != userOfferID || target == existing.Status || !existing.CanTransitionTo(target) {
+		c.JSON(http.StatusOK, gin.H{"message": "Conversion already recorded", "duplicate": true})
+		return false
+	}
+
+	conversion, previous, err := h.lifecycleService.Transition(existing.ID, services.ConversionTransition{
+		ToStatus: target,
+		Source:   platform,
+		Reason:   platform + " order status: " + status,
+	})
+	if err != nil {
+		fmt.Printf("[%s] Failed to update conversion %s: %v\n", platform, existing.ID, err)
+		h.platformService.ReleaseDelivery(platform, advertiserID, deliveryID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update conversion"})
+		return false
+	}
+
+	fmt.Printf("[%s] Conversion %s: %s -> %s\n", platform, conversion.ID, previous, conversion.Status)
+	c.JSON(http.StatusOK, gin.H{"success": true, "status": conversion.Status})
+	return false
+}
+
+// offerBelongsTo checks the offer is owned by the advertiser whose secret signed the webhook
+func (h *ConversionWebhookHandler) offerBelongsTo(offerID, advertiserID uuid.UUID) bool {
+	var offer models.Offer
+	if err := h.db.Select("id", "advertiser_id").First(&offer, "id = ?", offerID).Error; err != nil {
+		return false
+	}
+	return offer.AdvertiserID != nil && *offer.AdvertiserID == advertiserID
+}
+
+// resolveAttribution resolves click_id (a Click ID, or a UserOffer ID/tracking
+// code for links issued before per-click IDs) and checks the attribution window
+func (h *ConversionWebhookHandler) resolveAttribution(clickID string) (*services.AttributionResult, error) {
+	return h.attributionService.Attribute(clickID, time.Now().UTC())
+}
+
+// applyAttribution links the conversion to its click and holds late
+// conversions for review instead of letting them auto-approve
+func applyAttribution(conversion *models.Conversion, attribution *services.AttributionResult) {
+	conversion.ClickID = attribution.ClickID()
+	if attribution.IsFlagged() {
+		conversion.OutsideAttributionWindow = true
+		conversion.Status = models.ConversionStatusPending
+	}
 }
 
 // extractClickIDFromURL extracts click_id from URL query parameters
@@ -552,11 +685,3 @@ func mapZidStatus(status string) string {
 	}
 }
 
-// verifyHMAC verifies HMAC signature
-func verifyHMAC(message, signature, secret string) bool {
-	mac := hmac.New(sha256.New, []byte(secret))
-	mac.Write([]byte(message))
-	expectedMAC := hex.EncodeToString(mac.Sum(nil))
-	return hmac.Equal([]byte(expectedMAC), []byte(signature))
-}
-
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/aljapah/afftok-backend-prod/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ============================================
+// DEEP LINK HANDLER
+// ============================================
+
+// DeepLinkHandler lets promoters link to a specific page within an offer's
+// allowed domains (e.g. a product page) instead of the offer's destination
+type DeepLinkHandler struct {
+	db                 *gorm.DB
+	deepLinkService    *services.DeepLinkService
+	linkSigningService *services.LinkSigningService
+}
+
+// NewDeepLinkHandler creates a new deep link handler
+func NewDeepLinkHandler(db *gorm.DB) *DeepLinkHandler {
+	return &DeepLinkHandler{
+		db:                 db,
+		deepLinkService:    services.NewDeepLinkService(db),
+		linkSigningService: services.NewLinkSigningService(),
+	}
+}
+
+// SetLinkSigningService sets the link signing service (for dependency injection)
+func (h *DeepLinkHandler) SetLinkSigningService(service *services.LinkSigningService) {
+	h.linkSigningService = service
+}
+
+// CreateDeepLink generates a signed tracking link that redirects to
+// target_url. Pass the offer_id or the user_offer_id of a joined offer.
+// POST /api/deep-links
+func (h *DeepLinkHandler) CreateDeepLink(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req struct {
+		OfferID     string `json:"offer_id"`
+		UserOfferID string `json:"user_offer_id"`
+		TargetURL   string `json:"target_url" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	query := h.db.Preload("Offer").Where("user_id = ?", userID)
+	switch {
+	case req.UserOfferID != "":
+		query = query.Where("id = ?", req.UserOfferID)
+	case req.OfferID != "":
+		query = query.Where("offer_id = ?", req.OfferID)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "offer_id or user_offer_id is required"})
+		return
+	}
+
+	var userOffer models.UserOffer
+	if err := query.First(&userOffer).Error; err != nil || userOffer.Offer == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Join the offer before creating deep links"})
+		return
+	}
+	if !userOffer.IsActive() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "This offer is no longer active for you"})
+		return
+	}
+	if userOffer.TrackingCode == "" {
+		c.JSON(http.StatusConflict, gin.H{"error": "This offer has no tracking code; join it again to get one"})
+		return
+	}
+
+	deepLink, err := h.deepLinkService.Create(&userOffer, req.TargetURL)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrDeepLinksDisabled), errors.Is(err, services.ErrDeepLinkDomainNotAllowed):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":           err.Error(),
+				"allowed_domains": userOffer.Offer.DeepLinkDomainList(),
+			})
+		case errors.Is(err, services.ErrInvalidDeepLinkTarget):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create deep link"})
+		}
+		return
+	}
+
+	signedLink := h.linkSigningService.GenerateSignedLink(userOffer.TrackingCode)
+	token := h.linkSigningService.SignDeepLinkTarget(userOffer.TrackingCode, deepLink.TargetURL)
+	trackingURL := fmt.Sprintf("%s/api/c/%s?%s=%s", trackingBaseURL(), signedLink,
+		services.DeepLinkQueryParam, url.QueryEscape(token))
+
+	c.JSON(http.StatusCreated, gin.H{
+		"deep_link":    deepLink,
+		"tracking_url": trackingURL,
+		"signed_link":  signedLink,
+	})
+}
+
+// GetDeepLinkReport breaks the promoter's deep link clicks and conversions
+// down by target URL, for all offers or one user offer
+// GET /api/deep-links?user_offer_id=&days=30
+func (h *DeepLinkHandler) GetDeepLinkReport(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var userOfferID *uuid.UUID
+	if raw := c.Query("user_offer_id"); raw != "" {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user_offer_id"})
+			return
+		}
+		userOfferID = &id
+	}
+
+	days, _ := strconv.Atoi(c.Query("days"))
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	report, err := h.deepLinkService.ReportForPromoter(userID, userOfferID, days, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build deep link report"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
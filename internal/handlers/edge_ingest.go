@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/aljapah/afftok-backend-prod/internal/middleware"
 	"github.com/aljapah/afftok-backend-prod/internal/services"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -20,6 +21,7 @@ import (
 type EdgeIngestHandler struct {
 	db            *gorm.DB
 	ingestService *services.EdgeIngestService
+	authService   *services.EdgeAuthService
 }
 
 // NewEdgeIngestHandler creates a new edge ingest handler
@@ -27,6 +29,7 @@ func NewEdgeIngestHandler(db *gorm.DB) *EdgeIngestHandler {
 	return &EdgeIngestHandler{
 		db:            db,
 		ingestService: services.GetEdgeIngestService(db),
+		authService:   services.GetEdgeAuthService(db),
 	}
 }
 
@@ -95,6 +98,11 @@ func (h *EdgeIngestHandler) IngestEdgeClick(c *gin.Context) {
 		}
 	}
 
+	// Count the batch against the edge location's event quota
+	if credential, ok := middleware.GetEdgeCredential(c); ok {
+		h.authService.RecordEvents(credential, processed+failed)
+	}
+
 	if err != nil && processed == 0 {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success":        false,
@@ -172,6 +180,7 @@ func (h *EdgeIngestHandler) GetEdgeStats(c *gin.Context) {
 type AdminEdgeHandler struct {
 	db            *gorm.DB
 	ingestService *services.EdgeIngestService
+	authService   *services.EdgeAuthService
 }
 
 // NewAdminEdgeHandler creates a new admin edge handler
@@ -179,6 +188,7 @@ func NewAdminEdgeHandler(db *gorm.DB) *AdminEdgeHandler {
 	return &AdminEdgeHandler{
 		db:            db,
 		ingestService: services.GetEdgeIngestService(db),
+		authService:   services.GetEdgeAuthService(db),
 	}
 }
 
@@ -197,6 +207,10 @@ func (h *AdminEdgeHandler) GetEdgeStatus(c *gin.Context) {
 			"ingest_stats":    stats,
 			"workers_active":  true,
 			"queue_healthy":   stats["queue_size"].(int) < stats["queue_capacity"].(int)/2,
+			"auth": gin.H{
+				"mode":    middleware.EdgeAuthMode(),
+				"metrics": services.GetEdgeAuthMetrics(),
+			},
 		},
 		"timestamp": time.Now().UTC(),
 	})
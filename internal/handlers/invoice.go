@@ -6,17 +6,22 @@ import (
 	"time"
 
 	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/aljapah/afftok-backend-prod/internal/services"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
 type InvoiceHandler struct {
-	db *gorm.DB
+	db             *gorm.DB
+	invoiceService *services.InvoiceService
 }
 
 func NewInvoiceHandler(db *gorm.DB) *InvoiceHandler {
-	return &InvoiceHandler{db: db}
+	return &InvoiceHandler{
+		db:             db,
+		invoiceService: services.GetInvoiceService(db),
+	}
 }
 
 // GetMyInvoices returns invoices for the authenticated advertiser
@@ -252,7 +257,8 @@ func (h *InvoiceHandler) AdminRejectPayment(c *gin.Context) {
 	})
 }
 
-// AdminGenerateMonthlyInvoices generates invoices for all advertisers
+// AdminGenerateMonthlyInvoices generates invoices for all advertisers.
+// Safe to re-run: pending invoices for the period are refreshed, not duplicated.
 func (h *InvoiceHandler) AdminGenerateMonthlyInvoices(c *gin.Context) {
 	var req struct {
 		Month int `json:"month" binding:"required,min=1,max=12"`
@@ -263,76 +269,53 @@ func (h *InvoiceHandler) AdminGenerateMonthlyInvoices(c *gin.Context) {
 		return
 	}
 
-	// Get all advertisers
-	var advertisers []models.AfftokUser
-	if err := h.db.Where("role = ? AND status = ?", "advertiser", "active").
-		Find(&advertisers).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch advertisers"})
+	result, err := h.invoiceService.GenerateMonthlyInvoices(req.Year, req.Month)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate invoices"})
 		return
 	}
 
-	platformRate := 0.10 // 10%
-	createdCount := 0
-	skippedCount := 0
-
-	for _, advertiser := range advertisers {
-		// Check if invoice already exists
-		var existing models.Invoice
-		if err := h.db.Where("advertiser_id = ? AND month = ? AND year = ?",
-			advertiser.ID, req.Month, req.Year).First(&existing).Error; err == nil {
-			skippedCount++
-			continue
-		}
+	c.JSON(http.StatusOK, gin.H{
+		"message":       "Invoice generation completed",
+		"created_count": result.Created,
+		"updated_count": result.Updated,
+		"skipped_count": result.Skipped,
+		"errors":        result.Errors,
+	})
+}
 
-		// Calculate total conversions and payouts for this advertiser's offers
-		var result struct {
-			TotalConversions int
-			TotalPayout      float64
-		}
-		
-		// Get conversions for advertiser's offers in this period
-		periodStart := time.Date(req.Year, time.Month(req.Month), 1, 0, 0, 0, 0, time.UTC)
-		periodEnd := periodStart.AddDate(0, 1, 0).Add(-time.Second)
-		
-		h.db.Table("conversions").
-			Select("COUNT(*) as total_conversions, COALESCE(SUM(payout), 0) as total_payout").
-			Joins("JOIN offers ON conversions.offer_id = offers.id").
-			Where("offers.advertiser_id = ? AND conversions.created_at BETWEEN ? AND ?",
-				advertiser.ID, periodStart, periodEnd).
-			Scan(&result)
-
-		// Skip if no conversions
-		if result.TotalConversions == 0 {
-			skippedCount++
-			continue
-		}
+// AdminSetPlatformRate sets the platform fee rate for an advertiser.
+// Send null to fall back to the platform default.
+func (h *InvoiceHandler) AdminSetPlatformRate(c *gin.Context) {
+	advertiserID := c.Param("id")
 
-		// Create invoice
-		invoice := models.Invoice{
-			AdvertiserID:        advertiser.ID,
-			Month:               req.Month,
-			Year:                req.Year,
-			PeriodStart:         periodStart,
-			PeriodEnd:           periodEnd,
-			TotalConversions:    result.TotalConversions,
-			TotalPromoterPayout: result.TotalPayout,
-			PlatformRate:        platformRate,
-			PlatformAmount:      result.TotalPayout * platformRate,
-			Currency:            "KWD",
-			Status:              "pending",
-			DueDate:             periodEnd.AddDate(0, 0, 7), // Due 7 days after period end
-		}
+	var req struct {
+		PlatformRate *float64 `json:"platform_rate"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	if req.PlatformRate != nil && !models.IsValidPlatformRate(*req.PlatformRate) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "platform_rate must be between 0 and 1"})
+		return
+	}
 
-		if err := h.db.Create(&invoice).Error; err != nil {
-			continue
-		}
-		createdCount++
+	var advertiser models.AfftokUser
+	if err := h.db.Where("id = ? AND role = ?", advertiserID, "advertiser").First(&advertiser).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Advertiser not found"})
+		return
+	}
+
+	if err := h.db.Model(&advertiser).Update("platform_rate", req.PlatformRate).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update platform rate"})
+		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"message":       "Invoice generation completed",
-		"created_count": createdCount,
-		"skipped_count": skippedCount,
+		"message":       "Platform rate updated",
+		"advertiser_id": advertiser.ID,
+		"platform_rate": req.PlatformRate,
 	})
 }
 
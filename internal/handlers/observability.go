@@ -131,6 +131,15 @@ func (h *ObservabilityHandler) GetSystemHealth(c *gin.Context) {
 // GetHealth returns simplified health for public endpoint
 // GET /health
 func (h *ObservabilityHandler) GetHealth(c *gin.Context) {
+	// Fail health checks while shutting down so load balancers drain us
+	if !services.GetShutdownService().IsReady() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"status":  "shutting_down",
+			"message": "AffTok API is shutting down",
+		})
+		return
+	}
+
 	health := h.observability.GetSystemHealth()
 
 	status := "ok"
@@ -149,6 +158,17 @@ func (h *ObservabilityHandler) GetHealth(c *gin.Context) {
 	})
 }
 
+// GetReadiness reports whether the instance should receive traffic. It flips
+// to 503 as soon as a shutdown starts.
+// GET /ready
+func (h *ObservabilityHandler) GetReadiness(c *gin.Context) {
+	if !services.GetShutdownService().IsReady() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "shutting_down"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ready"})
+}
+
 // GetConnections returns connection information
 // GET /api/admin/connections
 func (h *ObservabilityHandler) GetConnections(c *gin.Context) {
@@ -1,9 +1,11 @@
 package handlers
 
 import (
+    "errors"
     "fmt"
     "net/http"
     "os"
+    "strconv"
     "strings"
 
     "github.com/aljapah/afftok-backend-prod/internal/models"
@@ -17,6 +19,9 @@ type OfferHandler struct {
     db                 *gorm.DB
     linkService        *services.LinkService
     linkSigningService *services.LinkSigningService
+    webhookService     *services.WebhookService
+    catalogService     *services.OfferCatalogService
+    accessService      *services.OfferAccessService
 }
 
 func NewOfferHandler(db *gorm.DB) *OfferHandler {
@@ -24,6 +29,9 @@ func NewOfferHandler(db *gorm.DB) *OfferHandler {
         db:                 db,
         linkService:        services.NewLinkService(),
         linkSigningService: services.NewLinkSigningService(),
+        webhookService:     services.GetWebhookService(db),
+        catalogService:     services.NewOfferCatalogService(db),
+        accessService:      services.NewOfferAccessService(db),
     }
 }
 
@@ -32,45 +40,61 @@ func (h *OfferHandler) SetLinkSigningService(service *services.LinkSigningServic
     h.linkSigningService = service
 }
 
+// GetAllOffers is the offer catalog: bilingual search (q), filters for
+// category, payout range, payout_type and country (by the offer's geo rules),
+// whitelisted sorts and cursor pagination (pass next_cursor back as cursor).
+// Category and payout_type take comma separated lists.
+// GET /api/offers?q=&category=&payout_type=&min_payout=&max_payout=&country=&sort=&order=&cursor=&limit=
 func (h *OfferHandler) GetAllOffers(c *gin.Context) {
-    var offers []models.Offer
-
-    page := 1
-    limit := 20
-    offset := (page - 1) * limit
-
-    query := h.db
-
-    status := c.Query("status")
-    if status != "" {
-        query = query.Where("status = ?", status)
-    } else {
-        query = query.Where("status = ?", "active")
+    query := services.OfferCatalogQuery{
+        Search:      c.Query("q"),
+        Categories:  splitQueryList(c.Query("category")),
+        PayoutTypes: splitQueryList(c.Query("payout_type")),
+        Country:     c.Query("country"),
+        Status:      c.Query("status"),
+        Sort:        c.Query("sort"),
+        Order:       c.Query("order"),
+        Cursor:      c.Query("cursor"),
+    }
+    if userID, ok := currentUserID(c); ok {
+        query.ViewerID = &userID
+    }
+
+    var err error
+    if query.MinPayout, err = optionalIntQuery(c, "min_payout"); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
     }
-
-    category := c.Query("category")
-    if category != "" {
-        query = query.Where("category = ?", category)
+    if query.MaxPayout, err = optionalIntQuery(c, "max_payout"); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+    if limit := c.Query("limit"); limit != "" {
+        if query.Limit, err = strconv.Atoi(limit); err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a number"})
+            return
+        }
     }
 
-    sortBy := c.DefaultQuery("sort", "created_at")
-    order := c.DefaultQuery("order", "desc")
-    query = query.Order(sortBy + " " + order)
-
-    if err := query.Limit(limit).Offset(offset).Find(&offers).Error; err != nil {
+    page, err := h.catalogService.Search(&query)
+    if err != nil {
+        if errors.Is(err, services.ErrInvalidCatalogQuery) {
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+        }
         c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch offers"})
         return
     }
 
-    var total int64
-    h.db.Model(&models.Offer{}).Where("status = ?", "active").Count(&total)
-
     c.JSON(http.StatusOK, gin.H{
-        "offers": offers,
+        "offers": page.Offers,
         "pagination": gin.H{
-            "page":  page,
-            "limit": limit,
-            "total": total,
+            "limit":       page.Limit,
+            "total":       page.Total,
+            "sort":        page.Sort,
+            "order":       page.Order,
+            "next_cursor": page.NextCursor,
+            "has_more":    page.HasMore,
         },
     })
 }
@@ -84,6 +108,16 @@ func (h *OfferHandler) GetOffer(c *gin.Context) {
         return
     }
 
+    // Invite-only offers don't exist for anyone who wasn't invited
+    var viewerID *uuid.UUID
+    if userID, ok := currentUserID(c); ok {
+        viewerID = &userID
+    }
+    if !h.accessService.CanView(&offer, viewerID) {
+        c.JSON(http.StatusNotFound, gin.H{"error": "Offer not found"})
+        return
+    }
+
     c.JSON(http.StatusOK, gin.H{
         "offer": offer,
     })
@@ -91,15 +125,21 @@ func (h *OfferHandler) GetOffer(c *gin.Context) {
 
 func (h *OfferHandler) CreateOffer(c *gin.Context) {
     type CreateOfferRequest struct {
-        Title          string `json:"title" binding:"required"`
-        Description    string `json:"description"`
-        ImageURL       string `json:"image_url"`
-        LogoURL        string `json:"logo_url"`
-        DestinationURL string `json:"destination_url" binding:"required"`
-        Category       string `json:"category"`
-        Payout         int    `json:"payout"`
-        Commission     int    `json:"commission"`
-        PayoutType     string `json:"payout_type"`
+        Title                 string `json:"title" binding:"required"`
+        Description           string `json:"description"`
+        ImageURL              string `json:"image_url"`
+        LogoURL               string `json:"logo_url"`
+        DestinationURL        string `json:"destination_url" binding:"required"`
+        Category              string `json:"category"`
+        Payout                int    `json:"payout"`
+        Commission            int    `json:"commission"`
+        PayoutType            string `json:"payout_type"`
+        AttributionWindowDays int    `json:"attribution_window_days"`
+        AttributionPolicy     string `json:"attribution_policy"`
+        TrackingTemplate      string   `json:"tracking_template"`
+        PassthroughParams     []string `json:"passthrough_params"`
+        Visibility            string   `json:"visibility"`
+        DeepLinkDomains       []string `json:"deep_link_domains"`
     }
 
     var req CreateOfferRequest
@@ -108,18 +148,53 @@ func (h *OfferHandler) CreateOffer(c *gin.Context) {
         return
     }
 
+    if err := validateAttributionSettings(req.AttributionWindowDays, req.AttributionPolicy); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+    if req.AttributionWindowDays == 0 {
+        req.AttributionWindowDays = models.DefaultAttributionWindowDays
+    }
+    if req.AttributionPolicy == "" {
+        req.AttributionPolicy = models.AttributionPolicyFlag
+    }
+    if req.Visibility == "" {
+        req.Visibility = models.OfferVisibilityPublic
+    }
+    if !models.IsValidOfferVisibility(req.Visibility) {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "visibility must be 'public', 'approval' or 'invite_only'"})
+        return
+    }
+
+    passthroughParams, err := normalizeTrackingSettings(req.TrackingTemplate, req.PassthroughParams)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+    deepLinkDomains, err := services.NormalizeDeepLinkDomains(req.DeepLinkDomains)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
     offer := models.Offer{
-        ID:             uuid.New(),
-        Title:          req.Title,
-        Description:    req.Description,
-        ImageURL:       req.ImageURL,
-        LogoURL:        req.LogoURL,
-        DestinationURL: req.DestinationURL,
-        Category:       req.Category,
-        Payout:         req.Payout,
-        Commission:     req.Commission,
-        PayoutType:     req.PayoutType,
-        Status:         "active",
+        ID:                    uuid.New(),
+        Title:                 req.Title,
+        Description:           req.Description,
+        ImageURL:              req.ImageURL,
+        LogoURL:               req.LogoURL,
+        DestinationURL:        req.DestinationURL,
+        Category:              req.Category,
+        Payout:                req.Payout,
+        Commission:            req.Commission,
+        PayoutType:            req.PayoutType,
+        AttributionWindowDays: req.AttributionWindowDays,
+        AttributionPolicy:     req.AttributionPolicy,
+        TrackingTemplate:      req.TrackingTemplate,
+        PassthroughParams:     passthroughParams,
+        Visibility:            req.Visibility,
+        DeepLinkDomains:       deepLinkDomains,
+        Status:                "active",
     }
 
     if err := h.db.Create(&offer).Error; err != nil {
@@ -137,15 +212,21 @@ func (h *OfferHandler) UpdateOffer(c *gin.Context) {
     offerID := c.Param("id")
 
     type UpdateOfferRequest struct {
-        Title          string `json:"title"`
-        Description    string `json:"description"`
-        ImageURL       string `json:"image_url"`
-        LogoURL        string `json:"logo_url"`
-        DestinationURL string `json:"destination_url"`
-        Category       string `json:"category"`
-        Payout         int    `json:"payout"`
-        Commission     int    `json:"commission"`
-        Status         string `json:"status"`
+        Title                 string `json:"title"`
+        Description           string `json:"description"`
+        ImageURL              string `json:"image_url"`
+        LogoURL               string `json:"logo_url"`
+        DestinationURL        string `json:"destination_url"`
+        Category              string `json:"category"`
+        Payout                int    `json:"payout"`
+        Commission            int    `json:"commission"`
+        Status                string `json:"status"`
+        AttributionWindowDays int    `json:"attribution_window_days"`
+        AttributionPolicy     string `json:"attribution_policy"`
+        TrackingTemplate      *string   `json:"tracking_template"`  // "" clears
+        PassthroughParams     *[]string `json:"passthrough_params"` // [] clears
+        Visibility            string    `json:"visibility"`
+        DeepLinkDomains       *[]string `json:"deep_link_domains"` // [] disables deep links
     }
 
     var req UpdateOfferRequest
@@ -154,6 +235,15 @@ func (h *OfferHandler) UpdateOffer(c *gin.Context) {
         return
     }
 
+    if err := validateAttributionSettings(req.AttributionWindowDays, req.AttributionPolicy); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+    if req.Visibility != "" && !models.IsValidOfferVisibility(req.Visibility) {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "visibility must be 'public', 'approval' or 'invite_only'"})
+        return
+    }
+
     updates := map[string]interface{}{}
     if req.Title != "" {
         updates["title"] = req.Title
@@ -182,6 +272,45 @@ func (h *OfferHandler) UpdateOffer(c *gin.Context) {
     if req.Status != "" {
         updates["status"] = req.Status
     }
+    if req.AttributionWindowDays > 0 {
+        updates["attribution_window_days"] = req.AttributionWindowDays
+    }
+    if req.AttributionPolicy != "" {
+        updates["attribution_policy"] = req.AttributionPolicy
+    }
+    if req.Visibility != "" {
+        updates["visibility"] = req.Visibility
+    }
+    if req.DeepLinkDomains != nil {
+        deepLinkDomains, err := services.NormalizeDeepLinkDomains(*req.DeepLinkDomains)
+        if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+        }
+        updates["deep_link_domains"] = deepLinkDomains
+    }
+
+    if req.TrackingTemplate != nil || req.PassthroughParams != nil {
+        var current models.Offer
+        if err := h.db.First(&current, "id = ?", offerID).Error; err != nil {
+            c.JSON(http.StatusNotFound, gin.H{"error": "Offer not found"})
+            return
+        }
+        template, passthrough := current.TrackingTemplate, current.PassthroughParamList()
+        if req.TrackingTemplate != nil {
+            template = *req.TrackingTemplate
+        }
+        if req.PassthroughParams != nil {
+            passthrough = *req.PassthroughParams
+        }
+        passthroughParams, err := normalizeTrackingSettings(template, passthrough)
+        if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+        }
+        updates["tracking_template"] = template
+        updates["passthrough_params"] = passthroughParams
+    }
 
     if err := h.db.Model(&models.Offer{}).Where("id = ?", offerID).Updates(updates).Error; err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update offer"})
@@ -197,6 +326,52 @@ func (h *OfferHandler) UpdateOffer(c *gin.Context) {
     })
 }
 
+// splitQueryList splits a comma separated query parameter
+func splitQueryList(value string) []string {
+    if value == "" {
+        return nil
+    }
+    return strings.Split(value, ",")
+}
+
+// optionalIntQuery parses an optional integer query parameter
+func optionalIntQuery(c *gin.Context, name string) (*int, error) {
+    raw := c.Query(name)
+    if raw == "" {
+        return nil, nil
+    }
+    value, err := strconv.Atoi(raw)
+    if err != nil {
+        return nil, fmt.Errorf("%s must be a number", name)
+    }
+    return &value, nil
+}
+
+// normalizeTrackingSettings validates a tracking template against the
+// passthrough whitelist and returns the whitelist in its stored form
+func normalizeTrackingSettings(template string, passthrough []string) (string, error) {
+    passthroughParams, err := services.NormalizePassthroughParams(passthrough)
+    if err != nil {
+        return "", err
+    }
+    offer := models.Offer{PassthroughParams: passthroughParams}
+    if err := services.ValidateTrackingTemplate(template, offer.PassthroughParamList()); err != nil {
+        return "", err
+    }
+    return passthroughParams, nil
+}
+
+// validateAttributionSettings checks attribution window/policy input (zero values mean "unchanged")
+func validateAttributionSettings(windowDays int, policy string) error {
+    if windowDays < 0 || windowDays > models.MaxAttributionWindowDays {
+        return fmt.Errorf("attribution_window_days must be between 1 and %d", models.MaxAttributionWindowDays)
+    }
+    if policy != "" && !models.IsValidAttributionPolicy(policy) {
+        return fmt.Errorf("attribution_policy must be 'flag' or 'reject'")
+    }
+    return nil
+}
+
 func (h *OfferHandler) DeleteOffer(c *gin.Context) {
     offerID := c.Param("id")
 
@@ -227,14 +402,32 @@ func (h *OfferHandler) JoinOffer(c *gin.Context) {
         return
     }
 
+    // Approval and invite-only offers need an approved application or invite
+    application, err := h.accessService.CheckJoinAccess(&offer, userUUID)
+    if err != nil {
+        if errors.Is(err, services.ErrOfferApplicationRequired) ||
+            errors.Is(err, services.ErrOfferInviteOnly) ||
+            errors.Is(err, services.ErrOfferAccessRevoked) {
+            c.JSON(http.StatusForbidden, gin.H{"error": err.Error(), "visibility": offer.Visibility})
+            return
+        }
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check offer access"})
+        return
+    }
+
     // Check for existing user offer
     var existingUserOffer models.UserOffer
     if err := h.db.Where("user_id = ? AND offer_id = ?", userUUID, offerID).First(&existingUserOffer).Error; err == nil {
-        c.JSON(http.StatusConflict, gin.H{
-            "error":          "You already joined this offer",
-            "user_offer":     existingUserOffer,
-            "affiliate_link": existingUserOffer.AffiliateLink,
-        })
+        if existingUserOffer.IsActive() {
+            c.JSON(http.StatusConflict, gin.H{
+                "error":          "You already joined this offer",
+                "user_offer":     existingUserOffer,
+                "affiliate_link": existingUserOffer.AffiliateLink,
+            })
+            return
+        }
+        // Revoked and since invited back: reactivate the existing links
+        h.rejoinOffer(c, &existingUserOffer, offer, application)
         return
     }
 
@@ -264,13 +457,14 @@ func (h *OfferHandler) JoinOffer(c *gin.Context) {
     }
 
     userOffer := models.UserOffer{
-        ID:            userOfferID,
-        UserID:        userUUID,
-        OfferID:       offer.ID,
-        AffiliateLink: affiliateLink,
-        ShortLink:     trackingCode, // Store just the code, not the full path
-        TrackingCode:  trackingCode,
-        Status:        "active",
+        ID:             userOfferID,
+        UserID:         userUUID,
+        OfferID:        offer.ID,
+        AffiliateLink:  affiliateLink,
+        ShortLink:      trackingCode, // Store just the code, not the full path
+        TrackingCode:   trackingCode,
+        Status:         models.UserOfferStatusActive,
+        PayoutOverride: services.PayoutOverrideFor(application),
     }
 
     // Use transaction for atomic operation
@@ -280,6 +474,10 @@ func (h *OfferHandler) JoinOffer(c *gin.Context) {
             return err
         }
 
+        if err := h.accessService.CompleteJoin(tx, application); err != nil {
+            return err
+        }
+
         // Increment users_count on offer
         if err := tx.Model(&models.Offer{}).
             Where("id = ?", offer.ID).
@@ -295,16 +493,69 @@ func (h *OfferHandler) JoinOffer(c *gin.Context) {
         return
     }
 
-    // Generate tracking URL for mobile app
+    h.webhookService.EmitJoinOffer(userOffer, offer)
+
+    trackingURL, signedLink := h.trackingURLs(offer, userUUID, trackingCode, shortLink)
+
+    c.JSON(http.StatusCreated, gin.H{
+        "success":        true,
+        "message":        "Joined offer successfully",
+        "user_offer":     userOffer,
+        "affiliate_link": affiliateLink,
+        "tracking_url":   trackingURL,
+        "short_link":     trackingCode,        // Original tracking code
+        "signed_link":    signedLink,          // Signed version
+    })
+}
+
+// rejoinOffer reactivates a revoked promoter's user offer once they are
+// allowed back in, keeping their existing tracking links
+func (h *OfferHandler) rejoinOffer(c *gin.Context, userOffer *models.UserOffer, offer models.Offer, application *models.OfferApplication) {
+    err := h.db.Transaction(func(tx *gorm.DB) error {
+        userOffer.Status = models.UserOfferStatusActive
+        userOffer.PayoutOverride = services.PayoutOverrideFor(application)
+        if err := tx.Model(userOffer).Updates(map[string]interface{}{
+            "status":          userOffer.Status,
+            "payout_override": userOffer.PayoutOverride,
+        }).Error; err != nil {
+            return err
+        }
+
+        if err := h.accessService.CompleteJoin(tx, application); err != nil {
+            return err
+        }
+
+        return tx.Model(&models.Offer{}).
+            Where("id = ?", offer.ID).
+            UpdateColumn("users_count", gorm.Expr("users_count + 1")).Error
+    })
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to join offer"})
+        return
+    }
+
+    h.webhookService.EmitJoinOffer(*userOffer, offer)
+
+    trackingURL, signedLink := h.trackingURLs(offer, userOffer.UserID, userOffer.TrackingCode, userOffer.ShortLink)
+
+    c.JSON(http.StatusCreated, gin.H{
+        "success":        true,
+        "message":        "Joined offer successfully",
+        "user_offer":     userOffer,
+        "affiliate_link": userOffer.AffiliateLink,
+        "tracking_url":   trackingURL,
+        "short_link":     userOffer.TrackingCode,
+        "signed_link":    signedLink,
+    })
+}
+
+// trackingURLs returns the tracking URL for the mobile app and the signed link
+func (h *OfferHandler) trackingURLs(offer models.Offer, userUUID uuid.UUID, trackingCode, shortLink string) (string, string) {
     // Use signed link for security
     var trackingURL string
     var signedLink string
     
-    // Get base URL from environment or use production default
-    baseURL := os.Getenv("BASE_URL")
-    if baseURL == "" {
-        baseURL = "https://afftok-backend-prod-production.up.railway.app"
-    }
+    baseURL := trackingBaseURL()
     
     if trackingCode != "" && h.linkSigningService != nil {
         // Generate signed tracking link
@@ -320,14 +571,70 @@ func (h *OfferHandler) JoinOffer(c *gin.Context) {
         signedLink = ""
     }
 
+    return trackingURL, signedLink
+}
+
+// trackingBaseURL returns the base URL of tracking links, from BASE_URL or
+// the production default
+func trackingBaseURL() string {
+    if baseURL := os.Getenv("BASE_URL"); baseURL != "" {
+        return baseURL
+    }
+    return "https://afftok-backend-prod-production.up.railway.app"
+}
+
+// ApplyToOffer submits an application to an approval-required offer
+// POST /api/offers/:id/apply
+func (h *OfferHandler) ApplyToOffer(c *gin.Context) {
+    userUUID := c.MustGet("userID").(uuid.UUID)
+
+    var req struct {
+        TrafficSource string `json:"traffic_source" binding:"required"`
+        Message       string `json:"message"`
+    }
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    var offer models.Offer
+    if err := h.db.First(&offer, "id = ? AND status = ?", c.Param("id"), "active").Error; err != nil {
+        c.JSON(http.StatusNotFound, gin.H{"error": "Offer not found"})
+        return
+    }
+
+    application, err := h.accessService.Apply(&offer, userUUID, req.TrafficSource, req.Message)
+    if err != nil {
+        switch {
+        case errors.Is(err, services.ErrOfferApplicationExists):
+            c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+        case errors.Is(err, services.ErrOfferInviteOnly), errors.Is(err, services.ErrOfferAccessRevoked):
+            c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+        default:
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        }
+        return
+    }
+
     c.JSON(http.StatusCreated, gin.H{
-        "success":        true,
-        "message":        "Joined offer successfully",
-        "user_offer":     userOffer,
-        "affiliate_link": affiliateLink,
-        "tracking_url":   trackingURL,
-        "short_link":     trackingCode,        // Original tracking code
-        "signed_link":    signedLink,          // Signed version
+        "message":     "Application submitted",
+        "application": application,
+    })
+}
+
+// GetMyApplications returns the promoter's offer applications and invites
+// GET /api/offers/applications
+func (h *OfferHandler) GetMyApplications(c *gin.Context) {
+    userUUID := c.MustGet("userID").(uuid.UUID)
+
+    applications, err := h.accessService.ListForPromoter(userUUID)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch applications"})
+        return
+    }
+
+    c.JSON(http.StatusOK, gin.H{
+        "applications": applications,
     })
 }
 
@@ -0,0 +1,270 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/aljapah/afftok-backend-prod/internal/middleware"
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/aljapah/afftok-backend-prod/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ============================================
+// OFFER ROUTING HANDLER
+// ============================================
+
+// OfferRoutingHandler manages the caps, routing rules, A/B tests and rotation
+// of an offer or a single user offer. Admins can manage any offer; advertisers
+// only the offers they own.
+type OfferRoutingHandler struct {
+	db             *gorm.DB
+	routingService *services.OfferRoutingService
+}
+
+// NewOfferRoutingHandler creates a new offer routing handler
+func NewOfferRoutingHandler(db *gorm.DB) *OfferRoutingHandler {
+	return &OfferRoutingHandler{
+		db:             db,
+		routingService: services.GetOfferRoutingService(db),
+	}
+}
+
+// ============================================
+// OFFER-LEVEL CONFIG
+// ============================================
+
+// GetOfferRouting returns an offer's routing config, its cap usage and the
+// user offers that override it
+// GET /api/admin/offers/:id/routing, GET /api/advertiser/offers/:id/routing
+func (h *OfferRoutingHandler) GetOfferRouting(c *gin.Context) {
+	h.get(c, false)
+}
+
+// SaveOfferRouting creates or replaces an offer's routing config
+// PUT /api/admin/offers/:id/routing, PUT /api/advertiser/offers/:id/routing
+func (h *OfferRoutingHandler) SaveOfferRouting(c *gin.Context) {
+	h.save(c, false)
+}
+
+// DeleteOfferRouting removes an offer's routing config
+// DELETE /api/admin/offers/:id/routing, DELETE /api/advertiser/offers/:id/routing
+func (h *OfferRoutingHandler) DeleteOfferRouting(c *gin.Context) {
+	h.delete(c, false)
+}
+
+// ============================================
+// USER OFFER CONFIG
+// ============================================
+
+// GetUserOfferRouting returns a user offer's own routing config
+// GET /api/admin/user-offers/:id/routing, GET /api/advertiser/user-offers/:id/routing
+func (h *OfferRoutingHandler) GetUserOfferRouting(c *gin.Context) {
+	h.get(c, true)
+}
+
+// SaveUserOfferRouting creates or replaces a user offer's routing config
+// PUT /api/admin/user-offers/:id/routing, PUT /api/advertiser/user-offers/:id/routing
+func (h *OfferRoutingHandler) SaveUserOfferRouting(c *gin.Context) {
+	h.save(c, true)
+}
+
+// DeleteUserOfferRouting removes a user offer's routing config, so the
+// offer's config applies again
+// DELETE /api/admin/user-offers/:id/routing, DELETE /api/advertiser/user-offers/:id/routing
+func (h *OfferRoutingHandler) DeleteUserOfferRouting(c *gin.Context) {
+	h.delete(c, true)
+}
+
+// ============================================
+// SHARED
+// ============================================
+
+func (h *OfferRoutingHandler) get(c *gin.Context, userOfferScope bool) {
+	correlationID := uuid.New().String()[:8]
+
+	offer, userOffer, ok := h.routingScope(c, correlationID, userOfferScope)
+	if !ok {
+		return
+	}
+
+	config, err := h.routingService.GetConfig(offer.ID, userOfferIDOf(userOffer))
+	if err != nil {
+		h.respondServiceError(c, correlationID, err)
+		return
+	}
+
+	data := gin.H{
+		"config": config,
+		"caps":   h.routingService.CapState(config, offer, userOffer),
+	}
+	if !userOfferScope {
+		overrides, err := h.routingService.ListUserOfferConfigs(offer.ID)
+		if err == nil {
+			data["user_offer_overrides"] = overrides
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":        true,
+		"correlation_id": correlationID,
+		"data":           data,
+		"timestamp":      time.Now().UTC(),
+	})
+}
+
+func (h *OfferRoutingHandler) save(c *gin.Context, userOfferScope bool) {
+	correlationID := uuid.New().String()[:8]
+
+	offer, userOffer, ok := h.routingScope(c, correlationID, userOfferScope)
+	if !ok {
+		return
+	}
+
+	var req services.OfferRoutingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          "Invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	var updatedBy *uuid.UUID
+	if userID, ok := currentUserID(c); ok {
+		updatedBy = &userID
+	}
+
+	config, err := h.routingService.SaveConfig(offer.ID, userOfferIDOf(userOffer), middleware.GetTenantID(c), updatedBy, &req)
+	if err != nil {
+		h.respondServiceError(c, correlationID, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":        true,
+		"correlation_id": correlationID,
+		"data":           config,
+		"timestamp":      time.Now().UTC(),
+	})
+}
+
+func (h *OfferRoutingHandler) delete(c *gin.Context, userOfferScope bool) {
+	correlationID := uuid.New().String()[:8]
+
+	offer, userOffer, ok := h.routingScope(c, correlationID, userOfferScope)
+	if !ok {
+		return
+	}
+
+	if err := h.routingService.DeleteConfig(offer.ID, userOfferIDOf(userOffer)); err != nil {
+		h.respondServiceError(c, correlationID, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":        true,
+		"correlation_id": correlationID,
+		"message":        "Routing config deleted",
+		"timestamp":      time.Now().UTC(),
+	})
+}
+
+// routingScope loads the offer (and user offer) a request targets and checks
+// that non-admins own the offer. It writes the error response itself.
+func (h *OfferRoutingHandler) routingScope(c *gin.Context, correlationID string, userOfferScope bool) (*models.Offer, *models.UserOffer, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          "Invalid ID",
+		})
+		return nil, nil, false
+	}
+
+	var offer models.Offer
+	var userOffer *models.UserOffer
+	if userOfferScope {
+		var uo models.UserOffer
+		if err := h.db.Preload("Offer").First(&uo, "id = ?", id).Error; err != nil || uo.Offer == nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"success":        false,
+				"correlation_id": correlationID,
+				"error":          "User offer not found",
+			})
+			return nil, nil, false
+		}
+		offer = *uo.Offer
+		userOffer = &uo
+	} else if err := h.db.First(&offer, "id = ?", id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          "Offer not found",
+		})
+		return nil, nil, false
+	}
+
+	if role, _ := c.Get("role"); role != "admin" {
+		userID, ok := currentUserID(c)
+		if !ok || offer.AdvertiserID == nil || *offer.AdvertiserID != userID {
+			c.JSON(http.StatusNotFound, gin.H{
+				"success":        false,
+				"correlation_id": correlationID,
+				"error":          "Offer not found or not owned by you",
+			})
+			return nil, nil, false
+		}
+	}
+
+	return &offer, userOffer, true
+}
+
+// respondServiceError maps routing service errors to responses
+func (h *OfferRoutingHandler) respondServiceError(c *gin.Context, correlationID string, err error) {
+	var validationErr *services.RoutingValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          "Invalid routing config",
+			"details":        validationErr.Problems,
+		})
+	case errors.Is(err, services.ErrRoutingConfigNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          "Routing config not found",
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          err.Error(),
+		})
+	}
+}
+
+// currentUserID returns the authenticated user's ID
+func currentUserID(c *gin.Context) (uuid.UUID, bool) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		return uuid.Nil, false
+	}
+	id, ok := userID.(uuid.UUID)
+	return id, ok
+}
+
+// userOfferIDOf returns the user offer's ID, or nil for offer-level scope
+func userOfferIDOf(userOffer *models.UserOffer) *uuid.UUID {
+	if userOffer == nil {
+		return nil
+	}
+	return &userOffer.ID
+}
@@ -2,10 +2,13 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
+	"github.com/aljapah/afftok-backend-prod/internal/middleware"
 	"github.com/aljapah/afftok-backend-prod/internal/models"
 	"github.com/aljapah/afftok-backend-prod/internal/services"
 	"github.com/gin-gonic/gin"
@@ -19,6 +22,10 @@ type PostbackHandler struct {
 	observabilityService *services.ObservabilityService
 	apiKeyService        *services.APIKeyService
 	geoRuleService       *services.GeoRuleService
+	attributionService   *services.AttributionService
+	geoIPService         *services.GeoIPService
+	lifecycleService     *services.ConversionLifecycleService
+	webhookService       *services.WebhookService
 }
 
 func NewPostbackHandler(db *gorm.DB) *PostbackHandler {
@@ -28,6 +35,10 @@ func NewPostbackHandler(db *gorm.DB) *PostbackHandler {
 		observabilityService: services.NewObservabilityService(),
 		apiKeyService:        services.NewAPIKeyService(db),
 		geoRuleService:       services.NewGeoRuleService(db),
+		attributionService:   services.NewAttributionService(db),
+		geoIPService:         services.GetGeoIPService(),
+		lifecycleService:     services.NewConversionLifecycleService(db),
+		webhookService:       services.GetWebhookService(db),
 	}
 }
 
@@ -88,12 +99,22 @@ func (h *PostbackHandler) HandlePostback(c *gin.Context) {
 	apiKeyID := c.GetString("api_key_id")
 	advertiserID := c.GetString("advertiser_id")
 	
-	// If API key auth, get the advertiser context
-	var authenticatedAdvertiserID *uuid.UUID
-	if authMethod == "api_key" && advertiserID != "" {
-		if id, err := uuid.Parse(advertiserID); err == nil {
-			authenticatedAdvertiserID = &id
+	// If API key auth, get the key; its postbacks are limited to its own offers
+	var apiKey *models.AdvertiserAPIKey
+	if authMethod == middleware.AuthMethodAPIKey {
+		key, ok := middleware.GetAPIKey(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid API key context"})
+			return
+		}
+		if !key.HasPermission(models.PermissionPostbackWrite) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "Missing required permission: " + models.PermissionPostbackWrite,
+				"code":  "PERMISSION_DENIED",
+			})
+			return
 		}
+		apiKey = key
 	}
 	
 	// Security: Rate limiting (different limits for API key vs IP)
@@ -130,8 +151,6 @@ func (h *PostbackHandler) HandlePostback(c *gin.Context) {
 		})
 	}
 	
-	_ = authenticatedAdvertiserID // Will be used for validation in future
-
 	var req PostbackRequest
 	
 	// Support both JSON and form/query params
@@ -180,10 +199,43 @@ func (h *PostbackHandler) HandlePostback(c *gin.Context) {
 		0, // duration will be set later
 	)
 
-	// Resolve user offer ID from various sources
+	now := time.Now().UTC()
+
+	// Resolve the click that earned this conversion (click_id from the redirect)
+	var attribution *services.AttributionResult
+	if req.ClickID != "" {
+		if result, err := h.attributionService.Attribute(req.ClickID, now); err == nil {
+			attribution = result
+		} else {
+			fmt.Printf("[Postback] Unresolved click_id %s: %v\n", req.ClickID, err)
+		}
+	}
+
+	// Resolve user offer ID from various sources, falling back to the click
 	userOfferID, err := h.resolveUserOfferID(&req)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		if attribution == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		userOfferID = attribution.UserOffer.ID
+	}
+
+	// The click must belong to the user offer being credited
+	if attribution != nil && attribution.UserOffer.ID != userOfferID {
+		h.observabilityService.LogFraud(
+			ip,
+			c.Request.UserAgent(),
+			"click_user_offer_mismatch",
+			70,
+			0.8,
+			[]string{"click_mismatch"},
+			map[string]interface{}{
+				"click_id":      req.ClickID,
+				"user_offer_id": userOfferID.String(),
+			},
+		)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "click_id does not belong to this user offer"})
 		return
 	}
 
@@ -194,6 +246,45 @@ func (h *PostbackHandler) HandlePostback(c *gin.Context) {
 		return
 	}
 
+	// API keys may only post conversions for their advertiser's (or network's) offers
+	if apiKey != nil && !apiKey.CanAccessOffer(userOffer.Offer) {
+		offerAdvertiserID := ""
+		if userOffer.Offer != nil && userOffer.Offer.AdvertiserID != nil {
+			offerAdvertiserID = userOffer.Offer.AdvertiserID.String()
+		}
+		h.observabilityService.LogFraud(
+			ip,
+			c.Request.UserAgent(),
+			"postback_advertiser_mismatch",
+			90,
+			0.95,
+			[]string{"api_key_scope_violation", "cross_advertiser_postback"},
+			map[string]interface{}{
+				"api_key_id":          apiKeyID,
+				"advertiser_id":       advertiserID,
+				"offer_id":            userOffer.OfferID.String(),
+				"offer_advertiser_id": offerAdvertiserID,
+				"user_offer_id":       userOfferID.String(),
+			},
+		)
+		h.securityService.LogAuditEvent(services.AuditEvent{
+			Timestamp: time.Now(),
+			EventType: "postback_scope_violation",
+			UserID:    advertiserID,
+			IP:        ip,
+			Resource:  c.Request.URL.Path,
+			Action:    "postback",
+			Success:   false,
+			Details: map[string]interface{}{
+				"api_key_id":    apiKeyID,
+				"user_offer_id": userOfferID.String(),
+			},
+		})
+		h.observabilityService.LogPostback(userOfferID.String(), req.NetworkID, req.ExternalID, ip, false, "advertiser_mismatch", time.Since(startTime).Milliseconds())
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key is not authorized for this offer"})
+		return
+	}
+
 	// Generate unique external ID if not provided
 	externalID := req.ExternalID
 	if externalID == "" {
@@ -204,28 +295,70 @@ func (h *PostbackHandler) HandlePostback(c *gin.Context) {
 		externalID = fmt.Sprintf("auto_%s_%d", userOfferID.String()[:8], time.Now().UnixNano())
 	}
 
-	// Check for duplicate conversion
+	// Existing conversion: a repeat postback, or a status update
 	if externalID != "" {
 		var existingConversion models.Conversion
 		if err := h.db.Where("external_conversion_id = ?", externalID).First(&existingConversion).Error; err == nil {
-			// Duplicate found - return success but don't create new
-			c.JSON(http.StatusOK, gin.H{
-				"message":    "Conversion already recorded",
-				"duplicate":  true,
-				"conversion": existingConversion,
-			})
+			h.handleStatusUpdate(c, &existingConversion, userOfferID, &req)
+			return
+		}
+
+		// The first postback for this order may still be in the WAL
+		if h.lifecycleService.IsPendingConversion(externalID) {
+			h.queueStatusUpdate(c, externalID, userOfferID, &req)
 			return
 		}
 	}
 
-	// Resolve click ID if provided
-	var clickID *uuid.UUID
-	if req.ClickID != "" {
-		if id, err := uuid.Parse(req.ClickID); err == nil {
-			clickID = &id
+	// Enforce the offer's attribution window. Only new conversions are refused:
+	// a refund or chargeback for a stored one must still reverse it, however late.
+	if attribution != nil && attribution.IsRejected() {
+		h.observabilityService.LogPostback(userOfferID.String(), req.NetworkID, req.ExternalID, ip, false, "outside_attribution_window", time.Since(startTime).Milliseconds())
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":  "Conversion is outside the offer's attribution window",
+			"reason": attribution.Reason,
+		})
+		return
+	}
+
+	// Geo rules on new conversions (GEO_ENFORCE_ON_POSTBACK)
+	if GeoEnforceOnPostback {
+		countryCode := h.resolvePostbackCountry(&req, attribution)
+		var advertiserID *uuid.UUID
+		if userOffer.Offer != nil {
+			advertiserID = userOffer.Offer.AdvertiserID
+		}
+
+		geoResult := h.geoRuleService.GetEffectiveGeoRule(&userOffer.OfferID, advertiserID, countryCode)
+		if !geoResult.Allowed {
+			h.observabilityService.LogFraud(
+				ip,
+				c.Request.UserAgent(),
+				"geo_block",
+				80,
+				0.9,
+				[]string{"geo_block", "geo_rule_violation", "postback"},
+				map[string]interface{}{
+					"country":       countryCode,
+					"offer_id":      userOffer.OfferID.String(),
+					"user_offer_id": userOfferID.String(),
+					"rule_id":       getRuleID(geoResult.Rule),
+					"mode":          getRuleMode(geoResult.Rule),
+					"reason":        geoResult.Reason,
+				},
+			)
+			h.observabilityService.LogPostback(userOfferID.String(), req.NetworkID, req.ExternalID, ip, false, "geo_blocked", time.Since(startTime).Milliseconds())
+			c.JSON(http.StatusForbidden, gin.H{"error": "Conversion country is not allowed for this offer"})
+			return
 		}
 	}
 
+	// Link the conversion to the attributed click
+	var clickID *uuid.UUID
+	if attribution != nil {
+		clickID = attribution.ClickID()
+	}
+
 	// Resolve network ID
 	var networkID *uuid.UUID
 	if req.NetworkID != "" {
@@ -234,11 +367,21 @@ func (h *PostbackHandler) HandlePostback(c *gin.Context) {
 		}
 	}
 
-	// Determine status
-	status := req.Status
+	// Determine status; new conversions start pending, approved or rejected
+	status := strings.ToLower(strings.TrimSpace(req.Status))
 	if status == "" {
 		status = models.ConversionStatusPending
 	}
+	if status != models.ConversionStatusPending && status != models.ConversionStatusApproved && status != models.ConversionStatusRejected {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status for a new conversion: " + req.Status})
+		return
+	}
+
+	// Late conversions are held for review instead of auto-approved
+	outsideWindow := attribution != nil && attribution.IsFlagged()
+	if outsideWindow {
+		status = models.ConversionStatusPending
+	}
 
 	// Determine currency
 	currency := req.Currency
@@ -248,77 +391,46 @@ func (h *PostbackHandler) HandlePostback(c *gin.Context) {
 
 	// Calculate commission if not provided
 	commission := req.Commission
-	if commission == 0 && userOffer.Offer != nil {
-		commission = userOffer.Offer.Commission
+	if commission == 0 {
+		commission = userOffer.EffectiveCommission()
 	}
 
 	// Store postback data for audit
 	postbackData, _ := json.Marshal(req)
-	now := time.Now().UTC()
 
 	// Create conversion record
 	conversion := models.Conversion{
-		ID:                   uuid.New(),
-		UserOfferID:          userOfferID,
-		ClickID:              clickID,
-		ExternalConversionID: externalID,
-		NetworkID:            networkID,
-		Amount:               req.Amount,
-		Commission:           commission,
-		Currency:             currency,
-		Status:               status,
-		ConvertedAt:          now,
-		PostbackData:         string(postbackData),
-		PostbackReceivedAt:   &now,
-	}
-
-	// Use transaction for atomic updates
-	err = h.db.Transaction(func(tx *gorm.DB) error {
-		// 1. Create conversion
-		if err := tx.Create(&conversion).Error; err != nil {
-			return fmt.Errorf("failed to create conversion: %w", err)
-		}
-
-		// 2. Update UserOffer stats (conversion counter + earnings + updated_at)
-		userOfferUpdates := map[string]interface{}{
-			"total_conversions": gorm.Expr("total_conversions + 1"),
-			"updated_at":        now,
-		}
-		if status == models.ConversionStatusApproved {
-			userOfferUpdates["earnings"] = gorm.Expr("earnings + ?", commission)
-		}
-		if err := tx.Model(&models.UserOffer{}).
-			Where("id = ?", userOfferID).
-			UpdateColumns(userOfferUpdates).Error; err != nil {
-			return fmt.Errorf("failed to update user offer: %w", err)
-		}
-
-		// 3. Update Offer total conversions
-		if err := tx.Model(&models.Offer{}).
-			Where("id = ?", userOffer.OfferID).
-			UpdateColumn("total_conversions", gorm.Expr("total_conversions + 1")).Error; err != nil {
-			return fmt.Errorf("failed to update offer conversions: %w", err)
-		}
-
-		// 4. Update User stats
-		if err := tx.Model(&models.AfftokUser{}).
-			Where("id = ?", userOffer.UserID).
-			UpdateColumn("total_conversions", gorm.Expr("total_conversions + 1")).Error; err != nil {
-			return fmt.Errorf("failed to update user conversions: %w", err)
-		}
-
-		// 5. If approved, update earnings
-		if status == models.ConversionStatusApproved {
-			if err := tx.Model(&models.AfftokUser{}).
-				Where("id = ?", userOffer.UserID).
-				UpdateColumn("total_earnings", gorm.Expr("total_earnings + ?", commission)).Error; err != nil {
-				return fmt.Errorf("failed to update user earnings: %w", err)
-			}
+		ID:                       uuid.New(),
+		UserOfferID:              userOfferID,
+		ClickID:                  clickID,
+		ExternalConversionID:     externalID,
+		NetworkID:                networkID,
+		Amount:                   req.Amount,
+		Commission:               commission,
+		Currency:                 currency,
+		Status:                   status,
+		OutsideAttributionWindow: outsideWindow,
+		ConvertedAt:              now,
+		PostbackData:             string(postbackData),
+		PostbackReceivedAt:       &now,
+	}
+
+	// Persist: WAL-first (acknowledged now, written asynchronously) when
+	// zero-drop mode is on for the tenant, otherwise in one transaction
+	queued, err := h.lifecycleService.RecordConversion(&services.ConversionEvent{
+		Conversion: &conversion,
+		OfferID:    userOffer.OfferID,
+		UserID:     userOffer.UserID,
+		Source:     models.ConversionSourcePostback,
+		Postback:   postbackPayload(&req),
+	}, middleware.GetTenantID(c).String())
+	if errors.Is(err, services.ErrDuplicateConversion) {
+		// A concurrent postback for the same order was stored first
+		if existing, findErr := h.lifecycleService.FindByExternalID(externalID); findErr == nil {
+			h.handleStatusUpdate(c, existing, userOfferID, &req)
+			return
 		}
-
-		return nil
-	})
-
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process postback: " + err.Error()})
 		return
@@ -342,9 +454,18 @@ func (h *PostbackHandler) HandlePostback(c *gin.Context) {
 		"success":    true,
 		"message":    "Conversion recorded successfully",
 		"conversion": conversion,
+		"queued":     queued,
 	})
 }
 
+// postbackPayload converts a postback request to a webhook trigger payload
+func postbackPayload(req *PostbackRequest) map[string]interface{} {
+	payload := map[string]interface{}{}
+	raw, _ := json.Marshal(req)
+	json.Unmarshal(raw, &payload)
+	return payload
+}
+
 // resolveUserOfferID resolves the user offer ID from various request parameters
 func (h *PostbackHandler) resolveUserOfferID(req *PostbackRequest) (uuid.UUID, error) {
 	// 1. Direct user_offer_id
@@ -377,64 +498,103 @@ func (h *PostbackHandler) resolveUserOfferID(req *PostbackRequest) (uuid.UUID, e
 	return uuid.Nil, fmt.Errorf("unable to resolve user offer ID from request")
 }
 
-// ApproveConversion approves a pending conversion
-func (h *PostbackHandler) ApproveConversion(c *gin.Context) {
-	conversionID := c.Param("id")
+// resolvePostbackCountry picks the country used for geo rules on a postback:
+// the country reported by the advertiser, else the attributed click's recorded
+// country, else a GeoIP lookup of the click IP. The postback sender's own IP is
+// never used; it is the advertiser's server, not the customer.
+func (h *PostbackHandler) resolvePostbackCountry(req *PostbackRequest, attribution *services.AttributionResult) string {
+	if country := strings.ToUpper(strings.TrimSpace(req.Country)); country != "" {
+		return country
+	}
+	if attribution == nil || attribution.Click == nil {
+		return ""
+	}
+	if attribution.Click.Country != "" {
+		return attribution.Click.Country
+	}
+	if loc := h.geoIPService.Lookup(attribution.Click.IPAddress); loc != nil {
+		return loc.CountryCode
+	}
+	return ""
+}
 
-	var conversion models.Conversion
-	if err := h.db.First(&conversion, "id = ?", conversionID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Conversion not found"})
+// handleStatusUpdate applies a postback for a conversion that already exists.
+// A repeat of the current status is acknowledged as a duplicate; anything else
+// must be a valid transition (e.g. rejected/refunded on an approved conversion
+// reverses it and claws back the commission).
+func (h *PostbackHandler) handleStatusUpdate(c *gin.Context, existing *models.Conversion, userOfferID uuid.UUID, req *PostbackRequest) {
+	requested := req.Status
+	if existing.UserOfferID != userOfferID {
+		c.JSON(http.StatusConflict, gin.H{"error": "external_id belongs to a different user offer"})
 		return
 	}
 
-	if !conversion.CanApprove() {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Conversion cannot be approved (current status: " + conversion.Status + ")"})
+	target := existing.ResolveStatusUpdate(requested)
+	if target == "" || target == existing.Status {
+		c.JSON(http.StatusOK, gin.H{
+			"message":    "Conversion already recorded",
+			"duplicate":  true,
+			"conversion": existing,
+		})
 		return
 	}
 
-	now := time.Now().UTC()
-	
-	// Use transaction for atomic update
-	err := h.db.Transaction(func(tx *gorm.DB) error {
-		// Get user offer for user ID
-		var userOffer models.UserOffer
-		if err := tx.First(&userOffer, "id = ?", conversion.UserOfferID).Error; err != nil {
-			return err
-		}
-
-		// Update conversion status
-		conversion.Status = models.ConversionStatusApproved
-		conversion.ApprovedAt = &now
-		if err := tx.Save(&conversion).Error; err != nil {
-			return err
-		}
-
-		// Update user earnings
-		if err := tx.Model(&models.AfftokUser{}).
-			Where("id = ?", userOffer.UserID).
-			UpdateColumn("total_earnings", gorm.Expr("total_earnings + ?", conversion.Commission)).Error; err != nil {
-			return err
+	conversion, previous, err := h.lifecycleService.Transition(existing.ID, services.ConversionTransition{
+		ToStatus: target,
+		Source:   models.ConversionSourcePostback,
+		Reason:   "status update postback: " + requested,
+	})
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidConversionTransition) {
+			c.JSON(http.StatusConflict, gin.H{
+				"error":          "Conversion cannot move from " + existing.Status + " to " + target,
+				"current_status": existing.Status,
+			})
+			return
 		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update conversion: " + err.Error()})
+		return
+	}
 
-		return nil
+	fmt.Printf("[Postback] Conversion %s: %s -> %s\n", conversion.ID, previous, conversion.Status)
+	h.webhookService.EmitPostback(postbackPayload(req), conversion.ID, userOfferID)
+	c.JSON(http.StatusOK, gin.H{
+		"success":         true,
+		"message":         "Conversion status updated",
+		"previous_status": previous,
+		"conversion":      conversion,
 	})
+}
 
+// queueStatusUpdate queues a status update for a conversion that was accepted
+// through the WAL but hasn't been stored yet; it is applied once it lands
+func (h *PostbackHandler) queueStatusUpdate(c *gin.Context, externalID string, userOfferID uuid.UUID, req *PostbackRequest) {
+	err := h.lifecycleService.QueueStatusUpdate(&services.ConversionStatusUpdate{
+		ExternalID:  externalID,
+		UserOfferID: userOfferID,
+		Status:      req.Status,
+		Source:      models.ConversionSourcePostback,
+		Postback:    postbackPayload(req),
+	}, middleware.GetTenantID(c).String())
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to approve conversion"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue status update: " + err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"success":    true,
-		"message":    "Conversion approved successfully",
-		"conversion": conversion,
+	c.JSON(http.StatusAccepted, gin.H{
+		"success": true,
+		"message": "Conversion status update queued",
+		"queued":  true,
 	})
 }
 
+// ApproveConversion approves a pending conversion
+func (h *PostbackHandler) ApproveConversion(c *gin.Context) {
+	h.transitionConversion(c, models.ConversionStatusApproved, "", "approved")
+}
+
 // RejectConversion rejects a pending conversion
 func (h *PostbackHandler) RejectConversion(c *gin.Context) {
-	conversionID := c.Param("id")
-
 	type RejectRequest struct {
 		Reason string `json:"reason"`
 	}
@@ -442,28 +602,81 @@ func (h *PostbackHandler) RejectConversion(c *gin.Context) {
 	var req RejectRequest
 	c.ShouldBindJSON(&req)
 
-	var conversion models.Conversion
-	if err := h.db.First(&conversion, "id = ?", conversionID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Conversion not found"})
+	h.transitionConversion(c, models.ConversionStatusRejected, req.Reason, "rejected")
+}
+
+// ReverseConversion reverses an approved conversion (refund, chargeback) and
+// claws back the commission
+func (h *PostbackHandler) ReverseConversion(c *gin.Context) {
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	c.ShouldBindJSON(&req)
+
+	h.transitionConversion(c, models.ConversionStatusReversed, req.Reason, "reversed")
+}
+
+// MarkConversionPaid marks an approved conversion as paid out
+func (h *PostbackHandler) MarkConversionPaid(c *gin.Context) {
+	h.transitionConversion(c, models.ConversionStatusPaid, "", "marked as paid")
+}
+
+// GetConversionHistory returns a conversion's status history
+func (h *PostbackHandler) GetConversionHistory(c *gin.Context) {
+	conversionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid conversion ID"})
 		return
 	}
 
-	if !conversion.CanReject() {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Conversion cannot be rejected (current status: " + conversion.Status + ")"})
+	history, err := h.lifecycleService.GetHistory(conversionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch conversion history"})
 		return
 	}
 
-	conversion.Status = models.ConversionStatusRejected
-	conversion.RejectionReason = req.Reason
+	c.JSON(http.StatusOK, gin.H{
+		"history": history,
+		"count":   len(history),
+	})
+}
 
-	if err := h.db.Save(&conversion).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reject conversion"})
+// transitionConversion applies an admin status change
+func (h *PostbackHandler) transitionConversion(c *gin.Context, status, reason, verb string) {
+	conversionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Conversion not found"})
+		return
+	}
+
+	var actorID *uuid.UUID
+	if userID, ok := c.Get("userID"); ok {
+		if id, err := uuid.Parse(fmt.Sprint(userID)); err == nil {
+			actorID = &id
+		}
+	}
+
+	conversion, previous, err := h.lifecycleService.Transition(conversionID, services.ConversionTransition{
+		ToStatus: status,
+		Source:   models.ConversionSourceAdmin,
+		Reason:   reason,
+		ActorID:  actorID,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrConversionNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Conversion not found"})
+		case errors.Is(err, services.ErrInvalidConversionTransition):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Conversion cannot be " + verb + " (current status: " + previous + ")"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update conversion"})
+		}
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"success":    true,
-		"message":    "Conversion rejected successfully",
+		"message":    "Conversion " + verb + " successfully",
 		"conversion": conversion,
 	})
 }
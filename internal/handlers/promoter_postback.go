@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/aljapah/afftok-backend-prod/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ============================================
+// PROMOTER POSTBACK HANDLER
+// ============================================
+
+// PromoterPostbackHandler lets promoters post their conversions back to
+// their own ad trackers and pixels, and view the delivery log
+type PromoterPostbackHandler struct {
+	postbackService *services.PromoterPostbackService
+}
+
+// NewPromoterPostbackHandler creates a new promoter postback handler
+func NewPromoterPostbackHandler(db *gorm.DB) *PromoterPostbackHandler {
+	return &PromoterPostbackHandler{
+		postbackService: services.GetPromoterPostbackService(db),
+	}
+}
+
+// promoterPostbackRequest is the body of create and update requests
+type promoterPostbackRequest struct {
+	OfferID  string   `json:"offer_id"` // Empty for a global postback
+	Name     string   `json:"name"`
+	URL      string   `json:"url" binding:"required"`
+	Method   string   `json:"method"`
+	Events   []string `json:"events"`
+	Statuses []string `json:"statuses"`
+	IsActive *bool    `json:"is_active"`
+}
+
+// bindPromoterPostback binds a create or update request
+func bindPromoterPostback(c *gin.Context) (services.PromoterPostbackInput, bool) {
+	var req promoterPostbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return services.PromoterPostbackInput{}, false
+	}
+
+	input := services.PromoterPostbackInput{
+		Name:     req.Name,
+		URL:      req.URL,
+		Method:   req.Method,
+		Events:   req.Events,
+		Statuses: req.Statuses,
+		IsActive: req.IsActive,
+	}
+	if req.OfferID != "" {
+		offerID, err := uuid.Parse(req.OfferID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid offer_id"})
+			return services.PromoterPostbackInput{}, false
+		}
+		input.OfferID = &offerID
+	}
+	return input, true
+}
+
+// respondPromoterPostbackError maps a promoter postback service error to a response
+func respondPromoterPostbackError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, services.ErrPromoterPostbackNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidPromoterPostback),
+		errors.Is(err, services.ErrPromoterPostbackLimit),
+		errors.Is(err, services.ErrPromoterPostbackOfferNotJoined):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
+
+// GetMyPostbacks lists the promoter's postbacks
+// GET /api/postbacks/mine
+func (h *PromoterPostbackHandler) GetMyPostbacks(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	postbacks, err := h.postbackService.List(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch postbacks"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"postbacks": postbacks,
+		"count":     len(postbacks),
+	})
+}
+
+// CreatePostback adds a global or per-offer postback URL. The URL may use
+// {click_id}, {conversion_id}, {external_id}, {offer_id}, {user_offer_id},
+// {sub1}-{sub5}, {payout}, {amount}, {currency}, {status}, {event} and
+// {timestamp}.
+// POST /api/postbacks/mine
+func (h *PromoterPostbackHandler) CreatePostback(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	input, ok := bindPromoterPostback(c)
+	if !ok {
+		return
+	}
+
+	postback, err := h.postbackService.Create(userID, input)
+	if err != nil {
+		respondPromoterPostbackError(c, err, "Failed to create postback")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"postback": postback})
+}
+
+// UpdatePostback replaces a postback's settings
+// PUT /api/postbacks/mine/:id
+func (h *PromoterPostbackHandler) UpdatePostback(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	postbackID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid postback ID"})
+		return
+	}
+
+	input, ok := bindPromoterPostback(c)
+	if !ok {
+		return
+	}
+
+	postback, err := h.postbackService.Update(userID, postbackID, input)
+	if err != nil {
+		respondPromoterPostbackError(c, err, "Failed to update postback")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"postback": postback})
+}
+
+// DeletePostback removes a postback
+// DELETE /api/postbacks/mine/:id
+func (h *PromoterPostbackHandler) DeletePostback(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	postbackID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid postback ID"})
+		return
+	}
+
+	if err := h.postbackService.Delete(userID, postbackID); err != nil {
+		respondPromoterPostbackError(c, err, "Failed to delete postback")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Postback deleted"})
+}
+
+// GetDeliveries returns the promoter's postback delivery log, newest first
+// GET /api/postbacks/mine/deliveries?postback_id=&status=&limit=50
+func (h *PromoterPostbackHandler) GetDeliveries(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var postbackID *uuid.UUID
+	if raw := c.Query("postback_id"); raw != "" {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid postback_id"})
+			return
+		}
+		postbackID = &id
+	}
+
+	status := c.Query("status")
+	switch status {
+	case "", models.PromoterPostbackDeliveryPending, models.PromoterPostbackDeliverySent,
+		models.PromoterPostbackDeliveryFailed, models.PromoterPostbackDeliveryDLQ:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status"})
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	deliveries, err := h.postbackService.ListDeliveries(userID, postbackID, status, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch deliveries"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"deliveries": deliveries,
+		"count":      len(deliveries),
+	})
+}
@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/aljapah/afftok-backend-prod/internal/middleware"
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/aljapah/afftok-backend-prod/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ============================================
+// SDK INGEST HANDLER
+// ============================================
+
+// SDKHandler receives clicks and conversions from the web and mobile SDKs.
+// Each endpoint takes a single signed event, or {"events": [...]} when an
+// SDK flushes its offline queue; every event in a batch is signed on its own.
+type SDKHandler struct {
+	ingestService *services.SDKIngestService
+}
+
+// NewSDKHandler creates a new SDK handler
+func NewSDKHandler(db *gorm.DB) *SDKHandler {
+	return &SDKHandler{
+		ingestService: services.NewSDKIngestService(db),
+	}
+}
+
+// sdkBatch is an offline-queue flush
+type sdkBatch struct {
+	Events []json.RawMessage `json:"events"`
+}
+
+// sdkEventResult is the per-event outcome of a batch
+type sdkEventResult struct {
+	Index     int    `json:"index"`
+	Success   bool   `json:"success"`
+	ID        string `json:"id,omitempty"`
+	Duplicate bool   `json:"duplicate,omitempty"`
+	Code      string `json:"code,omitempty"`
+	Error     string `json:"error,omitempty"`
+	Retryable bool   `json:"retryable,omitempty"`
+}
+
+// TrackClick records SDK clicks
+// POST /api/sdk/click
+func (h *SDKHandler) TrackClick(c *gin.Context) {
+	h.ingest(c, "click_id", func(key *models.AdvertiserAPIKey, rawKey, tenantID string, raw json.RawMessage) (*services.SDKResult, *services.SDKError) {
+		var req services.SDKClickRequest
+		if err := json.Unmarshal(raw, &req); err != nil {
+			return nil, &services.SDKError{Status: http.StatusBadRequest, Code: services.SDKErrInvalidPayload, Message: "Invalid click payload"}
+		}
+		return h.ingestService.IngestClick(c, key, rawKey, tenantID, &req)
+	})
+}
+
+// TrackConversion records SDK conversions
+// POST /api/sdk/conversion, POST /api/convert
+func (h *SDKHandler) TrackConversion(c *gin.Context) {
+	h.ingest(c, "conversion_id", func(key *models.AdvertiserAPIKey, rawKey, tenantID string, raw json.RawMessage) (*services.SDKResult, *services.SDKError) {
+		var req services.SDKConversionRequest
+		if err := json.Unmarshal(raw, &req); err != nil {
+			return nil, &services.SDKError{Status: http.StatusBadRequest, Code: services.SDKErrInvalidPayload, Message: "Invalid conversion payload"}
+		}
+		return h.ingestService.IngestConversion(key, rawKey, tenantID, &req)
+	})
+}
+
+// ingest decodes a single event or a batch and runs each through record
+func (h *SDKHandler) ingest(c *gin.Context, idField string, record func(key *models.AdvertiserAPIKey, rawKey, tenantID string, raw json.RawMessage) (*services.SDKResult, *services.SDKError)) {
+	correlationID := uuid.New().String()[:8]
+
+	key, ok := middleware.GetAPIKey(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          "API key required",
+			"code":           "API_KEY_REQUIRED",
+		})
+		return
+	}
+	rawKey := middleware.RequestAPIKey(c)
+	tenantID := middleware.GetTenantID(c).String()
+
+	body, err := c.GetRawData()
+	var batch sdkBatch
+	if err == nil {
+		err = json.Unmarshal(body, &batch)
+	}
+	if err != nil {
+		h.respondError(c, correlationID, &services.SDKError{Status: http.StatusBadRequest, Code: services.SDKErrInvalidPayload, Message: "Invalid JSON body"})
+		return
+	}
+
+	// Single event
+	if batch.Events == nil {
+		result, sdkErr := record(key, rawKey, tenantID, body)
+		if sdkErr != nil {
+			h.respondError(c, correlationID, sdkErr)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"success":        true,
+			"correlation_id": correlationID,
+			"data": gin.H{
+				idField:     result.ID,
+				"duplicate": result.Duplicate,
+				"queued":    result.Queued,
+			},
+			"timestamp": time.Now().UTC(),
+		})
+		return
+	}
+
+	// Offline-queue flush
+	if len(batch.Events) > services.SDKMaxBatchSize {
+		h.respondError(c, correlationID, &services.SDKError{
+			Status:  http.StatusRequestEntityTooLarge,
+			Code:    services.SDKErrBatchTooLarge,
+			Message: "Batch exceeds the maximum number of events",
+		})
+		return
+	}
+	h.ingestService.RecordBatch()
+
+	results := make([]sdkEventResult, len(batch.Events))
+	accepted := 0
+	for i, raw := range batch.Events {
+		result, sdkErr := record(key, rawKey, tenantID, raw)
+		if sdkErr != nil {
+			results[i] = sdkEventResult{Index: i, Code: sdkErr.Code, Error: sdkErr.Message, Retryable: sdkErr.Retryable}
+			continue
+		}
+		accepted++
+		results[i] = sdkEventResult{Index: i, Success: true, ID: result.ID, Duplicate: result.Duplicate}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":        accepted == len(results),
+		"correlation_id": correlationID,
+		"data": gin.H{
+			"accepted": accepted,
+			"failed":   len(results) - accepted,
+			"results":  results,
+		},
+		"timestamp": time.Now().UTC(),
+	})
+}
+
+// respondError writes an SDK error with its stable code
+func (h *SDKHandler) respondError(c *gin.Context, correlationID string, sdkErr *services.SDKError) {
+	c.JSON(sdkErr.Status, gin.H{
+		"success":        false,
+		"correlation_id": correlationID,
+		"error":          sdkErr.Message,
+		"code":           sdkErr.Code,
+		"retryable":      sdkErr.Retryable,
+	})
+}
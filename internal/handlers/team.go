@@ -5,6 +5,7 @@ import (
 	"net/http"
 
 	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/aljapah/afftok-backend-prod/internal/services"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
@@ -139,7 +140,9 @@ func (h *TeamHandler) CreateTeam(c *gin.Context) {
 		Points: 0,
 	}
 
-	h.db.Create(&member)
+	if h.db.Create(&member).Error == nil {
+		services.GetBadgeEngine(h.db).RecordTeamJoin(team.ID)
+	}
 
 	c.JSON(http.StatusCreated, gin.H{
 		"message":     "Team created successfully",
@@ -183,7 +186,8 @@ func (h *TeamHandler) JoinTeam(c *gin.Context) {
 		return
 	}
 
-	h.db.Model(&team).UpdateColumn("member_count", h.db.Raw("member_count + 1"))
+	h.db.Model(&team).UpdateColumn("member_count", gorm.Expr("member_count + 1"))
+	services.GetBadgeEngine(h.db).RecordTeamJoin(team.ID)
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Joined team successfully",
@@ -213,7 +217,7 @@ func (h *TeamHandler) LeaveTeam(c *gin.Context) {
 
 	var team models.Team
 	if err := h.db.First(&team, "id = ?", teamID).Error; err == nil {
-		h.db.Model(&team).UpdateColumn("member_count", h.db.Raw("member_count - 1"))
+		h.db.Model(&team).UpdateColumn("member_count", gorm.Expr("member_count - 1"))
 	}
 
 	c.JSON(http.StatusOK, gin.H{
@@ -345,6 +349,7 @@ func (h *TeamHandler) ApproveMember(c *gin.Context) {
 	member.Status = "active"
 	h.db.Save(&member)
 	h.db.Model(&team).UpdateColumn("member_count", gorm.Expr("member_count + 1"))
+	services.GetBadgeEngine(h.db).RecordTeamJoin(team.ID)
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Member approved successfully",
@@ -3,6 +3,7 @@ package middleware
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
@@ -27,7 +28,9 @@ var _ = models.APIKeyStatusActive
 // Context keys for API key auth
 const (
 	ContextAPIKeyID      = "api_key_id"
+	ContextAPIKey        = "api_key"
 	ContextAdvertiserID  = "advertiser_id"
+	ContextNetworkID     = "api_key_network_id"
 	ContextAPIKeyName    = "api_key_name"
 	ContextAPIKeyPerms   = "api_key_permissions"
 	ContextAuthMethod    = "auth_method"
@@ -143,14 +146,17 @@ func APIKeyAuthMiddleware() gin.HandlerFunc {
 			} else if strings.Contains(err.Error(), "expired") {
 				indicator = FraudIndicatorExpiredAPIKey
 				errorMsg = "API key has expired"
-			} else if strings.Contains(err.Error(), "IP not allowed") {
+			} else if errors.Is(err, services.ErrAPIKeyIPNotAllowed) {
 				indicator = FraudIndicatorAPIKeyIPViolation
 				errorMsg = "IP address not allowed for this API key"
 				atomic.AddInt64(&apiKeyMetrics.IPViolations, 1)
 			}
 
 			logAPIKeyFraud(ip, apiKey[:min(20, len(apiKey))], indicator, err.Error())
-			logAPIKeyUsage(uuid.Nil, uuid.Nil, ip, endpoint, method, c.GetHeader("User-Agent"), false, http.StatusUnauthorized, errorMsg, time.Since(startTime).Milliseconds())
+			// Allowlist violations are already logged against the key by the service
+			if indicator != FraudIndicatorAPIKeyIPViolation {
+				logAPIKeyUsage(uuid.Nil, uuid.Nil, ip, endpoint, method, c.GetHeader("User-Agent"), false, http.StatusUnauthorized, errorMsg, time.Since(startTime).Milliseconds())
+			}
 
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
 				"success": false,
@@ -179,19 +185,7 @@ func APIKeyAuthMiddleware() gin.HandlerFunc {
 		clearAPIKeyFailures(ip)
 
 		// Set context values
-		c.Set(ContextAPIKeyID, keyInfo.ID.String())
-		c.Set(ContextAdvertiserID, keyInfo.AdvertiserID.String())
-		c.Set(ContextAPIKeyName, keyInfo.Name)
-		c.Set(ContextAuthMethod, AuthMethodAPIKey)
-
-		// Parse and set permissions
-		var permissions []string
-		if keyInfo.Permissions != nil && len(keyInfo.Permissions) > 0 {
-			if err := json.Unmarshal(keyInfo.Permissions, &permissions); err != nil {
-				permissions = []string{"*"} // Default to all if parse fails
-			}
-		}
-		c.Set(ContextAPIKeyPerms, permissions)
+		setAPIKeyContext(c, keyInfo)
 
 		// Increment usage (async)
 		go service.IncrementUsage(keyInfo.ID, ip)
@@ -272,10 +266,7 @@ func APIKeyRequiredMiddleware() gin.HandlerFunc {
 		clearAPIKeyFailures(ip)
 
 		// Set context
-		c.Set(ContextAPIKeyID, keyInfo.ID.String())
-		c.Set(ContextAdvertiserID, keyInfo.AdvertiserID.String())
-		c.Set(ContextAPIKeyName, keyInfo.Name)
-		c.Set(ContextAuthMethod, AuthMethodAPIKey)
+		setAPIKeyContext(c, keyInfo)
 
 		go service.IncrementUsage(keyInfo.ID, ip)
 		go logAPIKeyUsage(keyInfo.ID, keyInfo.AdvertiserID, ip, c.Request.URL.Path, c.Request.Method, c.GetHeader("User-Agent"), true, http.StatusOK, "", time.Since(startTime).Milliseconds())
@@ -310,6 +301,44 @@ func APIKeyOrJWTMiddleware() gin.HandlerFunc {
 // HELPER FUNCTIONS
 // ============================================
 
+// setAPIKeyContext stores the authenticated key, its scope and its permissions
+// on the request context
+func setAPIKeyContext(c *gin.Context, keyInfo *models.AdvertiserAPIKey) {
+	c.Set(ContextAPIKey, keyInfo)
+	c.Set(ContextAPIKeyID, keyInfo.ID.String())
+	c.Set(ContextAdvertiserID, keyInfo.AdvertiserID.String())
+	c.Set(ContextAPIKeyName, keyInfo.Name)
+	c.Set(ContextAuthMethod, AuthMethodAPIKey)
+	if keyInfo.NetworkID != nil {
+		c.Set(ContextNetworkID, keyInfo.NetworkID.String())
+	}
+
+	// No stored permissions means no restrictions (see AdvertiserAPIKey.HasPermission)
+	permissions := []string{models.PermissionAllAccess}
+	if len(keyInfo.Permissions) > 0 {
+		var parsed []string
+		if err := json.Unmarshal(keyInfo.Permissions, &parsed); err == nil {
+			permissions = parsed
+		}
+	}
+	c.Set(ContextAPIKeyPerms, permissions)
+}
+
+// GetAPIKey returns the API key that authenticated the request, if any
+func GetAPIKey(c *gin.Context) (*models.AdvertiserAPIKey, bool) {
+	value, exists := c.Get(ContextAPIKey)
+	if !exists {
+		return nil, false
+	}
+	key, ok := value.(*models.AdvertiserAPIKey)
+	return key, ok && key != nil
+}
+
+// RequestAPIKey returns the plaintext API key presented with the request
+func RequestAPIKey(c *gin.Context) string {
+	return extractAPIKey(c)
+}
+
 // extractAPIKey extracts the API key from request headers
 func extractAPIKey(c *gin.Context) string {
 	// Try X-API-Key header first
@@ -383,6 +412,18 @@ func recordAPIKeyFailure(ip string) {
 	if count >= 10 {
 		blockKey := fmt.Sprintf("apikey:blocked:%s", ip)
 		cache.Set(ctx, blockKey, "1", apiKeyBlockDuration)
+
+		// Escalates to an IP block if the guessing continues
+		services.GetThreatDetector().RecordThreat(&services.ThreatEvent{
+			ID:          fmt.Sprintf("threat_%d", time.Now().UnixNano()),
+			Type:        services.ThreatBruteForce,
+			Severity:    services.SeverityHigh,
+			IP:          ip,
+			Description: fmt.Sprintf("Too many invalid API key attempts: %d", count),
+			Timestamp:   time.Now(),
+			Blocked:     true,
+			Action:      "rate_limited",
+		})
 	}
 }
 
@@ -13,6 +13,7 @@ import (
 // AuthMiddleware validates JWT token with enhanced security
 func AuthMiddleware() gin.HandlerFunc {
 	security := services.NewSecurityService()
+	tokenStore := services.GetTokenStore()
 	
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
@@ -68,6 +69,31 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		// Refresh tokens are only accepted by /auth/refresh
+		if utils.IsRefreshToken(claims) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token type"})
+			c.Abort()
+			return
+		}
+
+		// Reject tokens from logged-out sessions
+		if err := tokenStore.CheckAccessToken(claims); err != nil {
+			security.LogAuditEvent(services.AuditEvent{
+				Timestamp: time.Now(),
+				EventType: "auth_revoked_token",
+				UserID:    claims.UserID.String(),
+				IP:        c.ClientIP(),
+				UserAgent: c.Request.UserAgent(),
+				Resource:  c.Request.URL.Path,
+				Action:    "authenticate",
+				Success:   false,
+			})
+
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token has been revoked"})
+			c.Abort()
+			return
+		}
+
 		// Check if token is about to expire (warn client)
 		// This allows clients to refresh proactively
 		if claims.ExpiresAt != nil {
@@ -87,6 +113,35 @@ func AuthMiddleware() gin.HandlerFunc {
 	}
 }
 
+// OptionalAuthMiddleware identifies the caller on public routes: a valid
+// access token sets the same context as AuthMiddleware, anything else is
+// served as signed out
+func OptionalAuthMiddleware() gin.HandlerFunc {
+	tokenStore := services.GetTokenStore()
+
+	return func(c *gin.Context) {
+		authHeader := c.GetHeader("Authorization")
+		parts := strings.SplitN(authHeader, " ", 2)
+		if authHeader == "" || len(authHeader) > 2000 || len(parts) != 2 || parts[0] != "Bearer" {
+			c.Next()
+			return
+		}
+
+		claims, err := utils.ValidateToken(strings.TrimSpace(parts[1]))
+		if err != nil || utils.IsRefreshToken(claims) || tokenStore.CheckAccessToken(claims) != nil {
+			c.Next()
+			return
+		}
+
+		c.Set("userID", claims.UserID)
+		c.Set("username", claims.Username)
+		c.Set("email", claims.Email)
+		c.Set("role", claims.Role)
+
+		c.Next()
+	}
+}
+
 // AdminMiddleware checks if user is admin with audit logging
 func AdminMiddleware() gin.HandlerFunc {
 	security := services.NewSecurityService()
@@ -0,0 +1,113 @@
+package middleware
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/aljapah/afftok-backend-prod/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// ============================================
+// EDGE AUTH MIDDLEWARE
+// ============================================
+
+// ContextEdgeCredential holds the authenticated edge credential
+const ContextEdgeCredential = "edge_credential"
+
+// EdgeAuthModeMonitor lets unauthenticated edge requests through while still
+// verifying and counting them, for rolling credentials out to workers
+const EdgeAuthModeMonitor = "monitor"
+
+// EdgeAuthMode returns the configured edge auth mode (EDGE_AUTH_MODE):
+// "enforce" (default) or "monitor"
+func EdgeAuthMode() string {
+	if strings.EqualFold(os.Getenv("EDGE_AUTH_MODE"), EdgeAuthModeMonitor) {
+		return EdgeAuthModeMonitor
+	}
+	return "enforce"
+}
+
+// EdgeAuthMiddleware verifies the signed credentials edge workers send with
+// every call to the internal edge endpoints, then applies the edge location's
+// request quota (and, for ingestion, its event quota)
+func EdgeAuthMiddleware(authService *services.EdgeAuthService) gin.HandlerFunc {
+	monitor := EdgeAuthMode() == EdgeAuthModeMonitor
+
+	return func(c *gin.Context) {
+		body, err := io.ReadAll(io.LimitReader(c.Request.Body, services.EdgeMaxBodyBytes+1))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   "Failed to read request body",
+				"code":    "INVALID_BODY",
+			})
+			return
+		}
+		if len(body) > services.EdgeMaxBodyBytes {
+			authService.RecordRejection("", services.EdgeRejectBodyTooLarge)
+			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{
+				"success": false,
+				"error":   "Edge batch too large",
+				"code":    strings.ToUpper(services.EdgeRejectBodyTooLarge),
+			})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		credential, authErr := authService.Verify(c.Request.Method, c.Request.URL.Path, c.Request.Header, body)
+		if authErr == nil {
+			authErr = authService.ConsumeRequestQuota(credential)
+			if authErr == nil && c.Request.Method == http.MethodPost {
+				authErr = authService.CheckEventQuota(credential)
+			}
+		}
+
+		if authErr != nil {
+			// Only a known key names the location; the caller's own
+			// X-Edge-Location header would let anyone mint metric keys
+			location := ""
+			if credential != nil {
+				location = credential.EdgeLocation
+			}
+			authService.RecordRejection(location, authErr.Reason)
+
+			// Monitor mode only waives authentication; quotas still apply
+			if monitor && authErr.Reason != services.EdgeRejectQuotaExceeded {
+				authService.RecordUnauthenticated()
+				fmt.Printf("[EdgeAuth] Monitor mode, allowing %s %s from %s: %s\n",
+					c.Request.Method, c.Request.URL.Path, c.ClientIP(), authErr.Reason)
+				c.Next()
+				return
+			}
+
+			if authErr.Status == http.StatusTooManyRequests {
+				c.Header("Retry-After", "60")
+			}
+			c.AbortWithStatusJSON(authErr.Status, gin.H{
+				"success": false,
+				"error":   authErr.Message,
+				"code":    strings.ToUpper(authErr.Reason),
+			})
+			return
+		}
+
+		c.Set(ContextEdgeCredential, credential)
+		c.Next()
+	}
+}
+
+// GetEdgeCredential returns the authenticated edge credential, if any
+func GetEdgeCredential(c *gin.Context) (*models.EdgeCredential, bool) {
+	value, exists := c.Get(ContextEdgeCredential)
+	if !exists {
+		return nil, false
+	}
+	credential, ok := value.(*models.EdgeCredential)
+	return credential, ok && credential != nil
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
@@ -77,25 +78,33 @@ func (k *AdvertiserAPIKey) IsActive() bool {
 	return true
 }
 
-// IsIPAllowed checks if the given IP is allowed
+// GetAllowedIPs returns the raw allowlist entries
+func (k *AdvertiserAPIKey) GetAllowedIPs() ([]string, error) {
+	var allowedIPs []string
+	if len(k.AllowedIPs) == 0 {
+		return allowedIPs, nil
+	}
+	if err := json.Unmarshal(k.AllowedIPs, &allowedIPs); err != nil {
+		return nil, err
+	}
+	return allowedIPs, nil
+}
+
+// IsIPAllowed checks if the given IP is allowed (exact address or CIDR range)
 func (k *AdvertiserAPIKey) IsIPAllowed(ip string) bool {
-	if k.AllowedIPs == nil || len(k.AllowedIPs) == 0 {
-		return true // No restrictions
+	allowedIPs, err := k.GetAllowedIPs()
+	if err != nil {
+		return false // Unreadable allowlist: fail closed
 	}
-	
-	var allowedIPs []string
-	if err := k.AllowedIPs.UnmarshalJSON([]byte(k.AllowedIPs)); err != nil {
-		return true // Parse error, allow
+	if len(allowedIPs) == 0 {
+		return true // No restrictions
 	}
-	
-	for _, allowedIP := range allowedIPs {
-		if allowedIP == ip {
-			return true
-		}
-		// TODO: Add CIDR matching for ranges like "10.0.0.0/8"
+
+	allowlist, err := ParseIPAllowlist(allowedIPs)
+	if err != nil {
+		return false
 	}
-	
-	return false
+	return allowlist.Contains(ip)
 }
 
 // HasPermission checks if the key has a specific permission
@@ -105,7 +114,7 @@ func (k *AdvertiserAPIKey) HasPermission(permission string) bool {
 	}
 	
 	var permissions []string
-	if err := k.Permissions.UnmarshalJSON([]byte(k.Permissions)); err != nil {
+	if err := json.Unmarshal(k.Permissions, &permissions); err != nil {
 		return true // Parse error, allow
 	}
 	
@@ -118,6 +127,21 @@ func (k *AdvertiserAPIKey) HasPermission(permission string) bool {
 	return false
 }
 
+// CanAccessOffer checks the offer belongs to the key's advertiser or, for
+// network-scoped keys, to the key's network
+func (k *AdvertiserAPIKey) CanAccessOffer(offer *Offer) bool {
+	if offer == nil {
+		return false
+	}
+	if offer.AdvertiserID != nil && *offer.AdvertiserID == k.AdvertiserID {
+		return true
+	}
+	if k.NetworkID != nil && offer.NetworkID != nil && *offer.NetworkID == *k.NetworkID {
+		return true
+	}
+	return false
+}
+
 // ============================================
 // API KEY USAGE LOG
 // ============================================
@@ -0,0 +1,93 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+)
+
+func TestAPIKeyCanAccessOffer(t *testing.T) {
+	advertiserID, otherAdvertiserID := uuid.New(), uuid.New()
+	networkID, otherNetworkID := uuid.New(), uuid.New()
+
+	tests := []struct {
+		name  string
+		key   AdvertiserAPIKey
+		offer *Offer
+		want  bool
+	}{
+		{
+			name:  "own offer",
+			key:   AdvertiserAPIKey{AdvertiserID: advertiserID},
+			offer: &Offer{AdvertiserID: &advertiserID},
+			want:  true,
+		},
+		{
+			name:  "another advertiser's offer",
+			key:   AdvertiserAPIKey{AdvertiserID: advertiserID},
+			offer: &Offer{AdvertiserID: &otherAdvertiserID},
+		},
+		{
+			name:  "offer without advertiser",
+			key:   AdvertiserAPIKey{AdvertiserID: advertiserID},
+			offer: &Offer{},
+		},
+		{
+			name:  "network key on a network offer",
+			key:   AdvertiserAPIKey{AdvertiserID: advertiserID, NetworkID: &networkID},
+			offer: &Offer{AdvertiserID: &otherAdvertiserID, NetworkID: &networkID},
+			want:  true,
+		},
+		{
+			name:  "network key on another network's offer",
+			key:   AdvertiserAPIKey{AdvertiserID: advertiserID, NetworkID: &networkID},
+			offer: &Offer{AdvertiserID: &otherAdvertiserID, NetworkID: &otherNetworkID},
+		},
+		{
+			name:  "advertiser key on a network offer",
+			key:   AdvertiserAPIKey{AdvertiserID: advertiserID},
+			offer: &Offer{NetworkID: &networkID},
+		},
+		{
+			name: "nil offer",
+			key:  AdvertiserAPIKey{AdvertiserID: advertiserID},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.key.CanAccessOffer(tt.offer); got != tt.want {
+				t.Errorf("CanAccessOffer = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAPIKeyHasPermission(t *testing.T) {
+	tests := []struct {
+		name        string
+		permissions string
+		permission  string
+		want        bool
+	}{
+		{"no permissions means all", ``, "postback:write", true},
+		{"empty list grants nothing", `[]`, "postback:write", false},
+		{"granted", `["stats:read", "postback:write"]`, "postback:write", true},
+		{"not granted", `["stats:read"]`, "postback:write", false},
+		{"wildcard", `["*"]`, "postback:write", true},
+		{"no prefix matching", `["postback"]`, "postback:write", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := AdvertiserAPIKey{}
+			if tt.permissions != "" {
+				key.Permissions = datatypes.JSON(tt.permissions)
+			}
+			if got := key.HasPermission(tt.permission); got != tt.want {
+				t.Errorf("HasPermission(%q) = %v, want %v", tt.permission, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+)
+
+// ============================================
+// BADGE CRITERIA
+// ============================================
+
+// Badge criterion types
+const (
+	BadgeCriterionThreshold     = "threshold"      // Lifetime metric >= value
+	BadgeCriterionStreak        = "streak"         // Metric on each of the last `days` days, today included
+	BadgeCriterionGoal          = "goal"           // Metric >= value within a window
+	BadgeCriterionNewCategory   = "new_category"   // First conversion in an offer category
+	BadgeCriterionTeamMilestone = "team_milestone" // The promoter's team reached a metric
+	BadgeCriterionContestWins   = "contest_wins"   // Contests won, individually or with a team
+)
+
+// Badge criterion metrics
+const (
+	BadgeMetricClicks      = "clicks"
+	BadgeMetricConversions = "conversions"
+	BadgeMetricEarnings    = "earnings" // In cents
+	BadgeMetricPoints      = "points"
+	BadgeMetricMembers     = "members" // Team milestones only
+)
+
+// BadgeCriteria is a badge's award rule, stored as JSON in Badge.Criteria.
+// A node either combines other nodes (All, Any) or is a single criterion:
+//
+//	{"all": [{"type": "streak", "metric": "conversions", "days": 7},
+//	         {"type": "goal", "metric": "earnings", "value": 50000, "within_days": 30}]}
+//
+// The legacy plain criteria ("conversions", "clicks", "earnings", "points")
+// are read as a threshold on Badge.RequiredValue.
+type BadgeCriteria struct {
+	All []BadgeCriteria `json:"all,omitempty"`
+	Any []BadgeCriteria `json:"any,omitempty"`
+
+	Type   string `json:"type,omitempty"`
+	Metric string `json:"metric,omitempty"`
+	Value  int    `json:"value,omitempty"`
+
+	// Streaks
+	Days      int `json:"days,omitempty"`
+	MinPerDay int `json:"min_per_day,omitempty"` // Default 1
+
+	// Goals: a rolling window, or fixed dates
+	WithinDays int        `json:"within_days,omitempty"`
+	From       *time.Time `json:"from,omitempty"`
+	Until      *time.Time `json:"until,omitempty"`
+
+	// New category: a specific category, or `value` distinct categories (default 1)
+	Category string `json:"category,omitempty"`
+}
+
+// ParseBadgeCriteria reads and validates a badge's criteria
+func ParseBadgeCriteria(criteria string, requiredValue int) (*BadgeCriteria, error) {
+	criteria = strings.TrimSpace(criteria)
+	switch criteria {
+	case BadgeMetricClicks, BadgeMetricConversions, BadgeMetricEarnings, BadgeMetricPoints:
+		legacy := &BadgeCriteria{Type: BadgeCriterionThreshold, Metric: criteria, Value: requiredValue}
+		if err := legacy.Validate(); err != nil {
+			return nil, fmt.Errorf("required_value must be positive for %s badges", criteria)
+		}
+		return legacy, nil
+	case "":
+		return nil, fmt.Errorf("criteria is required")
+	}
+
+	var parsed BadgeCriteria
+	if err := json.Unmarshal([]byte(criteria), &parsed); err != nil {
+		return nil, fmt.Errorf("criteria must be JSON or one of clicks, conversions, earnings, points: %w", err)
+	}
+	if err := parsed.Validate(); err != nil {
+		return nil, err
+	}
+	return &parsed, nil
+}
+
+// Validate checks a criteria tree
+func (c *BadgeCriteria) Validate() error {
+	if len(c.All) > 0 || len(c.Any) > 0 {
+		if c.Type != "" || (len(c.All) > 0 && len(c.Any) > 0) {
+			return fmt.Errorf("a criteria node is either all, any or a single type")
+		}
+		for _, children := range [][]BadgeCriteria{c.All, c.Any} {
+			for i := range children {
+				if err := children[i].Validate(); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	switch c.Type {
+	case BadgeCriterionThreshold:
+		if !isBadgeMetric(c.Metric, BadgeMetricClicks, BadgeMetricConversions, BadgeMetricEarnings, BadgeMetricPoints) {
+			return fmt.Errorf("threshold metric must be clicks, conversions, earnings or points")
+		}
+		if c.Value <= 0 {
+			return fmt.Errorf("threshold value must be positive")
+		}
+	case BadgeCriterionStreak:
+		if !isBadgeMetric(c.Metric, BadgeMetricClicks, BadgeMetricConversions) {
+			return fmt.Errorf("streak metric must be clicks or conversions")
+		}
+		if c.Days <= 0 || c.Days > 365 {
+			return fmt.Errorf("streak days must be between 1 and 365")
+		}
+	case BadgeCriterionGoal:
+		if !isBadgeMetric(c.Metric, BadgeMetricClicks, BadgeMetricConversions, BadgeMetricEarnings) {
+			return fmt.Errorf("goal metric must be clicks, conversions or earnings")
+		}
+		if c.Value <= 0 {
+			return fmt.Errorf("goal value must be positive")
+		}
+		if (c.WithinDays > 0) == (c.From != nil || c.Until != nil) {
+			return fmt.Errorf("a goal needs either within_days or from/until")
+		}
+		if c.From != nil && c.Until != nil && !c.Until.After(*c.From) {
+			return fmt.Errorf("goal until must be after from")
+		}
+	case BadgeCriterionNewCategory:
+		if c.Value < 0 {
+			return fmt.Errorf("new_category value can't be negative")
+		}
+	case BadgeCriterionTeamMilestone:
+		if !isBadgeMetric(c.Metric, BadgeMetricMembers, BadgeMetricClicks, BadgeMetricConversions) {
+			return fmt.Errorf("team_milestone metric must be members, clicks or conversions")
+		}
+		if c.Value <= 0 {
+			return fmt.Errorf("team_milestone value must be positive")
+		}
+	case BadgeCriterionContestWins:
+		if c.Value <= 0 {
+			return fmt.Errorf("contest_wins value must be positive")
+		}
+	default:
+		return fmt.Errorf("unknown criteria type %q", c.Type)
+	}
+	return nil
+}
+
+// isBadgeMetric checks a metric against the ones a criterion supports
+func isBadgeMetric(metric string, allowed ...string) bool {
+	for _, value := range allowed {
+		if metric == value {
+			return true
+		}
+	}
+	return false
+}
+
+// ============================================
+// BADGE AWARDS
+// ============================================
+
+// BadgeAward is the audit trail of a badge being awarded: what triggered it,
+// the points it granted and the values that met its criteria
+type BadgeAward struct {
+	ID            uuid.UUID      `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	UserID        uuid.UUID      `gorm:"type:uuid;not null;index:idx_badge_awards_user" json:"user_id"`
+	BadgeID       uuid.UUID      `gorm:"type:uuid;not null;index:idx_badge_awards_badge" json:"badge_id"`
+	UserBadgeID   uuid.UUID      `gorm:"type:uuid;not null" json:"user_badge_id"`
+	Trigger       string         `gorm:"type:varchar(30);not null" json:"trigger"` // Event that led to the award, or "manual"
+	PointsAwarded int            `gorm:"default:0" json:"points_awarded"`
+	Evidence      datatypes.JSON `gorm:"type:jsonb" json:"evidence,omitempty"`
+	CreatedAt     time.Time      `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+}
+
+// TableName returns the table name for GORM
+func (BadgeAward) TableName() string {
+	return "badge_awards"
+}
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"testing"
+)
+
+func TestParseBadgeCriteria(t *testing.T) {
+	tests := []struct {
+		name          string
+		criteria      string
+		requiredValue int
+		want          string // Type of the parsed root, "" for a group
+		wantErr       bool
+	}{
+		{name: "legacy conversions", criteria: "conversions", requiredValue: 10, want: BadgeCriterionThreshold},
+		{name: "legacy padded", criteria: " clicks ", requiredValue: 100, want: BadgeCriterionThreshold},
+		{name: "legacy without a value", criteria: "earnings", wantErr: true},
+		{name: "empty", criteria: "  ", wantErr: true},
+		{name: "unknown legacy word", criteria: "sales", wantErr: true},
+		{name: "invalid JSON", criteria: `{"type":`, wantErr: true},
+
+		{name: "threshold", criteria: `{"type": "threshold", "metric": "points", "value": 500}`, want: BadgeCriterionThreshold},
+		{name: "streak", criteria: `{"type": "streak", "metric": "conversions", "days": 7, "min_per_day": 2}`, want: BadgeCriterionStreak},
+		{name: "rolling goal", criteria: `{"type": "goal", "metric": "earnings", "value": 50000, "within_days": 30}`, want: BadgeCriterionGoal},
+		{name: "fixed goal", criteria: `{"type": "goal", "metric": "clicks", "value": 1000, "from": "2026-10-01T00:00:00Z", "until": "2026-11-01T00:00:00Z"}`, want: BadgeCriterionGoal},
+		{name: "open ended goal", criteria: `{"type": "goal", "metric": "clicks", "value": 1000, "from": "2026-10-01T00:00:00Z"}`, want: BadgeCriterionGoal},
+		{name: "new category", criteria: `{"type": "new_category"}`, want: BadgeCriterionNewCategory},
+		{name: "team milestone", criteria: `{"type": "team_milestone", "metric": "members", "value": 10}`, want: BadgeCriterionTeamMilestone},
+		{name: "contest wins", criteria: `{"type": "contest_wins", "value": 3}`, want: BadgeCriterionContestWins},
+		{name: "all", criteria: `{"all": [{"type": "streak", "metric": "clicks", "days": 3}, {"type": "contest_wins", "value": 1}]}`},
+		{name: "nested any", criteria: `{"any": [{"all": [{"type": "new_category"}]}, {"type": "threshold", "metric": "clicks", "value": 1}]}`},
+
+		{name: "unknown type", criteria: `{"type": "lottery"}`, wantErr: true},
+		{name: "no type", criteria: `{}`, wantErr: true},
+		{name: "threshold on members", criteria: `{"type": "threshold", "metric": "members", "value": 5}`, wantErr: true},
+		{name: "threshold without value", criteria: `{"type": "threshold", "metric": "clicks"}`, wantErr: true},
+		{name: "streak on earnings", criteria: `{"type": "streak", "metric": "earnings", "days": 7}`, wantErr: true},
+		{name: "streak too long", criteria: `{"type": "streak", "metric": "clicks", "days": 366}`, wantErr: true},
+		{name: "streak without days", criteria: `{"type": "streak", "metric": "clicks"}`, wantErr: true},
+		{name: "goal without window", criteria: `{"type": "goal", "metric": "clicks", "value": 10}`, wantErr: true},
+		{name: "goal with both windows", criteria: `{"type": "goal", "metric": "clicks", "value": 10, "within_days": 7, "from": "2026-10-01T00:00:00Z"}`, wantErr: true},
+		{name: "goal ending before it starts", criteria: `{"type": "goal", "metric": "clicks", "value": 10, "from": "2026-11-01T00:00:00Z", "until": "2026-10-01T00:00:00Z"}`, wantErr: true},
+		{name: "goal on points", criteria: `{"type": "goal", "metric": "points", "value": 10, "within_days": 7}`, wantErr: true},
+		{name: "negative categories", criteria: `{"type": "new_category", "value": -1}`, wantErr: true},
+		{name: "team earnings", criteria: `{"type": "team_milestone", "metric": "earnings", "value": 10}`, wantErr: true},
+		{name: "no contest wins", criteria: `{"type": "contest_wins"}`, wantErr: true},
+		{name: "all and any", criteria: `{"all": [{"type": "new_category"}], "any": [{"type": "new_category"}]}`, wantErr: true},
+		{name: "group with a type", criteria: `{"type": "threshold", "all": [{"type": "new_category"}]}`, wantErr: true},
+		{name: "invalid child", criteria: `{"any": [{"type": "new_category"}, {"all": [{"type": "streak", "metric": "clicks"}]}]}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed, err := ParseBadgeCriteria(tt.criteria, tt.requiredValue)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", parsed)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if parsed.Type != tt.want {
+				t.Errorf("type = %q, want %q", parsed.Type, tt.want)
+			}
+		})
+	}
+
+	// Legacy criteria are a threshold on the required value
+	legacy, err := ParseBadgeCriteria("conversions", 25)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if legacy.Metric != BadgeMetricConversions || legacy.Value != 25 {
+		t.Errorf("legacy criteria = %+v, want conversions >= 25", legacy)
+	}
+}
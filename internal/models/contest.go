@@ -111,6 +111,14 @@ const (
 	ContestStatusCancelled = "cancelled"
 )
 
+// Participant Status Constants
+const (
+	ParticipantStatusActive       = "active"
+	ParticipantStatusWinner       = "winner"
+	ParticipantStatusCompleted    = "completed"
+	ParticipantStatusDisqualified = "disqualified"
+)
+
 // Contest Type Constants
 const (
 	ContestTypeTeam       = "team"
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ConversionStatusHistory records one status transition of a conversion
+type ConversionStatusHistory struct {
+	ID              uuid.UUID  `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	ConversionID    uuid.UUID  `gorm:"type:uuid;not null;index:idx_conv_history_conversion" json:"conversion_id"`
+	FromStatus      string     `gorm:"type:varchar(20)" json:"from_status"` // Empty for the initial status
+	ToStatus        string     `gorm:"type:varchar(20);not null" json:"to_status"`
+	Source          string     `gorm:"type:varchar(30);not null" json:"source"` // postback, admin, shopify, salla, zid, ...
+	Reason          string     `gorm:"type:text" json:"reason,omitempty"`
+	ActorID         *uuid.UUID `gorm:"type:uuid" json:"actor_id,omitempty"`
+	CommissionDelta int        `gorm:"default:0" json:"commission_delta"` // Earnings credited (+) or clawed back (-)
+	CreatedAt       time.Time  `gorm:"default:CURRENT_TIMESTAMP;index:idx_conv_history_time" json:"created_at"`
+}
+
+func (ConversionStatusHistory) TableName() string {
+	return "conversion_status_history"
+}
+
+// Conversion status change sources
+const (
+	ConversionSourcePostback = "postback"
+	ConversionSourceAdmin    = "admin"
+	ConversionSourcePixel    = "pixel"
+	ConversionSourceSDK      = "sdk"
+)
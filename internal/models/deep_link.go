@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DeepLink is a promoter-chosen landing page within an offer's allowed
+// domains, e.g. a single product page of a store. Clicks through it record
+// its ID so deep link usage can be reported per target URL.
+type DeepLink struct {
+	ID          uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	UserOfferID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_deep_links_user_offer_target" json:"user_offer_id"`
+	OfferID     uuid.UUID `gorm:"type:uuid;not null;index:idx_deep_links_offer" json:"offer_id"`
+	UserID      uuid.UUID `gorm:"type:uuid;not null;index:idx_deep_links_user" json:"user_id"`
+
+	TargetURL  string `gorm:"type:text;not null" json:"target_url"`
+	TargetHash string `gorm:"type:varchar(64);not null;uniqueIndex:idx_deep_links_user_offer_target" json:"-"` // SHA-256 of TargetURL
+
+	CreatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+
+	// Relationships
+	UserOffer *UserOffer `gorm:"foreignKey:UserOfferID" json:"user_offer,omitempty"`
+}
+
+// TableName returns the table name for GORM
+func (DeepLink) TableName() string {
+	return "deep_links"
+}
@@ -0,0 +1,62 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ============================================
+// EDGE CREDENTIAL MODEL
+// ============================================
+
+// EdgeCredentialStatus represents the status of an edge credential
+type EdgeCredentialStatus string
+
+const (
+	EdgeCredentialStatusActive  EdgeCredentialStatus = "active"
+	EdgeCredentialStatusRevoked EdgeCredentialStatus = "revoked"
+)
+
+// EdgeCredential is a signing key for one edge location (a worker
+// deployment). Edge workers sign every call to the internal edge endpoints
+// with it. On rotation the old key gets an ExpiresAt so both keys are
+// accepted while workers pick up the new one.
+type EdgeCredential struct {
+	ID           uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Name         string    `gorm:"size:100" json:"name"`
+	EdgeLocation string    `gorm:"size:50;not null;index:idx_edge_credentials_location" json:"edge_location"`
+
+	// Key material
+	KeyID           string `gorm:"size:40;not null;uniqueIndex:idx_edge_credentials_key_id" json:"key_id"`
+	SecretEncrypted string `gorm:"type:text;not null" json:"-"`
+	SecretHint      string `gorm:"size:10" json:"secret_hint"` // Last 4 chars for identification
+
+	// Validity
+	Status       EdgeCredentialStatus `gorm:"size:20;default:'active';index:idx_edge_credentials_status" json:"status"`
+	ValidFrom    time.Time            `json:"valid_from"`
+	ExpiresAt    *time.Time           `json:"expires_at,omitempty"` // Set when rotated out
+	ReplacedByID *uuid.UUID           `gorm:"type:uuid" json:"replaced_by_id,omitempty"`
+
+	// Quotas for the edge location (0 = platform default)
+	RequestsPerMinute int `gorm:"default:0" json:"requests_per_minute"`
+	EventsPerMinute   int `gorm:"default:0" json:"events_per_minute"`
+
+	// Usage
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt  time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt  time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"updated_at"`
+}
+
+// TableName returns the table name for GORM
+func (EdgeCredential) TableName() string {
+	return "edge_credentials"
+}
+
+// IsValidAt reports whether the credential is accepted at the given time
+func (c *EdgeCredential) IsValidAt(now time.Time) bool {
+	if c.Status != EdgeCredentialStatusActive || now.Before(c.ValidFrom) {
+		return false
+	}
+	return c.ExpiresAt == nil || now.Before(*c.ExpiresAt)
+}
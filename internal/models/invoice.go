@@ -10,12 +10,12 @@ import (
 // Invoice represents a monthly invoice for an advertiser
 type Invoice struct {
 	ID              uuid.UUID  `gorm:"type:uuid;primary_key" json:"id"`
-	AdvertiserID    uuid.UUID  `gorm:"type:uuid;not null;index" json:"advertiser_id"`
+	AdvertiserID    uuid.UUID  `gorm:"type:uuid;not null;index;uniqueIndex:idx_invoice_period" json:"advertiser_id"`
 	Advertiser      *AfftokUser `gorm:"foreignKey:AdvertiserID" json:"advertiser,omitempty"`
 	
 	// Invoice period
-	Month           int        `json:"month"` // 1-12
-	Year            int        `json:"year"`
+	Month           int        `gorm:"uniqueIndex:idx_invoice_period" json:"month"` // 1-12
+	Year            int        `gorm:"uniqueIndex:idx_invoice_period" json:"year"`
 	PeriodStart     time.Time  `json:"period_start"`
 	PeriodEnd       time.Time  `json:"period_end"`
 	
@@ -24,10 +24,10 @@ type Invoice struct {
 	TotalPromoterPayout float64 `json:"total_promoter_payout"` // Total paid to promoters
 	PlatformRate        float64 `json:"platform_rate"`          // 0.10 = 10%
 	PlatformAmount      float64 `json:"platform_amount"`        // Amount owed to platform
-	Currency            string  `gorm:"default:'KWD'" json:"currency"`
+	Currency            string  `gorm:"default:'KWD';uniqueIndex:idx_invoice_period" json:"currency"`
 	
 	// Status
-	Status          string     `gorm:"default:'pending'" json:"status"` // pending, paid, overdue, cancelled
+	Status          string     `gorm:"default:'pending'" json:"status"` // pending, pending_confirmation, paid, overdue, cancelled
 	DueDate         time.Time  `json:"due_date"`
 	PaidAt          *time.Time `json:"paid_at,omitempty"`
 	PaymentProof    string     `json:"payment_proof,omitempty"` // URL to uploaded receipt
@@ -43,6 +43,20 @@ type Invoice struct {
 	UpdatedAt       time.Time  `json:"updated_at"`
 }
 
+// Invoice Status Constants
+const (
+	InvoiceStatusPending             = "pending"
+	InvoiceStatusPendingConfirmation = "pending_confirmation"
+	InvoiceStatusPaid                = "paid"
+	InvoiceStatusOverdue             = "overdue"
+	InvoiceStatusCancelled           = "cancelled"
+)
+
+// IsValidPlatformRate checks a platform rate is a fraction between 0 and 1
+func IsValidPlatformRate(rate float64) bool {
+	return rate >= 0 && rate <= 1
+}
+
 // BeforeCreate generates UUID before creating
 func (i *Invoice) BeforeCreate(tx *gorm.DB) error {
 	if i.ID == uuid.Nil {
@@ -51,7 +65,7 @@ func (i *Invoice) BeforeCreate(tx *gorm.DB) error {
 	return nil
 }
 
-// InvoiceItem represents a line item in an invoice (optional detail)
+// InvoiceItem represents a per-offer line item in an invoice
 type InvoiceItem struct {
 	ID           uuid.UUID `gorm:"type:uuid;primary_key" json:"id"`
 	InvoiceID    uuid.UUID `gorm:"type:uuid;not null;index" json:"invoice_id"`
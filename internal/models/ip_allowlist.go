@@ -0,0 +1,101 @@
+package models
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// ============================================
+// IP ALLOWLISTS
+// ============================================
+
+// MaxAllowedIPs caps the number of entries in an API key allowlist
+const MaxAllowedIPs = 100
+
+// IPAllowlist is a parsed allowlist of IPv4/IPv6 addresses and CIDR ranges
+type IPAllowlist []netip.Prefix
+
+// NormalizeAllowedIP validates an allowlist entry and returns its canonical
+// form: a single address ("203.0.113.7", "2001:db8::1") or a masked CIDR
+// range ("10.0.0.0/8", "2001:db8::/32"). Host bits in a range are cleared, so
+// "10.1.2.3/8" becomes "10.0.0.0/8".
+func NormalizeAllowedIP(entry string) (string, error) {
+	entry = strings.TrimSpace(entry)
+	if entry == "" {
+		return "", fmt.Errorf("empty IP entry")
+	}
+
+	if strings.Contains(entry, "/") {
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return "", fmt.Errorf("invalid CIDR range %q", entry)
+		}
+		if prefix.Addr().Is4In6() && prefix.Bits() >= 96 {
+			prefix = netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()-96)
+		}
+		return prefix.Masked().String(), nil
+	}
+
+	addr, err := netip.ParseAddr(entry)
+	if err != nil {
+		return "", fmt.Errorf("invalid IP address %q", entry)
+	}
+	return addr.Unmap().WithZone("").String(), nil
+}
+
+// NormalizeAllowedIPs validates and de-duplicates allowlist entries
+func NormalizeAllowedIPs(entries []string) ([]string, error) {
+	if len(entries) > MaxAllowedIPs {
+		return nil, fmt.Errorf("too many allowed IPs (max %d)", MaxAllowedIPs)
+	}
+
+	seen := make(map[string]bool, len(entries))
+	normalized := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		value, err := NormalizeAllowedIP(entry)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[value] {
+			seen[value] = true
+			normalized = append(normalized, value)
+		}
+	}
+	return normalized, nil
+}
+
+// ParseIPAllowlist parses allowlist entries; single addresses become /32 or /128
+func ParseIPAllowlist(entries []string) (IPAllowlist, error) {
+	allowlist := make(IPAllowlist, 0, len(entries))
+	for _, entry := range entries {
+		value, err := NormalizeAllowedIP(entry)
+		if err != nil {
+			return nil, err
+		}
+		if strings.Contains(value, "/") {
+			allowlist = append(allowlist, netip.MustParsePrefix(value))
+			continue
+		}
+		addr := netip.MustParseAddr(value)
+		allowlist = append(allowlist, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return allowlist, nil
+}
+
+// Contains reports whether ip falls in any entry. IPv4-mapped IPv6 addresses
+// (::ffff:203.0.113.7) match IPv4 entries.
+func (l IPAllowlist) Contains(ip string) bool {
+	addr, err := netip.ParseAddr(strings.TrimSpace(ip))
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap().WithZone("")
+
+	for _, prefix := range l {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
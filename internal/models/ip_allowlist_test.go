@@ -0,0 +1,113 @@
+package models
+
+import (
+	"testing"
+)
+
+func TestNormalizeAllowedIP(t *testing.T) {
+	tests := []struct {
+		entry   string
+		want    string
+		wantErr bool
+	}{
+		{entry: "203.0.113.7", want: "203.0.113.7"},
+		{entry: "  203.0.113.7\t", want: "203.0.113.7"},
+		{entry: "2001:DB8::1", want: "2001:db8::1"},
+		{entry: "2001:0db8:0000::0001", want: "2001:db8::1"},
+		{entry: "fe80::1%eth0", want: "fe80::1"},
+		{entry: "::ffff:203.0.113.7", want: "203.0.113.7"},
+		{entry: "10.0.0.0/8", want: "10.0.0.0/8"},
+		{entry: "10.1.2.3/8", want: "10.0.0.0/8"},
+		{entry: "203.0.113.7/32", want: "203.0.113.7/32"},
+		{entry: "0.0.0.0/0", want: "0.0.0.0/0"},
+		{entry: "2001:db8:abcd::/32", want: "2001:db8::/32"},
+		{entry: "::ffff:10.0.0.0/104", want: "10.0.0.0/8"},
+
+		{entry: "", wantErr: true},
+		{entry: "   ", wantErr: true},
+		{entry: "localhost", wantErr: true},
+		{entry: "256.0.0.1", wantErr: true},
+		{entry: "10.0.0.0/33", wantErr: true},
+		{entry: "2001:db8::/129", wantErr: true},
+		{entry: "10.0.0.0/", wantErr: true},
+		{entry: "10.0.0.1-10.0.0.9", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := NormalizeAllowedIP(tt.entry)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("NormalizeAllowedIP(%q) error = %v, wantErr %v", tt.entry, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("NormalizeAllowedIP(%q) = %q, want %q", tt.entry, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeAllowedIPs(t *testing.T) {
+	got, err := NormalizeAllowedIPs([]string{"10.1.2.3/8", "10.0.0.0/8", "::ffff:1.2.3.4", "1.2.3.4"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0] != "10.0.0.0/8" || got[1] != "1.2.3.4" {
+		t.Errorf("NormalizeAllowedIPs = %v, want [10.0.0.0/8 1.2.3.4]", got)
+	}
+
+	if _, err := NormalizeAllowedIPs([]string{"1.2.3.4", "bogus"}); err == nil {
+		t.Error("invalid entry accepted")
+	}
+
+	tooMany := make([]string, MaxAllowedIPs+1)
+	for i := range tooMany {
+		tooMany[i] = "1.2.3.4"
+	}
+	if _, err := NormalizeAllowedIPs(tooMany); err == nil {
+		t.Error("oversized allowlist accepted")
+	}
+}
+
+func TestIPAllowlistContains(t *testing.T) {
+	allowlist, err := ParseIPAllowlist([]string{
+		"203.0.113.7",
+		"10.0.0.0/8",
+		"2001:db8::1",
+		"2001:db8:1::/48",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"203.0.113.7", true},
+		{" 203.0.113.7 ", true},
+		{"203.0.113.8", false},
+		{"10.0.0.1", true},
+		{"10.255.255.255", true},
+		{"11.0.0.0", false},
+		{"::ffff:10.1.2.3", true},
+		{"::ffff:203.0.113.8", false},
+		{"2001:db8::1", true},
+		{"2001:DB8:0::1", true},
+		{"2001:db8::2", false},
+		{"2001:db8:1:ffff::1", true},
+		{"2001:db8:2::1", false},
+		{"fe80::1%eth0", false},
+		{"::a00:1", false}, // IPv4-compatible, not IPv4-mapped
+		{"", false},
+		{"not-an-ip", false},
+	}
+
+	for _, tt := range tests {
+		if got := allowlist.Contains(tt.ip); got != tt.want {
+			t.Errorf("Contains(%q) = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+
+	if (IPAllowlist{}).Contains("10.0.0.1") {
+		t.Error("empty allowlist matched")
+	}
+}
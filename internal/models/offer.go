@@ -1,6 +1,7 @@
 package models
 
 import (
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -54,6 +55,25 @@ type Offer struct {
 	RejectionReason  string     `gorm:"type:text" json:"rejection_reason,omitempty"`      // NEW: Reason if rejected
 	TotalClicks      int        `gorm:"default:0" json:"total_clicks"`
 	TotalConversions int        `gorm:"default:0" json:"total_conversions"`
+
+	// Attribution (how long after a click a conversion may still be credited)
+	AttributionWindowDays int    `gorm:"default:30" json:"attribution_window_days"`
+	AttributionPolicy     string `gorm:"type:varchar(10);default:'flag'" json:"attribution_policy"` // flag, reject
+
+	// Tracking link passthrough. TrackingTemplate is the query string added to
+	// the destination, with macros like {click_id}, {sub1} and {country}
+	// (default "click_id={click_id}&aff_id={click_id}"). PassthroughParams is a
+	// comma separated whitelist of extra query parameters promoters may pass.
+	TrackingTemplate  string `gorm:"type:text" json:"tracking_template,omitempty"`
+	PassthroughParams string `gorm:"type:text" json:"passthrough_params,omitempty"`
+
+	// Who may join: public, approval (promoters apply) or invite_only
+	Visibility string `gorm:"type:varchar(20);default:'public';index" json:"visibility"`
+
+	// Comma separated domains promoters may deep link to (subdomains
+	// included). Empty disables deep links.
+	DeepLinkDomains string `gorm:"type:text" json:"deep_link_domains,omitempty"`
+
 	CreatedAt        time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
 	UpdatedAt        time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"updated_at"`
 	Network          *Network    `gorm:"foreignKey:NetworkID" json:"network,omitempty"`
@@ -81,6 +101,62 @@ func (Offer) TableName() string {
 	return "offers"
 }
 
+// OfferSearchDocument is the bilingual full-text document of an offer. The
+// catalog search and its GIN index (idx_offers_search) must use the same
+// expression. The 'simple' config doesn't stem, so it works for Arabic too.
+const OfferSearchDocument = "(setweight(to_tsvector('simple', coalesce(title, '') || ' ' || coalesce(title_ar, '')), 'A') || " +
+	"setweight(to_tsvector('simple', coalesce(description, '') || ' ' || coalesce(description_ar, '')), 'B'))"
+
+// Attribution policy constants
+const (
+	AttributionPolicyFlag   = "flag"   // Record the conversion but mark it for review
+	AttributionPolicyReject = "reject" // Refuse the conversion outright
+
+	DefaultAttributionWindowDays = 30
+	MaxAttributionWindowDays     = 365
+)
+
+// AttributionWindow returns the offer's attribution window as a duration
+func (o *Offer) AttributionWindow() time.Duration {
+	days := o.AttributionWindowDays
+	if days <= 0 {
+		days = DefaultAttributionWindowDays
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// RejectsOutsideWindow reports whether late conversions should be refused
+func (o *Offer) RejectsOutsideWindow() bool {
+	return o.AttributionPolicy == AttributionPolicyReject
+}
+
+// IsValidAttributionPolicy checks if an attribution policy is supported
+func IsValidAttributionPolicy(policy string) bool {
+	return policy == AttributionPolicyFlag || policy == AttributionPolicyReject
+}
+
+// PassthroughParamList returns the offer's whitelisted passthrough parameters
+func (o *Offer) PassthroughParamList() []string {
+	var params []string
+	for _, param := range strings.Split(o.PassthroughParams, ",") {
+		if param = strings.TrimSpace(param); param != "" {
+			params = append(params, param)
+		}
+	}
+	return params
+}
+
+// DeepLinkDomainList returns the domains promoters may deep link to
+func (o *Offer) DeepLinkDomainList() []string {
+	var domains []string
+	for _, domain := range strings.Split(o.DeepLinkDomains, ",") {
+		if domain = strings.TrimSpace(domain); domain != "" {
+			domains = append(domains, domain)
+		}
+	}
+	return domains
+}
+
 func (o *Offer) ConversionRate() float64 {
 	if o.TotalClicks == 0 {
 		return 0
@@ -99,6 +175,7 @@ type UserOffer struct {
 	Earnings      int       `gorm:"default:0" json:"earnings"`
 	TotalClicks   int       `gorm:"default:0" json:"total_clicks"`
 	TotalConversions int    `gorm:"default:0" json:"total_conversions"`
+	PayoutOverride *int     `json:"payout_override,omitempty"` // Per-promoter commission per conversion, replaces Offer.Commission
 	JoinedAt      time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"joined_at"`
 	UpdatedAt     time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"updated_at"`
 	User          *AfftokUser  `gorm:"foreignKey:UserID" json:"user,omitempty"`
@@ -111,6 +188,29 @@ func (UserOffer) TableName() string {
 	return "user_offers"
 }
 
+// User offer status constants
+const (
+	UserOfferStatusActive  = "active"
+	UserOfferStatusRevoked = "revoked" // Access revoked by the advertiser; links stop tracking
+)
+
+// IsActive reports whether the user offer's links track clicks
+func (uo *UserOffer) IsActive() bool {
+	return uo.Status == UserOfferStatusActive
+}
+
+// EffectiveCommission returns the promoter's commission per conversion: their
+// payout override, or the offer's commission
+func (uo *UserOffer) EffectiveCommission() int {
+	if uo.PayoutOverride != nil {
+		return *uo.PayoutOverride
+	}
+	if uo.Offer != nil {
+		return uo.Offer.Commission
+	}
+	return 0
+}
+
 func (uo *UserOffer) ConversionRate() float64 {
 	totalClicks := len(uo.Clicks)
 	totalConversions := len(uo.Conversions)
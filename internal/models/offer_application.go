@@ -0,0 +1,80 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ============================================
+// OFFER ACCESS
+// ============================================
+
+// Offer visibility constants
+const (
+	OfferVisibilityPublic     = "public"      // Anyone can join
+	OfferVisibilityApproval   = "approval"    // Promoters apply, the advertiser approves
+	OfferVisibilityInviteOnly = "invite_only" // Only promoters the advertiser invites
+)
+
+// IsValidOfferVisibility checks if an offer visibility is supported
+func IsValidOfferVisibility(visibility string) bool {
+	switch visibility {
+	case OfferVisibilityPublic, OfferVisibilityApproval, OfferVisibilityInviteOnly:
+		return true
+	}
+	return false
+}
+
+// OfferApplicationStatus represents the status of a promoter's access to an offer
+type OfferApplicationStatus string
+
+const (
+	OfferApplicationPending  OfferApplicationStatus = "pending"  // Applied, awaiting review
+	OfferApplicationInvited  OfferApplicationStatus = "invited"  // Invited, may join
+	OfferApplicationApproved OfferApplicationStatus = "approved" // May join
+	OfferApplicationRejected OfferApplicationStatus = "rejected" // May apply again
+	OfferApplicationRevoked  OfferApplicationStatus = "revoked"  // Removed from the offer
+)
+
+// OfferApplication is a promoter's access to a non-public offer: their
+// application (or the advertiser's invite), its review, and their payout
+// override. Revoking a promoter from any offer, public ones included, is
+// recorded here so they can't simply join again.
+type OfferApplication struct {
+	ID      uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	OfferID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_offer_applications_offer_user" json:"offer_id"`
+	UserID  uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_offer_applications_offer_user;index:idx_offer_applications_user" json:"user_id"`
+
+	Status OfferApplicationStatus `gorm:"size:20;not null;default:'pending';index:idx_offer_applications_status" json:"status"`
+
+	// Application
+	TrafficSource string `gorm:"type:text" json:"traffic_source,omitempty"` // Where the promoter will send traffic
+	Message       string `gorm:"type:text" json:"message,omitempty"`
+
+	// Review
+	ReviewNote string     `gorm:"type:text" json:"review_note,omitempty"` // Rejection or revocation reason
+	ReviewedBy *uuid.UUID `gorm:"type:uuid" json:"reviewed_by,omitempty"`
+	ReviewedAt *time.Time `json:"reviewed_at,omitempty"`
+	InvitedBy  *uuid.UUID `gorm:"type:uuid" json:"invited_by,omitempty"`
+
+	// Per-promoter commission per conversion, replaces Offer.Commission
+	PayoutOverride *int `json:"payout_override,omitempty"`
+
+	CreatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"updated_at"`
+
+	// Relationships
+	Offer *Offer      `gorm:"foreignKey:OfferID" json:"offer,omitempty"`
+	User  *AfftokUser `gorm:"foreignKey:UserID" json:"user,omitempty"`
+}
+
+// TableName returns the table name for GORM
+func (OfferApplication) TableName() string {
+	return "offer_applications"
+}
+
+// AllowsJoin reports whether the promoter may join the offer
+func (a *OfferApplication) AllowsJoin() bool {
+	return a.Status == OfferApplicationApproved || a.Status == OfferApplicationInvited
+}
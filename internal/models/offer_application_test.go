@@ -0,0 +1,73 @@
+package models
+
+import "testing"
+
+func TestIsValidOfferVisibility(t *testing.T) {
+	tests := map[string]bool{
+		OfferVisibilityPublic:     true,
+		OfferVisibilityApproval:   true,
+		OfferVisibilityInviteOnly: true,
+		"":                        false,
+		"private":                 false,
+		"Public":                  false,
+	}
+	for visibility, want := range tests {
+		if got := IsValidOfferVisibility(visibility); got != want {
+			t.Errorf("IsValidOfferVisibility(%q) = %v, want %v", visibility, got, want)
+		}
+	}
+}
+
+func TestOfferApplicationAllowsJoin(t *testing.T) {
+	tests := map[OfferApplicationStatus]bool{
+		OfferApplicationPending:  false,
+		OfferApplicationInvited:  true,
+		OfferApplicationApproved: true,
+		OfferApplicationRejected: false,
+		OfferApplicationRevoked:  false,
+	}
+	for status, want := range tests {
+		application := OfferApplication{Status: status}
+		if got := application.AllowsJoin(); got != want {
+			t.Errorf("AllowsJoin(%s) = %v, want %v", status, got, want)
+		}
+	}
+}
+
+func TestUserOfferEffectiveCommission(t *testing.T) {
+	override, zero := 250, 0
+
+	tests := []struct {
+		name      string
+		userOffer UserOffer
+		want      int
+	}{
+		{"offer commission", UserOffer{Offer: &Offer{Commission: 100}}, 100},
+		{"override wins", UserOffer{Offer: &Offer{Commission: 100}, PayoutOverride: &override}, 250},
+		{"zero override", UserOffer{Offer: &Offer{Commission: 100}, PayoutOverride: &zero}, 0},
+		{"no offer loaded", UserOffer{}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.userOffer.EffectiveCommission(); got != tt.want {
+				t.Errorf("EffectiveCommission = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUserOfferIsActive(t *testing.T) {
+	tests := map[string]bool{
+		UserOfferStatusActive:  true,
+		UserOfferStatusRevoked: false,
+		"paused":               false,
+		"":                     false,
+	}
+	for status, want := range tests {
+		userOffer := UserOffer{Status: status}
+		if got := userOffer.IsActive(); got != want {
+			t.Errorf("IsActive(%q) = %v, want %v", status, got, want)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+)
+
+// ============================================
+// OFFER ROUTING CONFIG MODEL
+// ============================================
+
+// OfferRoutingStatus represents the status of a routing config
+type OfferRoutingStatus string
+
+const (
+	OfferRoutingStatusActive   OfferRoutingStatus = "active"
+	OfferRoutingStatusDisabled OfferRoutingStatus = "disabled"
+)
+
+// OfferRoutingConfig holds caps, routing rules, A/B test and rotation for an
+// offer, or for a single user offer when UserOfferID is set. A user offer
+// config replaces the offer config for that link. The rule, A/B and rotation
+// documents use the edge worker's SmartRouter schema.
+type OfferRoutingConfig struct {
+	ID uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+
+	// Scope
+	OfferID     uuid.UUID  `gorm:"type:uuid;not null;index:idx_offer_routing_offer" json:"offer_id"`
+	UserOfferID *uuid.UUID `gorm:"type:uuid;index:idx_offer_routing_user_offer" json:"user_offer_id,omitempty"` // nil for offer-level configs
+	TenantID    uuid.UUID  `gorm:"type:uuid;index:idx_offer_routing_tenant" json:"tenant_id"`
+
+	// Caps (0 = unlimited)
+	DailyCap    int    `gorm:"default:0" json:"daily_cap"`
+	TotalCap    int    `gorm:"default:0" json:"total_cap"`
+	FallbackURL string `gorm:"type:text" json:"fallback_url,omitempty"`
+
+	// Routing
+	RoutingRules datatypes.JSON `gorm:"type:jsonb" json:"routing_rules,omitempty"`
+	ABTest       datatypes.JSON `gorm:"type:jsonb" json:"ab_test,omitempty"`
+	Rotation     datatypes.JSON `gorm:"type:jsonb" json:"rotation,omitempty"`
+
+	// Status
+	Status    OfferRoutingStatus `gorm:"size:20;default:'active';index:idx_offer_routing_status" json:"status"`
+	UpdatedBy *uuid.UUID         `gorm:"type:uuid" json:"updated_by,omitempty"`
+
+	// Timestamps
+	CreatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"updated_at"`
+}
+
+// TableName returns the table name for GORM
+func (OfferRoutingConfig) TableName() string {
+	return "offer_routing_configs"
+}
+
+// IsActive returns true if the config is active
+func (c *OfferRoutingConfig) IsActive() bool {
+	return c.Status == OfferRoutingStatusActive
+}
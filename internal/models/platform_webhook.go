@@ -0,0 +1,55 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ============================================
+// PLATFORM WEBHOOK SECRETS
+// ============================================
+
+// E-commerce platforms that send conversion webhooks
+const (
+	WebhookPlatformShopify = "shopify"
+	WebhookPlatformSalla   = "salla"
+	WebhookPlatformZid     = "zid"
+)
+
+// IsValidWebhookPlatform checks a platform name
+func IsValidWebhookPlatform(platform string) bool {
+	switch platform {
+	case WebhookPlatformShopify, WebhookPlatformSalla, WebhookPlatformZid:
+		return true
+	}
+	return false
+}
+
+// PlatformWebhookSecret is an advertiser's signing secret for one platform's
+// order webhooks. Secrets are stored encrypted; after a rotation the previous
+// secret keeps verifying until PreviousValidUntil so in-flight deliveries pass.
+type PlatformWebhookSecret struct {
+	ID                      uuid.UUID   `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	AdvertiserID            uuid.UUID   `gorm:"type:uuid;not null;uniqueIndex:idx_platform_webhook_secret" json:"advertiser_id"`
+	Platform                string      `gorm:"size:20;not null;uniqueIndex:idx_platform_webhook_secret" json:"platform"`
+	SecretEncrypted         string      `gorm:"type:text;not null" json:"-"`
+	SecretHint              string      `gorm:"size:10" json:"secret_hint"` // Last 4 chars for identification
+	PreviousSecretEncrypted string      `gorm:"type:text" json:"-"`
+	PreviousValidUntil      *time.Time  `json:"previous_valid_until,omitempty"`
+	LastVerifiedAt          *time.Time  `json:"last_verified_at,omitempty"`
+	RotatedAt               *time.Time  `json:"rotated_at,omitempty"`
+	CreatedAt               time.Time   `json:"created_at"`
+	UpdatedAt               time.Time   `json:"updated_at"`
+	Advertiser              *AfftokUser `gorm:"foreignKey:AdvertiserID" json:"advertiser,omitempty"`
+}
+
+// TableName returns the table name for GORM
+func (PlatformWebhookSecret) TableName() string {
+	return "platform_webhook_secrets"
+}
+
+// HasValidPreviousSecret reports whether the pre-rotation secret is still accepted
+func (s *PlatformWebhookSecret) HasValidPreviousSecret(now time.Time) bool {
+	return s.PreviousSecretEncrypted != "" && s.PreviousValidUntil != nil && now.Before(*s.PreviousValidUntil)
+}
@@ -0,0 +1,112 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ============================================
+// PROMOTER POSTBACKS
+// ============================================
+
+// Promoter postback events
+const (
+	PromoterPostbackEventCreated       = "created"        // A conversion was recorded
+	PromoterPostbackEventStatusChanged = "status_changed" // A conversion was approved, rejected, paid or reversed
+)
+
+// IsValidPromoterPostbackEvent checks if a promoter postback event is supported
+func IsValidPromoterPostbackEvent(event string) bool {
+	switch event {
+	case PromoterPostbackEventCreated, PromoterPostbackEventStatusChanged:
+		return true
+	}
+	return false
+}
+
+// PromoterPostback is a promoter's own postback URL (an ad tracker or pixel)
+// fired when their conversions are created or change status. Offer-specific
+// postbacks take precedence over the promoter's global ones (OfferID nil).
+type PromoterPostback struct {
+	ID      uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	UserID  uuid.UUID  `gorm:"type:uuid;not null;index:idx_promoter_postbacks_user" json:"user_id"`
+	OfferID *uuid.UUID `gorm:"type:uuid;index:idx_promoter_postbacks_offer" json:"offer_id,omitempty"`
+
+	Name   string `gorm:"type:varchar(100)" json:"name"`
+	URL    string `gorm:"type:text;not null" json:"url"`                         // May contain macros like {click_id}
+	Method string `gorm:"type:varchar(10);not null;default:'GET'" json:"method"` // GET or POST (JSON body)
+
+	Events   string `gorm:"type:varchar(100);not null;default:'created'" json:"events"` // Comma-separated events
+	Statuses string `gorm:"type:varchar(100)" json:"statuses,omitempty"`                // Comma-separated conversion statuses, empty = all
+
+	IsActive bool `gorm:"default:true" json:"is_active"`
+
+	CreatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"updated_at"`
+
+	// Relationships
+	Offer *Offer `gorm:"foreignKey:OfferID" json:"offer,omitempty"`
+}
+
+// TableName returns the table name for GORM
+func (PromoterPostback) TableName() string {
+	return "promoter_postbacks"
+}
+
+// FiresOn reports whether the postback fires for an event on a conversion
+// in the given status
+func (p *PromoterPostback) FiresOn(event, status string) bool {
+	if !p.IsActive || !containsListItem(p.Events, event) {
+		return false
+	}
+	return strings.TrimSpace(p.Statuses) == "" || containsListItem(p.Statuses, status)
+}
+
+// containsListItem reports whether a comma-separated list contains an item
+func containsListItem(list, item string) bool {
+	for _, value := range strings.Split(list, ",") {
+		if strings.TrimSpace(value) == item {
+			return true
+		}
+	}
+	return false
+}
+
+// Promoter postback delivery status constants
+const (
+	PromoterPostbackDeliveryPending = "pending" // Queued or waiting for a retry
+	PromoterPostbackDeliverySent    = "sent"
+	PromoterPostbackDeliveryFailed  = "failed" // Last attempt failed, will be retried
+	PromoterPostbackDeliveryDLQ     = "dlq"    // Gave up, in the postback dead letter queue
+)
+
+// PromoterPostbackDelivery records one firing of a promoter postback, kept
+// up to date as the postback queue attempts it. Its ID is the queue item ID.
+type PromoterPostbackDelivery struct {
+	ID           uuid.UUID `gorm:"type:uuid;primary_key" json:"id"`
+	PostbackID   uuid.UUID `gorm:"type:uuid;not null;index:idx_promoter_postback_deliveries_postback" json:"postback_id"`
+	UserID       uuid.UUID `gorm:"type:uuid;not null;index:idx_promoter_postback_deliveries_user_time,priority:1" json:"user_id"`
+	ConversionID uuid.UUID `gorm:"type:uuid;not null;index:idx_promoter_postback_deliveries_conversion" json:"conversion_id"`
+
+	Event            string `gorm:"type:varchar(30);not null" json:"event"`
+	ConversionStatus string `gorm:"type:varchar(20)" json:"conversion_status"`
+	URL              string `gorm:"type:text;not null" json:"url"` // Rendered URL
+	Method           string `gorm:"type:varchar(10);not null" json:"method"`
+
+	Status          string     `gorm:"type:varchar(20);not null;default:'pending';index:idx_promoter_postback_deliveries_status" json:"status"`
+	Attempts        int        `gorm:"default:0" json:"attempts"`
+	StatusCode      int        `json:"status_code,omitempty"`
+	ResponseSnippet string     `gorm:"type:text" json:"response_snippet,omitempty"`
+	LastError       string     `gorm:"type:text" json:"last_error,omitempty"`
+	DeliveredAt     *time.Time `json:"delivered_at,omitempty"`
+
+	CreatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP;index:idx_promoter_postback_deliveries_user_time,priority:2" json:"created_at"`
+	UpdatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"updated_at"`
+}
+
+// TableName returns the table name for GORM
+func (PromoterPostbackDelivery) TableName() string {
+	return "promoter_postback_deliveries"
+}
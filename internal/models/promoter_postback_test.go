@@ -0,0 +1,29 @@
+package models
+
+import "testing"
+
+func TestPromoterPostbackFiresOn(t *testing.T) {
+	tests := []struct {
+		name     string
+		postback PromoterPostback
+		event    string
+		status   string
+		want     bool
+	}{
+		{"matching event", PromoterPostback{IsActive: true, Events: PromoterPostbackEventCreated}, PromoterPostbackEventCreated, "pending", true},
+		{"other event", PromoterPostback{IsActive: true, Events: PromoterPostbackEventCreated}, PromoterPostbackEventStatusChanged, "approved", false},
+		{"event list with spaces", PromoterPostback{IsActive: true, Events: "created, status_changed"}, PromoterPostbackEventStatusChanged, "approved", true},
+		{"inactive", PromoterPostback{Events: PromoterPostbackEventCreated}, PromoterPostbackEventCreated, "pending", false},
+		{"status filter match", PromoterPostback{IsActive: true, Events: PromoterPostbackEventCreated, Statuses: "approved,pending"}, PromoterPostbackEventCreated, "pending", true},
+		{"status filter miss", PromoterPostback{IsActive: true, Events: PromoterPostbackEventCreated, Statuses: "approved"}, PromoterPostbackEventCreated, "pending", false},
+		{"no prefix matching", PromoterPostback{IsActive: true, Events: "create"}, PromoterPostbackEventCreated, "pending", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.postback.FiresOn(tt.event, tt.status); got != tt.want {
+				t.Errorf("FiresOn(%q, %q) = %v, want %v", tt.event, tt.status, got, tt.want)
+			}
+		})
+	}
+}
@@ -103,6 +103,10 @@ type TenantSettings struct {
 	// API Settings
 	APIRateLimitPerMin   int    `json:"api_rate_limit_per_min"`
 	
+	// Egress: hosts (and their subdomains) webhooks and postbacks may call;
+	// empty allows any public host
+	EgressAllowlist      []string `json:"egress_allowlist,omitempty"`
+	
 	// Notification Settings
 	NotifyOnConversion   bool   `json:"notify_on_conversion"`
 	NotifyOnFraud        bool   `json:"notify_on_fraud"`
@@ -1,11 +1,76 @@
 package models
 
 import (
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"gorm.io/datatypes"
 )
 
+// SubIDs are the traffic-source tags a promoter puts on a tracking link
+// (sub1..sub5, e.g. TikTok video ID, campaign, creative). They are stored on
+// the click and copied onto its conversions.
+type SubIDs struct {
+	Sub1 string `gorm:"type:varchar(255);index" json:"sub1,omitempty"`
+	Sub2 string `gorm:"type:varchar(255)" json:"sub2,omitempty"`
+	Sub3 string `gorm:"type:varchar(255)" json:"sub3,omitempty"`
+	Sub4 string `gorm:"type:varchar(255)" json:"sub4,omitempty"`
+	Sub5 string `gorm:"type:varchar(255)" json:"sub5,omitempty"`
+}
+
+// MaxSubIDs is the number of sub-ID slots
+const MaxSubIDs = 5
+
+// Get returns sub-ID n (1-5)
+func (s SubIDs) Get(n int) string {
+	switch n {
+	case 1:
+		return s.Sub1
+	case 2:
+		return s.Sub2
+	case 3:
+		return s.Sub3
+	case 4:
+		return s.Sub4
+	case 5:
+		return s.Sub5
+	}
+	return ""
+}
+
+// Set sets sub-ID n (1-5)
+func (s *SubIDs) Set(n int, value string) {
+	switch n {
+	case 1:
+		s.Sub1 = value
+	case 2:
+		s.Sub2 = value
+	case 3:
+		s.Sub3 = value
+	case 4:
+		s.Sub4 = value
+	case 5:
+		s.Sub5 = value
+	}
+}
+
+// IsEmpty reports whether no sub-ID is set
+func (s SubIDs) IsEmpty() bool {
+	return s == SubIDs{}
+}
+
+// ToMap returns the sub-IDs keyed sub1..sub5
+func (s SubIDs) ToMap() map[string]interface{} {
+	return map[string]interface{}{
+		"sub1": s.Sub1,
+		"sub2": s.Sub2,
+		"sub3": s.Sub3,
+		"sub4": s.Sub4,
+		"sub5": s.Sub5,
+	}
+}
+
 // Click represents a single click on an affiliate link
 type Click struct {
 	ID          uuid.UUID  `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
@@ -24,6 +89,18 @@ type Click struct {
 	Fingerprint string     `gorm:"type:varchar(64);index:idx_clicks_fingerprint" json:"fingerprint,omitempty"`
 	IsUnique    bool       `gorm:"default:true" json:"is_unique"`
 	
+	// SDK device context (empty for link clicks)
+	DeviceID    string         `gorm:"type:varchar(100);index:idx_clicks_device_id" json:"device_id,omitempty"`
+	DeviceInfo  datatypes.JSON `gorm:"type:jsonb" json:"device_info,omitempty"`
+	
+	// Tracking link parameters: sub1..sub5 and the offer's whitelisted
+	// passthrough parameters
+	SubIDs       `gorm:"embedded"`
+	CustomParams datatypes.JSON `gorm:"type:jsonb" json:"custom_params,omitempty"`
+	
+	// Deep link the click came through (nil for the offer's destination)
+	DeepLinkID  *uuid.UUID `gorm:"type:uuid;index:idx_clicks_deep_link" json:"deep_link_id,omitempty"`
+	
 	// Relationships
 	UserOffer   *UserOffer `gorm:"foreignKey:UserOfferID" json:"user_offer,omitempty"`
 }
@@ -50,16 +127,28 @@ type Conversion struct {
 	// Status tracking
 	Status               string     `gorm:"type:varchar(20);default:'pending';index:idx_conv_status" json:"status"`
 	RejectionReason      string     `gorm:"type:text" json:"rejection_reason,omitempty"`
+	ReversalReason       string     `gorm:"type:text" json:"reversal_reason,omitempty"`
+	
+	// Attribution
+	OutsideAttributionWindow bool   `gorm:"default:false" json:"outside_attribution_window"`
 	
 	// Timestamps
 	ConvertedAt          time.Time  `gorm:"default:CURRENT_TIMESTAMP;index:idx_conv_time" json:"converted_at"`
 	ApprovedAt           *time.Time `json:"approved_at,omitempty"`
 	PaidAt               *time.Time `json:"paid_at,omitempty"`
+	ReversedAt           *time.Time `json:"reversed_at,omitempty"`
 	
 	// Postback data
 	PostbackData         string     `gorm:"type:jsonb" json:"postback_data,omitempty"`
 	PostbackReceivedAt   *time.Time `json:"postback_received_at,omitempty"`
 	
+	// SDK device context (empty for server postbacks)
+	DeviceID             string         `gorm:"type:varchar(100);index:idx_conv_device_id" json:"device_id,omitempty"`
+	DeviceInfo           datatypes.JSON `gorm:"type:jsonb" json:"device_info,omitempty"`
+	
+	// Sub-IDs of the attributed click
+	SubIDs               `gorm:"embedded"`
+	
 	// Relationships
 	UserOffer            *UserOffer `gorm:"foreignKey:UserOfferID" json:"user_offer,omitempty"`
 	Click                *Click     `gorm:"foreignKey:ClickID" json:"click,omitempty"`
@@ -75,8 +164,16 @@ const (
 	ConversionStatusApproved = "approved"
 	ConversionStatusRejected = "rejected"
 	ConversionStatusPaid     = "paid"
+	ConversionStatusReversed = "reversed"
 )
 
+// conversionTransitions lists the allowed status changes:
+// pending -> approved -> paid, pending -> rejected, approved -> reversed
+var conversionTransitions = map[string][]string{
+	ConversionStatusPending:  {ConversionStatusApproved, ConversionStatusRejected},
+	ConversionStatusApproved: {ConversionStatusPaid, ConversionStatusReversed},
+}
+
 // IsValid checks if conversion status is valid
 func (c *Conversion) IsValid() bool {
 	validStatuses := map[string]bool{
@@ -84,10 +181,50 @@ func (c *Conversion) IsValid() bool {
 		ConversionStatusApproved: true,
 		ConversionStatusRejected: true,
 		ConversionStatusPaid:     true,
+		ConversionStatusReversed: true,
 	}
 	return validStatuses[c.Status]
 }
 
+// CanTransitionTo checks if the conversion may move to the given status
+func (c *Conversion) CanTransitionTo(status string) bool {
+	for _, next := range conversionTransitions[c.Status] {
+		if next == status {
+			return true
+		}
+	}
+	return false
+}
+
+// ResolveStatusUpdate maps a requested status onto the state machine. Networks
+// send "rejected" (or "refunded"/"chargeback") for an order that was already
+// approved; that is a reversal, not a rejection.
+func (c *Conversion) ResolveStatusUpdate(requested string) string {
+	requested = strings.ToLower(strings.TrimSpace(requested))
+	switch requested {
+	case ConversionStatusRejected, "refunded", "chargeback", "cancelled", "canceled":
+		if c.Status == ConversionStatusApproved {
+			return ConversionStatusReversed
+		}
+		return ConversionStatusRejected
+	}
+	return requested
+}
+
+// IsCredited reports whether the conversion's commission counts towards earnings
+func (c *Conversion) IsCredited() bool {
+	return c.Status == ConversionStatusApproved || c.Status == ConversionStatusPaid
+}
+
+// VoidedConversionStatuses are the statuses of conversions that no longer
+// count towards rankings
+var VoidedConversionStatuses = []string{ConversionStatusRejected, ConversionStatusReversed}
+
+// IsVoided checks if the conversion was rejected or reversed
+func (c *Conversion) IsVoided() bool {
+	return c.Status == ConversionStatusRejected || c.Status == ConversionStatusReversed
+}
+
 // CanApprove checks if conversion can be approved
 func (c *Conversion) CanApprove() bool {
 	return c.Status == ConversionStatusPending
@@ -121,9 +258,10 @@ func (Badge) TableName() string {
 // UserBadge represents a badge earned by a user
 type UserBadge struct {
 	ID       uuid.UUID   `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
-	UserID   uuid.UUID   `gorm:"type:uuid;not null;column:user_id;index:idx_user_badge_user" json:"user_id"`
-	BadgeID  uuid.UUID   `gorm:"type:uuid;not null;column:badge_id;index:idx_user_badge_badge" json:"badge_id"`
+	UserID   uuid.UUID   `gorm:"type:uuid;not null;column:user_id;index:idx_user_badge_user;uniqueIndex:idx_user_badge_unique" json:"user_id"`
+	BadgeID  uuid.UUID   `gorm:"type:uuid;not null;column:badge_id;index:idx_user_badge_badge;uniqueIndex:idx_user_badge_unique" json:"badge_id"`
 	EarnedAt time.Time   `gorm:"default:CURRENT_TIMESTAMP;column:earned_at" json:"earned_at"`
+	SeenAt   *time.Time  `gorm:"column:seen_at" json:"seen_at,omitempty"` // Nil until the promoter has seen the award notification
 	User     *AfftokUser `gorm:"foreignKey:UserID" json:"user,omitempty"`
 	Badge    *Badge      `gorm:"foreignKey:BadgeID" json:"badge,omitempty"`
 }
@@ -0,0 +1,142 @@
+package models
+
+import (
+	"testing"
+)
+
+var allConversionStatuses = []string{
+	ConversionStatusPending,
+	ConversionStatusApproved,
+	ConversionStatusRejected,
+	ConversionStatusPaid,
+	ConversionStatusReversed,
+}
+
+func TestConversionCanTransitionTo(t *testing.T) {
+	allowed := map[[2]string]bool{
+		{ConversionStatusPending, ConversionStatusApproved}:  true,
+		{ConversionStatusPending, ConversionStatusRejected}:  true,
+		{ConversionStatusApproved, ConversionStatusPaid}:     true,
+		{ConversionStatusApproved, ConversionStatusReversed}: true,
+	}
+
+	// Every pair of statuses, including a status to itself and unknown ones
+	statuses := append(allConversionStatuses, "", "refunded")
+	for _, from := range statuses {
+		for _, to := range statuses {
+			c := &Conversion{Status: from}
+			if got, want := c.CanTransitionTo(to), allowed[[2]string{from, to}]; got != want {
+				t.Errorf("%q -> %q: CanTransitionTo = %v, want %v", from, to, got, want)
+			}
+		}
+	}
+}
+
+func TestConversionResolveStatusUpdate(t *testing.T) {
+	tests := []struct {
+		current   string
+		requested string
+		want      string
+	}{
+		{ConversionStatusPending, "approved", ConversionStatusApproved},
+		{ConversionStatusPending, " Approved ", ConversionStatusApproved},
+		{ConversionStatusPending, "rejected", ConversionStatusRejected},
+		{ConversionStatusPending, "refunded", ConversionStatusRejected},
+		{ConversionStatusPending, "chargeback", ConversionStatusRejected},
+		{ConversionStatusPending, "cancelled", ConversionStatusRejected},
+		{ConversionStatusPending, "canceled", ConversionStatusRejected},
+		{ConversionStatusPending, "pending", ConversionStatusPending},
+		{ConversionStatusPending, "", ""},
+
+		{ConversionStatusApproved, "rejected", ConversionStatusReversed},
+		{ConversionStatusApproved, "REFUNDED", ConversionStatusReversed},
+		{ConversionStatusApproved, "chargeback", ConversionStatusReversed},
+		{ConversionStatusApproved, "canceled", ConversionStatusReversed},
+		{ConversionStatusApproved, "paid", ConversionStatusPaid},
+		{ConversionStatusApproved, "reversed", ConversionStatusReversed},
+		{ConversionStatusApproved, "approved", ConversionStatusApproved},
+
+		// Terminal states: a cancellation maps to rejected, which they can't reach
+		{ConversionStatusRejected, "refunded", ConversionStatusRejected},
+		{ConversionStatusPaid, "refunded", ConversionStatusRejected},
+		{ConversionStatusReversed, "chargeback", ConversionStatusRejected},
+		{ConversionStatusPaid, "approved", ConversionStatusApproved},
+
+		// Unknown values pass through and fail CanTransitionTo
+		{ConversionStatusPending, "shipped", "shipped"},
+	}
+
+	for _, tt := range tests {
+		c := &Conversion{Status: tt.current}
+		if got := c.ResolveStatusUpdate(tt.requested); got != tt.want {
+			t.Errorf("%s + %q: ResolveStatusUpdate = %q, want %q", tt.current, tt.requested, got, tt.want)
+		}
+	}
+}
+
+func TestConversionStatusUpdateOutcome(t *testing.T) {
+	// The postback handler resolves a requested status and then applies it only
+	// if it is a legal transition; this is the resulting decision for each case
+	requests := []string{"pending", "approved", "rejected", "refunded", "paid", "reversed"}
+	want := map[string]map[string]string{
+		ConversionStatusPending: {
+			"approved": ConversionStatusApproved,
+			"rejected": ConversionStatusRejected,
+			"refunded": ConversionStatusRejected,
+		},
+		ConversionStatusApproved: {
+			"rejected": ConversionStatusReversed,
+			"refunded": ConversionStatusReversed,
+			"paid":     ConversionStatusPaid,
+			"reversed": ConversionStatusReversed,
+		},
+	}
+
+	for _, current := range allConversionStatuses {
+		for _, requested := range requests {
+			c := &Conversion{Status: current}
+			got := ""
+			if target := c.ResolveStatusUpdate(requested); c.CanTransitionTo(target) {
+				got = target
+			}
+			if got != want[current][requested] {
+				t.Errorf("%s + %q: applied %q, want %q", current, requested, got, want[current][requested])
+			}
+		}
+	}
+}
+
+func TestConversionIsCredited(t *testing.T) {
+	credited := map[string]bool{ConversionStatusApproved: true, ConversionStatusPaid: true}
+	for _, status := range allConversionStatuses {
+		c := &Conversion{Status: status}
+		if c.IsCredited() != credited[status] {
+			t.Errorf("%s: IsCredited = %v", status, c.IsCredited())
+		}
+		if !c.IsValid() {
+			t.Errorf("%s: IsValid = false", status)
+		}
+	}
+	if (&Conversion{Status: "refunded"}).IsValid() {
+		t.Error("refunded is not a stored status")
+	}
+}
+
+func TestConversionIsVoided(t *testing.T) {
+	voided := map[string]bool{}
+	for _, status := range VoidedConversionStatuses {
+		voided[status] = true
+	}
+	for _, status := range allConversionStatuses {
+		c := &Conversion{Status: status}
+		if c.IsVoided() != voided[status] {
+			t.Errorf("%s: IsVoided = %v, in VoidedConversionStatuses = %v", status, c.IsVoided(), voided[status])
+		}
+		if c.IsVoided() && c.IsCredited() {
+			t.Errorf("%s: both voided and credited", status)
+		}
+	}
+	if len(voided) != 2 || !voided[ConversionStatusRejected] || !voided[ConversionStatusReversed] {
+		t.Errorf("VoidedConversionStatuses = %v", VoidedConversionStatuses)
+	}
+}
@@ -39,6 +39,9 @@ type AfftokUser struct {
 	Website     string `gorm:"type:text" json:"website,omitempty"`
 	Country     string `gorm:"type:varchar(50)" json:"country,omitempty"`
 
+	// Platform fee charged on promoter payouts; nil uses the platform default
+	PlatformRate *float64 `gorm:"type:decimal(5,4)" json:"platform_rate,omitempty"`
+
 	// Relationships
 	UserOffers       []UserOffer `gorm:"foreignKey:UserID" json:"user_offers,omitempty"`
 	TeamMember       *TeamMember `gorm:"foreignKey:UserID" json:"team_member,omitempty"`
@@ -131,6 +131,7 @@ const (
 	WebhookExecutionFailover   WebhookExecutionStatus = "failover"
 	WebhookExecutionDLQ        WebhookExecutionStatus = "dlq"
 	WebhookExecutionCancelled  WebhookExecutionStatus = "cancelled"
+	WebhookExecutionSkipped    WebhookExecutionStatus = "skipped" // Step conditions not met
 )
 
 // WebhookExecution represents a pipeline execution instance
@@ -180,6 +181,7 @@ type WebhookStepResult struct {
 	ResponseCode int                    `json:"response_code" gorm:"default:0"`
 	ResponseBody string                 `json:"response_body,omitempty" gorm:"type:text"`
 	ErrorMessage string                 `json:"error_message,omitempty" gorm:"type:text"`
+	SkipReason   string                 `json:"skip_reason,omitempty" gorm:"type:text"` // Set when Status is skipped
 	DurationMs   int64                  `json:"duration_ms" gorm:"default:0"`
 	StartedAt    *time.Time             `json:"started_at,omitempty"`
 	CompletedAt  *time.Time             `json:"completed_at,omitempty"`
@@ -224,6 +226,7 @@ type WebhookTask struct {
 	LastError     string           `json:"last_error,omitempty"`
 	NextRetryAt   time.Time        `json:"next_retry_at"`
 	CorrelationID string           `json:"correlation_id"`
+	TriggerID     string           `json:"trigger_id,omitempty"`
 }
 
 // ============================================
@@ -241,6 +244,7 @@ type WebhookMetrics struct {
 	AvgLatencyMs      float64 `json:"avg_latency_ms"`
 	StepSuccessCount  int64   `json:"step_success_count"`
 	StepFailureCount  int64   `json:"step_failure_count"`
+	StepSkippedCount  int64   `json:"step_skipped_count"`
 	PendingTasks      int64   `json:"pending_tasks"`
 	RunningTasks      int64   `json:"running_tasks"`
 	QueueSizes        map[string]int64 `json:"queue_sizes"`
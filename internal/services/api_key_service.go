@@ -6,9 +6,9 @@ import (
 	"crypto/subtle"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
-	"net"
 	"strings"
 	"sync"
 	"time"
@@ -29,8 +29,24 @@ import (
 type APIKeyService struct {
 	db    *gorm.DB
 	mutex sync.RWMutex
+
+	// Parsed allowlists keyed by API key ID, re-parsed when the raw JSON changes
+	allowlists sync.Map
+}
+
+// cachedAllowlist is a parsed allowlist and the raw JSON it came from
+type cachedAllowlist struct {
+	raw       string
+	allowlist models.IPAllowlist
+	err       error
 }
 
+// ErrAPIKeyIPNotAllowed is returned when a request comes from outside the key's allowlist
+var ErrAPIKeyIPNotAllowed = errors.New("IP not allowed")
+
+// apiKeyIPViolationReason is the usage log error reason for allowlist violations
+const apiKeyIPViolationReason = "ip_not_allowed"
+
 // NewAPIKeyService creates a new API key service
 func NewAPIKeyService(db *gorm.DB) *APIKeyService {
 	return &APIKeyService{db: db}
@@ -108,10 +124,14 @@ func (s *APIKeyService) GenerateAPIKey(advertiserID uuid.UUID, req *models.Creat
 	}
 	permissionsJSON, _ := json.Marshal(permissions)
 
-	// Parse allowed IPs
+	// Parse allowed IPs (addresses or CIDR ranges)
 	var allowedIPsJSON []byte
 	if len(req.AllowedIPs) > 0 {
-		allowedIPsJSON, _ = json.Marshal(req.AllowedIPs)
+		allowedIPs, err := models.NormalizeAllowedIPs(req.AllowedIPs)
+		if err != nil {
+			return nil, err
+		}
+		allowedIPsJSON, _ = json.Marshal(allowedIPs)
 	}
 
 	// Calculate expiration
@@ -232,9 +252,11 @@ func (s *APIKeyService) ValidateAPIKeyWithIP(plaintextKey, ip string) (*models.A
 		return nil, err
 	}
 
-	// Check IP allowlist
+	// Check IP allowlist. The violation is logged here, against the key,
+	// so callers must not log it again.
 	if !s.isIPAllowed(apiKey, ip) {
-		return nil, fmt.Errorf("IP not allowed")
+		s.LogUsage(apiKey.ID, apiKey.AdvertiserID, ip, "", "", "", false, 401, apiKeyIPViolationReason, 0)
+		return nil, ErrAPIKeyIPNotAllowed
 	}
 
 	return apiKey, nil
@@ -364,8 +386,13 @@ func (s *APIKeyService) RevokeAPIKey(apiKeyID uuid.UUID) error {
 // IP MANAGEMENT
 // ============================================
 
-// AddAllowedIP adds an IP to the allowlist
+// AddAllowedIP adds an IP address or CIDR range (IPv4 or IPv6) to the allowlist
 func (s *APIKeyService) AddAllowedIP(apiKeyID uuid.UUID, ip string) error {
+	ip, err := models.NormalizeAllowedIP(ip)
+	if err != nil {
+		return err
+	}
+
 	var key models.AdvertiserAPIKey
 	if err := s.db.First(&key, "id = ?", apiKeyID).Error; err != nil {
 		return fmt.Errorf("key not found: %w", err)
@@ -384,6 +411,10 @@ func (s *APIKeyService) AddAllowedIP(apiKeyID uuid.UUID, ip string) error {
 		}
 	}
 
+	if len(allowedIPs) >= models.MaxAllowedIPs {
+		return fmt.Errorf("too many allowed IPs (max %d)", models.MaxAllowedIPs)
+	}
+
 	// Add new IP
 	allowedIPs = append(allowedIPs, ip)
 	allowedIPsJSON, _ := json.Marshal(allowedIPs)
@@ -398,6 +429,10 @@ func (s *APIKeyService) AddAllowedIP(apiKeyID uuid.UUID, ip string) error {
 
 // RemoveAllowedIP removes an IP from the allowlist
 func (s *APIKeyService) RemoveAllowedIP(apiKeyID uuid.UUID, ip string) error {
+	if normalized, err := models.NormalizeAllowedIP(ip); err == nil {
+		ip = normalized
+	}
+
 	var key models.AdvertiserAPIKey
 	if err := s.db.First(&key, "id = ?", apiKeyID).Error; err != nil {
 		return fmt.Errorf("key not found: %w", err)
@@ -537,6 +572,18 @@ type APIKeyStats struct {
 	UsageToday    int64 `json:"usage_today"`
 	UsageThisWeek int64 `json:"usage_this_week"`
 	FailedAttempts int64 `json:"failed_attempts_today"`
+	IPViolationsToday int64 `json:"ip_violations_today"`
+	IPViolationsThisWeek int64 `json:"ip_violations_this_week"`
+	TopIPViolations []APIKeyIPViolation `json:"top_ip_violations"`
+}
+
+// APIKeyIPViolation summarizes requests for one key from one IP outside its allowlist
+type APIKeyIPViolation struct {
+	APIKeyID     uuid.UUID `json:"api_key_id"`
+	AdvertiserID uuid.UUID `json:"advertiser_id"`
+	IP           string    `json:"ip"`
+	Count        int64     `json:"count"`
+	LastSeen     time.Time `json:"last_seen"`
 }
 
 // GetAPIKeyStats returns API key statistics
@@ -563,6 +610,17 @@ func (s *APIKeyService) GetAPIKeyStats() (*APIKeyStats, error) {
 	// Failed attempts today
 	s.db.Model(&models.APIKeyUsageLog{}).Where("created_at >= ? AND success = ?", today, false).Count(&stats.FailedAttempts)
 
+	// Allowlist violations
+	s.db.Model(&models.APIKeyUsageLog{}).Where("created_at >= ? AND error_reason = ?", today, apiKeyIPViolationReason).Count(&stats.IPViolationsToday)
+	s.db.Model(&models.APIKeyUsageLog{}).Where("created_at >= ? AND error_reason = ?", weekAgo, apiKeyIPViolationReason).Count(&stats.IPViolationsThisWeek)
+	s.db.Model(&models.APIKeyUsageLog{}).
+		Select("api_key_id, advertiser_id, ip, COUNT(*) AS count, MAX(created_at) AS last_seen").
+		Where("created_at >= ? AND error_reason = ?", weekAgo, apiKeyIPViolationReason).
+		Group("api_key_id, advertiser_id, ip").
+		Order("count DESC").
+		Limit(20).
+		Scan(&stats.TopIPViolations)
+
 	return stats, nil
 }
 
@@ -615,41 +673,40 @@ func (s *APIKeyService) hashForCache(key string) string {
 
 // invalidateKeyCache invalidates the cache for a key
 func (s *APIKeyService) invalidateKeyCache(apiKeyID uuid.UUID) {
+	s.allowlists.Delete(apiKeyID)
+
 	// We can't easily invalidate by key content, so we rely on TTL
 	// For immediate invalidation, we could store a version counter
 }
 
-// isIPAllowed checks if an IP is in the allowlist
+// isIPAllowed checks if an IP is in the allowlist. The parsed allowlist is
+// cached per key so requests don't re-parse the JSON and CIDR ranges.
 func (s *APIKeyService) isIPAllowed(key *models.AdvertiserAPIKey, ip string) bool {
-	if key.AllowedIPs == nil || len(key.AllowedIPs) == 0 {
+	if len(key.AllowedIPs) == 0 {
 		return true
 	}
 
-	var allowedIPs []string
-	if err := json.Unmarshal(key.AllowedIPs, &allowedIPs); err != nil {
-		return true // Parse error, allow
+	raw := string(key.AllowedIPs)
+	entry, ok := s.allowlists.Load(key.ID)
+	cached, _ := entry.(*cachedAllowlist)
+	if !ok || cached.raw != raw {
+		cached = &cachedAllowlist{raw: raw}
+		if allowedIPs, err := key.GetAllowedIPs(); err != nil {
+			cached.err = err
+		} else if len(allowedIPs) > 0 {
+			cached.allowlist, cached.err = models.ParseIPAllowlist(allowedIPs)
+		}
+		s.allowlists.Store(key.ID, cached)
 	}
 
-	if len(allowedIPs) == 0 {
-		return true
+	if cached.err != nil {
+		log.Printf("[APIKey] Invalid allowlist on key %s: %v", key.ID, cached.err)
+		return false // Fail closed
 	}
-
-	for _, allowedIP := range allowedIPs {
-		// Direct match
-		if allowedIP == ip {
-			return true
-		}
-
-		// CIDR match
-		if strings.Contains(allowedIP, "/") {
-			_, network, err := net.ParseCIDR(allowedIP)
-			if err == nil && network.Contains(net.ParseIP(ip)) {
-				return true
-			}
-		}
+	if len(cached.allowlist) == 0 {
+		return true
 	}
-
-	return false
+	return cached.allowlist.Contains(ip)
 }
 
 // toAPIKeyInfo converts an AdvertiserAPIKey to APIKeyInfo
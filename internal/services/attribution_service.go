@@ -0,0 +1,183 @@
+package services
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ============================================
+// ATTRIBUTION SERVICE
+// ============================================
+
+// AttributionService links conversions back to the click that earned them
+// and enforces each offer's attribution window
+type AttributionService struct {
+	db *gorm.DB
+}
+
+// NewAttributionService creates a new attribution service
+func NewAttributionService(db *gorm.DB) *AttributionService {
+	return &AttributionService{db: db}
+}
+
+// Attribution decisions
+const (
+	AttributionAttributed = "attributed" // Click found and within window
+	AttributionLegacy     = "legacy"     // click_id resolved to a user offer, not a click
+	AttributionDirect     = "direct"     // No click_id; attributed to the promoter directly
+	AttributionFlagged    = "flagged"    // Outside window, recorded for review
+	AttributionRejected   = "rejected"   // Outside window, refused
+)
+
+// ============================================
+// ATTRIBUTION METRICS
+// ============================================
+
+// AttributionMetrics tracks attribution outcomes
+type AttributionMetrics struct {
+	Attributed int64
+	Legacy     int64
+	Flagged    int64
+	Rejected   int64
+	Unresolved int64
+}
+
+var attributionMetrics = &AttributionMetrics{}
+
+// GetAttributionMetrics returns attribution metrics
+func GetAttributionMetrics() *AttributionMetrics {
+	return &AttributionMetrics{
+		Attributed: atomic.LoadInt64(&attributionMetrics.Attributed),
+		Legacy:     atomic.LoadInt64(&attributionMetrics.Legacy),
+		Flagged:    atomic.LoadInt64(&attributionMetrics.Flagged),
+		Rejected:   atomic.LoadInt64(&attributionMetrics.Rejected),
+		Unresolved: atomic.LoadInt64(&attributionMetrics.Unresolved),
+	}
+}
+
+// ============================================
+// ATTRIBUTION RESULT
+// ============================================
+
+// AttributionResult describes how a conversion was attributed
+type AttributionResult struct {
+	Click      *models.Click
+	UserOffer  *models.UserOffer
+	Decision   string
+	ClickAge   time.Duration
+	WindowDays int
+	Reason     string
+}
+
+// ClickID returns the attributed click ID, or nil for legacy attribution
+func (r *AttributionResult) ClickID() *uuid.UUID {
+	if r.Click == nil {
+		return nil
+	}
+	id := r.Click.ID
+	return &id
+}
+
+// IsRejected returns true if the conversion must not be recorded
+func (r *AttributionResult) IsRejected() bool {
+	return r.Decision == AttributionRejected
+}
+
+// IsFlagged returns true if the conversion arrived outside the window
+// but the offer policy allows recording it for review
+func (r *AttributionResult) IsFlagged() bool {
+	return r.Decision == AttributionFlagged
+}
+
+// ============================================
+// RESOLUTION
+// ============================================
+
+// Attribute resolves a click_id and evaluates the offer's attribution window.
+// The click_id is normally a Click UUID; for links issued before per-click IDs
+// it may be a UserOffer UUID or tracking code, which is accepted as legacy.
+func (s *AttributionService) Attribute(clickID string, convertedAt time.Time) (*AttributionResult, error) {
+	if clickID == "" {
+		atomic.AddInt64(&attributionMetrics.Unresolved, 1)
+		return nil, fmt.Errorf("click_id is required")
+	}
+
+	if id, err := uuid.Parse(clickID); err == nil {
+		var click models.Click
+		if err := s.db.Preload("UserOffer.Offer").First(&click, "id = ?", id).Error; err == nil && click.UserOffer != nil {
+			return s.evaluateWindow(&click, convertedAt), nil
+		}
+	}
+
+	userOffer, err := s.findLegacyUserOffer(clickID)
+	if err != nil {
+		atomic.AddInt64(&attributionMetrics.Unresolved, 1)
+		return nil, err
+	}
+
+	atomic.AddInt64(&attributionMetrics.Legacy, 1)
+	return &AttributionResult{
+		UserOffer: userOffer,
+		Decision:  AttributionLegacy,
+		Reason:    "click_id_is_user_offer",
+	}, nil
+}
+
+// evaluateWindow checks the click age against the offer's attribution window
+func (s *AttributionService) evaluateWindow(click *models.Click, convertedAt time.Time) *AttributionResult {
+	result := &AttributionResult{
+		Click:     click,
+		UserOffer: click.UserOffer,
+		ClickAge:  convertedAt.Sub(click.ClickedAt),
+		Decision:  AttributionAttributed,
+	}
+
+	offer := click.UserOffer.Offer
+	if offer == nil {
+		atomic.AddInt64(&attributionMetrics.Attributed, 1)
+		return result
+	}
+
+	window := offer.AttributionWindow()
+	result.WindowDays = int(window / (24 * time.Hour))
+
+	if result.ClickAge <= window {
+		atomic.AddInt64(&attributionMetrics.Attributed, 1)
+		return result
+	}
+
+	result.Reason = fmt.Sprintf("click is %d days old, window is %d days",
+		int(result.ClickAge/(24*time.Hour)), result.WindowDays)
+
+	if offer.RejectsOutsideWindow() {
+		atomic.AddInt64(&attributionMetrics.Rejected, 1)
+		result.Decision = AttributionRejected
+	} else {
+		atomic.AddInt64(&attributionMetrics.Flagged, 1)
+		result.Decision = AttributionFlagged
+	}
+
+	return result
+}
+
+// findLegacyUserOffer resolves pre-click-ID links (UserOffer ID or tracking code)
+func (s *AttributionService) findLegacyUserOffer(clickID string) (*models.UserOffer, error) {
+	var userOffer models.UserOffer
+
+	if id, err := uuid.Parse(clickID); err == nil {
+		if err := s.db.Preload("Offer").First(&userOffer, "id = ?", id).Error; err == nil {
+			return &userOffer, nil
+		}
+	}
+
+	if err := s.db.Preload("Offer").Where("tracking_code = ? OR short_link = ?", clickID, clickID).First(&userOffer).Error; err == nil {
+		return &userOffer, nil
+	}
+
+	return nil, fmt.Errorf("user offer not found for click_id: %s", clickID)
+}
@@ -0,0 +1,69 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+)
+
+func TestAttributionEvaluateWindow(t *testing.T) {
+	s := &AttributionService{}
+	clickedAt := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+
+	tests := []struct {
+		name         string
+		offer        *models.Offer
+		age          time.Duration
+		wantDecision string
+		wantDays     int
+	}{
+		{"no offer loaded", nil, 400 * day, AttributionAttributed, 0},
+		{"same instant", &models.Offer{AttributionWindowDays: 7}, 0, AttributionAttributed, 7},
+		{"inside", &models.Offer{AttributionWindowDays: 7}, 3 * day, AttributionAttributed, 7},
+		{"on the edge", &models.Offer{AttributionWindowDays: 7}, 7 * day, AttributionAttributed, 7},
+		{"just outside, flag", &models.Offer{AttributionWindowDays: 7}, 7*day + time.Second, AttributionFlagged, 7},
+		{"just outside, reject", &models.Offer{AttributionWindowDays: 7, AttributionPolicy: models.AttributionPolicyReject}, 7*day + time.Second, AttributionRejected, 7},
+		{"default window inside", &models.Offer{}, 30 * day, AttributionAttributed, models.DefaultAttributionWindowDays},
+		{"default window outside", &models.Offer{}, 31 * day, AttributionFlagged, models.DefaultAttributionWindowDays},
+		{"negative days use default", &models.Offer{AttributionWindowDays: -1}, 29 * day, AttributionAttributed, models.DefaultAttributionWindowDays},
+		{"conversion before click", &models.Offer{AttributionWindowDays: 1}, -time.Hour, AttributionAttributed, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			click := &models.Click{
+				ClickedAt: clickedAt,
+				UserOffer: &models.UserOffer{Offer: tt.offer},
+			}
+			result := s.evaluateWindow(click, clickedAt.Add(tt.age))
+
+			if result.Decision != tt.wantDecision {
+				t.Errorf("decision = %q, want %q", result.Decision, tt.wantDecision)
+			}
+			if result.WindowDays != tt.wantDays {
+				t.Errorf("window days = %d, want %d", result.WindowDays, tt.wantDays)
+			}
+			if result.ClickAge != tt.age {
+				t.Errorf("click age = %v, want %v", result.ClickAge, tt.age)
+			}
+			if result.IsRejected() != (tt.wantDecision == AttributionRejected) || result.IsFlagged() != (tt.wantDecision == AttributionFlagged) {
+				t.Errorf("IsRejected/IsFlagged disagree with decision %q", result.Decision)
+			}
+			if (result.Reason != "") != (tt.wantDecision != AttributionAttributed) {
+				t.Errorf("reason = %q for decision %q", result.Reason, result.Decision)
+			}
+			if result.UserOffer != click.UserOffer || result.ClickID() == nil {
+				t.Error("result does not point back at the click")
+			}
+		})
+	}
+}
+
+func TestAttributionResultClickID(t *testing.T) {
+	legacy := &AttributionResult{Decision: AttributionLegacy}
+	if legacy.ClickID() != nil {
+		t.Error("legacy attribution returned a click ID")
+	}
+}
@@ -0,0 +1,846 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ============================================
+// BADGE ENGINE
+// ============================================
+
+// Badge engine events
+const (
+	BadgeEventClick      = "click"
+	BadgeEventConversion = "conversion"
+	BadgeEventTeamJoin   = "team_join"
+	BadgeEventContestWin = "contest_win"
+	BadgeEventManual     = "manual" // Evaluates every badge
+)
+
+// maxBadgePasses bounds re-evaluation after awards grant points that may
+// unlock points badges
+const maxBadgePasses = 3
+
+// BadgeEngineMetrics tracks badge engine activity
+type BadgeEngineMetrics struct {
+	EventsRecorded   int64
+	UsersEvaluated   int64
+	BadgesAwarded    int64
+	EvaluationErrors int64
+}
+
+var badgeEngineMetrics = &BadgeEngineMetrics{}
+
+// GetBadgeEngineMetrics returns badge engine metrics
+func GetBadgeEngineMetrics() *BadgeEngineMetrics {
+	return &BadgeEngineMetrics{
+		EventsRecorded:   atomic.LoadInt64(&badgeEngineMetrics.EventsRecorded),
+		UsersEvaluated:   atomic.LoadInt64(&badgeEngineMetrics.UsersEvaluated),
+		BadgesAwarded:    atomic.LoadInt64(&badgeEngineMetrics.BadgesAwarded),
+		EvaluationErrors: atomic.LoadInt64(&badgeEngineMetrics.EvaluationErrors),
+	}
+}
+
+// eventSet is a set of badge engine events
+type eventSet map[string]struct{}
+
+// addBadgeEvent adds an event to a set in a map of sets
+func addBadgeEvent[K comparable](sets map[K]eventSet, key K, event string) {
+	if sets[key] == nil {
+		sets[key] = eventSet{}
+	}
+	sets[key][event] = struct{}{}
+}
+
+// BadgeEngine awards badges as clicks, conversions, team joins and contest
+// wins come in. Events only mark promoters as dirty; a background loop
+// evaluates the badges each event can affect, like the contest engine.
+// Awards are idempotent (one per promoter and badge), grant the badge's
+// points, are audited in badge_awards and show up as unseen notifications.
+type BadgeEngine struct {
+	db *gorm.DB
+
+	mu              sync.Mutex
+	dirtyUserOffers map[uuid.UUID]eventSet
+	dirtyUsers      map[uuid.UUID]eventSet
+	dirtyTeams      map[uuid.UUID]eventSet
+
+	observability *ObservabilityService
+
+	// Configuration
+	flushInterval time.Duration
+
+	// State
+	isRunning bool
+	stopChan  chan struct{}
+	wg        sync.WaitGroup
+}
+
+var (
+	badgeEngineInstance *BadgeEngine
+	badgeEngineOnce     sync.Once
+)
+
+// NewBadgeEngine creates a new badge engine
+func NewBadgeEngine(db *gorm.DB) *BadgeEngine {
+	return &BadgeEngine{
+		db:              db,
+		dirtyUserOffers: make(map[uuid.UUID]eventSet),
+		dirtyUsers:      make(map[uuid.UUID]eventSet),
+		dirtyTeams:      make(map[uuid.UUID]eventSet),
+		observability:   NewObservabilityService(),
+		flushInterval:   30 * time.Second,
+		stopChan:        make(chan struct{}),
+	}
+}
+
+// GetBadgeEngine returns the singleton badge engine
+func GetBadgeEngine(db *gorm.DB) *BadgeEngine {
+	badgeEngineOnce.Do(func() {
+		badgeEngineInstance = NewBadgeEngine(db)
+	})
+	return badgeEngineInstance
+}
+
+// ============================================
+// EVENT INGESTION
+// ============================================
+
+// RecordClick notes a click recorded on a user offer
+func (e *BadgeEngine) RecordClick(userOfferID uuid.UUID) {
+	e.recordUserOffer(userOfferID, BadgeEventClick)
+}
+
+// RecordConversion notes a conversion created or approved on a user offer
+func (e *BadgeEngine) RecordConversion(userOfferID uuid.UUID) {
+	e.recordUserOffer(userOfferID, BadgeEventConversion)
+}
+
+// RecordTeamJoin notes that a promoter joined a team; every member is
+// evaluated, since team milestones are shared
+func (e *BadgeEngine) RecordTeamJoin(teamID uuid.UUID) {
+	if e == nil || teamID == uuid.Nil {
+		return
+	}
+	atomic.AddInt64(&badgeEngineMetrics.EventsRecorded, 1)
+
+	e.mu.Lock()
+	addBadgeEvent(e.dirtyTeams, teamID, BadgeEventTeamJoin)
+	e.mu.Unlock()
+}
+
+// RecordContestWin notes a contest won by a promoter or a team
+func (e *BadgeEngine) RecordContestWin(userID, teamID *uuid.UUID) {
+	if e == nil {
+		return
+	}
+	atomic.AddInt64(&badgeEngineMetrics.EventsRecorded, 1)
+
+	e.mu.Lock()
+	if userID != nil {
+		addBadgeEvent(e.dirtyUsers, *userID, BadgeEventContestWin)
+	}
+	if teamID != nil {
+		addBadgeEvent(e.dirtyTeams, *teamID, BadgeEventContestWin)
+	}
+	e.mu.Unlock()
+}
+
+// recordUserOffer marks a user offer's promoter as dirty
+func (e *BadgeEngine) recordUserOffer(userOfferID uuid.UUID, event string) {
+	if e == nil || userOfferID == uuid.Nil {
+		return
+	}
+	atomic.AddInt64(&badgeEngineMetrics.EventsRecorded, 1)
+
+	e.mu.Lock()
+	addBadgeEvent(e.dirtyUserOffers, userOfferID, event)
+	e.mu.Unlock()
+}
+
+// ============================================
+// WORKER
+// ============================================
+
+// Start starts the badge engine background loop
+func (e *BadgeEngine) Start() {
+	if e.isRunning {
+		return
+	}
+	e.isRunning = true
+
+	e.wg.Add(1)
+	go e.worker()
+}
+
+// Stop stops the badge engine, evaluating pending events first
+func (e *BadgeEngine) Stop() {
+	if !e.isRunning {
+		return
+	}
+	e.isRunning = false
+	close(e.stopChan)
+	e.wg.Wait()
+}
+
+// worker evaluates dirty promoters on every flush
+func (e *BadgeEngine) worker() {
+	defer e.wg.Done()
+
+	ticker := time.NewTicker(e.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-e.stopChan:
+			e.Flush()
+			return
+		case <-ticker.C:
+			e.Flush()
+		}
+	}
+}
+
+// Flush evaluates every promoter touched since the last flush
+func (e *BadgeEngine) Flush() {
+	e.mu.Lock()
+	dirtyUserOffers, dirtyUsers, dirtyTeams := e.dirtyUserOffers, e.dirtyUsers, e.dirtyTeams
+	e.dirtyUserOffers = make(map[uuid.UUID]eventSet)
+	e.dirtyUsers = make(map[uuid.UUID]eventSet)
+	e.dirtyTeams = make(map[uuid.UUID]eventSet)
+	e.mu.Unlock()
+
+	if len(dirtyUserOffers) == 0 && len(dirtyUsers) == 0 && len(dirtyTeams) == 0 {
+		return
+	}
+
+	badges, err := e.loadBadges()
+	if err != nil {
+		atomic.AddInt64(&badgeEngineMetrics.EvaluationErrors, 1)
+		fmt.Printf("[Badges] Failed to load badges: %v\n", err)
+		return
+	}
+	if len(badges) == 0 {
+		return
+	}
+
+	users, err := e.resolveDirtyUsers(dirtyUserOffers, dirtyUsers, dirtyTeams, badges)
+	if err != nil {
+		atomic.AddInt64(&badgeEngineMetrics.EvaluationErrors, 1)
+		fmt.Printf("[Badges] Failed to resolve promoters: %v\n", err)
+		return
+	}
+
+	for userID, events := range users {
+		if _, err := e.evaluate(userID, events, badges); err != nil {
+			atomic.AddInt64(&badgeEngineMetrics.EvaluationErrors, 1)
+			fmt.Printf("[Badges] Failed to evaluate badges for %s: %v\n", userID, err)
+		}
+	}
+}
+
+// resolveDirtyUsers maps dirty user offers and teams to their promoters.
+// Clicks and conversions of team members also dirty their teammates when a
+// team milestone badge could be affected.
+func (e *BadgeEngine) resolveDirtyUsers(userOffers, users, teams map[uuid.UUID]eventSet, badges []*parsedBadge) (map[uuid.UUID]eventSet, error) {
+	result := make(map[uuid.UUID]eventSet, len(userOffers)+len(users))
+	for userID, events := range users {
+		for event := range events {
+			addBadgeEvent(result, userID, event)
+		}
+	}
+
+	if len(userOffers) > 0 {
+		ids := make([]uuid.UUID, 0, len(userOffers))
+		for id := range userOffers {
+			ids = append(ids, id)
+		}
+		var rows []struct {
+			ID     uuid.UUID
+			UserID uuid.UUID
+		}
+		if err := e.db.Model(&models.UserOffer{}).Select("id, user_id").Where("id IN ?", ids).Scan(&rows).Error; err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			for event := range userOffers[row.ID] {
+				addBadgeEvent(result, row.UserID, event)
+			}
+		}
+	}
+
+	// Team milestones on clicks and conversions apply to the whole team
+	teamEvents := make(map[string]bool)
+	for _, badge := range badges {
+		collectTeamMilestoneEvents(badge.criteria, teamEvents)
+	}
+	if len(teamEvents) > 0 && len(result) > 0 {
+		userIDs := make([]uuid.UUID, 0, len(result))
+		for userID, events := range result {
+			for event := range events {
+				if teamEvents[event] {
+					userIDs = append(userIDs, userID)
+					break
+				}
+			}
+		}
+		if len(userIDs) > 0 {
+			var memberships []models.TeamMember
+			if err := e.db.Select("user_id", "team_id").
+				Where("user_id IN ? AND status = ?", userIDs, models.TeamMemberStatusActive).
+				Find(&memberships).Error; err != nil {
+				return nil, err
+			}
+			for _, membership := range memberships {
+				for event := range result[membership.UserID] {
+					if teamEvents[event] {
+						addBadgeEvent(teams, membership.TeamID, event)
+					}
+				}
+			}
+		}
+	}
+
+	if len(teams) > 0 {
+		teamIDs := make([]uuid.UUID, 0, len(teams))
+		for id := range teams {
+			teamIDs = append(teamIDs, id)
+		}
+		var members []models.TeamMember
+		if err := e.db.Select("user_id", "team_id").
+			Where("team_id IN ? AND status = ?", teamIDs, models.TeamMemberStatusActive).
+			Find(&members).Error; err != nil {
+			return nil, err
+		}
+		for _, member := range members {
+			for event := range teams[member.TeamID] {
+				addBadgeEvent(result, member.UserID, event)
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// ============================================
+// EVALUATION
+// ============================================
+
+// parsedBadge is a badge with its parsed criteria and the events that can
+// change its outcome
+type parsedBadge struct {
+	badge    models.Badge
+	criteria *models.BadgeCriteria
+	events   map[string]bool
+}
+
+// loadBadges loads every badge with valid criteria
+func (e *BadgeEngine) loadBadges() ([]*parsedBadge, error) {
+	var badges []models.Badge
+	if err := e.db.Find(&badges).Error; err != nil {
+		return nil, err
+	}
+
+	parsed := make([]*parsedBadge, 0, len(badges))
+	for _, badge := range badges {
+		criteria, err := models.ParseBadgeCriteria(badge.Criteria, badge.RequiredValue)
+		if err != nil {
+			fmt.Printf("[Badges] Skipping badge %s (%s): %v\n", badge.ID, badge.Name, err)
+			continue
+		}
+		events := make(map[string]bool)
+		collectBadgeEvents(criteria, events)
+		parsed = append(parsed, &parsedBadge{badge: badge, criteria: criteria, events: events})
+	}
+	return parsed, nil
+}
+
+// collectBadgeEvents adds the events that can change a criteria's outcome
+func collectBadgeEvents(c *models.BadgeCriteria, events map[string]bool) {
+	for i := range c.All {
+		collectBadgeEvents(&c.All[i], events)
+	}
+	for i := range c.Any {
+		collectBadgeEvents(&c.Any[i], events)
+	}
+
+	switch c.Type {
+	case models.BadgeCriterionThreshold, models.BadgeCriterionStreak, models.BadgeCriterionGoal:
+		switch c.Metric {
+		case models.BadgeMetricClicks:
+			events[BadgeEventClick] = true
+		case models.BadgeMetricConversions, models.BadgeMetricEarnings:
+			events[BadgeEventConversion] = true
+		case models.BadgeMetricPoints:
+			// Points come from badges themselves and elsewhere
+			events[BadgeEventClick] = true
+			events[BadgeEventConversion] = true
+			events[BadgeEventTeamJoin] = true
+			events[BadgeEventContestWin] = true
+		}
+	case models.BadgeCriterionNewCategory:
+		events[BadgeEventConversion] = true
+	case models.BadgeCriterionTeamMilestone:
+		events[BadgeEventTeamJoin] = true
+		switch c.Metric {
+		case models.BadgeMetricClicks:
+			events[BadgeEventClick] = true
+		case models.BadgeMetricConversions:
+			events[BadgeEventConversion] = true
+		}
+	case models.BadgeCriterionContestWins:
+		events[BadgeEventContestWin] = true
+	}
+}
+
+// collectTeamMilestoneEvents adds the member events that count towards team milestones
+func collectTeamMilestoneEvents(c *models.BadgeCriteria, events map[string]bool) {
+	for i := range c.All {
+		collectTeamMilestoneEvents(&c.All[i], events)
+	}
+	for i := range c.Any {
+		collectTeamMilestoneEvents(&c.Any[i], events)
+	}
+	if c.Type != models.BadgeCriterionTeamMilestone {
+		return
+	}
+	switch c.Metric {
+	case models.BadgeMetricClicks:
+		events[BadgeEventClick] = true
+	case models.BadgeMetricConversions:
+		events[BadgeEventConversion] = true
+	}
+}
+
+// EvaluateUser evaluates every badge for a promoter and awards those earned
+func (e *BadgeEngine) EvaluateUser(userID uuid.UUID) ([]models.UserBadge, error) {
+	badges, err := e.loadBadges()
+	if err != nil {
+		return nil, err
+	}
+	return e.evaluate(userID, eventSet{BadgeEventManual: {}}, badges)
+}
+
+// evaluate awards the unearned badges the events can affect, repeating while
+// awards grant points that may unlock more
+func (e *BadgeEngine) evaluate(userID uuid.UUID, events eventSet, badges []*parsedBadge) ([]models.UserBadge, error) {
+	atomic.AddInt64(&badgeEngineMetrics.UsersEvaluated, 1)
+
+	var earnedIDs []uuid.UUID
+	if err := e.db.Model(&models.UserBadge{}).Where("user_id = ?", userID).Pluck("badge_id", &earnedIDs).Error; err != nil {
+		return nil, err
+	}
+	earned := make(map[uuid.UUID]bool, len(earnedIDs))
+	for _, id := range earnedIDs {
+		earned[id] = true
+	}
+
+	trigger := BadgeEventManual
+	for event := range events {
+		if event != BadgeEventManual {
+			trigger = event
+			break
+		}
+	}
+	_, manual := events[BadgeEventManual]
+
+	var awarded []models.UserBadge
+	for pass := 0; pass < maxBadgePasses; pass++ {
+		var user models.AfftokUser
+		if err := e.db.First(&user, "id = ?", userID).Error; err != nil {
+			return awarded, err
+		}
+		ctx := newBadgeContext(e.db, &user)
+
+		awardedThisPass := 0
+		for _, badge := range badges {
+			if earned[badge.badge.ID] || !(manual || pass > 0 || badge.matches(events)) {
+				continue
+			}
+
+			met, err := ctx.evaluate(badge.criteria)
+			if err != nil {
+				return awarded, fmt.Errorf("badge %s: %w", badge.badge.ID, err)
+			}
+			if !met {
+				continue
+			}
+
+			userBadge, ok, err := e.award(&user, &badge.badge, trigger, ctx.evidence)
+			if err != nil {
+				return awarded, err
+			}
+			earned[badge.badge.ID] = true
+			if ok {
+				awarded = append(awarded, *userBadge)
+				awardedThisPass++
+			}
+		}
+
+		if awardedThisPass == 0 {
+			break
+		}
+	}
+	return awarded, nil
+}
+
+// matches reports whether any of the events can change the badge's outcome
+func (b *parsedBadge) matches(events eventSet) bool {
+	for event := range events {
+		if b.events[event] {
+			return true
+		}
+	}
+	return false
+}
+
+// award grants a badge once: the user badge, its points and the audit entry
+// are written together. ok is false if the badge was already awarded.
+func (e *BadgeEngine) award(user *models.AfftokUser, badge *models.Badge, trigger string, evidence map[string]interface{}) (*models.UserBadge, bool, error) {
+	points := badge.Points
+	if points == 0 {
+		points = badge.PointsReward
+	}
+	evidenceJSON, _ := json.Marshal(evidence)
+
+	userBadge := &models.UserBadge{
+		ID:       uuid.New(),
+		UserID:   user.ID,
+		BadgeID:  badge.ID,
+		EarnedAt: time.Now().UTC(),
+	}
+	created := false
+
+	err := e.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(userBadge)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		created = true
+
+		if points != 0 {
+			if err := tx.Model(&models.AfftokUser{}).
+				Where("id = ?", user.ID).
+				UpdateColumn("points", gorm.Expr("points + ?", points)).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Create(&models.BadgeAward{
+			ID:            uuid.New(),
+			UserID:        user.ID,
+			BadgeID:       badge.ID,
+			UserBadgeID:   userBadge.ID,
+			Trigger:       trigger,
+			PointsAwarded: points,
+			Evidence:      evidenceJSON,
+			CreatedAt:     userBadge.EarnedAt,
+		}).Error
+	})
+	if err != nil || !created {
+		return nil, false, err
+	}
+
+	atomic.AddInt64(&badgeEngineMetrics.BadgesAwarded, 1)
+	e.observability.Log(LogEvent{
+		Category: "badge_awarded",
+		Level:    LogLevelInfo,
+		Message:  "Badge awarded",
+		UserID:   user.ID.String(),
+		Metadata: map[string]interface{}{
+			"badge_id":   badge.ID.String(),
+			"badge_name": badge.Name,
+			"trigger":    trigger,
+			"points":     points,
+		},
+	})
+	fmt.Printf("[Badges] Awarded %q to %s (%s, +%d points)\n", badge.Name, user.Username, trigger, points)
+
+	userBadge.Badge = badge
+	return userBadge, true, nil
+}
+
+// ============================================
+// CRITERIA
+// ============================================
+
+// badgeContext evaluates criteria for one promoter, caching each measured
+// value so badges sharing a criterion don't query twice
+type badgeContext struct {
+	db       *gorm.DB
+	user     *models.AfftokUser
+	now      time.Time
+	values   map[string]int64
+	evidence map[string]interface{}
+
+	teamLoaded bool
+	teamID     *uuid.UUID
+}
+
+// newBadgeContext creates an evaluation context for a promoter
+func newBadgeContext(db *gorm.DB, user *models.AfftokUser) *badgeContext {
+	return &badgeContext{
+		db:       db,
+		user:     user,
+		now:      time.Now().UTC(),
+		values:   make(map[string]int64),
+		evidence: make(map[string]interface{}),
+	}
+}
+
+// evaluate reports whether a criteria tree is met
+func (ctx *badgeContext) evaluate(c *models.BadgeCriteria) (bool, error) {
+	if len(c.All) > 0 {
+		for i := range c.All {
+			met, err := ctx.evaluate(&c.All[i])
+			if err != nil || !met {
+				return false, err
+			}
+		}
+		return true, nil
+	}
+	if len(c.Any) > 0 {
+		for i := range c.Any {
+			met, err := ctx.evaluate(&c.Any[i])
+			if err != nil || met {
+				return met, err
+			}
+		}
+		return false, nil
+	}
+
+	key, _ := json.Marshal(c)
+	value, ok := ctx.values[string(key)]
+	if !ok {
+		var err error
+		if value, err = ctx.measure(c); err != nil {
+			return false, err
+		}
+		ctx.values[string(key)] = value
+		ctx.evidence[string(key)] = value
+	}
+	return value >= ctx.target(c), nil
+}
+
+// target returns the value a criterion must reach
+func (ctx *badgeContext) target(c *models.BadgeCriteria) int64 {
+	switch c.Type {
+	case models.BadgeCriterionStreak:
+		return int64(c.Days)
+	case models.BadgeCriterionNewCategory:
+		if c.Category != "" || c.Value < 1 {
+			return 1
+		}
+	}
+	return int64(c.Value)
+}
+
+// measure computes a criterion's current value
+func (ctx *badgeContext) measure(c *models.BadgeCriteria) (int64, error) {
+	switch c.Type {
+	case models.BadgeCriterionThreshold:
+		switch c.Metric {
+		case models.BadgeMetricClicks:
+			return int64(ctx.user.TotalClicks), nil
+		case models.BadgeMetricConversions:
+			return int64(ctx.user.TotalConversions), nil
+		case models.BadgeMetricEarnings:
+			return int64(ctx.user.TotalEarnings), nil
+		case models.BadgeMetricPoints:
+			return int64(ctx.user.Points), nil
+		}
+	case models.BadgeCriterionStreak:
+		return ctx.streak(c)
+	case models.BadgeCriterionGoal:
+		from, until := ctx.goalWindow(c)
+		return ctx.sumMetric(c.Metric, from, until)
+	case models.BadgeCriterionNewCategory:
+		return ctx.categories(c.Category)
+	case models.BadgeCriterionTeamMilestone:
+		return ctx.teamMetric(c.Metric)
+	case models.BadgeCriterionContestWins:
+		return ctx.contestWins()
+	}
+	return 0, nil
+}
+
+// goalWindow returns the window a goal is measured in
+func (ctx *badgeContext) goalWindow(c *models.BadgeCriteria) (time.Time, time.Time) {
+	if c.WithinDays > 0 {
+		return ctx.now.AddDate(0, 0, -c.WithinDays), ctx.now
+	}
+	from, until := time.Time{}, ctx.now
+	if c.From != nil {
+		from = *c.From
+	}
+	if c.Until != nil && c.Until.Before(until) {
+		until = *c.Until
+	}
+	return from, until
+}
+
+// metricQuery returns the promoter's clicks or counted conversions
+func (ctx *badgeContext) metricQuery(metric string) (*gorm.DB, string) {
+	if metric == models.BadgeMetricClicks {
+		return ctx.db.Table("clicks").
+			Joins("JOIN user_offers ON user_offers.id = clicks.user_offer_id").
+			Where("user_offers.user_id = ?", ctx.user.ID), "clicks.clicked_at"
+	}
+
+	query := ctx.db.Table("conversions").
+		Joins("JOIN user_offers ON user_offers.id = conversions.user_offer_id").
+		Where("user_offers.user_id = ?", ctx.user.ID)
+	if metric == models.BadgeMetricEarnings {
+		query = query.Where("conversions.status IN ?", []string{models.ConversionStatusApproved, models.ConversionStatusPaid})
+	} else {
+		query = query.Where("conversions.status NOT IN ?", contestExcludedConversionStatuses)
+	}
+	return query, "conversions.converted_at"
+}
+
+// sumMetric counts clicks or conversions, or sums earnings, in a window
+func (ctx *badgeContext) sumMetric(metric string, from, until time.Time) (int64, error) {
+	query, timeColumn := ctx.metricQuery(metric)
+	query = query.Where(timeColumn+" >= ? AND "+timeColumn+" < ?", from, until)
+
+	selectExpr := "COUNT(*)"
+	if metric == models.BadgeMetricEarnings {
+		selectExpr = "COALESCE(SUM(conversions.commission), 0)"
+	}
+	var total int64
+	err := query.Select(selectExpr).Scan(&total).Error
+	return total, err
+}
+
+// streak counts the consecutive days, up to c.Days and ending today, with at
+// least MinPerDay clicks or conversions
+func (ctx *badgeContext) streak(c *models.BadgeCriteria) (int64, error) {
+	minPerDay := c.MinPerDay
+	if minPerDay < 1 {
+		minPerDay = 1
+	}
+	today := time.Date(ctx.now.Year(), ctx.now.Month(), ctx.now.Day(), 0, 0, 0, 0, time.UTC)
+	from := today.AddDate(0, 0, -(c.Days - 1))
+
+	query, timeColumn := ctx.metricQuery(c.Metric)
+	var days []string
+	if err := query.
+		Select("TO_CHAR("+timeColumn+" AT TIME ZONE 'UTC', 'YYYY-MM-DD') AS day").
+		Where(timeColumn+" >= ?", from).
+		Group("day").
+		Having("COUNT(*) >= ?", minPerDay).
+		Pluck("day", &days).Error; err != nil {
+		return 0, err
+	}
+
+	active := make(map[string]bool, len(days))
+	for _, day := range days {
+		active[day] = true
+	}
+	return streakDays(active, today, from), nil
+}
+
+// streakDays counts the consecutive active days (YYYY-MM-DD) from today back
+// to from
+func streakDays(active map[string]bool, today, from time.Time) int64 {
+	var streak int64
+	for day := today; !day.Before(from); day = day.AddDate(0, 0, -1) {
+		if !active[day.Format("2006-01-02")] {
+			break
+		}
+		streak++
+	}
+	return streak
+}
+
+// categories counts the promoter's conversions in a category, or the
+// distinct offer categories they have converted in
+func (ctx *badgeContext) categories(category string) (int64, error) {
+	query, _ := ctx.metricQuery(models.BadgeMetricConversions)
+	query = query.Joins("JOIN offers ON offers.id = user_offers.offer_id").
+		Where("COALESCE(offers.category, '') <> ''")
+
+	var total int64
+	if category != "" {
+		err := query.Where("LOWER(offers.category) = LOWER(?)", category).Count(&total).Error
+		return total, err
+	}
+	err := query.Select("COUNT(DISTINCT LOWER(offers.category))").Scan(&total).Error
+	return total, err
+}
+
+// team returns the promoter's active team, if any
+func (ctx *badgeContext) team() (*uuid.UUID, error) {
+	if ctx.teamLoaded {
+		return ctx.teamID, nil
+	}
+	var member models.TeamMember
+	err := ctx.db.Select("team_id").
+		Where("user_id = ? AND status = ?", ctx.user.ID, models.TeamMemberStatusActive).
+		First(&member).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+	ctx.teamLoaded = true
+	if err == nil {
+		ctx.teamID = &member.TeamID
+	}
+	return ctx.teamID, nil
+}
+
+// teamMetric returns the promoter's team member count or total clicks or
+// conversions
+func (ctx *badgeContext) teamMetric(metric string) (int64, error) {
+	teamID, err := ctx.team()
+	if err != nil || teamID == nil {
+		return 0, err
+	}
+
+	members := ctx.db.Model(&models.TeamMember{}).
+		Where("team_id = ? AND status = ?", *teamID, models.TeamMemberStatusActive)
+	var total int64
+	switch metric {
+	case models.BadgeMetricMembers:
+		err = members.Count(&total).Error
+	case models.BadgeMetricClicks, models.BadgeMetricConversions:
+		column := "total_clicks"
+		if metric == models.BadgeMetricConversions {
+			column = "total_conversions"
+		}
+		err = ctx.db.Model(&models.AfftokUser{}).
+			Select("COALESCE(SUM("+column+"), 0)").
+			Where("id IN (?)", members.Select("user_id")).
+			Scan(&total).Error
+	}
+	return total, err
+}
+
+// contestWins counts contests the promoter won, alone or with their team
+func (ctx *badgeContext) contestWins() (int64, error) {
+	teamID, err := ctx.team()
+	if err != nil {
+		return 0, err
+	}
+
+	query := ctx.db.Model(&models.ContestParticipant{}).Where("status = ?", models.ParticipantStatusWinner)
+	if teamID != nil {
+		query = query.Where("user_id = ? OR team_id = ?", ctx.user.ID, *teamID)
+	} else {
+		query = query.Where("user_id = ?", ctx.user.ID)
+	}
+	var total int64
+	err = query.Count(&total).Error
+	return total, err
+}
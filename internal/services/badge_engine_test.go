@@ -0,0 +1,132 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+)
+
+func TestStreakDays(t *testing.T) {
+	date := func(month time.Month, day int) time.Time {
+		return time.Date(2026, month, day, 0, 0, 0, 0, time.UTC)
+	}
+	active := func(dates ...string) map[string]bool {
+		set := make(map[string]bool, len(dates))
+		for _, d := range dates {
+			set[d] = true
+		}
+		return set
+	}
+
+	tests := []struct {
+		name   string
+		active map[string]bool
+		today  time.Time
+		days   int
+		want   int64
+	}{
+		{"no activity", active(), date(10, 16), 7, 0},
+		{"today only", active("2026-10-16"), date(10, 16), 7, 1},
+		{"inactive today", active("2026-10-15", "2026-10-14"), date(10, 16), 7, 0},
+		{"broken streak", active("2026-10-16", "2026-10-15", "2026-10-13"), date(10, 16), 7, 2},
+		{"capped at the streak length", active("2026-10-16", "2026-10-15", "2026-10-14", "2026-10-13"), date(10, 16), 3, 3},
+		{"across a month", active("2026-10-02", "2026-10-01", "2026-09-30"), date(10, 2), 3, 3},
+		{"single day streak", active("2026-10-16", "2026-10-15"), date(10, 16), 1, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from := tt.today.AddDate(0, 0, -(tt.days - 1))
+			if got := streakDays(tt.active, tt.today, from); got != tt.want {
+				t.Errorf("streakDays = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBadgeGoalWindow(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	ctx := &badgeContext{now: now}
+	at := func(month time.Month, day int) *time.Time {
+		t := time.Date(2026, month, day, 0, 0, 0, 0, time.UTC)
+		return &t
+	}
+
+	tests := []struct {
+		name      string
+		criteria  models.BadgeCriteria
+		wantFrom  time.Time
+		wantUntil time.Time
+	}{
+		{"rolling window", models.BadgeCriteria{WithinDays: 30}, now.AddDate(0, 0, -30), now},
+		{"fixed window", models.BadgeCriteria{From: at(9, 1), Until: at(10, 1)}, *at(9, 1), *at(10, 1)},
+		{"window still open", models.BadgeCriteria{From: at(10, 1), Until: at(11, 1)}, *at(10, 1), now},
+		{"open ended", models.BadgeCriteria{From: at(10, 1)}, *at(10, 1), now},
+		{"until only", models.BadgeCriteria{Until: at(10, 1)}, time.Time{}, *at(10, 1)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from, until := ctx.goalWindow(&tt.criteria)
+			if !from.Equal(tt.wantFrom) || !until.Equal(tt.wantUntil) {
+				t.Errorf("window = [%v, %v), want [%v, %v)", from, until, tt.wantFrom, tt.wantUntil)
+			}
+		})
+	}
+}
+
+func TestBadgeCriteriaEvaluate(t *testing.T) {
+	user := &models.AfftokUser{TotalClicks: 120, TotalConversions: 8, TotalEarnings: 5000, Points: 40}
+
+	tests := []struct {
+		name     string
+		criteria string
+		want     bool
+	}{
+		{"legacy met", "conversions", true},
+		{"threshold met exactly", `{"type": "threshold", "metric": "clicks", "value": 120}`, true},
+		{"threshold missed", `{"type": "threshold", "metric": "points", "value": 41}`, false},
+		{"all met", `{"all": [{"type": "threshold", "metric": "clicks", "value": 100}, {"type": "threshold", "metric": "earnings", "value": 5000}]}`, true},
+		{"all missed", `{"all": [{"type": "threshold", "metric": "clicks", "value": 100}, {"type": "threshold", "metric": "conversions", "value": 9}]}`, false},
+		{"any met", `{"any": [{"type": "threshold", "metric": "conversions", "value": 9}, {"type": "threshold", "metric": "points", "value": 40}]}`, true},
+		{"any missed", `{"any": [{"type": "threshold", "metric": "conversions", "value": 9}, {"type": "threshold", "metric": "points", "value": 50}]}`, false},
+		{"nested", `{"any": [{"all": [{"type": "threshold", "metric": "clicks", "value": 1}, {"type": "threshold", "metric": "points", "value": 1}]}]}`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			criteria, err := models.ParseBadgeCriteria(tt.criteria, 8)
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			ctx := newBadgeContext(nil, user)
+			met, err := ctx.evaluate(criteria)
+			if err != nil {
+				t.Fatalf("evaluate: %v", err)
+			}
+			if met != tt.want {
+				t.Errorf("met = %v, want %v", met, tt.want)
+			}
+		})
+	}
+}
+
+func TestBadgeCriteriaTarget(t *testing.T) {
+	ctx := &badgeContext{}
+	tests := []struct {
+		criteria models.BadgeCriteria
+		want     int64
+	}{
+		{models.BadgeCriteria{Type: models.BadgeCriterionThreshold, Value: 50}, 50},
+		{models.BadgeCriteria{Type: models.BadgeCriterionStreak, Days: 7, Value: 3}, 7},
+		{models.BadgeCriteria{Type: models.BadgeCriterionNewCategory}, 1},
+		{models.BadgeCriteria{Type: models.BadgeCriterionNewCategory, Value: 3}, 3},
+		{models.BadgeCriteria{Type: models.BadgeCriterionNewCategory, Category: "fintech", Value: 3}, 1},
+	}
+	for _, tt := range tests {
+		if got := ctx.target(&tt.criteria); got != tt.want {
+			t.Errorf("target(%+v) = %d, want %d", tt.criteria, got, tt.want)
+		}
+	}
+}
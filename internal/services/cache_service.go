@@ -60,6 +60,7 @@ type CacheService struct {
 	
 	ctx          context.Context
 	cancel       context.CancelFunc
+	flushDone    chan struct{} // Closed when flushWorker exits
 }
 
 type writeOp struct {
@@ -90,6 +91,7 @@ func NewCacheService() *CacheService {
 			flushInterval: 100 * time.Millisecond,
 			ctx:           ctx,
 			cancel:        cancel,
+			flushDone:     make(chan struct{}),
 		}
 		
 		// Start background flush worker
@@ -419,6 +421,8 @@ func (c *CacheService) setRedis(key string, value interface{}, ttl time.Duration
 
 // flushWorker batches writes to Redis
 func (c *CacheService) flushWorker() {
+	defer close(c.flushDone)
+
 	ticker := time.NewTicker(c.flushInterval)
 	defer ticker.Stop()
 
@@ -452,7 +456,8 @@ func (c *CacheService) flushBatch(batch []writeOp) {
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(c.ctx, 500*time.Millisecond)
+	// Not derived from c.ctx: the final flush runs after it is cancelled
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
 	defer cancel()
 
 	pipe := cache.RedisClient.Pipeline()
@@ -500,6 +505,50 @@ func (c *CacheService) Stop() {
 	c.cancel()
 }
 
+// Drain stops the write-behind worker and flushes the buffered writes to
+// Redis. Returns the writes dropped because ctx expired or Redis is gone.
+func (c *CacheService) Drain(ctx context.Context) (int, error) {
+	c.cancel()
+	select {
+	case <-c.flushDone:
+	case <-ctx.Done():
+	}
+
+	batch := make([]writeOp, 0, c.bufferSize)
+	dropped := 0
+	for done := false; !done; {
+		select {
+		case op := <-c.writeBuffer:
+			if ctx.Err() != nil || cache.RedisClient == nil {
+				dropped++
+				continue
+			}
+			batch = append(batch, op)
+			if len(batch) >= c.bufferSize {
+				c.flushBatch(batch)
+				batch = batch[:0]
+			}
+		default:
+			done = true
+		}
+	}
+	c.flushBatch(batch)
+
+	if dropped > 0 {
+		// Cached values are rebuilt from the database on the next read
+		fmt.Printf("[CacheService] %d buffered cache writes dropped on shutdown\n", dropped)
+	}
+	return dropped, nil
+}
+
+// DrainCacheService drains the cache service if it was started
+func DrainCacheService(ctx context.Context) (int, error) {
+	if cacheServiceInstance == nil {
+		return 0, nil
+	}
+	return cacheServiceInstance.Drain(ctx)
+}
+
 // GetStats returns cache statistics
 func (c *CacheService) GetCacheStats() map[string]interface{} {
 	localCount := 0
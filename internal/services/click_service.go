@@ -12,20 +12,72 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type ClickService struct {
-	linkService *LinkService
+	linkService  *LinkService
+	geoIPService *GeoIPService
 }
 
 func NewClickService() *ClickService {
 	return &ClickService{
-		linkService: NewLinkService(),
+		linkService:  NewLinkService(),
+		geoIPService: GetGeoIPService(),
 	}
 }
 
 // TrackClick records a click on an affiliate link with atomic operations
 func (s *ClickService) TrackClick(c *gin.Context, userOfferID uuid.UUID) (*models.Click, error) {
+	click, existing := s.prepareClick(c, userOfferID, nil)
+	if existing {
+		return click, nil
+	}
+
+	if _, err := s.PersistClick(click); err != nil {
+		return nil, err
+	}
+	return click, nil
+}
+
+// TrackClickForTenant records a click, going through the zero-drop WAL when it
+// is enabled for the tenant. WAL-first clicks are persisted asynchronously;
+// the returned click carries the ID they will be stored under. params are the
+// tracking link's sub-IDs and passthrough parameters (may be nil).
+func (s *ClickService) TrackClickForTenant(c *gin.Context, userOfferID uuid.UUID, tenantID string, params *TrackingParams) (*models.Click, error) {
+	click, existing := s.prepareClick(c, userOfferID, params)
+	if existing {
+		return click, nil
+	}
+
+	if err := s.RecordClick(click, tenantID); err != nil {
+		return nil, err
+	}
+	return click, nil
+}
+
+// RecordClick stores a prepared click, through the zero-drop WAL when it is
+// enabled for the tenant and synchronously otherwise
+func (s *ClickService) RecordClick(click *models.Click, tenantID string) error {
+	pipeline := GetZeroDropPipeline()
+	if pipeline.IsWALFirst(tenantID) {
+		data, err := toEventData(click)
+		if err == nil {
+			err = pipeline.Submit(tenantID, WALEventClick, data)
+		}
+		if err == nil {
+			return nil
+		}
+		fmt.Printf("[Click] WAL submit failed, writing synchronously: %v\n", err)
+	}
+
+	_, err := s.PersistClick(click)
+	return err
+}
+
+// prepareClick builds the click record for a request. If the click is a
+// duplicate of a recorded one, that click is returned with existing set.
+func (s *ClickService) prepareClick(c *gin.Context, userOfferID uuid.UUID, params *TrackingParams) (*models.Click, bool) {
 	// Extract device info from user agent
 	userAgent := c.Request.UserAgent()
 	device, browser, os := parseUserAgent(userAgent)
@@ -36,6 +88,9 @@ func (s *ClickService) TrackClick(c *gin.Context, userOfferID uuid.UUID) (*model
 	// Get referrer
 	referrer := c.Request.Referer()
 
+	// Resolve location
+	geo := s.geoIPService.ResolveRequest(c)
+
 	// Generate click fingerprint for deduplication
 	clickID := s.linkService.GenerateClickID(userOfferID, ipAddress, userAgent)
 
@@ -46,13 +101,12 @@ func (s *ClickService) TrackClick(c *gin.Context, userOfferID uuid.UUID) (*model
 		if err := database.DB.Where("user_offer_id = ? AND ip_address = ?", userOfferID, ipAddress).
 			Order("clicked_at DESC").
 			First(&existingClick).Error; err == nil {
-			return &existingClick, nil
+			return &existingClick, true
 		}
 		// If not found in DB, still proceed (Redis might be stale)
 	}
 
-	// Create click record
-	click := models.Click{
+	click := &models.Click{
 		ID:          uuid.New(),
 		UserOfferID: userOfferID,
 		IPAddress:   ipAddress,
@@ -61,25 +115,41 @@ func (s *ClickService) TrackClick(c *gin.Context, userOfferID uuid.UUID) (*model
 		Browser:     browser,
 		OS:          os,
 		Referrer:    referrer,
+		Country:     geo.CountryCode,
+		City:        geo.City,
 		ClickedAt:   time.Now().UTC(),
 	}
+	params.ApplyToClick(click)
+	return click, false
+}
+
+// PersistClick stores a click and bumps the click counters atomically. It is
+// idempotent on the click ID, so WAL replays and redelivered stream messages
+// don't double count; created is false if the click was already stored.
+func (s *ClickService) PersistClick(click *models.Click) (bool, error) {
+	created := false
 
 	// Use transaction for atomic updates
 	err := database.DB.Transaction(func(tx *gorm.DB) error {
 		// 1. Create click record
-		if err := tx.Create(&click).Error; err != nil {
-			return fmt.Errorf("failed to create click: %w", err)
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(click)
+		if result.Error != nil {
+			return fmt.Errorf("failed to create click: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return nil
 		}
+		created = true
 
 		// 2. Get user offer to find related IDs
 		var userOffer models.UserOffer
-		if err := tx.First(&userOffer, "id = ?", userOfferID).Error; err != nil {
+		if err := tx.First(&userOffer, "id = ?", click.UserOfferID).Error; err != nil {
 			return fmt.Errorf("user offer not found: %w", err)
 		}
 
 		// 3. Atomic increment on UserOffer (clicks counter + updated_at)
 		if err := tx.Model(&models.UserOffer{}).
-			Where("id = ?", userOfferID).
+			Where("id = ?", click.UserOfferID).
 			UpdateColumns(map[string]interface{}{
 				"total_clicks": gorm.Expr("total_clicks + 1"),
 				"updated_at":   time.Now().UTC(),
@@ -105,12 +175,44 @@ func (s *ClickService) TrackClick(c *gin.Context, userOfferID uuid.UUID) (*model
 	})
 
 	if err != nil {
-		return nil, err
+		return false, err
+	}
+
+	if created {
+		// Update Redis counters asynchronously (non-blocking)
+		go s.updateRedisCounters(click.UserOfferID, click.ID)
+		GetWebhookService(database.DB).EmitClick(*click)
+		GetLeaderboardService(database.DB).RecordClick(click.UserOfferID, click.ClickedAt)
+		GetBadgeEngine(database.DB).RecordClick(click.UserOfferID)
 	}
 
-	// Update Redis counters asynchronously (non-blocking)
-	go s.updateRedisCounters(userOfferID, click.ID)
+	return created, nil
+}
 
+// PersistClickEvent is the zero-drop processor for click events
+func (s *ClickService) PersistClickEvent(data map[string]interface{}) error {
+	var click models.Click
+	if err := fromEventData(data, &click); err != nil {
+		return fmt.Errorf("invalid click event: %w", err)
+	}
+	if click.ID == uuid.Nil || click.UserOfferID == uuid.Nil {
+		return fmt.Errorf("invalid click event: missing id or user_offer_id")
+	}
+
+	_, err := s.PersistClick(&click)
+	return err
+}
+
+// FindRecentClick returns the latest click from an IP on a user offer, used to
+// keep the same click_id when a duplicate click is redirected without recording
+func (s *ClickService) FindRecentClick(userOfferID uuid.UUID, ipAddress string, within time.Duration) (*models.Click, error) {
+	var click models.Click
+	if err := database.DB.Where("user_offer_id = ? AND ip_address = ? AND clicked_at > ?",
+		userOfferID, ipAddress, time.Now().UTC().Add(-within)).
+		Order("clicked_at DESC").
+		First(&click).Error; err != nil {
+		return nil, err
+	}
 	return &click, nil
 }
 
@@ -35,6 +35,7 @@ type ClickServiceV2 struct {
 	
 	ctx           context.Context
 	cancel        context.CancelFunc
+	flushDone     chan struct{} // Closed when clickFlushWorker exits
 }
 
 // ClickData represents click data for async processing
@@ -75,6 +76,7 @@ func NewClickServiceV2() *ClickServiceV2 {
 			counterBuffer: make(map[string]int64),
 			ctx:           ctx,
 			cancel:        cancel,
+			flushDone:     make(chan struct{}),
 		}
 		
 		// Start background workers
@@ -207,6 +209,8 @@ func (s *ClickServiceV2) updateRedisCounters(data *ClickData) {
 
 // clickFlushWorker batches click writes to database
 func (s *ClickServiceV2) clickFlushWorker() {
+	defer close(s.flushDone)
+
 	ticker := time.NewTicker(s.flushInterval)
 	defer ticker.Stop()
 
@@ -235,9 +239,9 @@ func (s *ClickServiceV2) clickFlushWorker() {
 }
 
 // flushClickBatch writes a batch of clicks to database
-func (s *ClickServiceV2) flushClickBatch(batch []*ClickData) {
+func (s *ClickServiceV2) flushClickBatch(batch []*ClickData) error {
 	if len(batch) == 0 {
-		return
+		return nil
 	}
 
 	start := time.Now()
@@ -247,20 +251,7 @@ func (s *ClickServiceV2) flushClickBatch(batch []*ClickData) {
 	counterUpdates := make(map[uuid.UUID]int)
 
 	for i, data := range batch {
-		clicks[i] = models.Click{
-			ID:          data.ID,
-			UserOfferID: data.UserOfferID,
-			IPAddress:   data.IP,
-			UserAgent:   data.UserAgent,
-			Device:      data.Device,
-			Browser:     data.Browser,
-			OS:          data.OS,
-			Country:     data.Country,
-			City:        data.City,
-			Fingerprint: data.Fingerprint,
-			Referrer:    data.Referrer,
-			ClickedAt:   data.ClickedAt,
-		}
+		clicks[i] = data.toClick()
 		counterUpdates[data.UserOfferID]++
 	}
 
@@ -292,6 +283,25 @@ func (s *ClickServiceV2) flushClickBatch(batch []*ClickData) {
 	} else {
 		fmt.Printf("[ClickServiceV2] Flushed %d clicks in %v\n", len(batch), duration)
 	}
+	return err
+}
+
+// toClick converts buffered click data to a click record
+func (d *ClickData) toClick() models.Click {
+	return models.Click{
+		ID:          d.ID,
+		UserOfferID: d.UserOfferID,
+		IPAddress:   d.IP,
+		UserAgent:   d.UserAgent,
+		Device:      d.Device,
+		Browser:     d.Browser,
+		OS:          d.OS,
+		Country:     d.Country,
+		City:        d.City,
+		Fingerprint: d.Fingerprint,
+		Referrer:    d.Referrer,
+		ClickedAt:   d.ClickedAt,
+	}
 }
 
 // counterFlushWorker periodically syncs in-memory counters to database
@@ -413,6 +423,66 @@ func (s *ClickServiceV2) Stop() {
 	s.cancel()
 }
 
+// Drain stops the flush workers and writes out every buffered click. Clicks
+// that can't be written before ctx expires go to the WAL for replay.
+func (s *ClickServiceV2) Drain(ctx context.Context) (int, error) {
+	s.cancel()
+	select {
+	case <-s.flushDone:
+	case <-ctx.Done():
+	}
+
+	batch := make([]*ClickData, 0, s.bufferSize)
+	var spill []*ClickData
+	flush := func() {
+		if err := s.flushClickBatch(batch); err != nil {
+			spill = append(spill, batch...)
+		}
+		batch = make([]*ClickData, 0, s.bufferSize)
+	}
+
+	for done := false; !done; {
+		select {
+		case data := <-s.clickBuffer:
+			if ctx.Err() != nil {
+				spill = append(spill, data)
+				continue
+			}
+			batch = append(batch, data)
+			if len(batch) >= s.bufferSize {
+				flush()
+			}
+		default:
+			done = true
+		}
+	}
+	flush()
+
+	lost := 0
+	for _, data := range spill {
+		event, err := toEventData(data.toClick())
+		if err == nil {
+			err = spillToWAL(WALEventClick, "", event)
+		}
+		if err != nil {
+			lost++
+			fmt.Printf("[ClickServiceV2] Undrained click lost: id=%s user_offer=%s: %v\n", data.ID, data.UserOfferID, err)
+		}
+	}
+	if len(spill) > 0 {
+		fmt.Printf("[ClickServiceV2] %d clicks spilled to the WAL for replay\n", len(spill)-lost)
+	}
+	return len(spill), nil
+}
+
+// DrainClickServiceV2 drains the click service if it was started
+func DrainClickServiceV2(ctx context.Context) (int, error) {
+	if clickServiceV2Instance == nil {
+		return 0, nil
+	}
+	return clickServiceV2Instance.Drain(ctx)
+}
+
 // ============================================
 // CLICK DEDUPLICATION
 // ============================================
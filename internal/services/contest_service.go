@@ -0,0 +1,643 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ============================================
+// CONTEST ENGINE
+// ============================================
+
+// Contest scoring uses the same weights as the global user leaderboard
+const (
+	ContestPointsPerClick      = 2
+	ContestPointsPerConversion = 20
+)
+
+// contestExcludedConversionStatuses are not counted towards contest progress
+var contestExcludedConversionStatuses = []string{
+	models.ConversionStatusRejected,
+	models.ConversionStatusReversed,
+}
+
+// ContestMetrics tracks contest engine activity
+type ContestMetrics struct {
+	EventsRecorded  int64
+	Recomputes      int64
+	RecomputeErrors int64
+	Activated       int64
+	Ended           int64
+	WinnersMarked   int64
+}
+
+var contestMetrics = &ContestMetrics{}
+
+// GetContestMetrics returns contest engine metrics
+func GetContestMetrics() *ContestMetrics {
+	return &ContestMetrics{
+		EventsRecorded:  atomic.LoadInt64(&contestMetrics.EventsRecorded),
+		Recomputes:      atomic.LoadInt64(&contestMetrics.Recomputes),
+		RecomputeErrors: atomic.LoadInt64(&contestMetrics.RecomputeErrors),
+		Activated:       atomic.LoadInt64(&contestMetrics.Activated),
+		Ended:           atomic.LoadInt64(&contestMetrics.Ended),
+		WinnersMarked:   atomic.LoadInt64(&contestMetrics.WinnersMarked),
+	}
+}
+
+// ContestService keeps contest participant progress, ranks and lifecycle up to date.
+// Clicks and conversions mark their user offer as dirty; a background loop
+// recomputes the affected contests from the tracking tables, so progress is
+// always derived from the source of truth and never drifts.
+type ContestService struct {
+	db *gorm.DB
+
+	mu              sync.Mutex
+	dirtyUserOffers map[uuid.UUID]struct{}
+
+	// Configuration
+	flushInterval     time.Duration
+	lifecycleInterval time.Duration
+	reconcileInterval time.Duration
+
+	// State
+	isRunning bool
+	stopChan  chan struct{}
+	wg        sync.WaitGroup
+}
+
+var (
+	contestServiceInstance *ContestService
+	contestServiceOnce     sync.Once
+)
+
+// NewContestService creates a new contest service
+func NewContestService(db *gorm.DB) *ContestService {
+	return &ContestService{
+		db:                db,
+		dirtyUserOffers:   make(map[uuid.UUID]struct{}),
+		flushInterval:     15 * time.Second,
+		lifecycleInterval: time.Minute,
+		reconcileInterval: 10 * time.Minute,
+		stopChan:          make(chan struct{}),
+	}
+}
+
+// GetContestService returns the singleton contest service
+func GetContestService(db *gorm.DB) *ContestService {
+	contestServiceOnce.Do(func() {
+		contestServiceInstance = NewContestService(db)
+	})
+	return contestServiceInstance
+}
+
+// ============================================
+// EVENT INGESTION
+// ============================================
+
+// RecordActivity notes that a click or conversion landed on a user offer.
+// It is cheap and non-blocking; the contest is recomputed on the next flush.
+func (s *ContestService) RecordActivity(userOfferID uuid.UUID) {
+	if s == nil || userOfferID == uuid.Nil {
+		return
+	}
+	atomic.AddInt64(&contestMetrics.EventsRecorded, 1)
+
+	s.mu.Lock()
+	s.dirtyUserOffers[userOfferID] = struct{}{}
+	s.mu.Unlock()
+}
+
+// ============================================
+// WORKER
+// ============================================
+
+// Start starts the contest engine background loop
+func (s *ContestService) Start() {
+	if s.isRunning {
+		return
+	}
+	s.isRunning = true
+
+	s.wg.Add(1)
+	go s.worker()
+}
+
+// Stop stops the contest engine, flushing pending activity first
+func (s *ContestService) Stop() {
+	if !s.isRunning {
+		return
+	}
+	s.isRunning = false
+	close(s.stopChan)
+	s.wg.Wait()
+}
+
+// worker flushes activity, advances contest lifecycles and periodically
+// reconciles every active contest
+func (s *ContestService) worker() {
+	defer s.wg.Done()
+
+	flushTicker := time.NewTicker(s.flushInterval)
+	defer flushTicker.Stop()
+	lifecycleTicker := time.NewTicker(s.lifecycleInterval)
+	defer lifecycleTicker.Stop()
+	reconcileTicker := time.NewTicker(s.reconcileInterval)
+	defer reconcileTicker.Stop()
+
+	// Catch up on anything that started or ended while we were down
+	s.RunLifecycle(time.Now())
+
+	for {
+		select {
+		case <-s.stopChan:
+			s.FlushActivity()
+			return
+		case <-flushTicker.C:
+			s.FlushActivity()
+		case <-lifecycleTicker.C:
+			s.RunLifecycle(time.Now())
+		case <-reconcileTicker.C:
+			s.ReconcileActive()
+		}
+	}
+}
+
+// FlushActivity recomputes every active contest touched since the last flush
+func (s *ContestService) FlushActivity() {
+	s.mu.Lock()
+	if len(s.dirtyUserOffers) == 0 {
+		s.mu.Unlock()
+		return
+	}
+	userOfferIDs := make([]uuid.UUID, 0, len(s.dirtyUserOffers))
+	for id := range s.dirtyUserOffers {
+		userOfferIDs = append(userOfferIDs, id)
+	}
+	s.dirtyUserOffers = make(map[uuid.UUID]struct{})
+	s.mu.Unlock()
+
+	contestIDs, err := s.findAffectedContests(userOfferIDs)
+	if err != nil {
+		fmt.Printf("[Contest] Failed to resolve affected contests: %v\n", err)
+		return
+	}
+
+	for _, contestID := range contestIDs {
+		if err := s.RecomputeContest(contestID); err != nil {
+			fmt.Printf("[Contest] Failed to recompute contest %s: %v\n", contestID, err)
+		}
+	}
+}
+
+// findAffectedContests maps user offers to the active contests their owners
+// take part in, either individually or through their team
+func (s *ContestService) findAffectedContests(userOfferIDs []uuid.UUID) ([]uuid.UUID, error) {
+	var userIDs []uuid.UUID
+	if err := s.db.Model(&models.UserOffer{}).
+		Where("id IN ?", userOfferIDs).
+		Distinct().
+		Pluck("user_id", &userIDs).Error; err != nil {
+		return nil, err
+	}
+	if len(userIDs) == 0 {
+		return nil, nil
+	}
+
+	var teamIDs []uuid.UUID
+	if err := s.db.Model(&models.TeamMember{}).
+		Where("user_id IN ? AND status = ?", userIDs, models.TeamMemberStatusActive).
+		Distinct().
+		Pluck("team_id", &teamIDs).Error; err != nil {
+		return nil, err
+	}
+
+	query := s.db.Model(&models.ContestParticipant{}).
+		Joins("JOIN contests ON contests.id = contest_participants.contest_id").
+		Where("contests.status = ?", models.ContestStatusActive)
+	if len(teamIDs) > 0 {
+		query = query.Where("contest_participants.user_id IN ? OR contest_participants.team_id IN ?", userIDs, teamIDs)
+	} else {
+		query = query.Where("contest_participants.user_id IN ?", userIDs)
+	}
+
+	var contestIDs []uuid.UUID
+	if err := query.Distinct().Pluck("contest_participants.contest_id", &contestIDs).Error; err != nil {
+		return nil, err
+	}
+	return contestIDs, nil
+}
+
+// ReconcileActive recomputes every active contest from scratch
+func (s *ContestService) ReconcileActive() {
+	var contestIDs []uuid.UUID
+	if err := s.db.Model(&models.Contest{}).
+		Where("status = ?", models.ContestStatusActive).
+		Pluck("id", &contestIDs).Error; err != nil {
+		fmt.Printf("[Contest] Failed to list active contests: %v\n", err)
+		return
+	}
+
+	for _, contestID := range contestIDs {
+		if err := s.RecomputeContest(contestID); err != nil {
+			fmt.Printf("[Contest] Failed to reconcile contest %s: %v\n", contestID, err)
+		}
+	}
+}
+
+// ============================================
+// LIFECYCLE
+// ============================================
+
+// RunLifecycle activates draft contests whose start date has passed and
+// settles active contests whose end date has passed
+func (s *ContestService) RunLifecycle(now time.Time) {
+	result := s.db.Model(&models.Contest{}).
+		Where("status = ? AND start_date <= ? AND end_date > ?", models.ContestStatusDraft, now, now).
+		UpdateColumns(map[string]interface{}{
+			"status":     models.ContestStatusActive,
+			"updated_at": now,
+		})
+	if result.Error != nil {
+		fmt.Printf("[Contest] Failed to activate contests: %v\n", result.Error)
+	} else if result.RowsAffected > 0 {
+		atomic.AddInt64(&contestMetrics.Activated, result.RowsAffected)
+		fmt.Printf("[Contest] Activated %d contest(s)\n", result.RowsAffected)
+	}
+
+	// Draft contests that were never activated before their end date are
+	// settled the same way so they don't linger as drafts forever
+	var due []models.Contest
+	if err := s.db.Where("status IN ? AND end_date <= ? AND start_date <= ?",
+		[]string{models.ContestStatusActive, models.ContestStatusDraft}, now, now).
+		Find(&due).Error; err != nil {
+		fmt.Printf("[Contest] Failed to list ended contests: %v\n", err)
+		return
+	}
+
+	for i := range due {
+		if err := s.SettleContest(&due[i]); err != nil {
+			fmt.Printf("[Contest] Failed to settle contest %s: %v\n", due[i].ID, err)
+		}
+	}
+}
+
+// SettleContest runs a final recompute, marks the winner and closes the contest.
+// Settlement is claimed with a conditional status update, so it runs once
+// even if several instances race on the same contest.
+func (s *ContestService) SettleContest(contest *models.Contest) error {
+	if contest.Status != models.ContestStatusActive && contest.Status != models.ContestStatusDraft {
+		return fmt.Errorf("contest is %s", contest.Status)
+	}
+
+	standings, err := s.recomputeContest(contest.ID)
+	if err != nil {
+		return err
+	}
+
+	var winner *models.ContestParticipant
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		claim := tx.Model(&models.Contest{}).
+			Where("id = ? AND status = ?", contest.ID, contest.Status).
+			UpdateColumns(map[string]interface{}{
+				"status":     models.ContestStatusEnded,
+				"updated_at": now,
+			})
+		if claim.Error != nil {
+			return claim.Error
+		}
+		if claim.RowsAffected == 0 {
+			// Already settled elsewhere
+			return nil
+		}
+
+		winners := 0
+		for i, st := range standings {
+			var status string
+			switch {
+			case !st.eligible:
+				status = models.ParticipantStatusDisqualified
+			case i == 0 && st.score > 0:
+				status = models.ParticipantStatusWinner
+				winner = st.participant
+				winners++
+			default:
+				status = models.ParticipantStatusCompleted
+			}
+			if status == st.participant.Status {
+				continue
+			}
+			if err := tx.Model(&models.ContestParticipant{}).
+				Where("id = ?", st.participant.ID).
+				UpdateColumns(map[string]interface{}{
+					"status":     status,
+					"updated_at": now,
+				}).Error; err != nil {
+				return err
+			}
+		}
+
+		atomic.AddInt64(&contestMetrics.Ended, 1)
+		atomic.AddInt64(&contestMetrics.WinnersMarked, int64(winners))
+		contest.Status = models.ContestStatusEnded
+		fmt.Printf("[Contest] Contest %s ended with %d winner(s)\n", contest.ID, winners)
+		return nil
+	})
+	if err == nil && winner != nil {
+		GetBadgeEngine(s.db).RecordContestWin(winner.UserID, winner.TeamID)
+	}
+	return err
+}
+
+// ============================================
+// RECOMPUTATION
+// ============================================
+
+// participantStanding is the computed state of one participant
+type participantStanding struct {
+	participant *models.ContestParticipant
+	clicks      int
+	conversions int
+	referrals   int
+	members     int
+	points      int
+	score       int
+	progress    int
+	eligible    bool
+}
+
+// userCount is a grouped count keyed by user or team
+type userCount struct {
+	ID    uuid.UUID
+	Count int
+}
+
+// RecomputeContest rebuilds progress and ranks for every participant of a
+// contest from clicks and conversions inside the contest window
+func (s *ContestService) RecomputeContest(contestID uuid.UUID) error {
+	_, err := s.recomputeContest(contestID)
+	return err
+}
+
+// recomputeContest persists and returns the ranked standings of a contest
+func (s *ContestService) recomputeContest(contestID uuid.UUID) ([]*participantStanding, error) {
+	atomic.AddInt64(&contestMetrics.Recomputes, 1)
+
+	var contest models.Contest
+	if err := s.db.First(&contest, "id = ?", contestID).Error; err != nil {
+		atomic.AddInt64(&contestMetrics.RecomputeErrors, 1)
+		return nil, fmt.Errorf("contest not found: %w", err)
+	}
+
+	var participants []models.ContestParticipant
+	if err := s.db.Where("contest_id = ?", contestID).Find(&participants).Error; err != nil {
+		atomic.AddInt64(&contestMetrics.RecomputeErrors, 1)
+		return nil, err
+	}
+	if len(participants) == 0 {
+		return nil, nil
+	}
+
+	standings, err := s.computeStandings(&contest, participants)
+	if err != nil {
+		atomic.AddInt64(&contestMetrics.RecomputeErrors, 1)
+		return nil, err
+	}
+
+	rankStandings(standings)
+
+	now := time.Now()
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		for i, st := range standings {
+			p := st.participant
+			rank := i + 1
+			if p.CurrentClicks == st.clicks && p.CurrentConversions == st.conversions &&
+				p.CurrentPoints == st.points && p.Progress == st.progress && p.Rank == rank {
+				continue
+			}
+			if err := tx.Model(&models.ContestParticipant{}).
+				Where("id = ?", p.ID).
+				UpdateColumns(map[string]interface{}{
+					"current_clicks":      st.clicks,
+					"current_conversions": st.conversions,
+					"current_points":      st.points,
+					"progress":            st.progress,
+					"rank":                rank,
+					"updated_at":          now,
+				}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		atomic.AddInt64(&contestMetrics.RecomputeErrors, 1)
+		return nil, err
+	}
+
+	return standings, nil
+}
+
+// computeStandings aggregates activity for all participants in a few grouped queries
+func (s *ContestService) computeStandings(contest *models.Contest, participants []models.ContestParticipant) ([]*participantStanding, error) {
+	// Resolve the users behind each participant
+	var teamIDs []uuid.UUID
+	for _, p := range participants {
+		if p.TeamID != nil {
+			teamIDs = append(teamIDs, *p.TeamID)
+		}
+	}
+
+	teamMembers := make(map[uuid.UUID][]uuid.UUID)
+	if len(teamIDs) > 0 {
+		var members []models.TeamMember
+		if err := s.db.Where("team_id IN ? AND status = ?", teamIDs, models.TeamMemberStatusActive).
+			Find(&members).Error; err != nil {
+			return nil, err
+		}
+		for _, m := range members {
+			teamMembers[m.TeamID] = append(teamMembers[m.TeamID], m.UserID)
+		}
+	}
+
+	var userIDs []uuid.UUID
+	participantUsers := make([][]uuid.UUID, len(participants))
+	for i, p := range participants {
+		switch {
+		case p.TeamID != nil:
+			participantUsers[i] = teamMembers[*p.TeamID]
+		case p.UserID != nil:
+			participantUsers[i] = []uuid.UUID{*p.UserID}
+		}
+		userIDs = append(userIDs, participantUsers[i]...)
+	}
+
+	clicksByUser := make(map[uuid.UUID]int)
+	conversionsByUser := make(map[uuid.UUID]int)
+	if len(userIDs) > 0 {
+		var rows []userCount
+		if err := s.db.Table("clicks").
+			Select("user_offers.user_id AS id, COUNT(*) AS count").
+			Joins("JOIN user_offers ON user_offers.id = clicks.user_offer_id").
+			Where("user_offers.user_id IN ? AND clicks.clicked_at >= ? AND clicks.clicked_at < ?",
+				userIDs, contest.StartDate, contest.EndDate).
+			Group("user_offers.user_id").
+			Scan(&rows).Error; err != nil {
+			return nil, err
+		}
+		for _, r := range rows {
+			clicksByUser[r.ID] = r.Count
+		}
+
+		rows = nil
+		if err := s.db.Table("conversions").
+			Select("user_offers.user_id AS id, COUNT(*) AS count").
+			Joins("JOIN user_offers ON user_offers.id = conversions.user_offer_id").
+			Where("user_offers.user_id IN ? AND conversions.converted_at >= ? AND conversions.converted_at < ? AND conversions.status NOT IN ?",
+				userIDs, contest.StartDate, contest.EndDate, contestExcludedConversionStatuses).
+			Group("user_offers.user_id").
+			Scan(&rows).Error; err != nil {
+			return nil, err
+		}
+		for _, r := range rows {
+			conversionsByUser[r.ID] = r.Count
+		}
+	}
+
+	referralsByTeam, referralsByOwner, err := s.countReferrals(contest, teamIDs, participants)
+	if err != nil {
+		return nil, err
+	}
+
+	standings := make([]*participantStanding, len(participants))
+	for i := range participants {
+		p := &participants[i]
+		st := &participantStanding{participant: p, members: len(participantUsers[i])}
+
+		for _, uid := range participantUsers[i] {
+			st.clicks += clicksByUser[uid]
+			st.conversions += conversionsByUser[uid]
+		}
+		if p.TeamID != nil {
+			st.referrals = referralsByTeam[*p.TeamID]
+		} else if p.UserID != nil {
+			st.referrals = referralsByOwner[*p.UserID]
+		}
+		scoreStanding(contest, st)
+
+		standings[i] = st
+	}
+
+	return standings, nil
+}
+
+// scoreStanding derives points, score, progress and eligibility from a
+// participant's counts
+func scoreStanding(contest *models.Contest, st *participantStanding) {
+	st.points = st.clicks*ContestPointsPerClick + st.conversions*ContestPointsPerConversion
+
+	switch contest.TargetType {
+	case models.TargetTypeConversions:
+		st.score = st.conversions
+	case models.TargetTypeReferrals:
+		st.score = st.referrals
+	case models.TargetTypePoints:
+		st.score = st.points
+	default:
+		st.score = st.clicks
+	}
+
+	st.progress = 0
+	if contest.TargetValue > 0 {
+		st.progress = st.score * 100 / contest.TargetValue
+		if st.progress > 100 {
+			st.progress = 100
+		}
+	}
+
+	st.eligible = st.participant.Status != models.ParticipantStatusDisqualified &&
+		st.clicks >= contest.MinClicks &&
+		st.conversions >= contest.MinConversions
+	if contest.ContestType == models.ContestTypeTeam && st.members < contest.MinMembers {
+		st.eligible = false
+	}
+}
+
+// countReferrals counts members recruited into a team during the contest.
+// Team participants are credited for their own team; individual participants
+// for the teams they own.
+func (s *ContestService) countReferrals(contest *models.Contest, teamIDs []uuid.UUID, participants []models.ContestParticipant) (map[uuid.UUID]int, map[uuid.UUID]int, error) {
+	byTeam := make(map[uuid.UUID]int)
+	byOwner := make(map[uuid.UUID]int)
+	if contest.TargetType != models.TargetTypeReferrals {
+		return byTeam, byOwner, nil
+	}
+
+	var ownerIDs []uuid.UUID
+	for _, p := range participants {
+		if p.TeamID == nil && p.UserID != nil {
+			ownerIDs = append(ownerIDs, *p.UserID)
+		}
+	}
+
+	base := s.db.Table("team_members").
+		Joins("JOIN teams ON teams.id = team_members.team_id").
+		Where("team_members.status = ? AND team_members.user_id <> teams.owner_id AND team_members.joined_at >= ? AND team_members.joined_at < ?",
+			models.TeamMemberStatusActive, contest.StartDate, contest.EndDate)
+
+	if len(teamIDs) > 0 {
+		var rows []userCount
+		if err := base.Session(&gorm.Session{}).
+			Select("teams.id AS id, COUNT(*) AS count").
+			Where("teams.id IN ?", teamIDs).
+			Group("teams.id").
+			Scan(&rows).Error; err != nil {
+			return nil, nil, err
+		}
+		for _, r := range rows {
+			byTeam[r.ID] = r.Count
+		}
+	}
+
+	if len(ownerIDs) > 0 {
+		var rows []userCount
+		if err := base.Session(&gorm.Session{}).
+			Select("teams.owner_id AS id, COUNT(*) AS count").
+			Where("teams.owner_id IN ?", ownerIDs).
+			Group("teams.owner_id").
+			Scan(&rows).Error; err != nil {
+			return nil, nil, err
+		}
+		for _, r := range rows {
+			byOwner[r.ID] = r.Count
+		}
+	}
+
+	return byTeam, byOwner, nil
+}
+
+// rankStandings orders participants: eligible first, then by score, points,
+// and finally by who joined first so ranks are always unique
+func rankStandings(standings []*participantStanding) {
+	sort.SliceStable(standings, func(i, j int) bool {
+		a, b := standings[i], standings[j]
+		if a.eligible != b.eligible {
+			return a.eligible
+		}
+		if a.score != b.score {
+			return a.score > b.score
+		}
+		if a.points != b.points {
+			return a.points > b.points
+		}
+		return a.participant.JoinedAt.Before(b.participant.JoinedAt)
+	})
+}
@@ -0,0 +1,209 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/google/uuid"
+)
+
+func TestScoreStanding(t *testing.T) {
+	tests := []struct {
+		name         string
+		contest      models.Contest
+		status       string
+		clicks       int
+		conversions  int
+		referrals    int
+		members      int
+		wantScore    int
+		wantPoints   int
+		wantProgress int
+		wantEligible bool
+	}{
+		{
+			name:         "clicks by default",
+			contest:      models.Contest{TargetValue: 100},
+			clicks:       40,
+			conversions:  1,
+			wantScore:    40,
+			wantPoints:   40*ContestPointsPerClick + ContestPointsPerConversion,
+			wantProgress: 40,
+			wantEligible: true,
+		},
+		{
+			name:         "conversions",
+			contest:      models.Contest{TargetType: models.TargetTypeConversions, TargetValue: 10},
+			clicks:       5,
+			conversions:  3,
+			wantScore:    3,
+			wantPoints:   5*ContestPointsPerClick + 3*ContestPointsPerConversion,
+			wantProgress: 30,
+			wantEligible: true,
+		},
+		{
+			name:         "referrals",
+			contest:      models.Contest{TargetType: models.TargetTypeReferrals, TargetValue: 4},
+			referrals:    2,
+			wantScore:    2,
+			wantProgress: 50,
+			wantEligible: true,
+		},
+		{
+			name:         "points",
+			contest:      models.Contest{TargetType: models.TargetTypePoints, TargetValue: 50},
+			clicks:       5,
+			conversions:  1,
+			wantScore:    30,
+			wantPoints:   30,
+			wantProgress: 60,
+			wantEligible: true,
+		},
+		{
+			name:         "progress capped at 100",
+			contest:      models.Contest{TargetValue: 10},
+			clicks:       25,
+			wantScore:    25,
+			wantPoints:   50,
+			wantProgress: 100,
+			wantEligible: true,
+		},
+		{
+			name:         "no target means no progress",
+			contest:      models.Contest{},
+			clicks:       25,
+			wantScore:    25,
+			wantPoints:   50,
+			wantEligible: true,
+		},
+		{
+			name:         "below minimum clicks",
+			contest:      models.Contest{MinClicks: 10},
+			clicks:       9,
+			wantScore:    9,
+			wantPoints:   18,
+			wantEligible: false,
+		},
+		{
+			name:         "below minimum conversions",
+			contest:      models.Contest{TargetType: models.TargetTypeConversions, MinConversions: 2},
+			conversions:  1,
+			wantScore:    1,
+			wantPoints:   20,
+			wantEligible: false,
+		},
+		{
+			name:         "team below minimum members",
+			contest:      models.Contest{ContestType: models.ContestTypeTeam, MinMembers: 3},
+			members:      2,
+			wantEligible: false,
+		},
+		{
+			name:         "individual ignores minimum members",
+			contest:      models.Contest{ContestType: models.ContestTypeIndividual, MinMembers: 3},
+			members:      1,
+			wantEligible: true,
+		},
+		{
+			name:         "disqualified",
+			contest:      models.Contest{},
+			status:       models.ParticipantStatusDisqualified,
+			clicks:       100,
+			wantScore:    100,
+			wantPoints:   200,
+			wantEligible: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := &participantStanding{
+				participant: &models.ContestParticipant{Status: tt.status},
+				clicks:      tt.clicks,
+				conversions: tt.conversions,
+				referrals:   tt.referrals,
+				members:     tt.members,
+			}
+			scoreStanding(&tt.contest, st)
+
+			if st.score != tt.wantScore {
+				t.Errorf("score = %d, want %d", st.score, tt.wantScore)
+			}
+			if st.points != tt.wantPoints {
+				t.Errorf("points = %d, want %d", st.points, tt.wantPoints)
+			}
+			if st.progress != tt.wantProgress {
+				t.Errorf("progress = %d, want %d", st.progress, tt.wantProgress)
+			}
+			if st.eligible != tt.wantEligible {
+				t.Errorf("eligible = %v, want %v", st.eligible, tt.wantEligible)
+			}
+		})
+	}
+}
+
+func TestRankStandings(t *testing.T) {
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	standing := func(name string, eligible bool, score, points int, joinedAfter time.Duration) *participantStanding {
+		id := uuid.NewSHA1(uuid.NameSpaceOID, []byte(name))
+		return &participantStanding{
+			participant: &models.ContestParticipant{ID: id, JoinedAt: base.Add(joinedAfter)},
+			eligible:    eligible,
+			score:       score,
+			points:      points,
+		}
+	}
+
+	tests := []struct {
+		name      string
+		standings []*participantStanding
+		want      []string
+	}{
+		{
+			name: "score descending",
+			standings: []*participantStanding{
+				standing("a", true, 1, 0, 0),
+				standing("b", true, 3, 0, 0),
+				standing("c", true, 2, 0, 0),
+			},
+			want: []string{"b", "c", "a"},
+		},
+		{
+			name: "eligible before ineligible",
+			standings: []*participantStanding{
+				standing("a", false, 100, 100, 0),
+				standing("b", true, 1, 1, 0),
+			},
+			want: []string{"b", "a"},
+		},
+		{
+			name: "points break score ties",
+			standings: []*participantStanding{
+				standing("a", true, 5, 10, 0),
+				standing("b", true, 5, 20, 0),
+			},
+			want: []string{"b", "a"},
+		},
+		{
+			name: "earliest join breaks full ties",
+			standings: []*participantStanding{
+				standing("a", true, 5, 10, 2*time.Hour),
+				standing("b", true, 5, 10, time.Hour),
+			},
+			want: []string{"b", "a"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rankStandings(tt.standings)
+			for i, name := range tt.want {
+				want := uuid.NewSHA1(uuid.NameSpaceOID, []byte(name))
+				if tt.standings[i].participant.ID != want {
+					t.Fatalf("position %d is not %q", i, name)
+				}
+			}
+		})
+	}
+}
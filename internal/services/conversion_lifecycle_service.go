@@ -0,0 +1,533 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/aljapah/afftok-backend-prod/internal/cache"
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ============================================
+// CONVERSION LIFECYCLE SERVICE
+// ============================================
+
+// ConversionLifecycleService enforces the conversion state machine
+// (pending -> approved -> paid, pending -> rejected, approved -> reversed),
+// keeps earnings in step with each transition and records the history
+type ConversionLifecycleService struct {
+	db *gorm.DB
+}
+
+// NewConversionLifecycleService creates a new conversion lifecycle service
+func NewConversionLifecycleService(db *gorm.DB) *ConversionLifecycleService {
+	return &ConversionLifecycleService{db: db}
+}
+
+// Conversion lifecycle errors
+var (
+	ErrConversionNotFound          = errors.New("conversion not found")
+	ErrInvalidConversionTransition = errors.New("invalid conversion status transition")
+	ErrDuplicateConversion         = errors.New("conversion already recorded")
+)
+
+// ConversionTransition describes a requested status change
+type ConversionTransition struct {
+	ToStatus string
+	Source   string
+	Reason   string
+	ActorID  *uuid.UUID
+}
+
+// ConversionLifecycleMetrics tracks state machine activity
+type ConversionLifecycleMetrics struct {
+	Transitions        int64
+	InvalidTransitions int64
+	Reversals          int64
+	ClawedBack         int64 // Total commission clawed back
+}
+
+var conversionLifecycleMetrics = &ConversionLifecycleMetrics{}
+
+// GetConversionLifecycleMetrics returns conversion lifecycle metrics
+func GetConversionLifecycleMetrics() *ConversionLifecycleMetrics {
+	return &ConversionLifecycleMetrics{
+		Transitions:        atomic.LoadInt64(&conversionLifecycleMetrics.Transitions),
+		InvalidTransitions: atomic.LoadInt64(&conversionLifecycleMetrics.InvalidTransitions),
+		Reversals:          atomic.LoadInt64(&conversionLifecycleMetrics.Reversals),
+		ClawedBack:         atomic.LoadInt64(&conversionLifecycleMetrics.ClawedBack),
+	}
+}
+
+// ============================================
+// CREATION
+// ============================================
+
+// RecordCreation records the initial status of a newly created conversion and
+// credits earnings if it was created approved. Call it inside the transaction
+// that creates the conversion.
+func (s *ConversionLifecycleService) RecordCreation(tx *gorm.DB, conversion *models.Conversion, source string) error {
+	delta := 0
+	if conversion.IsCredited() {
+		delta = conversion.Commission
+		if err := applyEarningsDelta(tx, conversion.UserOfferID, delta); err != nil {
+			return err
+		}
+	}
+
+	return tx.Create(&models.ConversionStatusHistory{
+		ID:              uuid.New(),
+		ConversionID:    conversion.ID,
+		ToStatus:        conversion.Status,
+		Source:          source,
+		CommissionDelta: delta,
+		CreatedAt:       time.Now().UTC(),
+	}).Error
+}
+
+// CreateConversion creates a conversion, bumps the conversion counters on the
+// user offer, offer and user, and records its initial status in one transaction
+func (s *ConversionLifecycleService) CreateConversion(conversion *models.Conversion, source string) error {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		attachClickSubIDs(tx, conversion)
+		if err := tx.Create(conversion).Error; err != nil {
+			return err
+		}
+
+		var userOffer models.UserOffer
+		if err := tx.Select("id", "user_id", "offer_id").First(&userOffer, "id = ?", conversion.UserOfferID).Error; err != nil {
+			return fmt.Errorf("user offer not found: %w", err)
+		}
+		if err := bumpConversionCounters(tx, userOffer.ID, userOffer.OfferID, userOffer.UserID); err != nil {
+			return err
+		}
+
+		return s.RecordCreation(tx, conversion, source)
+	})
+	if err != nil {
+		return err
+	}
+
+	GetContestService(s.db).RecordActivity(conversion.UserOfferID)
+	GetWebhookService(s.db).EmitConversion(*conversion)
+	GetPromoterPostbackService(s.db).EmitConversion(*conversion, models.PromoterPostbackEventCreated)
+	if !conversion.IsVoided() {
+		GetLeaderboardService(s.db).RecordConversion(conversion.UserOfferID, conversion.ConvertedAt)
+	}
+	GetBadgeEngine(s.db).RecordConversion(conversion.UserOfferID)
+	return nil
+}
+
+// bumpConversionCounters counts a new conversion on its user offer, offer and user
+func bumpConversionCounters(tx *gorm.DB, userOfferID, offerID, userID uuid.UUID) error {
+	if err := tx.Model(&models.UserOffer{}).
+		Where("id = ?", userOfferID).
+		UpdateColumns(map[string]interface{}{
+			"total_conversions": gorm.Expr("total_conversions + 1"),
+			"updated_at":        time.Now().UTC(),
+		}).Error; err != nil {
+		return fmt.Errorf("failed to update user offer: %w", err)
+	}
+
+	if err := tx.Model(&models.Offer{}).
+		Where("id = ?", offerID).
+		UpdateColumn("total_conversions", gorm.Expr("total_conversions + 1")).Error; err != nil {
+		return fmt.Errorf("failed to update offer conversions: %w", err)
+	}
+
+	if err := tx.Model(&models.AfftokUser{}).
+		Where("id = ?", userID).
+		UpdateColumn("total_conversions", gorm.Expr("total_conversions + 1")).Error; err != nil {
+		return fmt.Errorf("failed to update user conversions: %w", err)
+	}
+	return nil
+}
+
+// PersistConversion stores a new conversion, bumps the conversion counters on
+// the user offer, offer and user, and records its initial status. It is
+// idempotent on the conversion ID so zero-drop replays don't double count;
+// created is false if the conversion was already stored. A different
+// conversion with the same external ID returns ErrDuplicateConversion.
+// Contest, webhook and leaderboard side effects fire only once the row exists.
+func (s *ConversionLifecycleService) PersistConversion(event *ConversionEvent) (bool, error) {
+	conversion := event.Conversion
+	created := false
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		attachClickSubIDs(tx, conversion)
+
+		// Only a replay of this same conversion is ignored; any other conflict
+		// (the external ID index) fails the insert
+		result := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "id"}},
+			DoNothing: true,
+		}).Create(conversion)
+		if result.Error != nil {
+			return fmt.Errorf("failed to create conversion: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		created = true
+
+		if err := bumpConversionCounters(tx, conversion.UserOfferID, event.OfferID, event.UserID); err != nil {
+			return err
+		}
+
+		// Credits earnings if approved
+		if err := s.RecordCreation(tx, conversion, event.Source); err != nil {
+			return fmt.Errorf("failed to record conversion status: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		if existing, findErr := s.FindByExternalID(conversion.ExternalConversionID); findErr == nil && existing.ID != conversion.ID {
+			clearPendingConversion(conversion.ExternalConversionID)
+			return false, ErrDuplicateConversion
+		}
+		return false, err
+	}
+	clearPendingConversion(conversion.ExternalConversionID)
+
+	if created {
+		GetContestService(s.db).RecordActivity(conversion.UserOfferID)
+		GetWebhookService(s.db).EmitConversion(*conversion)
+		if event.Postback != nil {
+			GetWebhookService(s.db).EmitPostback(event.Postback, conversion.ID, conversion.UserOfferID)
+		}
+		GetPromoterPostbackService(s.db).EmitConversion(*conversion, models.PromoterPostbackEventCreated)
+		if !conversion.IsVoided() {
+			GetLeaderboardService(s.db).RecordConversion(conversion.UserOfferID, conversion.ConvertedAt)
+		}
+		GetBadgeEngine(s.db).RecordConversion(conversion.UserOfferID)
+	}
+	return created, nil
+}
+
+// FindByExternalID loads a conversion by the advertiser's order/transaction ID
+func (s *ConversionLifecycleService) FindByExternalID(externalID string) (*models.Conversion, error) {
+	if externalID == "" {
+		return nil, ErrConversionNotFound
+	}
+	var conversion models.Conversion
+	if err := s.db.Where("external_conversion_id = ?", externalID).First(&conversion).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrConversionNotFound
+		}
+		return nil, err
+	}
+	return &conversion, nil
+}
+
+// attachClickSubIDs copies the attributed click's sub-IDs onto a conversion
+// that doesn't carry its own
+func attachClickSubIDs(tx *gorm.DB, conversion *models.Conversion) {
+	if conversion.ClickID == nil || !conversion.SubIDs.IsEmpty() {
+		return
+	}
+	var click models.Click
+	if err := tx.Select("sub1", "sub2", "sub3", "sub4", "sub5").
+		First(&click, "id = ?", *conversion.ClickID).Error; err == nil {
+		conversion.SubIDs = click.SubIDs
+	}
+}
+
+// RecordConversion stores a new conversion, through the zero-drop WAL when it
+// is enabled for the tenant (queued is true; it is persisted asynchronously)
+// and synchronously otherwise
+func (s *ConversionLifecycleService) RecordConversion(event *ConversionEvent, tenantID string) (bool, error) {
+	pipeline := GetZeroDropPipeline()
+	if pipeline.IsWALFirst(tenantID) {
+		// Marked before submitting so a status update that races the
+		// consumer is queued rather than rejected
+		markPendingConversion(event.Conversion.ExternalConversionID)
+
+		data, err := toEventData(event)
+		if err == nil {
+			err = pipeline.Submit(tenantID, WALEventConversion, data)
+		}
+		if err == nil {
+			return true, nil
+		}
+		clearPendingConversion(event.Conversion.ExternalConversionID)
+		fmt.Printf("[ConversionLifecycle] WAL submit failed, writing synchronously: %v\n", err)
+	}
+
+	_, err := s.PersistConversion(event)
+	return false, err
+}
+
+// ConversionEvent is the zero-drop event payload for a new conversion, or for
+// a status update to a conversion that was still in the WAL when it arrived
+type ConversionEvent struct {
+	Conversion   *models.Conversion      `json:"conversion,omitempty"`
+	OfferID      uuid.UUID               `json:"offer_id"`
+	UserID       uuid.UUID               `json:"user_id"`
+	Source       string                  `json:"source"`
+	Postback     map[string]interface{}  `json:"postback,omitempty"` // Raw postback, for postback webhooks
+	StatusUpdate *ConversionStatusUpdate `json:"status_update,omitempty"`
+}
+
+// ConversionStatusUpdate is a status change addressed by external ID
+type ConversionStatusUpdate struct {
+	ExternalID  string                 `json:"external_id"`
+	UserOfferID uuid.UUID              `json:"user_offer_id"`
+	Status      string                 `json:"status"`
+	Source      string                 `json:"source"`
+	Postback    map[string]interface{} `json:"postback,omitempty"`
+	QueuedAt    time.Time              `json:"queued_at"`
+}
+
+// PersistConversionEvent is the zero-drop processor for conversion events
+func (s *ConversionLifecycleService) PersistConversionEvent(data map[string]interface{}) error {
+	var event ConversionEvent
+	if err := fromEventData(data, &event); err != nil {
+		return fmt.Errorf("invalid conversion event: %w", err)
+	}
+	if event.StatusUpdate != nil {
+		return s.ApplyStatusUpdate(event.StatusUpdate)
+	}
+	if event.Conversion == nil || event.Conversion.ID == uuid.Nil {
+		return fmt.Errorf("invalid conversion event: missing conversion")
+	}
+
+	_, err := s.PersistConversion(&event)
+	if errors.Is(err, ErrDuplicateConversion) {
+		// Another postback for the same order was stored first, so this one
+		// is a status update to it
+		return s.ApplyStatusUpdate(&ConversionStatusUpdate{
+			ExternalID:  event.Conversion.ExternalConversionID,
+			UserOfferID: event.Conversion.UserOfferID,
+			Status:      event.Conversion.Status,
+			Source:      event.Source,
+			Postback:    event.Postback,
+			QueuedAt:    event.Conversion.ConvertedAt,
+		})
+	}
+	return err
+}
+
+// ============================================
+// PENDING (WAL-FIRST) CONVERSIONS
+// ============================================
+
+// pendingConversionTTL bounds how long a WAL'd conversion is expected to take
+// to land; status updates for it are retried until then
+const pendingConversionTTL = 24 * time.Hour
+
+func pendingConversionKey(externalID string) string {
+	return "conversion:pending:" + externalID
+}
+
+func markPendingConversion(externalID string) {
+	if externalID == "" {
+		return
+	}
+	cache.Set(context.Background(), pendingConversionKey(externalID), "1", pendingConversionTTL)
+}
+
+func clearPendingConversion(externalID string) {
+	if externalID == "" {
+		return
+	}
+	cache.Delete(context.Background(), pendingConversionKey(externalID))
+}
+
+// IsPendingConversion reports whether a conversion with this external ID was
+// acknowledged through the WAL and hasn't been stored yet
+func (s *ConversionLifecycleService) IsPendingConversion(externalID string) bool {
+	if externalID == "" {
+		return false
+	}
+	n, err := cache.Exists(context.Background(), pendingConversionKey(externalID))
+	return err == nil && n > 0
+}
+
+// QueueStatusUpdate queues a status update for a conversion that is still in
+// the WAL; it is applied once the conversion has been stored
+func (s *ConversionLifecycleService) QueueStatusUpdate(update *ConversionStatusUpdate, tenantID string) error {
+	update.QueuedAt = time.Now().UTC()
+	data, err := toEventData(&ConversionEvent{Source: update.Source, StatusUpdate: update})
+	if err != nil {
+		return err
+	}
+	return GetZeroDropPipeline().Submit(tenantID, WALEventConversion, data)
+}
+
+// ApplyStatusUpdate applies a queued status update. While the conversion has
+// not landed it returns ErrConversionNotFound so the pipeline retries it;
+// updates that can never apply are logged and dropped.
+func (s *ConversionLifecycleService) ApplyStatusUpdate(update *ConversionStatusUpdate) error {
+	existing, err := s.FindByExternalID(update.ExternalID)
+	if errors.Is(err, ErrConversionNotFound) && time.Since(update.QueuedAt) > pendingConversionTTL {
+		fmt.Printf("[ConversionLifecycle] Dropping status update for %s: conversion never arrived\n", update.ExternalID)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if existing.UserOfferID != update.UserOfferID {
+		fmt.Printf("[ConversionLifecycle] Dropping status update for %s: belongs to a different user offer\n", update.ExternalID)
+		return nil
+	}
+
+	target := existing.ResolveStatusUpdate(update.Status)
+	if target == "" || target == existing.Status {
+		return nil
+	}
+
+	conversion, previous, err := s.Transition(existing.ID, ConversionTransition{
+		ToStatus: target,
+		Source:   update.Source,
+		Reason:   "status update postback: " + update.Status,
+	})
+	if errors.Is(err, ErrInvalidConversionTransition) {
+		fmt.Printf("[ConversionLifecycle] Dropping status update for %s: %v\n", update.ExternalID, err)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("[ConversionLifecycle] Conversion %s: %s -> %s (queued update)\n", conversion.ID, previous, conversion.Status)
+	if update.Postback != nil {
+		GetWebhookService(s.db).EmitPostback(update.Postback, conversion.ID, conversion.UserOfferID)
+	}
+	return nil
+}
+
+// ============================================
+// TRANSITIONS
+// ============================================
+
+// Transition moves a conversion to a new status. The row is locked for the
+// duration so concurrent postbacks can't apply the same transition twice.
+// Approval credits the commission; reversal claws it back.
+func (s *ConversionLifecycleService) Transition(conversionID uuid.UUID, t ConversionTransition) (*models.Conversion, string, error) {
+	var conversion models.Conversion
+	var fromStatus string
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&conversion, "id = ?", conversionID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrConversionNotFound
+			}
+			return err
+		}
+
+		fromStatus = conversion.Status
+		history, err := applyTransition(&conversion, t, time.Now().UTC())
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Save(&conversion).Error; err != nil {
+			return err
+		}
+		if history.CommissionDelta != 0 {
+			if err := applyEarningsDelta(tx, conversion.UserOfferID, history.CommissionDelta); err != nil {
+				return err
+			}
+		}
+
+		return tx.Create(history).Error
+	})
+
+	if err != nil {
+		if errors.Is(err, ErrInvalidConversionTransition) {
+			atomic.AddInt64(&conversionLifecycleMetrics.InvalidTransitions, 1)
+		}
+		return nil, fromStatus, err
+	}
+
+	atomic.AddInt64(&conversionLifecycleMetrics.Transitions, 1)
+	if conversion.Status == models.ConversionStatusReversed {
+		atomic.AddInt64(&conversionLifecycleMetrics.Reversals, 1)
+		atomic.AddInt64(&conversionLifecycleMetrics.ClawedBack, int64(conversion.Commission))
+		fmt.Printf("[ConversionLifecycle] Conversion %s reversed via %s, clawed back %d\n",
+			conversion.ID, t.Source, conversion.Commission)
+	}
+
+	if conversion.IsVoided() && !(&models.Conversion{Status: fromStatus}).IsVoided() {
+		GetLeaderboardService(s.db).RemoveConversion(conversion.UserOfferID, conversion.ConvertedAt)
+	}
+	GetPromoterPostbackService(s.db).EmitConversion(conversion, models.PromoterPostbackEventStatusChanged)
+	GetBadgeEngine(s.db).RecordConversion(conversion.UserOfferID)
+	return &conversion, fromStatus, nil
+}
+
+// applyTransition moves a conversion to t.ToStatus, stamping the transition
+// time and reason, and returns the history row to record. Its commission delta
+// is what the transition credits (approval) or claws back (reversal).
+func applyTransition(conversion *models.Conversion, t ConversionTransition, now time.Time) (*models.ConversionStatusHistory, error) {
+	fromStatus := conversion.Status
+	if !conversion.CanTransitionTo(t.ToStatus) {
+		return nil, fmt.Errorf("%w: %s -> %s", ErrInvalidConversionTransition, fromStatus, t.ToStatus)
+	}
+
+	delta := 0
+	switch t.ToStatus {
+	case models.ConversionStatusApproved:
+		conversion.ApprovedAt = &now
+		delta = conversion.Commission
+	case models.ConversionStatusRejected:
+		conversion.RejectionReason = t.Reason
+	case models.ConversionStatusPaid:
+		conversion.PaidAt = &now
+	case models.ConversionStatusReversed:
+		conversion.ReversedAt = &now
+		conversion.ReversalReason = t.Reason
+		delta = -conversion.Commission
+	}
+	conversion.Status = t.ToStatus
+
+	return &models.ConversionStatusHistory{
+		ID:              uuid.New(),
+		ConversionID:    conversion.ID,
+		FromStatus:      fromStatus,
+		ToStatus:        t.ToStatus,
+		Source:          t.Source,
+		Reason:          t.Reason,
+		ActorID:         t.ActorID,
+		CommissionDelta: delta,
+		CreatedAt:       now,
+	}, nil
+}
+
+// GetHistory returns a conversion's status history, oldest first
+func (s *ConversionLifecycleService) GetHistory(conversionID uuid.UUID) ([]models.ConversionStatusHistory, error) {
+	var history []models.ConversionStatusHistory
+	err := s.db.Where("conversion_id = ?", conversionID).Order("created_at ASC").Find(&history).Error
+	return history, err
+}
+
+// applyEarningsDelta credits (or claws back) commission on the user offer and its owner
+func applyEarningsDelta(tx *gorm.DB, userOfferID uuid.UUID, delta int) error {
+	var userOffer models.UserOffer
+	if err := tx.Select("id", "user_id").First(&userOffer, "id = ?", userOfferID).Error; err != nil {
+		return fmt.Errorf("failed to load user offer: %w", err)
+	}
+
+	if err := tx.Model(&models.UserOffer{}).
+		Where("id = ?", userOfferID).
+		UpdateColumn("earnings", gorm.Expr("earnings + ?", delta)).Error; err != nil {
+		return fmt.Errorf("failed to update user offer earnings: %w", err)
+	}
+
+	if err := tx.Model(&models.AfftokUser{}).
+		Where("id = ?", userOffer.UserID).
+		UpdateColumn("total_earnings", gorm.Expr("total_earnings + ?", delta)).Error; err != nil {
+		return fmt.Errorf("failed to update user earnings: %w", err)
+	}
+
+	return nil
+}
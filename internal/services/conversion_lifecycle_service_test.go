@@ -0,0 +1,247 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// sqlRecorder collects the statements a dry-run session would have executed
+type sqlRecorder struct {
+	statements []string
+}
+
+func (r *sqlRecorder) LogMode(logger.LogLevel) logger.Interface      { return r }
+func (r *sqlRecorder) Info(context.Context, string, ...interface{})  {}
+func (r *sqlRecorder) Warn(context.Context, string, ...interface{})  {}
+func (r *sqlRecorder) Error(context.Context, string, ...interface{}) {}
+
+func (r *sqlRecorder) Trace(_ context.Context, _ time.Time, fc func() (string, int64), _ error) {
+	sql, _ := fc()
+	r.statements = append(r.statements, sql)
+}
+
+func (r *sqlRecorder) find(fragment string) string {
+	for _, sql := range r.statements {
+		if strings.Contains(sql, fragment) {
+			return sql
+		}
+	}
+	return ""
+}
+
+// dryRunDB builds SQL without a database so write paths can be inspected
+func dryRunDB(t *testing.T) (*gorm.DB, *sqlRecorder) {
+	t.Helper()
+	rec := &sqlRecorder{}
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=127.0.0.1 port=1"}), &gorm.Config{
+		DryRun:                 true,
+		DisableAutomaticPing:   true,
+		SkipDefaultTransaction: true,
+		Logger:                 rec,
+	})
+	if err != nil {
+		t.Fatalf("open dry-run db: %v", err)
+	}
+	return db, rec
+}
+
+func TestApplyTransition(t *testing.T) {
+	now := time.Date(2026, 5, 10, 9, 0, 0, 0, time.UTC)
+	actor := uuid.New()
+
+	tests := []struct {
+		name      string
+		from      string
+		to        string
+		wantErr   bool
+		wantDelta int
+	}{
+		{"approve", models.ConversionStatusPending, models.ConversionStatusApproved, false, 250},
+		{"reject", models.ConversionStatusPending, models.ConversionStatusRejected, false, 0},
+		{"pay", models.ConversionStatusApproved, models.ConversionStatusPaid, false, 0},
+		{"reverse", models.ConversionStatusApproved, models.ConversionStatusReversed, false, -250},
+		{"pending cannot be paid", models.ConversionStatusPending, models.ConversionStatusPaid, true, 0},
+		{"paid cannot be reversed", models.ConversionStatusPaid, models.ConversionStatusReversed, true, 0},
+		{"rejected is final", models.ConversionStatusRejected, models.ConversionStatusApproved, true, 0},
+		{"reversed is final", models.ConversionStatusReversed, models.ConversionStatusApproved, true, 0},
+		{"no-op", models.ConversionStatusApproved, models.ConversionStatusApproved, true, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conversion := &models.Conversion{ID: uuid.New(), Status: tt.from, Commission: 250}
+			history, err := applyTransition(conversion, ConversionTransition{
+				ToStatus: tt.to,
+				Source:   models.ConversionSourceAdmin,
+				Reason:   "review",
+				ActorID:  &actor,
+			}, now)
+
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidConversionTransition) {
+					t.Fatalf("err = %v, want ErrInvalidConversionTransition", err)
+				}
+				if conversion.Status != tt.from {
+					t.Errorf("status changed to %q on a refused transition", conversion.Status)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if conversion.Status != tt.to {
+				t.Errorf("status = %q, want %q", conversion.Status, tt.to)
+			}
+			if history.ConversionID != conversion.ID || history.FromStatus != tt.from || history.ToStatus != tt.to {
+				t.Errorf("history = %s %s -> %s, want %s %s -> %s",
+					history.ConversionID, history.FromStatus, history.ToStatus, conversion.ID, tt.from, tt.to)
+			}
+			if history.Source != models.ConversionSourceAdmin || history.Reason != "review" || history.ActorID != &actor {
+				t.Errorf("history source/reason/actor = %q/%q/%v", history.Source, history.Reason, history.ActorID)
+			}
+			if !history.CreatedAt.Equal(now) {
+				t.Errorf("history CreatedAt = %v, want %v", history.CreatedAt, now)
+			}
+			if history.CommissionDelta != tt.wantDelta {
+				t.Errorf("CommissionDelta = %d, want %d", history.CommissionDelta, tt.wantDelta)
+			}
+		})
+	}
+}
+
+func TestApplyTransitionStampsStatusFields(t *testing.T) {
+	now := time.Date(2026, 5, 10, 9, 0, 0, 0, time.UTC)
+
+	approved := &models.Conversion{Status: models.ConversionStatusPending}
+	applyTransition(approved, ConversionTransition{ToStatus: models.ConversionStatusApproved}, now)
+	if approved.ApprovedAt == nil || !approved.ApprovedAt.Equal(now) {
+		t.Errorf("ApprovedAt = %v, want %v", approved.ApprovedAt, now)
+	}
+
+	rejected := &models.Conversion{Status: models.ConversionStatusPending}
+	applyTransition(rejected, ConversionTransition{ToStatus: models.ConversionStatusRejected, Reason: "fraud"}, now)
+	if rejected.RejectionReason != "fraud" {
+		t.Errorf("RejectionReason = %q, want fraud", rejected.RejectionReason)
+	}
+
+	paid := &models.Conversion{Status: models.ConversionStatusApproved}
+	applyTransition(paid, ConversionTransition{ToStatus: models.ConversionStatusPaid}, now)
+	if paid.PaidAt == nil || !paid.PaidAt.Equal(now) {
+		t.Errorf("PaidAt = %v, want %v", paid.PaidAt, now)
+	}
+
+	reversed := &models.Conversion{Status: models.ConversionStatusApproved}
+	applyTransition(reversed, ConversionTransition{ToStatus: models.ConversionStatusReversed, Reason: "refund"}, now)
+	if reversed.ReversedAt == nil || !reversed.ReversedAt.Equal(now) || reversed.ReversalReason != "refund" {
+		t.Errorf("ReversedAt/ReversalReason = %v/%q", reversed.ReversedAt, reversed.ReversalReason)
+	}
+}
+
+func TestApplyEarningsDelta(t *testing.T) {
+	db, rec := dryRunDB(t)
+
+	if err := applyEarningsDelta(db, uuid.New(), -250); err != nil {
+		t.Fatalf("applyEarningsDelta: %v", err)
+	}
+
+	if sql := rec.find(`UPDATE "user_offers" SET "earnings"=earnings + -250`); sql == "" {
+		t.Errorf("no user offer earnings update in %q", rec.statements)
+	}
+	if sql := rec.find(`SET "total_earnings"=total_earnings + -250`); sql == "" {
+		t.Errorf("no user total earnings update in %q", rec.statements)
+	}
+}
+
+func TestRecordCreationHistory(t *testing.T) {
+	s := &ConversionLifecycleService{}
+
+	t.Run("pending conversion credits nothing", func(t *testing.T) {
+		db, rec := dryRunDB(t)
+		conversion := &models.Conversion{ID: uuid.New(), Status: models.ConversionStatusPending, Commission: 250}
+		if err := s.RecordCreation(db, conversion, models.ConversionSourcePostback); err != nil {
+			t.Fatalf("RecordCreation: %v", err)
+		}
+		if rec.find("earnings") != "" {
+			t.Errorf("pending conversion touched earnings: %q", rec.statements)
+		}
+		insert := rec.find(`INSERT INTO "conversion_status_history"`)
+		if insert == "" || !strings.Contains(insert, "'pending','postback'") {
+			t.Errorf("history insert = %q", insert)
+		}
+	})
+
+	t.Run("approved conversion credits its commission", func(t *testing.T) {
+		db, rec := dryRunDB(t)
+		conversion := &models.Conversion{ID: uuid.New(), Status: models.ConversionStatusApproved, Commission: 250}
+		if err := s.RecordCreation(db, conversion, models.ConversionSourcePostback); err != nil {
+			t.Fatalf("RecordCreation: %v", err)
+		}
+		if rec.find("earnings + 250") == "" {
+			t.Errorf("approved conversion was not credited: %q", rec.statements)
+		}
+		insert := rec.find(`INSERT INTO "conversion_status_history"`)
+		if insert == "" || !strings.Contains(insert, "'approved','postback','',NULL,250") {
+			t.Errorf("history insert = %q", insert)
+		}
+	})
+}
+
+// A refund that arrives long after the attribution window still reverses the
+// stored conversion and claws its commission back; the window only refuses
+// new conversions.
+func TestRefundAfterAttributionWindowReversesConversion(t *testing.T) {
+	clickedAt := time.Date(2026, 1, 5, 12, 0, 0, 0, time.UTC)
+	refundAt := clickedAt.Add(60 * 24 * time.Hour)
+
+	click := &models.Click{
+		ClickedAt: clickedAt,
+		UserOffer: &models.UserOffer{Offer: &models.Offer{
+			AttributionWindowDays: 7,
+			AttributionPolicy:     models.AttributionPolicyReject,
+		}},
+	}
+	if result := (&AttributionService{}).evaluateWindow(click, refundAt); !result.IsRejected() {
+		t.Fatalf("decision = %q, want the window to be closed", result.Decision)
+	}
+
+	conversion := &models.Conversion{
+		ID:          uuid.New(),
+		UserOfferID: uuid.New(),
+		Status:      models.ConversionStatusApproved,
+		Commission:  250,
+	}
+	status := conversion.ResolveStatusUpdate("refunded")
+	if status != models.ConversionStatusReversed {
+		t.Fatalf("refund resolved to %q, want reversed", status)
+	}
+
+	history, err := applyTransition(conversion, ConversionTransition{
+		ToStatus: status,
+		Source:   models.ConversionSourcePostback,
+		Reason:   "refunded",
+	}, refundAt)
+	if err != nil {
+		t.Fatalf("applyTransition: %v", err)
+	}
+	if conversion.Status != models.ConversionStatusReversed || history.CommissionDelta != -250 {
+		t.Fatalf("status = %q, delta = %d, want reversed and -250", conversion.Status, history.CommissionDelta)
+	}
+
+	db, rec := dryRunDB(t)
+	if err := applyEarningsDelta(db, conversion.UserOfferID, history.CommissionDelta); err != nil {
+		t.Fatalf("applyEarningsDelta: %v", err)
+	}
+	if rec.find("earnings + -250") == "" {
+		t.Errorf("earnings were not clawed back: %q", rec.statements)
+	}
+}
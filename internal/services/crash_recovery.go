@@ -36,6 +36,7 @@ type CrashRecoveryEngine struct {
 	clickHandler      func(data map[string]interface{}) error
 	conversionHandler func(data map[string]interface{}) error
 	postbackHandler   func(data map[string]interface{}) error
+	edgeEventHandler  func(data map[string]interface{}) error
 }
 
 // NewCrashRecoveryEngine creates a new crash recovery engine
@@ -63,6 +64,11 @@ func (e *CrashRecoveryEngine) SetPostbackHandler(handler func(data map[string]in
 	e.postbackHandler = handler
 }
 
+// SetEdgeEventHandler sets the edge event recovery handler
+func (e *CrashRecoveryEngine) SetEdgeEventHandler(handler func(data map[string]interface{}) error) {
+	e.edgeEventHandler = handler
+}
+
 // ============================================
 // RECOVERY OPERATIONS
 // ============================================
@@ -208,6 +214,13 @@ func (e *CrashRecoveryEngine) processEntry(entry *WALEntry) error {
 		}
 		return e.defaultPostbackHandler(entry.Data)
 
+	case WALEventEdgeEvent:
+		// Edge events spilled to the WAL on shutdown
+		if e.edgeEventHandler != nil {
+			return e.edgeEventHandler(entry.Data)
+		}
+		return nil
+
 	default:
 		return nil
 	}
@@ -560,13 +573,23 @@ func InitZeroDrop(db *gorm.DB) error {
 	walService := GetWALService()
 	walService.Start()
 	
+	// Initialize crash recovery engine
+	recoveryEngine := GetCrashRecoveryEngine(db)
+	
+	// Route the stream consumer, failover queue and recovery to the
+	// click and conversion writers
+	pipeline := GetZeroDropPipeline()
+	pipeline.RegisterProcessor(WALEventClick, NewClickService().PersistClickEvent)
+	pipeline.RegisterProcessor(WALEventConversion, NewConversionLifecycleService(db).PersistConversionEvent)
+	pipeline.attach(recoveryEngine)
+	recoveryEngine.SetEdgeEventHandler(GetEdgeIngestService(db).PersistEdgeEvent)
+	recoveryEngine.SetPostbackHandler(GetPostbackQueueService().RestorePostbackEvent)
+	GetPromoterPostbackService(db) // Registers its result handler before replayed postbacks are sent
+	
 	// Initialize failover queue
 	failoverQueue := GetFailoverQueue()
 	failoverQueue.Start()
 	
-	// Initialize crash recovery engine
-	recoveryEngine := GetCrashRecoveryEngine(db)
-	
 	// Perform recovery on startup
 	result, err := recoveryEngine.Recover()
 	if err != nil {
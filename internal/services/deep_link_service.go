@@ -0,0 +1,420 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aljapah/afftok-backend-prod/internal/cache"
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/google/uuid"
+	"golang.org/x/net/publicsuffix"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ============================================
+// DEEP LINK SERVICE
+// ============================================
+
+const (
+	// DeepLinkQueryParam carries the signed deep link target on a tracking link
+	DeepLinkQueryParam = "dl"
+
+	// MaxDeepLinkDomains is the most domains an offer may allow deep links to
+	MaxDeepLinkDomains = 20
+
+	maxDeepLinkURLLength = 2048
+
+	deepLinkCachePrefix = "deeplink:"
+	deepLinkCacheTTL    = 24 * time.Hour
+)
+
+// Deep link errors
+var (
+	ErrDeepLinksDisabled        = errors.New("this offer doesn't allow deep links")
+	ErrDeepLinkDomainNotAllowed = errors.New("target_url is not on one of the offer's allowed domains")
+	ErrInvalidDeepLinkTarget    = errors.New("target_url must be an absolute http or https URL")
+)
+
+var deepLinkDomainPattern = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z]{2,63}$`)
+
+// sharedStoreDomains host many merchants' stores but aren't on the public
+// suffix list. Allowing one would let a promoter deep link to any store on
+// the platform, so offers must list their own store host instead.
+var sharedStoreDomains = map[string]bool{
+	"salla.sa":        true,
+	"salla.store":     true,
+	"zid.sa":          true,
+	"zid.store":       true,
+	"shopify.com":     true,
+	"wordpress.com":   true,
+	"squarespace.com": true,
+}
+
+// DeepLinkService validates, stores and reports on deep links
+type DeepLinkService struct {
+	db *gorm.DB
+}
+
+// NewDeepLinkService creates a new deep link service
+func NewDeepLinkService(db *gorm.DB) *DeepLinkService {
+	return &DeepLinkService{db: db}
+}
+
+// ============================================
+// DOMAINS AND TARGETS
+// ============================================
+
+// NormalizeDeepLinkDomains validates an offer's deep link domains and returns
+// them in their stored (comma separated) form. Schemes, paths and a leading
+// "*." or "www." are stripped. Public suffixes (com.sa, myshopify.com) and
+// shared store platforms are rejected: list the store's own host instead
+// (mystore.myshopify.com).
+func NormalizeDeepLinkDomains(domains []string) (string, error) {
+	seen := make(map[string]bool)
+	var names []string
+	for _, domain := range domains {
+		domain = strings.ToLower(strings.TrimSpace(domain))
+		if i := strings.Index(domain, "://"); i >= 0 {
+			domain = domain[i+3:]
+		}
+		if i := strings.IndexAny(domain, "/?#:"); i >= 0 {
+			domain = domain[:i]
+		}
+		domain = strings.TrimPrefix(domain, "*.")
+		domain = strings.TrimPrefix(domain, "www.")
+		domain = strings.TrimSuffix(domain, ".")
+		if domain == "" || seen[domain] {
+			continue
+		}
+		if !deepLinkDomainPattern.MatchString(domain) {
+			return "", fmt.Errorf("invalid deep link domain %q", domain)
+		}
+		if isSharedDomain(domain) {
+			return "", fmt.Errorf("deep link domain %q is shared by many sites, use your own store's host", domain)
+		}
+		seen[domain] = true
+		names = append(names, domain)
+	}
+	if len(names) > MaxDeepLinkDomains {
+		return "", fmt.Errorf("at most %d deep link domains are allowed", MaxDeepLinkDomains)
+	}
+	return strings.Join(names, ","), nil
+}
+
+// ValidateTarget checks that a deep link target is an http(s) URL on one of
+// the offer's allowed domains and returns it normalized
+func (s *DeepLinkService) ValidateTarget(offer *models.Offer, target string) (string, error) {
+	domains := offer.DeepLinkDomainList()
+	if len(domains) == 0 {
+		return "", ErrDeepLinksDisabled
+	}
+
+	target = strings.TrimSpace(target)
+	if target == "" || len(target) > maxDeepLinkURLLength {
+		return "", ErrInvalidDeepLinkTarget
+	}
+	parsed, err := url.Parse(target)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.User != nil {
+		return "", ErrInvalidDeepLinkTarget
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(parsed.Hostname()), ".")
+	if host == "" || net.ParseIP(host) != nil {
+		return "", ErrInvalidDeepLinkTarget
+	}
+	if !hostInDomains(host, ownDomains(domains)) {
+		return "", ErrDeepLinkDomainNotAllowed
+	}
+
+	parsed.Host = strings.ToLower(parsed.Host)
+	return parsed.String(), nil
+}
+
+// hostInDomains reports whether host is one of the domains or a subdomain
+func hostInDomains(host string, domains []string) bool {
+	for _, domain := range domains {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// ownDomains drops the shared domains an offer may have stored before they
+// were rejected, so they don't match every site on the platform
+func ownDomains(domains []string) []string {
+	own := make([]string, 0, len(domains))
+	for _, domain := range domains {
+		if !isSharedDomain(domain) {
+			own = append(own, domain)
+		}
+	}
+	return own
+}
+
+// isSharedDomain reports whether domain is a public suffix (including
+// hosting platforms on the list, like myshopify.com) or a shared store
+// platform, whose subdomains belong to unrelated sites
+func isSharedDomain(domain string) bool {
+	if suffix, _ := publicsuffix.PublicSuffix(domain); suffix == domain {
+		return true
+	}
+	return sharedStoreDomains[domain]
+}
+
+// ============================================
+// DEEP LINKS
+// ============================================
+
+// Create returns the promoter's deep link to target, creating it on first use.
+// userOffer must have its Offer loaded.
+func (s *DeepLinkService) Create(userOffer *models.UserOffer, target string) (*models.DeepLink, error) {
+	target, err := s.ValidateTarget(userOffer.Offer, target)
+	if err != nil {
+		return nil, err
+	}
+	return s.findOrCreate(userOffer, target)
+}
+
+// Resolve returns the deep link for a target taken from a signed tracking
+// link, or nil if the target is no longer on the offer's allowed domains.
+// Clicks only read: the deep link was stored when the promoter created it,
+// so it is written here only if it has gone missing since.
+func (s *DeepLinkService) Resolve(offer *models.Offer, userOffer *models.UserOffer, target string) *models.DeepLink {
+	target, err := s.ValidateTarget(offer, target)
+	if err != nil {
+		fmt.Printf("[DeepLink] Ignoring target for user offer %s: %v\n", userOffer.ID, err)
+		return nil
+	}
+
+	hash := deepLinkTargetHash(target)
+	if id := cachedDeepLinkID(userOffer.ID, hash); id != uuid.Nil {
+		return &models.DeepLink{
+			ID:          id,
+			UserOfferID: userOffer.ID,
+			OfferID:     userOffer.OfferID,
+			UserID:      userOffer.UserID,
+			TargetURL:   target,
+			TargetHash:  hash,
+		}
+	}
+
+	var deepLink models.DeepLink
+	err = s.db.Where("user_offer_id = ? AND target_hash = ?", userOffer.ID, hash).First(&deepLink).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		var created *models.DeepLink
+		if created, err = s.findOrCreate(userOffer, target); err == nil {
+			deepLink = *created
+		}
+	}
+	if err != nil {
+		// Still send the visitor to the page, just without deep link stats
+		fmt.Printf("[DeepLink] Failed to record deep link: %v\n", err)
+		return &models.DeepLink{TargetURL: target}
+	}
+
+	cacheDeepLinkID(userOffer.ID, hash, deepLink.ID)
+	return &deepLink
+}
+
+func (s *DeepLinkService) findOrCreate(userOffer *models.UserOffer, target string) (*models.DeepLink, error) {
+	hash := deepLinkTargetHash(target)
+
+	deepLink := models.DeepLink{
+		ID:          uuid.New(),
+		UserOfferID: userOffer.ID,
+		OfferID:     userOffer.OfferID,
+		UserID:      userOffer.UserID,
+		TargetURL:   target,
+		TargetHash:  hash,
+		CreatedAt:   time.Now().UTC(),
+	}
+	if err := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&deepLink).Error; err != nil {
+		return nil, fmt.Errorf("failed to save deep link: %w", err)
+	}
+
+	var existing models.DeepLink
+	if err := s.db.Where("user_offer_id = ? AND target_hash = ?", userOffer.ID, hash).First(&existing).Error; err != nil {
+		return nil, fmt.Errorf("failed to load deep link: %w", err)
+	}
+	cacheDeepLinkID(userOffer.ID, hash, existing.ID)
+	return &existing, nil
+}
+
+// deepLinkTargetHash is the stored hash of a normalized target URL
+func deepLinkTargetHash(target string) string {
+	sum := sha256.Sum256([]byte(target))
+	return hex.EncodeToString(sum[:])
+}
+
+func deepLinkCacheKey(userOfferID uuid.UUID, hash string) string {
+	return deepLinkCachePrefix + userOfferID.String() + ":" + hash
+}
+
+// cachedDeepLinkID returns the cached deep link ID, or uuid.Nil on a miss
+func cachedDeepLinkID(userOfferID uuid.UUID, hash string) uuid.UUID {
+	value, err := cache.Get(context.Background(), deepLinkCacheKey(userOfferID, hash))
+	if err != nil {
+		return uuid.Nil
+	}
+	id, err := uuid.Parse(value)
+	if err != nil {
+		return uuid.Nil
+	}
+	return id
+}
+
+func cacheDeepLinkID(userOfferID uuid.UUID, hash string, id uuid.UUID) {
+	cache.Set(context.Background(), deepLinkCacheKey(userOfferID, hash), id.String(), deepLinkCacheTTL)
+}
+
+// ============================================
+// REPORTS
+// ============================================
+
+// DeepLinkReportRow is the traffic and results of one target URL
+type DeepLinkReportRow struct {
+	TargetURL           string  `json:"target_url"`
+	Links               int64   `json:"links"` // Promoters' deep links to this URL
+	Clicks              int64   `json:"clicks"`
+	Conversions         int64   `json:"conversions"`
+	ApprovedConversions int64   `json:"approved_conversions"`
+	Revenue             int64   `json:"revenue"`    // Cents, all conversions
+	Commission          int64   `json:"commission"` // Cents, approved and paid conversions
+	ConversionRate      float64 `json:"conversion_rate"`
+}
+
+// DeepLinkReport breaks deep link clicks and conversions down by target URL
+type DeepLinkReport struct {
+	From time.Time           `json:"from"`
+	To   time.Time           `json:"to"`
+	Rows []DeepLinkReportRow `json:"rows"`
+}
+
+// ReportForPromoter reports on a promoter's deep links, or those of one user offer
+func (s *DeepLinkService) ReportForPromoter(userID uuid.UUID, userOfferID *uuid.UUID, days, limit int) (*DeepLinkReport, error) {
+	scope := s.db.Model(&models.DeepLink{}).Where("user_id = ?", userID)
+	if userOfferID != nil {
+		scope = scope.Where("user_offer_id = ?", *userOfferID)
+	}
+	return s.report(scope, days, limit)
+}
+
+// ReportForOffer reports on all promoters' deep links to an offer
+func (s *DeepLinkService) ReportForOffer(offerID uuid.UUID, days, limit int) (*DeepLinkReport, error) {
+	return s.report(s.db.Model(&models.DeepLink{}).Where("offer_id = ?", offerID), days, limit)
+}
+
+// report aggregates the clicks (and their conversions) of the deep links
+// selected by scope, by target URL
+func (s *DeepLinkService) report(scope *gorm.DB, days, limit int) (*DeepLinkReport, error) {
+	if days <= 0 {
+		days = defaultSubIDReportDays
+	}
+	if days > maxSubIDReportDays {
+		days = maxSubIDReportDays
+	}
+	if limit <= 0 {
+		limit = defaultSubIDReportLimit
+	}
+	if limit > maxSubIDReportLimit {
+		limit = maxSubIDReportLimit
+	}
+
+	to := time.Now().UTC()
+	from := to.AddDate(0, 0, -days)
+
+	var deepLinks []models.DeepLink
+	if err := scope.Select("id", "target_url").Find(&deepLinks).Error; err != nil {
+		return nil, fmt.Errorf("failed to load deep links: %w", err)
+	}
+
+	report := &DeepLinkReport{From: from, To: to, Rows: []DeepLinkReportRow{}}
+	if len(deepLinks) == 0 {
+		return report, nil
+	}
+
+	ids := make([]uuid.UUID, len(deepLinks))
+	targets := make(map[uuid.UUID]string, len(deepLinks))
+	byTarget := make(map[string]*DeepLinkReportRow)
+	for i, deepLink := range deepLinks {
+		ids[i] = deepLink.ID
+		targets[deepLink.ID] = deepLink.TargetURL
+		row, ok := byTarget[deepLink.TargetURL]
+		if !ok {
+			row = &DeepLinkReportRow{TargetURL: deepLink.TargetURL}
+			byTarget[deepLink.TargetURL] = row
+		}
+		row.Links++
+	}
+
+	var clickRows []struct {
+		DeepLinkID uuid.UUID
+		Clicks     int64
+	}
+	if err := s.db.Model(&models.Click{}).
+		Select("deep_link_id, COUNT(*) AS clicks").
+		Where("deep_link_id IN ? AND clicked_at BETWEEN ? AND ?", ids, from, to).
+		Group("deep_link_id").
+		Scan(&clickRows).Error; err != nil {
+		return nil, fmt.Errorf("failed to aggregate clicks: %w", err)
+	}
+
+	var conversionRows []struct {
+		DeepLinkID          uuid.UUID
+		Conversions         int64
+		ApprovedConversions int64
+		Revenue             int64
+		Commission          int64
+	}
+	if err := s.db.Table("conversions").
+		Select(`clicks.deep_link_id,
+			COUNT(*) AS conversions,
+			COUNT(*) FILTER (WHERE conversions.status IN ?) AS approved_conversions,
+			COALESCE(SUM(conversions.amount), 0) AS revenue,
+			COALESCE(SUM(conversions.commission) FILTER (WHERE conversions.status IN ?), 0) AS commission`,
+			creditedConversionStatuses, creditedConversionStatuses).
+		Joins("JOIN clicks ON clicks.id = conversions.click_id").
+		Where("clicks.deep_link_id IN ? AND conversions.converted_at BETWEEN ? AND ?", ids, from, to).
+		Group("clicks.deep_link_id").
+		Scan(&conversionRows).Error; err != nil {
+		return nil, fmt.Errorf("failed to aggregate conversions: %w", err)
+	}
+
+	for _, c := range clickRows {
+		byTarget[targets[c.DeepLinkID]].Clicks += c.Clicks
+	}
+	for _, c := range conversionRows {
+		row := byTarget[targets[c.DeepLinkID]]
+		row.Conversions += c.Conversions
+		row.ApprovedConversions += c.ApprovedConversions
+		row.Revenue += c.Revenue
+		row.Commission += c.Commission
+	}
+
+	for _, row := range byTarget {
+		row.ConversionRate = conversionRate(row.Conversions, row.Clicks)
+		report.Rows = append(report.Rows, *row)
+	}
+	sort.Slice(report.Rows, func(i, j int) bool {
+		if report.Rows[i].Clicks != report.Rows[j].Clicks {
+			return report.Rows[i].Clicks > report.Rows[j].Clicks
+		}
+		return report.Rows[i].TargetURL < report.Rows[j].TargetURL
+	})
+	if len(report.Rows) > limit {
+		report.Rows = report.Rows[:limit]
+	}
+
+	return report, nil
+}
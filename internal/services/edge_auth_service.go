@@ -0,0 +1,533 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aljapah/afftok-backend-prod/internal/cache"
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/aljapah/afftok-backend-prod/pkg/utils"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ============================================
+// EDGE AUTH SERVICE
+// ============================================
+
+// EdgeAuthService authenticates edge workers calling the internal edge
+// endpoints. Each request carries a key ID, timestamp and nonce, and an
+// HMAC-SHA256 signature over:
+//
+//	METHOD \n PATH \n TIMESTAMP \n NONCE \n hex(SHA256(body))
+//
+// keyed with the credential's secret. The body is signed as sent, so gzip
+// batches are signed compressed.
+type EdgeAuthService struct {
+	db *gorm.DB
+
+	mu          sync.RWMutex
+	credentials map[string]*edgeCredentialEntry // by key ID
+	loadedAt    time.Time
+	lastTouched map[uuid.UUID]time.Time
+}
+
+// edgeCredentialEntry is a credential with its decrypted secret
+type edgeCredentialEntry struct {
+	credential models.EdgeCredential
+	secret     string
+}
+
+// Edge auth headers
+const (
+	EdgeKeyIDHeader     = "X-Edge-Key-Id"
+	EdgeTimestampHeader = "X-Edge-Timestamp"
+	EdgeNonceHeader     = "X-Edge-Nonce"
+	EdgeSignatureHeader = "X-Edge-Signature"
+)
+
+// Edge auth configuration
+const (
+	EdgeMaxBodyBytes             = 20 << 20
+	edgeMaxClockSkew             = 5 * time.Minute
+	edgeNonceTTL                 = 2 * edgeMaxClockSkew
+	edgeNonceKeyPrefix           = "edge:nonce:"
+	edgeQuotaKeyPrefix           = "edge:quota:"
+	edgeCredentialRefresh        = 30 * time.Second
+	edgeCredentialTouchInterval  = time.Minute
+	defaultEdgeKeyOverlap        = 24 * time.Hour
+	defaultEdgeRequestsPerMinute = 600
+	defaultEdgeEventsPerMinute   = 60000
+)
+
+// Edge auth rejection reasons
+const (
+	EdgeRejectMissingCredentials = "missing_credentials"
+	EdgeRejectUnknownKey         = "unknown_key"
+	EdgeRejectKeyExpired         = "key_expired"
+	EdgeRejectInvalidSignature   = "invalid_signature"
+	EdgeRejectTimestampSkew      = "timestamp_out_of_window"
+	EdgeRejectNonceReplay        = "nonce_replay"
+	EdgeRejectQuotaExceeded      = "quota_exceeded"
+	EdgeRejectBodyTooLarge       = "body_too_large"
+)
+
+// ErrEdgeCredentialNotFound is returned for unknown credential IDs
+var ErrEdgeCredentialNotFound = errors.New("edge credential not found")
+
+// EdgeAuthError is a rejected edge request
+type EdgeAuthError struct {
+	Status  int
+	Reason  string
+	Message string
+}
+
+func (e *EdgeAuthError) Error() string {
+	return e.Message
+}
+
+// NewEdgeAuthService creates a new edge auth service
+func NewEdgeAuthService(db *gorm.DB) *EdgeAuthService {
+	return &EdgeAuthService{
+		db:          db,
+		credentials: make(map[string]*edgeCredentialEntry),
+		lastTouched: make(map[uuid.UUID]time.Time),
+	}
+}
+
+var (
+	edgeAuthInstance *EdgeAuthService
+	edgeAuthOnce     sync.Once
+)
+
+// GetEdgeAuthService returns the global edge auth service
+func GetEdgeAuthService(db *gorm.DB) *EdgeAuthService {
+	edgeAuthOnce.Do(func() {
+		edgeAuthInstance = NewEdgeAuthService(db)
+	})
+	return edgeAuthInstance
+}
+
+// ============================================
+// EDGE AUTH METRICS
+// ============================================
+
+// EdgeAuthMetrics tracks edge authentication outcomes
+type EdgeAuthMetrics struct {
+	Accepted            int64            `json:"accepted"`
+	Rejected            int64            `json:"rejected"`
+	RejectedByReason    map[string]int64 `json:"rejected_by_reason"`
+	RejectedByLocation  map[string]int64 `json:"rejected_by_location"`
+	EventsByLocation    map[string]int64 `json:"events_by_location"`
+	NonceChecksSkipped  int64            `json:"nonce_checks_skipped"` // Redis unavailable
+	QuotaChecksSkipped  int64            `json:"quota_checks_skipped"` // Redis unavailable
+	UnauthenticatedSeen int64            `json:"unauthenticated_seen"` // Let through in monitor mode
+}
+
+var (
+	edgeAuthAccepted            int64
+	edgeAuthRejected            int64
+	edgeAuthNonceSkipped        int64
+	edgeAuthQuotaSkipped        int64
+	edgeAuthUnauthenticatedSeen int64
+	edgeAuthByReason            sync.Map // reason -> *int64
+	edgeAuthByLocation          sync.Map // location -> *int64
+	edgeEventsByLocation        sync.Map // location -> *int64
+)
+
+// GetEdgeAuthMetrics returns edge auth metrics
+func GetEdgeAuthMetrics() *EdgeAuthMetrics {
+	return &EdgeAuthMetrics{
+		Accepted:            atomic.LoadInt64(&edgeAuthAccepted),
+		Rejected:            atomic.LoadInt64(&edgeAuthRejected),
+		RejectedByReason:    loadCounterMap(&edgeAuthByReason),
+		RejectedByLocation:  loadCounterMap(&edgeAuthByLocation),
+		EventsByLocation:    loadCounterMap(&edgeEventsByLocation),
+		NonceChecksSkipped:  atomic.LoadInt64(&edgeAuthNonceSkipped),
+		QuotaChecksSkipped:  atomic.LoadInt64(&edgeAuthQuotaSkipped),
+		UnauthenticatedSeen: atomic.LoadInt64(&edgeAuthUnauthenticatedSeen),
+	}
+}
+
+// RecordRejection counts a rejected edge request. The location must come
+// from a configured credential, so the per-location counters stay bounded;
+// requests without one are counted as "unknown".
+func (s *EdgeAuthService) RecordRejection(location, reason string) {
+	if location == "" {
+		location = "unknown"
+	}
+	atomic.AddInt64(&edgeAuthRejected, 1)
+	addToCounterMap(&edgeAuthByReason, reason, 1)
+	addToCounterMap(&edgeAuthByLocation, location, 1)
+}
+
+// RecordUnauthenticated counts a request let through without valid
+// credentials because edge auth is in monitor mode
+func (s *EdgeAuthService) RecordUnauthenticated() {
+	atomic.AddInt64(&edgeAuthUnauthenticatedSeen, 1)
+}
+
+func addToCounterMap(m *sync.Map, key string, delta int64) {
+	counter, _ := m.LoadOrStore(key, new(int64))
+	atomic.AddInt64(counter.(*int64), delta)
+}
+
+func loadCounterMap(m *sync.Map) map[string]int64 {
+	out := make(map[string]int64)
+	m.Range(func(key, value interface{}) bool {
+		out[key.(string)] = atomic.LoadInt64(value.(*int64))
+		return true
+	})
+	return out
+}
+
+// ============================================
+// CREDENTIAL MANAGEMENT
+// ============================================
+
+// CreateCredential issues a new key for an edge location. Returns the
+// plaintext secret, which is only shown once.
+func (s *EdgeAuthService) CreateCredential(name, location string, requestsPerMinute, eventsPerMinute int) (*models.EdgeCredential, string, error) {
+	location = strings.TrimSpace(location)
+	if location == "" {
+		return nil, "", fmt.Errorf("edge_location is required")
+	}
+	if requestsPerMinute < 0 || eventsPerMinute < 0 {
+		return nil, "", fmt.Errorf("quotas must not be negative")
+	}
+
+	credential, secret, err := newEdgeCredential(name, location, requestsPerMinute, eventsPerMinute)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := s.db.Create(credential).Error; err != nil {
+		return nil, "", fmt.Errorf("failed to create edge credential: %w", err)
+	}
+
+	s.invalidate()
+	return credential, secret, nil
+}
+
+// RotateCredential issues a replacement key for the same edge location. The
+// old key stays valid for the overlap (24h if zero) so workers can be
+// redeployed without dropping batches.
+func (s *EdgeAuthService) RotateCredential(id uuid.UUID, overlap time.Duration) (*models.EdgeCredential, string, error) {
+	if overlap <= 0 {
+		overlap = defaultEdgeKeyOverlap
+	}
+
+	var old models.EdgeCredential
+	if err := s.db.First(&old, "id = ?", id).Error; err != nil {
+		return nil, "", ErrEdgeCredentialNotFound
+	}
+	now := time.Now().UTC()
+	if !old.IsValidAt(now) {
+		return nil, "", fmt.Errorf("credential is no longer valid and can't be rotated")
+	}
+
+	replacement, secret, err := newEdgeCredential(old.Name, old.EdgeLocation, old.RequestsPerMinute, old.EventsPerMinute)
+	if err != nil {
+		return nil, "", err
+	}
+
+	expiresAt := now.Add(overlap)
+	if old.ExpiresAt != nil && old.ExpiresAt.Before(expiresAt) {
+		expiresAt = *old.ExpiresAt
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(replacement).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.EdgeCredential{}).
+			Where("id = ?", old.ID).
+			Updates(map[string]interface{}{
+				"expires_at":     expiresAt,
+				"replaced_by_id": replacement.ID,
+				"updated_at":     now,
+			}).Error
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to rotate edge credential: %w", err)
+	}
+
+	s.invalidate()
+	return replacement, secret, nil
+}
+
+// RevokeCredential stops accepting a key immediately
+func (s *EdgeAuthService) RevokeCredential(id uuid.UUID) error {
+	result := s.db.Model(&models.EdgeCredential{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"status":     models.EdgeCredentialStatusRevoked,
+			"updated_at": time.Now().UTC(),
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrEdgeCredentialNotFound
+	}
+
+	s.invalidate()
+	return nil
+}
+
+// ListCredentials returns all edge credentials (hints only)
+func (s *EdgeAuthService) ListCredentials() ([]models.EdgeCredential, error) {
+	var credentials []models.EdgeCredential
+	err := s.db.Order("edge_location ASC, created_at DESC").Find(&credentials).Error
+	return credentials, err
+}
+
+// newEdgeCredential generates a key ID and secret
+func newEdgeCredential(name, location string, requestsPerMinute, eventsPerMinute int) (*models.EdgeCredential, string, error) {
+	keyBytes := make([]byte, 12)
+	secretBytes := make([]byte, 32)
+	if _, err := rand.Read(keyBytes); err != nil {
+		return nil, "", err
+	}
+	if _, err := rand.Read(secretBytes); err != nil {
+		return nil, "", err
+	}
+	secret := hex.EncodeToString(secretBytes)
+
+	encrypted, err := utils.EncryptSecret(secret)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to encrypt secret: %w", err)
+	}
+
+	now := time.Now().UTC()
+	return &models.EdgeCredential{
+		ID:                uuid.New(),
+		Name:              name,
+		EdgeLocation:      location,
+		KeyID:             "edk_" + hex.EncodeToString(keyBytes),
+		SecretEncrypted:   encrypted,
+		SecretHint:        secretHint(secret),
+		Status:            models.EdgeCredentialStatusActive,
+		ValidFrom:         now,
+		RequestsPerMinute: requestsPerMinute,
+		EventsPerMinute:   eventsPerMinute,
+		CreatedAt:         now,
+		UpdatedAt:         now,
+	}, secret, nil
+}
+
+// ============================================
+// CREDENTIAL CACHE
+// ============================================
+
+// lookup returns a credential by key ID, reloading the in-memory set when it
+// is stale. Revocations on other instances take effect within the refresh
+// interval.
+func (s *EdgeAuthService) lookup(keyID string) (*edgeCredentialEntry, error) {
+	s.mu.RLock()
+	entry, ok := s.credentials[keyID]
+	fresh := time.Since(s.loadedAt) < edgeCredentialRefresh
+	s.mu.RUnlock()
+
+	if fresh {
+		return entry, nil
+	}
+	if err := s.reload(); err != nil {
+		if ok {
+			return entry, nil // Serve the stale set while the database is down
+		}
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.credentials[keyID], nil
+}
+
+// reload loads every credential that can still be valid
+func (s *EdgeAuthService) reload() error {
+	var records []models.EdgeCredential
+	err := s.db.Where("status = ? AND (expires_at IS NULL OR expires_at > ?)",
+		models.EdgeCredentialStatusActive, time.Now().UTC()).
+		Find(&records).Error
+	if err != nil {
+		return err
+	}
+
+	credentials := make(map[string]*edgeCredentialEntry, len(records))
+	for _, record := range records {
+		secret, err := utils.DecryptSecret(record.SecretEncrypted)
+		if err != nil {
+			fmt.Printf("[EdgeAuth] Can't decrypt secret for %s: %v\n", record.KeyID, err)
+			continue
+		}
+		credentials[record.KeyID] = &edgeCredentialEntry{credential: record, secret: secret}
+	}
+
+	s.mu.Lock()
+	s.credentials = credentials
+	s.loadedAt = time.Now()
+	s.mu.Unlock()
+	return nil
+}
+
+// invalidate forces the next lookup to reload
+func (s *EdgeAuthService) invalidate() {
+	s.mu.Lock()
+	s.loadedAt = time.Time{}
+	s.mu.Unlock()
+}
+
+// touch records credential use, at most once a minute per credential
+func (s *EdgeAuthService) touch(id uuid.UUID) {
+	now := time.Now()
+	s.mu.Lock()
+	if now.Sub(s.lastTouched[id]) < edgeCredentialTouchInterval {
+		s.mu.Unlock()
+		return
+	}
+	s.lastTouched[id] = now
+	s.mu.Unlock()
+
+	go s.db.Model(&models.EdgeCredential{}).Where("id = ?", id).UpdateColumn("last_used_at", now.UTC())
+}
+
+// ============================================
+// VERIFICATION
+// ============================================
+
+// Verify authenticates a signed edge request and claims its nonce
+func (s *EdgeAuthService) Verify(method, path string, header http.Header, body []byte) (*models.EdgeCredential, *EdgeAuthError) {
+	keyID := header.Get(EdgeKeyIDHeader)
+	timestamp := header.Get(EdgeTimestampHeader)
+	nonce := header.Get(EdgeNonceHeader)
+	signature := strings.ToLower(strings.TrimSpace(header.Get(EdgeSignatureHeader)))
+
+	if keyID == "" || timestamp == "" || nonce == "" || signature == "" {
+		return nil, &EdgeAuthError{Status: http.StatusUnauthorized, Reason: EdgeRejectMissingCredentials, Message: "Edge credentials required"}
+	}
+	if len(nonce) < 8 || len(nonce) > 64 {
+		return nil, &EdgeAuthError{Status: http.StatusUnauthorized, Reason: EdgeRejectMissingCredentials, Message: "Invalid nonce"}
+	}
+
+	entry, err := s.lookup(keyID)
+	if err != nil {
+		return nil, &EdgeAuthError{Status: http.StatusServiceUnavailable, Reason: EdgeRejectUnknownKey, Message: "Edge credentials unavailable"}
+	}
+	if entry == nil {
+		return nil, &EdgeAuthError{Status: http.StatusUnauthorized, Reason: EdgeRejectUnknownKey, Message: "Unknown edge key"}
+	}
+	credential := entry.credential
+	if !credential.IsValidAt(time.Now().UTC()) {
+		return &credential, &EdgeAuthError{Status: http.StatusUnauthorized, Reason: EdgeRejectKeyExpired, Message: "Edge key expired"}
+	}
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return &credential, &EdgeAuthError{Status: http.StatusUnauthorized, Reason: EdgeRejectTimestampSkew, Message: "Invalid timestamp"}
+	}
+	skew := time.Since(time.Unix(unix, 0))
+	if skew > edgeMaxClockSkew || skew < -edgeMaxClockSkew {
+		return &credential, &EdgeAuthError{Status: http.StatusUnauthorized, Reason: EdgeRejectTimestampSkew, Message: "Timestamp outside the allowed window"}
+	}
+
+	expected := SignEdgeRequest(entry.secret, method, path, timestamp, nonce, body)
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return &credential, &EdgeAuthError{Status: http.StatusUnauthorized, Reason: EdgeRejectInvalidSignature, Message: "Invalid edge signature"}
+	}
+
+	// Nonce last, so a bad signature can't burn a valid request's nonce.
+	// Redis errors fail open; the timestamp window still bounds replays.
+	first, err := cache.SetNX(context.Background(), edgeNonceKeyPrefix+keyID+":"+nonce, "1", edgeNonceTTL)
+	if err != nil {
+		atomic.AddInt64(&edgeAuthNonceSkipped, 1)
+	} else if !first {
+		return &credential, &EdgeAuthError{Status: http.StatusConflict, Reason: EdgeRejectNonceReplay, Message: "Nonce already used"}
+	}
+
+	atomic.AddInt64(&edgeAuthAccepted, 1)
+	s.touch(credential.ID)
+	return &credential, nil
+}
+
+// SignEdgeRequest computes the hex signature for an edge request
+func SignEdgeRequest(secret, method, path, timestamp, nonce string, body []byte) string {
+	bodyHash := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strings.ToUpper(method) + "\n" + path + "\n" + timestamp + "\n" + nonce + "\n" + hex.EncodeToString(bodyHash[:])))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// ============================================
+// QUOTAS
+// ============================================
+
+// ConsumeRequestQuota counts a request against the edge location's
+// per-minute request quota. Redis errors fail open.
+func (s *EdgeAuthService) ConsumeRequestQuota(credential *models.EdgeCredential) *EdgeAuthError {
+	limit := credential.RequestsPerMinute
+	if limit <= 0 {
+		limit = defaultEdgeRequestsPerMinute
+	}
+
+	count, err := cache.IncrWithExpire(context.Background(), edgeQuotaKey("requests", credential.EdgeLocation), 2*time.Minute)
+	if err != nil {
+		atomic.AddInt64(&edgeAuthQuotaSkipped, 1)
+		return nil
+	}
+	if count > int64(limit) {
+		return &EdgeAuthError{Status: http.StatusTooManyRequests, Reason: EdgeRejectQuotaExceeded, Message: "Edge location request quota exceeded"}
+	}
+	return nil
+}
+
+// CheckEventQuota rejects a batch when the edge location has already used
+// its per-minute event quota. Batch sizes are only known after decoding, so
+// events are counted after ingestion with RecordEvents.
+func (s *EdgeAuthService) CheckEventQuota(credential *models.EdgeCredential) *EdgeAuthError {
+	limit := credential.EventsPerMinute
+	if limit <= 0 {
+		limit = defaultEdgeEventsPerMinute
+	}
+
+	value, err := cache.Get(context.Background(), edgeQuotaKey("events", credential.EdgeLocation))
+	if err != nil {
+		if !cache.IsNil(err) {
+			atomic.AddInt64(&edgeAuthQuotaSkipped, 1)
+		}
+		return nil
+	}
+	if count, _ := strconv.ParseInt(value, 10, 64); count >= int64(limit) {
+		return &EdgeAuthError{Status: http.StatusTooManyRequests, Reason: EdgeRejectQuotaExceeded, Message: "Edge location event quota exceeded"}
+	}
+	return nil
+}
+
+// RecordEvents counts ingested events against the edge location's quota
+func (s *EdgeAuthService) RecordEvents(credential *models.EdgeCredential, count int) {
+	if count <= 0 {
+		return
+	}
+	addToCounterMap(&edgeEventsByLocation, credential.EdgeLocation, int64(count))
+
+	ctx := context.Background()
+	key := edgeQuotaKey("events", credential.EdgeLocation)
+	if _, err := cache.IncrBy(ctx, key, int64(count)); err == nil {
+		cache.Expire(ctx, key, 2*time.Minute)
+	}
+}
+
+// edgeQuotaKey returns the current minute's quota counter for a location
+func edgeQuotaKey(kind, location string) string {
+	return fmt.Sprintf("%s%s:%s:%d", edgeQuotaKeyPrefix, kind, location, time.Now().Unix()/60)
+}
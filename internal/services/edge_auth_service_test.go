@@ -0,0 +1,209 @@
+package services
+
+import (
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/google/uuid"
+)
+
+// The edge signing vector; the same values are documented in
+// edge/cloudflare-worker/src/edge-auth.ts
+const (
+	edgeVectorSecret    = "edge_test_secret_0001"
+	edgeVectorPath      = "/api/internal/edge-click"
+	edgeVectorTimestamp = "1767225600"
+	edgeVectorNonce     = "0f8e7d6c5b4a39281706f5e4d3c2b1a0"
+	edgeVectorBody      = `{"events":[{"tracking_code":"abc123"}]}`
+	edgeVectorSignature = "e3028dde24e0a31df133efdc503c32e05af7d571b9d1908536c8422595554413"
+)
+
+func TestSignEdgeRequestVector(t *testing.T) {
+	got := SignEdgeRequest(edgeVectorSecret, "POST", edgeVectorPath, edgeVectorTimestamp, edgeVectorNonce, []byte(edgeVectorBody))
+	if got != edgeVectorSignature {
+		t.Errorf("SignEdgeRequest = %s, want %s", got, edgeVectorSignature)
+	}
+	if lower := SignEdgeRequest(edgeVectorSecret, "post", edgeVectorPath, edgeVectorTimestamp, edgeVectorNonce, []byte(edgeVectorBody)); lower != got {
+		t.Error("method case changed the signature")
+	}
+	if other := SignEdgeRequest(edgeVectorSecret, "POST", edgeVectorPath, edgeVectorTimestamp, edgeVectorNonce, []byte(edgeVectorBody+" ")); other == got {
+		t.Error("changing the body did not change the signature")
+	}
+}
+
+// newTestEdgeAuthService returns a service whose credential set is already
+// loaded, so Verify never reaches the database
+func newTestEdgeAuthService(entries ...*edgeCredentialEntry) *EdgeAuthService {
+	s := NewEdgeAuthService(nil)
+	for _, entry := range entries {
+		s.credentials[entry.credential.KeyID] = entry
+		s.lastTouched[entry.credential.ID] = time.Now() // Skip the last_used_at write
+	}
+	s.loadedAt = time.Now().Add(time.Hour)
+	return s
+}
+
+func testEdgeCredential(keyID, secret string, validFrom time.Time, expiresAt *time.Time) *edgeCredentialEntry {
+	return &edgeCredentialEntry{
+		credential: models.EdgeCredential{
+			ID:           uuid.New(),
+			KeyID:        keyID,
+			EdgeLocation: "cloudflare",
+			Status:       models.EdgeCredentialStatusActive,
+			ValidFrom:    validFrom,
+			ExpiresAt:    expiresAt,
+		},
+		secret: secret,
+	}
+}
+
+// signedEdgeHeaders signs a request the way edge-auth.ts does
+func signedEdgeHeaders(keyID, secret string, ts time.Time, nonce, body string) http.Header {
+	timestamp := strconv.FormatInt(ts.Unix(), 10)
+	header := http.Header{}
+	header.Set(EdgeKeyIDHeader, keyID)
+	header.Set(EdgeTimestampHeader, timestamp)
+	header.Set(EdgeNonceHeader, nonce)
+	header.Set(EdgeSignatureHeader, SignEdgeRequest(secret, "POST", edgeVectorPath, timestamp, nonce, []byte(body)))
+	return header
+}
+
+func TestEdgeAuthVerify(t *testing.T) {
+	now := time.Now().UTC()
+	overlapEnds := now.Add(time.Hour)
+	expired := now.Add(-time.Minute)
+
+	// A rotated key: the old one is accepted until the overlap ends
+	oldKey := testEdgeCredential("edk_old", "old-secret", now.Add(-48*time.Hour), &overlapEnds)
+	newKey := testEdgeCredential("edk_new", "new-secret", now.Add(-time.Minute), nil)
+	retired := testEdgeCredential("edk_retired", "retired-secret", now.Add(-72*time.Hour), &expired)
+	future := testEdgeCredential("edk_future", "future-secret", now.Add(time.Hour), nil)
+	revoked := testEdgeCredential("edk_revoked", "revoked-secret", now.Add(-time.Hour), nil)
+	revoked.credential.Status = models.EdgeCredentialStatusRevoked
+
+	s := newTestEdgeAuthService(oldKey, newKey, retired, future, revoked)
+
+	nonce := 0
+	nextNonce := func() string {
+		nonce++
+		return "nonce-" + strconv.Itoa(nonce) + "-0123456789"
+	}
+	sign := func(keyID, secret string, ts time.Time) http.Header {
+		return signedEdgeHeaders(keyID, secret, ts, nextNonce(), edgeVectorBody)
+	}
+
+	tests := []struct {
+		name       string
+		header     http.Header
+		body       string
+		wantReason string
+		wantStatus int
+	}{
+		{"new key", sign("edk_new", "new-secret", now), edgeVectorBody, "", 0},
+		{"old key during the overlap", sign("edk_old", "old-secret", now), edgeVectorBody, "", 0},
+		{"old key after the overlap", sign("edk_retired", "retired-secret", now), edgeVectorBody, EdgeRejectKeyExpired, http.StatusUnauthorized},
+		{"key not yet valid", sign("edk_future", "future-secret", now), edgeVectorBody, EdgeRejectKeyExpired, http.StatusUnauthorized},
+		{"revoked key", sign("edk_revoked", "revoked-secret", now), edgeVectorBody, EdgeRejectKeyExpired, http.StatusUnauthorized},
+		{"new key ID with the old secret", sign("edk_new", "old-secret", now), edgeVectorBody, EdgeRejectInvalidSignature, http.StatusUnauthorized},
+		{"unknown key", sign("edk_nope", "new-secret", now), edgeVectorBody, EdgeRejectUnknownKey, http.StatusUnauthorized},
+
+		{"skew within the window", sign("edk_new", "new-secret", now.Add(-4*time.Minute)), edgeVectorBody, "", 0},
+		{"future skew within the window", sign("edk_new", "new-secret", now.Add(4*time.Minute)), edgeVectorBody, "", 0},
+		{"too old", sign("edk_new", "new-secret", now.Add(-6*time.Minute)), edgeVectorBody, EdgeRejectTimestampSkew, http.StatusUnauthorized},
+		{"too far ahead", sign("edk_new", "new-secret", now.Add(6*time.Minute)), edgeVectorBody, EdgeRejectTimestampSkew, http.StatusUnauthorized},
+		{"timestamp in milliseconds", func() http.Header {
+			h := sign("edk_new", "new-secret", now)
+			h.Set(EdgeTimestampHeader, strconv.FormatInt(now.UnixMilli(), 10))
+			return h
+		}(), edgeVectorBody, EdgeRejectTimestampSkew, http.StatusUnauthorized},
+
+		{"body changed", sign("edk_new", "new-secret", now), edgeVectorBody + " ", EdgeRejectInvalidSignature, http.StatusUnauthorized},
+		{"missing signature", func() http.Header {
+			h := sign("edk_new", "new-secret", now)
+			h.Del(EdgeSignatureHeader)
+			return h
+		}(), edgeVectorBody, EdgeRejectMissingCredentials, http.StatusUnauthorized},
+		{"short nonce", signedEdgeHeaders("edk_new", "new-secret", now, "abc", edgeVectorBody), edgeVectorBody, EdgeRejectMissingCredentials, http.StatusUnauthorized},
+		{"no headers", http.Header{}, edgeVectorBody, EdgeRejectMissingCredentials, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			credential, authErr := s.Verify("POST", edgeVectorPath, tt.header, []byte(tt.body))
+			if tt.wantReason == "" {
+				if authErr != nil {
+					t.Fatalf("Verify rejected: %s", authErr.Reason)
+				}
+				if credential == nil || credential.KeyID != tt.header.Get(EdgeKeyIDHeader) {
+					t.Errorf("credential = %v, want %s", credential, tt.header.Get(EdgeKeyIDHeader))
+				}
+				return
+			}
+			if authErr == nil {
+				t.Fatalf("Verify accepted, want %s", tt.wantReason)
+			}
+			if authErr.Reason != tt.wantReason || authErr.Status != tt.wantStatus {
+				t.Errorf("Verify = %s (%d), want %s (%d)", authErr.Reason, authErr.Status, tt.wantReason, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestEdgeAuthVerifyReplay(t *testing.T) {
+	startFakeRedis(t)
+	now := time.Now().UTC()
+	s := newTestEdgeAuthService(testEdgeCredential("edk_new", "new-secret", now.Add(-time.Minute), nil))
+	const nonce = "replay-nonce-0123456789"
+
+	// A forged request doesn't burn the nonce
+	forged := signedEdgeHeaders("edk_new", "wrong-secret", now, nonce, edgeVectorBody)
+	if _, authErr := s.Verify("POST", edgeVectorPath, forged, []byte(edgeVectorBody)); authErr == nil || authErr.Reason != EdgeRejectInvalidSignature {
+		t.Fatalf("forged request: %v", authErr)
+	}
+
+	header := signedEdgeHeaders("edk_new", "new-secret", now, nonce, edgeVectorBody)
+	if _, authErr := s.Verify("POST", edgeVectorPath, header, []byte(edgeVectorBody)); authErr != nil {
+		t.Fatalf("first request rejected: %s", authErr.Reason)
+	}
+	_, authErr := s.Verify("POST", edgeVectorPath, header, []byte(edgeVectorBody))
+	if authErr == nil || authErr.Reason != EdgeRejectNonceReplay || authErr.Status != http.StatusConflict {
+		t.Fatalf("replay = %v, want %s (409)", authErr, EdgeRejectNonceReplay)
+	}
+
+	// Nonces are per key
+	other := testEdgeCredential("edk_other", "other-secret", now.Add(-time.Minute), nil)
+	s.credentials[other.credential.KeyID] = other
+	s.lastTouched[other.credential.ID] = time.Now()
+	otherHeader := signedEdgeHeaders("edk_other", "other-secret", now, nonce, edgeVectorBody)
+	if _, authErr := s.Verify("POST", edgeVectorPath, otherHeader, []byte(edgeVectorBody)); authErr != nil {
+		t.Errorf("same nonce on another key rejected: %s", authErr.Reason)
+	}
+}
+
+func TestEdgeAuthVerifyWithoutRedis(t *testing.T) {
+	now := time.Now().UTC()
+	s := newTestEdgeAuthService(testEdgeCredential("edk_new", "new-secret", now.Add(-time.Minute), nil))
+	header := signedEdgeHeaders("edk_new", "new-secret", now, "no-redis-nonce-0123", edgeVectorBody)
+
+	before := atomic.LoadInt64(&edgeAuthNonceSkipped)
+	if _, authErr := s.Verify("POST", edgeVectorPath, header, []byte(edgeVectorBody)); authErr != nil {
+		t.Fatalf("Verify rejected: %s", authErr.Reason)
+	}
+	if atomic.LoadInt64(&edgeAuthNonceSkipped) != before+1 {
+		t.Error("skipped nonce check was not counted")
+	}
+}
+
+func TestEdgeAuthRecordRejection(t *testing.T) {
+	s := newTestEdgeAuthService()
+	before := GetEdgeAuthMetrics().RejectedByLocation["unknown"]
+
+	s.RecordRejection("", EdgeRejectUnknownKey)
+	if got := GetEdgeAuthMetrics().RejectedByLocation["unknown"]; got != before+1 {
+		t.Errorf("unknown location count = %d, want %d", got, before+1)
+	}
+}
@@ -41,6 +41,11 @@ type EdgeClickEvent struct {
 	LatencyMs        int                    `json:"latency_ms"`
 	RouterDecision   string                 `json:"router_decision"`
 	FinalDestination string                 `json:"final_destination"`
+	Sub1             string                 `json:"sub1,omitempty"`
+	Sub2             string                 `json:"sub2,omitempty"`
+	Sub3             string                 `json:"sub3,omitempty"`
+	Sub4             string                 `json:"sub4,omitempty"`
+	Sub5             string                 `json:"sub5,omitempty"`
 	Meta             map[string]interface{} `json:"meta"`
 }
 
@@ -59,6 +64,7 @@ type EdgeIngestService struct {
 	observability *ObservabilityService
 	linkService   *LinkService
 	clickService  *ClickService
+	geoIPService  *GeoIPService
 	
 	// Async processing
 	eventQueue    chan EdgeClickEvent
@@ -69,10 +75,12 @@ type EdgeIngestService struct {
 	totalReceived  int64
 	totalProcessed int64
 	totalFailed    int64
+	totalSkipped   int64 // Clicks on revoked or paused links
 	
 	// Worker control
 	workerWg      sync.WaitGroup
 	stopChan      chan struct{}
+	stopOnce      sync.Once
 }
 
 // NewEdgeIngestService creates a new edge ingest service
@@ -80,6 +88,7 @@ func NewEdgeIngestService(db *gorm.DB) *EdgeIngestService {
 	service := &EdgeIngestService{
 		db:            db,
 		observability: NewObservabilityService(),
+		geoIPService:  GetGeoIPService(),
 		eventQueue:    make(chan EdgeClickEvent, 10000),
 		batchSize:     100,
 		flushInterval: 5 * time.Second,
@@ -113,8 +122,49 @@ func (s *EdgeIngestService) Start(workerCount int) {
 
 // Stop stops the edge ingest workers
 func (s *EdgeIngestService) Stop() {
-	close(s.stopChan)
-	s.workerWg.Wait()
+	s.stopOnce.Do(func() {
+		close(s.stopChan)
+		s.workerWg.Wait()
+	})
+}
+
+// Drain lets the workers empty the event queue, then stops them. Events still
+// queued when ctx expires go to the WAL for replay on the next boot.
+func (s *EdgeIngestService) Drain(ctx context.Context) (int, error) {
+	waitUntil(ctx, func() bool { return len(s.eventQueue) == 0 })
+	s.Stop()
+
+	undrained, lost := 0, 0
+	for done := false; !done; {
+		select {
+		case event := <-s.eventQueue:
+			undrained++
+			data, err := toEventData(event)
+			if err == nil {
+				err = spillToWAL(WALEventEdgeEvent, "", data)
+			}
+			if err != nil {
+				lost++
+				fmt.Printf("[EdgeIngest] Undrained edge event lost: tracking_code=%s: %v\n", event.TrackingCode, err)
+			}
+		default:
+			done = true
+		}
+	}
+	if undrained > 0 {
+		fmt.Printf("[EdgeIngest] %d edge events spilled to the WAL for replay\n", undrained-lost)
+	}
+	return undrained, nil
+}
+
+// PersistEdgeEvent is the crash recovery processor for edge events spilled to
+// the WAL
+func (s *EdgeIngestService) PersistEdgeEvent(data map[string]interface{}) error {
+	var event EdgeClickEvent
+	if err := fromEventData(data, &event); err != nil {
+		return fmt.Errorf("invalid edge event: %w", err)
+	}
+	return s.processEvent(event)
 }
 
 // ============================================
@@ -286,6 +336,7 @@ func (s *EdgeIngestService) processEvent(event EdgeClickEvent) error {
 	// Resolve user offer ID from tracking code
 	var userOfferID uuid.UUID
 	var promoterID uuid.UUID
+	var offerID uuid.UUID
 	
 	if s.linkService != nil {
 		uoID, err := s.linkService.ResolveTrackingCode(event.TrackingCode)
@@ -293,11 +344,6 @@ func (s *EdgeIngestService) processEvent(event EdgeClickEvent) error {
 			return fmt.Errorf("failed to resolve tracking code: %w", err)
 		}
 		userOfferID = uoID
-		// Get promoter ID from user offer
-		var userOffer models.UserOffer
-		if err := s.db.First(&userOffer, "id = ?", userOfferID).Error; err == nil {
-			promoterID = userOffer.UserID
-		}
 	} else {
 		// Try to parse as UUID directly
 		if event.UserOfferID != "" {
@@ -309,6 +355,32 @@ func (s *EdgeIngestService) processEvent(event EdgeClickEvent) error {
 		return fmt.Errorf("could not resolve user offer ID")
 	}
 	
+	// Get promoter ID from user offer
+	var userOffer models.UserOffer
+	if err := s.db.First(&userOffer, "id = ?", userOfferID).Error; err == nil {
+		// Revoked or paused promoters: the edge already redirected, but
+		// nothing is attributed to them (same as /api/c/:id)
+		if !userOffer.IsActive() {
+			atomic.AddInt64(&s.totalSkipped, 1)
+			fmt.Printf("[EdgeIngest] User offer %s is %s, click not recorded\n", userOfferID.String(), userOffer.Status)
+			return nil
+		}
+		promoterID = userOffer.UserID
+		offerID = userOffer.OfferID
+	}
+	
+	// Fill in location from the IP when the edge didn't provide it
+	country := normalizeCountryCode(event.Country)
+	city := event.City
+	if country == "" {
+		if loc := s.geoIPService.Lookup(event.IP); loc != nil {
+			country = loc.CountryCode
+			if city == "" {
+				city = loc.City
+			}
+		}
+	}
+	
 	// Parse tenant ID
 	tenantID := models.DefaultTenantID
 	if event.TenantID != "" {
@@ -326,11 +398,14 @@ func (s *EdgeIngestService) processEvent(event EdgeClickEvent) error {
 		Device:      event.Device,
 		Browser:     event.Browser,
 		OS:          event.OS,
-		Country:     event.Country,
-		City:        event.City,
+		Country:     country,
+		City:        city,
 		ClickedAt:   clickedAt,
 		Fingerprint: s.generateFingerprint(event),
 	}
+	for n, subID := range []string{event.Sub1, event.Sub2, event.Sub3, event.Sub4, event.Sub5} {
+		click.SubIDs.Set(n+1, truncate(subID, MaxSubIDLength))
+	}
 	
 	// Process in transaction
 	err = s.db.Transaction(func(tx *gorm.DB) error {
@@ -374,12 +449,18 @@ func (s *EdgeIngestService) processEvent(event EdgeClickEvent) error {
 	if err != nil {
 		return fmt.Errorf("failed to process click: %w", err)
 	}
+	GetWebhookService(s.db).EmitClick(*click)
+	GetLeaderboardService(s.db).RecordClick(userOfferID, click.ClickedAt)
+	GetBadgeEngine(s.db).RecordClick(userOfferID)
 	
 	// Update Redis counters
 	ctx := context.Background()
 	cache.Increment(ctx, fmt.Sprintf("clicks:total:%s", userOfferID.String()))
-	cache.Increment(ctx, fmt.Sprintf("clicks:daily:%s:%s", userOfferID.String(), time.Now().Format("2006-01-02")))
+	cache.Increment(ctx, fmt.Sprintf("clicks:daily:%s:%s", userOfferID.String(), time.Now().UTC().Format("2006-01-02")))
 	cache.Increment(ctx, fmt.Sprintf("tenant:%s:clicks:total", tenantID.String()))
+	// The edge enforced its caps from the config snapshot; count the click
+	// against the same daily budget the origin reserves from
+	countDailyCapClick(ctx, offerID, userOfferID)
 	
 	// Log event
 	s.observability.Log(LogEvent{
@@ -391,7 +472,7 @@ func (s *EdgeIngestService) processEvent(event EdgeClickEvent) error {
 		UserAgent:   event.UserAgent,
 		Device:      event.Device,
 		Metadata: map[string]interface{}{
-			"country":          event.Country,
+			"country":          country,
 			"edge_location":    event.EdgeLocation,
 			"edge_latency_ms":  event.LatencyMs,
 			"router_decision":  event.RouterDecision,
@@ -419,6 +500,7 @@ func (s *EdgeIngestService) GetStats() map[string]interface{} {
 		"total_received":  atomic.LoadInt64(&s.totalReceived),
 		"total_processed": atomic.LoadInt64(&s.totalProcessed),
 		"total_failed":    atomic.LoadInt64(&s.totalFailed),
+		"total_skipped":   atomic.LoadInt64(&s.totalSkipped),
 		"queue_size":      len(s.eventQueue),
 		"queue_capacity":  cap(s.eventQueue),
 	}
@@ -438,6 +520,7 @@ type EdgeOfferConfig struct {
 	DailyCap      int                    `json:"daily_cap,omitempty"`
 	TotalCap      int                    `json:"total_cap,omitempty"`
 	CurrentClicks int                    `json:"current_clicks,omitempty"`
+	CurrentDailyClicks int               `json:"current_daily_clicks,omitempty"`
 	Status        string                 `json:"status"`
 	RoutingRules  []EdgeRoutingRule      `json:"routing_rules,omitempty"`
 	ABTest        *EdgeABTestConfig      `json:"ab_test,omitempty"`
@@ -498,15 +581,39 @@ func (s *EdgeIngestService) GetOfferConfigForEdge(trackingCode string) (*EdgeOff
 		return nil, err
 	}
 	
+	offer := userOffer.Offer
+	if offer == nil {
+		return nil, fmt.Errorf("offer not found for user offer %s", userOfferID)
+	}
+	
+	// Advertiser-owned offers are keyed by the advertiser, network offers by the network
+	advertiserID := ""
+	if offer.AdvertiserID != nil {
+		advertiserID = offer.AdvertiserID.String()
+	} else if offer.NetworkID != nil {
+		advertiserID = offer.NetworkID.String()
+	}
+	
+	status := "active"
+	if offer.Status != "active" || userOffer.Status != "active" {
+		status = "paused"
+	}
+	
 	// Build config
 	config := &EdgeOfferConfig{
-		ID:            userOffer.OfferID.String(),
-		TenantID:      models.DefaultTenantID.String(), // TODO: Get from user offer
-		AdvertiserID:  userOffer.Offer.NetworkID.String(),
-		LandingURL:    userOffer.Offer.DestinationURL,
-		FallbackURL:   userOffer.Offer.DestinationURL,
+		ID:            offer.ID.String(),
+		TenantID:      GetTenantService(s.db).OfferTenantID(offer).String(),
+		AdvertiserID:  advertiserID,
+		LandingURL:    offer.DestinationURL,
+		FallbackURL:   offer.DestinationURL,
 		CurrentClicks: userOffer.TotalClicks,
-		Status:        string(userOffer.Status),
+		Status:        status,
+	}
+	
+	// Caps, routing rules, A/B test and rotation
+	routingService := GetOfferRoutingService(s.db)
+	if routing := routingService.ResolveConfig(offer.ID, userOffer.ID); routing != nil {
+		routingService.ApplyToEdgeConfig(config, routing, offer, &userOffer)
 	}
 	
 	return config, nil
@@ -28,6 +28,7 @@ type FailoverQueueEvent struct {
 	LastAttempt *time.Time             `json:"last_attempt,omitempty"`
 	Error       string                 `json:"error,omitempty"`
 	Source      string                 `json:"source"` // edge, backend, api
+	WALID       string                 `json:"wal_id,omitempty"` // Set when the event is already in the WAL
 }
 
 // FailoverQueue provides local failover queuing with retry
@@ -113,10 +114,12 @@ func (q *FailoverQueue) Enqueue(event *FailoverQueueEvent) error {
 		event.Timestamp = time.Now().UTC()
 	}
 
-	// Write to WAL first for durability
-	if q.walService != nil {
+	// Write to WAL first for durability (unless the caller already did)
+	if q.walService != nil && event.WALID == "" {
 		walEventType := WALEventType(event.Type)
-		if _, err := q.walService.Append(walEventType, event.TenantID, event.Data); err != nil {
+		if entry, err := q.walService.Append(walEventType, event.TenantID, event.Data); err == nil {
+			event.WALID = entry.ID
+		} else {
 			q.observability.Log(LogEvent{
 				Category: LogCategoryErrorEvent,
 				Level:    LogLevelError,
@@ -247,8 +250,8 @@ func (q *FailoverQueue) processEvent(event *FailoverQueueEvent) error {
 	atomic.AddInt64(&q.totalSent, 1)
 	
 	// Mark as processed in WAL
-	if q.walService != nil {
-		q.walService.MarkProcessed(event.ID)
+	if q.walService != nil && event.WALID != "" {
+		q.walService.MarkProcessed(event.WALID)
 	}
 
 	return nil
@@ -0,0 +1,170 @@
+package services
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aljapah/afftok-backend-prod/internal/cache"
+	"github.com/redis/go-redis/v9"
+)
+
+// fakeRedis is a minimal in-memory RESP2 server for tests of Redis-backed
+// paths (nonces, counters, caches). It implements the string commands the
+// services use and ignores expiry.
+type fakeRedis struct {
+	mu   sync.Mutex
+	data map[string]string
+}
+
+// startFakeRedis points cache.RedisClient at a fresh fakeRedis for the rest
+// of the test
+func startFakeRedis(t *testing.T) *fakeRedis {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("can't listen for a fake Redis: %v", err)
+	}
+
+	r := &fakeRedis{data: make(map[string]string)}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go r.serve(conn)
+		}
+	}()
+
+	previous := cache.RedisClient
+	cache.RedisClient = redis.NewClient(&redis.Options{Addr: ln.Addr().String()})
+	t.Cleanup(func() {
+		cache.RedisClient.Close()
+		cache.RedisClient = previous
+		ln.Close()
+	})
+	return r
+}
+
+// Get returns a stored value
+func (r *fakeRedis) Get(key string) (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	value, ok := r.data[key]
+	return value, ok
+}
+
+func (r *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	for {
+		args, err := readRESPCommand(reader)
+		if err != nil {
+			return
+		}
+		if _, err := io.WriteString(conn, r.exec(args)); err != nil {
+			return
+		}
+	}
+}
+
+func readRESPCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if !strings.HasPrefix(line, "*") {
+		return nil, fmt.Errorf("unexpected %q", line)
+	}
+	n, err := strconv.Atoi(line[1:])
+	if err != nil || n < 1 {
+		return nil, fmt.Errorf("bad array header %q", line)
+	}
+
+	args := make([]string, n)
+	for i := range args {
+		header, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimRight(header, "\r\n")[1:])
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(reader, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+func (r *fakeRedis) exec(args []string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	command := strings.ToUpper(args[0])
+	switch command {
+	case "PING":
+		return "+PONG\r\n"
+	case "GET":
+		value, ok := r.data[args[1]]
+		if !ok {
+			return "$-1\r\n"
+		}
+		return fmt.Sprintf("$%d\r\n%s\r\n", len(value), value)
+	case "SET":
+		nx := false
+		for _, opt := range args[3:] {
+			nx = nx || strings.EqualFold(opt, "NX")
+		}
+		if _, exists := r.data[args[1]]; exists && nx {
+			return "$-1\r\n"
+		}
+		r.data[args[1]] = args[2]
+		return "+OK\r\n"
+	case "SETNX":
+		if _, exists := r.data[args[1]]; exists {
+			return ":0\r\n"
+		}
+		r.data[args[1]] = args[2]
+		return ":1\r\n"
+	case "DEL", "EXISTS":
+		count := 0
+		for _, key := range args[1:] {
+			if _, exists := r.data[key]; exists {
+				count++
+				if command == "DEL" {
+					delete(r.data, key)
+				}
+			}
+		}
+		return fmt.Sprintf(":%d\r\n", count)
+	case "INCR", "DECR", "INCRBY", "DECRBY":
+		delta := int64(1)
+		if len(args) > 2 {
+			delta, _ = strconv.ParseInt(args[2], 10, 64)
+		}
+		if strings.HasPrefix(command, "DECR") {
+			delta = -delta
+		}
+		value, _ := strconv.ParseInt(r.data[args[1]], 10, 64)
+		value += delta
+		r.data[args[1]] = strconv.FormatInt(value, 10)
+		return fmt.Sprintf(":%d\r\n", value)
+	case "EXPIRE", "PEXPIRE":
+		if _, exists := r.data[args[1]]; exists {
+			return ":1\r\n"
+		}
+		return ":0\r\n"
+	}
+	return fmt.Sprintf("-ERR unknown command '%s'\r\n", args[0])
+}
@@ -0,0 +1,600 @@
+package services
+
+import (
+	"container/list"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// ============================================
+// GEOIP SERVICE
+// ============================================
+
+// GeoLocation is the resolved location of an IP address
+type GeoLocation struct {
+	CountryCode string `json:"country_code"`
+	Region      string `json:"region,omitempty"`
+	City        string `json:"city,omitempty"`
+	Source      string `json:"source"` // Resolver name, "header", or "" if unresolved
+}
+
+// Location sources
+const (
+	GeoSourceHeader = "header"
+	GeoSourceMMDB   = "mmdb"
+)
+
+// GeoIPResolver resolves an IP address to a location. Implementations must be
+// safe for concurrent use; a nil location with a nil error means "not found".
+type GeoIPResolver interface {
+	Name() string
+	Lookup(ip net.IP) (*GeoLocation, error)
+}
+
+// geoIPReloader is implemented by resolvers backed by a file that can be
+// replaced on disk while the server is running
+type geoIPReloader interface {
+	ReloadIfChanged() (bool, error)
+}
+
+// Country headers set by CDNs and proxies, checked in order when the resolver
+// has no answer
+var geoCountryHeaders = []string{"CF-IPCountry", "X-Country", "X-Geo-Country"}
+
+// City headers, used to fill in the city when the resolver doesn't provide one
+var geoCityHeaders = []string{"CF-IPCity", "X-City", "X-Geo-City"}
+
+// ============================================
+// GEOIP METRICS
+// ============================================
+
+// GeoIPMetrics tracks GeoIP resolution
+type GeoIPMetrics struct {
+	Lookups         int64
+	CacheHits       int64
+	ResolverHits    int64
+	HeaderFallbacks int64
+	Unresolved      int64
+	ResolverErrors  int64
+	Reloads         int64
+	ReloadErrors    int64
+}
+
+var geoIPMetrics = &GeoIPMetrics{}
+
+// GetGeoIPMetrics returns GeoIP metrics
+func GetGeoIPMetrics() *GeoIPMetrics {
+	return &GeoIPMetrics{
+		Lookups:         atomic.LoadInt64(&geoIPMetrics.Lookups),
+		CacheHits:       atomic.LoadInt64(&geoIPMetrics.CacheHits),
+		ResolverHits:    atomic.LoadInt64(&geoIPMetrics.ResolverHits),
+		HeaderFallbacks: atomic.LoadInt64(&geoIPMetrics.HeaderFallbacks),
+		Unresolved:      atomic.LoadInt64(&geoIPMetrics.Unresolved),
+		ResolverErrors:  atomic.LoadInt64(&geoIPMetrics.ResolverErrors),
+		Reloads:         atomic.LoadInt64(&geoIPMetrics.Reloads),
+		ReloadErrors:    atomic.LoadInt64(&geoIPMetrics.ReloadErrors),
+	}
+}
+
+// GeoIPService resolves client locations for geo rules and click records.
+// Resolution order: configured resolver (cached) -> CDN/proxy headers.
+type GeoIPService struct {
+	resolver   GeoIPResolver
+	resolverMu sync.RWMutex
+	cache      *geoLRU
+
+	dbPath         string // GEOIP_DB_PATH, loaded by the watcher if it was missing at startup
+	reloadInterval time.Duration
+
+	// Control
+	mu        sync.Mutex
+	isRunning bool
+	stopChan  chan struct{}
+	wg        sync.WaitGroup
+}
+
+var (
+	geoIPServiceInstance *GeoIPService
+	geoIPServiceOnce     sync.Once
+)
+
+// NewGeoIPService creates a GeoIP service. GEOIP_DB_PATH points at a MaxMind
+// format (.mmdb) database; without it only the header fallback is used.
+func NewGeoIPService() *GeoIPService {
+	cacheSize := 10000
+	if v, err := strconv.Atoi(os.Getenv("GEOIP_CACHE_SIZE")); err == nil && v > 0 {
+		cacheSize = v
+	}
+	reloadInterval := time.Minute
+	if v, err := strconv.Atoi(os.Getenv("GEOIP_RELOAD_SECONDS")); err == nil && v > 0 {
+		reloadInterval = time.Duration(v) * time.Second
+	}
+
+	s := &GeoIPService{
+		cache:          newGeoLRU(cacheSize),
+		dbPath:         os.Getenv("GEOIP_DB_PATH"),
+		reloadInterval: reloadInterval,
+	}
+
+	if path := s.dbPath; path != "" {
+		resolver, err := NewMMDBResolver(path)
+		if err != nil {
+			fmt.Printf("[GeoIP] Failed to load %s, using headers only until it appears: %v\n", path, err)
+		} else {
+			s.resolver = resolver
+			fmt.Printf("[GeoIP] Loaded %s\n", path)
+		}
+	}
+
+	return s
+}
+
+// GetGeoIPService returns the singleton GeoIP service
+func GetGeoIPService() *GeoIPService {
+	geoIPServiceOnce.Do(func() {
+		geoIPServiceInstance = NewGeoIPService()
+	})
+	return geoIPServiceInstance
+}
+
+// SetResolver replaces the resolver (nil disables IP lookups) and clears the cache
+func (s *GeoIPService) SetResolver(resolver GeoIPResolver) {
+	s.resolverMu.Lock()
+	s.resolver = resolver
+	s.resolverMu.Unlock()
+	s.cache.Purge()
+}
+
+// HasResolver reports whether an IP resolver is configured
+func (s *GeoIPService) HasResolver() bool {
+	s.resolverMu.RLock()
+	defer s.resolverMu.RUnlock()
+	return s.resolver != nil
+}
+
+// ============================================
+// RESOLUTION
+// ============================================
+
+// Lookup resolves an IP address with the configured resolver. Returns nil for
+// private, invalid or unknown addresses.
+func (s *GeoIPService) Lookup(ipAddress string) *GeoLocation {
+	atomic.AddInt64(&geoIPMetrics.Lookups, 1)
+
+	ip := net.ParseIP(strings.TrimSpace(ipAddress))
+	if ip == nil || ip.IsPrivate() || ip.IsLoopback() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() {
+		return nil
+	}
+
+	s.resolverMu.RLock()
+	resolver := s.resolver
+	s.resolverMu.RUnlock()
+	if resolver == nil {
+		return nil
+	}
+
+	key := ip.String()
+	if loc, ok := s.cache.Get(key); ok {
+		atomic.AddInt64(&geoIPMetrics.CacheHits, 1)
+		return loc
+	}
+
+	loc, err := resolver.Lookup(ip)
+	if err != nil {
+		atomic.AddInt64(&geoIPMetrics.ResolverErrors, 1)
+		return nil
+	}
+	if loc != nil && loc.CountryCode == "" {
+		loc = nil
+	}
+
+	// Misses are cached too so unknown ranges don't hit the database every time
+	s.cache.Add(key, loc)
+	if loc != nil {
+		atomic.AddInt64(&geoIPMetrics.ResolverHits, 1)
+	}
+	return loc
+}
+
+// ResolveRequest resolves the client location of a request, falling back to
+// CDN/proxy headers when the resolver has no answer. Never returns nil.
+func (s *GeoIPService) ResolveRequest(c *gin.Context) *GeoLocation {
+	result := &GeoLocation{}
+	if loc := s.Lookup(c.ClientIP()); loc != nil {
+		*result = *loc
+	}
+
+	if result.CountryCode == "" {
+		for _, header := range geoCountryHeaders {
+			if country := normalizeCountryCode(c.GetHeader(header)); country != "" {
+				result.CountryCode = country
+				result.Source = GeoSourceHeader
+				break
+			}
+		}
+	}
+
+	if result.City == "" && result.CountryCode != "" {
+		for _, header := range geoCityHeaders {
+			if city := strings.TrimSpace(c.GetHeader(header)); city != "" {
+				result.City = city
+				break
+			}
+		}
+	}
+
+	if result.CountryCode == "" {
+		atomic.AddInt64(&geoIPMetrics.Unresolved, 1)
+	} else if result.Source == GeoSourceHeader {
+		atomic.AddInt64(&geoIPMetrics.HeaderFallbacks, 1)
+	}
+	return result
+}
+
+// normalizeCountryCode upper-cases a two-letter code and drops Cloudflare's
+// "XX" (unknown) and "T1" (Tor) markers
+func normalizeCountryCode(code string) string {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if len(code) != 2 || code == "XX" || code == "T1" {
+		return ""
+	}
+	return code
+}
+
+// ============================================
+// HOT RELOAD
+// ============================================
+
+// Start watches the database file, loading it once it appears and reloading
+// it when it changes on disk
+func (s *GeoIPService) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.isRunning {
+		return
+	}
+	s.isRunning = true
+	s.stopChan = make(chan struct{})
+
+	s.wg.Add(1)
+	go s.watch()
+}
+
+// Stop stops the reload watcher
+func (s *GeoIPService) Stop() {
+	s.mu.Lock()
+	if !s.isRunning {
+		s.mu.Unlock()
+		return
+	}
+	s.isRunning = false
+	close(s.stopChan)
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+func (s *GeoIPService) watch() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.reloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopChan:
+			return
+		case <-ticker.C:
+			s.Reload()
+		}
+	}
+}
+
+// Reload reloads the resolver's database if the file changed, or loads it if
+// it wasn't readable at startup. Lookups keep using the old database until
+// the new one has loaded successfully.
+func (s *GeoIPService) Reload() {
+	s.resolverMu.RLock()
+	resolver := s.resolver
+	s.resolverMu.RUnlock()
+
+	if resolver == nil {
+		s.loadMissingDatabase()
+		return
+	}
+
+	reloader, ok := resolver.(geoIPReloader)
+	if !ok {
+		return
+	}
+
+	changed, err := reloader.ReloadIfChanged()
+	if err != nil {
+		atomic.AddInt64(&geoIPMetrics.ReloadErrors, 1)
+		fmt.Printf("[GeoIP] Reload failed, keeping previous database: %v\n", err)
+		return
+	}
+	if changed {
+		atomic.AddInt64(&geoIPMetrics.Reloads, 1)
+		s.cache.Purge()
+		fmt.Printf("[GeoIP] Database reloaded\n")
+	}
+}
+
+// loadMissingDatabase loads GEOIP_DB_PATH once the file is readable. Failures
+// are expected until the file is deployed, so they aren't logged.
+func (s *GeoIPService) loadMissingDatabase() {
+	if s.dbPath == "" {
+		return
+	}
+	if _, err := os.Stat(s.dbPath); err != nil {
+		return
+	}
+
+	resolver, err := NewMMDBResolver(s.dbPath)
+	if err != nil {
+		atomic.AddInt64(&geoIPMetrics.ReloadErrors, 1)
+		return
+	}
+
+	s.resolverMu.Lock()
+	if s.resolver != nil {
+		// Set meanwhile, e.g. through SetResolver
+		s.resolverMu.Unlock()
+		resolver.Close()
+		return
+	}
+	s.resolver = resolver
+	s.resolverMu.Unlock()
+	s.cache.Purge()
+
+	atomic.AddInt64(&geoIPMetrics.Reloads, 1)
+	fmt.Printf("[GeoIP] Loaded %s\n", s.dbPath)
+}
+
+// GetStatus returns the resolver state and metrics
+func (s *GeoIPService) GetStatus() map[string]interface{} {
+	s.resolverMu.RLock()
+	resolver := s.resolver
+	s.resolverMu.RUnlock()
+
+	status := map[string]interface{}{
+		"resolver":   "",
+		"cache_size": s.cache.Len(),
+		"metrics":    GetGeoIPMetrics(),
+	}
+	if resolver != nil {
+		status["resolver"] = resolver.Name()
+	}
+	if mmdb, ok := resolver.(*MMDBResolver); ok {
+		status["database"] = mmdb.Info()
+	}
+	return status
+}
+
+// ============================================
+// MMDB RESOLVER
+// ============================================
+
+// MMDBResolver resolves IPs from a local MaxMind-format database
+// (GeoLite2/GeoIP2 Country or City, or a compatible file)
+type MMDBResolver struct {
+	path    string
+	mu      sync.RWMutex
+	reader  *maxminddb.Reader
+	modTime time.Time
+	size    int64
+}
+
+// mmdbRecord is the subset of the GeoIP2 schema we read
+type mmdbRecord struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	RegisteredCountry struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"registered_country"`
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+	Subdivisions []struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"subdivisions"`
+}
+
+// NewMMDBResolver opens a MaxMind-format database file
+func NewMMDBResolver(path string) (*MMDBResolver, error) {
+	r := &MMDBResolver{path: path}
+	if _, err := r.ReloadIfChanged(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Name returns the resolver name
+func (r *MMDBResolver) Name() string {
+	return GeoSourceMMDB
+}
+
+// Lookup resolves an IP address
+func (r *MMDBResolver) Lookup(ip net.IP) (*GeoLocation, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.reader == nil {
+		return nil, fmt.Errorf("geoip database not loaded")
+	}
+
+	var record mmdbRecord
+	if err := r.reader.Lookup(ip, &record); err != nil {
+		return nil, err
+	}
+
+	country := record.Country.ISOCode
+	if country == "" {
+		country = record.RegisteredCountry.ISOCode
+	}
+	if country == "" {
+		return nil, nil
+	}
+
+	loc := &GeoLocation{
+		CountryCode: strings.ToUpper(country),
+		City:        record.City.Names["en"],
+		Source:      GeoSourceMMDB,
+	}
+	if len(record.Subdivisions) > 0 {
+		loc.Region = record.Subdivisions[0].ISOCode
+	}
+	return loc, nil
+}
+
+// ReloadIfChanged reopens the database when the file's size or modification
+// time differs from the loaded copy
+func (r *MMDBResolver) ReloadIfChanged() (bool, error) {
+	info, err := os.Stat(r.path)
+	if err != nil {
+		return false, err
+	}
+
+	r.mu.RLock()
+	unchanged := r.reader != nil && info.ModTime().Equal(r.modTime) && info.Size() == r.size
+	r.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	reader, err := maxminddb.Open(r.path)
+	if err != nil {
+		return false, err
+	}
+
+	r.mu.Lock()
+	old := r.reader
+	r.reader = reader
+	r.modTime = info.ModTime()
+	r.size = info.Size()
+	r.mu.Unlock()
+
+	// Safe to close: lookups hold the read lock while using the reader
+	if old != nil {
+		old.Close()
+	}
+	return true, nil
+}
+
+// Close closes the database
+func (r *MMDBResolver) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.reader != nil {
+		r.reader.Close()
+		r.reader = nil
+	}
+}
+
+// Info describes the loaded database
+func (r *MMDBResolver) Info() map[string]interface{} {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	info := map[string]interface{}{
+		"path":     r.path,
+		"modified": r.modTime,
+	}
+	if r.reader != nil {
+		info["type"] = r.reader.Metadata.DatabaseType
+		info["build_epoch"] = time.Unix(int64(r.reader.Metadata.BuildEpoch), 0).UTC()
+	}
+	return info
+}
+
+// ============================================
+// LRU CACHE
+// ============================================
+
+// geoLRU is a fixed-size LRU of IP -> location (nil for misses)
+type geoLRU struct {
+	mu       sync.Mutex
+	capacity int
+	items    map[string]*list.Element
+	order    *list.List
+}
+
+type geoLRUEntry struct {
+	key string
+	loc *GeoLocation
+}
+
+func newGeoLRU(capacity int) *geoLRU {
+	return &geoLRU{
+		capacity: capacity,
+		items:    make(map[string]*list.Element, capacity),
+		order:    list.New(),
+	}
+}
+
+// Get returns a copy of the cached location so callers can't mutate the cache
+func (l *geoLRU) Get(key string) (*GeoLocation, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	elem, ok := l.items[key]
+	if !ok {
+		return nil, false
+	}
+	l.order.MoveToFront(elem)
+
+	entry := elem.Value.(*geoLRUEntry)
+	if entry.loc == nil {
+		return nil, true
+	}
+	loc := *entry.loc
+	return &loc, true
+}
+
+func (l *geoLRU) Add(key string, loc *GeoLocation) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if loc != nil {
+		copied := *loc
+		loc = &copied
+	}
+
+	if elem, ok := l.items[key]; ok {
+		elem.Value.(*geoLRUEntry).loc = loc
+		l.order.MoveToFront(elem)
+		return
+	}
+
+	l.items[key] = l.order.PushFront(&geoLRUEntry{key: key, loc: loc})
+	if l.order.Len() > l.capacity {
+		oldest := l.order.Back()
+		l.order.Remove(oldest)
+		delete(l.items, oldest.Value.(*geoLRUEntry).key)
+	}
+}
+
+func (l *geoLRU) Purge() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.items = make(map[string]*list.Element, l.capacity)
+	l.order.Init()
+}
+
+func (l *geoLRU) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.order.Len()
+}
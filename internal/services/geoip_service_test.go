@@ -0,0 +1,244 @@
+package services
+
+import (
+	"net"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// fakeGeoResolver answers from a fixed table and counts lookups
+type fakeGeoResolver struct {
+	locations map[string]*GeoLocation
+	lookups   int64
+}
+
+func (r *fakeGeoResolver) Name() string { return "fake" }
+
+func (r *fakeGeoResolver) Lookup(ip net.IP) (*GeoLocation, error) {
+	atomic.AddInt64(&r.lookups, 1)
+	return r.locations[ip.String()], nil
+}
+
+func newTestGeoIPService(resolver GeoIPResolver) *GeoIPService {
+	s := &GeoIPService{cache: newGeoLRU(16)}
+	if resolver != nil {
+		s.SetResolver(resolver)
+	}
+	return s
+}
+
+func TestNormalizeCountryCode(t *testing.T) {
+	tests := map[string]string{
+		"sa":    "SA",
+		" KW ":  "KW",
+		"XX":    "",
+		"t1":    "",
+		"SAU":   "",
+		"":      "",
+		"k":     "",
+		"ae\n":  "AE",
+		"us-ca": "",
+	}
+	for in, want := range tests {
+		if got := normalizeCountryCode(in); got != want {
+			t.Errorf("normalizeCountryCode(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestGeoIPLookup(t *testing.T) {
+	resolver := &fakeGeoResolver{locations: map[string]*GeoLocation{
+		"8.8.8.8":              {CountryCode: "US", Source: "fake"},
+		"2001:4860:4860::8888": {CountryCode: "US", Source: "fake"},
+		"1.2.3.4":              {Source: "fake"}, // No country
+	}}
+	s := newTestGeoIPService(resolver)
+
+	tests := []struct {
+		name        string
+		ip          string
+		wantCountry string
+		wantLookup  bool
+	}{
+		{"public IPv4", "8.8.8.8", "US", true},
+		{"public IPv6", "2001:4860:4860::8888", "US", true},
+		{"padded", " 8.8.8.8 ", "US", false}, // Cached by the first case
+		{"unknown", "9.9.9.9", "", true},
+		{"no country", "1.2.3.4", "", true},
+		{"private", "10.0.0.1", "", false},
+		{"loopback", "127.0.0.1", "", false},
+		{"link local", "169.254.1.1", "", false},
+		{"unspecified", "::", "", false},
+		{"invalid", "not-an-ip", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := atomic.LoadInt64(&resolver.lookups)
+			loc := s.Lookup(tt.ip)
+
+			got := ""
+			if loc != nil {
+				got = loc.CountryCode
+			}
+			if got != tt.wantCountry {
+				t.Errorf("country = %q, want %q", got, tt.wantCountry)
+			}
+			if looked := atomic.LoadInt64(&resolver.lookups) > before; looked != tt.wantLookup {
+				t.Errorf("resolver called = %v, want %v", looked, tt.wantLookup)
+			}
+		})
+	}
+
+	// Misses are cached as well
+	before := atomic.LoadInt64(&resolver.lookups)
+	if s.Lookup("9.9.9.9") != nil || atomic.LoadInt64(&resolver.lookups) != before {
+		t.Error("cached miss went to the resolver")
+	}
+
+	// Replacing the resolver purges the cache
+	s.SetResolver(&fakeGeoResolver{locations: map[string]*GeoLocation{"9.9.9.9": {CountryCode: "CH"}}})
+	if loc := s.Lookup("9.9.9.9"); loc == nil || loc.CountryCode != "CH" {
+		t.Errorf("lookup after SetResolver = %v, want CH", loc)
+	}
+}
+
+func TestGeoIPResolveRequest(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	resolver := &fakeGeoResolver{locations: map[string]*GeoLocation{
+		"8.8.8.8": {CountryCode: "US", City: "Mountain View", Source: GeoSourceMMDB},
+		"8.8.4.4": {CountryCode: "US", Source: GeoSourceMMDB},
+	}}
+
+	tests := []struct {
+		name       string
+		resolver   GeoIPResolver
+		remoteIP   string
+		headers    map[string]string
+		wantCode   string
+		wantCity   string
+		wantSource string
+	}{
+		{
+			name:       "resolver wins over headers",
+			resolver:   resolver,
+			remoteIP:   "8.8.8.8",
+			headers:    map[string]string{"CF-IPCountry": "SA", "CF-IPCity": "Riyadh"},
+			wantCode:   "US",
+			wantCity:   "Mountain View",
+			wantSource: GeoSourceMMDB,
+		},
+		{
+			name:       "header city fills in",
+			resolver:   resolver,
+			remoteIP:   "8.8.4.4",
+			headers:    map[string]string{"X-City": "Reston"},
+			wantCode:   "US",
+			wantCity:   "Reston",
+			wantSource: GeoSourceMMDB,
+		},
+		{
+			name:       "header fallback",
+			remoteIP:   "8.8.8.8",
+			headers:    map[string]string{"CF-IPCountry": "XX", "X-Country": "kw", "X-Geo-City": "Kuwait City"},
+			wantCode:   "KW",
+			wantCity:   "Kuwait City",
+			wantSource: GeoSourceHeader,
+		},
+		{
+			name:     "city header ignored without a country",
+			remoteIP: "10.0.0.1",
+			headers:  map[string]string{"CF-IPCity": "Nowhere"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestGeoIPService(tt.resolver)
+
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest("GET", "/", nil)
+			c.Request.RemoteAddr = tt.remoteIP + ":1234"
+			for k, v := range tt.headers {
+				c.Request.Header.Set(k, v)
+			}
+
+			loc := s.ResolveRequest(c)
+			if loc == nil {
+				t.Fatal("ResolveRequest returned nil")
+			}
+			if loc.CountryCode != tt.wantCode || loc.City != tt.wantCity || loc.Source != tt.wantSource {
+				t.Errorf("got %+v, want %s/%s/%s", *loc, tt.wantCode, tt.wantCity, tt.wantSource)
+			}
+		})
+	}
+}
+
+func TestGeoIPLoadMissingDatabase(t *testing.T) {
+	s := newTestGeoIPService(nil)
+	s.dbPath = filepath.Join(t.TempDir(), "missing.mmdb")
+
+	s.Reload()
+	if s.HasResolver() {
+		t.Fatal("resolver set for a missing file")
+	}
+
+	// A file that isn't a valid database is retried, not loaded
+	if err := os.WriteFile(s.dbPath, []byte("not a database"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	s.Reload()
+	if s.HasResolver() {
+		t.Fatal("resolver set for an unreadable file")
+	}
+}
+
+func TestGeoLRU(t *testing.T) {
+	l := newGeoLRU(2)
+
+	if _, ok := l.Get("8.8.8.8"); ok {
+		t.Fatal("hit on an empty cache")
+	}
+
+	l.Add("8.8.8.8", &GeoLocation{CountryCode: "US"})
+	l.Add("9.9.9.9", nil)
+
+	if loc, ok := l.Get("9.9.9.9"); !ok || loc != nil {
+		t.Errorf("cached miss = %v, %v; want nil, true", loc, ok)
+	}
+
+	// Returned values are copies
+	loc, _ := l.Get("8.8.8.8")
+	loc.CountryCode = "XX"
+	if again, _ := l.Get("8.8.8.8"); again.CountryCode != "US" {
+		t.Errorf("cache entry mutated through Get: %q", again.CountryCode)
+	}
+
+	// 8.8.8.8 was used last, so 9.9.9.9 is evicted
+	l.Add("1.1.1.1", &GeoLocation{CountryCode: "AU"})
+	if l.Len() != 2 {
+		t.Errorf("Len = %d, want 2", l.Len())
+	}
+	if _, ok := l.Get("9.9.9.9"); ok {
+		t.Error("least recently used entry was not evicted")
+	}
+	if _, ok := l.Get("8.8.8.8"); !ok {
+		t.Error("recently used entry was evicted")
+	}
+
+	// Re-adding updates in place
+	l.Add("1.1.1.1", &GeoLocation{CountryCode: "NZ"})
+	if loc, _ := l.Get("1.1.1.1"); loc == nil || loc.CountryCode != "NZ" || l.Len() != 2 {
+		t.Errorf("update = %v (len %d), want NZ (len 2)", loc, l.Len())
+	}
+
+	l.Purge()
+	if _, ok := l.Get("8.8.8.8"); ok || l.Len() != 0 {
+		t.Error("Purge left entries behind")
+	}
+}
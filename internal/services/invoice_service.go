@@ -0,0 +1,499 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ============================================
+// INVOICE SERVICE
+// ============================================
+
+const (
+	defaultInvoicePlatformRate = 0.10 // 10% of promoter payouts
+	defaultInvoiceDueDays      = 7    // Due 7 days after period end
+)
+
+// billableConversionStatuses are the conversion statuses an advertiser is invoiced for
+var billableConversionStatuses = []string{
+	models.ConversionStatusApproved,
+	models.ConversionStatusPaid,
+}
+
+// InvoiceMetrics tracks invoice generation
+type InvoiceMetrics struct {
+	Runs            int64
+	InvoicesCreated int64
+	InvoicesUpdated int64
+	MarkedOverdue   int64
+	Errors          int64
+}
+
+var invoiceMetrics = &InvoiceMetrics{}
+
+// GetInvoiceMetrics returns invoice metrics
+func GetInvoiceMetrics() *InvoiceMetrics {
+	return &InvoiceMetrics{
+		Runs:            atomic.LoadInt64(&invoiceMetrics.Runs),
+		InvoicesCreated: atomic.LoadInt64(&invoiceMetrics.InvoicesCreated),
+		InvoicesUpdated: atomic.LoadInt64(&invoiceMetrics.InvoicesUpdated),
+		MarkedOverdue:   atomic.LoadInt64(&invoiceMetrics.MarkedOverdue),
+		Errors:          atomic.LoadInt64(&invoiceMetrics.Errors),
+	}
+}
+
+// InvoiceService generates monthly advertiser invoices from approved conversions
+type InvoiceService struct {
+	db                  *gorm.DB
+	defaultPlatformRate float64
+	dueDays             int
+
+	// Scheduler
+	checkInterval time.Duration
+	isRunning     bool
+	stopChan      chan struct{}
+	wg            sync.WaitGroup
+}
+
+var (
+	invoiceServiceInstance *InvoiceService
+	invoiceServiceOnce     sync.Once
+)
+
+// NewInvoiceService creates a new invoice service
+func NewInvoiceService(db *gorm.DB) *InvoiceService {
+	service := &InvoiceService{
+		db:                  db,
+		defaultPlatformRate: defaultInvoicePlatformRate,
+		dueDays:             defaultInvoiceDueDays,
+		checkInterval:       time.Hour,
+		stopChan:            make(chan struct{}),
+	}
+
+	// Load from environment
+	if rate := os.Getenv("INVOICE_PLATFORM_RATE"); rate != "" {
+		if r, err := strconv.ParseFloat(rate, 64); err == nil && models.IsValidPlatformRate(r) {
+			service.defaultPlatformRate = r
+		}
+	}
+	if days := os.Getenv("INVOICE_DUE_DAYS"); days != "" {
+		if d, err := strconv.Atoi(days); err == nil && d >= 0 {
+			service.dueDays = d
+		}
+	}
+
+	return service
+}
+
+// GetInvoiceService returns the singleton invoice service
+func GetInvoiceService(db *gorm.DB) *InvoiceService {
+	invoiceServiceOnce.Do(func() {
+		invoiceServiceInstance = NewInvoiceService(db)
+	})
+	return invoiceServiceInstance
+}
+
+// ============================================
+// GENERATION
+// ============================================
+
+// InvoiceGenerationResult summarizes a generation run
+type InvoiceGenerationResult struct {
+	Month   int      `json:"month"`
+	Year    int      `json:"year"`
+	Created int      `json:"created_count"`
+	Updated int      `json:"updated_count"`
+	Skipped int      `json:"skipped_count"`
+	Errors  []string `json:"errors,omitempty"`
+}
+
+// invoiceLine is one offer/currency aggregate for an advertiser
+type invoiceLine struct {
+	OfferID        uuid.UUID
+	OfferTitle     string
+	Currency       string
+	Conversions    int
+	PromoterPayout float64
+}
+
+// PeriodBounds returns the [start, end) range of a billing month in UTC
+func PeriodBounds(year, month int) (time.Time, time.Time) {
+	start := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
+	return start, start.AddDate(0, 1, 0)
+}
+
+// GenerateMonthlyInvoices builds one invoice per active advertiser and
+// currency for the given month. Running it again for the same period is safe:
+// invoices that are still pending are refreshed in place (down to zero if
+// every conversion was reversed), anything further along is left alone.
+// Advertisers who are no longer active only get their pending invoices
+// refreshed.
+func (s *InvoiceService) GenerateMonthlyInvoices(year, month int) (*InvoiceGenerationResult, error) {
+	atomic.AddInt64(&invoiceMetrics.Runs, 1)
+
+	result := &InvoiceGenerationResult{Month: month, Year: year}
+	periodStart, periodEnd := PeriodBounds(year, month)
+
+	// Pending invoices are refreshed even when nothing is billable any more
+	var pending []models.Invoice
+	if err := s.db.Select("advertiser_id", "currency").
+		Where("year = ? AND month = ? AND status = ?", year, month, models.InvoiceStatusPending).
+		Find(&pending).Error; err != nil {
+		atomic.AddInt64(&invoiceMetrics.Errors, 1)
+		return nil, fmt.Errorf("failed to fetch pending invoices: %w", err)
+	}
+	pendingCurrencies := make(map[uuid.UUID][]string)
+	for _, invoice := range pending {
+		pendingCurrencies[invoice.AdvertiserID] = append(pendingCurrencies[invoice.AdvertiserID], invoice.Currency)
+	}
+
+	query := s.db.Where("role = ?", "advertiser")
+	if len(pendingCurrencies) > 0 {
+		ids := make([]uuid.UUID, 0, len(pendingCurrencies))
+		for id := range pendingCurrencies {
+			ids = append(ids, id)
+		}
+		query = query.Where("status = ? OR id IN ?", "active", ids)
+	} else {
+		query = query.Where("status = ?", "active")
+	}
+
+	var advertisers []models.AfftokUser
+	if err := query.Find(&advertisers).Error; err != nil {
+		atomic.AddInt64(&invoiceMetrics.Errors, 1)
+		return nil, fmt.Errorf("failed to fetch advertisers: %w", err)
+	}
+
+	for i := range advertisers {
+		advertiser := &advertisers[i]
+
+		lines, err := s.collectLines(advertiser.ID, periodStart, periodEnd)
+		if err != nil {
+			atomic.AddInt64(&invoiceMetrics.Errors, 1)
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", advertiser.ID, err))
+			continue
+		}
+		if advertiser.Status != "active" {
+			lines = filterLinesByCurrency(lines, pendingCurrencies[advertiser.ID])
+		}
+
+		currencies, byCurrency := groupLinesByCurrency(lines, pendingCurrencies[advertiser.ID])
+		if len(currencies) == 0 {
+			result.Skipped++
+			continue
+		}
+
+		rate := s.platformRateFor(advertiser)
+		for _, currency := range currencies {
+			outcome, err := s.upsertInvoice(advertiser.ID, year, month, periodStart, periodEnd, currency, rate, byCurrency[currency])
+			if err != nil {
+				atomic.AddInt64(&invoiceMetrics.Errors, 1)
+				result.Errors = append(result.Errors, fmt.Sprintf("%s/%s: %v", advertiser.ID, currency, err))
+				continue
+			}
+			switch outcome {
+			case invoiceCreated:
+				result.Created++
+				atomic.AddInt64(&invoiceMetrics.InvoicesCreated, 1)
+			case invoiceUpdated:
+				result.Updated++
+				atomic.AddInt64(&invoiceMetrics.InvoicesUpdated, 1)
+			default:
+				result.Skipped++
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// groupLinesByCurrency splits lines per currency, in first-seen order.
+// Currencies of pending invoices with no lines left are included with none,
+// so those invoices get zeroed.
+func groupLinesByCurrency(lines []invoiceLine, pendingCurrencies []string) ([]string, map[string][]invoiceLine) {
+	byCurrency := make(map[string][]invoiceLine)
+	var currencies []string
+	for _, line := range lines {
+		if _, ok := byCurrency[line.Currency]; !ok {
+			currencies = append(currencies, line.Currency)
+		}
+		byCurrency[line.Currency] = append(byCurrency[line.Currency], line)
+	}
+	for _, currency := range pendingCurrencies {
+		if _, ok := byCurrency[currency]; !ok {
+			currencies = append(currencies, currency)
+			byCurrency[currency] = nil
+		}
+	}
+	return currencies, byCurrency
+}
+
+// filterLinesByCurrency keeps the lines in the given currencies
+func filterLinesByCurrency(lines []invoiceLine, currencies []string) []invoiceLine {
+	var filtered []invoiceLine
+	for _, line := range lines {
+		for _, currency := range currencies {
+			if line.Currency == currency {
+				filtered = append(filtered, line)
+				break
+			}
+		}
+	}
+	return filtered
+}
+
+// collectLines aggregates billable conversions per offer and currency.
+// Conversions without their own commission fall back to the promoter's payout
+// override, then the offer commission, matching how the postback handler
+// credits promoters.
+func (s *InvoiceService) collectLines(advertiserID uuid.UUID, periodStart, periodEnd time.Time) ([]invoiceLine, error) {
+	var lines []invoiceLine
+	err := s.db.Table("conversions").
+		Select(`offers.id AS offer_id, offers.title AS offer_title,
+			COALESCE(NULLIF(conversions.currency, ''), 'USD') AS currency,
+			COUNT(*) AS conversions,
+			COALESCE(SUM(CASE WHEN conversions.commission > 0 THEN conversions.commission
+				ELSE COALESCE(user_offers.payout_override, offers.commission) END), 0) AS promoter_payout`).
+		Joins("JOIN user_offers ON user_offers.id = conversions.user_offer_id").
+		Joins("JOIN offers ON offers.id = user_offers.offer_id").
+		Where("offers.advertiser_id = ? AND conversions.status IN ?", advertiserID, billableConversionStatuses).
+		Where("COALESCE(conversions.approved_at, conversions.converted_at) >= ? AND COALESCE(conversions.approved_at, conversions.converted_at) < ?",
+			periodStart, periodEnd).
+		Group("offers.id, offers.title, COALESCE(NULLIF(conversions.currency, ''), 'USD')").
+		Order("offers.title").
+		Scan(&lines).Error
+	return lines, err
+}
+
+// platformRateFor returns the advertiser's negotiated rate or the default
+func (s *InvoiceService) platformRateFor(advertiser *models.AfftokUser) float64 {
+	if advertiser.PlatformRate != nil {
+		return *advertiser.PlatformRate
+	}
+	return s.defaultPlatformRate
+}
+
+type invoiceOutcome int
+
+const (
+	invoiceSkipped invoiceOutcome = iota
+	invoiceCreated
+	invoiceUpdated
+)
+
+// upsertInvoice creates the invoice for a period/currency, or refreshes it if it
+// is still pending. Items are replaced wholesale so totals always match them.
+func (s *InvoiceService) upsertInvoice(advertiserID uuid.UUID, year, month int, periodStart, periodEnd time.Time,
+	currency string, rate float64, lines []invoiceLine) (invoiceOutcome, error) {
+
+	outcome := invoiceSkipped
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var invoice models.Invoice
+		err := tx.Where("advertiser_id = ? AND year = ? AND month = ? AND currency = ?",
+			advertiserID, year, month, currency).First(&invoice).Error
+		switch {
+		case err == nil:
+			if invoice.Status != models.InvoiceStatusPending {
+				return nil
+			}
+			outcome = invoiceUpdated
+		case err == gorm.ErrRecordNotFound:
+			if len(lines) == 0 {
+				return nil
+			}
+			invoice = models.Invoice{
+				AdvertiserID: advertiserID,
+				Month:        month,
+				Year:         year,
+				PeriodStart:  periodStart,
+				PeriodEnd:    periodEnd.Add(-time.Second),
+				Currency:     currency,
+				Status:       models.InvoiceStatusPending,
+				DueDate:      periodEnd.AddDate(0, 0, s.dueDays),
+			}
+			outcome = invoiceCreated
+		default:
+			return err
+		}
+
+		items := applyInvoiceLines(&invoice, rate, lines)
+
+		if err := tx.Save(&invoice).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("invoice_id = ?", invoice.ID).Delete(&models.InvoiceItem{}).Error; err != nil {
+			return err
+		}
+		if len(items) == 0 {
+			return nil
+		}
+		for i := range items {
+			items[i].InvoiceID = invoice.ID
+		}
+		return tx.Create(&items).Error
+	})
+	if err != nil {
+		return invoiceSkipped, err
+	}
+	return outcome, nil
+}
+
+// applyInvoiceLines sets an invoice's rate and totals from its lines and
+// returns the matching items
+func applyInvoiceLines(invoice *models.Invoice, rate float64, lines []invoiceLine) []models.InvoiceItem {
+	invoice.TotalConversions = 0
+	invoice.TotalPromoterPayout = 0
+	invoice.PlatformAmount = 0
+	invoice.PlatformRate = rate
+
+	items := make([]models.InvoiceItem, 0, len(lines))
+	for _, line := range lines {
+		platformAmount := roundAmount(line.PromoterPayout * rate)
+		items = append(items, models.InvoiceItem{
+			OfferID:        line.OfferID,
+			OfferTitle:     line.OfferTitle,
+			Conversions:    line.Conversions,
+			PromoterPayout: line.PromoterPayout,
+			PlatformAmount: platformAmount,
+		})
+		invoice.TotalConversions += line.Conversions
+		invoice.TotalPromoterPayout += line.PromoterPayout
+		invoice.PlatformAmount += platformAmount
+	}
+	invoice.PlatformAmount = roundAmount(invoice.PlatformAmount)
+	return items
+}
+
+// roundAmount rounds to two decimal places
+func roundAmount(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// RefreshPendingInvoices regenerates every period that still has pending
+// invoices, other than the one given (already generated by the caller)
+func (s *InvoiceService) RefreshPendingInvoices(skipYear, skipMonth int) error {
+	var periods []struct {
+		Year  int
+		Month int
+	}
+	if err := s.db.Model(&models.Invoice{}).
+		Distinct("year", "month").
+		Where("status = ?", models.InvoiceStatusPending).
+		Where("NOT (year = ? AND month = ?)", skipYear, skipMonth).
+		Scan(&periods).Error; err != nil {
+		atomic.AddInt64(&invoiceMetrics.Errors, 1)
+		return err
+	}
+
+	for _, period := range periods {
+		result, err := s.GenerateMonthlyInvoices(period.Year, period.Month)
+		if err != nil {
+			return err
+		}
+		if result.Updated > 0 || len(result.Errors) > 0 {
+			fmt.Printf("[Invoice] Refreshed %04d-%02d: updated=%d errors=%d\n",
+				result.Year, result.Month, result.Updated, len(result.Errors))
+		}
+	}
+	return nil
+}
+
+// ============================================
+// OVERDUE
+// ============================================
+
+// MarkOverdue flags pending invoices whose due date has passed.
+// Invoices with a payment awaiting confirmation are not touched.
+func (s *InvoiceService) MarkOverdue(now time.Time) (int64, error) {
+	result := s.db.Model(&models.Invoice{}).
+		Where("status = ? AND due_date < ?", models.InvoiceStatusPending, now).
+		UpdateColumns(map[string]interface{}{
+			"status":     models.InvoiceStatusOverdue,
+			"updated_at": now,
+		})
+	if result.Error != nil {
+		atomic.AddInt64(&invoiceMetrics.Errors, 1)
+		return 0, result.Error
+	}
+	atomic.AddInt64(&invoiceMetrics.MarkedOverdue, result.RowsAffected)
+	return result.RowsAffected, nil
+}
+
+// ============================================
+// SCHEDULER
+// ============================================
+
+// Start starts the invoice scheduler
+func (s *InvoiceService) Start() {
+	if s.isRunning {
+		return
+	}
+	s.isRunning = true
+
+	s.wg.Add(1)
+	go s.scheduler()
+}
+
+// Stop stops the invoice scheduler
+func (s *InvoiceService) Stop() {
+	if !s.isRunning {
+		return
+	}
+	s.isRunning = false
+	close(s.stopChan)
+	s.wg.Wait()
+}
+
+// scheduler invoices the previous month, refreshes older pending invoices and
+// marks overdue invoices. Generation is idempotent, so running it every
+// interval simply keeps pending invoices in sync with late approvals and
+// reversals until the advertiser pays.
+func (s *InvoiceService) scheduler() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.checkInterval)
+	defer ticker.Stop()
+
+	s.runScheduled(time.Now().UTC())
+
+	for {
+		select {
+		case <-s.stopChan:
+			return
+		case <-ticker.C:
+			s.runScheduled(time.Now().UTC())
+		}
+	}
+}
+
+func (s *InvoiceService) runScheduled(now time.Time) {
+	previous := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, -1, 0)
+
+	result, err := s.GenerateMonthlyInvoices(previous.Year(), int(previous.Month()))
+	if err != nil {
+		fmt.Printf("[Invoice] Scheduled generation failed: %v\n", err)
+	} else if result.Created > 0 || len(result.Errors) > 0 {
+		fmt.Printf("[Invoice] Generated %04d-%02d: created=%d updated=%d errors=%d\n",
+			result.Year, result.Month, result.Created, result.Updated, len(result.Errors))
+	}
+
+	if err := s.RefreshPendingInvoices(previous.Year(), int(previous.Month())); err != nil {
+		fmt.Printf("[Invoice] Failed to refresh pending invoices: %v\n", err)
+	}
+
+	if marked, err := s.MarkOverdue(now); err != nil {
+		fmt.Printf("[Invoice] Failed to mark overdue invoices: %v\n", err)
+	} else if marked > 0 {
+		fmt.Printf("[Invoice] Marked %d invoice(s) overdue\n", marked)
+	}
+}
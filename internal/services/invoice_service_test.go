@@ -0,0 +1,172 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/google/uuid"
+)
+
+func TestPeriodBounds(t *testing.T) {
+	tests := []struct {
+		year, month int
+		wantStart   time.Time
+		wantEnd     time.Time
+	}{
+		{2026, 1, time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{2026, 2, time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{2026, 12, time.Date(2026, 12, 1, 0, 0, 0, 0, time.UTC), time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		start, end := PeriodBounds(tt.year, tt.month)
+		if !start.Equal(tt.wantStart) || !end.Equal(tt.wantEnd) {
+			t.Errorf("PeriodBounds(%d, %d) = %v, %v; want %v, %v", tt.year, tt.month, start, end, tt.wantStart, tt.wantEnd)
+		}
+	}
+}
+
+func TestGroupLinesByCurrency(t *testing.T) {
+	usd1 := invoiceLine{OfferTitle: "a", Currency: "USD"}
+	kwd := invoiceLine{OfferTitle: "b", Currency: "KWD"}
+	usd2 := invoiceLine{OfferTitle: "c", Currency: "USD"}
+
+	tests := []struct {
+		name           string
+		lines          []invoiceLine
+		pending        []string
+		wantCurrencies []string
+		wantByCurrency map[string][]invoiceLine
+	}{
+		{
+			name:           "first-seen order",
+			lines:          []invoiceLine{usd1, kwd, usd2},
+			wantCurrencies: []string{"USD", "KWD"},
+			wantByCurrency: map[string][]invoiceLine{"USD": {usd1, usd2}, "KWD": {kwd}},
+		},
+		{
+			name:           "pending currency with no lines left",
+			lines:          []invoiceLine{usd1},
+			pending:        []string{"USD", "SAR"},
+			wantCurrencies: []string{"USD", "SAR"},
+			wantByCurrency: map[string][]invoiceLine{"USD": {usd1}, "SAR": nil},
+		},
+		{
+			name: "nothing billable",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			currencies, byCurrency := groupLinesByCurrency(tt.lines, tt.pending)
+			if !reflect.DeepEqual(currencies, tt.wantCurrencies) {
+				t.Errorf("currencies = %v, want %v", currencies, tt.wantCurrencies)
+			}
+			if len(byCurrency) != len(tt.wantByCurrency) {
+				t.Fatalf("byCurrency = %v, want %v", byCurrency, tt.wantByCurrency)
+			}
+			for currency, want := range tt.wantByCurrency {
+				if !reflect.DeepEqual(byCurrency[currency], want) {
+					t.Errorf("byCurrency[%s] = %v, want %v", currency, byCurrency[currency], want)
+				}
+			}
+		})
+	}
+}
+
+func TestFilterLinesByCurrency(t *testing.T) {
+	lines := []invoiceLine{{Currency: "USD"}, {Currency: "KWD"}, {Currency: "USD"}}
+
+	if got := filterLinesByCurrency(lines, []string{"USD"}); len(got) != 2 {
+		t.Errorf("USD lines = %d, want 2", len(got))
+	}
+	if got := filterLinesByCurrency(lines, nil); len(got) != 0 {
+		t.Errorf("lines with no currencies = %d, want 0", len(got))
+	}
+}
+
+func TestApplyInvoiceLines(t *testing.T) {
+	offerA, offerB := uuid.New(), uuid.New()
+
+	tests := []struct {
+		name            string
+		rate            float64
+		lines           []invoiceLine
+		wantConversions int
+		wantPayout      float64
+		wantPlatform    float64
+		wantItems       []float64 // Platform amount per item
+	}{
+		{
+			name: "rate applied per item",
+			rate: 0.10,
+			lines: []invoiceLine{
+				{OfferID: offerA, Conversions: 3, PromoterPayout: 150},
+				{OfferID: offerB, Conversions: 1, PromoterPayout: 33.33},
+			},
+			wantConversions: 4,
+			wantPayout:      183.33,
+			wantPlatform:    18.33,
+			wantItems:       []float64{15, 3.33},
+		},
+		{
+			name:            "item amounts rounded before totalling",
+			rate:            0.125,
+			lines:           []invoiceLine{{OfferID: offerA, Conversions: 1, PromoterPayout: 0.1}, {OfferID: offerB, Conversions: 1, PromoterPayout: 0.1}},
+			wantConversions: 2,
+			wantPayout:      0.2,
+			wantPlatform:    0.02,
+			wantItems:       []float64{0.01, 0.01},
+		},
+		{
+			name:      "no lines zeroes the invoice",
+			rate:      0.10,
+			wantItems: []float64{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			invoice := &models.Invoice{TotalConversions: 99, TotalPromoterPayout: 999, PlatformAmount: 99}
+			items := applyInvoiceLines(invoice, tt.rate, tt.lines)
+
+			if invoice.TotalConversions != tt.wantConversions {
+				t.Errorf("TotalConversions = %d, want %d", invoice.TotalConversions, tt.wantConversions)
+			}
+			if roundAmount(invoice.TotalPromoterPayout) != tt.wantPayout {
+				t.Errorf("TotalPromoterPayout = %v, want %v", invoice.TotalPromoterPayout, tt.wantPayout)
+			}
+			if invoice.PlatformAmount != tt.wantPlatform {
+				t.Errorf("PlatformAmount = %v, want %v", invoice.PlatformAmount, tt.wantPlatform)
+			}
+			if invoice.PlatformRate != tt.rate {
+				t.Errorf("PlatformRate = %v, want %v", invoice.PlatformRate, tt.rate)
+			}
+			if len(items) != len(tt.wantItems) {
+				t.Fatalf("items = %d, want %d", len(items), len(tt.wantItems))
+			}
+			for i, want := range tt.wantItems {
+				if items[i].PlatformAmount != want {
+					t.Errorf("items[%d].PlatformAmount = %v, want %v", i, items[i].PlatformAmount, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRoundAmount(t *testing.T) {
+	tests := map[float64]float64{
+		1.005:   1, // Float representation of 1.005 is just below it
+		1.006:   1.01,
+		2.5:     2.5,
+		-1.234:  -1.23,
+		100.999: 101,
+	}
+	for in, want := range tests {
+		if got := roundAmount(in); got != want {
+			t.Errorf("roundAmount(%v) = %v, want %v", in, got, want)
+		}
+	}
+}
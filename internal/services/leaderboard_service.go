@@ -0,0 +1,982 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aljapah/afftok-backend-prod/internal/cache"
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ============================================
+// LEADERBOARDS
+// ============================================
+
+// Leaderboard scoring weights
+const (
+	LeaderboardPointsPerClick      = 2
+	LeaderboardPointsPerConversion = 20
+)
+
+// Leaderboard periods
+const (
+	LeaderboardDaily   = "daily"
+	LeaderboardWeekly  = "weekly"
+	LeaderboardMonthly = "monthly"
+	LeaderboardAllTime = "all_time"
+)
+
+// IsValidLeaderboardPeriod checks if a leaderboard period is supported
+func IsValidLeaderboardPeriod(period string) bool {
+	switch period {
+	case LeaderboardDaily, LeaderboardWeekly, LeaderboardMonthly, LeaderboardAllTime:
+		return true
+	}
+	return false
+}
+
+// windowedLeaderboardPeriods are the periods that reset, with how long a
+// board is kept after its period ends
+var windowedLeaderboardPeriods = map[string]time.Duration{
+	LeaderboardDaily:   2 * 24 * time.Hour,
+	LeaderboardWeekly:  8 * 24 * time.Hour,
+	LeaderboardMonthly: 32 * 24 * time.Hour,
+}
+
+// leaderboardPromoterRoles are the roles ranked on leaderboards. "user" and
+// empty are legacy promoter roles.
+var leaderboardPromoterRoles = []string{"promoter", "user", ""}
+
+const (
+	leaderboardKeyPrefix    = "leaderboard:"
+	leaderboardReconcileKey = "leaderboard:reconcile:lock"
+	leaderboardMemberTTL    = 10 * time.Minute
+	leaderboardZAddChunk    = 1000
+	maxLeaderboardLimit     = 100
+	maxLeaderboardAround    = 10
+)
+
+// LeaderboardSegment narrows a leaderboard to a country or a team. The zero
+// value is the global board.
+type LeaderboardSegment struct {
+	Country string
+	TeamID  *uuid.UUID
+}
+
+// Name returns the segment's key suffix: "global", "country:<code>" or "team:<id>"
+func (s LeaderboardSegment) Name() string {
+	switch {
+	case s.TeamID != nil:
+		return "team:" + s.TeamID.String()
+	case s.Country != "":
+		return "country:" + normalizeLeaderboardCountry(s.Country)
+	}
+	return "global"
+}
+
+// normalizeLeaderboardCountry normalizes a profile country for board keys
+func normalizeLeaderboardCountry(country string) string {
+	return strings.ToUpper(strings.TrimSpace(country))
+}
+
+// LeaderboardEntry is a ranked promoter
+type LeaderboardEntry struct {
+	Rank      int64     `json:"rank"` // 0 when not ranked yet
+	UserID    uuid.UUID `json:"id"`
+	Username  string    `json:"username"`
+	FullName  string    `json:"full_name"`
+	AvatarURL string    `json:"avatar_url"`
+	Country   string    `json:"country"`
+	Points    int64     `json:"points"`
+
+	// Lifetime totals, only on the all-time board
+	TotalClicks      int `json:"total_clicks,omitempty"`
+	TotalConversions int `json:"total_conversions,omitempty"`
+}
+
+// Leaderboard is a page of a leaderboard with the caller's own position
+type Leaderboard struct {
+	Period     string             `json:"period"`
+	PeriodKey  string             `json:"period_key,omitempty"` // e.g. 2026-10-16, 2026-W42, 2026-10
+	Segment    string             `json:"segment"`
+	Entries    []LeaderboardEntry `json:"leaderboard"`
+	Me         *LeaderboardEntry  `json:"my_rank"`
+	Neighbours []LeaderboardEntry `json:"neighbours"` // Promoters ranked around the caller, the caller included
+	Total      int64              `json:"total"`
+	Source     string             `json:"source"` // redis, or database when Redis is unavailable
+}
+
+// LeaderboardMetrics tracks leaderboard activity
+type LeaderboardMetrics struct {
+	EventsRecorded  int64
+	Flushes         int64
+	FlushErrors     int64
+	Reconciles      int64
+	ReconcileErrors int64
+}
+
+var leaderboardMetrics = &LeaderboardMetrics{}
+
+// GetLeaderboardMetrics returns leaderboard metrics
+func GetLeaderboardMetrics() *LeaderboardMetrics {
+	return &LeaderboardMetrics{
+		EventsRecorded:  atomic.LoadInt64(&leaderboardMetrics.EventsRecorded),
+		Flushes:         atomic.LoadInt64(&leaderboardMetrics.Flushes),
+		FlushErrors:     atomic.LoadInt64(&leaderboardMetrics.FlushErrors),
+		Reconciles:      atomic.LoadInt64(&leaderboardMetrics.Reconciles),
+		ReconcileErrors: atomic.LoadInt64(&leaderboardMetrics.ReconcileErrors),
+	}
+}
+
+// leaderboardBucket accumulates a user offer's points for one day
+type leaderboardBucket struct {
+	userOfferID uuid.UUID
+	day         string
+}
+
+// leaderboardMember is the promoter behind a user offer and the segments
+// they are ranked in
+type leaderboardMember struct {
+	userID    uuid.UUID
+	country   string
+	teamID    *uuid.UUID
+	eligible  bool
+	expiresAt time.Time
+}
+
+// LeaderboardService maintains daily, weekly, monthly and all-time
+// leaderboards, globally and per country and team, in Redis sorted sets.
+// Clicks and conversions are accumulated in memory and added to the boards
+// on each flush; a nightly reconciliation rebuilds the boards from the
+// database so they never drift.
+type LeaderboardService struct {
+	db *gorm.DB
+
+	mu      sync.Mutex
+	pending map[leaderboardBucket]int64
+
+	membersMu sync.RWMutex
+	members   map[uuid.UUID]*leaderboardMember // By user offer ID
+
+	// Configuration
+	flushInterval time.Duration
+	reconcileHour int // UTC
+
+	// State
+	isRunning bool
+	stopChan  chan struct{}
+	wg        sync.WaitGroup
+}
+
+var (
+	leaderboardServiceInstance *LeaderboardService
+	leaderboardServiceOnce     sync.Once
+)
+
+// NewLeaderboardService creates a new leaderboard service
+func NewLeaderboardService(db *gorm.DB) *LeaderboardService {
+	reconcileHour := 3
+	if value, err := strconv.Atoi(os.Getenv("LEADERBOARD_RECONCILE_HOUR")); err == nil && value >= 0 && value < 24 {
+		reconcileHour = value
+	}
+
+	return &LeaderboardService{
+		db:            db,
+		pending:       make(map[leaderboardBucket]int64),
+		members:       make(map[uuid.UUID]*leaderboardMember),
+		flushInterval: 5 * time.Second,
+		reconcileHour: reconcileHour,
+		stopChan:      make(chan struct{}),
+	}
+}
+
+// GetLeaderboardService returns the singleton leaderboard service
+func GetLeaderboardService(db *gorm.DB) *LeaderboardService {
+	leaderboardServiceOnce.Do(func() {
+		leaderboardServiceInstance = NewLeaderboardService(db)
+	})
+	return leaderboardServiceInstance
+}
+
+// ============================================
+// KEYS
+// ============================================
+
+// leaderboardPeriodKey identifies the period containing t
+func leaderboardPeriodKey(period string, t time.Time) string {
+	t = t.UTC()
+	switch period {
+	case LeaderboardDaily:
+		return t.Format("2006-01-02")
+	case LeaderboardWeekly:
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	case LeaderboardMonthly:
+		return t.Format("2006-01")
+	}
+	return ""
+}
+
+// leaderboardPeriodBounds returns the start and end of the period containing t
+func leaderboardPeriodBounds(period string, t time.Time) (time.Time, time.Time) {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	switch period {
+	case LeaderboardDaily:
+		return day, day.AddDate(0, 0, 1)
+	case LeaderboardWeekly:
+		start := day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7)) // Monday
+		return start, start.AddDate(0, 0, 7)
+	case LeaderboardMonthly:
+		start := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(0, 1, 0)
+	}
+	return time.Time{}, time.Time{}
+}
+
+// leaderboardKey returns the Redis key of a board
+func leaderboardKey(period, periodKey, segment string) string {
+	if period == LeaderboardAllTime {
+		return leaderboardKeyPrefix + period + ":" + segment
+	}
+	return leaderboardKeyPrefix + period + ":" + periodKey + ":" + segment
+}
+
+// segmentNames returns the boards a member is ranked on
+func (m *leaderboardMember) segmentNames() []string {
+	names := []string{"global"}
+	if m.country != "" {
+		names = append(names, LeaderboardSegment{Country: m.country}.Name())
+	}
+	if m.teamID != nil {
+		names = append(names, LeaderboardSegment{TeamID: m.teamID}.Name())
+	}
+	return names
+}
+
+// ============================================
+// EVENT INGESTION
+// ============================================
+
+// RecordClick adds a recorded click to its promoter's boards on the next flush
+func (s *LeaderboardService) RecordClick(userOfferID uuid.UUID, at time.Time) {
+	s.record(userOfferID, at, LeaderboardPointsPerClick)
+}
+
+// RecordConversion adds a recorded conversion to its promoter's boards on the next flush
+func (s *LeaderboardService) RecordConversion(userOfferID uuid.UUID, at time.Time) {
+	s.record(userOfferID, at, LeaderboardPointsPerConversion)
+}
+
+// RemoveConversion takes back the points of a conversion that was rejected or
+// reversed, from the boards of the period it was recorded in
+func (s *LeaderboardService) RemoveConversion(userOfferID uuid.UUID, at time.Time) {
+	s.record(userOfferID, at, -LeaderboardPointsPerConversion)
+}
+
+// record accumulates points for a user offer on the day of the event
+func (s *LeaderboardService) record(userOfferID uuid.UUID, at time.Time, points int64) {
+	if s == nil || userOfferID == uuid.Nil {
+		return
+	}
+	if at.IsZero() {
+		at = time.Now()
+	}
+	atomic.AddInt64(&leaderboardMetrics.EventsRecorded, 1)
+
+	bucket := leaderboardBucket{userOfferID: userOfferID, day: at.UTC().Format("2006-01-02")}
+	s.mu.Lock()
+	s.pending[bucket] += points
+	s.mu.Unlock()
+}
+
+// Flush adds the accumulated points to the boards
+func (s *LeaderboardService) Flush() {
+	s.mu.Lock()
+	if len(s.pending) == 0 {
+		s.mu.Unlock()
+		return
+	}
+	pending := s.pending
+	s.pending = make(map[leaderboardBucket]int64)
+	s.mu.Unlock()
+
+	if cache.RedisClient == nil {
+		// Nothing to update; the next reconciliation rebuilds the boards
+		return
+	}
+
+	userOfferIDs := make([]uuid.UUID, 0, len(pending))
+	for bucket := range pending {
+		userOfferIDs = append(userOfferIDs, bucket.userOfferID)
+	}
+	members, err := s.resolveMembers(userOfferIDs)
+	if err != nil {
+		atomic.AddInt64(&leaderboardMetrics.FlushErrors, 1)
+		fmt.Printf("[Leaderboard] Failed to resolve promoters: %v\n", err)
+		return
+	}
+
+	ctx := context.Background()
+	now := time.Now().UTC()
+	pipe := cache.RedisClient.Pipeline()
+	for bucket, points := range pending {
+		member := members[bucket.userOfferID]
+		if member == nil || !member.eligible {
+			continue
+		}
+		day, err := time.Parse("2006-01-02", bucket.day)
+		if err != nil {
+			continue
+		}
+
+		userID := member.userID.String()
+		for _, segment := range member.segmentNames() {
+			pipe.ZIncrBy(ctx, leaderboardKey(LeaderboardAllTime, "", segment), float64(points), userID)
+
+			for period, retention := range windowedLeaderboardPeriods {
+				_, end := leaderboardPeriodBounds(period, day)
+				expireAt := end.Add(retention)
+				if !expireAt.After(now) {
+					continue // Board already expired, e.g. a late WAL replay
+				}
+				key := leaderboardKey(period, leaderboardPeriodKey(period, day), segment)
+				pipe.ZIncrBy(ctx, key, float64(points), userID)
+				pipe.ExpireAt(ctx, key, expireAt)
+			}
+		}
+	}
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		atomic.AddInt64(&leaderboardMetrics.FlushErrors, 1)
+		fmt.Printf("[Leaderboard] Failed to update boards: %v\n", err)
+		return
+	}
+	atomic.AddInt64(&leaderboardMetrics.Flushes, 1)
+}
+
+// resolveMembers maps user offers to their promoters, from the member cache
+// or the database
+func (s *LeaderboardService) resolveMembers(userOfferIDs []uuid.UUID) (map[uuid.UUID]*leaderboardMember, error) {
+	now := time.Now()
+	members := make(map[uuid.UUID]*leaderboardMember, len(userOfferIDs))
+	var missing []uuid.UUID
+
+	s.membersMu.RLock()
+	for _, id := range userOfferIDs {
+		if member, ok := s.members[id]; ok && now.Before(member.expiresAt) {
+			members[id] = member
+		} else {
+			missing = append(missing, id)
+		}
+	}
+	s.membersMu.RUnlock()
+
+	if len(missing) == 0 {
+		return members, nil
+	}
+
+	var rows []struct {
+		UserOfferID uuid.UUID
+		UserID      uuid.UUID
+		Country     string
+		Role        string
+		Status      string
+	}
+	if err := s.db.Table("user_offers").
+		Select("user_offers.id AS user_offer_id, afftok_users.id AS user_id, COALESCE(afftok_users.country, '') AS country, COALESCE(afftok_users.role, '') AS role, afftok_users.status").
+		Joins("JOIN afftok_users ON afftok_users.id = user_offers.user_id").
+		Where("user_offers.id IN ?", missing).
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	userIDs := make([]uuid.UUID, 0, len(rows))
+	for _, row := range rows {
+		userIDs = append(userIDs, row.UserID)
+	}
+	teams, err := s.loadTeams(userIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	s.membersMu.Lock()
+	defer s.membersMu.Unlock()
+	for _, row := range rows {
+		member := &leaderboardMember{
+			userID:    row.UserID,
+			country:   normalizeLeaderboardCountry(row.Country),
+			teamID:    teams[row.UserID],
+			eligible:  isLeaderboardRole(row.Role) && row.Status == "active",
+			expiresAt: now.Add(leaderboardMemberTTL),
+		}
+		s.members[row.UserOfferID] = member
+		members[row.UserOfferID] = member
+	}
+	return members, nil
+}
+
+// isLeaderboardRole reports whether a role is ranked on leaderboards
+func isLeaderboardRole(role string) bool {
+	for _, ranked := range leaderboardPromoterRoles {
+		if role == ranked {
+			return true
+		}
+	}
+	return false
+}
+
+// loadTeams returns the active team of each user (nil users: all users)
+func (s *LeaderboardService) loadTeams(userIDs []uuid.UUID) (map[uuid.UUID]*uuid.UUID, error) {
+	var rows []struct {
+		UserID uuid.UUID
+		TeamID uuid.UUID
+	}
+	query := s.db.Model(&models.TeamMember{}).
+		Select("user_id, team_id").
+		Where("status = ?", models.TeamMemberStatusActive)
+	if userIDs != nil {
+		if len(userIDs) == 0 {
+			return map[uuid.UUID]*uuid.UUID{}, nil
+		}
+		query = query.Where("user_id IN ?", userIDs)
+	}
+	if err := query.Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	teams := make(map[uuid.UUID]*uuid.UUID, len(rows))
+	for _, row := range rows {
+		teamID := row.TeamID
+		teams[row.UserID] = &teamID
+	}
+	return teams, nil
+}
+
+// ============================================
+// WORKER
+// ============================================
+
+// Start starts the flush loop and the nightly reconciliation. The boards are
+// built right away if they don't exist yet (first deploy or Redis flushed).
+func (s *LeaderboardService) Start() {
+	if s.isRunning {
+		return
+	}
+	s.isRunning = true
+
+	s.wg.Add(1)
+	go s.worker()
+
+	if cache.RedisClient != nil {
+		if exists, err := cache.Exists(context.Background(), leaderboardKey(LeaderboardAllTime, "", "global")); err == nil && exists == 0 {
+			go func() {
+				if err := s.Reconcile(time.Now()); err != nil {
+					fmt.Printf("[Leaderboard] Initial build failed: %v\n", err)
+				}
+			}()
+		}
+	}
+}
+
+// Stop stops the leaderboard service, flushing pending points first
+func (s *LeaderboardService) Stop() {
+	if !s.isRunning {
+		return
+	}
+	s.isRunning = false
+	close(s.stopChan)
+	s.wg.Wait()
+}
+
+// worker flushes points and runs the nightly reconciliation
+func (s *LeaderboardService) worker() {
+	defer s.wg.Done()
+
+	flushTicker := time.NewTicker(s.flushInterval)
+	defer flushTicker.Stop()
+	reconcileTimer := time.NewTimer(s.untilReconcile(time.Now()))
+	defer reconcileTimer.Stop()
+
+	for {
+		select {
+		case <-s.stopChan:
+			s.Flush()
+			return
+		case <-flushTicker.C:
+			s.Flush()
+		case <-reconcileTimer.C:
+			if err := s.Reconcile(time.Now()); err != nil {
+				fmt.Printf("[Leaderboard] Nightly reconciliation failed: %v\n", err)
+			}
+			reconcileTimer.Reset(s.untilReconcile(time.Now()))
+		}
+	}
+}
+
+// untilReconcile returns the time until the next reconciliation hour
+func (s *LeaderboardService) untilReconcile(now time.Time) time.Duration {
+	now = now.UTC()
+	next := time.Date(now.Year(), now.Month(), now.Day(), s.reconcileHour, 0, 0, 0, time.UTC)
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next.Sub(now)
+}
+
+// ============================================
+// RECONCILIATION
+// ============================================
+
+// Reconcile rebuilds the current boards of every period and segment from the
+// database and removes boards of segments that no longer have members. One
+// instance runs it at a time.
+func (s *LeaderboardService) Reconcile(now time.Time) error {
+	if cache.RedisClient == nil {
+		return fmt.Errorf("redis not available")
+	}
+
+	ctx := context.Background()
+	locked, err := cache.SetNX(ctx, leaderboardReconcileKey, "1", 30*time.Minute)
+	if err != nil {
+		return err
+	}
+	if !locked {
+		return nil
+	}
+	defer cache.Delete(ctx, leaderboardReconcileKey)
+
+	start := time.Now()
+	atomic.AddInt64(&leaderboardMetrics.Reconciles, 1)
+	s.Flush()
+
+	members, err := s.loadEligibleMembers()
+	if err != nil {
+		atomic.AddInt64(&leaderboardMetrics.ReconcileErrors, 1)
+		return err
+	}
+
+	for _, period := range []string{LeaderboardAllTime, LeaderboardDaily, LeaderboardWeekly, LeaderboardMonthly} {
+		scores, err := s.loadScores(period, now)
+		if err != nil {
+			atomic.AddInt64(&leaderboardMetrics.ReconcileErrors, 1)
+			return fmt.Errorf("failed to load %s scores: %w", period, err)
+		}
+
+		boards := make(map[string][]cache.RedisZ)
+		for userID, points := range scores {
+			member := members[userID]
+			if member == nil || points <= 0 {
+				continue
+			}
+			for _, segment := range member.segmentNames() {
+				boards[segment] = append(boards[segment], cache.RedisZ{Score: float64(points), Member: userID.String()})
+			}
+		}
+
+		periodKey := leaderboardPeriodKey(period, now)
+		var expireAt time.Time
+		if retention, ok := windowedLeaderboardPeriods[period]; ok {
+			_, end := leaderboardPeriodBounds(period, now)
+			expireAt = end.Add(retention)
+		}
+
+		rebuilt := make(map[string]bool, len(boards))
+		for segment, entries := range boards {
+			key := leaderboardKey(period, periodKey, segment)
+			if err := replaceSortedSet(ctx, key, entries, expireAt); err != nil {
+				atomic.AddInt64(&leaderboardMetrics.ReconcileErrors, 1)
+				return fmt.Errorf("failed to rebuild %s: %w", key, err)
+			}
+			rebuilt[key] = true
+		}
+		s.removeStaleBoards(ctx, leaderboardKey(period, periodKey, "*"), rebuilt)
+	}
+
+	// Pick up role, country and team changes on the next flush
+	s.membersMu.Lock()
+	s.members = make(map[uuid.UUID]*leaderboardMember)
+	s.membersMu.Unlock()
+
+	fmt.Printf("[Leaderboard] Reconciled %d promoters in %v\n", len(members), time.Since(start))
+	return nil
+}
+
+// replaceSortedSet atomically replaces a sorted set with entries
+func replaceSortedSet(ctx context.Context, key string, entries []cache.RedisZ, expireAt time.Time) error {
+	tmpKey := key + ":rebuild"
+	if err := cache.RedisClient.Del(ctx, tmpKey).Err(); err != nil {
+		return err
+	}
+
+	for i := 0; i < len(entries); i += leaderboardZAddChunk {
+		end := i + leaderboardZAddChunk
+		if end > len(entries) {
+			end = len(entries)
+		}
+		if err := cache.RedisClient.ZAdd(ctx, tmpKey, entries[i:end]...).Err(); err != nil {
+			return err
+		}
+	}
+
+	pipe := cache.RedisClient.TxPipeline()
+	pipe.Rename(ctx, tmpKey, key)
+	if !expireAt.IsZero() {
+		pipe.ExpireAt(ctx, key, expireAt)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// removeStaleBoards deletes the boards matching pattern that weren't rebuilt,
+// e.g. of a team whose members all left
+func (s *LeaderboardService) removeStaleBoards(ctx context.Context, pattern string, rebuilt map[string]bool) {
+	var cursor uint64
+	for {
+		keys, next, err := cache.RedisClient.Scan(ctx, cursor, pattern, 100).Result()
+		if err != nil {
+			fmt.Printf("[Leaderboard] Failed to scan boards: %v\n", err)
+			return
+		}
+		for _, key := range keys {
+			if !rebuilt[key] && !strings.HasSuffix(key, ":rebuild") {
+				cache.Delete(ctx, key)
+			}
+		}
+		cursor = next
+		if cursor == 0 {
+			return
+		}
+	}
+}
+
+// loadEligibleMembers returns every ranked promoter by user ID
+func (s *LeaderboardService) loadEligibleMembers() (map[uuid.UUID]*leaderboardMember, error) {
+	var users []struct {
+		ID      uuid.UUID
+		Country string
+	}
+	if err := s.db.Model(&models.AfftokUser{}).
+		Select("id, COALESCE(country, '') AS country").
+		Where("COALESCE(role, '') IN ? AND status = ?", leaderboardPromoterRoles, "active").
+		Scan(&users).Error; err != nil {
+		return nil, err
+	}
+
+	teams, err := s.loadTeams(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	members := make(map[uuid.UUID]*leaderboardMember, len(users))
+	for _, user := range users {
+		members[user.ID] = &leaderboardMember{
+			userID:   user.ID,
+			country:  normalizeLeaderboardCountry(user.Country),
+			teamID:   teams[user.ID],
+			eligible: true,
+		}
+	}
+	return members, nil
+}
+
+// loadScores computes every promoter's points for the period containing now
+// from the database: clicks and conversions recorded in the period, or over
+// their lifetime for the all-time board. Rejected and reversed conversions
+// don't count.
+func (s *LeaderboardService) loadScores(period string, now time.Time) (map[uuid.UUID]int64, error) {
+	scores := make(map[uuid.UUID]int64)
+	conversions := s.db.Table("conversions").Where("conversions.status NOT IN ?", models.VoidedConversionStatuses)
+
+	if period == LeaderboardAllTime {
+		// Lifetime clicks come from the counters rather than a scan of every click
+		var rows []struct {
+			ID     uuid.UUID
+			Clicks int64
+		}
+		if err := s.db.Model(&models.AfftokUser{}).
+			Select("id, total_clicks AS clicks").
+			Where("COALESCE(role, '') IN ? AND status = ?", leaderboardPromoterRoles, "active").
+			Scan(&rows).Error; err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			scores[row.ID] = row.Clicks * LeaderboardPointsPerClick
+		}
+		if err := addPromoterCounts(scores, conversions, "conversions", LeaderboardPointsPerConversion); err != nil {
+			return nil, err
+		}
+		return scores, nil
+	}
+
+	start, end := leaderboardPeriodBounds(period, now)
+	clicks := s.db.Table("clicks").Where("clicks.clicked_at >= ? AND clicks.clicked_at < ?", start, end)
+	if err := addPromoterCounts(scores, clicks, "clicks", LeaderboardPointsPerClick); err != nil {
+		return nil, err
+	}
+	conversions = conversions.Where("conversions.converted_at >= ? AND conversions.converted_at < ?", start, end)
+	if err := addPromoterCounts(scores, conversions, "conversions", LeaderboardPointsPerConversion); err != nil {
+		return nil, err
+	}
+	return scores, nil
+}
+
+// addPromoterCounts counts the rows of a query on a table of user offer
+// events per promoter and adds points for each to scores
+func addPromoterCounts(scores map[uuid.UUID]int64, query *gorm.DB, table string, points int64) error {
+	var rows []struct {
+		UserID uuid.UUID
+		Count  int64
+	}
+	if err := query.
+		Select("user_offers.user_id AS user_id, COUNT(*) AS count").
+		Joins("JOIN user_offers ON user_offers.id = " + table + ".user_offer_id").
+		Group("user_offers.user_id").
+		Scan(&rows).Error; err != nil {
+		return err
+	}
+	for _, row := range rows {
+		scores[row.UserID] += row.Count * points
+	}
+	return nil
+}
+
+// ============================================
+// QUERIES
+// ============================================
+
+// GetLeaderboard returns the top promoters of a board plus the caller's rank
+// and the promoters ranked around them. It falls back to computing the board
+// from the database when Redis is unavailable.
+func (s *LeaderboardService) GetLeaderboard(period string, segment LeaderboardSegment, userID uuid.UUID, limit, around int) (*Leaderboard, error) {
+	if !IsValidLeaderboardPeriod(period) {
+		return nil, fmt.Errorf("invalid leaderboard period: %s", period)
+	}
+	if limit <= 0 {
+		limit = 10
+	}
+	if limit > maxLeaderboardLimit {
+		limit = maxLeaderboardLimit
+	}
+	if around < 0 {
+		around = 0
+	}
+	if around > maxLeaderboardAround {
+		around = maxLeaderboardAround
+	}
+
+	now := time.Now()
+	board := &Leaderboard{
+		Period:    period,
+		PeriodKey: leaderboardPeriodKey(period, now),
+		Segment:   segment.Name(),
+	}
+
+	var err error
+	if cache.RedisClient != nil {
+		err = s.readFromRedis(board, userID, limit, around)
+		if err == nil {
+			board.Source = "redis"
+		}
+	}
+	if cache.RedisClient == nil || err != nil {
+		if err := s.readFromDatabase(board, segment, now, userID, limit, around); err != nil {
+			return nil, err
+		}
+		board.Source = "database"
+	}
+
+	if board.Entries == nil {
+		board.Entries = []LeaderboardEntry{}
+	}
+	if board.Neighbours == nil {
+		board.Neighbours = []LeaderboardEntry{}
+	}
+
+	if err := s.hydrate(board); err != nil {
+		return nil, err
+	}
+	return board, nil
+}
+
+// readFromRedis reads a board from its sorted set
+func (s *LeaderboardService) readFromRedis(board *Leaderboard, userID uuid.UUID, limit, around int) error {
+	ctx := context.Background()
+	key := leaderboardKey(board.Period, board.PeriodKey, board.Segment)
+
+	total, err := cache.RedisClient.ZCard(ctx, key).Result()
+	if err != nil {
+		return err
+	}
+	board.Total = total
+
+	top, err := cache.RedisClient.ZRevRangeWithScores(ctx, key, 0, int64(limit-1)).Result()
+	if err != nil {
+		return err
+	}
+	board.Entries = sortedSetEntries(top, 0)
+
+	rank, err := cache.RedisClient.ZRevRank(ctx, key, userID.String()).Result()
+	if err != nil {
+		if cache.IsNil(err) {
+			board.Me = &LeaderboardEntry{UserID: userID}
+			return nil
+		}
+		return err
+	}
+
+	start := rank - int64(around)
+	if start < 0 {
+		start = 0
+	}
+	neighbours, err := cache.RedisClient.ZRevRangeWithScores(ctx, key, start, rank+int64(around)).Result()
+	if err != nil {
+		return err
+	}
+	board.Neighbours = sortedSetEntries(neighbours, start)
+	for i := range board.Neighbours {
+		if board.Neighbours[i].UserID == userID {
+			me := board.Neighbours[i]
+			board.Me = &me
+		}
+	}
+	return nil
+}
+
+// sortedSetEntries converts sorted set members ranked from offset
+func sortedSetEntries(members []cache.RedisZ, offset int64) []LeaderboardEntry {
+	entries := make([]LeaderboardEntry, 0, len(members))
+	for i, z := range members {
+		member, _ := z.Member.(string)
+		userID, err := uuid.Parse(member)
+		if err != nil {
+			continue
+		}
+		entries = append(entries, LeaderboardEntry{
+			Rank:   offset + int64(i) + 1,
+			UserID: userID,
+			Points: int64(z.Score),
+		})
+	}
+	return entries
+}
+
+// readFromDatabase computes a board from the database
+func (s *LeaderboardService) readFromDatabase(board *Leaderboard, segment LeaderboardSegment, now time.Time, userID uuid.UUID, limit, around int) error {
+	scores, err := s.loadScores(board.Period, now)
+	if err != nil {
+		return err
+	}
+	members, err := s.loadEligibleMembers()
+	if err != nil {
+		return err
+	}
+
+	rankLeaderboard(board, scores, members, segment, userID, limit, around)
+	return nil
+}
+
+// rankLeaderboard ranks the members of a segment by points, ties broken by
+// user ID, and fills in the top entries and the caller's neighbourhood
+func rankLeaderboard(board *Leaderboard, scores map[uuid.UUID]int64, members map[uuid.UUID]*leaderboardMember, segment LeaderboardSegment, userID uuid.UUID, limit, around int) {
+	segmentName := segment.Name()
+	var ranked []LeaderboardEntry
+	for id, points := range scores {
+		member := members[id]
+		if member == nil || points <= 0 {
+			continue
+		}
+		inSegment := false
+		for _, name := range member.segmentNames() {
+			if name == segmentName {
+				inSegment = true
+			}
+		}
+		if inSegment {
+			ranked = append(ranked, LeaderboardEntry{UserID: id, Points: points})
+		}
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Points != ranked[j].Points {
+			return ranked[i].Points > ranked[j].Points
+		}
+		return ranked[i].UserID.String() > ranked[j].UserID.String()
+	})
+	for i := range ranked {
+		ranked[i].Rank = int64(i) + 1
+	}
+
+	board.Total = int64(len(ranked))
+	board.Entries = ranked[:min(limit, len(ranked))]
+	board.Me = &LeaderboardEntry{UserID: userID}
+	for i := range ranked {
+		if ranked[i].UserID != userID {
+			continue
+		}
+		me := ranked[i]
+		board.Me = &me
+		board.Neighbours = ranked[max(0, i-around):min(len(ranked), i+around+1)]
+		break
+	}
+}
+
+// hydrate fills in the profile of every promoter on a board
+func (s *LeaderboardService) hydrate(board *Leaderboard) error {
+	ids := make([]uuid.UUID, 0, len(board.Entries)+len(board.Neighbours)+1)
+	for _, entry := range board.Entries {
+		ids = append(ids, entry.UserID)
+	}
+	for _, entry := range board.Neighbours {
+		ids = append(ids, entry.UserID)
+	}
+	if board.Me != nil {
+		ids = append(ids, board.Me.UserID)
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	var users []models.AfftokUser
+	if err := s.db.Select("id", "username", "full_name", "avatar_url", "country", "total_clicks", "total_conversions").
+		Where("id IN ?", ids).Find(&users).Error; err != nil {
+		return err
+	}
+	byID := make(map[uuid.UUID]*models.AfftokUser, len(users))
+	for i := range users {
+		byID[users[i].ID] = &users[i]
+	}
+
+	fill := func(entry *LeaderboardEntry) {
+		user := byID[entry.UserID]
+		if user == nil {
+			return
+		}
+		entry.Username = user.Username
+		entry.FullName = user.FullName
+		entry.AvatarURL = user.AvatarURL
+		entry.Country = user.Country
+		if board.Period == LeaderboardAllTime {
+			entry.TotalClicks = user.TotalClicks
+			entry.TotalConversions = user.TotalConversions
+		}
+	}
+	for i := range board.Entries {
+		fill(&board.Entries[i])
+	}
+	for i := range board.Neighbours {
+		fill(&board.Neighbours[i])
+	}
+	if board.Me != nil {
+		fill(board.Me)
+	}
+	return nil
+}
@@ -0,0 +1,187 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestLeaderboardPeriods(t *testing.T) {
+	date := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	}
+	riyadh := time.FixedZone("AST", 3*60*60)
+
+	tests := []struct {
+		name      string
+		period    string
+		at        time.Time
+		wantKey   string
+		wantStart time.Time
+		wantEnd   time.Time
+	}{
+		{"daily", LeaderboardDaily, time.Date(2026, 10, 16, 23, 59, 59, 0, time.UTC), "2026-10-16", date(2026, 10, 16), date(2026, 10, 17)},
+		{"daily in UTC", LeaderboardDaily, time.Date(2026, 10, 17, 1, 0, 0, 0, riyadh), "2026-10-16", date(2026, 10, 16), date(2026, 10, 17)},
+		{"daily year end", LeaderboardDaily, time.Date(2026, 12, 31, 12, 0, 0, 0, time.UTC), "2026-12-31", date(2026, 12, 31), date(2027, 1, 1)},
+
+		{"weekly on a Friday", LeaderboardWeekly, date(2026, 10, 16), "2026-W42", date(2026, 10, 12), date(2026, 10, 19)},
+		{"weekly on a Monday", LeaderboardWeekly, date(2026, 10, 12), "2026-W42", date(2026, 10, 12), date(2026, 10, 19)},
+		{"weekly on a Sunday", LeaderboardWeekly, time.Date(2026, 10, 18, 23, 0, 0, 0, time.UTC), "2026-W42", date(2026, 10, 12), date(2026, 10, 19)},
+		{"weekly in UTC", LeaderboardWeekly, time.Date(2026, 10, 19, 1, 0, 0, 0, riyadh), "2026-W42", date(2026, 10, 12), date(2026, 10, 19)},
+		{"first ISO week starts in the previous year", LeaderboardWeekly, date(2026, 1, 1), "2026-W01", date(2025, 12, 29), date(2026, 1, 5)},
+		{"last ISO week ends in the next year", LeaderboardWeekly, date(2027, 1, 1), "2026-W53", date(2026, 12, 28), date(2027, 1, 4)},
+		{"December day in the next ISO year", LeaderboardWeekly, date(2024, 12, 30), "2025-W01", date(2024, 12, 30), date(2025, 1, 6)},
+
+		{"monthly", LeaderboardMonthly, date(2026, 10, 16), "2026-10", date(2026, 10, 1), date(2026, 11, 1)},
+		{"monthly leap day", LeaderboardMonthly, date(2024, 2, 29), "2024-02", date(2024, 2, 1), date(2024, 3, 1)},
+		{"monthly December", LeaderboardMonthly, date(2026, 12, 31), "2026-12", date(2026, 12, 1), date(2027, 1, 1)},
+
+		{"all time has no window", LeaderboardAllTime, date(2026, 10, 16), "", time.Time{}, time.Time{}},
+		{"unknown period", "yearly", date(2026, 10, 16), "", time.Time{}, time.Time{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := leaderboardPeriodKey(tt.period, tt.at); got != tt.wantKey {
+				t.Errorf("key = %q, want %q", got, tt.wantKey)
+			}
+			start, end := leaderboardPeriodBounds(tt.period, tt.at)
+			if !start.Equal(tt.wantStart) || !end.Equal(tt.wantEnd) {
+				t.Errorf("bounds = [%v, %v), want [%v, %v)", start, end, tt.wantStart, tt.wantEnd)
+			}
+			if !tt.wantStart.IsZero() && (tt.at.Before(start) || !tt.at.Before(end)) {
+				t.Errorf("%v is outside its own period [%v, %v)", tt.at, start, end)
+			}
+		})
+	}
+}
+
+func TestRankLeaderboard(t *testing.T) {
+	// Fixed IDs so ties break predictably (higher ID first)
+	id := func(n byte) uuid.UUID { return uuid.UUID{15: n} }
+	team := id(100)
+
+	members := map[uuid.UUID]*leaderboardMember{
+		id(1): {userID: id(1), country: "SA", eligible: true},
+		id(2): {userID: id(2), country: "SA", teamID: &team, eligible: true},
+		id(3): {userID: id(3), country: "KW", eligible: true},
+		id(4): {userID: id(4), eligible: true},
+		id(5): {userID: id(5), country: "SA", teamID: &team, eligible: true},
+		id(6): {userID: id(6), country: "KW", eligible: true},
+	}
+	scores := map[uuid.UUID]int64{
+		id(1): 40,
+		id(2): 100,
+		id(3): 40,
+		id(4): 10,
+		id(5): 0,   // No points, not ranked
+		id(6): -20, // Only voided conversions, not ranked
+		id(7): 500, // Not an eligible promoter
+	}
+
+	ids := func(entries []LeaderboardEntry) []uuid.UUID {
+		out := make([]uuid.UUID, len(entries))
+		for i, entry := range entries {
+			out[i] = entry.UserID
+		}
+		return out
+	}
+	equal := func(a, b []uuid.UUID) bool {
+		if len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if a[i] != b[i] {
+				return false
+			}
+		}
+		return true
+	}
+
+	tests := []struct {
+		name          string
+		segment       LeaderboardSegment
+		userID        uuid.UUID
+		limit, around int
+		wantEntries   []uuid.UUID
+		wantTotal     int64
+		wantMeRank    int64
+		wantNeighbour []uuid.UUID
+	}{
+		{
+			name:          "global",
+			userID:        id(1),
+			limit:         10,
+			around:        1,
+			wantEntries:   []uuid.UUID{id(2), id(3), id(1), id(4)},
+			wantTotal:     4,
+			wantMeRank:    3,
+			wantNeighbour: []uuid.UUID{id(3), id(1), id(4)},
+		},
+		{
+			name:          "limit",
+			userID:        id(2),
+			limit:         2,
+			around:        2,
+			wantEntries:   []uuid.UUID{id(2), id(3)},
+			wantTotal:     4,
+			wantMeRank:    1,
+			wantNeighbour: []uuid.UUID{id(2), id(3), id(1)},
+		},
+		{
+			name:          "country",
+			segment:       LeaderboardSegment{Country: "sa"},
+			userID:        id(1),
+			limit:         10,
+			around:        5,
+			wantEntries:   []uuid.UUID{id(2), id(1)},
+			wantTotal:     2,
+			wantMeRank:    2,
+			wantNeighbour: []uuid.UUID{id(2), id(1)},
+		},
+		{
+			name:        "team, caller without points",
+			segment:     LeaderboardSegment{TeamID: &team},
+			userID:      id(5),
+			limit:       10,
+			around:      1,
+			wantEntries: []uuid.UUID{id(2)},
+			wantTotal:   1,
+		},
+		{
+			name:        "caller outside the segment",
+			segment:     LeaderboardSegment{Country: "KW"},
+			userID:      id(1),
+			limit:       10,
+			around:      1,
+			wantEntries: []uuid.UUID{id(3)},
+			wantTotal:   1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			board := &Leaderboard{Period: LeaderboardWeekly}
+			rankLeaderboard(board, scores, members, tt.segment, tt.userID, tt.limit, tt.around)
+
+			if got := ids(board.Entries); !equal(got, tt.wantEntries) {
+				t.Errorf("entries = %v, want %v", got, tt.wantEntries)
+			}
+			for i, entry := range board.Entries {
+				if entry.Rank != int64(i)+1 {
+					t.Errorf("entry %d has rank %d", i, entry.Rank)
+				}
+			}
+			if board.Total != tt.wantTotal {
+				t.Errorf("total = %d, want %d", board.Total, tt.wantTotal)
+			}
+			if board.Me == nil || board.Me.UserID != tt.userID || board.Me.Rank != tt.wantMeRank {
+				t.Errorf("me = %+v, want %s ranked %d", board.Me, tt.userID, tt.wantMeRank)
+			}
+			if got := ids(board.Neighbours); !equal(got, tt.wantNeighbour) {
+				t.Errorf("neighbours = %v, want %v", got, tt.wantNeighbour)
+			}
+		})
+	}
+}
@@ -158,6 +158,52 @@ func (s *LinkSigningService) createSignature(trackingCode string, timestamp int6
 	return hex.EncodeToString(h.Sum(nil))[:16]
 }
 
+// ============================================
+// DEEP LINK TARGETS
+// ============================================
+
+// SignDeepLinkTarget creates the token that embeds a deep link target in a
+// tracking link. Format: {base64url(target)}.{signature}, bound to the
+// tracking code so it can't be moved to another promoter's link.
+func (s *LinkSigningService) SignDeepLinkTarget(trackingCode, target string) string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	encoded := base64.RawURLEncoding.EncodeToString([]byte(target))
+	return encoded + "." + s.createDeepLinkSignature(trackingCode, encoded)
+}
+
+// ValidateDeepLinkTarget returns the target embedded in a deep link token,
+// or false if the token wasn't signed for this tracking code
+func (s *LinkSigningService) ValidateDeepLinkTarget(trackingCode, token string) (string, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	i := strings.LastIndex(token, ".")
+	if i <= 0 {
+		return "", false
+	}
+	encoded, signature := token[:i], token[i+1:]
+
+	expected := s.createDeepLinkSignature(trackingCode, encoded)
+	if !hmac.Equal([]byte(signature), []byte(expected)) {
+		return "", false
+	}
+
+	target, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", false
+	}
+	return string(target), true
+}
+
+// createDeepLinkSignature signs a deep link target for a tracking code
+func (s *LinkSigningService) createDeepLinkSignature(trackingCode, encodedTarget string) string {
+	h := hmac.New(sha256.New, s.signingSecret)
+	h.Write([]byte("deeplink." + trackingCode + "." + encodedTarget))
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
 // ============================================
 // LINK VALIDATION
 // ============================================
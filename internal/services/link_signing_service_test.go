@@ -0,0 +1,54 @@
+package services
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestSignDeepLinkTarget(t *testing.T) {
+	s := &LinkSigningService{signingSecret: []byte("test-signing-secret")}
+	const trackingCode = "abc123"
+	target := "https://example.com/p/1?c=red&size=m#reviews"
+
+	token := s.SignDeepLinkTarget(trackingCode, target)
+	if strings.ContainsAny(token, "+/=?&#") {
+		t.Errorf("token %q isn't URL safe", token)
+	}
+
+	got, ok := s.ValidateDeepLinkTarget(trackingCode, token)
+	if !ok || got != target {
+		t.Fatalf("ValidateDeepLinkTarget = %q, %v; want %q, true", got, ok, target)
+	}
+
+	encoded, signature, _ := strings.Cut(token, ".")
+	otherTarget := base64.RawURLEncoding.EncodeToString([]byte("https://evil.com/"))
+
+	tests := []struct {
+		name         string
+		trackingCode string
+		token        string
+	}{
+		{"other tracking code", "abc124", token},
+		{"other target", trackingCode, otherTarget + "." + signature},
+		{"signature flipped", trackingCode, encoded + "." + strings.Repeat("0", len(signature))},
+		{"signature truncated", trackingCode, encoded + "." + signature[:len(signature)-1]},
+		{"no signature", trackingCode, encoded},
+		{"empty", trackingCode, ""},
+		{"only a dot", trackingCode, "."},
+		{"other secret", trackingCode, (&LinkSigningService{signingSecret: []byte("other")}).SignDeepLinkTarget(trackingCode, target)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, ok := s.ValidateDeepLinkTarget(tt.trackingCode, tt.token); ok {
+				t.Errorf("accepted a forged token, target %q", got)
+			}
+		})
+	}
+
+	// Deep link signatures can't be confused with tracking link signatures
+	if s.createDeepLinkSignature(trackingCode, encoded) == s.createSignature(trackingCode, 0, encoded) {
+		t.Error("deep link and tracking link signatures collide")
+	}
+}
@@ -0,0 +1,383 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ============================================
+// OFFER ACCESS SERVICE
+// ============================================
+
+const maxApplicationTextLength = 2000
+
+// Offer access errors
+var (
+	ErrOfferApplicationRequired = errors.New("this offer requires an approved application")
+	ErrOfferInviteOnly          = errors.New("this offer is invite only")
+	ErrOfferAccessRevoked       = errors.New("your access to this offer was revoked")
+	ErrOfferApplicationNotFound = errors.New("application not found")
+	ErrOfferApplicationExists   = errors.New("you already applied to this offer")
+	ErrOfferApplicationState    = errors.New("application can't be changed in its current status")
+	ErrOfferNotApplicable       = errors.New("this offer doesn't take applications")
+)
+
+// OfferAccessService decides who may join an offer and manages promoter
+// applications, invites, approvals, revocations and payout overrides
+type OfferAccessService struct {
+	db *gorm.DB
+}
+
+// NewOfferAccessService creates a new offer access service
+func NewOfferAccessService(db *gorm.DB) *OfferAccessService {
+	return &OfferAccessService{db: db}
+}
+
+// ============================================
+// PROMOTER SIDE
+// ============================================
+
+// CheckJoinAccess returns whether a promoter may join an offer, and their
+// application if they have one
+func (s *OfferAccessService) CheckJoinAccess(offer *models.Offer, userID uuid.UUID) (*models.OfferApplication, error) {
+	application, err := s.findApplication(offer.ID, userID)
+	if err != nil && !errors.Is(err, ErrOfferApplicationNotFound) {
+		return nil, err
+	}
+
+	if application != nil && application.Status == models.OfferApplicationRevoked {
+		return application, ErrOfferAccessRevoked
+	}
+
+	switch offer.Visibility {
+	case models.OfferVisibilityApproval:
+		if application == nil || !application.AllowsJoin() {
+			return application, ErrOfferApplicationRequired
+		}
+	case models.OfferVisibilityInviteOnly:
+		if application == nil || !application.AllowsJoin() {
+			return application, ErrOfferInviteOnly
+		}
+	}
+	return application, nil
+}
+
+// CanView reports whether an offer may be shown to a caller (nil when
+// signed out). Invite-only offers are visible to their advertiser and to
+// promoters whose invite or application allows them to join.
+func (s *OfferAccessService) CanView(offer *models.Offer, viewerID *uuid.UUID) bool {
+	if offer.Visibility != models.OfferVisibilityInviteOnly {
+		return true
+	}
+	if viewerID == nil {
+		return false
+	}
+	if offer.AdvertiserID != nil && *offer.AdvertiserID == *viewerID {
+		return true
+	}
+	application, err := s.findApplication(offer.ID, *viewerID)
+	return err == nil && application.AllowsJoin()
+}
+
+// CompleteJoin marks an invite as accepted once the promoter has joined
+func (s *OfferAccessService) CompleteJoin(tx *gorm.DB, application *models.OfferApplication) error {
+	if application == nil || application.Status != models.OfferApplicationInvited {
+		return nil
+	}
+	application.Status = models.OfferApplicationApproved
+	return tx.Model(application).Updates(map[string]interface{}{
+		"status":     application.Status,
+		"updated_at": time.Now().UTC(),
+	}).Error
+}
+
+// Apply submits a promoter's application to an approval-required offer. A
+// rejected promoter may apply again.
+func (s *OfferAccessService) Apply(offer *models.Offer, userID uuid.UUID, trafficSource, message string) (*models.OfferApplication, error) {
+	if offer.Visibility != models.OfferVisibilityApproval {
+		if offer.Visibility == models.OfferVisibilityInviteOnly {
+			return nil, ErrOfferInviteOnly
+		}
+		return nil, ErrOfferNotApplicable
+	}
+
+	trafficSource = strings.TrimSpace(trafficSource)
+	if trafficSource == "" {
+		return nil, fmt.Errorf("traffic_source is required")
+	}
+
+	application, err := s.findApplication(offer.ID, userID)
+	switch {
+	case errors.Is(err, ErrOfferApplicationNotFound):
+		application = &models.OfferApplication{
+			ID:      uuid.New(),
+			OfferID: offer.ID,
+			UserID:  userID,
+		}
+	case err != nil:
+		return nil, err
+	case application.Status == models.OfferApplicationRevoked:
+		return nil, ErrOfferAccessRevoked
+	case application.Status != models.OfferApplicationRejected:
+		return nil, ErrOfferApplicationExists
+	}
+
+	application.Status = models.OfferApplicationPending
+	application.TrafficSource = truncate(trafficSource, maxApplicationTextLength)
+	application.Message = truncate(strings.TrimSpace(message), maxApplicationTextLength)
+	application.ReviewNote = ""
+	application.ReviewedBy = nil
+	application.ReviewedAt = nil
+	application.UpdatedAt = time.Now().UTC()
+
+	if err := s.db.Save(application).Error; err != nil {
+		return nil, fmt.Errorf("failed to save application: %w", err)
+	}
+	return application, nil
+}
+
+// ListForPromoter returns a promoter's applications and invites
+func (s *OfferAccessService) ListForPromoter(userID uuid.UUID) ([]models.OfferApplication, error) {
+	var applications []models.OfferApplication
+	err := s.db.Preload("Offer").
+		Where("user_id = ?", userID).
+		Order("updated_at DESC").
+		Find(&applications).Error
+	return applications, err
+}
+
+// ============================================
+// ADVERTISER SIDE
+// ============================================
+
+// ListForOffer returns an offer's applications, optionally by status
+func (s *OfferAccessService) ListForOffer(offerID uuid.UUID, status string) ([]models.OfferApplication, error) {
+	query := s.db.Preload("User", func(db *gorm.DB) *gorm.DB {
+		return db.Select("id", "username", "full_name", "avatar_url")
+	}).Where("offer_id = ?", offerID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var applications []models.OfferApplication
+	err := query.Order("created_at DESC").Find(&applications).Error
+	return applications, err
+}
+
+// GetForAdvertiser loads an application on one of the advertiser's offers
+func (s *OfferAccessService) GetForAdvertiser(applicationID, advertiserID uuid.UUID) (*models.OfferApplication, error) {
+	var application models.OfferApplication
+	err := s.db.Joins("JOIN offers ON offers.id = offer_applications.offer_id").
+		Where("offer_applications.id = ? AND offers.advertiser_id = ?", applicationID, advertiserID).
+		First(&application).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrOfferApplicationNotFound
+		}
+		return nil, err
+	}
+	return &application, nil
+}
+
+// Approve lets a pending (or previously rejected) applicant join, with an
+// optional payout override
+func (s *OfferAccessService) Approve(application *models.OfferApplication, reviewerID uuid.UUID, payoutOverride *int, note string) error {
+	if application.Status != models.OfferApplicationPending && application.Status != models.OfferApplicationRejected {
+		return ErrOfferApplicationState
+	}
+	if err := validatePayoutOverride(payoutOverride); err != nil {
+		return err
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now().UTC()
+		application.Status = models.OfferApplicationApproved
+		application.ReviewNote = strings.TrimSpace(note)
+		application.ReviewedBy = &reviewerID
+		application.ReviewedAt = &now
+		application.PayoutOverride = payoutOverride
+		application.UpdatedAt = now
+		if err := tx.Save(application).Error; err != nil {
+			return fmt.Errorf("failed to approve application: %w", err)
+		}
+		return applyPayoutOverride(tx, application)
+	})
+}
+
+// Reject declines a pending application
+func (s *OfferAccessService) Reject(application *models.OfferApplication, reviewerID uuid.UUID, reason string) error {
+	if application.Status != models.OfferApplicationPending {
+		return ErrOfferApplicationState
+	}
+
+	now := time.Now().UTC()
+	application.Status = models.OfferApplicationRejected
+	application.ReviewNote = strings.TrimSpace(reason)
+	application.ReviewedBy = &reviewerID
+	application.ReviewedAt = &now
+	application.UpdatedAt = now
+	return s.db.Save(application).Error
+}
+
+// Invite lets a promoter join an offer, whatever its visibility. It also
+// restores a revoked promoter, who then has to join again.
+func (s *OfferAccessService) Invite(offer *models.Offer, promoterID, advertiserID uuid.UUID, payoutOverride *int) (*models.OfferApplication, error) {
+	if err := validatePayoutOverride(payoutOverride); err != nil {
+		return nil, err
+	}
+
+	application, err := s.findApplication(offer.ID, promoterID)
+	switch {
+	case errors.Is(err, ErrOfferApplicationNotFound):
+		application = &models.OfferApplication{
+			ID:      uuid.New(),
+			OfferID: offer.ID,
+			UserID:  promoterID,
+		}
+	case err != nil:
+		return nil, err
+	case application.Status == models.OfferApplicationApproved:
+		return nil, ErrOfferApplicationState
+	}
+
+	now := time.Now().UTC()
+	application.Status = models.OfferApplicationInvited
+	application.InvitedBy = &advertiserID
+	application.ReviewedBy = &advertiserID
+	application.ReviewedAt = &now
+	application.ReviewNote = ""
+	application.PayoutOverride = payoutOverride
+	application.UpdatedAt = now
+
+	if err := s.db.Save(application).Error; err != nil {
+		return nil, fmt.Errorf("failed to save invite: %w", err)
+	}
+	return application, nil
+}
+
+// Revoke removes a promoter from an offer: their access is recorded as
+// revoked and their user offer is deactivated, so its links stop tracking
+func (s *OfferAccessService) Revoke(offer *models.Offer, promoterID, advertiserID uuid.UUID, reason string) (*models.OfferApplication, error) {
+	var application *models.OfferApplication
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var existing models.OfferApplication
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("offer_id = ? AND user_id = ?", offer.ID, promoterID).
+			First(&existing).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			existing = models.OfferApplication{
+				ID:      uuid.New(),
+				OfferID: offer.ID,
+				UserID:  promoterID,
+			}
+		case err != nil:
+			return err
+		}
+
+		now := time.Now().UTC()
+		existing.Status = models.OfferApplicationRevoked
+		existing.ReviewNote = strings.TrimSpace(reason)
+		existing.ReviewedBy = &advertiserID
+		existing.ReviewedAt = &now
+		existing.UpdatedAt = now
+		if err := tx.Save(&existing).Error; err != nil {
+			return fmt.Errorf("failed to save revocation: %w", err)
+		}
+
+		result := tx.Model(&models.UserOffer{}).
+			Where("offer_id = ? AND user_id = ? AND status = ?", offer.ID, promoterID, models.UserOfferStatusActive).
+			Updates(map[string]interface{}{
+				"status":     models.UserOfferStatusRevoked,
+				"updated_at": now,
+			})
+		if result.Error != nil {
+			return fmt.Errorf("failed to deactivate user offer: %w", result.Error)
+		}
+		if result.RowsAffected > 0 {
+			if err := tx.Model(&models.Offer{}).
+				Where("id = ? AND users_count > 0", offer.ID).
+				UpdateColumn("users_count", gorm.Expr("users_count - 1")).Error; err != nil {
+				return fmt.Errorf("failed to update offer: %w", err)
+			}
+		}
+
+		application = &existing
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Printf("[OfferAccess] Promoter %s revoked from offer %s by %s\n", promoterID, offer.ID, advertiserID)
+	return application, nil
+}
+
+// SetPayoutOverride changes an approved promoter's payout override (nil
+// restores the offer's commission)
+func (s *OfferAccessService) SetPayoutOverride(application *models.OfferApplication, payoutOverride *int) error {
+	if !application.AllowsJoin() {
+		return ErrOfferApplicationState
+	}
+	if err := validatePayoutOverride(payoutOverride); err != nil {
+		return err
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		application.PayoutOverride = payoutOverride
+		application.UpdatedAt = time.Now().UTC()
+		if err := tx.Model(application).Updates(map[string]interface{}{
+			"payout_override": payoutOverride,
+			"updated_at":      application.UpdatedAt,
+		}).Error; err != nil {
+			return err
+		}
+		return applyPayoutOverride(tx, application)
+	})
+}
+
+// ============================================
+// HELPERS
+// ============================================
+
+// PayoutOverrideFor returns the payout override a joining promoter gets
+func PayoutOverrideFor(application *models.OfferApplication) *int {
+	if application == nil || !application.AllowsJoin() {
+		return nil
+	}
+	return application.PayoutOverride
+}
+
+func (s *OfferAccessService) findApplication(offerID, userID uuid.UUID) (*models.OfferApplication, error) {
+	var application models.OfferApplication
+	if err := s.db.Where("offer_id = ? AND user_id = ?", offerID, userID).First(&application).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrOfferApplicationNotFound
+		}
+		return nil, err
+	}
+	return &application, nil
+}
+
+// applyPayoutOverride copies an application's payout override onto the
+// promoter's user offer, where conversions pick it up
+func applyPayoutOverride(tx *gorm.DB, application *models.OfferApplication) error {
+	return tx.Model(&models.UserOffer{}).
+		Where("offer_id = ? AND user_id = ?", application.OfferID, application.UserID).
+		Update("payout_override", application.PayoutOverride).Error
+}
+
+func validatePayoutOverride(payoutOverride *int) error {
+	if payoutOverride != nil && *payoutOverride < 0 {
+		return fmt.Errorf("payout_override can't be negative")
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/google/uuid"
+)
+
+func TestOfferAccessCanView(t *testing.T) {
+	s := NewOfferAccessService(nil)
+	advertiserID, promoterID := uuid.New(), uuid.New()
+
+	tests := []struct {
+		name       string
+		visibility string
+		viewerID   *uuid.UUID
+		want       bool
+	}{
+		{"public, signed out", models.OfferVisibilityPublic, nil, true},
+		{"legacy empty visibility", "", nil, true},
+		{"approval, signed out", models.OfferVisibilityApproval, nil, true},
+		{"approval, promoter", models.OfferVisibilityApproval, &promoterID, true},
+		{"invite only, signed out", models.OfferVisibilityInviteOnly, nil, false},
+		{"invite only, advertiser", models.OfferVisibilityInviteOnly, &advertiserID, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			offer := &models.Offer{ID: uuid.New(), AdvertiserID: &advertiserID, Visibility: tt.visibility}
+			if got := s.CanView(offer, tt.viewerID); got != tt.want {
+				t.Errorf("CanView = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOfferAccessApplyRejectsNonApprovalOffers(t *testing.T) {
+	s := NewOfferAccessService(nil)
+
+	tests := []struct {
+		visibility string
+		want       error
+	}{
+		{models.OfferVisibilityPublic, ErrOfferNotApplicable},
+		{"", ErrOfferNotApplicable},
+		{models.OfferVisibilityInviteOnly, ErrOfferInviteOnly},
+	}
+
+	for _, tt := range tests {
+		offer := &models.Offer{ID: uuid.New(), Visibility: tt.visibility}
+		if _, err := s.Apply(offer, uuid.New(), "instagram", ""); !errors.Is(err, tt.want) {
+			t.Errorf("Apply(%q) error = %v, want %v", tt.visibility, err, tt.want)
+		}
+	}
+}
+
+func TestPayoutOverrideFor(t *testing.T) {
+	override := 300
+
+	tests := []struct {
+		name        string
+		application *models.OfferApplication
+		want        *int
+	}{
+		{"no application", nil, nil},
+		{"approved", &models.OfferApplication{Status: models.OfferApplicationApproved, PayoutOverride: &override}, &override},
+		{"invited", &models.OfferApplication{Status: models.OfferApplicationInvited, PayoutOverride: &override}, &override},
+		{"approved without override", &models.OfferApplication{Status: models.OfferApplicationApproved}, nil},
+		{"pending", &models.OfferApplication{Status: models.OfferApplicationPending, PayoutOverride: &override}, nil},
+		{"revoked", &models.OfferApplication{Status: models.OfferApplicationRevoked, PayoutOverride: &override}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PayoutOverrideFor(tt.application); got != tt.want {
+				t.Errorf("PayoutOverrideFor = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidatePayoutOverride(t *testing.T) {
+	negative, zero, positive := -1, 0, 500
+
+	tests := []struct {
+		override *int
+		wantErr  bool
+	}{
+		{nil, false},
+		{&zero, false},
+		{&positive, false},
+		{&negative, true},
+	}
+	for _, tt := range tests {
+		if err := validatePayoutOverride(tt.override); (err != nil) != tt.wantErr {
+			t.Errorf("validatePayoutOverride(%v) error = %v, wantErr %v", tt.override, err, tt.wantErr)
+		}
+	}
+}
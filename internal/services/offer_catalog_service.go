@@ -0,0 +1,375 @@
+package services
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ============================================
+// OFFER CATALOG SERVICE
+// ============================================
+
+// Catalog sort options
+const (
+	CatalogSortNewest         = "newest"
+	CatalogSortPayout         = "payout"
+	CatalogSortEPC            = "epc"
+	CatalogSortConversionRate = "conversion_rate"
+	CatalogSortRelevance      = "relevance" // Only with a search query
+)
+
+const (
+	DefaultCatalogLimit   = 20
+	MaxCatalogLimit       = 100
+	maxCatalogSearchTerms = 8
+)
+
+// ErrInvalidCatalogQuery is returned for bad filters, sorts and cursors
+var ErrInvalidCatalogQuery = errors.New("invalid catalog query")
+
+// catalogStatuses are the offer statuses the catalog can be filtered by
+var catalogStatuses = map[string]bool{
+	"active":   true,
+	"paused":   true,
+	"pending":  true,
+	"rejected": true,
+}
+
+// catalogScoreExpressions are the computed sort keys. EPC is the payout earned
+// per click so far.
+var catalogScoreExpressions = map[string]string{
+	CatalogSortEPC:            "COALESCE(offers.payout::float8 * offers.total_conversions / NULLIF(offers.total_clicks, 0), 0)",
+	CatalogSortConversionRate: "COALESCE(offers.total_conversions::float8 / NULLIF(offers.total_clicks, 0), 0)",
+}
+
+// catalogGeoMatch evaluates geo rule gr for a country, like GeoRuleService.matchCountry
+const catalogGeoMatch = "CASE gr.mode WHEN 'allow' THEN gr.countries @> jsonb_build_array(?::text) " +
+	"WHEN 'block' THEN NOT (gr.countries @> jsonb_build_array(?::text)) ELSE TRUE END"
+
+// OfferCatalogQuery holds the catalog filters, sort and page
+type OfferCatalogQuery struct {
+	Search      string
+	Categories  []string
+	PayoutTypes []string
+	MinPayout   *int
+	MaxPayout   *int
+	Country     string // Only offers whose geo rules allow this country
+	Status      string // Default active
+	Sort        string // newest (default), payout, epc, conversion_rate, relevance
+	Order       string // desc (default), asc
+	Cursor      string
+	Limit       int
+	ViewerID    *uuid.UUID // Signed-in caller; invite-only offers are hidden from everyone else
+}
+
+// OfferCatalogPage is one page of the catalog
+type OfferCatalogPage struct {
+	Offers     []models.Offer `json:"offers"`
+	Total      int64          `json:"total"`
+	Limit      int            `json:"limit"`
+	Sort       string         `json:"sort"`
+	Order      string         `json:"order"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+	HasMore    bool           `json:"has_more"`
+}
+
+// catalogCursor is the position after the last offer of a page. It is bound
+// to the sort it was issued for.
+type catalogCursor struct {
+	Sort      string    `json:"s"`
+	Order     string    `json:"o"`
+	Score     float64   `json:"v,omitempty"`
+	CreatedAt time.Time `json:"t,omitempty"`
+	ID        uuid.UUID `json:"id"`
+}
+
+// catalogRow is an offer with its computed sort key
+type catalogRow struct {
+	models.Offer
+	SortScore float64 `gorm:"column:sort_score;->"`
+}
+
+// OfferCatalogService searches and pages through offers
+type OfferCatalogService struct {
+	db *gorm.DB
+}
+
+// NewOfferCatalogService creates a new offer catalog service
+func NewOfferCatalogService(db *gorm.DB) *OfferCatalogService {
+	return &OfferCatalogService{db: db}
+}
+
+// Search returns one page of offers matching the query
+func (s *OfferCatalogService) Search(query *OfferCatalogQuery) (*OfferCatalogPage, error) {
+	if err := s.normalize(query); err != nil {
+		return nil, err
+	}
+
+	base := s.filtered(query)
+
+	var total int64
+	if err := base.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, fmt.Errorf("failed to count offers: %w", err)
+	}
+
+	key, keyVars := s.sortKey(query)
+	direction, comparison := "DESC", "<"
+	if query.Order == "asc" {
+		direction, comparison = "ASC", ">"
+	}
+
+	page := base.Session(&gorm.Session{})
+	if query.Cursor != "" {
+		cursor, err := decodeCatalogCursor(query.Cursor)
+		if err != nil || cursor.Sort != query.Sort || cursor.Order != query.Order {
+			return nil, fmt.Errorf("%w: cursor doesn't match this sort", ErrInvalidCatalogQuery)
+		}
+		var position interface{} = cursor.Score
+		if query.Sort == CatalogSortNewest {
+			position = cursor.CreatedAt
+		}
+		vars := append(append([]interface{}{}, keyVars...), position, cursor.ID)
+		page = page.Where(clause.Expr{
+			SQL:  fmt.Sprintf("(%s, offers.id) %s (?, ?)", key, comparison),
+			Vars: vars,
+		})
+	}
+
+	var rows []catalogRow
+	if err := page.
+		Select("offers.*, ("+s.scoreSelect(query.Sort, key)+")::float8 AS sort_score", s.scoreSelectVars(query.Sort, keyVars)...).
+		Order(clause.OrderBy{Expression: clause.Expr{
+			SQL:  fmt.Sprintf("%s %s, offers.id %s", key, direction, direction),
+			Vars: keyVars,
+		}}).
+		Limit(query.Limit + 1).
+		Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch offers: %w", err)
+	}
+
+	result := &OfferCatalogPage{
+		Offers: make([]models.Offer, 0, len(rows)),
+		Total:  total,
+		Limit:  query.Limit,
+		Sort:   query.Sort,
+		Order:  query.Order,
+	}
+	if len(rows) > query.Limit {
+		rows = rows[:query.Limit]
+		result.HasMore = true
+	}
+	for _, row := range rows {
+		result.Offers = append(result.Offers, row.Offer)
+	}
+	if result.HasMore {
+		last := rows[len(rows)-1]
+		result.NextCursor = encodeCatalogCursor(&catalogCursor{
+			Sort:      query.Sort,
+			Order:     query.Order,
+			Score:     last.SortScore,
+			CreatedAt: last.CreatedAt,
+			ID:        last.ID,
+		})
+	}
+
+	return result, nil
+}
+
+// normalize applies defaults and validates the query
+func (s *OfferCatalogService) normalize(query *OfferCatalogQuery) error {
+	query.Search = strings.TrimSpace(query.Search)
+	query.Country = strings.ToUpper(strings.TrimSpace(query.Country))
+
+	if query.Status == "" {
+		query.Status = "active"
+	}
+	if !catalogStatuses[query.Status] {
+		return fmt.Errorf("%w: unknown status %q", ErrInvalidCatalogQuery, query.Status)
+	}
+
+	if query.Sort == "" {
+		query.Sort = CatalogSortNewest
+		if len(searchTerms(query.Search)) > 0 {
+			query.Sort = CatalogSortRelevance
+		}
+	}
+	switch query.Sort {
+	case CatalogSortNewest, CatalogSortPayout, CatalogSortEPC, CatalogSortConversionRate:
+	case CatalogSortRelevance:
+		if len(searchTerms(query.Search)) == 0 {
+			return fmt.Errorf("%w: sort=relevance needs a search query", ErrInvalidCatalogQuery)
+		}
+	default:
+		return fmt.Errorf("%w: sort must be one of newest, payout, epc, conversion_rate, relevance", ErrInvalidCatalogQuery)
+	}
+
+	query.Order = strings.ToLower(query.Order)
+	if query.Order == "" {
+		query.Order = "desc"
+	}
+	if query.Order != "asc" && query.Order != "desc" {
+		return fmt.Errorf("%w: order must be asc or desc", ErrInvalidCatalogQuery)
+	}
+
+	if query.Country != "" && len(query.Country) != 2 {
+		return fmt.Errorf("%w: country must be an ISO 3166-1 alpha-2 code", ErrInvalidCatalogQuery)
+	}
+	if query.MinPayout != nil && query.MaxPayout != nil && *query.MinPayout > *query.MaxPayout {
+		return fmt.Errorf("%w: min_payout is greater than max_payout", ErrInvalidCatalogQuery)
+	}
+
+	if query.Limit <= 0 {
+		query.Limit = DefaultCatalogLimit
+	}
+	if query.Limit > MaxCatalogLimit {
+		query.Limit = MaxCatalogLimit
+	}
+	return nil
+}
+
+// filtered builds the query for all offers matching the filters
+func (s *OfferCatalogService) filtered(query *OfferCatalogQuery) *gorm.DB {
+	db := s.db.Model(&models.Offer{}).Where("offers.status = ?", query.Status)
+
+	// Invite-only offers are listed only to their advertiser and to
+	// promoters who were invited (same rule as OfferAccessService.CanView)
+	if query.ViewerID == nil {
+		db = db.Where("COALESCE(offers.visibility, ?) <> ?", models.OfferVisibilityPublic, models.OfferVisibilityInviteOnly)
+	} else {
+		db = db.Where("(COALESCE(offers.visibility, ?) <> ? OR offers.advertiser_id = ? OR EXISTS ("+
+			"SELECT 1 FROM offer_applications oa WHERE oa.offer_id = offers.id AND oa.user_id = ? AND oa.status IN ?))",
+			models.OfferVisibilityPublic, models.OfferVisibilityInviteOnly, *query.ViewerID, *query.ViewerID,
+			[]models.OfferApplicationStatus{models.OfferApplicationInvited, models.OfferApplicationApproved})
+	}
+
+	if terms := searchTerms(query.Search); len(terms) > 0 {
+		db = db.Where(models.OfferSearchDocument+" @@ to_tsquery('simple', ?)", tsQuery(terms))
+	}
+	if categories := trimmedValues(query.Categories); len(categories) > 0 {
+		db = db.Where("offers.category IN ?", categories)
+	}
+	if payoutTypes := trimmedValues(query.PayoutTypes); len(payoutTypes) > 0 {
+		db = db.Where("offers.payout_type IN ?", payoutTypes)
+	}
+	if query.MinPayout != nil {
+		db = db.Where("offers.payout >= ?", *query.MinPayout)
+	}
+	if query.MaxPayout != nil {
+		db = db.Where("offers.payout <= ?", *query.MaxPayout)
+	}
+
+	if query.Country != "" {
+		// Same precedence as GeoRuleService.GetEffectiveGeoRule:
+		// offer rule, then advertiser rule, then global rule, then allow
+		rule := func(scope string) string {
+			return "(SELECT " + catalogGeoMatch + " FROM geo_rules gr WHERE " + scope +
+				" AND gr.status = 'active' ORDER BY gr.priority ASC LIMIT 1)"
+		}
+		condition := "COALESCE(" +
+			rule("gr.scope_type = 'offer' AND gr.scope_id = offers.id") + ", " +
+			rule("gr.scope_type = 'advertiser' AND gr.scope_id = offers.advertiser_id") + ", " +
+			rule("gr.scope_type = 'global'") + ", TRUE)"
+		args := make([]interface{}, 6)
+		for i := range args {
+			args[i] = query.Country
+		}
+		db = db.Where(condition, args...)
+	}
+
+	return db
+}
+
+// sortKey returns the SQL sort key for a query and its bind variables
+func (s *OfferCatalogService) sortKey(query *OfferCatalogQuery) (string, []interface{}) {
+	switch query.Sort {
+	case CatalogSortPayout:
+		return "offers.payout", nil
+	case CatalogSortEPC, CatalogSortConversionRate:
+		return catalogScoreExpressions[query.Sort], nil
+	case CatalogSortRelevance:
+		return "ts_rank(" + models.OfferSearchDocument + ", to_tsquery('simple', ?))::float8",
+			[]interface{}{tsQuery(searchTerms(query.Search))}
+	}
+	return "offers.created_at", nil
+}
+
+// scoreSelect is the sort key as a number for the cursor; newest pages use
+// created_at itself
+func (s *OfferCatalogService) scoreSelect(sort, key string) string {
+	if sort == CatalogSortNewest {
+		return "0"
+	}
+	return key
+}
+
+func (s *OfferCatalogService) scoreSelectVars(sort string, keyVars []interface{}) []interface{} {
+	if sort == CatalogSortNewest {
+		return nil
+	}
+	return keyVars
+}
+
+// ============================================
+// HELPERS
+// ============================================
+
+// searchTerms splits a search into words (letters and digits in any script)
+func searchTerms(search string) []string {
+	terms := strings.FieldsFunc(search, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(terms) > maxCatalogSearchTerms {
+		terms = terms[:maxCatalogSearchTerms]
+	}
+	return terms
+}
+
+// tsQuery matches all terms, each as a prefix, so results show while typing
+func tsQuery(terms []string) string {
+	parts := make([]string, len(terms))
+	for i, term := range terms {
+		parts[i] = strings.ToLower(term) + ":*"
+	}
+	return strings.Join(parts, " & ")
+}
+
+// trimmedValues trims values and drops empty ones
+func trimmedValues(values []string) []string {
+	var result []string
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+func encodeCatalogCursor(cursor *catalogCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCatalogCursor(raw string) (*catalogCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, err
+	}
+	var cursor catalogCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, err
+	}
+	if cursor.ID == uuid.Nil {
+		return nil, errors.New("cursor has no id")
+	}
+	return &cursor, nil
+}
@@ -0,0 +1,169 @@
+package services
+
+import (
+	"encoding/base64"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestCatalogCursorRoundTrip(t *testing.T) {
+	cursors := []*catalogCursor{
+		{Sort: CatalogSortNewest, Order: "desc", CreatedAt: time.Date(2026, 5, 1, 12, 30, 45, 123456000, time.UTC), ID: uuid.New()},
+		{Sort: CatalogSortEPC, Order: "asc", Score: 0.0375, ID: uuid.New()},
+		{Sort: CatalogSortPayout, Order: "desc", Score: 0, ID: uuid.New()},
+	}
+
+	for _, cursor := range cursors {
+		encoded := encodeCatalogCursor(cursor)
+		if strings.ContainsAny(encoded, "+/=") {
+			t.Errorf("cursor %q is not URL-safe", encoded)
+		}
+
+		decoded, err := decodeCatalogCursor(encoded)
+		if err != nil {
+			t.Fatalf("decodeCatalogCursor(%q): %v", encoded, err)
+		}
+		if decoded.Sort != cursor.Sort || decoded.Order != cursor.Order || decoded.Score != cursor.Score ||
+			!decoded.CreatedAt.Equal(cursor.CreatedAt) || decoded.ID != cursor.ID {
+			t.Errorf("round trip = %+v, want %+v", decoded, cursor)
+		}
+	}
+}
+
+func TestDecodeCatalogCursorRejects(t *testing.T) {
+	encode := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+
+	for name, raw := range map[string]string{
+		"empty":          "",
+		"not base64":     "!!!",
+		"padded base64":  encode(`{"s":"payout","o":"desc","id":"`+uuid.NewString()+`"}`) + "==",
+		"not JSON":       encode("payout:desc"),
+		"no id":          encode(`{"s":"payout","o":"desc","v":1}`),
+		"nil id":         encode(`{"s":"payout","o":"desc","id":"00000000-0000-0000-0000-000000000000"}`),
+		"id not a UUID":  encode(`{"s":"payout","o":"desc","id":"42"}`),
+		"time not valid": encode(`{"s":"newest","o":"desc","t":"yesterday","id":"` + uuid.NewString() + `"}`),
+	} {
+		if _, err := decodeCatalogCursor(raw); err == nil {
+			t.Errorf("%s: cursor %q accepted", name, raw)
+		}
+	}
+}
+
+func TestCatalogNormalize(t *testing.T) {
+	s := &OfferCatalogService{}
+	intPtr := func(v int) *int { return &v }
+
+	tests := []struct {
+		name    string
+		query   OfferCatalogQuery
+		want    OfferCatalogQuery // Fields checked: Search, Country, Status, Sort, Order, Limit
+		wantErr bool
+	}{
+		{name: "defaults", query: OfferCatalogQuery{},
+			want: OfferCatalogQuery{Status: "active", Sort: CatalogSortNewest, Order: "desc", Limit: DefaultCatalogLimit}},
+		{name: "search defaults to relevance", query: OfferCatalogQuery{Search: "  fitness app "},
+			want: OfferCatalogQuery{Search: "fitness app", Status: "active", Sort: CatalogSortRelevance, Order: "desc", Limit: DefaultCatalogLimit}},
+		{name: "punctuation-only search keeps newest", query: OfferCatalogQuery{Search: "&|!"},
+			want: OfferCatalogQuery{Search: "&|!", Status: "active", Sort: CatalogSortNewest, Order: "desc", Limit: DefaultCatalogLimit}},
+		{name: "explicit sort with search", query: OfferCatalogQuery{Search: "shoes", Sort: CatalogSortPayout, Order: "ASC"},
+			want: OfferCatalogQuery{Search: "shoes", Status: "active", Sort: CatalogSortPayout, Order: "asc", Limit: DefaultCatalogLimit}},
+		{name: "country upper-cased", query: OfferCatalogQuery{Country: " sa "},
+			want: OfferCatalogQuery{Country: "SA", Status: "active", Sort: CatalogSortNewest, Order: "desc", Limit: DefaultCatalogLimit}},
+		{name: "limit capped", query: OfferCatalogQuery{Limit: 1000, Status: "paused", Sort: CatalogSortEPC},
+			want: OfferCatalogQuery{Status: "paused", Sort: CatalogSortEPC, Order: "desc", Limit: MaxCatalogLimit}},
+		{name: "negative limit", query: OfferCatalogQuery{Limit: -5, Sort: CatalogSortConversionRate},
+			want: OfferCatalogQuery{Status: "active", Sort: CatalogSortConversionRate, Order: "desc", Limit: DefaultCatalogLimit}},
+		{name: "equal payout bounds", query: OfferCatalogQuery{MinPayout: intPtr(5), MaxPayout: intPtr(5)},
+			want: OfferCatalogQuery{Status: "active", Sort: CatalogSortNewest, Order: "desc", Limit: DefaultCatalogLimit}},
+
+		{name: "unknown status", query: OfferCatalogQuery{Status: "deleted"}, wantErr: true},
+		{name: "unknown sort", query: OfferCatalogQuery{Sort: "random"}, wantErr: true},
+		{name: "sort by raw column", query: OfferCatalogQuery{Sort: "offers.payout; DROP TABLE offers"}, wantErr: true},
+		{name: "relevance without search", query: OfferCatalogQuery{Sort: CatalogSortRelevance}, wantErr: true},
+		{name: "unknown order", query: OfferCatalogQuery{Order: "sideways"}, wantErr: true},
+		{name: "three-letter country", query: OfferCatalogQuery{Country: "SAU"}, wantErr: true},
+		{name: "inverted payout bounds", query: OfferCatalogQuery{MinPayout: intPtr(10), MaxPayout: intPtr(5)}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query := tt.query
+			err := s.normalize(&query)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidCatalogQuery) {
+					t.Fatalf("error = %v, want ErrInvalidCatalogQuery", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if query.Search != tt.want.Search || query.Country != tt.want.Country || query.Status != tt.want.Status ||
+				query.Sort != tt.want.Sort || query.Order != tt.want.Order || query.Limit != tt.want.Limit {
+				t.Errorf("normalized to %+v, want %+v", query, tt.want)
+			}
+		})
+	}
+}
+
+func TestCatalogSearchTerms(t *testing.T) {
+	tests := []struct {
+		search    string
+		wantTerms []string
+		wantQuery string
+	}{
+		{"", nil, ""},
+		{"Fitness App", []string{"Fitness", "App"}, "fitness:* & app:*"},
+		{"x' | !y & (z):*", []string{"x", "y", "z"}, "x:* & y:* & z:*"},
+		{"عروض رمضان", []string{"عروض", "رمضان"}, "عروض:* & رمضان:*"},
+		{"vpn-2025", []string{"vpn", "2025"}, "vpn:* & 2025:*"},
+		{"a b c d e f g h i j", []string{"a", "b", "c", "d", "e", "f", "g", "h"}, "a:* & b:* & c:* & d:* & e:* & f:* & g:* & h:*"},
+	}
+
+	for _, tt := range tests {
+		terms := searchTerms(tt.search)
+		if !reflect.DeepEqual(terms, tt.wantTerms) && !(len(terms) == 0 && len(tt.wantTerms) == 0) {
+			t.Errorf("searchTerms(%q) = %q, want %q", tt.search, terms, tt.wantTerms)
+		}
+		if got := tsQuery(terms); got != tt.wantQuery {
+			t.Errorf("tsQuery(%q) = %q, want %q", tt.search, got, tt.wantQuery)
+		}
+	}
+}
+
+func TestCatalogTrimmedValues(t *testing.T) {
+	got := trimmedValues([]string{" finance ", "", "  ", "gaming"})
+	if !reflect.DeepEqual(got, []string{"finance", "gaming"}) {
+		t.Errorf("trimmedValues = %q", got)
+	}
+	if got := trimmedValues([]string{" "}); got != nil {
+		t.Errorf("trimmedValues of blanks = %q, want nil", got)
+	}
+}
+
+func TestCatalogSortKey(t *testing.T) {
+	s := &OfferCatalogService{}
+
+	for sort, want := range map[string]string{
+		CatalogSortNewest:         "offers.created_at",
+		CatalogSortPayout:         "offers.payout",
+		CatalogSortEPC:            catalogScoreExpressions[CatalogSortEPC],
+		CatalogSortConversionRate: catalogScoreExpressions[CatalogSortConversionRate],
+	} {
+		key, vars := s.sortKey(&OfferCatalogQuery{Sort: sort})
+		if key != want || len(vars) != 0 {
+			t.Errorf("sortKey(%s) = %q %v, want %q", sort, key, vars, want)
+		}
+	}
+
+	// The search is bound, never interpolated
+	key, vars := s.sortKey(&OfferCatalogQuery{Sort: CatalogSortRelevance, Search: "x'); DROP TABLE offers; --"})
+	if strings.Contains(key, "DROP") || len(vars) != 1 || vars[0] != "x:* & drop:* & table:* & offers:*" {
+		t.Errorf("relevance sortKey = %q %v", key, vars)
+	}
+}
@@ -0,0 +1,1165 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aljapah/afftok-backend-prod/internal/cache"
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// ============================================
+// OFFER ROUTING SERVICE
+// ============================================
+
+// OfferRoutingService stores the caps, routing rules, A/B tests and rotation
+// served to the edge worker, and evaluates them at the origin with the same
+// semantics as the worker's SmartRouter so both route a click identically.
+type OfferRoutingService struct {
+	db    *gorm.DB
+	mutex sync.Mutex
+}
+
+// NewOfferRoutingService creates a new offer routing service
+func NewOfferRoutingService(db *gorm.DB) *OfferRoutingService {
+	return &OfferRoutingService{db: db}
+}
+
+var (
+	offerRoutingInstance *OfferRoutingService
+	offerRoutingOnce     sync.Once
+)
+
+// GetOfferRoutingService returns the global offer routing service
+func GetOfferRoutingService(db *gorm.DB) *OfferRoutingService {
+	offerRoutingOnce.Do(func() {
+		offerRoutingInstance = NewOfferRoutingService(db)
+	})
+	return offerRoutingInstance
+}
+
+// Cache configuration
+const (
+	offerRoutingCacheTTL    = 5 * time.Minute
+	offerRoutingCachePrefix = "offer_routing:"
+	routingRotationPrefix   = "routing:rotation:"
+	dailyCapPrefix          = "routing:cap:daily:"
+)
+
+// Schema limits
+const (
+	maxRoutingRules      = 50
+	maxRoutingConditions = 20
+	maxRoutingURLs       = 20
+)
+
+// ErrRoutingConfigNotFound is returned when a scope has no routing config
+var ErrRoutingConfigNotFound = errors.New("routing config not found")
+
+// RoutingValidationError lists everything wrong with a routing config
+type RoutingValidationError struct {
+	Problems []string
+}
+
+func (e *RoutingValidationError) Error() string {
+	return "invalid routing config: " + strings.Join(e.Problems, "; ")
+}
+
+// ============================================
+// OFFER ROUTING METRICS
+// ============================================
+
+// OfferRoutingMetrics tracks routing metrics
+type OfferRoutingMetrics struct {
+	ConfigsSaved       int64
+	ValidationFailures int64
+	Evaluations        int64
+	Capped             int64
+	Blocked            int64
+	RuleMatches        int64
+	ABTestAssignments  int64
+	Rotations          int64
+	CacheHits          int64
+	CacheMisses        int64
+}
+
+var routingMetrics = &OfferRoutingMetrics{}
+
+// GetOfferRoutingMetrics returns routing metrics
+func GetOfferRoutingMetrics() *OfferRoutingMetrics {
+	return &OfferRoutingMetrics{
+		ConfigsSaved:       atomic.LoadInt64(&routingMetrics.ConfigsSaved),
+		ValidationFailures: atomic.LoadInt64(&routingMetrics.ValidationFailures),
+		Evaluations:        atomic.LoadInt64(&routingMetrics.Evaluations),
+		Capped:             atomic.LoadInt64(&routingMetrics.Capped),
+		Blocked:            atomic.LoadInt64(&routingMetrics.Blocked),
+		RuleMatches:        atomic.LoadInt64(&routingMetrics.RuleMatches),
+		ABTestAssignments:  atomic.LoadInt64(&routingMetrics.ABTestAssignments),
+		Rotations:          atomic.LoadInt64(&routingMetrics.Rotations),
+		CacheHits:          atomic.LoadInt64(&routingMetrics.CacheHits),
+		CacheMisses:        atomic.LoadInt64(&routingMetrics.CacheMisses),
+	}
+}
+
+// ============================================
+// SCHEMA (mirrors edge/cloudflare-worker/src/smart-router.ts)
+// ============================================
+
+// routingConditionFields lists the fields each condition type reads. isp,
+// connection, cap and custom conditions take any field.
+var routingConditionFields = map[string]map[string]bool{
+	"geo":        {"country": true, "region": true, "city": true, "continent": true, "isEU": true, "asn": true},
+	"device":     {"type": true, "browser": true, "os": true, "isMobile": true, "isTablet": true, "isDesktop": true},
+	"time":       {"hour": true, "day": true, "date": true, "month": true},
+	"isp":        nil,
+	"connection": nil,
+	"cap":        nil,
+	"custom":     nil,
+}
+
+var routingOperators = map[string]bool{
+	"eq": true, "neq": true, "in": true, "not_in": true, "gt": true, "lt": true, "regex": true,
+}
+
+var routingRotationModes = map[string]bool{
+	"round_robin": true, "weighted": true, "smart_ctr": true,
+}
+
+// routingCondition is the typed form of an EdgeRoutingRule condition
+type routingCondition struct {
+	Type     string      `json:"type"`
+	Operator string      `json:"operator"`
+	Field    string      `json:"field"`
+	Value    interface{} `json:"value"`
+}
+
+// routingAction is the typed form of an EdgeRoutingRule action
+type routingAction struct {
+	Type          string                    `json:"type"`
+	Destination   string                    `json:"destination,omitempty"`
+	Destinations  []EdgeWeightedDestination `json:"destinations,omitempty"`
+	ABVariants    []EdgeABTestVariant       `json:"ab_variants,omitempty"`
+	FallbackChain []string                  `json:"fallback_chain,omitempty"`
+}
+
+// OfferRoutingRequest is the body of a routing config upsert
+type OfferRoutingRequest struct {
+	DailyCap     int                 `json:"daily_cap"`
+	TotalCap     int                 `json:"total_cap"`
+	FallbackURL  string              `json:"fallback_url"`
+	RoutingRules []EdgeRoutingRule   `json:"routing_rules"`
+	ABTest       *EdgeABTestConfig   `json:"ab_test"`
+	Rotation     *EdgeRotationConfig `json:"rotation"`
+	Status       string              `json:"status"`
+}
+
+// ============================================
+// VALIDATION
+// ============================================
+
+// ValidateRoutingRequest checks a routing config against the worker schema,
+// filling in rule IDs and statuses that were left empty
+func ValidateRoutingRequest(req *OfferRoutingRequest) error {
+	var problems []string
+	addf := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if req.DailyCap < 0 {
+		addf("daily_cap must not be negative")
+	}
+	if req.TotalCap < 0 {
+		addf("total_cap must not be negative")
+	}
+	if req.FallbackURL != "" && !isRoutingURL(req.FallbackURL) {
+		addf("fallback_url must be an absolute http(s) URL")
+	}
+	switch models.OfferRoutingStatus(req.Status) {
+	case "":
+		req.Status = string(models.OfferRoutingStatusActive)
+	case models.OfferRoutingStatusActive, models.OfferRoutingStatusDisabled:
+	default:
+		addf("status must be active or disabled")
+	}
+
+	// Routing rules
+	if len(req.RoutingRules) > maxRoutingRules {
+		addf("at most %d routing rules are allowed", maxRoutingRules)
+	}
+	for i := range req.RoutingRules {
+		rule := &req.RoutingRules[i]
+		prefix := fmt.Sprintf("routing_rules[%d]", i)
+
+		if rule.ID == "" {
+			rule.ID = uuid.New().String()
+		}
+		if strings.TrimSpace(rule.Name) == "" {
+			addf("%s.name is required", prefix)
+		}
+		switch rule.Status {
+		case "":
+			rule.Status = "active"
+		case "active", "inactive":
+		default:
+			addf("%s.status must be active or inactive", prefix)
+		}
+
+		if len(rule.Conditions) > maxRoutingConditions {
+			addf("%s: at most %d conditions are allowed", prefix, maxRoutingConditions)
+		}
+		for j, raw := range rule.Conditions {
+			var cond routingCondition
+			if err := remarshal(raw, &cond); err != nil {
+				addf("%s.conditions[%d] is malformed", prefix, j)
+				continue
+			}
+			for _, problem := range validateRoutingCondition(&cond) {
+				addf("%s.conditions[%d]: %s", prefix, j, problem)
+			}
+		}
+
+		var action routingAction
+		if err := remarshal(rule.Action, &action); err != nil {
+			addf("%s.action is malformed", prefix)
+			continue
+		}
+		for _, problem := range validateRoutingAction(&action) {
+			addf("%s.action: %s", prefix, problem)
+		}
+	}
+
+	// A/B test
+	if req.ABTest != nil && req.ABTest.Enabled {
+		for _, problem := range validateABVariants(req.ABTest.Variants) {
+			addf("ab_test: %s", problem)
+		}
+	}
+
+	// Rotation
+	if req.Rotation != nil {
+		if !routingRotationModes[req.Rotation.Mode] {
+			addf("rotation.mode must be round_robin, weighted or smart_ctr")
+		}
+		for _, problem := range validateWeightedDestinations(req.Rotation.Destinations) {
+			addf("rotation: %s", problem)
+		}
+	}
+
+	if len(problems) > 0 {
+		atomic.AddInt64(&routingMetrics.ValidationFailures, 1)
+		return &RoutingValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// validateRoutingCondition checks one condition
+func validateRoutingCondition(cond *routingCondition) []string {
+	var problems []string
+
+	fields, ok := routingConditionFields[cond.Type]
+	if !ok {
+		problems = append(problems, fmt.Sprintf("unknown type %q", cond.Type))
+	} else if fields != nil && !fields[cond.Field] {
+		problems = append(problems, fmt.Sprintf("unknown %s field %q", cond.Type, cond.Field))
+	} else if cond.Type == "connection" && cond.Field == "" {
+		problems = append(problems, "connection conditions need a field")
+	}
+
+	if !routingOperators[cond.Operator] {
+		problems = append(problems, fmt.Sprintf("unknown operator %q", cond.Operator))
+		return problems
+	}
+
+	switch cond.Operator {
+	case "in", "not_in":
+		if _, ok := cond.Value.([]interface{}); !ok {
+			problems = append(problems, cond.Operator+" needs an array value")
+		}
+	case "gt", "lt":
+		if _, ok := cond.Value.(float64); !ok {
+			problems = append(problems, cond.Operator+" needs a numeric value")
+		}
+	case "regex":
+		pattern, ok := cond.Value.(string)
+		if !ok {
+			problems = append(problems, "regex needs a string value")
+		} else if _, err := compileRoutingRegex(pattern); err != nil {
+			problems = append(problems, "invalid regex: "+err.Error())
+		}
+	default:
+		switch cond.Value.(type) {
+		case string, float64, bool:
+		default:
+			problems = append(problems, cond.Operator+" needs a string, number or boolean value")
+		}
+	}
+
+	return problems
+}
+
+// validateRoutingAction checks one rule action
+func validateRoutingAction(action *routingAction) []string {
+	var problems []string
+
+	switch action.Type {
+	case "redirect":
+		if !isRoutingURL(action.Destination) {
+			problems = append(problems, "redirect needs an absolute http(s) destination")
+		}
+	case "rotate":
+		problems = append(problems, validateWeightedDestinations(action.Destinations)...)
+		for _, dest := range action.Destinations {
+			if dest.Weight <= 0 {
+				problems = append(problems, "rotate weights must be positive")
+				break
+			}
+		}
+	case "ab_test":
+		problems = append(problems, validateABVariants(action.ABVariants)...)
+	case "fallback":
+		if len(action.FallbackChain) == 0 || len(action.FallbackChain) > maxRoutingURLs {
+			problems = append(problems, fmt.Sprintf("fallback needs 1 to %d URLs in fallback_chain", maxRoutingURLs))
+		}
+		for _, u := range action.FallbackChain {
+			if !isRoutingURL(u) {
+				problems = append(problems, "fallback_chain URLs must be absolute http(s) URLs")
+				break
+			}
+		}
+	case "block":
+	default:
+		problems = append(problems, fmt.Sprintf("unknown type %q", action.Type))
+	}
+
+	return problems
+}
+
+// validateABVariants checks that variants have unique IDs and percentages
+// that add up to 100 (the worker picks a variant from a 0-100 roll)
+func validateABVariants(variants []EdgeABTestVariant) []string {
+	var problems []string
+	if len(variants) == 0 || len(variants) > maxRoutingURLs {
+		return append(problems, fmt.Sprintf("needs 1 to %d variants", maxRoutingURLs))
+	}
+
+	seen := make(map[string]bool)
+	total := 0.0
+	for i, variant := range variants {
+		if variant.ID == "" || seen[variant.ID] {
+			problems = append(problems, fmt.Sprintf("variants[%d] needs a unique id", i))
+		}
+		seen[variant.ID] = true
+		if !isRoutingURL(variant.URL) {
+			problems = append(problems, fmt.Sprintf("variants[%d].url must be an absolute http(s) URL", i))
+		}
+		if variant.Percentage <= 0 {
+			problems = append(problems, fmt.Sprintf("variants[%d].percentage must be positive", i))
+		}
+		total += variant.Percentage
+	}
+	if math.Abs(total-100) > 0.01 {
+		problems = append(problems, fmt.Sprintf("variant percentages add up to %g, not 100", total))
+	}
+	return problems
+}
+
+// validateWeightedDestinations checks rotation destinations
+func validateWeightedDestinations(destinations []EdgeWeightedDestination) []string {
+	var problems []string
+	if len(destinations) == 0 || len(destinations) > maxRoutingURLs {
+		return append(problems, fmt.Sprintf("needs 1 to %d destinations", maxRoutingURLs))
+	}
+	for i, dest := range destinations {
+		if !isRoutingURL(dest.URL) {
+			problems = append(problems, fmt.Sprintf("destinations[%d].url must be an absolute http(s) URL", i))
+		}
+		if dest.Weight < 0 {
+			problems = append(problems, fmt.Sprintf("destinations[%d].weight must not be negative", i))
+		}
+		if dest.OfferID != "" {
+			if _, err := uuid.Parse(dest.OfferID); err != nil {
+				problems = append(problems, fmt.Sprintf("destinations[%d].offer_id must be a UUID", i))
+			}
+		}
+	}
+	return problems
+}
+
+// isRoutingURL reports whether s is an absolute http(s) URL
+func isRoutingURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// remarshal converts between JSON-compatible representations
+func remarshal(in, out interface{}) error {
+	raw, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, out)
+}
+
+// ============================================
+// CRUD OPERATIONS
+// ============================================
+
+// scopeQuery restricts a query to one config scope
+func (s *OfferRoutingService) scopeQuery(offerID uuid.UUID, userOfferID *uuid.UUID) *gorm.DB {
+	q := s.db.Where("offer_id = ?", offerID)
+	if userOfferID != nil {
+		return q.Where("user_offer_id = ?", *userOfferID)
+	}
+	return q.Where("user_offer_id IS NULL")
+}
+
+// GetConfig returns the config stored for exactly this scope, whatever its status
+func (s *OfferRoutingService) GetConfig(offerID uuid.UUID, userOfferID *uuid.UUID) (*models.OfferRoutingConfig, error) {
+	var config models.OfferRoutingConfig
+	if err := s.scopeQuery(offerID, userOfferID).First(&config).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRoutingConfigNotFound
+		}
+		return nil, err
+	}
+	return &config, nil
+}
+
+// ListUserOfferConfigs returns the per-link configs under an offer
+func (s *OfferRoutingService) ListUserOfferConfigs(offerID uuid.UUID) ([]models.OfferRoutingConfig, error) {
+	var configs []models.OfferRoutingConfig
+	err := s.db.Where("offer_id = ? AND user_offer_id IS NOT NULL", offerID).
+		Order("updated_at DESC").
+		Find(&configs).Error
+	return configs, err
+}
+
+// SaveConfig validates and creates or replaces the config for a scope
+func (s *OfferRoutingService) SaveConfig(offerID uuid.UUID, userOfferID *uuid.UUID, tenantID uuid.UUID, updatedBy *uuid.UUID, req *OfferRoutingRequest) (*models.OfferRoutingConfig, error) {
+	if err := ValidateRoutingRequest(req); err != nil {
+		return nil, err
+	}
+
+	var rulesJSON, abTestJSON, rotationJSON datatypes.JSON
+	if len(req.RoutingRules) > 0 {
+		raw, _ := json.Marshal(req.RoutingRules)
+		rulesJSON = datatypes.JSON(raw)
+	}
+	if req.ABTest != nil {
+		raw, _ := json.Marshal(req.ABTest)
+		abTestJSON = datatypes.JSON(raw)
+	}
+	if req.Rotation != nil {
+		raw, _ := json.Marshal(req.Rotation)
+		rotationJSON = datatypes.JSON(raw)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	config, err := s.GetConfig(offerID, userOfferID)
+	if err != nil && !errors.Is(err, ErrRoutingConfigNotFound) {
+		return nil, fmt.Errorf("failed to load routing config: %w", err)
+	}
+	if config == nil {
+		config = &models.OfferRoutingConfig{
+			ID:          uuid.New(),
+			OfferID:     offerID,
+			UserOfferID: userOfferID,
+			CreatedAt:   time.Now().UTC(),
+		}
+	}
+
+	config.TenantID = tenantID
+	config.DailyCap = req.DailyCap
+	config.TotalCap = req.TotalCap
+	config.FallbackURL = req.FallbackURL
+	config.RoutingRules = rulesJSON
+	config.ABTest = abTestJSON
+	config.Rotation = rotationJSON
+	config.Status = models.OfferRoutingStatus(req.Status)
+	config.UpdatedBy = updatedBy
+	config.UpdatedAt = time.Now().UTC()
+
+	if err := s.db.Save(config).Error; err != nil {
+		return nil, fmt.Errorf("failed to save routing config: %w", err)
+	}
+
+	s.InvalidateCache(offerID, userOfferID)
+	atomic.AddInt64(&routingMetrics.ConfigsSaved, 1)
+
+	return config, nil
+}
+
+// DeleteConfig removes the config for a scope
+func (s *OfferRoutingService) DeleteConfig(offerID uuid.UUID, userOfferID *uuid.UUID) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	result := s.scopeQuery(offerID, userOfferID).Delete(&models.OfferRoutingConfig{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete routing config: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrRoutingConfigNotFound
+	}
+
+	s.InvalidateCache(offerID, userOfferID)
+	return nil
+}
+
+// ============================================
+// RESOLUTION & CACHE
+// ============================================
+
+// ResolveConfig returns the active config for a click: the user offer's own
+// config if it has one, otherwise the offer's. Returns nil when neither exists.
+func (s *OfferRoutingService) ResolveConfig(offerID, userOfferID uuid.UUID) *models.OfferRoutingConfig {
+	ctx := context.Background()
+
+	if userOfferID != uuid.Nil {
+		if config := s.getCached(ctx, offerID, &userOfferID); config != nil {
+			return config
+		}
+	}
+	return s.getCached(ctx, offerID, nil)
+}
+
+// getCached loads the active config for a scope through the cache
+func (s *OfferRoutingService) getCached(ctx context.Context, offerID uuid.UUID, userOfferID *uuid.UUID) *models.OfferRoutingConfig {
+	cacheKey := routingCacheKey(offerID, userOfferID)
+
+	if cached, err := cache.Get(ctx, cacheKey); err == nil && cached != "" {
+		atomic.AddInt64(&routingMetrics.CacheHits, 1)
+		if cached == "null" {
+			return nil
+		}
+		var config models.OfferRoutingConfig
+		if err := json.Unmarshal([]byte(cached), &config); err == nil {
+			return &config
+		}
+	}
+
+	atomic.AddInt64(&routingMetrics.CacheMisses, 1)
+
+	var config models.OfferRoutingConfig
+	err := s.scopeQuery(offerID, userOfferID).
+		Where("status = ?", models.OfferRoutingStatusActive).
+		First(&config).Error
+	if err != nil {
+		// Cache negative result
+		cache.Set(ctx, cacheKey, "null", offerRoutingCacheTTL)
+		return nil
+	}
+
+	if jsonBytes, err := json.Marshal(config); err == nil {
+		cache.Set(ctx, cacheKey, string(jsonBytes), offerRoutingCacheTTL)
+	}
+	return &config
+}
+
+// InvalidateCache invalidates the cached config for a scope
+func (s *OfferRoutingService) InvalidateCache(offerID uuid.UUID, userOfferID *uuid.UUID) {
+	cache.Delete(context.Background(), routingCacheKey(offerID, userOfferID))
+}
+
+// routingCacheKey returns the cache key for a scope
+func routingCacheKey(offerID uuid.UUID, userOfferID *uuid.UUID) string {
+	if userOfferID != nil {
+		return fmt.Sprintf("%s%s:%s", offerRoutingCachePrefix, offerID.String(), userOfferID.String())
+	}
+	return fmt.Sprintf("%s%s:offer", offerRoutingCachePrefix, offerID.String())
+}
+
+// ============================================
+// CAPS
+// ============================================
+
+// RoutingCapState is a config's cap usage
+type RoutingCapState struct {
+	TotalClicks int    `json:"total_clicks"`
+	DailyClicks int    `json:"daily_clicks"`
+	Capped      bool   `json:"capped"`
+	Reason      string `json:"reason,omitempty"` // total_cap_exceeded, daily_cap_exceeded
+}
+
+// CapState counts clicks against a config's caps. Offer-level configs count
+// every link of the offer; user offer configs count that link only.
+func (s *OfferRoutingService) CapState(config *models.OfferRoutingConfig, offer *models.Offer, userOffer *models.UserOffer) RoutingCapState {
+	state := RoutingCapState{TotalClicks: capTotalClicks(config, offer, userOffer)}
+	if config.DailyCap > 0 {
+		state.DailyClicks = s.dailyClicks(config)
+	}
+
+	if config.TotalCap > 0 && state.TotalClicks >= config.TotalCap {
+		state.Capped = true
+		state.Reason = "total_cap_exceeded"
+	} else if config.DailyCap > 0 && state.DailyClicks >= config.DailyCap {
+		state.Capped = true
+		state.Reason = "daily_cap_exceeded"
+	}
+	return state
+}
+
+// reserveCaps is CapState for a click being routed: the daily cap is checked
+// by taking a slot with INCR-and-compare, so concurrent clicks can't
+// overshoot it. A reserved slot stays taken even if the click is later
+// dropped as a duplicate or geo block.
+func (s *OfferRoutingService) reserveCaps(config *models.OfferRoutingConfig, offer *models.Offer, userOffer *models.UserOffer) RoutingCapState {
+	state := RoutingCapState{TotalClicks: capTotalClicks(config, offer, userOffer)}
+	if config.TotalCap > 0 && state.TotalClicks >= config.TotalCap {
+		state.Capped = true
+		state.Reason = "total_cap_exceeded"
+		return state
+	}
+
+	userOfferID := uuid.Nil
+	if userOffer != nil {
+		userOfferID = userOffer.ID
+	}
+
+	if config.DailyCap <= 0 {
+		countDailyCapClick(context.Background(), config.OfferID, userOfferID)
+		return state
+	}
+
+	count, ok := s.reserveDailyCap(config, userOfferID)
+	state.DailyClicks = count
+	if !ok {
+		state.Capped = true
+		state.Reason = "daily_cap_exceeded"
+	}
+	return state
+}
+
+// capTotalClicks returns the all-time clicks a config's total cap applies to
+func capTotalClicks(config *models.OfferRoutingConfig, offer *models.Offer, userOffer *models.UserOffer) int {
+	if config.UserOfferID != nil && userOffer != nil {
+		return userOffer.TotalClicks
+	} else if offer != nil {
+		return offer.TotalClicks
+	}
+	return 0
+}
+
+// reserveDailyCap takes a slot from a config's daily cap and returns today's
+// count with it. A click that fits also counts towards the other scope (the
+// offer for link configs, the link for offer configs), where another config
+// may cap it. Without Redis the cap is checked against the database count.
+func (s *OfferRoutingService) reserveDailyCap(config *models.OfferRoutingConfig, userOfferID uuid.UUID) (int, bool) {
+	if cache.RedisClient == nil {
+		count := s.dailyClicks(config)
+		return count, count < config.DailyCap
+	}
+
+	ctx := context.Background()
+	now := time.Now()
+	key := configDailyCapKey(config, now)
+
+	count, err := cache.IncrWithExpire(ctx, key, 48*time.Hour)
+	if err != nil {
+		fmt.Printf("[OfferRouting] Redis increment error for %s: %v\n", key, err)
+		count := s.dailyClicks(config)
+		return count, count < config.DailyCap
+	}
+	if count > int64(config.DailyCap) {
+		// Give the slot back so the counter stays at the clicks let through
+		cache.Decrement(ctx, key)
+		return int(count) - 1, false
+	}
+
+	if config.UserOfferID != nil {
+		incrementDailyCapKey(ctx, dailyCapKey("offer", config.OfferID, now))
+	} else if userOfferID != uuid.Nil {
+		incrementDailyCapKey(ctx, dailyCapKey("link", userOfferID, now))
+	}
+	return int(count), true
+}
+
+// dailyClicks reads today's cap counter for a config's scope, counting in
+// the database when Redis is unavailable
+func (s *OfferRoutingService) dailyClicks(config *models.OfferRoutingConfig) int {
+	now := time.Now()
+
+	if cache.RedisClient != nil {
+		value, err := cache.Get(context.Background(), configDailyCapKey(config, now))
+		if err == nil {
+			count, _ := strconv.Atoi(value)
+			return count
+		}
+		if cache.IsNil(err) {
+			return 0
+		}
+	}
+
+	startOfDay := now.UTC().Truncate(24 * time.Hour)
+	var count int64
+	q := s.db.Model(&models.Click{}).Where("clicked_at >= ?", startOfDay)
+	if config.UserOfferID != nil {
+		q = q.Where("user_offer_id = ?", *config.UserOfferID)
+	} else {
+		q = q.Where("user_offer_id IN (SELECT id FROM user_offers WHERE offer_id = ?)", config.OfferID)
+	}
+	q.Count(&count)
+	return int(count)
+}
+
+// countDailyCapClick counts a click that wasn't routed by the origin (edge
+// and SDK clicks) against its link's and offer's daily caps
+func countDailyCapClick(ctx context.Context, offerID, userOfferID uuid.UUID) {
+	if cache.RedisClient == nil {
+		return
+	}
+	now := time.Now()
+	if offerID != uuid.Nil {
+		incrementDailyCapKey(ctx, dailyCapKey("offer", offerID, now))
+	}
+	if userOfferID != uuid.Nil {
+		incrementDailyCapKey(ctx, dailyCapKey("link", userOfferID, now))
+	}
+}
+
+func incrementDailyCapKey(ctx context.Context, key string) {
+	if _, err := cache.IncrWithExpire(ctx, key, 48*time.Hour); err != nil {
+		fmt.Printf("[OfferRouting] Redis increment error for %s: %v\n", key, err)
+	}
+}
+
+// dailyCapKey returns the UTC day's cap counter for an offer or a link
+func dailyCapKey(scope string, id uuid.UUID, now time.Time) string {
+	return fmt.Sprintf("%s%s:%s:%s", dailyCapPrefix, scope, id.String(), now.UTC().Format("2006-01-02"))
+}
+
+// configDailyCapKey returns the counter a config's daily cap is checked against
+func configDailyCapKey(config *models.OfferRoutingConfig, now time.Time) string {
+	if config.UserOfferID != nil {
+		return dailyCapKey("link", *config.UserOfferID, now)
+	}
+	return dailyCapKey("offer", config.OfferID, now)
+}
+
+// FallbackURL returns where capped and blocked clicks are sent
+func (s *OfferRoutingService) FallbackURL(config *models.OfferRoutingConfig, offer *models.Offer) string {
+	if config != nil && config.FallbackURL != "" {
+		return config.FallbackURL
+	}
+	if fallback := os.Getenv("FALLBACK_REDIRECT_URL"); fallback != "" {
+		return fallback
+	}
+	return offer.DestinationURL
+}
+
+// ============================================
+// EDGE CONFIG
+// ============================================
+
+// ApplyToEdgeConfig copies a config's caps, rules, A/B test and rotation into
+// the config served to the edge worker
+func (s *OfferRoutingService) ApplyToEdgeConfig(edge *EdgeOfferConfig, config *models.OfferRoutingConfig, offer *models.Offer, userOffer *models.UserOffer) {
+	edge.FallbackURL = s.FallbackURL(config, offer)
+	if config.TenantID != uuid.Nil {
+		edge.TenantID = config.TenantID.String()
+	}
+	edge.DailyCap = config.DailyCap
+	edge.TotalCap = config.TotalCap
+
+	caps := s.CapState(config, offer, userOffer)
+	edge.CurrentClicks = caps.TotalClicks
+	edge.CurrentDailyClicks = caps.DailyClicks
+	if caps.Capped && edge.Status == "active" {
+		edge.Status = "capped"
+	}
+
+	if len(config.RoutingRules) > 0 {
+		json.Unmarshal(config.RoutingRules, &edge.RoutingRules)
+	}
+	if len(config.ABTest) > 0 {
+		json.Unmarshal(config.ABTest, &edge.ABTest)
+	}
+	if len(config.Rotation) > 0 {
+		json.Unmarshal(config.Rotation, &edge.Rotation)
+	}
+}
+
+// ============================================
+// ORIGIN ROUTING
+// ============================================
+
+// RoutingContext is what the origin knows about a click. Geo and device
+// fields the origin can't resolve (continent, isEU, asn, isp, connection)
+// evaluate as missing, the same as an unknown field at the edge.
+type RoutingContext struct {
+	Country string
+	Region  string
+	City    string
+	Device  string // mobile, tablet, desktop
+	Browser string
+	OS      string
+	Now     time.Time
+}
+
+// RequestContext builds the routing context for a click request
+func (s *OfferRoutingService) RequestContext(c *gin.Context) *RoutingContext {
+	geo := GetGeoIPService().ResolveRequest(c)
+	device, browser, os := parseUserAgent(c.Request.UserAgent())
+	return &RoutingContext{
+		Country: geo.CountryCode,
+		Region:  geo.Region,
+		City:    geo.City,
+		Device:  device,
+		Browser: browser,
+		OS:      os,
+		Now:     time.Now().UTC(),
+	}
+}
+
+// RoutingDecision is the outcome of routing a click
+type RoutingDecision struct {
+	Destination   string `json:"final_destination"`
+	RuleApplied   string `json:"rule_applied"`
+	VariantID     string `json:"variant_id,omitempty"`
+	RotationIndex int    `json:"rotation_index"` // -1 when no rotation was applied
+	Capped        bool   `json:"capped,omitempty"`
+	Blocked       bool   `json:"blocked,omitempty"`
+	BlockReason   string `json:"block_reason,omitempty"`
+}
+
+// Route picks the destination for a click in the same order as the edge
+// SmartRouter: caps, routing rules, A/B test, then rotation
+func (s *OfferRoutingService) Route(config *models.OfferRoutingConfig, offer *models.Offer, userOffer *models.UserOffer, rctx *RoutingContext) *RoutingDecision {
+	atomic.AddInt64(&routingMetrics.Evaluations, 1)
+
+	decision := &RoutingDecision{
+		Destination:   offer.DestinationURL,
+		RuleApplied:   "default",
+		RotationIndex: -1,
+	}
+	fallback := s.FallbackURL(config, offer)
+
+	// 1. Caps
+	if caps := s.reserveCaps(config, offer, userOffer); caps.Capped {
+		atomic.AddInt64(&routingMetrics.Capped, 1)
+		decision.Capped = true
+		decision.RuleApplied = "cap_exceeded"
+		decision.Destination = fallback
+		return decision
+	}
+
+	// 2. Routing rules
+	var rules []EdgeRoutingRule
+	if len(config.RoutingRules) > 0 {
+		json.Unmarshal(config.RoutingRules, &rules)
+	}
+	if len(rules) > 0 {
+		if destination, ruleName, blocked := s.applyRules(rules, rctx); blocked {
+			atomic.AddInt64(&routingMetrics.Blocked, 1)
+			decision.Blocked = true
+			decision.BlockReason = ruleName
+			decision.Destination = fallback
+		} else if destination != "" {
+			atomic.AddInt64(&routingMetrics.RuleMatches, 1)
+			decision.Destination = destination
+			decision.RuleApplied = ruleName
+		}
+	}
+
+	// 3. A/B test
+	var abTest EdgeABTestConfig
+	if !decision.Blocked && len(config.ABTest) > 0 && json.Unmarshal(config.ABTest, &abTest) == nil && abTest.Enabled {
+		if variant := selectABVariant(abTest.Variants); variant != nil {
+			atomic.AddInt64(&routingMetrics.ABTestAssignments, 1)
+			decision.Destination = variant.URL
+			decision.VariantID = variant.ID
+			decision.RuleApplied = "ab_test:" + variant.ID
+		}
+	}
+
+	// 4. Rotation
+	var rotation EdgeRotationConfig
+	if !decision.Blocked && decision.VariantID == "" && len(config.Rotation) > 0 && json.Unmarshal(config.Rotation, &rotation) == nil {
+		if index := s.rotate(config, &rotation); index >= 0 {
+			atomic.AddInt64(&routingMetrics.Rotations, 1)
+			decision.Destination = rotation.Destinations[index].URL
+			decision.RotationIndex = index
+			decision.RuleApplied = "rotation:" + rotation.Mode
+		}
+	}
+
+	return decision
+}
+
+// applyRules returns the first matching active rule's destination, highest
+// priority first
+func (s *OfferRoutingService) applyRules(rules []EdgeRoutingRule, rctx *RoutingContext) (string, string, bool) {
+	sorted := make([]EdgeRoutingRule, len(rules))
+	copy(sorted, rules)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Priority > sorted[j].Priority })
+
+	for _, rule := range sorted {
+		if rule.Status != "active" || !matchRoutingConditions(rule.Conditions, rctx) {
+			continue
+		}
+
+		var action routingAction
+		if remarshal(rule.Action, &action) != nil {
+			continue
+		}
+
+		switch action.Type {
+		case "redirect":
+			return action.Destination, rule.Name, false
+		case "rotate":
+			if len(action.Destinations) > 0 {
+				return selectWeighted(action.Destinations).URL, rule.Name, false
+			}
+		case "ab_test":
+			if variant := selectABVariant(action.ABVariants); variant != nil {
+				return variant.URL, rule.Name + ":" + variant.ID, false
+			}
+		case "fallback":
+			if len(action.FallbackChain) > 0 {
+				return action.FallbackChain[0], rule.Name, false
+			}
+		case "block":
+			return "", rule.Name, true
+		}
+	}
+	return "", "", false
+}
+
+// matchRoutingConditions reports whether every condition matches
+func matchRoutingConditions(conditions []map[string]interface{}, rctx *RoutingContext) bool {
+	for _, raw := range conditions {
+		var cond routingCondition
+		if remarshal(raw, &cond) != nil {
+			return false
+		}
+		if !matchRoutingCondition(&cond, rctx) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchRoutingCondition evaluates one condition; cap and custom conditions
+// always match, as they do at the edge
+func matchRoutingCondition(cond *routingCondition, rctx *RoutingContext) bool {
+	var value interface{}
+
+	switch cond.Type {
+	case "geo":
+		switch cond.Field {
+		case "country":
+			value = nonEmpty(rctx.Country)
+		case "region":
+			value = nonEmpty(rctx.Region)
+		case "city":
+			value = nonEmpty(rctx.City)
+		}
+	case "device":
+		switch cond.Field {
+		case "type":
+			value = nonEmpty(rctx.Device)
+		case "browser":
+			value = nonEmpty(rctx.Browser)
+		case "os":
+			value = nonEmpty(rctx.OS)
+		case "isMobile":
+			value = rctx.Device == "mobile"
+		case "isTablet":
+			value = rctx.Device == "tablet"
+		case "isDesktop":
+			value = rctx.Device == "desktop"
+		}
+	case "isp", "connection":
+		// Not known at the origin
+	case "time":
+		now := rctx.Now.UTC()
+		switch cond.Field {
+		case "hour":
+			value = float64(now.Hour())
+		case "day":
+			value = float64(now.Weekday())
+		case "date":
+			value = float64(now.Day())
+		case "month":
+			value = float64(now.Month())
+		}
+	default:
+		return true
+	}
+
+	return compareRoutingValues(value, cond.Operator, cond.Value)
+}
+
+// compareRoutingValues applies an operator with JavaScript's strict comparison
+// rules, so origin and edge agree on mixed types
+func compareRoutingValues(fieldValue interface{}, operator string, conditionValue interface{}) bool {
+	switch operator {
+	case "eq":
+		return fieldValue == conditionValue
+	case "neq":
+		return fieldValue != conditionValue
+	case "in", "not_in":
+		list, ok := conditionValue.([]interface{})
+		if !ok {
+			return false
+		}
+		found := false
+		for _, item := range list {
+			if item == fieldValue {
+				found = true
+				break
+			}
+		}
+		return found == (operator == "in")
+	case "gt", "lt":
+		a, aok := fieldValue.(float64)
+		b, bok := conditionValue.(float64)
+		if !aok || !bok {
+			return false
+		}
+		if operator == "gt" {
+			return a > b
+		}
+		return a < b
+	case "regex":
+		pattern, ok := conditionValue.(string)
+		if !ok {
+			return false
+		}
+		re, err := compileRoutingRegex(pattern)
+		return err == nil && re.MatchString(routingString(fieldValue))
+	}
+	return false
+}
+
+// Compiled regex conditions, shared by every config. Patterns are compiled
+// when a config is validated or first evaluated, not on every click.
+var (
+	routingRegexes    sync.Map // pattern -> *regexp.Regexp
+	routingRegexCount int64
+	maxRoutingRegexes = int64(1024)
+)
+
+// compileRoutingRegex returns the compiled pattern from the cache, compiling
+// it on first use. Once the cache is full, new patterns are compiled uncached.
+func compileRoutingRegex(pattern string) (*regexp.Regexp, error) {
+	if cached, ok := routingRegexes.Load(pattern); ok {
+		return cached.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	if atomic.LoadInt64(&routingRegexCount) < maxRoutingRegexes {
+		if _, loaded := routingRegexes.LoadOrStore(pattern, re); !loaded {
+			atomic.AddInt64(&routingRegexCount, 1)
+		}
+	}
+	return re, nil
+}
+
+// routingString formats a value like JavaScript's String()
+func routingString(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return val
+	case bool:
+		return strconv.FormatBool(val)
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
+
+// nonEmpty maps an unresolved field to a missing value
+func nonEmpty(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// selectABVariant picks a variant from a 0-100 roll over cumulative percentages
+func selectABVariant(variants []EdgeABTestVariant) *EdgeABTestVariant {
+	if len(variants) == 0 {
+		return nil
+	}
+	roll := rand.Float64() * 100
+	cumulative := 0.0
+	for i := range variants {
+		cumulative += variants[i].Percentage
+		if roll <= cumulative {
+			return &variants[i]
+		}
+	}
+	return &variants[0]
+}
+
+// selectWeighted picks a destination in proportion to its weight
+func selectWeighted(destinations []EdgeWeightedDestination) EdgeWeightedDestination {
+	total := 0
+	for _, dest := range destinations {
+		total += dest.Weight
+	}
+	roll := rand.Float64() * float64(total)
+	cumulative := 0
+	for _, dest := range destinations {
+		cumulative += dest.Weight
+		if roll <= float64(cumulative) {
+			return dest
+		}
+	}
+	return destinations[0]
+}
+
+// rotate returns the index of the next rotation destination, or -1
+func (s *OfferRoutingService) rotate(config *models.OfferRoutingConfig, rotation *EdgeRotationConfig) int {
+	count := len(rotation.Destinations)
+	if count == 0 {
+		return -1
+	}
+
+	switch rotation.Mode {
+	case "weighted":
+		selected := selectWeighted(rotation.Destinations)
+		for i, dest := range rotation.Destinations {
+			if dest == selected {
+				return i
+			}
+		}
+		return 0
+	case "round_robin", "smart_ctr":
+		// smart_ctr needs CTR data; like the edge it rotates round robin for now
+		scope := config.OfferID.String()
+		if config.UserOfferID != nil {
+			scope = config.UserOfferID.String()
+		}
+		next, err := cache.IncrWithExpire(context.Background(), routingRotationPrefix+scope, 24*time.Hour)
+		if err != nil {
+			return 0
+		}
+		return int((next - 1) % int64(count))
+	}
+	return -1
+}
@@ -0,0 +1,295 @@
+package services
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/google/uuid"
+)
+
+func TestValidateRoutingRequest(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []string // Expected problems, as substrings; none for a valid config
+	}{
+		{name: "empty", body: `{}`},
+		{name: "full", body: `{
+			"daily_cap": 100, "total_cap": 1000, "fallback_url": "https://example.com/sold-out",
+			"routing_rules": [{
+				"name": "gulf mobile", "priority": 10,
+				"conditions": [
+					{"type": "geo", "field": "country", "operator": "in", "value": ["SA", "KW"]},
+					{"type": "device", "field": "isMobile", "operator": "eq", "value": true},
+					{"type": "time", "field": "hour", "operator": "gt", "value": 8},
+					{"type": "device", "field": "browser", "operator": "regex", "value": "^(Chrome|Safari)$"},
+					{"type": "connection", "field": "type", "operator": "neq", "value": "cellular"}
+				],
+				"action": {"type": "redirect", "destination": "https://example.com/gulf"}
+			}, {
+				"name": "rotate", "conditions": [],
+				"action": {"type": "rotate", "destinations": [{"url": "https://a.example.com", "weight": 1}, {"url": "https://b.example.com", "weight": 3}]}
+			}, {
+				"name": "bots", "status": "inactive", "conditions": [{"type": "custom", "field": "x", "operator": "eq", "value": "1"}],
+				"action": {"type": "block"}
+			}],
+			"ab_test": {"enabled": true, "variants": [{"id": "a", "url": "https://a.example.com", "percentage": 33.33}, {"id": "b", "url": "https://b.example.com", "percentage": 66.67}]},
+			"rotation": {"mode": "weighted", "destinations": [{"url": "https://a.example.com", "weight": 1, "offer_id": "5f0c6b2e-8d1a-4c3b-9e7f-2a4d6c8b0e1f"}]},
+			"status": "disabled"
+		}`},
+		{name: "disabled A/B test isn't checked", body: `{"ab_test": {"enabled": false, "variants": []}}`},
+
+		{name: "negative caps", body: `{"daily_cap": -1, "total_cap": -1}`, want: []string{"daily_cap", "total_cap"}},
+		{name: "relative fallback", body: `{"fallback_url": "/sold-out"}`, want: []string{"fallback_url"}},
+		{name: "javascript fallback", body: `{"fallback_url": "javascript:alert(1)"}`, want: []string{"fallback_url"}},
+		{name: "unknown status", body: `{"status": "paused"}`, want: []string{"status must be active or disabled"}},
+		{name: "rule without name", body: `{"routing_rules": [{"action": {"type": "block"}}]}`, want: []string{"routing_rules[0].name is required"}},
+		{name: "rule status", body: `{"routing_rules": [{"name": "r", "status": "on", "action": {"type": "block"}}]}`, want: []string{"routing_rules[0].status"}},
+		{name: "unknown condition type", body: `{"routing_rules": [{"name": "r", "conditions": [{"type": "weather", "operator": "eq", "value": "sunny"}], "action": {"type": "block"}}]}`,
+			want: []string{`conditions[0]: unknown type "weather"`}},
+		{name: "unknown geo field", body: `{"routing_rules": [{"name": "r", "conditions": [{"type": "geo", "field": "zip", "operator": "eq", "value": "1"}], "action": {"type": "block"}}]}`,
+			want: []string{`unknown geo field "zip"`}},
+		{name: "connection without field", body: `{"routing_rules": [{"name": "r", "conditions": [{"type": "connection", "operator": "eq", "value": "4g"}], "action": {"type": "block"}}]}`,
+			want: []string{"connection conditions need a field"}},
+		{name: "unknown operator", body: `{"routing_rules": [{"name": "r", "conditions": [{"type": "geo", "field": "country", "operator": "like", "value": "S%"}], "action": {"type": "block"}}]}`,
+			want: []string{`unknown operator "like"`}},
+		{name: "in needs array", body: `{"routing_rules": [{"name": "r", "conditions": [{"type": "geo", "field": "country", "operator": "in", "value": "SA"}], "action": {"type": "block"}}]}`,
+			want: []string{"in needs an array value"}},
+		{name: "gt needs number", body: `{"routing_rules": [{"name": "r", "conditions": [{"type": "time", "field": "hour", "operator": "gt", "value": "8"}], "action": {"type": "block"}}]}`,
+			want: []string{"gt needs a numeric value"}},
+		{name: "invalid regex", body: `{"routing_rules": [{"name": "r", "conditions": [{"type": "device", "field": "os", "operator": "regex", "value": "("}], "action": {"type": "block"}}]}`,
+			want: []string{"invalid regex"}},
+		{name: "eq needs scalar", body: `{"routing_rules": [{"name": "r", "conditions": [{"type": "geo", "field": "country", "operator": "eq", "value": ["SA"]}], "action": {"type": "block"}}]}`,
+			want: []string{"eq needs a string, number or boolean value"}},
+		{name: "unknown action", body: `{"routing_rules": [{"name": "r", "action": {"type": "teleport"}}]}`, want: []string{`action: unknown type "teleport"`}},
+		{name: "redirect without destination", body: `{"routing_rules": [{"name": "r", "action": {"type": "redirect"}}]}`, want: []string{"redirect needs an absolute http(s) destination"}},
+		{name: "rotate with zero weight", body: `{"routing_rules": [{"name": "r", "action": {"type": "rotate", "destinations": [{"url": "https://a.example.com", "weight": 0}]}}]}`,
+			want: []string{"rotate weights must be positive"}},
+		{name: "empty fallback chain", body: `{"routing_rules": [{"name": "r", "action": {"type": "fallback", "fallback_chain": []}}]}`, want: []string{"fallback needs 1 to"}},
+		{name: "fallback chain URL", body: `{"routing_rules": [{"name": "r", "action": {"type": "fallback", "fallback_chain": ["ftp://example.com"]}}]}`,
+			want: []string{"fallback_chain URLs"}},
+		{name: "A/B percentages", body: `{"ab_test": {"enabled": true, "variants": [{"id": "a", "url": "https://a.example.com", "percentage": 50}, {"id": "b", "url": "https://b.example.com", "percentage": 40}]}}`,
+			want: []string{"add up to 90, not 100"}},
+		{name: "A/B duplicate IDs", body: `{"ab_test": {"enabled": true, "variants": [{"id": "a", "url": "https://a.example.com", "percentage": 50}, {"id": "a", "url": "https://b.example.com", "percentage": 50}]}}`,
+			want: []string{"variants[1] needs a unique id"}},
+		{name: "A/B without variants", body: `{"ab_test": {"enabled": true}}`, want: []string{"ab_test: needs 1 to"}},
+		{name: "rotation mode", body: `{"rotation": {"mode": "random", "destinations": [{"url": "https://a.example.com", "weight": 1}]}}`, want: []string{"rotation.mode"}},
+		{name: "rotation destinations", body: `{"rotation": {"mode": "round_robin", "destinations": [{"url": "a.example.com", "weight": -1, "offer_id": "offer-1"}]}}`,
+			want: []string{"destinations[0].url", "destinations[0].weight", "destinations[0].offer_id"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var req OfferRoutingRequest
+			if err := json.Unmarshal([]byte(tt.body), &req); err != nil {
+				t.Fatalf("bad test body: %v", err)
+			}
+
+			err := ValidateRoutingRequest(&req)
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+
+			verr, ok := err.(*RoutingValidationError)
+			if !ok {
+				t.Fatalf("error = %v, want a RoutingValidationError", err)
+			}
+			for _, want := range tt.want {
+				found := false
+				for _, problem := range verr.Problems {
+					if strings.Contains(problem, want) {
+						found = true
+						break
+					}
+				}
+				if !found {
+					t.Errorf("no problem mentions %q; got %q", want, verr.Problems)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateRoutingRequestLimits(t *testing.T) {
+	req := &OfferRoutingRequest{}
+	for i := 0; i <= maxRoutingRules; i++ {
+		req.RoutingRules = append(req.RoutingRules, EdgeRoutingRule{Name: "r", Action: map[string]interface{}{"type": "block"}})
+	}
+	if err := ValidateRoutingRequest(req); err == nil || !strings.Contains(err.Error(), "routing rules are allowed") {
+		t.Errorf("too many rules: error = %v", err)
+	}
+
+	rule := EdgeRoutingRule{Name: "r", Action: map[string]interface{}{"type": "block"}}
+	for i := 0; i <= maxRoutingConditions; i++ {
+		rule.Conditions = append(rule.Conditions, map[string]interface{}{"type": "custom", "operator": "eq", "value": "x"})
+	}
+	if err := ValidateRoutingRequest(&OfferRoutingRequest{RoutingRules: []EdgeRoutingRule{rule}}); err == nil || !strings.Contains(err.Error(), "conditions are allowed") {
+		t.Errorf("too many conditions: error = %v", err)
+	}
+}
+
+func TestValidateRoutingRequestDefaults(t *testing.T) {
+	req := &OfferRoutingRequest{RoutingRules: []EdgeRoutingRule{
+		{Name: "a", Action: map[string]interface{}{"type": "block"}},
+		{ID: "keep", Name: "b", Status: "inactive", Action: map[string]interface{}{"type": "block"}},
+	}}
+	if err := ValidateRoutingRequest(req); err != nil {
+		t.Fatal(err)
+	}
+
+	if req.Status != string(models.OfferRoutingStatusActive) {
+		t.Errorf("status = %q, want active", req.Status)
+	}
+	if _, err := uuid.Parse(req.RoutingRules[0].ID); err != nil {
+		t.Errorf("rule ID = %q, want a generated UUID", req.RoutingRules[0].ID)
+	}
+	if req.RoutingRules[0].Status != "active" {
+		t.Errorf("rule status = %q, want active", req.RoutingRules[0].Status)
+	}
+	if req.RoutingRules[1].ID != "keep" || req.RoutingRules[1].Status != "inactive" {
+		t.Errorf("explicit ID and status were overwritten: %+v", req.RoutingRules[1])
+	}
+}
+
+func TestCompareRoutingValues(t *testing.T) {
+	list := []interface{}{"SA", "KW", 3.0}
+
+	tests := []struct {
+		name     string
+		field    interface{}
+		operator string
+		value    interface{}
+		want     bool
+	}{
+		{"eq string", "SA", "eq", "SA", true},
+		{"eq is case-sensitive", "sa", "eq", "SA", false},
+		{"eq number", 3.0, "eq", 3.0, true},
+		{"eq is strict across types", "3", "eq", 3.0, false},
+		{"eq bool", true, "eq", true, true},
+		{"eq missing", nil, "eq", "SA", false},
+		{"neq", "SA", "neq", "KW", true},
+		{"neq missing", nil, "neq", "SA", true},
+
+		{"in", "KW", "in", list, true},
+		{"in number", 3.0, "in", list, true},
+		{"in miss", "AE", "in", list, false},
+		{"in is strict", "3", "in", list, false},
+		{"in missing", nil, "in", list, false},
+		{"in needs a list", "SA", "in", "SA", false},
+		{"not_in", "AE", "not_in", list, true},
+		{"not_in hit", "SA", "not_in", list, false},
+		{"not_in missing", nil, "not_in", list, true},
+		{"not_in needs a list", "AE", "not_in", "SA", false},
+
+		{"gt", 9.0, "gt", 8.0, true},
+		{"gt boundary", 8.0, "gt", 8.0, false},
+		{"lt", 7.0, "lt", 8.0, true},
+		{"lt boundary", 8.0, "lt", 8.0, false},
+		{"gt on a string", "9", "gt", 8.0, false},
+		{"gt missing", nil, "gt", -1.0, false},
+
+		{"regex", "Chrome", "regex", "^(Chrome|Safari)$", true},
+		{"regex miss", "Firefox", "regex", "^(Chrome|Safari)$", false},
+		{"regex is unanchored", "Mobile Safari", "regex", "Safari", true},
+		{"regex on a number", 12.5, "regex", `^12\.5$`, true},
+		{"regex on a bool", true, "regex", "^true$", true},
+		{"regex on missing", nil, "regex", "^null$", true},
+		{"invalid regex", "(", "regex", "(", false},
+		{"regex needs a string", "1", "regex", 1.0, false},
+
+		{"unknown operator", "SA", "like", "SA", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := compareRoutingValues(tt.field, tt.operator, tt.value); got != tt.want {
+				t.Errorf("compareRoutingValues(%v, %s, %v) = %v, want %v", tt.field, tt.operator, tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompileRoutingRegexCaches(t *testing.T) {
+	const pattern = `^routing-regex-cache-test-\d+$`
+
+	first, err := compileRoutingRegex(pattern)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := compileRoutingRegex(pattern)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != second {
+		t.Error("pattern was compiled twice")
+	}
+
+	if _, err := compileRoutingRegex("("); err == nil {
+		t.Error("invalid pattern compiled")
+	}
+	if _, ok := routingRegexes.Load("("); ok {
+		t.Error("invalid pattern was cached")
+	}
+}
+
+func TestDailyCapKeyUsesUTC(t *testing.T) {
+	id := uuid.MustParse("5f0c6b2e-8d1a-4c3b-9e7f-2a4d6c8b0e1f")
+	riyadh := time.FixedZone("AST", 3*60*60)
+
+	// 01:30 in Riyadh on Jan 2nd is still Jan 1st in UTC
+	now := time.Date(2026, 1, 2, 1, 30, 0, 0, riyadh)
+	want := "routing:cap:daily:link:5f0c6b2e-8d1a-4c3b-9e7f-2a4d6c8b0e1f:2026-01-01"
+	if got := dailyCapKey("link", id, now); got != want {
+		t.Errorf("dailyCapKey = %s, want %s", got, want)
+	}
+
+	offerConfig := &models.OfferRoutingConfig{OfferID: id}
+	if got := configDailyCapKey(offerConfig, now); !strings.HasPrefix(got, "routing:cap:daily:offer:") {
+		t.Errorf("offer config key = %s", got)
+	}
+	linkConfig := &models.OfferRoutingConfig{OfferID: uuid.New(), UserOfferID: &id}
+	if got := configDailyCapKey(linkConfig, now); got != want {
+		t.Errorf("link config key = %s, want %s", got, want)
+	}
+}
+
+func TestMatchRoutingCondition(t *testing.T) {
+	rctx := &RoutingContext{
+		Country: "SA",
+		Device:  "mobile",
+		Browser: "Chrome",
+		Now:     time.Date(2026, 3, 15, 14, 0, 0, 0, time.UTC), // A Sunday
+	}
+
+	tests := []struct {
+		name string
+		cond routingCondition
+		want bool
+	}{
+		{"country", routingCondition{Type: "geo", Field: "country", Operator: "eq", Value: "SA"}, true},
+		{"unresolved city is missing", routingCondition{Type: "geo", Field: "city", Operator: "neq", Value: "Riyadh"}, true},
+		{"continent unknown at origin", routingCondition{Type: "geo", Field: "continent", Operator: "eq", Value: "AS"}, false},
+		{"isMobile", routingCondition{Type: "device", Field: "isMobile", Operator: "eq", Value: true}, true},
+		{"isDesktop", routingCondition{Type: "device", Field: "isDesktop", Operator: "eq", Value: true}, false},
+		{"hour", routingCondition{Type: "time", Field: "hour", Operator: "gt", Value: 13.0}, true},
+		{"weekday", routingCondition{Type: "time", Field: "day", Operator: "eq", Value: 0.0}, true},
+		{"month", routingCondition{Type: "time", Field: "month", Operator: "eq", Value: 3.0}, true},
+		{"isp unknown at origin", routingCondition{Type: "isp", Field: "name", Operator: "eq", Value: "STC"}, false},
+		{"cap always matches", routingCondition{Type: "cap", Field: "daily", Operator: "lt", Value: 0.0}, true},
+		{"custom always matches", routingCondition{Type: "custom", Field: "x", Operator: "eq", Value: "y"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := matchRoutingCondition(&tt.cond, rctx); got != tt.want {
+				t.Errorf("matchRoutingCondition = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
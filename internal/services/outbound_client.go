@@ -0,0 +1,353 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ============================================
+// OUTBOUND HTTP CLIENT
+// ============================================
+
+// Outbound request errors
+var (
+	ErrOutboundScheme           = errors.New("outbound URL scheme is not allowed")
+	ErrOutboundPort             = errors.New("outbound URL port is not allowed")
+	ErrOutboundDestination      = errors.New("outbound destination is a private or reserved address")
+	ErrOutboundNotAllowlisted   = errors.New("outbound host is not on the tenant's egress allowlist")
+	ErrOutboundTooManyRedirects = errors.New("outbound request stopped after too many redirects")
+	ErrOutboundResponseTooLarge = errors.New("outbound response body is too large")
+)
+
+const (
+	defaultOutboundTimeout          = 30 * time.Second
+	defaultOutboundMaxResponseBytes = 1 << 20 // 1 MB
+	maxOutboundRedirects            = 5
+)
+
+var defaultOutboundPorts = []string{"80", "443", "8080", "8443"}
+
+// blockedOutboundNetworks are reserved ranges not covered by the net.IP
+// helpers (loopback, private, link-local, multicast, unspecified)
+var blockedOutboundNetworks = mustParseCIDRs(
+	"0.0.0.0/8",       // "This" network
+	"100.64.0.0/10",   // Carrier-grade NAT (also Alibaba Cloud metadata)
+	"192.0.0.0/24",    // IETF protocol assignments
+	"192.0.2.0/24",    // TEST-NET-1
+	"198.18.0.0/15",   // Benchmarking
+	"198.51.100.0/24", // TEST-NET-2
+	"203.0.113.0/24",  // TEST-NET-3
+	"240.0.0.0/4",     // Reserved, broadcast
+	"64:ff9b::/96",    // NAT64 (may embed any IPv4 address)
+	"64:ff9b:1::/48",  // Local-use NAT64
+	"2001:db8::/32",   // Documentation
+)
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
+
+// IsBlockedOutboundIP reports whether ip is a private, loopback, link-local
+// (including the 169.254.169.254 metadata endpoint) or otherwise reserved
+// address that outbound requests may not reach
+func IsBlockedOutboundIP(ip net.IP) bool {
+	if v4 := ip.To4(); v4 != nil {
+		ip = v4
+	}
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return true
+	}
+	for _, network := range blockedOutboundNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+type outboundTenantKey struct{}
+
+// WithOutboundTenant scopes outbound requests made with ctx to a tenant's
+// egress allowlist
+func WithOutboundTenant(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, outboundTenantKey{}, tenantID)
+}
+
+// OutboundClient is the HTTP client for every request to a URL configured by
+// advertisers or admins (webhook steps, postbacks, alert channels). It only
+// allows the configured schemes and ports and refuses to connect to private
+// or reserved addresses. The check runs on the resolved address of every
+// connection, so it also covers redirects and DNS rebinding. Response bodies
+// are capped (OUTBOUND_MAX_RESPONSE_BYTES).
+type OutboundClient struct {
+	client           *http.Client
+	allowedSchemes   map[string]bool
+	allowedPorts     map[string]bool
+	allowPrivate     bool
+	maxResponseBytes int64
+	tenantService    *TenantService
+
+	blockedRequests int64
+}
+
+var (
+	outboundClientInstance *OutboundClient
+	outboundClientOnce     sync.Once
+)
+
+// GetOutboundClient returns the shared outbound client
+func GetOutboundClient() *OutboundClient {
+	outboundClientOnce.Do(func() {
+		outboundClientInstance = NewOutboundClient(defaultOutboundTimeout)
+	})
+	return outboundClientInstance
+}
+
+// NewOutboundClient creates an outbound client. Configuration comes from
+// OUTBOUND_ALLOWED_SCHEMES (default "http,https"), OUTBOUND_ALLOWED_PORTS
+// (default "80,443,8080,8443"), OUTBOUND_MAX_RESPONSE_BYTES and
+// OUTBOUND_ALLOW_PRIVATE_NETWORKS (local development only).
+func NewOutboundClient(timeout time.Duration) *OutboundClient {
+	c := &OutboundClient{
+		allowedSchemes:   envSet("OUTBOUND_ALLOWED_SCHEMES", []string{"http", "https"}),
+		allowedPorts:     envSet("OUTBOUND_ALLOWED_PORTS", defaultOutboundPorts),
+		allowPrivate:     os.Getenv("OUTBOUND_ALLOW_PRIVATE_NETWORKS") == "true",
+		maxResponseBytes: defaultOutboundMaxResponseBytes,
+	}
+	if value, err := strconv.ParseInt(os.Getenv("OUTBOUND_MAX_RESPONSE_BYTES"), 10, 64); err == nil && value > 0 {
+		c.maxResponseBytes = value
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   10 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   c.checkConnection,
+	}
+
+	c.client = &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			// No proxy: connections must go straight to the checked address
+			Proxy:                 nil,
+			DialContext:           dialer.DialContext,
+			MaxIdleConns:          100,
+			MaxIdleConnsPerHost:   10,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ExpectContinueTimeout: time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxOutboundRedirects {
+				return ErrOutboundTooManyRedirects
+			}
+			return c.checkURL(req.Context(), req.URL)
+		},
+	}
+
+	return c
+}
+
+// envSet reads a comma separated, case-insensitive set from the environment
+func envSet(name string, fallback []string) map[string]bool {
+	values := fallback
+	if raw := os.Getenv(name); raw != "" {
+		values = strings.Split(raw, ",")
+	}
+	set := make(map[string]bool, len(values))
+	for _, value := range values {
+		if value = strings.ToLower(strings.TrimSpace(value)); value != "" {
+			set[value] = true
+		}
+	}
+	return set
+}
+
+// SetTenantService enables per-tenant egress allowlists
+func (c *OutboundClient) SetTenantService(service *TenantService) {
+	c.tenantService = service
+}
+
+// Do sends a request after checking its URL. The response body is capped
+// at the client's maximum size; reading past it returns
+// ErrOutboundResponseTooLarge.
+func (c *OutboundClient) Do(req *http.Request) (*http.Response, error) {
+	if err := c.checkURL(req.Context(), req.URL); err != nil {
+		atomic.AddInt64(&c.blockedRequests, 1)
+		return nil, err
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		if IsOutboundPolicyError(err) {
+			atomic.AddInt64(&c.blockedRequests, 1)
+		}
+		return nil, err
+	}
+
+	resp.Body = &limitedBody{ReadCloser: resp.Body, remaining: c.maxResponseBytes}
+	return resp, nil
+}
+
+// Post sends a POST request with the given content type
+func (c *OutboundClient) Post(ctx context.Context, rawURL, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, rawURL, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	return c.Do(req)
+}
+
+// ValidateURL checks a URL against the scheme, port and tenant allowlists
+// and, if its host is an IP address, the blocked ranges. Use it to reject
+// bad URLs when they are configured; hostnames are checked again on every
+// connection.
+func (c *OutboundClient) ValidateURL(rawURL, tenantID string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid URL: %w", err)
+	}
+	return c.checkURL(WithOutboundTenant(context.Background(), tenantID), parsed)
+}
+
+// checkURL checks the scheme, port and tenant allowlist of a request or
+// redirect URL
+func (c *OutboundClient) checkURL(ctx context.Context, u *url.URL) error {
+	scheme := strings.ToLower(u.Scheme)
+	if !c.allowedSchemes[scheme] {
+		return fmt.Errorf("%w: %q", ErrOutboundScheme, u.Scheme)
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if host == "" {
+		return fmt.Errorf("%w: missing host", ErrOutboundDestination)
+	}
+
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if scheme == "https" {
+			port = "443"
+		}
+	}
+	if !c.allowedPorts[port] {
+		return fmt.Errorf("%w: %s", ErrOutboundPort, port)
+	}
+
+	if ip := net.ParseIP(host); ip != nil && !c.allowPrivate && IsBlockedOutboundIP(ip) {
+		return fmt.Errorf("%w: %s", ErrOutboundDestination, host)
+	}
+
+	tenantID, _ := ctx.Value(outboundTenantKey{}).(string)
+	if allowlist := c.egressAllowlist(tenantID); len(allowlist) > 0 && !hostInDomains(host, allowlist) {
+		return fmt.Errorf("%w: %s", ErrOutboundNotAllowlisted, host)
+	}
+
+	return nil
+}
+
+// egressAllowlist returns a tenant's egress allowlist (empty: any public host)
+func (c *OutboundClient) egressAllowlist(tenantID string) []string {
+	if c.tenantService == nil || tenantID == "" {
+		return nil
+	}
+	id, err := uuid.Parse(tenantID)
+	if err != nil {
+		return nil
+	}
+	settings, err := c.tenantService.GetSettings(id)
+	if err != nil {
+		return nil
+	}
+
+	allowlist := make([]string, 0, len(settings.EgressAllowlist))
+	for _, host := range settings.EgressAllowlist {
+		if host = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(host)), "."); host != "" {
+			allowlist = append(allowlist, host)
+		}
+	}
+	return allowlist
+}
+
+// checkConnection runs on every dial with the resolved address, so a
+// hostname can't resolve (or re-resolve) to a blocked address
+func (c *OutboundClient) checkConnection(network, address string, _ syscall.RawConn) error {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if !c.allowedPorts[port] {
+		return fmt.Errorf("%w: %s", ErrOutboundPort, port)
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return fmt.Errorf("%w: unresolved address %s", ErrOutboundDestination, host)
+	}
+	if !c.allowPrivate && IsBlockedOutboundIP(ip) {
+		return fmt.Errorf("%w: %s", ErrOutboundDestination, host)
+	}
+	return nil
+}
+
+// IsOutboundPolicyError reports whether a request was refused by the
+// outbound policy (retrying it won't help)
+func IsOutboundPolicyError(err error) bool {
+	return errors.Is(err, ErrOutboundDestination) || errors.Is(err, ErrOutboundPort) ||
+		errors.Is(err, ErrOutboundScheme) || errors.Is(err, ErrOutboundNotAllowlisted)
+}
+
+// GetStats returns outbound client statistics
+func (c *OutboundClient) GetStats() map[string]interface{} {
+	return map[string]interface{}{
+		"blocked_requests":   atomic.LoadInt64(&c.blockedRequests),
+		"max_response_bytes": c.maxResponseBytes,
+		"allow_private":      c.allowPrivate,
+	}
+}
+
+// limitedBody fails reads past the response size limit
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining <= 0 {
+		// Only an error if the body actually continues
+		var probe [1]byte
+		if n, err := b.ReadCloser.Read(probe[:]); n == 0 {
+			return 0, err
+		}
+		return 0, ErrOutboundResponseTooLarge
+	}
+	if int64(len(p)) > b.remaining {
+		p = p[:b.remaining]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.remaining -= int64(n)
+	return n, err
+}
@@ -0,0 +1,169 @@
+package services
+
+import (
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIsBlockedOutboundIP(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"8.8.8.8", false},
+		{"2001:4860:4860::8888", false},
+		{"93.184.216.34", false},
+
+		{"127.0.0.1", true},
+		{"10.1.2.3", true},
+		{"172.16.0.1", true},
+		{"192.168.1.1", true},
+		{"169.254.169.254", true}, // Cloud metadata
+		{"100.100.100.200", true}, // Alibaba Cloud metadata (carrier-grade NAT)
+		{"0.0.0.0", true},
+		{"0.1.2.3", true},
+		{"224.0.0.1", true},
+		{"255.255.255.255", true},
+		{"198.51.100.7", true},
+		{"::", true},
+		{"::1", true},
+		{"fe80::1", true},
+		{"fc00::1", true},
+		{"2001:db8::1", true},
+
+		// IPv4-mapped IPv6 is checked as IPv4
+		{"::ffff:127.0.0.1", true},
+		{"::ffff:169.254.169.254", true},
+		{"::ffff:10.0.0.1", true},
+		{"::ffff:8.8.8.8", false},
+
+		// NAT64 may embed any IPv4 address
+		{"64:ff9b::a9fe:a9fe", true},
+		{"64:ff9b::808:808", true},
+		{"64:ff9b:1::1", true},
+	}
+
+	for _, tt := range tests {
+		ip := net.ParseIP(tt.ip)
+		if ip == nil {
+			t.Fatalf("bad test IP %q", tt.ip)
+		}
+		if got := IsBlockedOutboundIP(ip); got != tt.want {
+			t.Errorf("IsBlockedOutboundIP(%s) = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+}
+
+func TestOutboundValidateURL(t *testing.T) {
+	t.Setenv("OUTBOUND_ALLOWED_SCHEMES", "")
+	t.Setenv("OUTBOUND_ALLOWED_PORTS", "")
+	t.Setenv("OUTBOUND_ALLOW_PRIVATE_NETWORKS", "")
+	c := NewOutboundClient(time.Second)
+
+	tests := []struct {
+		url  string
+		want error
+	}{
+		{"https://example.com/postback?id=1", nil},
+		{"http://example.com", nil},
+		{"HTTPS://Example.com.", nil},
+		{"https://example.com:8443/hook", nil},
+		{"http://example.com:8080/hook", nil},
+		{"http://8.8.8.8/hook", nil},
+
+		{"ftp://example.com/file", ErrOutboundScheme},
+		{"file:///etc/passwd", ErrOutboundScheme},
+		{"gopher://example.com", ErrOutboundScheme},
+		{"//example.com/hook", ErrOutboundScheme},
+		{"http://example.com:22/", ErrOutboundPort},
+		{"https://example.com:6379", ErrOutboundPort},
+		{"http://example.com:443", nil}, // Allowed port, whatever the scheme
+		{"http:///hook", ErrOutboundDestination},
+		{"http://127.0.0.1/", ErrOutboundDestination},
+		{"http://169.254.169.254/latest/meta-data", ErrOutboundDestination},
+		{"http://[::1]:8080/", ErrOutboundDestination},
+		{"http://[::ffff:10.0.0.1]/", ErrOutboundDestination},
+	}
+
+	for _, tt := range tests {
+		err := c.ValidateURL(tt.url, "")
+		if tt.want == nil {
+			if err != nil {
+				t.Errorf("ValidateURL(%q) = %v, want nil", tt.url, err)
+			}
+			continue
+		}
+		if !errors.Is(err, tt.want) {
+			t.Errorf("ValidateURL(%q) = %v, want %v", tt.url, err, tt.want)
+		}
+		if !IsOutboundPolicyError(err) {
+			t.Errorf("IsOutboundPolicyError(%v) = false", err)
+		}
+	}
+
+	// Local development may reach private networks, never other ports
+	t.Setenv("OUTBOUND_ALLOW_PRIVATE_NETWORKS", "true")
+	dev := NewOutboundClient(time.Second)
+	if err := dev.ValidateURL("http://127.0.0.1:8080/", ""); err != nil {
+		t.Errorf("private network refused in development: %v", err)
+	}
+	if err := dev.ValidateURL("http://127.0.0.1:5432/", ""); !errors.Is(err, ErrOutboundPort) {
+		t.Errorf("port check skipped in development: %v", err)
+	}
+}
+
+func TestOutboundCheckConnection(t *testing.T) {
+	t.Setenv("OUTBOUND_ALLOWED_PORTS", "")
+	t.Setenv("OUTBOUND_ALLOW_PRIVATE_NETWORKS", "")
+	c := NewOutboundClient(time.Second)
+
+	tests := []struct {
+		address string
+		want    error
+	}{
+		{"93.184.216.34:443", nil},
+		{"[2606:2800:220:1:248:1893:25c8:1946]:80", nil},
+		{"10.0.0.1:443", ErrOutboundDestination},
+		{"[::ffff:127.0.0.1]:443", ErrOutboundDestination},
+		{"93.184.216.34:25", ErrOutboundPort},
+	}
+
+	for _, tt := range tests {
+		err := c.checkConnection("tcp", tt.address, nil)
+		if (tt.want == nil && err != nil) || (tt.want != nil && !errors.Is(err, tt.want)) {
+			t.Errorf("checkConnection(%s) = %v, want %v", tt.address, err, tt.want)
+		}
+	}
+}
+
+func TestLimitedBody(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		limit   int64
+		want    string
+		wantErr error
+	}{
+		{"under the limit", "hello", 10, "hello", nil},
+		{"exactly the limit", "hello", 5, "hello", nil},
+		{"over the limit", "hello world", 5, "hello", ErrOutboundResponseTooLarge},
+		{"empty", "", 5, "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := &limitedBody{ReadCloser: io.NopCloser(strings.NewReader(tt.body)), remaining: tt.limit}
+			got, err := io.ReadAll(body)
+			if string(got) != tt.want {
+				t.Errorf("read %q, want %q", got, tt.want)
+			}
+			if err != tt.wantErr {
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,304 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/aljapah/afftok-backend-prod/internal/cache"
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/aljapah/afftok-backend-prod/pkg/utils"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ============================================
+// PLATFORM WEBHOOK SERVICE
+// ============================================
+
+// PlatformWebhookService verifies order webhooks from e-commerce platforms
+// (Shopify, Salla, Zid) against per-advertiser secrets and drops replays
+type PlatformWebhookService struct {
+	db *gorm.DB
+}
+
+// NewPlatformWebhookService creates a new platform webhook service
+func NewPlatformWebhookService(db *gorm.DB) *PlatformWebhookService {
+	return &PlatformWebhookService{db: db}
+}
+
+// Signature headers
+const (
+	ShopifyHmacHeader          = "X-Shopify-Hmac-Sha256"
+	ShopifyWebhookIDHeader     = "X-Shopify-Webhook-Id"
+	SallaSignatureHeader       = "X-Salla-Signature"
+	SallaSecurityStrategy      = "X-Salla-Security-Strategy"
+	ZidSignatureHeader         = "X-Zid-Signature"
+	platformWebhookReplayTTL   = 7 * 24 * time.Hour
+	platformWebhookReplayKey   = "platform_webhook:delivery:"
+	defaultSecretRotationGrace = 24 * time.Hour
+)
+
+// Platform webhook errors
+var (
+	ErrUnsupportedWebhookPlatform = errors.New("unsupported webhook platform")
+	ErrWebhookSecretNotConfigured = errors.New("webhook secret not configured")
+	ErrWebhookSignatureMissing    = errors.New("webhook signature missing")
+	ErrWebhookSignatureInvalid    = errors.New("webhook signature invalid")
+)
+
+// PlatformWebhookMetrics tracks webhook verification outcomes
+type PlatformWebhookMetrics struct {
+	Verified         int64
+	InvalidSignature int64
+	MissingSecret    int64
+	Replays          int64
+}
+
+var platformWebhookMetrics = &PlatformWebhookMetrics{}
+
+// GetPlatformWebhookMetrics returns platform webhook metrics
+func GetPlatformWebhookMetrics() *PlatformWebhookMetrics {
+	return &PlatformWebhookMetrics{
+		Verified:         atomic.LoadInt64(&platformWebhookMetrics.Verified),
+		InvalidSignature: atomic.LoadInt64(&platformWebhookMetrics.InvalidSignature),
+		MissingSecret:    atomic.LoadInt64(&platformWebhookMetrics.MissingSecret),
+		Replays:          atomic.LoadInt64(&platformWebhookMetrics.Replays),
+	}
+}
+
+// ============================================
+// SECRET MANAGEMENT
+// ============================================
+
+// SetSecret registers or rotates an advertiser's secret for a platform. An
+// empty secret generates a random one. On rotation the old secret stays valid
+// for the grace period (24h if zero). Returns the plaintext secret.
+func (s *PlatformWebhookService) SetSecret(advertiserID uuid.UUID, platform, secret string, grace time.Duration) (*models.PlatformWebhookSecret, string, error) {
+	if !models.IsValidWebhookPlatform(platform) {
+		return nil, "", ErrUnsupportedWebhookPlatform
+	}
+
+	if secret == "" {
+		buf := make([]byte, 32)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, "", err
+		}
+		secret = hex.EncodeToString(buf)
+	}
+	if len(secret) < 16 {
+		return nil, "", fmt.Errorf("secret must be at least 16 characters")
+	}
+	if grace <= 0 {
+		grace = defaultSecretRotationGrace
+	}
+
+	encrypted, err := utils.EncryptSecret(secret)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to encrypt secret: %w", err)
+	}
+
+	now := time.Now().UTC()
+	var record models.PlatformWebhookSecret
+	err = s.db.Where("advertiser_id = ? AND platform = ?", advertiserID, platform).First(&record).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		record = models.PlatformWebhookSecret{
+			ID:              uuid.New(),
+			AdvertiserID:    advertiserID,
+			Platform:        platform,
+			SecretEncrypted: encrypted,
+			SecretHint:      secretHint(secret),
+		}
+		if err := s.db.Create(&record).Error; err != nil {
+			return nil, "", err
+		}
+		return &record, secret, nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	validUntil := now.Add(grace)
+	record.PreviousSecretEncrypted = record.SecretEncrypted
+	record.PreviousValidUntil = &validUntil
+	record.SecretEncrypted = encrypted
+	record.SecretHint = secretHint(secret)
+	record.RotatedAt = &now
+	if err := s.db.Save(&record).Error; err != nil {
+		return nil, "", err
+	}
+	return &record, secret, nil
+}
+
+// DeleteSecret removes an advertiser's secret; that platform's webhooks are
+// rejected until a new one is registered
+func (s *PlatformWebhookService) DeleteSecret(advertiserID uuid.UUID, platform string) error {
+	result := s.db.Where("advertiser_id = ? AND platform = ?", advertiserID, platform).
+		Delete(&models.PlatformWebhookSecret{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrWebhookSecretNotConfigured
+	}
+	return nil
+}
+
+// ListSecrets returns an advertiser's registered secrets (hints only)
+func (s *PlatformWebhookService) ListSecrets(advertiserID uuid.UUID) ([]models.PlatformWebhookSecret, error) {
+	var secrets []models.PlatformWebhookSecret
+	err := s.db.Where("advertiser_id = ?", advertiserID).Order("platform ASC").Find(&secrets).Error
+	return secrets, err
+}
+
+// secretHint returns the last 4 characters of a secret
+func secretHint(secret string) string {
+	if len(secret) <= 4 {
+		return ""
+	}
+	return secret[len(secret)-4:]
+}
+
+// ============================================
+// VERIFICATION
+// ============================================
+
+// VerifySignature checks a webhook body against the advertiser's secret using
+// the platform's own scheme:
+//   - Shopify: X-Shopify-Hmac-Sha256 = base64(HMAC-SHA256(secret, body))
+//   - Salla:   X-Salla-Signature = hex(HMAC-SHA256(secret, body)), or with
+//     X-Salla-Security-Strategy: Token, Authorization: Bearer <secret>
+//   - Zid:     X-Zid-Signature = hex(HMAC-SHA256(secret, body))
+func (s *PlatformWebhookService) VerifySignature(advertiserID uuid.UUID, platform string, header http.Header, body []byte) error {
+	if !models.IsValidWebhookPlatform(platform) {
+		return ErrUnsupportedWebhookPlatform
+	}
+
+	var record models.PlatformWebhookSecret
+	if err := s.db.Where("advertiser_id = ? AND platform = ?", advertiserID, platform).First(&record).Error; err != nil {
+		atomic.AddInt64(&platformWebhookMetrics.MissingSecret, 1)
+		return ErrWebhookSecretNotConfigured
+	}
+
+	now := time.Now().UTC()
+	encrypted := []string{record.SecretEncrypted}
+	if record.HasValidPreviousSecret(now) {
+		encrypted = append(encrypted, record.PreviousSecretEncrypted)
+	}
+
+	result := ErrWebhookSignatureInvalid
+	for _, value := range encrypted {
+		secret, err := utils.DecryptSecret(value)
+		if err != nil {
+			continue
+		}
+		result = verifyPlatformSignature(platform, secret, header, body)
+		if result == nil || result == ErrWebhookSignatureMissing {
+			break
+		}
+	}
+
+	if result != nil {
+		atomic.AddInt64(&platformWebhookMetrics.InvalidSignature, 1)
+		return result
+	}
+
+	atomic.AddInt64(&platformWebhookMetrics.Verified, 1)
+	s.db.Model(&record).UpdateColumn("last_verified_at", now)
+	return nil
+}
+
+// verifyPlatformSignature applies one platform's signature scheme
+func verifyPlatformSignature(platform, secret string, header http.Header, body []byte) error {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	sum := mac.Sum(nil)
+
+	switch platform {
+	case models.WebhookPlatformShopify:
+		signature := header.Get(ShopifyHmacHeader)
+		if signature == "" {
+			return ErrWebhookSignatureMissing
+		}
+		expected := base64.StdEncoding.EncodeToString(sum)
+		if !hmac.Equal([]byte(expected), []byte(signature)) {
+			return ErrWebhookSignatureInvalid
+		}
+		return nil
+
+	case models.WebhookPlatformSalla:
+		if strings.EqualFold(header.Get(SallaSecurityStrategy), "token") {
+			token := strings.TrimSpace(strings.TrimPrefix(header.Get("Authorization"), "Bearer "))
+			if token == "" {
+				return ErrWebhookSignatureMissing
+			}
+			if !hmac.Equal([]byte(token), []byte(secret)) {
+				return ErrWebhookSignatureInvalid
+			}
+			return nil
+		}
+		return verifyHexSignature(header.Get(SallaSignatureHeader), sum)
+
+	case models.WebhookPlatformZid:
+		return verifyHexSignature(header.Get(ZidSignatureHeader), sum)
+	}
+
+	return ErrUnsupportedWebhookPlatform
+}
+
+// verifyHexSignature compares a hex HMAC header (optionally "sha256=" prefixed)
+func verifyHexSignature(signature string, sum []byte) error {
+	signature = strings.TrimPrefix(strings.TrimSpace(signature), "sha256=")
+	if signature == "" {
+		return ErrWebhookSignatureMissing
+	}
+	expected := hex.EncodeToString(sum)
+	if !hmac.Equal([]byte(expected), []byte(strings.ToLower(signature))) {
+		return ErrWebhookSignatureInvalid
+	}
+	return nil
+}
+
+// ============================================
+// REPLAY PROTECTION
+// ============================================
+
+// ClaimDelivery records a platform delivery (event or order ID) and returns
+// false if it was already seen. Redis errors fail open; conversions are still
+// de-duplicated by external ID in the database.
+func (s *PlatformWebhookService) ClaimDelivery(platform string, advertiserID uuid.UUID, deliveryID string) bool {
+	if deliveryID == "" {
+		return true
+	}
+
+	first, err := cache.SetNX(context.Background(), replayKey(platform, advertiserID, deliveryID), "1", platformWebhookReplayTTL)
+	if err != nil {
+		return true
+	}
+	if !first {
+		atomic.AddInt64(&platformWebhookMetrics.Replays, 1)
+	}
+	return first
+}
+
+// ReleaseDelivery forgets a claimed delivery so the platform's retry is
+// accepted, used when processing fails after the claim
+func (s *PlatformWebhookService) ReleaseDelivery(platform string, advertiserID uuid.UUID, deliveryID string) {
+	if deliveryID == "" {
+		return
+	}
+	cache.Delete(context.Background(), replayKey(platform, advertiserID, deliveryID))
+}
+
+func replayKey(platform string, advertiserID uuid.UUID, deliveryID string) string {
+	return platformWebhookReplayKey + platform + ":" + advertiserID.String() + ":" + deliveryID
+}
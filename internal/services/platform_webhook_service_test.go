@@ -0,0 +1,77 @@
+package services
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+)
+
+func TestVerifyPlatformSignature(t *testing.T) {
+	const secret = "whsec_test"
+	body := []byte(`{"id":1001,"total_price":"49.90"}`)
+
+	// HMAC-SHA256(secret, body), computed independently
+	const sumHex = "1ba301a0ad3608ebceda37d0dfc8f027423ea8d1d0c3761faa22ce79dc91c4e8"
+	const sumBase64 = "G6MBoK02COvO2jfQ38jwJ0I+qNHQw3YfqiLOedyRxOg="
+
+	headers := func(kv ...string) http.Header {
+		h := http.Header{}
+		for i := 0; i+1 < len(kv); i += 2 {
+			h.Set(kv[i], kv[i+1])
+		}
+		return h
+	}
+
+	tests := []struct {
+		name     string
+		platform string
+		secret   string
+		header   http.Header
+		body     []byte
+		want     error
+	}{
+		{"shopify valid", models.WebhookPlatformShopify, secret, headers(ShopifyHmacHeader, sumBase64), body, nil},
+		{"shopify hex is not base64", models.WebhookPlatformShopify, secret, headers(ShopifyHmacHeader, sumHex), body, ErrWebhookSignatureInvalid},
+		{"shopify missing", models.WebhookPlatformShopify, secret, headers(), body, ErrWebhookSignatureMissing},
+		{"shopify wrong secret", models.WebhookPlatformShopify, "other", headers(ShopifyHmacHeader, sumBase64), body, ErrWebhookSignatureInvalid},
+		{"shopify body changed", models.WebhookPlatformShopify, secret, headers(ShopifyHmacHeader, sumBase64), []byte(`{"id":1002}`), ErrWebhookSignatureInvalid},
+
+		{"salla valid", models.WebhookPlatformSalla, secret, headers(SallaSignatureHeader, sumHex), body, nil},
+		{"salla upper-case hex", models.WebhookPlatformSalla, secret, headers(SallaSignatureHeader, "1BA301A0AD3608EBCEDA37D0DFC8F027423EA8D1D0C3761FAA22CE79DC91C4E8"), body, nil},
+		{"salla sha256= prefix", models.WebhookPlatformSalla, secret, headers(SallaSignatureHeader, "sha256="+sumHex), body, nil},
+		{"salla missing", models.WebhookPlatformSalla, secret, headers(), body, ErrWebhookSignatureMissing},
+		{"salla invalid", models.WebhookPlatformSalla, secret, headers(SallaSignatureHeader, "00"+sumHex[2:]), body, ErrWebhookSignatureInvalid},
+		{"salla token", models.WebhookPlatformSalla, secret, headers(SallaSecurityStrategy, "Token", "Authorization", "Bearer "+secret), body, nil},
+		{"salla token ignores signature header", models.WebhookPlatformSalla, secret, headers(SallaSecurityStrategy, "token", SallaSignatureHeader, sumHex), body, ErrWebhookSignatureMissing},
+		{"salla wrong token", models.WebhookPlatformSalla, secret, headers(SallaSecurityStrategy, "token", "Authorization", "Bearer nope"), body, ErrWebhookSignatureInvalid},
+
+		{"zid valid", models.WebhookPlatformZid, secret, headers(ZidSignatureHeader, sumHex), body, nil},
+		{"zid missing", models.WebhookPlatformZid, secret, headers(SallaSignatureHeader, sumHex), body, ErrWebhookSignatureMissing},
+		{"zid invalid", models.WebhookPlatformZid, secret, headers(ZidSignatureHeader, sumBase64), body, ErrWebhookSignatureInvalid},
+
+		{"unsupported platform", "woocommerce", secret, headers(), body, ErrUnsupportedWebhookPlatform},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := verifyPlatformSignature(tt.platform, tt.secret, tt.header, tt.body); got != tt.want {
+				t.Errorf("verifyPlatformSignature = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSecretHint(t *testing.T) {
+	tests := map[string]string{
+		"":           "",
+		"abcd":       "",
+		"abcde":      "bcde",
+		"whsec_1234": "1234",
+	}
+	for secret, want := range tests {
+		if got := secretHint(secret); got != want {
+			t.Errorf("secretHint(%q) = %q, want %q", secret, got, want)
+		}
+	}
+}
@@ -33,10 +33,12 @@ type PostbackQueueItem struct {
 	Timestamp    time.Time              `json:"timestamp"`
 	Attempts     int                    `json:"attempts"`
 	LastAttempt  *time.Time             `json:"last_attempt,omitempty"`
+	NextAttempt  *time.Time             `json:"next_attempt,omitempty"` // Set while waiting for a retry
 	LastError    string                 `json:"last_error,omitempty"`
 	StatusCode   int                    `json:"status_code,omitempty"`
 	Response     string                 `json:"response,omitempty"`
 	Metadata     map[string]interface{} `json:"metadata,omitempty"`
+	WALID        string                 `json:"wal_id,omitempty"` // WAL entry acked once sent
 }
 
 // PostbackQueueStatus represents item status
@@ -49,6 +51,11 @@ const (
 	PostbackStatusDLQ       PostbackQueueStatus = "dlq"
 )
 
+// PostbackResultHandler is notified of each attempt of the postbacks whose
+// "source" metadata it was registered for: sent, failed (retry scheduled) or
+// dlq. It runs on the queue worker, so it should be quick.
+type PostbackResultHandler func(item *PostbackQueueItem, status PostbackQueueStatus)
+
 // ============================================
 // POSTBACK QUEUE SERVICE
 // ============================================
@@ -58,10 +65,11 @@ type PostbackQueueService struct {
 	mu            sync.RWMutex
 	walService    *WALService
 	observability *ObservabilityService
-	httpClient    *http.Client
+	httpClient    *OutboundClient
 	
 	// Queues
 	pendingQueue  []*PostbackQueueItem
+	retryQueue    []*PostbackQueueItem // Waiting out their backoff
 	dlq           []*PostbackQueueItem
 	
 	// Configuration
@@ -70,9 +78,13 @@ type PostbackQueueService struct {
 	maxRetryMs    int
 	requestTimeout time.Duration
 	
+	// Result handlers by metadata source
+	resultHandlers map[string]PostbackResultHandler
+	
 	// State
 	isRunning     bool
 	stopChan      chan struct{}
+	stopOnce      sync.Once
 	wg            sync.WaitGroup
 	
 	// Metrics
@@ -105,15 +117,15 @@ func NewPostbackQueueService(config PostbackQueueConfig) *PostbackQueueService {
 	return &PostbackQueueService{
 		walService:     GetWALService(),
 		observability:  NewObservabilityService(),
-		httpClient: &http.Client{
-			Timeout: config.RequestTimeout,
-		},
+		httpClient:     GetOutboundClient(),
 		pendingQueue:   make([]*PostbackQueueItem, 0),
+		retryQueue:     make([]*PostbackQueueItem, 0),
 		dlq:            make([]*PostbackQueueItem, 0),
 		maxRetries:     config.MaxRetries,
 		baseRetryMs:    config.BaseRetryMs,
 		maxRetryMs:     config.MaxRetryMs,
 		requestTimeout: config.RequestTimeout,
+		resultHandlers: make(map[string]PostbackResultHandler),
 		stopChan:       make(chan struct{}),
 	}
 }
@@ -139,17 +151,7 @@ func (s *PostbackQueueService) Enqueue(item *PostbackQueueItem) error {
 	}
 
 	// Write to WAL first
-	if s.walService != nil {
-		data := map[string]interface{}{
-			"id":            item.ID,
-			"url":           item.URL,
-			"method":        item.Method,
-			"headers":       item.Headers,
-			"body":          item.Body,
-			"advertiser_id": item.AdvertiserID,
-		}
-		s.walService.Append(WALEventPostback, item.TenantID, data)
-	}
+	s.appendToWAL(item)
 
 	// Add to pending queue
 	s.pendingQueue = append(s.pendingQueue, item)
@@ -182,6 +184,83 @@ func (s *PostbackQueueService) EnqueuePostback(
 	return s.Enqueue(item)
 }
 
+// OnResult registers the result handler for postbacks enqueued with the
+// given "source" metadata
+func (s *PostbackQueueService) OnResult(source string, handler PostbackResultHandler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.resultHandlers[source] = handler
+}
+
+// notifyResult calls the result handler registered for the item's source
+func (s *PostbackQueueService) notifyResult(item *PostbackQueueItem, status PostbackQueueStatus) {
+	source, _ := item.Metadata["source"].(string)
+	if source == "" {
+		return
+	}
+
+	s.mu.RLock()
+	handler := s.resultHandlers[source]
+	s.mu.RUnlock()
+
+	if handler != nil {
+		handler(item, status)
+	}
+}
+
+// appendToWAL records the item in the WAL, where crash recovery replays it
+// (RestorePostbackEvent) until it is sent or moved to the DLQ
+func (s *PostbackQueueService) appendToWAL(item *PostbackQueueItem) bool {
+	if s.walService == nil {
+		return false
+	}
+	data := map[string]interface{}{
+		"id":            item.ID,
+		"tenant_id":     item.TenantID,
+		"url":           item.URL,
+		"method":        item.Method,
+		"headers":       item.Headers,
+		"body":          item.Body,
+		"advertiser_id": item.AdvertiserID,
+		"metadata":      item.Metadata,
+	}
+	entry, err := s.walService.Append(WALEventPostback, item.TenantID, data)
+	if err != nil {
+		return false
+	}
+	item.WALID = entry.ID
+	return true
+}
+
+// RestorePostbackEvent re-queues a postback replayed from the WAL. It keeps
+// the postback's ID and metadata, so result handlers still match it up.
+func (s *PostbackQueueService) RestorePostbackEvent(data map[string]interface{}) error {
+	item := &PostbackQueueItem{}
+	item.ID, _ = data["id"].(string)
+	item.TenantID, _ = data["tenant_id"].(string)
+	item.URL, _ = data["url"].(string)
+	item.Method, _ = data["method"].(string)
+	item.Body, _ = data["body"].(string)
+	item.AdvertiserID, _ = data["advertiser_id"].(string)
+	if item.URL == "" {
+		return fmt.Errorf("missing url")
+	}
+
+	if headers, ok := data["headers"].(map[string]interface{}); ok {
+		item.Headers = make(map[string]string, len(headers))
+		for key, value := range headers {
+			if value, ok := value.(string); ok {
+				item.Headers[key] = value
+			}
+		}
+	}
+	if metadata, ok := data["metadata"].(map[string]interface{}); ok {
+		item.Metadata = metadata
+	}
+
+	return s.Enqueue(item)
+}
+
 // persistToRedis persists item to Redis
 func (s *PostbackQueueService) persistToRedis(item *PostbackQueueItem) {
 	ctx := context.Background()
@@ -202,6 +281,7 @@ func (s *PostbackQueueService) persistToRedis(item *PostbackQueueItem) {
 // ProcessQueue processes pending postbacks
 func (s *PostbackQueueService) ProcessQueue() {
 	s.mu.Lock()
+	s.promoteRetries(time.Now())
 	if len(s.pendingQueue) == 0 {
 		s.mu.Unlock()
 		return
@@ -235,6 +315,11 @@ func (s *PostbackQueueService) processItem(item *PostbackQueueItem) {
 
 	if err != nil {
 		item.LastError = err.Error()
+		if IsOutboundPolicyError(err) {
+			// The destination is refused, retrying won't help
+			s.moveToDLQ(item)
+			return
+		}
 		s.handleFailure(item)
 		return
 	}
@@ -243,13 +328,14 @@ func (s *PostbackQueueService) processItem(item *PostbackQueueItem) {
 	atomic.AddInt64(&s.totalSent, 1)
 	
 	// Mark as processed in WAL
-	if s.walService != nil {
-		s.walService.MarkProcessed(item.ID)
+	if s.walService != nil && item.WALID != "" {
+		s.walService.MarkProcessed(item.WALID)
 	}
 
 	// Remove from Redis
 	ctx := context.Background()
 	cache.Delete(ctx, fmt.Sprintf("postback_queue:%s", item.ID))
+	s.notifyResult(item, PostbackStatusSent)
 
 	s.observability.Log(LogEvent{
 		Category: LogCategoryPostbackEvent,
@@ -273,7 +359,9 @@ func (s *PostbackQueueService) sendPostback(item *PostbackQueueItem) (int, strin
 		bodyReader = bytes.NewBufferString(item.Body)
 	}
 
-	req, err := http.NewRequest(item.Method, item.URL, bodyReader)
+	ctx, cancel := context.WithTimeout(WithOutboundTenant(context.Background(), item.TenantID), s.requestTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, item.Method, item.URL, bodyReader)
 	if err != nil {
 		return 0, "", fmt.Errorf("failed to create request: %w", err)
 	}
@@ -317,6 +405,7 @@ func (s *PostbackQueueService) handleFailure(item *PostbackQueueItem) {
 
 	// Calculate backoff
 	backoffMs := s.calculateBackoff(item.Attempts)
+	s.notifyResult(item, PostbackStatusFailed)
 
 	s.observability.Log(LogEvent{
 		Category: LogCategoryPostbackEvent,
@@ -331,12 +420,30 @@ func (s *PostbackQueueService) handleFailure(item *PostbackQueueItem) {
 	})
 
 	// Schedule retry
-	go func() {
-		time.Sleep(time.Duration(backoffMs) * time.Millisecond)
-		s.mu.Lock()
-		s.pendingQueue = append(s.pendingQueue, item)
-		s.mu.Unlock()
-	}()
+	next := time.Now().Add(time.Duration(backoffMs) * time.Millisecond)
+	item.NextAttempt = &next
+	s.mu.Lock()
+	s.retryQueue = append(s.retryQueue, item)
+	s.mu.Unlock()
+	s.persistToRedis(item)
+}
+
+// promoteRetries moves the retries due by now to the pending queue. The
+// caller holds s.mu; a zero now promotes all of them.
+func (s *PostbackQueueService) promoteRetries(now time.Time) {
+	waiting := s.retryQueue[:0]
+	for _, item := range s.retryQueue {
+		if now.IsZero() || item.NextAttempt == nil || !item.NextAttempt.After(now) {
+			item.NextAttempt = nil
+			s.pendingQueue = append(s.pendingQueue, item)
+			continue
+		}
+		waiting = append(waiting, item)
+	}
+	for i := len(waiting); i < len(s.retryQueue); i++ {
+		s.retryQueue[i] = nil
+	}
+	s.retryQueue = waiting
 }
 
 // moveToDLQ moves item to Dead Letter Queue
@@ -348,11 +455,16 @@ func (s *PostbackQueueService) moveToDLQ(item *PostbackQueueItem) {
 	atomic.AddInt64(&s.totalFailed, 1)
 	atomic.AddInt64(&s.totalDLQ, 1)
 
-	// Persist to Redis DLQ
+	// Persist to Redis DLQ, which replaces the WAL entry as its record
 	ctx := context.Background()
 	key := fmt.Sprintf("postback_dlq:%s", item.ID)
 	data, _ := json.Marshal(item)
 	cache.Set(ctx, key, string(data), 7*24*time.Hour) // 7 days
+	cache.Delete(ctx, fmt.Sprintf("postback_queue:%s", item.ID))
+	if s.walService != nil && item.WALID != "" {
+		s.walService.MarkProcessed(item.WALID)
+	}
+	s.notifyResult(item, PostbackStatusDLQ)
 
 	s.observability.Log(LogEvent{
 		Category: LogCategoryPostbackEvent,
@@ -487,9 +599,48 @@ func (s *PostbackQueueService) Start() {
 
 // Stop stops the postback queue
 func (s *PostbackQueueService) Stop() {
-	s.isRunning = false
-	close(s.stopChan)
-	s.wg.Wait()
+	s.stopOnce.Do(func() {
+		s.isRunning = false
+		close(s.stopChan)
+		s.wg.Wait()
+	})
+}
+
+// Drain stops the workers and sends pending postbacks, including those
+// waiting for a retry, until the queue is empty or ctx expires. What's left
+// stays in the WAL (written on enqueue, or now if that failed) for crash
+// recovery to replay on the next boot, and in Redis.
+func (s *PostbackQueueService) Drain(ctx context.Context) (int, error) {
+	s.Stop()
+
+	// One last attempt for the retries instead of waiting out their backoff
+	s.mu.Lock()
+	s.promoteRetries(time.Time{})
+	s.mu.Unlock()
+
+	for s.GetPendingCount() > 0 && ctx.Err() == nil {
+		s.ProcessQueue()
+	}
+
+	s.mu.Lock()
+	remaining := append(append([]*PostbackQueueItem{}, s.pendingQueue...), s.retryQueue...)
+	s.mu.Unlock()
+
+	notLogged := 0
+	for _, item := range remaining {
+		if item.WALID == "" && !s.appendToWAL(item) {
+			notLogged++
+		}
+		s.persistToRedis(item)
+	}
+
+	if len(remaining) > 0 {
+		fmt.Printf("[PostbackQueue] %d postbacks left unsent, kept in the WAL for replay on the next boot\n", len(remaining)-notLogged)
+		if notLogged > 0 {
+			fmt.Printf("[PostbackQueue] ⚠️ %d postbacks couldn't be written to the WAL, only kept in Redis (postback_queue:<id>)\n", notLogged)
+		}
+	}
+	return len(remaining), nil
 }
 
 // processWorker continuously processes the queue
@@ -541,11 +692,13 @@ func (s *PostbackQueueService) syncFromRedis() {
 func (s *PostbackQueueService) GetStats() map[string]interface{} {
 	s.mu.RLock()
 	pendingCount := len(s.pendingQueue)
+	retryCount := len(s.retryQueue)
 	dlqCount := len(s.dlq)
 	s.mu.RUnlock()
 
 	return map[string]interface{}{
 		"pending_count":  pendingCount,
+		"retry_count":    retryCount,
 		"dlq_count":      dlqCount,
 		"total_queued":   atomic.LoadInt64(&s.totalQueued),
 		"total_sent":     atomic.LoadInt64(&s.totalSent),
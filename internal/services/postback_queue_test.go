@@ -0,0 +1,212 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestPostbackQueue builds a queue with retries far enough out that only
+// Drain sends them, and an outbound client that may call httptest servers
+func newTestPostbackQueue(t *testing.T, wal *WALService, server *httptest.Server) *PostbackQueueService {
+	t.Helper()
+	if server != nil {
+		u, _ := url.Parse(server.URL)
+		t.Setenv("OUTBOUND_ALLOW_PRIVATE_NETWORKS", "true")
+		t.Setenv("OUTBOUND_ALLOWED_PORTS", u.Port())
+	}
+	return &PostbackQueueService{
+		walService:     wal,
+		observability:  NewObservabilityService(),
+		httpClient:     NewOutboundClient(time.Second),
+		maxRetries:     5,
+		baseRetryMs:    60000,
+		maxRetryMs:     60000,
+		requestTimeout: time.Second,
+		resultHandlers: make(map[string]PostbackResultHandler),
+		stopChan:       make(chan struct{}),
+	}
+}
+
+// newStatusServer answers with the status stored in status
+func newStatusServer(t *testing.T, status *int32, hits *int32) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(hits, 1)
+		w.WriteHeader(int(atomic.LoadInt32(status)))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestPostbackQueuePromoteRetries(t *testing.T) {
+	now := time.Now()
+	past, future := now.Add(-time.Second), now.Add(time.Minute)
+	due := &PostbackQueueItem{ID: "due", NextAttempt: &past}
+	exact := &PostbackQueueItem{ID: "exact", NextAttempt: &now}
+	later := &PostbackQueueItem{ID: "later", NextAttempt: &future}
+	unscheduled := &PostbackQueueItem{ID: "unscheduled"}
+
+	q := &PostbackQueueService{retryQueue: []*PostbackQueueItem{due, later, exact, unscheduled}}
+	q.promoteRetries(now)
+
+	if ids := queueIDs(q.pendingQueue); ids != "due,exact,unscheduled" {
+		t.Errorf("promoted = %s, want due,exact,unscheduled", ids)
+	}
+	if ids := queueIDs(q.retryQueue); ids != "later" {
+		t.Errorf("still waiting = %s, want later", ids)
+	}
+	if due.NextAttempt != nil {
+		t.Error("promoted item kept its retry time")
+	}
+
+	// A zero time promotes everything, as Drain does
+	q.promoteRetries(time.Time{})
+	if len(q.retryQueue) != 0 || queueIDs(q.pendingQueue) != "due,exact,unscheduled,later" {
+		t.Errorf("after flush: pending %s, waiting %d", queueIDs(q.pendingQueue), len(q.retryQueue))
+	}
+}
+
+func queueIDs(items []*PostbackQueueItem) string {
+	ids := ""
+	for i, item := range items {
+		if i > 0 {
+			ids += ","
+		}
+		ids += item.ID
+	}
+	return ids
+}
+
+func TestPostbackQueueDrainSendsRetries(t *testing.T) {
+	status, hits := int32(http.StatusServiceUnavailable), int32(0)
+	server := newStatusServer(t, &status, &hits)
+	wal := newTestWALService(t, t.TempDir())
+	defer wal.Stop()
+	q := newTestPostbackQueue(t, wal, server)
+
+	var results []PostbackQueueStatus
+	q.OnResult("test", func(item *PostbackQueueItem, status PostbackQueueStatus) {
+		results = append(results, status)
+	})
+
+	q.Enqueue(&PostbackQueueItem{URL: server.URL, Metadata: map[string]interface{}{"source": "test"}})
+	q.ProcessQueue()
+
+	// The failure waits out its backoff in the retry queue
+	if q.GetPendingCount() != 0 || q.GetStats()["retry_count"] != 1 {
+		t.Fatalf("after a failure: %+v", q.GetStats())
+	}
+
+	atomic.StoreInt32(&status, http.StatusOK)
+	remaining, err := q.Drain(context.Background())
+	if err != nil || remaining != 0 {
+		t.Fatalf("Drain = %d, %v; want 0, nil", remaining, err)
+	}
+	if atomic.LoadInt32(&hits) != 2 || len(results) != 2 || results[1] != PostbackStatusSent {
+		t.Errorf("hits = %d, results = %v; want 2 attempts ending in sent", hits, results)
+	}
+	if pending, _ := wal.GetPendingEntries(); len(pending) != 0 {
+		t.Errorf("sent postback left %d WAL entries pending", len(pending))
+	}
+}
+
+func TestPostbackQueueDrainKeepsUnsentForReplay(t *testing.T) {
+	status, hits := int32(http.StatusBadGateway), int32(0)
+	server := newStatusServer(t, &status, &hits)
+	dir := t.TempDir()
+	wal := newTestWALService(t, dir)
+
+	q := newTestPostbackQueue(t, nil, server)
+	q.Enqueue(&PostbackQueueItem{
+		ID:       "no-wal",
+		TenantID: "tenant-1",
+		URL:      server.URL + "/pb?x=1",
+		Headers:  map[string]string{"X-Token": "secret"},
+		Body:     `{"payout":"1.00"}`,
+		Metadata: map[string]interface{}{"source": "test", "delivery": "d-1"},
+	})
+	q.ProcessQueue()
+
+	// The WAL wasn't available on enqueue; Drain writes the leftover to it
+	q.walService = wal
+	remaining, _ := q.Drain(context.Background())
+	if remaining != 1 || atomic.LoadInt32(&hits) != 2 {
+		t.Fatalf("Drain left %d after %d attempts, want 1 after 2", remaining, hits)
+	}
+	wal.Stop()
+
+	// Next boot: crash recovery replays it into a fresh queue
+	wal = newTestWALService(t, dir)
+	defer wal.Stop()
+	restored := newTestPostbackQueue(t, wal, server)
+	replayed, failed, err := wal.Replay(func(entry *WALEntry) error {
+		return restored.RestorePostbackEvent(entry.Data)
+	})
+	if err != nil || replayed != 1 || failed != 0 {
+		t.Fatalf("Replay = %d, %d, %v; want 1, 0, nil", replayed, failed, err)
+	}
+
+	if restored.GetPendingCount() != 1 {
+		t.Fatalf("restored queue has %d pending, want 1", restored.GetPendingCount())
+	}
+	item := restored.pendingQueue[0]
+	if item.ID != "no-wal" || item.TenantID != "tenant-1" || item.URL != server.URL+"/pb?x=1" ||
+		item.Method != "POST" || item.Body != `{"payout":"1.00"}` || item.Headers["X-Token"] != "secret" {
+		t.Errorf("restored item = %+v", item)
+	}
+	if item.Metadata["source"] != "test" || item.Metadata["delivery"] != "d-1" {
+		t.Errorf("restored metadata = %v", item.Metadata)
+	}
+
+	// The replayed entry is acked; the re-queued postback has its own
+	pending, _ := wal.GetPendingEntries()
+	if len(pending) != 1 || pending[0].ID != item.WALID {
+		t.Errorf("WAL pending = %d entries, want only the re-queued one", len(pending))
+	}
+}
+
+func TestPostbackQueueDrainWithExpiredContext(t *testing.T) {
+	status, hits := int32(http.StatusOK), int32(0)
+	server := newStatusServer(t, &status, &hits)
+	q := newTestPostbackQueue(t, nil, server)
+	q.Enqueue(&PostbackQueueItem{URL: server.URL})
+	q.Enqueue(&PostbackQueueItem{URL: server.URL})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if remaining, _ := q.Drain(ctx); remaining != 2 || atomic.LoadInt32(&hits) != 0 {
+		t.Errorf("Drain after the deadline = %d left, %d sent; want 2, 0", remaining, hits)
+	}
+}
+
+func TestPostbackQueueRefusedDestinationLeavesWAL(t *testing.T) {
+	wal := newTestWALService(t, t.TempDir())
+	defer wal.Stop()
+	q := newTestPostbackQueue(t, wal, nil)
+
+	q.Enqueue(&PostbackQueueItem{URL: "http://169.254.169.254/latest/meta-data/"})
+	q.ProcessQueue()
+
+	// Refused outright: no retry, and the DLQ replaces the WAL entry
+	if len(q.GetDLQ()) != 1 || q.GetStats()["retry_count"] != 0 {
+		t.Errorf("stats = %+v, want the item in the DLQ", q.GetStats())
+	}
+	if pending, _ := wal.GetPendingEntries(); len(pending) != 0 {
+		t.Errorf("DLQ item left %d WAL entries to replay", len(pending))
+	}
+}
+
+func TestRestorePostbackEventRequiresURL(t *testing.T) {
+	q := newTestPostbackQueue(t, nil, nil)
+	if err := q.RestorePostbackEvent(map[string]interface{}{"id": "x"}); err == nil {
+		t.Error("restored a postback without a URL")
+	}
+	if q.GetPendingCount() != 0 {
+		t.Error("invalid postback was queued")
+	}
+}
@@ -0,0 +1,453 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ============================================
+// PROMOTER POSTBACK SERVICE
+// ============================================
+
+// PromoterPostbackSource marks promoter postbacks in the postback queue
+const PromoterPostbackSource = "promoter_postback"
+
+const (
+	maxPromoterPostbacks        = 20
+	maxDeliveryResponseSnippet  = 500
+	defaultPromoterDeliveryPage = 50
+	maxPromoterDeliveryPage     = 200
+)
+
+// Promoter postback errors
+var (
+	ErrPromoterPostbackNotFound       = errors.New("postback not found")
+	ErrPromoterPostbackLimit          = fmt.Errorf("at most %d postbacks are allowed", maxPromoterPostbacks)
+	ErrPromoterPostbackOfferNotJoined = errors.New("join the offer before adding a postback for it")
+	ErrInvalidPromoterPostback        = errors.New("invalid postback")
+)
+
+// promoterPostbackMacros are the macros a promoter postback URL may use
+var promoterPostbackMacros = map[string]bool{
+	"click_id": true, "conversion_id": true, "external_id": true,
+	"offer_id": true, "user_offer_id": true,
+	"sub1": true, "sub2": true, "sub3": true, "sub4": true, "sub5": true,
+	"payout": true, "amount": true, "currency": true,
+	"status": true, "event": true, "timestamp": true,
+}
+
+// PromoterPostbackInput is a promoter postback as submitted by the promoter
+type PromoterPostbackInput struct {
+	OfferID  *uuid.UUID
+	Name     string
+	URL      string
+	Method   string
+	Events   []string
+	Statuses []string
+	IsActive *bool
+}
+
+// PromoterPostbackService fires promoters' own postbacks (ad trackers and
+// pixels) on their conversions through the postback queue, and keeps a
+// delivery log of every firing
+type PromoterPostbackService struct {
+	db    *gorm.DB
+	queue *PostbackQueueService
+}
+
+var (
+	promoterPostbackInstance *PromoterPostbackService
+	promoterPostbackOnce     sync.Once
+)
+
+// GetPromoterPostbackService returns the global promoter postback service
+func GetPromoterPostbackService(db *gorm.DB) *PromoterPostbackService {
+	promoterPostbackOnce.Do(func() {
+		promoterPostbackInstance = &PromoterPostbackService{
+			db:    db,
+			queue: GetPostbackQueueService(),
+		}
+		promoterPostbackInstance.queue.OnResult(PromoterPostbackSource, promoterPostbackInstance.recordResult)
+	})
+	return promoterPostbackInstance
+}
+
+// ============================================
+// CONFIGURATION
+// ============================================
+
+// List returns a promoter's postbacks, global ones first
+func (s *PromoterPostbackService) List(userID uuid.UUID) ([]models.PromoterPostback, error) {
+	var postbacks []models.PromoterPostback
+	err := s.db.Where("user_id = ?", userID).
+		Order("offer_id IS NOT NULL, created_at").
+		Find(&postbacks).Error
+	return postbacks, err
+}
+
+// Create adds a postback for a promoter
+func (s *PromoterPostbackService) Create(userID uuid.UUID, input PromoterPostbackInput) (*models.PromoterPostback, error) {
+	var count int64
+	if err := s.db.Model(&models.PromoterPostback{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count >= maxPromoterPostbacks {
+		return nil, ErrPromoterPostbackLimit
+	}
+
+	postback := &models.PromoterPostback{ID: uuid.New(), UserID: userID, IsActive: true}
+	if err := s.apply(postback, input); err != nil {
+		return nil, err
+	}
+	if err := s.db.Create(postback).Error; err != nil {
+		return nil, err
+	}
+	return postback, nil
+}
+
+// Update replaces a promoter's postback settings
+func (s *PromoterPostbackService) Update(userID, postbackID uuid.UUID, input PromoterPostbackInput) (*models.PromoterPostback, error) {
+	postback, err := s.get(userID, postbackID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.apply(postback, input); err != nil {
+		return nil, err
+	}
+	postback.UpdatedAt = time.Now().UTC()
+	if err := s.db.Save(postback).Error; err != nil {
+		return nil, err
+	}
+	return postback, nil
+}
+
+// Delete removes a promoter's postback. Its delivery log is kept.
+func (s *PromoterPostbackService) Delete(userID, postbackID uuid.UUID) error {
+	result := s.db.Where("id = ? AND user_id = ?", postbackID, userID).Delete(&models.PromoterPostback{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrPromoterPostbackNotFound
+	}
+	return nil
+}
+
+// get loads a promoter's postback
+func (s *PromoterPostbackService) get(userID, postbackID uuid.UUID) (*models.PromoterPostback, error) {
+	var postback models.PromoterPostback
+	if err := s.db.Where("id = ? AND user_id = ?", postbackID, userID).First(&postback).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPromoterPostbackNotFound
+		}
+		return nil, err
+	}
+	return &postback, nil
+}
+
+// apply validates input and copies it onto a postback
+func (s *PromoterPostbackService) apply(postback *models.PromoterPostback, input PromoterPostbackInput) error {
+	method := strings.ToUpper(strings.TrimSpace(input.Method))
+	if method == "" {
+		method = "GET"
+	}
+	if method != "GET" && method != "POST" {
+		return fmt.Errorf("%w: method must be GET or POST", ErrInvalidPromoterPostback)
+	}
+
+	// Offer postbacks are checked against the offer's egress allowlist;
+	// global ones fire for offers of any tenant, so their allowlist is
+	// checked on delivery
+	tenantID := ""
+	if input.OfferID != nil {
+		tenantID = s.offerTenantID(*input.OfferID)
+	}
+	rawURL := strings.TrimSpace(input.URL)
+	if err := ValidatePromoterPostbackURL(rawURL, tenantID); err != nil {
+		return err
+	}
+
+	events := trimmedValues(input.Events)
+	if len(events) == 0 {
+		events = []string{models.PromoterPostbackEventCreated}
+	}
+	for _, event := range events {
+		if !models.IsValidPromoterPostbackEvent(event) {
+			return fmt.Errorf("%w: unknown event %q", ErrInvalidPromoterPostback, event)
+		}
+	}
+
+	statuses := trimmedValues(input.Statuses)
+	for _, status := range statuses {
+		if !(&models.Conversion{Status: status}).IsValid() {
+			return fmt.Errorf("%w: unknown conversion status %q", ErrInvalidPromoterPostback, status)
+		}
+	}
+
+	if input.OfferID != nil {
+		var joined int64
+		if err := s.db.Model(&models.UserOffer{}).
+			Where("user_id = ? AND offer_id = ?", postback.UserID, *input.OfferID).
+			Count(&joined).Error; err != nil {
+			return err
+		}
+		if joined == 0 {
+			return ErrPromoterPostbackOfferNotJoined
+		}
+	}
+
+	postback.OfferID = input.OfferID
+	postback.Name = strings.TrimSpace(input.Name)
+	postback.URL = rawURL
+	postback.Method = method
+	postback.Events = strings.Join(events, ",")
+	postback.Statuses = strings.Join(statuses, ",")
+	if input.IsActive != nil {
+		postback.IsActive = *input.IsActive
+	}
+	return nil
+}
+
+// ValidatePromoterPostbackURL checks that a postback URL is an http(s) URL
+// using only supported macros, and that the outbound policy allows it for
+// the tenant ("" checks only the tenant-independent rules)
+func ValidatePromoterPostbackURL(rawURL, tenantID string) error {
+	if rawURL == "" {
+		return fmt.Errorf("%w: url is required", ErrInvalidPromoterPostback)
+	}
+	if strings.Count(rawURL, "{") != strings.Count(rawURL, "}") {
+		return fmt.Errorf("%w: url has unbalanced braces", ErrInvalidPromoterPostback)
+	}
+	for _, match := range macroPattern.FindAllStringSubmatch(rawURL, -1) {
+		if !promoterPostbackMacros[match[1]] {
+			return fmt.Errorf("%w: unknown macro {%s}", ErrInvalidPromoterPostback, match[1])
+		}
+	}
+
+	sample := macroPattern.ReplaceAllString(rawURL, "x")
+	parsed, err := url.Parse(sample)
+	if err != nil || parsed.Host == "" {
+		return fmt.Errorf("%w: url must be an absolute http(s) URL", ErrInvalidPromoterPostback)
+	}
+	if err := GetOutboundClient().ValidateURL(sample, tenantID); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidPromoterPostback, err)
+	}
+	return nil
+}
+
+// offerTenantID returns the tenant of an offer, whose egress allowlist
+// applies to postbacks for it
+func (s *PromoterPostbackService) offerTenantID(offerID uuid.UUID) string {
+	var offer models.Offer
+	if err := s.db.Select("id", "advertiser_id", "network_id").First(&offer, "id = ?", offerID).Error; err != nil {
+		return models.DefaultTenantID.String()
+	}
+	return GetTenantService(s.db).OfferTenantID(&offer).String()
+}
+
+// ============================================
+// FIRING
+// ============================================
+
+// EmitConversion fires the promoter's postbacks for a conversion event in the
+// background. Offer-specific postbacks replace the promoter's global ones.
+func (s *PromoterPostbackService) EmitConversion(conversion models.Conversion, event string) {
+	go func() {
+		if err := s.fire(&conversion, event); err != nil {
+			fmt.Printf("[PromoterPostback] Failed to fire %s postbacks for conversion %s: %v\n", event, conversion.ID, err)
+		}
+	}()
+}
+
+// fire enqueues the matching postbacks for a conversion event. Nothing is
+// sent for offer links that are no longer active (e.g. revoked).
+func (s *PromoterPostbackService) fire(conversion *models.Conversion, event string) error {
+	var userOffer models.UserOffer
+	if err := s.db.Select("id", "user_id", "offer_id", "status").First(&userOffer, "id = ?", conversion.UserOfferID).Error; err != nil {
+		return fmt.Errorf("user offer not found: %w", err)
+	}
+	if !userOffer.IsActive() {
+		fmt.Printf("[PromoterPostback] Skipping %s postbacks for conversion %s: user offer %s is %s\n",
+			event, conversion.ID, userOffer.ID, userOffer.Status)
+		return nil
+	}
+
+	var postbacks []models.PromoterPostback
+	if err := s.db.Where("user_id = ? AND is_active = ? AND (offer_id IS NULL OR offer_id = ?)",
+		userOffer.UserID, true, userOffer.OfferID).Find(&postbacks).Error; err != nil {
+		return err
+	}
+
+	var global, offerSpecific []models.PromoterPostback
+	for _, postback := range postbacks {
+		if !postback.FiresOn(event, conversion.Status) {
+			continue
+		}
+		if postback.OfferID == nil {
+			global = append(global, postback)
+		} else {
+			offerSpecific = append(offerSpecific, postback)
+		}
+	}
+	matched := global
+	if len(offerSpecific) > 0 {
+		matched = offerSpecific
+	}
+
+	if len(matched) == 0 {
+		return nil
+	}
+
+	tenantID := s.offerTenantID(userOffer.OfferID)
+	macros := promoterPostbackMacroValues(conversion, &userOffer, event)
+	for i := range matched {
+		if err := s.enqueue(&matched[i], conversion, event, tenantID, macros); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// enqueue records a delivery and queues the rendered postback, scoped to the
+// offer's tenant
+func (s *PromoterPostbackService) enqueue(postback *models.PromoterPostback, conversion *models.Conversion, event, tenantID string, macros map[string]string) error {
+	delivery := models.PromoterPostbackDelivery{
+		ID:               uuid.New(),
+		PostbackID:       postback.ID,
+		UserID:           postback.UserID,
+		ConversionID:     conversion.ID,
+		Event:            event,
+		ConversionStatus: conversion.Status,
+		URL:              RenderPromoterPostbackURL(postback.URL, macros),
+		Method:           postback.Method,
+		Status:           models.PromoterPostbackDeliveryPending,
+	}
+	if err := s.db.Create(&delivery).Error; err != nil {
+		return fmt.Errorf("failed to record delivery: %w", err)
+	}
+
+	body := ""
+	if delivery.Method == "POST" {
+		data, _ := json.Marshal(macros)
+		body = string(data)
+	}
+
+	return s.queue.Enqueue(&PostbackQueueItem{
+		ID:       delivery.ID.String(),
+		URL:      delivery.URL,
+		Method:   delivery.Method,
+		Body:     body,
+		TenantID: tenantID,
+		Metadata: map[string]interface{}{
+			"source":        PromoterPostbackSource,
+			"postback_id":   postback.ID.String(),
+			"user_id":       postback.UserID.String(),
+			"conversion_id": conversion.ID.String(),
+			"event":         event,
+		},
+	})
+}
+
+// promoterPostbackMacroValues builds the macro values for a conversion event
+func promoterPostbackMacroValues(conversion *models.Conversion, userOffer *models.UserOffer, event string) map[string]string {
+	macros := map[string]string{
+		"conversion_id": conversion.ID.String(),
+		"external_id":   conversion.ExternalConversionID,
+		"offer_id":      userOffer.OfferID.String(),
+		"user_offer_id": userOffer.ID.String(),
+		"payout":        formatCents(conversion.Commission),
+		"amount":        formatCents(conversion.Amount),
+		"currency":      conversion.Currency,
+		"status":        conversion.Status,
+		"event":         event,
+		"timestamp":     strconv.FormatInt(time.Now().Unix(), 10),
+	}
+	if conversion.ClickID != nil {
+		macros["click_id"] = conversion.ClickID.String()
+	}
+	for n := 1; n <= models.MaxSubIDs; n++ {
+		macros["sub"+strconv.Itoa(n)] = conversion.SubIDs.Get(n)
+	}
+	return macros
+}
+
+// formatCents formats an amount in cents as a decimal, e.g. 1250 -> "12.50"
+func formatCents(cents int) string {
+	return strconv.FormatFloat(float64(cents)/100, 'f', 2, 64)
+}
+
+// RenderPromoterPostbackURL expands the macros in a postback URL. Values are
+// query-escaped; macros without a value are left empty.
+func RenderPromoterPostbackURL(rawURL string, macros map[string]string) string {
+	return macroPattern.ReplaceAllStringFunc(rawURL, func(match string) string {
+		return url.QueryEscape(macros[match[1:len(match)-1]])
+	})
+}
+
+// ============================================
+// DELIVERY LOG
+// ============================================
+
+// recordResult updates a delivery from a postback queue attempt
+func (s *PromoterPostbackService) recordResult(item *PostbackQueueItem, status PostbackQueueStatus) {
+	deliveryID, err := uuid.Parse(item.ID)
+	if err != nil {
+		return
+	}
+
+	response := item.Response
+	if len(response) > maxDeliveryResponseSnippet {
+		response = response[:maxDeliveryResponseSnippet]
+	}
+
+	now := time.Now().UTC()
+	updates := map[string]interface{}{
+		"status":           string(status),
+		"attempts":         item.Attempts,
+		"status_code":      item.StatusCode,
+		"response_snippet": response,
+		"last_error":       item.LastError,
+		"updated_at":       now,
+	}
+	if status == PostbackStatusSent {
+		updates["delivered_at"] = now
+		updates["last_error"] = ""
+	}
+
+	if err := s.db.Model(&models.PromoterPostbackDelivery{}).Where("id = ?", deliveryID).Updates(updates).Error; err != nil {
+		fmt.Printf("[PromoterPostback] Failed to update delivery %s: %v\n", deliveryID, err)
+	}
+}
+
+// ListDeliveries returns a promoter's delivery log, newest first, optionally
+// for one postback or delivery status
+func (s *PromoterPostbackService) ListDeliveries(userID uuid.UUID, postbackID *uuid.UUID, status string, limit int) ([]models.PromoterPostbackDelivery, error) {
+	if limit <= 0 {
+		limit = defaultPromoterDeliveryPage
+	}
+	if limit > maxPromoterDeliveryPage {
+		limit = maxPromoterDeliveryPage
+	}
+
+	query := s.db.Where("user_id = ?", userID)
+	if postbackID != nil {
+		query = query.Where("postback_id = ?", *postbackID)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var deliveries []models.PromoterPostbackDelivery
+	err := query.Order("created_at DESC").Limit(limit).Find(&deliveries).Error
+	return deliveries, err
+}
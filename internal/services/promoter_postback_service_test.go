@@ -0,0 +1,95 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/google/uuid"
+)
+
+func TestValidatePromoterPostbackURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		url     string
+		wantErr bool
+	}{
+		{"plain URL", "https://tracker.example.com/pb", false},
+		{"macros", "https://tracker.example.com/pb?cid={click_id}&payout={payout}&s1={sub1}", false},
+		{"every sub ID", "https://tracker.example.com/pb?a={sub1}&b={sub2}&c={sub3}&d={sub4}&e={sub5}", false},
+		{"macro in path", "https://tracker.example.com/{event}/{status}", false},
+
+		{"empty", "", true},
+		{"unknown macro", "https://tracker.example.com/pb?x={password}", true},
+		{"unbalanced braces", "https://tracker.example.com/pb?x={click_id", true},
+		{"relative", "/pb?cid={click_id}", true},
+		{"macro as host", "https://{sub1}/pb", false}, // Rendered at delivery, checked there
+		{"scheme not allowed", "ftp://tracker.example.com/pb", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidatePromoterPostbackURL(tt.url, "")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValidatePromoterPostbackURL(%q) = %v, wantErr %v", tt.url, err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidPromoterPostback) {
+				t.Errorf("error %v is not ErrInvalidPromoterPostback", err)
+			}
+		})
+	}
+}
+
+func TestPromoterPostbackMacros(t *testing.T) {
+	clickID := uuid.New()
+	conversion := &models.Conversion{
+		ID:                   uuid.New(),
+		ClickID:              &clickID,
+		ExternalConversionID: "order 1001",
+		Amount:               4990,
+		Commission:           250,
+		Currency:             "SAR",
+		Status:               models.ConversionStatusApproved,
+		SubIDs:               models.SubIDs{Sub1: "fb&ads", Sub3: "spring sale"},
+	}
+	userOffer := &models.UserOffer{ID: uuid.New(), OfferID: uuid.New()}
+
+	macros := promoterPostbackMacroValues(conversion, userOffer, models.PromoterPostbackEventCreated)
+	for macro := range promoterPostbackMacros {
+		if _, ok := macros[macro]; !ok {
+			t.Errorf("no value for macro {%s}", macro)
+		}
+	}
+
+	rendered := RenderPromoterPostbackURL(
+		"https://t.example.com/pb?cid={click_id}&ext={external_id}&p={payout}&a={amount}&c={currency}&s1={sub1}&s2={sub2}&s3={sub3}&st={status}",
+		macros,
+	)
+	want := "https://t.example.com/pb?cid=" + clickID.String() +
+		"&ext=order+1001&p=2.50&a=49.90&c=SAR&s1=fb%26ads&s2=&s3=spring+sale&st=" + models.ConversionStatusApproved
+	if rendered != want {
+		t.Errorf("rendered URL\n got %s\nwant %s", rendered, want)
+	}
+
+	// Conversions without a click leave the macro empty
+	conversion.ClickID = nil
+	macros = promoterPostbackMacroValues(conversion, userOffer, models.PromoterPostbackEventCreated)
+	if got := RenderPromoterPostbackURL("https://t.example.com/pb?cid={click_id}", macros); got != "https://t.example.com/pb?cid=" {
+		t.Errorf("rendered URL without click = %s", got)
+	}
+}
+
+func TestFormatCents(t *testing.T) {
+	tests := map[int]string{
+		0:      "0.00",
+		5:      "0.05",
+		1250:   "12.50",
+		100000: "1000.00",
+		-250:   "-2.50",
+	}
+	for cents, want := range tests {
+		if got := formatCents(cents); got != want {
+			t.Errorf("formatCents(%d) = %q, want %q", cents, got, want)
+		}
+	}
+}
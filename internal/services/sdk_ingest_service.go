@@ -0,0 +1,581 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"github.com/aljapah/afftok-backend-prod/internal/cache"
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ============================================
+// SDK INGEST SERVICE
+// ============================================
+
+// SDKIngestService records clicks and conversions sent by the web and mobile
+// SDKs. Every event is signed with HMAC-SHA256 over
+// "api_key|advertiser_id|timestamp|nonce" using the API key as the secret.
+type SDKIngestService struct {
+	db                 *gorm.DB
+	clickService       *ClickService
+	lifecycleService   *ConversionLifecycleService
+	attributionService *AttributionService
+	contestService     *ContestService
+	geoIPService       *GeoIPService
+}
+
+// NewSDKIngestService creates a new SDK ingest service
+func NewSDKIngestService(db *gorm.DB) *SDKIngestService {
+	return &SDKIngestService{
+		db:                 db,
+		clickService:       NewClickService(),
+		lifecycleService:   NewConversionLifecycleService(db),
+		attributionService: NewAttributionService(db),
+		contestService:     GetContestService(db),
+		geoIPService:       GetGeoIPService(),
+	}
+}
+
+const (
+	// SDKMaxBatchSize caps events per offline-queue flush
+	SDKMaxBatchSize = 100
+
+	// Offline queues replay events long after they were signed
+	sdkMaxEventAge    = 72 * time.Hour
+	sdkMaxClockSkew   = 5 * time.Minute
+	sdkNonceTTL       = sdkMaxEventAge + time.Hour
+	sdkNoncePending   = "pending"
+	sdkMinNonceLen    = 16
+	sdkMaxNonceLen    = 64
+	sdkMaxDeviceID    = 100
+	sdkMaxFingerprint = 64
+)
+
+// SDK error codes. These are part of the SDK contract; don't rename them.
+const (
+	SDKErrInvalidPayload       = "INVALID_PAYLOAD"
+	SDKErrBatchTooLarge        = "BATCH_TOO_LARGE"
+	SDKErrAPIKeyMismatch       = "API_KEY_MISMATCH"
+	SDKErrAdvertiserMismatch   = "ADVERTISER_MISMATCH"
+	SDKErrInvalidSignature     = "INVALID_SIGNATURE"
+	SDKErrTimestampExpired     = "TIMESTAMP_EXPIRED"
+	SDKErrInvalidTimestamp     = "INVALID_TIMESTAMP"
+	SDKErrInvalidNonce         = "INVALID_NONCE"
+	SDKErrNonceInFlight        = "NONCE_IN_FLIGHT"
+	SDKErrPermissionDenied     = "PERMISSION_DENIED"
+	SDKErrUserOfferNotFound    = "USER_OFFER_NOT_FOUND"
+	SDKErrOfferMismatch        = "OFFER_MISMATCH"
+	SDKErrOfferNotAllowed      = "OFFER_NOT_ALLOWED"
+	SDKErrLinkInactive         = "LINK_INACTIVE"
+	SDKErrInvalidStatus        = "INVALID_STATUS"
+	SDKErrInvalidClickID       = "INVALID_CLICK_ID"
+	SDKErrOutsideWindow        = "OUTSIDE_ATTRIBUTION_WINDOW"
+	SDKErrDuplicateTransaction = "DUPLICATE_TRANSACTION"
+	SDKErrInternal             = "INTERNAL_ERROR"
+)
+
+// SDKError is a rejected SDK event. Retryable tells the SDK whether to keep
+// the event in its offline queue.
+type SDKError struct {
+	Status    int
+	Code      string
+	Message   string
+	Retryable bool
+}
+
+func (e *SDKError) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+func sdkError(status int, code, message string) *SDKError {
+	return &SDKError{Status: status, Code: code, Message: message}
+}
+
+// ============================================
+// REQUESTS
+// ============================================
+
+// SDKEnvelope holds the fields every signed SDK event carries
+type SDKEnvelope struct {
+	APIKey       string                 `json:"api_key"`
+	AdvertiserID string                 `json:"advertiser_id"`
+	Timestamp    int64                  `json:"timestamp"` // Unix milliseconds
+	Nonce        string                 `json:"nonce"`
+	Signature    string                 `json:"signature"`
+	UserID       string                 `json:"user_id"`
+	DeviceInfo   map[string]interface{} `json:"device_info"`
+	CustomParams map[string]interface{} `json:"custom_params"`
+}
+
+// SDKClickRequest is a click event from an SDK
+type SDKClickRequest struct {
+	SDKEnvelope
+	OfferID      string `json:"offer_id"`
+	TrackingCode string `json:"tracking_code"`
+	SignedLink   string `json:"signed_link"`
+	SubID1       string `json:"sub_id_1"`
+	SubID2       string `json:"sub_id_2"`
+	SubID3       string `json:"sub_id_3"`
+	SubID4       string `json:"sub_id_4"`
+	SubID5       string `json:"sub_id_5"`
+}
+
+// SDKConversionRequest is a conversion event from an SDK
+type SDKConversionRequest struct {
+	SDKEnvelope
+	OfferID       string                 `json:"offer_id"`
+	TransactionID string                 `json:"transaction_id"`
+	ClickID       string                 `json:"click_id"`
+	Amount        float64                `json:"amount"` // Major currency units
+	Currency      string                 `json:"currency"`
+	Status        string                 `json:"status"`
+	Metadata      map[string]interface{} `json:"metadata"`
+}
+
+// SDKResult is the outcome of an accepted SDK event
+type SDKResult struct {
+	ID        string `json:"id"`
+	Duplicate bool   `json:"duplicate"`
+	Queued    bool   `json:"queued"`
+}
+
+// ============================================
+// SDK METRICS
+// ============================================
+
+// SDKIngestMetrics tracks SDK ingestion
+type SDKIngestMetrics struct {
+	Clicks            int64
+	Conversions       int64
+	Duplicates        int64
+	Rejected          int64
+	InvalidSignatures int64
+	Batches           int64
+}
+
+var sdkIngestMetrics = &SDKIngestMetrics{}
+
+// GetSDKIngestMetrics returns SDK ingestion metrics
+func GetSDKIngestMetrics() *SDKIngestMetrics {
+	return &SDKIngestMetrics{
+		Clicks:            atomic.LoadInt64(&sdkIngestMetrics.Clicks),
+		Conversions:       atomic.LoadInt64(&sdkIngestMetrics.Conversions),
+		Duplicates:        atomic.LoadInt64(&sdkIngestMetrics.Duplicates),
+		Rejected:          atomic.LoadInt64(&sdkIngestMetrics.Rejected),
+		InvalidSignatures: atomic.LoadInt64(&sdkIngestMetrics.InvalidSignatures),
+		Batches:           atomic.LoadInt64(&sdkIngestMetrics.Batches),
+	}
+}
+
+// RecordBatch counts an offline-queue flush
+func (s *SDKIngestService) RecordBatch() {
+	atomic.AddInt64(&sdkIngestMetrics.Batches, 1)
+}
+
+// ============================================
+// CLICKS
+// ============================================
+
+// IngestClick verifies and records an SDK click
+func (s *SDKIngestService) IngestClick(c *gin.Context, key *models.AdvertiserAPIKey, rawKey, tenantID string, req *SDKClickRequest) (*SDKResult, *SDKError) {
+	if sdkErr := s.verify(key, rawKey, &req.SDKEnvelope); sdkErr != nil {
+		return nil, s.reject(sdkErr)
+	}
+
+	userOffer, sdkErr := s.resolveClickUserOffer(req)
+	if sdkErr == nil {
+		sdkErr = checkOfferAccess(key, userOffer, req.OfferID)
+	}
+	if sdkErr != nil {
+		return nil, s.reject(sdkErr)
+	}
+
+	return s.withNonce(key, req.Nonce, func() (*SDKResult, *SDKError) {
+		click := s.buildClick(c, sdkEventID(key, req.Nonce), userOffer.ID, req)
+		if err := s.clickService.RecordClick(click, tenantID); err != nil {
+			fmt.Printf("[SDK] Failed to record click: %v\n", err)
+			return nil, &SDKError{Status: http.StatusInternalServerError, Code: SDKErrInternal, Message: "Failed to record click", Retryable: true}
+		}
+
+		atomic.AddInt64(&sdkIngestMetrics.Clicks, 1)
+		countDailyCapClick(context.Background(), userOffer.OfferID, userOffer.ID)
+		s.contestService.RecordActivity(userOffer.ID)
+		return &SDKResult{ID: click.ID.String()}, nil
+	})
+}
+
+// resolveClickUserOffer finds the promoter link an SDK click belongs to:
+// by tracking code (or signed link), else by promoter user_id + offer_id
+func (s *SDKIngestService) resolveClickUserOffer(req *SDKClickRequest) (*models.UserOffer, *SDKError) {
+	trackingCode := strings.TrimSpace(req.TrackingCode)
+	if trackingCode == "" && req.SignedLink != "" {
+		// Format: {trackingCode}.{timestamp}.{nonce}.{signature}
+		trackingCode = strings.SplitN(req.SignedLink, ".", 2)[0]
+	}
+
+	var userOffer models.UserOffer
+	if trackingCode != "" {
+		if err := s.db.Preload("Offer").Where("tracking_code = ? OR short_link = ?", trackingCode, trackingCode).
+			First(&userOffer).Error; err == nil {
+			return &userOffer, nil
+		}
+		return nil, sdkError(http.StatusNotFound, SDKErrUserOfferNotFound, "No link found for tracking_code")
+	}
+
+	return s.findPromoterUserOffer(req.UserID, req.OfferID, "tracking_code")
+}
+
+// findPromoterUserOffer finds a promoter's link by promoter user_id + offer_id.
+// alternative names the identifier that could have been sent instead.
+func (s *SDKIngestService) findPromoterUserOffer(rawUserID, rawOfferID, alternative string) (*models.UserOffer, *SDKError) {
+	offerID, offerErr := uuid.Parse(rawOfferID)
+	userID, userErr := uuid.Parse(rawUserID)
+	if offerErr != nil || userErr != nil {
+		return nil, sdkError(http.StatusBadRequest, SDKErrInvalidPayload, alternative+", or offer_id and user_id, is required")
+	}
+
+	var userOffer models.UserOffer
+	if err := s.db.Preload("Offer").Where("user_id = ? AND offer_id = ?", userID, offerID).
+		First(&userOffer).Error; err != nil {
+		return nil, sdkError(http.StatusNotFound, SDKErrUserOfferNotFound, "User has not joined this offer")
+	}
+	return &userOffer, nil
+}
+
+// buildClick builds the click record, preferring the device's own context
+// over the request's (offline events are sent later, possibly via a proxy)
+func (s *SDKIngestService) buildClick(c *gin.Context, id, userOfferID uuid.UUID, req *SDKClickRequest) *models.Click {
+	userAgent := deviceString(req.DeviceInfo, "user_agent")
+	if userAgent == "" {
+		userAgent = c.Request.UserAgent()
+	}
+	device, browser, os := parseUserAgent(userAgent)
+	geo := s.geoIPService.ResolveRequest(c)
+
+	click := &models.Click{
+		ID:          id,
+		UserOfferID: userOfferID,
+		IPAddress:   c.ClientIP(),
+		UserAgent:   userAgent,
+		Device:      device,
+		Browser:     browser,
+		OS:          os,
+		Referrer:    deviceString(req.DeviceInfo, "referrer"),
+		Country:     geo.CountryCode,
+		City:        geo.City,
+		ClickedAt:   eventTime(req.Timestamp),
+		Fingerprint: truncate(deviceString(req.DeviceInfo, "fingerprint"), sdkMaxFingerprint),
+		IsUnique:    true,
+	}
+	click.DeviceID, click.DeviceInfo = deviceContext(req.DeviceInfo)
+	for n, subID := range []string{req.SubID1, req.SubID2, req.SubID3, req.SubID4, req.SubID5} {
+		click.SubIDs.Set(n+1, truncate(strings.TrimSpace(subID), MaxSubIDLength))
+	}
+	return click
+}
+
+// ============================================
+// CONVERSIONS
+// ============================================
+
+// IngestConversion verifies and records an SDK conversion
+func (s *SDKIngestService) IngestConversion(key *models.AdvertiserAPIKey, rawKey, tenantID string, req *SDKConversionRequest) (*SDKResult, *SDKError) {
+	if sdkErr := s.verify(key, rawKey, &req.SDKEnvelope); sdkErr != nil {
+		return nil, s.reject(sdkErr)
+	}
+	if !key.HasPermission(models.PermissionPostbackWrite) {
+		return nil, s.reject(sdkError(http.StatusForbidden, SDKErrPermissionDenied, "API key lacks "+models.PermissionPostbackWrite))
+	}
+
+	req.TransactionID = strings.TrimSpace(req.TransactionID)
+	if req.TransactionID == "" || len(req.TransactionID) > 100 {
+		return nil, s.reject(sdkError(http.StatusBadRequest, SDKErrInvalidPayload, "transaction_id is required (max 100 characters)"))
+	}
+
+	status := strings.ToLower(strings.TrimSpace(req.Status))
+	if status == "" {
+		status = models.ConversionStatusPending
+	}
+	if status != models.ConversionStatusPending && status != models.ConversionStatusApproved && status != models.ConversionStatusRejected {
+		return nil, s.reject(sdkError(http.StatusBadRequest, SDKErrInvalidStatus, "status must be pending, approved or rejected"))
+	}
+
+	// click_id is optional in the SDKs; without one the conversion is
+	// attributed to the promoter by user_id + offer_id, as clicks are
+	var attribution *AttributionResult
+	if req.ClickID != "" {
+		result, err := s.attributionService.Attribute(req.ClickID, eventTime(req.Timestamp))
+		if err != nil {
+			return nil, s.reject(sdkError(http.StatusNotFound, SDKErrInvalidClickID, "Unknown click_id"))
+		}
+		if result.IsRejected() {
+			return nil, s.reject(sdkError(http.StatusUnprocessableEntity, SDKErrOutsideWindow, "Conversion is outside the offer's attribution window"))
+		}
+		attribution = result
+	} else {
+		userOffer, sdkErr := s.findPromoterUserOffer(req.UserID, req.OfferID, "click_id")
+		if sdkErr != nil {
+			return nil, s.reject(sdkErr)
+		}
+		attribution = &AttributionResult{
+			UserOffer: userOffer,
+			Decision:  AttributionDirect,
+			Reason:    "no click_id; attributed by user_id and offer_id",
+		}
+	}
+	userOffer := attribution.UserOffer
+	if sdkErr := checkOfferAccess(key, userOffer, req.OfferID); sdkErr != nil {
+		return nil, s.reject(sdkErr)
+	}
+
+	return s.withNonce(key, req.Nonce, func() (*SDKResult, *SDKError) {
+		// The SDK retries until it sees a response, so a repeat is a success
+		var existing models.Conversion
+		if err := s.db.Select("id", "user_offer_id").
+			Where("external_conversion_id = ?", req.TransactionID).First(&existing).Error; err == nil {
+			if existing.UserOfferID != userOffer.ID {
+				return nil, sdkError(http.StatusConflict, SDKErrDuplicateTransaction, "transaction_id is already used by another link")
+			}
+			atomic.AddInt64(&sdkIngestMetrics.Duplicates, 1)
+			return &SDKResult{ID: existing.ID.String(), Duplicate: true}, nil
+		}
+
+		conversion := s.buildConversion(userOffer, attribution, status, req)
+		queued, err := s.lifecycleService.RecordConversion(&ConversionEvent{
+			Conversion: conversion,
+			OfferID:    userOffer.OfferID,
+			UserID:     userOffer.UserID,
+			Source:     models.ConversionSourceSDK,
+		}, tenantID)
+		if errors.Is(err, ErrDuplicateConversion) {
+			atomic.AddInt64(&sdkIngestMetrics.Duplicates, 1)
+			if existing, findErr := s.lifecycleService.FindByExternalID(req.TransactionID); findErr == nil {
+				if existing.UserOfferID != userOffer.ID {
+					return nil, sdkError(http.StatusConflict, SDKErrDuplicateTransaction, "transaction_id is already used by another link")
+				}
+				return &SDKResult{ID: existing.ID.String(), Duplicate: true}, nil
+			}
+		}
+		if err != nil {
+			fmt.Printf("[SDK] Failed to record conversion: %v\n", err)
+			return nil, &SDKError{Status: http.StatusInternalServerError, Code: SDKErrInternal, Message: "Failed to record conversion", Retryable: true}
+		}
+
+		atomic.AddInt64(&sdkIngestMetrics.Conversions, 1)
+		return &SDKResult{ID: conversion.ID.String(), Queued: queued}, nil
+	})
+}
+
+// buildConversion builds the conversion record for an SDK event
+func (s *SDKIngestService) buildConversion(userOffer *models.UserOffer, attribution *AttributionResult, status string, req *SDKConversionRequest) *models.Conversion {
+	currency := strings.ToUpper(strings.TrimSpace(req.Currency))
+	if len(currency) != 3 {
+		currency = "USD"
+	}
+
+	commission := userOffer.EffectiveCommission()
+
+	// Audit trail; never store the API key or signature
+	postbackData, _ := json.Marshal(map[string]interface{}{
+		"source":         models.ConversionSourceSDK,
+		"transaction_id": req.TransactionID,
+		"amount":         req.Amount,
+		"currency":       currency,
+		"status":         req.Status,
+		"custom_params":  req.CustomParams,
+		"metadata":       req.Metadata,
+	})
+
+	now := time.Now().UTC()
+	conversion := &models.Conversion{
+		ID:                   uuid.New(),
+		UserOfferID:          userOffer.ID,
+		ClickID:              attribution.ClickID(),
+		ExternalConversionID: req.TransactionID,
+		Amount:               int(math.Round(req.Amount * 100)), // Store in cents
+		Commission:           commission,
+		Currency:             currency,
+		Status:               status,
+		ConvertedAt:          eventTime(req.Timestamp),
+		PostbackData:         string(postbackData),
+		PostbackReceivedAt:   &now,
+	}
+	if attribution.IsFlagged() {
+		conversion.OutsideAttributionWindow = true
+		conversion.Status = models.ConversionStatusPending
+	}
+	conversion.DeviceID, conversion.DeviceInfo = deviceContext(req.DeviceInfo)
+	return conversion
+}
+
+// ============================================
+// VERIFICATION
+// ============================================
+
+// verify checks the event is signed by the presented key for its advertiser
+// and was signed recently enough
+func (s *SDKIngestService) verify(key *models.AdvertiserAPIKey, rawKey string, env *SDKEnvelope) *SDKError {
+	if env.APIKey != "" && !hmac.Equal([]byte(env.APIKey), []byte(rawKey)) {
+		return sdkError(http.StatusUnauthorized, SDKErrAPIKeyMismatch, "api_key does not match the X-API-Key header")
+	}
+	if !strings.EqualFold(env.AdvertiserID, key.AdvertiserID.String()) {
+		return sdkError(http.StatusForbidden, SDKErrAdvertiserMismatch, "advertiser_id does not match the API key")
+	}
+
+	if env.Timestamp <= 0 {
+		return sdkError(http.StatusBadRequest, SDKErrInvalidTimestamp, "timestamp is required (Unix milliseconds)")
+	}
+	age := time.Since(time.UnixMilli(env.Timestamp))
+	if age > sdkMaxEventAge {
+		return sdkError(http.StatusUnauthorized, SDKErrTimestampExpired, "Event is too old to accept")
+	}
+	if age < -sdkMaxClockSkew {
+		return sdkError(http.StatusBadRequest, SDKErrInvalidTimestamp, "timestamp is in the future")
+	}
+
+	if len(env.Nonce) < sdkMinNonceLen || len(env.Nonce) > sdkMaxNonceLen {
+		return sdkError(http.StatusBadRequest, SDKErrInvalidNonce, fmt.Sprintf("nonce must be %d-%d characters", sdkMinNonceLen, sdkMaxNonceLen))
+	}
+
+	expected := SignSDKRequest(rawKey, env.AdvertiserID, env.Timestamp, env.Nonce)
+	if !hmac.Equal([]byte(strings.ToLower(env.Signature)), []byte(expected)) {
+		atomic.AddInt64(&sdkIngestMetrics.InvalidSignatures, 1)
+		return sdkError(http.StatusUnauthorized, SDKErrInvalidSignature, "Signature verification failed")
+	}
+
+	return nil
+}
+
+// SignSDKRequest computes the SDK request signature
+func SignSDKRequest(apiKey, advertiserID string, timestamp int64, nonce string) string {
+	mac := hmac.New(sha256.New, []byte(apiKey))
+	mac.Write([]byte(fmt.Sprintf("%s|%s|%d|%s", apiKey, advertiserID, timestamp, nonce)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// withNonce runs fn at most once per nonce. A replay of an event that was
+// already recorded returns the original result as a duplicate, so an SDK that
+// lost the response can drop it from its queue; a failed attempt releases the
+// nonce so the SDK can retry. If Redis is unavailable the nonce check is
+// skipped (fail open, like signed links); records are still deduplicated,
+// clicks by their nonce-derived ID (sdkEventID) and conversions by
+// transaction ID.
+func (s *SDKIngestService) withNonce(key *models.AdvertiserAPIKey, nonce string, fn func() (*SDKResult, *SDKError)) (*SDKResult, *SDKError) {
+	ctx := context.Background()
+	nonceKey := fmt.Sprintf("sdk:nonce:%s:%s", key.ID.String(), nonce)
+
+	claimed, err := cache.SetNX(ctx, nonceKey, sdkNoncePending, sdkNonceTTL)
+	if err != nil {
+		result, sdkErr := fn()
+		if sdkErr != nil {
+			return nil, s.reject(sdkErr)
+		}
+		return result, nil
+	}
+
+	if !claimed {
+		previous, err := cache.Get(ctx, nonceKey)
+		if err != nil || previous == sdkNoncePending {
+			return nil, s.reject(&SDKError{Status: http.StatusConflict, Code: SDKErrNonceInFlight, Message: "Event is already being processed", Retryable: true})
+		}
+		atomic.AddInt64(&sdkIngestMetrics.Duplicates, 1)
+		return &SDKResult{ID: previous, Duplicate: true}, nil
+	}
+
+	result, sdkErr := fn()
+	if sdkErr != nil {
+		cache.Delete(ctx, nonceKey)
+		return nil, s.reject(sdkErr)
+	}
+
+	cache.Set(ctx, nonceKey, result.ID, sdkNonceTTL)
+	return result, nil
+}
+
+// sdkEventID derives a click's ID from the key and nonce, so a replayed event
+// maps to the same record even when the nonce check can't run
+func sdkEventID(key *models.AdvertiserAPIKey, nonce string) uuid.UUID {
+	return uuid.NewSHA1(key.ID, []byte("sdk:click:"+nonce))
+}
+
+// reject counts a rejected event
+func (s *SDKIngestService) reject(sdkErr *SDKError) *SDKError {
+	atomic.AddInt64(&sdkIngestMetrics.Rejected, 1)
+	return sdkErr
+}
+
+// checkOfferAccess ensures the key may track the link's offer, that the
+// offer_id the SDK sent (if any) is the link's offer and that the link is
+// still active (revoked or paused promoters get no clicks or conversions)
+func checkOfferAccess(key *models.AdvertiserAPIKey, userOffer *models.UserOffer, offerID string) *SDKError {
+	if id, err := uuid.Parse(offerID); err == nil && id != userOffer.OfferID {
+		return sdkError(http.StatusBadRequest, SDKErrOfferMismatch, "offer_id does not match the link's offer")
+	}
+	if !key.CanAccessOffer(userOffer.Offer) {
+		return sdkError(http.StatusForbidden, SDKErrOfferNotAllowed, "API key cannot track this offer")
+	}
+	if !userOffer.IsActive() {
+		return sdkError(http.StatusForbidden, SDKErrLinkInactive, "Link is "+userOffer.Status)
+	}
+	return nil
+}
+
+// ============================================
+// HELPERS
+// ============================================
+
+// eventTime converts an SDK timestamp to the event time, clamped to now
+func eventTime(timestampMs int64) time.Time {
+	now := time.Now().UTC()
+	t := time.UnixMilli(timestampMs).UTC()
+	if t.After(now) {
+		return now
+	}
+	return t
+}
+
+// deviceContext extracts the device ID and the stored device info
+func deviceContext(info map[string]interface{}) (string, []byte) {
+	if len(info) == 0 {
+		return "", nil
+	}
+	raw, err := json.Marshal(info)
+	if err != nil {
+		return truncate(deviceString(info, "device_id"), sdkMaxDeviceID), nil
+	}
+	return truncate(deviceString(info, "device_id"), sdkMaxDeviceID), raw
+}
+
+// deviceString reads a string field from device_info
+func deviceString(info map[string]interface{}, field string) string {
+	if value, ok := info[field].(string); ok {
+		return strings.TrimSpace(value)
+	}
+	return ""
+}
+
+// truncate limits a string to max bytes without splitting a UTF-8 sequence,
+// which Postgres would reject
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	for max > 0 && !utf8.RuneStart(s[max]) {
+		max--
+	}
+	return s[:max]
+}
@@ -0,0 +1,169 @@
+package services
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/google/uuid"
+)
+
+// The SDK signing vector; the same values are documented next to
+// _generateSignature in sdk/web/afftok.js
+const (
+	sdkVectorAPIKey       = "afftok_live_sk_test_vector_0001"
+	sdkVectorAdvertiserID = "5f0c6b2e-8d1a-4c3b-9e7f-2a4d6c8b0e1f"
+	sdkVectorTimestamp    = int64(1767225600000)
+	sdkVectorNonce        = "Zk3Qp9LmT2vX8rWc"
+	sdkVectorSignature    = "c58f1a73ee486bbde53189ac4b0cff9c4461044681c676721aab89685e3773e3"
+)
+
+func TestSignSDKRequestVector(t *testing.T) {
+	got := SignSDKRequest(sdkVectorAPIKey, sdkVectorAdvertiserID, sdkVectorTimestamp, sdkVectorNonce)
+	if got != sdkVectorSignature {
+		t.Errorf("SignSDKRequest = %s, want %s", got, sdkVectorSignature)
+	}
+
+	// Every signed field matters
+	for name, sig := range map[string]string{
+		"key":        SignSDKRequest(sdkVectorAPIKey+"x", sdkVectorAdvertiserID, sdkVectorTimestamp, sdkVectorNonce),
+		"advertiser": SignSDKRequest(sdkVectorAPIKey, strings.ToUpper(sdkVectorAdvertiserID), sdkVectorTimestamp, sdkVectorNonce),
+		"timestamp":  SignSDKRequest(sdkVectorAPIKey, sdkVectorAdvertiserID, sdkVectorTimestamp+1, sdkVectorNonce),
+		"nonce":      SignSDKRequest(sdkVectorAPIKey, sdkVectorAdvertiserID, sdkVectorTimestamp, sdkVectorNonce+"x"),
+	} {
+		if sig == sdkVectorSignature {
+			t.Errorf("changing the %s did not change the signature", name)
+		}
+	}
+}
+
+func TestSDKVerify(t *testing.T) {
+	s := &SDKIngestService{}
+	advertiserID := uuid.MustParse(sdkVectorAdvertiserID)
+	key := &models.AdvertiserAPIKey{ID: uuid.New(), AdvertiserID: advertiserID}
+	now := time.Now().UnixMilli()
+
+	envelope := func(mutate func(env *SDKEnvelope)) *SDKEnvelope {
+		env := &SDKEnvelope{
+			APIKey:       sdkVectorAPIKey,
+			AdvertiserID: sdkVectorAdvertiserID,
+			Timestamp:    now,
+			Nonce:        sdkVectorNonce,
+		}
+		if mutate != nil {
+			mutate(env)
+		}
+		if env.Signature == "" {
+			env.Signature = SignSDKRequest(sdkVectorAPIKey, env.AdvertiserID, env.Timestamp, env.Nonce)
+		}
+		return env
+	}
+
+	tests := []struct {
+		name     string
+		env      *SDKEnvelope
+		wantCode string
+	}{
+		{"valid", envelope(nil), ""},
+		{"api_key omitted from body", envelope(func(e *SDKEnvelope) { e.APIKey = "" }), ""},
+		{"upper-case signature", envelope(func(e *SDKEnvelope) {
+			e.Signature = strings.ToUpper(SignSDKRequest(sdkVectorAPIKey, e.AdvertiserID, e.Timestamp, e.Nonce))
+		}), ""},
+		{"slight clock skew", envelope(func(e *SDKEnvelope) { e.Timestamp = now + time.Minute.Milliseconds() }), ""},
+		{"offline event", envelope(func(e *SDKEnvelope) { e.Timestamp = now - (48 * time.Hour).Milliseconds() }), ""},
+
+		{"different api_key", envelope(func(e *SDKEnvelope) { e.APIKey = "afftok_live_sk_other" }), SDKErrAPIKeyMismatch},
+		{"different advertiser", envelope(func(e *SDKEnvelope) { e.AdvertiserID = uuid.New().String() }), SDKErrAdvertiserMismatch},
+		{"missing timestamp", envelope(func(e *SDKEnvelope) { e.Timestamp = 0 }), SDKErrInvalidTimestamp},
+		{"too old", envelope(func(e *SDKEnvelope) { e.Timestamp = now - (sdkMaxEventAge + time.Minute).Milliseconds() }), SDKErrTimestampExpired},
+		{"in the future", envelope(func(e *SDKEnvelope) { e.Timestamp = now + (sdkMaxClockSkew + time.Minute).Milliseconds() }), SDKErrInvalidTimestamp},
+		{"short nonce", envelope(func(e *SDKEnvelope) { e.Nonce = "abc" }), SDKErrInvalidNonce},
+		{"long nonce", envelope(func(e *SDKEnvelope) { e.Nonce = strings.Repeat("n", sdkMaxNonceLen+1) }), SDKErrInvalidNonce},
+		{"signed with another key", envelope(func(e *SDKEnvelope) {
+			e.Signature = SignSDKRequest("afftok_live_sk_other", e.AdvertiserID, e.Timestamp, e.Nonce)
+		}), SDKErrInvalidSignature},
+		{"signature for another nonce", envelope(func(e *SDKEnvelope) {
+			e.Signature = SignSDKRequest(sdkVectorAPIKey, e.AdvertiserID, e.Timestamp, "AAAAAAAAAAAAAAAA")
+		}), SDKErrInvalidSignature},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sdkErr := s.verify(key, sdkVectorAPIKey, tt.env)
+			got := ""
+			if sdkErr != nil {
+				got = sdkErr.Code
+				if sdkErr.Retryable {
+					t.Error("verification failures must not be retried")
+				}
+			}
+			if got != tt.wantCode {
+				t.Errorf("verify = %q, want %q", got, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestSDKEventID(t *testing.T) {
+	key := &models.AdvertiserAPIKey{ID: uuid.New()}
+	other := &models.AdvertiserAPIKey{ID: uuid.New()}
+
+	if sdkEventID(key, sdkVectorNonce) != sdkEventID(key, sdkVectorNonce) {
+		t.Error("replayed nonce gave a different click ID")
+	}
+	if sdkEventID(key, sdkVectorNonce) == sdkEventID(key, sdkVectorNonce+"x") {
+		t.Error("different nonces gave the same click ID")
+	}
+	if sdkEventID(key, sdkVectorNonce) == sdkEventID(other, sdkVectorNonce) {
+		t.Error("the same nonce on different keys gave the same click ID")
+	}
+}
+
+func TestSDKCheckOfferAccess(t *testing.T) {
+	advertiserID := uuid.New()
+	offer := &models.Offer{ID: uuid.New(), AdvertiserID: &advertiserID}
+	key := &models.AdvertiserAPIKey{AdvertiserID: advertiserID}
+
+	tests := []struct {
+		name       string
+		key        *models.AdvertiserAPIKey
+		status     string
+		offerID    string
+		wantCode   string
+		wantStatus int
+	}{
+		{"no offer_id", key, models.UserOfferStatusActive, "", "", 0},
+		{"matching offer_id", key, models.UserOfferStatusActive, offer.ID.String(), "", 0},
+		{"unparseable offer_id is ignored", key, models.UserOfferStatusActive, "offer_123", "", 0},
+		{"other offer_id", key, models.UserOfferStatusActive, uuid.New().String(), SDKErrOfferMismatch, http.StatusBadRequest},
+		{"other advertiser's key", &models.AdvertiserAPIKey{AdvertiserID: uuid.New()}, models.UserOfferStatusActive, "", SDKErrOfferNotAllowed, http.StatusForbidden},
+		{"revoked link", key, models.UserOfferStatusRevoked, "", SDKErrLinkInactive, http.StatusForbidden},
+		{"paused link", key, "paused", offer.ID.String(), SDKErrLinkInactive, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		userOffer := &models.UserOffer{OfferID: offer.ID, Offer: offer, Status: tt.status}
+		sdkErr := checkOfferAccess(tt.key, userOffer, tt.offerID)
+		if tt.wantCode == "" {
+			if sdkErr != nil {
+				t.Errorf("%s: checkOfferAccess = %v", tt.name, sdkErr)
+			}
+			continue
+		}
+		if sdkErr == nil || sdkErr.Code != tt.wantCode || sdkErr.Status != tt.wantStatus {
+			t.Errorf("%s: checkOfferAccess = %v, want %s (%d)", tt.name, sdkErr, tt.wantCode, tt.wantStatus)
+		}
+	}
+}
+
+func TestSDKEventTime(t *testing.T) {
+	past := time.Date(2026, 1, 2, 3, 4, 5, 6000000, time.UTC)
+	if got := eventTime(past.UnixMilli()); !got.Equal(past) {
+		t.Errorf("eventTime(past) = %v, want %v", got, past)
+	}
+	future := time.Now().Add(time.Hour)
+	if got := eventTime(future.UnixMilli()); got.After(time.Now()) {
+		t.Errorf("eventTime(future) = %v, not clamped to now", got)
+	}
+}
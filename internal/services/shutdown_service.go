@@ -0,0 +1,277 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ============================================
+// GRACEFUL SHUTDOWN
+// ============================================
+
+// ShutdownPhase orders the steps of a graceful shutdown
+type ShutdownPhase int
+
+const (
+	// ShutdownStopIntake stops accepting work (HTTP server, consumers)
+	ShutdownStopIntake ShutdownPhase = iota
+	// ShutdownDrainWorkers drains queues and worker pools (run concurrently)
+	ShutdownDrainWorkers
+	// ShutdownFlush flushes the WAL and counters
+	ShutdownFlush
+	// ShutdownStopConsumers stops the services fed by the steps above
+	// (webhooks, contests, ...) once nothing can emit to them (run concurrently)
+	ShutdownStopConsumers
+	// ShutdownCloseStores closes Redis and the database
+	ShutdownCloseStores
+)
+
+var shutdownPhaseNames = map[ShutdownPhase]string{
+	ShutdownStopIntake:    "stop_intake",
+	ShutdownDrainWorkers:  "drain_workers",
+	ShutdownFlush:         "flush",
+	ShutdownStopConsumers: "stop_consumers",
+	ShutdownCloseStores:   "close_stores",
+}
+
+const (
+	defaultShutdownTimeout  = 30 * time.Second
+	defaultReadinessDelay   = 5 * time.Second
+	maxShutdownFlushReserve = 5 * time.Second
+)
+
+// ShutdownFunc is a shutdown step. It returns how many items it couldn't
+// drain before ctx expired (they should be spilled for replay or logged).
+type ShutdownFunc func(ctx context.Context) (int, error)
+
+// StopFunc adapts a plain Stop method to a ShutdownFunc
+func StopFunc(stop func()) ShutdownFunc {
+	return func(ctx context.Context) (int, error) {
+		stop()
+		return 0, nil
+	}
+}
+
+// ShutdownStepResult is the outcome of one shutdown step
+type ShutdownStepResult struct {
+	Phase      string `json:"phase"`
+	Name       string `json:"name"`
+	Undrained  int    `json:"undrained"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// ShutdownReport summarizes a graceful shutdown
+type ShutdownReport struct {
+	Steps      []ShutdownStepResult `json:"steps"`
+	Undrained  int                  `json:"undrained"`
+	TimedOut   bool                 `json:"timed_out"`
+	DurationMs int64                `json:"duration_ms"`
+}
+
+type shutdownStep struct {
+	phase ShutdownPhase
+	name  string
+	fn    ShutdownFunc
+}
+
+// ShutdownService flips readiness and runs the registered shutdown steps in
+// phase order within a deadline (SHUTDOWN_TIMEOUT_SECONDS)
+type ShutdownService struct {
+	mu             sync.Mutex
+	steps          []shutdownStep
+	ready          atomic.Bool
+	shuttingDown   atomic.Bool
+	timeout        time.Duration
+	readinessDelay time.Duration
+}
+
+var (
+	shutdownServiceInstance *ShutdownService
+	shutdownServiceOnce     sync.Once
+)
+
+// GetShutdownService returns the global shutdown service
+func GetShutdownService() *ShutdownService {
+	shutdownServiceOnce.Do(func() {
+		shutdownServiceInstance = &ShutdownService{
+			timeout:        envSeconds("SHUTDOWN_TIMEOUT_SECONDS", defaultShutdownTimeout),
+			readinessDelay: envSeconds("SHUTDOWN_READINESS_DELAY_SECONDS", defaultReadinessDelay),
+		}
+		shutdownServiceInstance.ready.Store(true)
+	})
+	return shutdownServiceInstance
+}
+
+// envSeconds reads a duration in seconds from the environment
+func envSeconds(name string, fallback time.Duration) time.Duration {
+	if value := os.Getenv(name); value != "" {
+		if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+			return time.Duration(seconds) * time.Second
+		}
+	}
+	return fallback
+}
+
+// Register adds a shutdown step. Steps of a phase run in registration order,
+// except ShutdownDrainWorkers and ShutdownStopConsumers steps, which run
+// concurrently.
+func (s *ShutdownService) Register(phase ShutdownPhase, name string, fn ShutdownFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.steps = append(s.steps, shutdownStep{phase: phase, name: name, fn: fn})
+}
+
+// IsReady reports whether the instance should receive traffic
+func (s *ShutdownService) IsReady() bool {
+	return s.ready.Load()
+}
+
+// IsShuttingDown reports whether a shutdown has started
+func (s *ShutdownService) IsShuttingDown() bool {
+	return s.shuttingDown.Load()
+}
+
+// Timeout returns the shutdown deadline
+func (s *ShutdownService) Timeout() time.Duration {
+	return s.timeout
+}
+
+// Shutdown flips readiness, waits for load balancers to notice, then runs the
+// steps phase by phase. Draining and stopping consumers get the deadline minus
+// a reserve so the WAL is always flushed and the stores closed.
+func (s *ShutdownService) Shutdown() *ShutdownReport {
+	if !s.shuttingDown.CompareAndSwap(false, true) {
+		return &ShutdownReport{}
+	}
+
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	s.ready.Store(false)
+	fmt.Printf("[Shutdown] Not ready, waiting %v for load balancers (deadline %v)\n", s.readinessDelay, s.timeout)
+	select {
+	case <-time.After(s.readinessDelay):
+	case <-ctx.Done():
+	}
+
+	reserve := s.timeout / 5
+	if reserve > maxShutdownFlushReserve {
+		reserve = maxShutdownFlushReserve
+	}
+	deadline, _ := ctx.Deadline()
+	drainCtx, cancelDrain := context.WithDeadline(ctx, deadline.Add(-reserve))
+	defer cancelDrain()
+
+	s.mu.Lock()
+	steps := append([]shutdownStep(nil), s.steps...)
+	s.mu.Unlock()
+
+	report := &ShutdownReport{}
+	for _, phase := range []ShutdownPhase{ShutdownStopIntake, ShutdownDrainWorkers, ShutdownFlush, ShutdownStopConsumers, ShutdownCloseStores} {
+		phaseCtx := ctx
+		if phase <= ShutdownDrainWorkers || phase == ShutdownStopConsumers {
+			phaseCtx = drainCtx
+		}
+
+		var phaseSteps []shutdownStep
+		for _, step := range steps {
+			if step.phase == phase {
+				phaseSteps = append(phaseSteps, step)
+			}
+		}
+
+		if phase == ShutdownDrainWorkers || phase == ShutdownStopConsumers {
+			results := make([]ShutdownStepResult, len(phaseSteps))
+			var wg sync.WaitGroup
+			for i, step := range phaseSteps {
+				wg.Add(1)
+				go func(i int, step shutdownStep) {
+					defer wg.Done()
+					results[i] = runShutdownStep(phaseCtx, step)
+				}(i, step)
+			}
+			wg.Wait()
+			report.Steps = append(report.Steps, results...)
+		} else {
+			for _, step := range phaseSteps {
+				report.Steps = append(report.Steps, runShutdownStep(phaseCtx, step))
+			}
+		}
+	}
+
+	for _, step := range report.Steps {
+		report.Undrained += step.Undrained
+	}
+	report.TimedOut = ctx.Err() != nil || drainCtx.Err() == context.DeadlineExceeded
+	report.DurationMs = time.Since(start).Milliseconds()
+
+	if report.Undrained > 0 {
+		fmt.Printf("[Shutdown] ⚠️ %d items left undrained (spilled to the WAL/Redis for replay where possible, see above)\n", report.Undrained)
+	}
+	fmt.Printf("[Shutdown] Completed in %dms (timed out: %v)\n", report.DurationMs, report.TimedOut)
+	return report
+}
+
+// runShutdownStep runs one step, recovering from panics so a faulty step
+// can't skip the WAL flush or store close
+func runShutdownStep(ctx context.Context, step shutdownStep) (result ShutdownStepResult) {
+	start := time.Now()
+	result = ShutdownStepResult{Phase: shutdownPhaseNames[step.phase], Name: step.name}
+
+	defer func() {
+		if r := recover(); r != nil {
+			result.Error = fmt.Sprintf("panic: %v", r)
+		}
+		result.DurationMs = time.Since(start).Milliseconds()
+		switch {
+		case result.Error != "":
+			fmt.Printf("[Shutdown] %s/%s failed after %dms: %s\n", result.Phase, result.Name, result.DurationMs, result.Error)
+		case result.Undrained > 0:
+			fmt.Printf("[Shutdown] %s/%s done in %dms, %d undrained\n", result.Phase, result.Name, result.DurationMs, result.Undrained)
+		default:
+			fmt.Printf("[Shutdown] %s/%s done in %dms\n", result.Phase, result.Name, result.DurationMs)
+		}
+	}()
+
+	undrained, err := step.fn(ctx)
+	result.Undrained = undrained
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// ============================================
+// SPILLOVER
+// ============================================
+
+// spillToWAL records an event that couldn't be processed before shutdown, so
+// crash recovery replays it on the next boot
+func spillToWAL(eventType WALEventType, tenantID string, data map[string]interface{}) error {
+	if walServiceInstance == nil {
+		return fmt.Errorf("WAL not initialized")
+	}
+	_, err := walServiceInstance.Append(eventType, tenantID, data)
+	return err
+}
+
+// waitUntil polls done every 50ms until it returns true or ctx expires
+func waitUntil(ctx context.Context, done func() bool) bool {
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	for !done() {
+		select {
+		case <-ctx.Done():
+			return done()
+		case <-ticker.C:
+		}
+	}
+	return true
+}
@@ -0,0 +1,195 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestShutdownService(timeout time.Duration) *ShutdownService {
+	s := &ShutdownService{timeout: timeout}
+	s.ready.Store(true)
+	return s
+}
+
+func TestShutdownPhaseOrder(t *testing.T) {
+	s := newTestShutdownService(5 * time.Second)
+
+	var mu sync.Mutex
+	var order []string
+	step := func(name string) ShutdownFunc {
+		return func(ctx context.Context) (int, error) {
+			mu.Lock()
+			order = append(order, name)
+			mu.Unlock()
+			return 0, nil
+		}
+	}
+
+	// Drain and consumer steps run concurrently: each waits for the other to start
+	aStarted, bStarted := make(chan struct{}), make(chan struct{})
+	cStarted, dStarted := make(chan struct{}), make(chan struct{})
+	concurrent := func(name string, started, other chan struct{}) ShutdownFunc {
+		return func(ctx context.Context) (int, error) {
+			close(started)
+			select {
+			case <-other:
+			case <-time.After(2 * time.Second):
+				return 0, errors.New(name + " steps ran one after the other")
+			}
+			return step(name)(ctx)
+		}
+	}
+
+	// Registered out of order
+	s.Register(ShutdownFlush, "wal", step("wal"))
+	s.Register(ShutdownCloseStores, "database", step("database"))
+	s.Register(ShutdownStopIntake, "http", step("http"))
+	s.Register(ShutdownStopConsumers, "webhooks", concurrent("consumer", cStarted, dStarted))
+	s.Register(ShutdownDrainWorkers, "a", concurrent("drain", aStarted, bStarted))
+	s.Register(ShutdownDrainWorkers, "b", concurrent("drain", bStarted, aStarted))
+	s.Register(ShutdownStopIntake, "stream", step("stream"))
+	s.Register(ShutdownStopConsumers, "badges", concurrent("consumer", dStarted, cStarted))
+	s.Register(ShutdownCloseStores, "redis", step("redis"))
+
+	report := s.Shutdown()
+
+	// Consumers stop only after the producers are drained and flushed
+	want := "http,stream,drain,drain,wal,consumer,consumer,database,redis"
+	if got := strings.Join(order, ","); got != want {
+		t.Errorf("order = %s, want %s", got, want)
+	}
+	for _, result := range report.Steps {
+		if result.Error != "" {
+			t.Errorf("%s/%s: %s", result.Phase, result.Name, result.Error)
+		}
+	}
+	if len(report.Steps) != 9 || report.TimedOut || report.Undrained != 0 {
+		t.Errorf("report = %+v", report)
+	}
+	if phase := report.Steps[5].Phase; phase != "stop_consumers" {
+		t.Errorf("phase after flush = %s, want stop_consumers", phase)
+	}
+	if phases := report.Steps[0].Phase + "," + report.Steps[8].Phase; phases != "stop_intake,close_stores" {
+		t.Errorf("first and last phases = %s", phases)
+	}
+}
+
+func TestShutdownTimeouts(t *testing.T) {
+	const timeout = time.Second
+	reserve := timeout / 5
+	s := newTestShutdownService(timeout)
+
+	var drainDeadline, flushDeadline, consumerDeadline time.Time
+	var flushErr error = errors.New("flush didn't run")
+
+	s.Register(ShutdownDrainWorkers, "stuck", func(ctx context.Context) (int, error) {
+		drainDeadline, _ = ctx.Deadline()
+		<-ctx.Done()
+		return 3, nil
+	})
+	s.Register(ShutdownDrainWorkers, "faulty", func(ctx context.Context) (int, error) {
+		panic("boom")
+	})
+	s.Register(ShutdownFlush, "wal", func(ctx context.Context) (int, error) {
+		flushDeadline, _ = ctx.Deadline()
+		flushErr = ctx.Err()
+		return 0, nil
+	})
+	s.Register(ShutdownStopConsumers, "webhooks", func(ctx context.Context) (int, error) {
+		consumerDeadline, _ = ctx.Deadline()
+		return 0, nil
+	})
+
+	start := time.Now()
+	report := s.Shutdown()
+	elapsed := time.Since(start)
+
+	// Draining stops short of the deadline so the flush still has time
+	if flushErr != nil {
+		t.Errorf("flush ran with an expired context: %v", flushErr)
+	}
+	if gap := flushDeadline.Sub(drainDeadline); gap != reserve {
+		t.Errorf("drain deadline is %v before the shutdown deadline, want %v", gap, reserve)
+	}
+	if !consumerDeadline.Equal(drainDeadline) {
+		t.Errorf("consumer deadline = %v, want the drain deadline %v", consumerDeadline, drainDeadline)
+	}
+	if elapsed < timeout-reserve || elapsed > timeout {
+		t.Errorf("shutdown took %v, want between %v and %v", elapsed, timeout-reserve, timeout)
+	}
+
+	if !report.TimedOut || report.Undrained != 3 {
+		t.Errorf("report timed out = %v, undrained = %d; want true, 3", report.TimedOut, report.Undrained)
+	}
+	var faulty *ShutdownStepResult
+	for i := range report.Steps {
+		if report.Steps[i].Name == "faulty" {
+			faulty = &report.Steps[i]
+		}
+	}
+	if faulty == nil || faulty.Error != "panic: boom" {
+		t.Errorf("panicking step result = %+v, want the panic recorded", faulty)
+	}
+}
+
+func TestShutdownReadiness(t *testing.T) {
+	s := newTestShutdownService(time.Second)
+	runs := 0
+	s.Register(ShutdownStopIntake, "http", func(ctx context.Context) (int, error) {
+		runs++
+		if s.IsReady() || !s.IsShuttingDown() {
+			t.Error("steps ran while still ready")
+		}
+		return 0, nil
+	})
+
+	if !s.IsReady() || s.IsShuttingDown() {
+		t.Fatal("new service isn't ready")
+	}
+	s.Shutdown()
+
+	// A second shutdown (e.g. a second signal) doesn't run the steps again
+	if report := s.Shutdown(); len(report.Steps) != 0 || runs != 1 {
+		t.Errorf("second shutdown ran %d steps (runs = %d)", len(report.Steps), runs)
+	}
+	if s.IsReady() {
+		t.Error("still ready after shutdown")
+	}
+}
+
+func TestEnvSeconds(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", 30 * time.Second},
+		{"10", 10 * time.Second},
+		{"0", 0},
+		{"-5", 30 * time.Second},
+		{"ten", 30 * time.Second},
+		{"1.5", 30 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Setenv("TEST_SHUTDOWN_SECONDS", tt.value)
+		if got := envSeconds("TEST_SHUTDOWN_SECONDS", 30*time.Second); got != tt.want {
+			t.Errorf("envSeconds(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestWaitUntil(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	calls := 0
+	if !waitUntil(ctx, func() bool { calls++; return calls >= 3 }) {
+		t.Error("waitUntil gave up before done")
+	}
+	if waitUntil(ctx, func() bool { return false }) {
+		t.Error("waitUntil reported done after the context expired")
+	}
+}
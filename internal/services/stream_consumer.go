@@ -36,6 +36,7 @@ type StreamMessage struct {
 	TenantID  string                 `json:"tenant_id"`
 	Data      map[string]interface{} `json:"data"`
 	Processed bool                   `json:"processed"`
+	WALID     string                 `json:"wal_id,omitempty"` // Set when the producer already wrote the WAL entry
 }
 
 // ============================================
@@ -82,6 +83,9 @@ func (p *StreamProducer) Publish(ctx context.Context, stream string, msg *Stream
 		"timestamp": msg.Timestamp.Format(time.RFC3339Nano),
 		"data":      string(data),
 	}
+	if msg.WALID != "" {
+		args["wal_id"] = msg.WALID
+	}
 
 	result := cache.RedisClient.XAdd(ctx, &cache.RedisXAddArgs{
 		Stream: stream,
@@ -238,6 +242,11 @@ func (c *StreamConsumer) Start() error {
 	return nil
 }
 
+// IsRunning reports whether the consumer is reading streams
+func (c *StreamConsumer) IsRunning() bool {
+	return c.isRunning
+}
+
 // Stop stops the stream consumer
 func (c *StreamConsumer) Stop() {
 	c.isRunning = false
@@ -317,10 +326,12 @@ func (c *StreamConsumer) processMessage(ctx context.Context, stream string, mess
 		return
 	}
 
-	// Write to WAL before processing
-	if c.walService != nil {
+	// Write to WAL before processing, unless the producer already did
+	if c.walService != nil && msg.WALID == "" {
 		walType := WALEventType(msg.Type)
-		c.walService.Append(walType, msg.TenantID, msg.Data)
+		if entry, err := c.walService.Append(walType, msg.TenantID, msg.Data); err == nil {
+			msg.WALID = entry.ID
+		}
 	}
 
 	// Get handler
@@ -357,8 +368,8 @@ func (c *StreamConsumer) processMessage(ctx context.Context, stream string, mess
 	atomic.AddInt64(&c.totalAcked, 1)
 
 	// Mark WAL entry as processed
-	if c.walService != nil {
-		c.walService.MarkProcessed(msg.ID)
+	if c.walService != nil && msg.WALID != "" {
+		c.walService.MarkProcessed(msg.WALID)
 	}
 }
 
@@ -377,6 +388,9 @@ func (c *StreamConsumer) parseMessage(message cache.RedisXMessage) (*StreamMessa
 	if tenantID, ok := message.Values["tenant_id"].(string); ok {
 		msg.TenantID = tenantID
 	}
+	if walID, ok := message.Values["wal_id"].(string); ok {
+		msg.WALID = walID
+	}
 	if ts, ok := message.Values["timestamp"].(string); ok {
 		if t, err := time.Parse(time.RFC3339Nano, ts); err == nil {
 			msg.Timestamp = t
@@ -0,0 +1,204 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ============================================
+// SUB-ID REPORTS
+// ============================================
+
+const (
+	defaultSubIDReportDays  = 30
+	maxSubIDReportDays      = 365
+	defaultSubIDReportLimit = 100
+	maxSubIDReportLimit     = 500
+)
+
+// SubIDReportRow is the traffic and results of one sub-ID value
+type SubIDReportRow struct {
+	Value               string  `json:"value"`
+	Clicks              int64   `json:"clicks"`
+	Conversions         int64   `json:"conversions"`
+	ApprovedConversions int64   `json:"approved_conversions"`
+	Revenue             int64   `json:"revenue"`    // Cents, all conversions
+	Commission          int64   `json:"commission"` // Cents, approved and paid conversions
+	ConversionRate      float64 `json:"conversion_rate"`
+}
+
+// SubIDReport breaks clicks and conversions down by one sub-ID
+type SubIDReport struct {
+	GroupBy string           `json:"group_by"`
+	From    time.Time        `json:"from"`
+	To      time.Time        `json:"to"`
+	Rows    []SubIDReportRow `json:"rows"`
+	Total   SubIDReportRow   `json:"total"`
+}
+
+// SubIDReportQuery selects the dimension, period and size of a report
+type SubIDReportQuery struct {
+	GroupBy string // sub1..sub5
+	Days    int
+	Limit   int
+}
+
+// SubIDReportService builds sub-ID breakdowns for promoters and advertisers
+type SubIDReportService struct {
+	db *gorm.DB
+}
+
+// NewSubIDReportService creates a new sub-ID report service
+func NewSubIDReportService(db *gorm.DB) *SubIDReportService {
+	return &SubIDReportService{db: db}
+}
+
+// ForPromoter reports on a promoter's user offers, or just one of them
+func (s *SubIDReportService) ForPromoter(userID uuid.UUID, userOfferID *uuid.UUID, query SubIDReportQuery) (*SubIDReport, error) {
+	scope := s.db.Model(&models.UserOffer{}).Select("id").Where("user_id = ?", userID)
+	if userOfferID != nil {
+		scope = scope.Where("id = ?", *userOfferID)
+	}
+	return s.report(scope, query)
+}
+
+// ForOffer reports on all promoters of an offer
+func (s *SubIDReportService) ForOffer(offerID uuid.UUID, query SubIDReportQuery) (*SubIDReport, error) {
+	scope := s.db.Model(&models.UserOffer{}).Select("id").Where("offer_id = ?", offerID)
+	return s.report(scope, query)
+}
+
+// report aggregates the clicks and conversions of the user offers selected by
+// scope (a subquery returning user offer IDs)
+func (s *SubIDReportService) report(scope *gorm.DB, query SubIDReportQuery) (*SubIDReport, error) {
+	column, err := subIDColumn(query.GroupBy)
+	if err != nil {
+		return nil, err
+	}
+
+	days := query.Days
+	if days <= 0 {
+		days = defaultSubIDReportDays
+	}
+	if days > maxSubIDReportDays {
+		days = maxSubIDReportDays
+	}
+	limit := query.Limit
+	if limit <= 0 {
+		limit = defaultSubIDReportLimit
+	}
+	if limit > maxSubIDReportLimit {
+		limit = maxSubIDReportLimit
+	}
+
+	to := time.Now().UTC()
+	from := to.AddDate(0, 0, -days)
+
+	var clickRows []struct {
+		Value  string
+		Clicks int64
+	}
+	if err := s.db.Model(&models.Click{}).
+		Select(column+" AS value, COUNT(*) AS clicks").
+		Where("user_offer_id IN (?) AND clicked_at BETWEEN ? AND ?", scope, from, to).
+		Group(column).
+		Scan(&clickRows).Error; err != nil {
+		return nil, fmt.Errorf("failed to aggregate clicks: %w", err)
+	}
+
+	var conversionRows []struct {
+		Value               string
+		Conversions         int64
+		ApprovedConversions int64
+		Revenue             int64
+		Commission          int64
+	}
+	if err := s.db.Model(&models.Conversion{}).
+		Select(column+` AS value,
+			COUNT(*) AS conversions,
+			COUNT(*) FILTER (WHERE status IN ?) AS approved_conversions,
+			COALESCE(SUM(amount), 0) AS revenue,
+			COALESCE(SUM(commission) FILTER (WHERE status IN ?), 0) AS commission`,
+			creditedConversionStatuses, creditedConversionStatuses).
+		Where("user_offer_id IN (?) AND converted_at BETWEEN ? AND ?", scope, from, to).
+		Group(column).
+		Scan(&conversionRows).Error; err != nil {
+		return nil, fmt.Errorf("failed to aggregate conversions: %w", err)
+	}
+
+	byValue := make(map[string]*SubIDReportRow)
+	row := func(value string) *SubIDReportRow {
+		if r, ok := byValue[value]; ok {
+			return r
+		}
+		r := &SubIDReportRow{Value: value}
+		byValue[value] = r
+		return r
+	}
+	for _, c := range clickRows {
+		row(c.Value).Clicks += c.Clicks
+	}
+	for _, c := range conversionRows {
+		r := row(c.Value)
+		r.Conversions += c.Conversions
+		r.ApprovedConversions += c.ApprovedConversions
+		r.Revenue += c.Revenue
+		r.Commission += c.Commission
+	}
+
+	report := &SubIDReport{
+		GroupBy: query.GroupBy,
+		From:    from,
+		To:      to,
+		Rows:    make([]SubIDReportRow, 0, len(byValue)),
+		Total:   SubIDReportRow{Value: "total"},
+	}
+	for _, r := range byValue {
+		r.ConversionRate = conversionRate(r.Conversions, r.Clicks)
+		report.Rows = append(report.Rows, *r)
+
+		report.Total.Clicks += r.Clicks
+		report.Total.Conversions += r.Conversions
+		report.Total.ApprovedConversions += r.ApprovedConversions
+		report.Total.Revenue += r.Revenue
+		report.Total.Commission += r.Commission
+	}
+	report.Total.ConversionRate = conversionRate(report.Total.Conversions, report.Total.Clicks)
+
+	sort.Slice(report.Rows, func(i, j int) bool {
+		if report.Rows[i].Clicks != report.Rows[j].Clicks {
+			return report.Rows[i].Clicks > report.Rows[j].Clicks
+		}
+		return report.Rows[i].Conversions > report.Rows[j].Conversions
+	})
+	if len(report.Rows) > limit {
+		report.Rows = report.Rows[:limit]
+	}
+
+	return report, nil
+}
+
+// creditedConversionStatuses are the statuses whose commission is earned
+var creditedConversionStatuses = []string{models.ConversionStatusApproved, models.ConversionStatusPaid}
+
+// subIDColumn maps a group_by value to its column
+func subIDColumn(groupBy string) (string, error) {
+	switch groupBy {
+	case "sub1", "sub2", "sub3", "sub4", "sub5":
+		return groupBy, nil
+	}
+	return "", fmt.Errorf("group_by must be one of sub1, sub2, sub3, sub4, sub5")
+}
+
+// conversionRate returns conversions per click as a percentage
+func conversionRate(conversions, clicks int64) float64 {
+	if clicks == 0 {
+		return 0
+	}
+	return float64(conversions) / float64(clicks) * 100
+}
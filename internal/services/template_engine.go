@@ -50,6 +50,9 @@ type TemplateContext struct {
 	// Postback data
 	Postback map[string]interface{} `json:"postback,omitempty"`
 	
+	// Tracking link sub-IDs (sub1..sub5), available unprefixed as {{sub1}}
+	SubIDs map[string]interface{} `json:"sub_ids,omitempty"`
+	
 	// System data
 	Timestamp     int64  `json:"timestamp"`
 	TimestampISO  string `json:"timestamp_iso"`
@@ -70,6 +73,7 @@ func NewTemplateContext() *TemplateContext {
 		Offer:        make(map[string]interface{}),
 		User:         make(map[string]interface{}),
 		Postback:     make(map[string]interface{}),
+		SubIDs:       make(map[string]interface{}),
 		Custom:       make(map[string]interface{}),
 		Timestamp:    now.Unix(),
 		TimestampISO: now.UTC().Format(time.RFC3339),
@@ -115,6 +119,11 @@ func (ctx *TemplateContext) ToMap() map[string]interface{} {
 		result["custom."+k] = v
 	}
 	
+	// Add sub-IDs without prefix
+	for k, v := range ctx.SubIDs {
+		result[k] = v
+	}
+	
 	// Add system data
 	result["timestamp"] = ctx.Timestamp
 	result["timestamp_iso"] = ctx.TimestampISO
@@ -305,6 +314,9 @@ func MergeContexts(contexts ...*TemplateContext) *TemplateContext {
 		for k, v := range ctx.Custom {
 			result.Custom[k] = v
 		}
+		for k, v := range ctx.SubIDs {
+			result.SubIDs[k] = v
+		}
 		
 		// Use latest non-empty values
 		if ctx.CorrelationID != "" {
@@ -512,6 +512,58 @@ func (s *TenantService) CheckDailyClickLimit(tenantID uuid.UUID) (bool, int64, e
 	return int(count) < tenant.MaxClicksPerDay, count, nil
 }
 
+// ============================================
+// OWNERSHIP
+// ============================================
+
+// OfferTenantID returns the tenant an offer belongs to: its advertiser's, or
+// its network's for network offers. Falls back to the default tenant.
+func (s *TenantService) OfferTenantID(offer *models.Offer) uuid.UUID {
+	switch {
+	case offer == nil:
+		return models.DefaultTenantID
+	case offer.AdvertiserID != nil:
+		return s.ownerTenantID("afftok_users", *offer.AdvertiserID)
+	case offer.NetworkID != nil:
+		return s.ownerTenantID("networks", *offer.NetworkID)
+	}
+	return models.DefaultTenantID
+}
+
+// AdvertiserTenantID returns the tenant an advertiser belongs to, or the
+// default tenant
+func (s *TenantService) AdvertiserTenantID(advertiserID uuid.UUID) uuid.UUID {
+	return s.ownerTenantID("afftok_users", advertiserID)
+}
+
+// ownerTenantID reads a row's tenant_id through the cache. Rows without one
+// (or tables not yet migrated) belong to the default tenant.
+func (s *TenantService) ownerTenantID(table string, id uuid.UUID) uuid.UUID {
+	if id == uuid.Nil {
+		return models.DefaultTenantID
+	}
+
+	ctx := context.Background()
+	key := fmt.Sprintf("tenant:owner:%s:%s", table, id.String())
+	if cached, err := cache.Get(ctx, key); err == nil {
+		if tenantID, err := uuid.Parse(cached); err == nil {
+			return tenantID
+		}
+	}
+
+	tenantID := models.DefaultTenantID
+	var row struct {
+		TenantID *uuid.UUID
+	}
+	if err := s.db.Table(table).Select("tenant_id").Where("id = ?", id).Take(&row).Error; err == nil &&
+		row.TenantID != nil && *row.TenantID != uuid.Nil {
+		tenantID = *row.TenantID
+	}
+
+	cache.Set(ctx, key, tenantID.String(), 5*time.Minute)
+	return tenantID
+}
+
 // ============================================
 // CACHING
 // ============================================
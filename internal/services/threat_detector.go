@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"math"
+	"os"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -75,12 +76,16 @@ type AnomalyDetector struct {
 	ipVelocity    map[string]*VelocityTracker
 	keyVelocity   map[string]*VelocityTracker
 	userVelocity  map[string]*VelocityTracker
+	clickVelocity map[string]*VelocityTracker
 	
 	// Thresholds
 	ipRateLimit      int     // requests per minute
+	clickRateLimit   int     // clicks per minute per IP (shared by carrier NAT users)
 	keyRateLimit     int     // requests per minute per API key
+	userRateLimit    int     // requests per minute per user
 	loginRateLimit   int     // login attempts per hour
 	velocitySpike    float64 // percentage increase to trigger
+	spikeBaseline    float64 // average req/min below which spikes aren't judged
 	
 	// Metrics
 	totalAnomalies   int64
@@ -103,10 +108,14 @@ func NewAnomalyDetector() *AnomalyDetector {
 		ipVelocity:     make(map[string]*VelocityTracker),
 		keyVelocity:    make(map[string]*VelocityTracker),
 		userVelocity:   make(map[string]*VelocityTracker),
+		clickVelocity:  make(map[string]*VelocityTracker),
 		ipRateLimit:    100,   // 100 req/min per IP
+		clickRateLimit: 1000,  // 1000 clicks/min per IP
 		keyRateLimit:   1000,  // 1000 req/min per API key
+		userRateLimit:  300,   // 300 req/min per user
 		loginRateLimit: 10,    // 10 login attempts per hour
 		velocitySpike:  200,   // 200% increase triggers alert
+		spikeBaseline:  10,    // judge spikes once an IP averages 10 req/min
 	}
 }
 
@@ -141,32 +150,14 @@ func (d *AnomalyDetector) DetectAnomaly(ip, apiKeyID, userID string) *ThreatEven
 
 // checkIPVelocity checks IP request velocity
 func (d *AnomalyDetector) checkIPVelocity(ip string, now time.Time) *ThreatEvent {
-	tracker, exists := d.ipVelocity[ip]
-	if !exists {
-		tracker = &VelocityTracker{
-			Counts:     make([]int64, 60), // 60 seconds window
-			Timestamps: make([]time.Time, 60),
-			WindowSize: 60,
-		}
-		d.ipVelocity[ip] = tracker
-	}
-
-	// Update count
-	second := now.Second()
-	if tracker.Timestamps[second].Minute() != now.Minute() {
-		tracker.Counts[second] = 0
-		tracker.Timestamps[second] = now
-	}
-	tracker.Counts[second]++
+	total := countPerMinute(d.ipVelocity, ip, now)
+	tracker := d.ipVelocity[ip]
 	tracker.LastCount++
 
-	// Calculate total in last minute
-	var total int64
-	for i := 0; i < 60; i++ {
-		if now.Sub(tracker.Timestamps[i]) < time.Minute {
-			total += tracker.Counts[i]
-		}
-	}
+	// The average follows every request, so a spike is judged against the
+	// rate before it and a sustained rate becomes the new normal
+	average := tracker.Average
+	tracker.Average = (tracker.Average*0.9 + float64(total)*0.1)
 
 	// Check threshold
 	if total > int64(d.ipRateLimit) {
@@ -187,9 +178,9 @@ func (d *AnomalyDetector) checkIPVelocity(ip string, now time.Time) *ThreatEvent
 		}
 	}
 
-	// Check for velocity spike
-	if tracker.Average > 0 {
-		increase := (float64(total) / tracker.Average) * 100
+	// Check for velocity spike, once the IP has a meaningful baseline
+	if average >= d.spikeBaseline {
+		increase := (float64(total) / average) * 100
 		if increase > d.velocitySpike {
 			atomic.AddInt64(&d.totalAnomalies, 1)
 			return &ThreatEvent{
@@ -203,45 +194,49 @@ func (d *AnomalyDetector) checkIPVelocity(ip string, now time.Time) *ThreatEvent
 				Action:      "flagged",
 				Metadata: map[string]interface{}{
 					"current_rate": total,
-					"average_rate": tracker.Average,
+					"average_rate": average,
 					"increase":     increase,
 				},
 			}
 		}
 	}
 
-	// Update average
-	tracker.Average = (tracker.Average*0.9 + float64(total)*0.1)
-
 	return nil
 }
 
-// checkKeyVelocity checks API key request velocity
-func (d *AnomalyDetector) checkKeyVelocity(keyID string, now time.Time) *ThreatEvent {
-	tracker, exists := d.keyVelocity[keyID]
-	if !exists {
-		tracker = &VelocityTracker{
-			Counts:     make([]int64, 60),
-			Timestamps: make([]time.Time, 60),
-			WindowSize: 60,
-		}
-		d.keyVelocity[keyID] = tracker
-	}
+// DetectClickAnomaly checks the click rate of an IP. Clicks are counted apart
+// from other requests, against a limit high enough for the many users a
+// carrier NAT puts behind one address.
+func (d *AnomalyDetector) DetectClickAnomaly(ip string) *ThreatEvent {
+	d.mu.Lock()
+	defer d.mu.Unlock()
 
-	second := now.Second()
-	if tracker.Timestamps[second].Minute() != now.Minute() {
-		tracker.Counts[second] = 0
-		tracker.Timestamps[second] = now
+	now := time.Now()
+	total := countPerMinute(d.clickVelocity, ip, now)
+	if total <= int64(d.clickRateLimit) {
+		return nil
 	}
-	tracker.Counts[second]++
 
-	var total int64
-	for i := 0; i < 60; i++ {
-		if now.Sub(tracker.Timestamps[i]) < time.Minute {
-			total += tracker.Counts[i]
-		}
+	atomic.AddInt64(&d.totalAnomalies, 1)
+	return &ThreatEvent{
+		ID:          fmt.Sprintf("threat_%d", now.UnixNano()),
+		Type:        ThreatVelocityAbuse,
+		Severity:    SeverityHigh,
+		IP:          ip,
+		Description: fmt.Sprintf("IP exceeded click rate limit: %d clicks/min (limit: %d)", total, d.clickRateLimit),
+		Timestamp:   now,
+		Blocked:     true,
+		Action:      "rate_limited",
+		Metadata: map[string]interface{}{
+			"clicks_per_minute": total,
+			"limit":             d.clickRateLimit,
+		},
 	}
+}
 
+// checkKeyVelocity checks API key request velocity
+func (d *AnomalyDetector) checkKeyVelocity(keyID string, now time.Time) *ThreatEvent {
+	total := countPerMinute(d.keyVelocity, keyID, now)
 	if total > int64(d.keyRateLimit) {
 		atomic.AddInt64(&d.totalAnomalies, 1)
 		return &ThreatEvent{
@@ -261,12 +256,67 @@ func (d *AnomalyDetector) checkKeyVelocity(keyID string, now time.Time) *ThreatE
 
 // checkUserVelocity checks user request velocity
 func (d *AnomalyDetector) checkUserVelocity(userID string, now time.Time) *ThreatEvent {
-	// Similar implementation for user-level tracking
+	total := countPerMinute(d.userVelocity, userID, now)
+	if total > int64(d.userRateLimit) {
+		atomic.AddInt64(&d.totalAnomalies, 1)
+		return &ThreatEvent{
+			ID:          fmt.Sprintf("threat_%d", now.UnixNano()),
+			Type:        ThreatVelocityAbuse,
+			Severity:    SeverityHigh,
+			UserID:      userID,
+			Description: fmt.Sprintf("User exceeded rate limit: %d req/min", total),
+			Timestamp:   now,
+			Blocked:     true,
+			Action:      "rate_limited",
+			Metadata: map[string]interface{}{
+				"requests_per_minute": total,
+				"limit":               d.userRateLimit,
+			},
+		}
+	}
+
 	return nil
 }
 
-// DetectLoginAnomaly checks for login anomalies
+// countPerMinute records a request for key in trackers and returns the
+// number of requests it made in the last minute
+func countPerMinute(trackers map[string]*VelocityTracker, key string, now time.Time) int64 {
+	tracker, exists := trackers[key]
+	if !exists {
+		tracker = &VelocityTracker{
+			Counts:     make([]int64, 60),
+			Timestamps: make([]time.Time, 60),
+			WindowSize: 60,
+		}
+		trackers[key] = tracker
+	}
+
+	// Slots are reused every minute, so reset one left over from an earlier
+	// second (including a fresh slot, or one from the same minute of another
+	// hour)
+	second := now.Second()
+	if !tracker.Timestamps[second].Truncate(time.Second).Equal(now.Truncate(time.Second)) {
+		tracker.Counts[second] = 0
+		tracker.Timestamps[second] = now
+	}
+	tracker.Counts[second]++
+
+	var total int64
+	for i := 0; i < 60; i++ {
+		if now.Sub(tracker.Timestamps[i]) < time.Minute {
+			total += tracker.Counts[i]
+		}
+	}
+	return total
+}
+
+// DetectLoginAnomaly checks for login anomalies. Only failed attempts count
+// towards the hourly limit (shared across replicas through Redis).
 func (d *AnomalyDetector) DetectLoginAnomaly(ip, userID string, success bool) *ThreatEvent {
+	if success {
+		return nil
+	}
+
 	ctx := context.Background()
 	key := fmt.Sprintf("login_attempts:%s:%s", ip, time.Now().Format("2006010215"))
 
@@ -274,7 +324,7 @@ func (d *AnomalyDetector) DetectLoginAnomaly(ip, userID string, success bool) *T
 	count, _ := cache.Increment(ctx, key)
 	cache.Expire(ctx, key, time.Hour)
 
-	if !success && count > int64(d.loginRateLimit) {
+	if count > int64(d.loginRateLimit) {
 		atomic.AddInt64(&d.totalAnomalies, 1)
 		return &ThreatEvent{
 			ID:          fmt.Sprintf("threat_%d", time.Now().UnixNano()),
@@ -322,6 +372,7 @@ type SuspiciousIPService struct {
 	// IP tracking
 	suspiciousIPs map[string]*SuspiciousIPInfo
 	blockedIPs    map[string]*BlockedIPInfo
+	blockLookups  map[string]blockLookup // Recent Redis block lookups
 	
 	// Thresholds
 	suspiciousThreshold int // Score to mark as suspicious
@@ -340,6 +391,27 @@ type SuspiciousIPInfo struct {
 	ThreatTypes   []ThreatType `json:"threat_types"`
 }
 
+// blockLookup caches a Redis block lookup for blockLookupTTL
+type blockLookup struct {
+	blocked   bool
+	checkedAt time.Time
+}
+
+const (
+	// blockLookupTTL bounds how long a replica takes to see a block or
+	// unblock made on another replica
+	blockLookupTTL       = 5 * time.Second
+	maxBlockLookups      = 50000
+	suspiciousScoreReset = time.Hour // Quiet time after which an IP's score restarts
+)
+
+// blockedIPKey is where blocks made through the threat detector are stored.
+// Blocks made through /admin/fraud/block-ip are stored under
+// NSFraud+"blocked_ip:" and are enforced too.
+func blockedIPKey(ip string) string {
+	return fmt.Sprintf("blocked_ip:%s", ip)
+}
+
 // BlockedIPInfo holds information about a blocked IP
 type BlockedIPInfo struct {
 	IP          string    `json:"ip"`
@@ -356,6 +428,7 @@ func NewSuspiciousIPService() *SuspiciousIPService {
 		observability:       NewObservabilityService(),
 		suspiciousIPs:       make(map[string]*SuspiciousIPInfo),
 		blockedIPs:          make(map[string]*BlockedIPInfo),
+		blockLookups:        make(map[string]blockLookup),
 		suspiciousThreshold: 50,
 		blockThreshold:      100,
 	}
@@ -379,6 +452,9 @@ func (s *SuspiciousIPService) ReportSuspiciousActivity(ip string, threatType Thr
 		s.suspiciousIPs[ip] = info
 	}
 
+	if exists && now.Sub(info.LastSeen) > suspiciousScoreReset {
+		info.Score = 0
+	}
 	info.Score += score
 	info.LastSeen = now
 	info.RequestCount++
@@ -406,9 +482,11 @@ func (s *SuspiciousIPService) blockIP(ip, reason string, duration time.Duration,
 		BlockedBy: blockedBy,
 	}
 
+	s.blockLookups[ip] = blockLookup{blocked: true, checkedAt: now}
+
 	// Persist to Redis
 	ctx := context.Background()
-	key := fmt.Sprintf("blocked_ip:%s", ip)
+	key := blockedIPKey(ip)
 	data, _ := json.Marshal(s.blockedIPs[ip])
 	if duration > 0 {
 		cache.Set(ctx, key, string(data), duration)
@@ -417,32 +495,68 @@ func (s *SuspiciousIPService) blockIP(ip, reason string, duration time.Duration,
 	}
 }
 
-// IsBlocked checks if an IP is blocked
+// IsBlocked checks if an IP is blocked. Redis is the source of truth so
+// blocks apply on every replica; lookups are cached for blockLookupTTL and
+// the local blocks are used while Redis is unavailable.
 func (s *SuspiciousIPService) IsBlocked(ip string) bool {
+	now := time.Now()
+
 	s.mu.RLock()
-	info, exists := s.blockedIPs[ip]
+	lookup, cached := s.blockLookups[ip]
 	s.mu.RUnlock()
-
-	if exists {
-		if info.Permanent || time.Now().Before(info.ExpiresAt) {
-			return true
-		}
-		// Expired, remove from map
-		s.mu.Lock()
-		delete(s.blockedIPs, ip)
-		s.mu.Unlock()
+	if cached && now.Sub(lookup.checkedAt) < blockLookupTTL {
+		return lookup.blocked
 	}
 
-	// Check Redis
 	ctx := context.Background()
-	key := fmt.Sprintf("blocked_ip:%s", ip)
-	if data, err := cache.Get(ctx, key); err == nil && data != "" {
+	count, err := cache.Exists(ctx, blockedIPKey(ip), NSFraud+"blocked_ip:"+ip)
+	if err != nil {
+		return s.isBlockedLocally(ip, now)
+	}
+
+	blocked := count > 0
+	s.mu.Lock()
+	if len(s.blockLookups) >= maxBlockLookups {
+		s.blockLookups = make(map[string]blockLookup)
+	}
+	s.blockLookups[ip] = blockLookup{blocked: blocked, checkedAt: now}
+	s.mu.Unlock()
+
+	return blocked
+}
+
+// isBlockedLocally checks the blocks made on this replica
+func (s *SuspiciousIPService) isBlockedLocally(ip string, now time.Time) bool {
+	s.mu.RLock()
+	info, exists := s.blockedIPs[ip]
+	s.mu.RUnlock()
+
+	if !exists {
+		return false
+	}
+	if info.Permanent || now.Before(info.ExpiresAt) {
 		return true
 	}
 
+	// Expired, remove from map
+	s.mu.Lock()
+	delete(s.blockedIPs, ip)
+	s.mu.Unlock()
 	return false
 }
 
+// Score returns an IP's current suspicion score
+func (s *SuspiciousIPService) Score(ip string) int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	info, exists := s.suspiciousIPs[ip]
+	if !exists || time.Since(info.LastSeen) > suspiciousScoreReset {
+		return 0
+	}
+	return info.Score
+}
+
 // BlockIP manually blocks an IP
 func (s *SuspiciousIPService) BlockIP(ip, reason string, duration time.Duration, blockedBy string) {
 	s.mu.Lock()
@@ -456,9 +570,10 @@ func (s *SuspiciousIPService) UnblockIP(ip string) {
 	defer s.mu.Unlock()
 	
 	delete(s.blockedIPs, ip)
+	delete(s.blockLookups, ip)
 	
 	ctx := context.Background()
-	cache.Delete(ctx, fmt.Sprintf("blocked_ip:%s", ip))
+	cache.Delete(ctx, blockedIPKey(ip), NSFraud+"blocked_ip:"+ip)
 }
 
 // GetSuspiciousIPs returns all suspicious IPs
@@ -487,8 +602,13 @@ func (s *SuspiciousIPService) GetSuspiciousIPs(limit int) []*SuspiciousIPInfo {
 	return result
 }
 
-// GetBlockedIPs returns all blocked IPs
+// GetBlockedIPs returns all blocked IPs, across replicas when Redis is
+// available
 func (s *SuspiciousIPService) GetBlockedIPs() []*BlockedIPInfo {
+	if result, err := s.getBlockedIPsFromRedis(); err == nil {
+		return result
+	}
+
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
@@ -499,6 +619,66 @@ func (s *SuspiciousIPService) GetBlockedIPs() []*BlockedIPInfo {
 	return result
 }
 
+// getBlockedIPsFromRedis lists the blocks stored in Redis, including those
+// made through /admin/fraud/block-ip
+func (s *SuspiciousIPService) getBlockedIPsFromRedis() ([]*BlockedIPInfo, error) {
+	if cache.RedisClient == nil {
+		return nil, fmt.Errorf("Redis client not initialized")
+	}
+
+	ctx := context.Background()
+	result := make([]*BlockedIPInfo, 0)
+	seen := make(map[string]bool)
+
+	var cursor uint64
+	for {
+		keys, next, err := cache.RedisClient.Scan(ctx, cursor, blockedIPKey("*"), 100).Result()
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range keys {
+			data, err := cache.Get(ctx, key)
+			if err != nil {
+				continue
+			}
+			var info BlockedIPInfo
+			if json.Unmarshal([]byte(data), &info) == nil && !seen[info.IP] {
+				seen[info.IP] = true
+				result = append(result, &info)
+			}
+		}
+		if cursor = next; cursor == 0 {
+			break
+		}
+	}
+
+	cursor = 0
+	for {
+		keys, next, err := cache.RedisClient.Scan(ctx, cursor, NSFraud+"blocked_ip:*", 100).Result()
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range keys {
+			data, err := cache.RedisClient.HGetAll(ctx, key).Result()
+			if err != nil || len(data) == 0 || seen[data["ip"]] {
+				continue
+			}
+			seen[data["ip"]] = true
+			info := &BlockedIPInfo{IP: data["ip"], Reason: data["reason"], BlockedBy: data["blocked_by"]}
+			info.BlockedAt, _ = time.Parse(time.RFC3339, data["blocked_at"])
+			if ttl, err := cache.RedisClient.TTL(ctx, key).Result(); err == nil && ttl > 0 {
+				info.ExpiresAt = time.Now().Add(ttl)
+			}
+			result = append(result, info)
+		}
+		if cursor = next; cursor == 0 {
+			break
+		}
+	}
+
+	return result, nil
+}
+
 // persistSuspiciousIP persists suspicious IP to Redis
 func (s *SuspiciousIPService) persistSuspiciousIP(ip string, info *SuspiciousIPInfo) {
 	ctx := context.Background()
@@ -530,6 +710,7 @@ type ThreatDetector struct {
 	anomalyDetector  *AnomalyDetector
 	suspiciousIPs    *SuspiciousIPService
 	observability    *ObservabilityService
+	enforceClicks    bool // THREAT_ENFORCE_CLICKS: escalate click threats instead of only recording them
 	
 	// Threat history
 	recentThreats    []*ThreatEvent
@@ -546,6 +727,7 @@ func NewThreatDetector() *ThreatDetector {
 		anomalyDetector:  NewAnomalyDetector(),
 		suspiciousIPs:    NewSuspiciousIPService(),
 		observability:    NewObservabilityService(),
+		enforceClicks:    os.Getenv("THREAT_ENFORCE_CLICKS") == "true",
 		recentThreats:    make([]*ThreatEvent, 0),
 		maxRecentThreats: 1000,
 		threatsByType:    make(map[ThreatType]int64),
@@ -556,17 +738,7 @@ func NewThreatDetector() *ThreatDetector {
 func (t *ThreatDetector) DetectThreat(ip, apiKeyID, userID, tenantID string) *ThreatEvent {
 	// Check if IP is blocked
 	if t.suspiciousIPs.IsBlocked(ip) {
-		return &ThreatEvent{
-			ID:          fmt.Sprintf("threat_%d", time.Now().UnixNano()),
-			Type:        ThreatSuspiciousIP,
-			Severity:    SeverityCritical,
-			IP:          ip,
-			TenantID:    tenantID,
-			Description: "Request from blocked IP",
-			Timestamp:   time.Now(),
-			Blocked:     true,
-			Action:      "blocked",
-		}
+		return blockedIPThreat(ip, tenantID)
 	}
 
 	// Run anomaly detection
@@ -579,6 +751,21 @@ func (t *ThreatDetector) DetectThreat(ip, apiKeyID, userID, tenantID string) *Th
 	return nil
 }
 
+// blockedIPThreat is the threat reported for a request from a blocked IP
+func blockedIPThreat(ip, tenantID string) *ThreatEvent {
+	return &ThreatEvent{
+		ID:          fmt.Sprintf("threat_%d", time.Now().UnixNano()),
+		Type:        ThreatSuspiciousIP,
+		Severity:    SeverityCritical,
+		IP:          ip,
+		TenantID:    tenantID,
+		Description: "Request from blocked IP",
+		Timestamp:   time.Now(),
+		Blocked:     true,
+		Action:      "blocked",
+	}
+}
+
 // RecordThreat records a detected threat
 func (t *ThreatDetector) RecordThreat(threat *ThreatEvent) {
 	t.recordThreat(threat)
@@ -586,6 +773,16 @@ func (t *ThreatDetector) RecordThreat(threat *ThreatEvent) {
 
 // recordThreat internal method to record threat
 func (t *ThreatDetector) recordThreat(threat *ThreatEvent) {
+	t.noteThreat(threat)
+
+	// Report to suspicious IP service
+	score := t.getSeverityScore(threat.Severity)
+	t.suspiciousIPs.ReportSuspiciousActivity(threat.IP, threat.Type, score, threat.Description)
+}
+
+// noteThreat adds a threat to the history, metrics and logs without scoring
+// its IP
+func (t *ThreatDetector) noteThreat(threat *ThreatEvent) {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
@@ -598,10 +795,6 @@ func (t *ThreatDetector) recordThreat(threat *ThreatEvent) {
 		t.recentThreats = t.recentThreats[1:]
 	}
 
-	// Report to suspicious IP service
-	score := t.getSeverityScore(threat.Severity)
-	t.suspiciousIPs.ReportSuspiciousActivity(threat.IP, threat.Type, score, threat.Description)
-
 	// Log threat
 	t.observability.Log(LogEvent{
 		Category: LogCategoryFraudDetection,
@@ -689,6 +882,124 @@ func (t *ThreatDetector) GetSuspiciousIPs(limit int) []*SuspiciousIPInfo {
 	return t.suspiciousIPs.GetSuspiciousIPs(limit)
 }
 
+// DetectLoginAnomaly records a login attempt and returns (and records) a
+// threat if the IP has too many failed attempts
+func (t *ThreatDetector) DetectLoginAnomaly(ip, userID string, success bool) *ThreatEvent {
+	threat := t.anomalyDetector.DetectLoginAnomaly(ip, userID, success)
+	if threat != nil {
+		t.recordThreat(threat)
+	}
+	return threat
+}
+
+// ============================================
+// ENFORCEMENT
+// ============================================
+
+// EnforcementAction is what the request pipeline does about a request
+type EnforcementAction string
+
+const (
+	EnforcementAllow     EnforcementAction = "allow"     // No threat
+	EnforcementMonitor   EnforcementAction = "monitor"   // Threat recorded, request served
+	EnforcementChallenge EnforcementAction = "challenge" // Request refused, client must slow down
+	EnforcementBlock     EnforcementAction = "block"     // IP blocked
+)
+
+// ChallengeRetryAfter is how long challenged clients are asked to back off
+const ChallengeRetryAfter = time.Minute
+
+// EnforcementDecision is the outcome of evaluating a request
+type EnforcementDecision struct {
+	Action EnforcementAction `json:"action"`
+	Threat *ThreatEvent      `json:"threat,omitempty"`
+}
+
+// enforcementForSeverity maps a threat's severity to the action it warrants
+// on its own
+func enforcementForSeverity(severity ThreatSeverity) EnforcementAction {
+	switch severity {
+	case SeverityCritical:
+		return EnforcementBlock
+	case SeverityHigh:
+		return EnforcementChallenge
+	default:
+		return EnforcementMonitor
+	}
+}
+
+// Evaluate runs threat detection for a request and decides what to do with
+// it. Threats escalate from monitor to challenge to block: low and medium
+// severity threats are only monitored until the IP's score passes the
+// suspicious threshold, high severity ones are challenged, and an IP is
+// blocked (on every replica, for 24h) once its score passes the block
+// threshold or a critical threat is detected.
+func (t *ThreatDetector) Evaluate(ip, apiKeyID, userID, tenantID string) *EnforcementDecision {
+	threat := t.DetectThreat(ip, apiKeyID, userID, tenantID)
+	if threat == nil {
+		return &EnforcementDecision{Action: EnforcementAllow}
+	}
+	return t.decide(ip, threat)
+}
+
+// EvaluateClick decides what to do with a click. Blocked IPs are refused, but
+// click velocity alone is only monitored (recorded without raising the IP's
+// score) unless THREAT_ENFORCE_CLICKS is set: busy carrier NAT addresses are
+// indistinguishable from click floods by rate.
+func (t *ThreatDetector) EvaluateClick(ip, tenantID string) *EnforcementDecision {
+	if t.suspiciousIPs.IsBlocked(ip) {
+		atomic.AddInt64(&t.anomalyDetector.blockedRequests, 1)
+		return &EnforcementDecision{Action: EnforcementBlock, Threat: blockedIPThreat(ip, tenantID)}
+	}
+
+	threat := t.anomalyDetector.DetectClickAnomaly(ip)
+	if threat == nil {
+		return &EnforcementDecision{Action: EnforcementAllow}
+	}
+	threat.TenantID = tenantID
+
+	if t.enforceClicks {
+		t.recordThreat(threat)
+		return t.decide(ip, threat)
+	}
+
+	threat.Action = string(EnforcementMonitor)
+	threat.Blocked = false
+	t.noteThreat(threat)
+	return &EnforcementDecision{Action: EnforcementMonitor, Threat: threat}
+}
+
+// decide escalates a detected (and recorded) threat to the action it warrants
+// given the IP's score
+func (t *ThreatDetector) decide(ip string, threat *ThreatEvent) *EnforcementDecision {
+	action := enforcementForSeverity(threat.Severity)
+	if threat.Type == ThreatSuspiciousIP && threat.Action == "blocked" {
+		// Already blocked, nothing new to record
+		atomic.AddInt64(&t.anomalyDetector.blockedRequests, 1)
+		return &EnforcementDecision{Action: EnforcementBlock, Threat: threat}
+	}
+
+	if action == EnforcementMonitor && t.suspiciousIPs.Score(ip) >= t.suspiciousIPs.suspiciousThreshold {
+		action = EnforcementChallenge
+	}
+	if action == EnforcementBlock {
+		t.BlockIP(ip, threat.Description, 24*time.Hour, "system")
+	} else if t.suspiciousIPs.IsBlocked(ip) {
+		// Recording the threat pushed the IP over the block threshold
+		action = EnforcementBlock
+	}
+
+	t.mu.Lock()
+	threat.Action = string(action)
+	threat.Blocked = action != EnforcementMonitor
+	t.mu.Unlock()
+	if threat.Blocked {
+		atomic.AddInt64(&t.anomalyDetector.blockedRequests, 1)
+	}
+
+	return &EnforcementDecision{Action: action, Threat: threat}
+}
+
 // ============================================
 // GLOBAL INSTANCE
 // ============================================
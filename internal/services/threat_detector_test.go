@@ -0,0 +1,222 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCountPerMinute(t *testing.T) {
+	trackers := map[string]*VelocityTracker{}
+	now := time.Date(2026, 3, 1, 12, 0, 30, 0, time.UTC)
+
+	for i := 0; i < 3; i++ {
+		countPerMinute(trackers, "a", now)
+	}
+	if got := countPerMinute(trackers, "a", now.Add(10*time.Second)); got != 4 {
+		t.Errorf("count within the minute = %d, want 4", got)
+	}
+	if got := countPerMinute(trackers, "b", now); got != 1 {
+		t.Errorf("count for another key = %d, want 1", got)
+	}
+
+	// Requests older than a minute drop out of the window
+	if got := countPerMinute(trackers, "a", now.Add(65*time.Second)); got != 2 {
+		t.Errorf("count a minute later = %d, want 2", got)
+	}
+	if got := countPerMinute(trackers, "a", now.Add(3*time.Minute)); got != 1 {
+		t.Errorf("count after the window = %d, want 1", got)
+	}
+}
+
+func TestCountPerMinuteResetsStaleSlots(t *testing.T) {
+	trackers := map[string]*VelocityTracker{}
+	now := time.Date(2026, 3, 1, 12, 5, 30, 0, time.UTC)
+
+	for i := 0; i < 5; i++ {
+		countPerMinute(trackers, "a", now)
+	}
+	// Same second and minute, an hour later: the slot must not be reused
+	if got := countPerMinute(trackers, "a", now.Add(time.Hour)); got != 1 {
+		t.Errorf("count an hour later = %d, want 1", got)
+	}
+}
+
+func TestDetectAnomalyIPVelocity(t *testing.T) {
+	d := NewAnomalyDetector()
+	d.ipRateLimit = 20
+
+	ip := "198.51.100.7"
+	for i := 1; i <= d.ipRateLimit; i++ {
+		if threat := d.DetectAnomaly(ip, "", ""); threat != nil {
+			t.Fatalf("request %d flagged below the limit: %s", i, threat.Description)
+		}
+	}
+
+	threat := d.DetectAnomaly(ip, "", "")
+	if threat == nil {
+		t.Fatal("request over the limit was not flagged")
+	}
+	if threat.Type != ThreatVelocityAbuse || threat.Severity != SeverityHigh || threat.IP != ip {
+		t.Errorf("threat = %s/%s for %s, want %s/%s for %s", threat.Type, threat.Severity, threat.IP, ThreatVelocityAbuse, SeverityHigh, ip)
+	}
+
+	// Another IP has its own window
+	if threat := d.DetectAnomaly("198.51.100.8", "", ""); threat != nil {
+		t.Errorf("fresh IP flagged: %s", threat.Description)
+	}
+}
+
+func TestDetectAnomalyVelocitySpike(t *testing.T) {
+	d := NewAnomalyDetector()
+	ip := "198.51.100.9"
+	tracker := func() *VelocityTracker { return d.ipVelocity[ip] }
+
+	// A handful of requests is no baseline to judge a spike against
+	d.DetectAnomaly(ip, "", "")
+	for i := 0; i < 5; i++ {
+		if threat := d.DetectAnomaly(ip, "", ""); threat != nil {
+			t.Fatalf("request %d flagged as a spike: %s", i+2, threat.Description)
+		}
+	}
+
+	// An IP established at 12 req/min bursting to over three times that is
+	// flagged, but not blocked
+	for i := 0; i < 30; i++ {
+		countPerMinute(d.ipVelocity, ip, time.Now())
+	}
+	tracker().Average = 12
+	threat := d.DetectAnomaly(ip, "", "")
+	if threat == nil {
+		t.Fatal("spike was not flagged")
+	}
+	if threat.Severity != SeverityMedium || threat.Blocked {
+		t.Errorf("spike = %s (blocked %v), want %s and not blocked", threat.Severity, threat.Blocked, SeverityMedium)
+	}
+}
+
+func TestDetectAnomalyVelocity(t *testing.T) {
+	tests := []struct {
+		name     string
+		apiKeyID string
+		userID   string
+		wantType ThreatType
+	}{
+		{"anonymous", "", "", ""},
+		{"API key over the limit", "key-1", "", ThreatAPIKeyAbuse},
+		{"user over the limit", "", "user-1", ThreatVelocityAbuse},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewAnomalyDetector()
+			d.ipRateLimit = 1000
+			d.keyRateLimit = 3
+			d.userRateLimit = 3
+
+			// Requests come from different IPs, so only the key or the
+			// user can trip a limit
+			var threat *ThreatEvent
+			ips := []string{"198.51.100.1", "198.51.100.2", "198.51.100.3", "198.51.100.4"}
+			for i, ip := range ips {
+				threat = d.DetectAnomaly(ip, tt.apiKeyID, tt.userID)
+				if threat != nil && i < len(ips)-1 {
+					t.Fatalf("request %d flagged below the limit: %s", i+1, threat.Description)
+				}
+			}
+
+			if tt.wantType == "" {
+				if threat != nil {
+					t.Fatalf("unexpected threat: %s", threat.Description)
+				}
+				return
+			}
+			if threat == nil {
+				t.Fatal("request over the limit was not flagged")
+			}
+			if threat.Type != tt.wantType || threat.Severity != SeverityHigh {
+				t.Errorf("threat = %s/%s, want %s/%s", threat.Type, threat.Severity, tt.wantType, SeverityHigh)
+			}
+			if threat.APIKeyID != tt.apiKeyID || threat.UserID != tt.userID {
+				t.Errorf("threat subject = %q/%q, want %q/%q", threat.APIKeyID, threat.UserID, tt.apiKeyID, tt.userID)
+			}
+		})
+	}
+}
+
+func TestEnforcementForSeverity(t *testing.T) {
+	tests := map[ThreatSeverity]EnforcementAction{
+		SeverityLow:      EnforcementMonitor,
+		SeverityMedium:   EnforcementMonitor,
+		SeverityHigh:     EnforcementChallenge,
+		SeverityCritical: EnforcementBlock,
+	}
+	for severity, want := range tests {
+		if got := enforcementForSeverity(severity); got != want {
+			t.Errorf("enforcementForSeverity(%s) = %s, want %s", severity, got, want)
+		}
+	}
+}
+
+func TestEvaluateClick(t *testing.T) {
+	ip := "203.0.113.10"
+	flood := func(td *ThreatDetector) *EnforcementDecision {
+		var decision *EnforcementDecision
+		for i := 0; i <= td.anomalyDetector.clickRateLimit; i++ {
+			decision = td.EvaluateClick(ip, "")
+		}
+		return decision
+	}
+
+	t.Run("below the click limit", func(t *testing.T) {
+		td := NewThreatDetector()
+		// Well past the general per-IP limit, as a carrier NAT address is
+		for i := 0; i < 3*td.anomalyDetector.ipRateLimit; i++ {
+			if decision := td.EvaluateClick(ip, ""); decision.Action != EnforcementAllow {
+				t.Fatalf("click %d: action = %s, want allow", i+1, decision.Action)
+			}
+		}
+	})
+
+	t.Run("monitored by default", func(t *testing.T) {
+		td := NewThreatDetector()
+		td.anomalyDetector.clickRateLimit = 5
+
+		decision := flood(td)
+		for i := 0; i < 10; i++ {
+			decision = td.EvaluateClick(ip, "")
+		}
+		if decision.Action != EnforcementMonitor || decision.Threat == nil || decision.Threat.Blocked {
+			t.Fatalf("decision = %+v, want an unblocked monitor", decision)
+		}
+		if score := td.suspiciousIPs.Score(ip); score != 0 {
+			t.Errorf("click threats raised the IP's score to %d", score)
+		}
+		if td.suspiciousIPs.isBlockedLocally(ip, time.Now()) {
+			t.Error("click flood blocked the IP")
+		}
+		if threats := td.GetRecentThreats(0); len(threats) == 0 {
+			t.Error("click threats were not recorded")
+		}
+	})
+
+	t.Run("enforced when enabled", func(t *testing.T) {
+		td := NewThreatDetector()
+		td.enforceClicks = true
+		td.anomalyDetector.clickRateLimit = 5
+
+		if decision := flood(td); decision.Action != EnforcementChallenge {
+			t.Errorf("action = %s, want challenge", decision.Action)
+		}
+		if score := td.suspiciousIPs.Score(ip); score == 0 {
+			t.Error("enforced click threat didn't raise the IP's score")
+		}
+	})
+
+	t.Run("blocked IP", func(t *testing.T) {
+		td := NewThreatDetector()
+		td.BlockIP(ip, "test", time.Hour, "admin")
+		if decision := td.EvaluateClick(ip, ""); decision.Action != EnforcementBlock {
+			t.Errorf("action = %s, want block", decision.Action)
+		}
+	})
+}
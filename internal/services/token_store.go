@@ -0,0 +1,355 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aljapah/afftok-backend-prod/internal/cache"
+	"github.com/aljapah/afftok-backend-prod/pkg/utils"
+	"github.com/google/uuid"
+)
+
+// ============================================
+// TOKEN STORE
+// ============================================
+
+// Redis key prefixes
+const (
+	tokenSessionPrefix       = "auth:session:"        // sid -> user ID
+	tokenRefreshPrefix       = "auth:refresh:"        // refresh jti -> sid (deleted on use)
+	tokenUserSessionsPrefix  = "auth:user_sessions:"  // user ID -> set of sids
+	tokenRevokedAccessPrefix = "auth:revoked:"        // access jti -> revoked marker
+	tokenRevokedBeforePrefix = "auth:revoked_before:" // user ID -> unix time cutoff for pre-session tokens
+	tokenLegacyUsedPrefix    = "auth:legacy_used:"    // refresh jti of pre-session tokens already exchanged
+)
+
+// Token store errors
+var (
+	ErrTokenRevoked            = errors.New("token has been revoked")
+	ErrTokenReused             = errors.New("refresh token already used")
+	ErrSessionStoreUnavailable = errors.New("session store unavailable")
+)
+
+// TokenStoreMetrics tracks session activity
+type TokenStoreMetrics struct {
+	SessionsCreated int64
+	Rotations       int64
+	ReuseDetected   int64
+	Revocations     int64
+	RejectedAccess  int64
+	StoreErrors     int64
+	DegradedLogins  int64 // Sessions issued without Redis, see degradedTokenPair
+}
+
+var tokenStoreMetrics = &TokenStoreMetrics{}
+
+// GetTokenStoreMetrics returns token store metrics
+func GetTokenStoreMetrics() *TokenStoreMetrics {
+	return &TokenStoreMetrics{
+		SessionsCreated: atomic.LoadInt64(&tokenStoreMetrics.SessionsCreated),
+		Rotations:       atomic.LoadInt64(&tokenStoreMetrics.Rotations),
+		ReuseDetected:   atomic.LoadInt64(&tokenStoreMetrics.ReuseDetected),
+		Revocations:     atomic.LoadInt64(&tokenStoreMetrics.Revocations),
+		RejectedAccess:  atomic.LoadInt64(&tokenStoreMetrics.RejectedAccess),
+		StoreErrors:     atomic.LoadInt64(&tokenStoreMetrics.StoreErrors),
+		DegradedLogins:  atomic.LoadInt64(&tokenStoreMetrics.DegradedLogins),
+	}
+}
+
+// TokenStore tracks login sessions in Redis so tokens can be rotated and revoked.
+// A session is created at login and shared by the access/refresh pair; each
+// refresh token can be exchanged exactly once for a new pair in the same session.
+type TokenStore struct {
+	sessionTTL time.Duration
+}
+
+var (
+	tokenStoreInstance *TokenStore
+	tokenStoreOnce     sync.Once
+)
+
+// NewTokenStore creates a new token store
+func NewTokenStore() *TokenStore {
+	return &TokenStore{
+		sessionTTL: utils.RefreshTokenExpiry,
+	}
+}
+
+// GetTokenStore returns the singleton token store
+func GetTokenStore() *TokenStore {
+	tokenStoreOnce.Do(func() {
+		tokenStoreInstance = NewTokenStore()
+	})
+	return tokenStoreInstance
+}
+
+// ============================================
+// SESSIONS
+// ============================================
+
+// CreateSession starts a new login session and issues its first token pair.
+// While Redis is unavailable the login still succeeds with a degraded pair
+// (see CheckAccessToken for the policy).
+func (s *TokenStore) CreateSession(userID uuid.UUID, username, email, role string) (*utils.TokenPair, error) {
+	pair, err := utils.GenerateTokenPair(userID, username, email, role, uuid.New().String())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.storePair(userID, pair); err != nil {
+		fmt.Printf("[TokenStore] %v, issuing a degraded session to %s\n", err, userID)
+		return degradedTokenPair(userID, username, email, role)
+	}
+
+	atomic.AddInt64(&tokenStoreMetrics.SessionsCreated, 1)
+	return pair, nil
+}
+
+// degradedTokenPair issues an access token outside any session and no refresh
+// token. Like the tokens issued before sessions existed, it is still checked
+// against its own revocation and "log out all devices" once Redis is back; it
+// can't be refreshed, so the user logs in again when it expires.
+func degradedTokenPair(userID uuid.UUID, username, email, role string) (*utils.TokenPair, error) {
+	pair, err := utils.GenerateTokenPair(userID, username, email, role, "")
+	if err != nil {
+		return nil, err
+	}
+	pair.RefreshToken = ""
+	pair.RefreshTokenID = ""
+	pair.RefreshExpiresAt = time.Time{}
+
+	atomic.AddInt64(&tokenStoreMetrics.DegradedLogins, 1)
+	return pair, nil
+}
+
+// storePair records the session and its current refresh token
+func (s *TokenStore) storePair(userID uuid.UUID, pair *utils.TokenPair) error {
+	ctx := context.Background()
+	pipe := cache.TxPipeline()
+	if pipe == nil {
+		atomic.AddInt64(&tokenStoreMetrics.StoreErrors, 1)
+		return ErrSessionStoreUnavailable
+	}
+
+	userSessionsKey := tokenUserSessionsPrefix + userID.String()
+	pipe.Set(ctx, tokenSessionPrefix+pair.SessionID, userID.String(), s.sessionTTL)
+	pipe.Set(ctx, tokenRefreshPrefix+pair.RefreshTokenID, pair.SessionID, time.Until(pair.RefreshExpiresAt))
+	pipe.SAdd(ctx, userSessionsKey, pair.SessionID)
+	pipe.Expire(ctx, userSessionsKey, s.sessionTTL)
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		atomic.AddInt64(&tokenStoreMetrics.StoreErrors, 1)
+		return fmt.Errorf("%w: %v", ErrSessionStoreUnavailable, err)
+	}
+	return nil
+}
+
+// RotateRefreshToken consumes a refresh token and issues a new pair in the same
+// session. Presenting an already-used refresh token revokes the whole session,
+// since it means the token was copied.
+func (s *TokenStore) RotateRefreshToken(claims *utils.Claims, username, email, role string) (*utils.TokenPair, error) {
+	ctx := context.Background()
+
+	// Refresh tokens issued before sessions existed are exchanged once for a session
+	if claims.SessionID == "" {
+		if err := s.checkRevokedBefore(ctx, claims); err != nil {
+			return nil, err
+		}
+		ttl := time.Until(utils.GetTokenExpiry(claims))
+		first, err := cache.SetNX(ctx, tokenLegacyUsedPrefix+claims.TokenID, "1", ttl)
+		if err != nil {
+			atomic.AddInt64(&tokenStoreMetrics.StoreErrors, 1)
+			return nil, ErrSessionStoreUnavailable
+		}
+		if !first {
+			atomic.AddInt64(&tokenStoreMetrics.ReuseDetected, 1)
+			return nil, ErrTokenReused
+		}
+		return s.CreateSession(claims.UserID, username, email, role)
+	}
+
+	sessionID, err := cache.GetDel(ctx, tokenRefreshPrefix+claims.TokenID)
+	if cache.IsNil(err) {
+		// Either replayed or the session was revoked; kill the session either way
+		atomic.AddInt64(&tokenStoreMetrics.ReuseDetected, 1)
+		s.RevokeSession(claims.UserID, claims.SessionID)
+		return nil, ErrTokenReused
+	}
+	if err != nil {
+		atomic.AddInt64(&tokenStoreMetrics.StoreErrors, 1)
+		return nil, ErrSessionStoreUnavailable
+	}
+	if sessionID != claims.SessionID {
+		s.RevokeSession(claims.UserID, claims.SessionID)
+		return nil, ErrTokenRevoked
+	}
+
+	active, err := s.sessionActive(ctx, claims.UserID, claims.SessionID)
+	if err != nil {
+		return nil, ErrSessionStoreUnavailable
+	}
+	if !active {
+		return nil, ErrTokenRevoked
+	}
+
+	pair, err := utils.GenerateTokenPair(claims.UserID, username, email, role, claims.SessionID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.storePair(claims.UserID, pair); err != nil {
+		return nil, err
+	}
+
+	atomic.AddInt64(&tokenStoreMetrics.Rotations, 1)
+	return pair, nil
+}
+
+// sessionActive checks the session exists and belongs to the user
+func (s *TokenStore) sessionActive(ctx context.Context, userID uuid.UUID, sessionID string) (bool, error) {
+	owner, err := cache.Get(ctx, tokenSessionPrefix+sessionID)
+	if cache.IsNil(err) {
+		return false, nil
+	}
+	if err != nil {
+		atomic.AddInt64(&tokenStoreMetrics.StoreErrors, 1)
+		return false, err
+	}
+	return owner == userID.String(), nil
+}
+
+// ============================================
+// REVOCATION
+// ============================================
+
+// RevokeSession ends a login session; its refresh token stops working
+// immediately and its access tokens are rejected by AuthMiddleware
+func (s *TokenStore) RevokeSession(userID uuid.UUID, sessionID string) error {
+	if sessionID == "" {
+		return nil
+	}
+	ctx := context.Background()
+
+	if err := cache.Delete(ctx, tokenSessionPrefix+sessionID); err != nil {
+		atomic.AddInt64(&tokenStoreMetrics.StoreErrors, 1)
+		return err
+	}
+	cache.SRem(ctx, tokenUserSessionsPrefix+userID.String(), sessionID)
+
+	atomic.AddInt64(&tokenStoreMetrics.Revocations, 1)
+	return nil
+}
+
+// RevokeAccessToken blocks a single access token until it expires. Needed for
+// tokens issued before sessions existed, which can't be revoked by session.
+func (s *TokenStore) RevokeAccessToken(claims *utils.Claims) error {
+	ttl := time.Until(utils.GetTokenExpiry(claims))
+	if claims.TokenID == "" || ttl <= 0 {
+		return nil
+	}
+	if err := cache.Set(context.Background(), tokenRevokedAccessPrefix+claims.TokenID, "1", ttl); err != nil {
+		atomic.AddInt64(&tokenStoreMetrics.StoreErrors, 1)
+		return err
+	}
+	return nil
+}
+
+// RevokeAllSessions logs a user out everywhere: every session is deleted and
+// any pre-session token issued up to now is rejected
+func (s *TokenStore) RevokeAllSessions(userID uuid.UUID) (int, error) {
+	ctx := context.Background()
+	userSessionsKey := tokenUserSessionsPrefix + userID.String()
+
+	sessionIDs, err := cache.SMembers(ctx, userSessionsKey)
+	if err != nil && !cache.IsNil(err) {
+		atomic.AddInt64(&tokenStoreMetrics.StoreErrors, 1)
+		return 0, err
+	}
+
+	keys := make([]string, 0, len(sessionIDs)+1)
+	for _, sid := range sessionIDs {
+		keys = append(keys, tokenSessionPrefix+sid)
+	}
+	keys = append(keys, userSessionsKey)
+	if err := cache.Delete(ctx, keys...); err != nil {
+		atomic.AddInt64(&tokenStoreMetrics.StoreErrors, 1)
+		return 0, err
+	}
+
+	cutoff := strconv.FormatInt(time.Now().Unix(), 10)
+	if err := cache.Set(ctx, tokenRevokedBeforePrefix+userID.String(), cutoff, s.sessionTTL); err != nil {
+		atomic.AddInt64(&tokenStoreMetrics.StoreErrors, 1)
+		return 0, err
+	}
+
+	atomic.AddInt64(&tokenStoreMetrics.Revocations, int64(len(sessionIDs)))
+	return len(sessionIDs), nil
+}
+
+// ============================================
+// VALIDATION
+// ============================================
+
+// CheckAccessToken returns ErrTokenRevoked if the token's session or the token
+// itself has been revoked. Redis errors fail open so an outage doesn't lock
+// every user out; they are counted in StoreErrors. Logins follow the same
+// policy: CreateSession issues a degraded, non-refreshable token while Redis is
+// down instead of failing, counted in DegradedLogins.
+func (s *TokenStore) CheckAccessToken(claims *utils.Claims) error {
+	ctx := context.Background()
+
+	if claims.TokenID != "" {
+		n, err := cache.Exists(ctx, tokenRevokedAccessPrefix+claims.TokenID)
+		if err != nil {
+			atomic.AddInt64(&tokenStoreMetrics.StoreErrors, 1)
+			return nil
+		}
+		if n > 0 {
+			atomic.AddInt64(&tokenStoreMetrics.RejectedAccess, 1)
+			return ErrTokenRevoked
+		}
+	}
+
+	if claims.SessionID == "" {
+		if err := s.checkRevokedBefore(ctx, claims); err == ErrTokenRevoked {
+			atomic.AddInt64(&tokenStoreMetrics.RejectedAccess, 1)
+			return err
+		}
+		return nil
+	}
+
+	active, err := s.sessionActive(ctx, claims.UserID, claims.SessionID)
+	if err != nil {
+		return nil
+	}
+	if !active {
+		atomic.AddInt64(&tokenStoreMetrics.RejectedAccess, 1)
+		return ErrTokenRevoked
+	}
+	return nil
+}
+
+// checkRevokedBefore rejects pre-session tokens issued before a "log out all devices"
+func (s *TokenStore) checkRevokedBefore(ctx context.Context, claims *utils.Claims) error {
+	value, err := cache.Get(ctx, tokenRevokedBeforePrefix+claims.UserID.String())
+	if cache.IsNil(err) {
+		return nil
+	}
+	if err != nil {
+		atomic.AddInt64(&tokenStoreMetrics.StoreErrors, 1)
+		return ErrSessionStoreUnavailable
+	}
+
+	cutoff, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || claims.IssuedAt == nil {
+		return nil
+	}
+	if claims.IssuedAt.Unix() <= cutoff {
+		return ErrTokenRevoked
+	}
+	return nil
+}
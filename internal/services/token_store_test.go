@@ -0,0 +1,65 @@
+package services
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/aljapah/afftok-backend-prod/internal/cache"
+	"github.com/aljapah/afftok-backend-prod/pkg/utils"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestCreateSessionWithoutRedis(t *testing.T) {
+	// An address nothing listens on
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("can't reserve a port: %v", err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	tests := []struct {
+		name   string
+		client *redis.Client
+	}{
+		{"not configured", nil},
+		{"unreachable", redis.NewClient(&redis.Options{Addr: addr, MaxRetries: -1, DialTimeout: 100 * time.Millisecond})},
+	}
+
+	previous := cache.RedisClient
+	defer func() { cache.RedisClient = previous }()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache.RedisClient = tt.client
+			userID := uuid.New()
+			before := GetTokenStoreMetrics().DegradedLogins
+
+			pair, err := NewTokenStore().CreateSession(userID, "promoter", "p@example.com", "promoter")
+			if err != nil {
+				t.Fatalf("CreateSession: %v", err)
+			}
+			if pair.RefreshToken != "" || pair.SessionID != "" {
+				t.Errorf("degraded pair has refresh token %q, session %q; want neither", pair.RefreshToken, pair.SessionID)
+			}
+			if got := GetTokenStoreMetrics().DegradedLogins - before; got != 1 {
+				t.Errorf("DegradedLogins went up by %d, want 1", got)
+			}
+
+			claims, err := utils.ValidateToken(pair.AccessToken)
+			if err != nil {
+				t.Fatalf("access token: %v", err)
+			}
+			if claims.UserID != userID || claims.SessionID != "" || utils.IsRefreshToken(claims) {
+				t.Errorf("claims = %+v, want an access token for %s outside any session", claims, userID)
+			}
+
+			// The same outage lets the token through
+			if err := NewTokenStore().CheckAccessToken(claims); err != nil {
+				t.Errorf("CheckAccessToken = %v, want nil while Redis is down", err)
+			}
+		})
+	}
+}
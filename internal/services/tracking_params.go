@@ -0,0 +1,266 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+)
+
+// ============================================
+// TRACKING LINK PARAMETERS
+// ============================================
+
+const (
+	// MaxSubIDLength is the longest sub-ID value stored
+	MaxSubIDLength = 255
+
+	// MaxPassthroughParams is the most passthrough parameters an offer may whitelist
+	MaxPassthroughParams = 10
+
+	maxPassthroughValueLength = 255
+
+	// DefaultTrackingTemplate is used for offers without a tracking template
+	DefaultTrackingTemplate = "click_id={click_id}&aff_id={click_id}"
+)
+
+// subIDAliases are the query parameters accepted for each sub-ID slot, in
+// order of precedence. sub_id_N matches the SDK's field names.
+var subIDAliases = [models.MaxSubIDs][]string{
+	{"sub1", "sub_id_1", "subid1", "aff_sub"},
+	{"sub2", "sub_id_2", "subid2", "aff_sub2"},
+	{"sub3", "sub_id_3", "subid3", "aff_sub3"},
+	{"sub4", "sub_id_4", "subid4", "aff_sub4"},
+	{"sub5", "sub_id_5", "subid5", "aff_sub5"},
+}
+
+var (
+	passthroughParamPattern = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_]{0,49}$`)
+	macroPattern            = regexp.MustCompile(`\{([a-zA-Z0-9_]+)\}`)
+)
+
+// builtinMacros are the macros every tracking template can use. Passthrough
+// parameters can't shadow them.
+var builtinMacros = map[string]bool{
+	"click_id":      true,
+	"aff_id":        true,
+	"offer_id":      true,
+	"user_offer_id": true,
+	"promoter_id":   true,
+	"country":       true,
+	"device":        true,
+	"os":            true,
+	"timestamp":     true,
+	"sub1":          true,
+	"sub2":          true,
+	"sub3":          true,
+	"sub4":          true,
+	"sub5":          true,
+}
+
+// TrackingParams are the promoter-supplied parameters of a tracking link click
+type TrackingParams struct {
+	SubIDs     models.SubIDs
+	Custom     map[string]string
+	DeepLinkID *uuid.UUID // Set when the click came through a deep link
+}
+
+// ExtractTrackingParams reads sub1..sub5 (and their aliases) and the allowed
+// passthrough parameters from a tracking link query
+func ExtractTrackingParams(query url.Values, allowed []string) *TrackingParams {
+	params := &TrackingParams{}
+
+	for i, aliases := range subIDAliases {
+		for _, name := range aliases {
+			if value := strings.TrimSpace(query.Get(name)); value != "" {
+				params.SubIDs.Set(i+1, truncate(value, MaxSubIDLength))
+				break
+			}
+		}
+	}
+
+	for _, name := range allowed {
+		value := strings.TrimSpace(query.Get(name))
+		if value == "" {
+			continue
+		}
+		if params.Custom == nil {
+			params.Custom = make(map[string]string)
+		}
+		params.Custom[name] = truncate(value, maxPassthroughValueLength)
+	}
+
+	return params
+}
+
+// ApplyToClick stores the parameters on a click
+func (p *TrackingParams) ApplyToClick(click *models.Click) {
+	if p == nil {
+		return
+	}
+	click.SubIDs = p.SubIDs
+	click.DeepLinkID = p.DeepLinkID
+	if len(p.Custom) > 0 {
+		if data, err := json.Marshal(p.Custom); err == nil {
+			click.CustomParams = datatypes.JSON(data)
+		}
+	}
+}
+
+// ============================================
+// OFFER SETTINGS
+// ============================================
+
+// NormalizePassthroughParams validates a passthrough whitelist and returns it
+// in its stored (comma separated) form
+func NormalizePassthroughParams(params []string) (string, error) {
+	seen := make(map[string]bool)
+	var names []string
+	for _, name := range params {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		if !passthroughParamPattern.MatchString(name) {
+			return "", fmt.Errorf("invalid passthrough parameter %q: use letters, digits and underscores", name)
+		}
+		if builtinMacros[strings.ToLower(name)] || isSubIDAlias(name) || strings.EqualFold(name, DeepLinkQueryParam) {
+			return "", fmt.Errorf("passthrough parameter %q is reserved", name)
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	if len(names) > MaxPassthroughParams {
+		return "", fmt.Errorf("at most %d passthrough parameters are allowed", MaxPassthroughParams)
+	}
+	return strings.Join(names, ","), nil
+}
+
+// ValidateTrackingTemplate checks that a tracking template is a query string
+// using only built-in macros and the offer's passthrough parameters
+func ValidateTrackingTemplate(template string, allowed []string) error {
+	if template == "" {
+		return nil
+	}
+	if strings.HasPrefix(template, "?") || strings.Contains(template, "://") {
+		return fmt.Errorf("tracking_template must be a query string like %q", DefaultTrackingTemplate)
+	}
+	if strings.Count(template, "{") != strings.Count(template, "}") {
+		return fmt.Errorf("tracking_template has unbalanced braces")
+	}
+
+	custom := make(map[string]bool, len(allowed))
+	for _, name := range allowed {
+		custom[name] = true
+	}
+	for _, match := range macroPattern.FindAllStringSubmatch(template, -1) {
+		if !builtinMacros[match[1]] && !custom[match[1]] {
+			return fmt.Errorf("unknown macro {%s} in tracking_template", match[1])
+		}
+	}
+
+	if _, err := url.ParseQuery(macroPattern.ReplaceAllString(template, "x")); err != nil {
+		return fmt.Errorf("tracking_template is not a valid query string: %w", err)
+	}
+	return nil
+}
+
+// ============================================
+// DESTINATION URL
+// ============================================
+
+// RedirectMacros builds the macro values for a tracking link redirect. click
+// is the recorded click, or nil when none was recorded (capped, blocked or
+// duplicate traffic), in which case device and geo come from the request.
+func RedirectMacros(c *gin.Context, clickID string, offer *models.Offer, userOffer *models.UserOffer, click *models.Click, params *TrackingParams) map[string]string {
+	macros := map[string]string{
+		"click_id":  clickID,
+		"aff_id":    clickID,
+		"offer_id":  offer.ID.String(),
+		"timestamp": strconv.FormatInt(time.Now().Unix(), 10),
+	}
+	if userOffer != nil && userOffer.ID != uuid.Nil {
+		macros["user_offer_id"] = userOffer.ID.String()
+		macros["promoter_id"] = userOffer.UserID.String()
+	}
+
+	if click != nil {
+		macros["country"] = click.Country
+		macros["device"] = click.Device
+		macros["os"] = click.OS
+	} else {
+		device, _, os := parseUserAgent(c.Request.UserAgent())
+		macros["country"] = GetGeoIPService().ResolveRequest(c).CountryCode
+		macros["device"] = device
+		macros["os"] = os
+	}
+
+	if params != nil {
+		for n := 1; n <= models.MaxSubIDs; n++ {
+			macros["sub"+strconv.Itoa(n)] = params.SubIDs.Get(n)
+		}
+		for name, value := range params.Custom {
+			macros[name] = value
+		}
+	}
+
+	return macros
+}
+
+// BuildDestinationURL expands the macros in a destination URL and appends the
+// rendered tracking template (DefaultTrackingTemplate when empty). Unknown
+// macros are left alone in the destination and dropped from the template.
+func BuildDestinationURL(destination, template string, macros map[string]string) string {
+	if template == "" {
+		template = DefaultTrackingTemplate
+	}
+
+	destination = macroPattern.ReplaceAllStringFunc(destination, func(match string) string {
+		if value, ok := macros[match[1:len(match)-1]]; ok {
+			return url.QueryEscape(value)
+		}
+		return match
+	})
+	query := macroPattern.ReplaceAllStringFunc(template, func(match string) string {
+		return url.QueryEscape(macros[match[1:len(match)-1]])
+	})
+	if query == "" {
+		return destination
+	}
+
+	// Keep any fragment at the end
+	fragment := ""
+	if i := strings.Index(destination, "#"); i >= 0 {
+		destination, fragment = destination[:i], destination[i:]
+	}
+
+	separator := "?"
+	if strings.Contains(destination, "?") {
+		separator = "&"
+		if strings.HasSuffix(destination, "?") || strings.HasSuffix(destination, "&") {
+			separator = ""
+		}
+	}
+	return destination + separator + query + fragment
+}
+
+// isSubIDAlias reports whether a parameter name is read as a sub-ID
+func isSubIDAlias(name string) bool {
+	name = strings.ToLower(name)
+	for _, aliases := range subIDAliases {
+		for _, alias := range aliases {
+			if name == alias {
+				return true
+			}
+		}
+	}
+	return false
+}
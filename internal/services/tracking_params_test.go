@@ -0,0 +1,216 @@
+package services
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+)
+
+func TestExtractTrackingParams(t *testing.T) {
+	arabic := strings.Repeat("م", 200) // 400 bytes
+
+	tests := []struct {
+		name       string
+		query      string
+		allowed    []string
+		wantSubIDs models.SubIDs
+		wantCustom map[string]string
+	}{
+		{name: "none", query: ""},
+		{name: "sub1-sub5", query: "sub1=a&sub2=b&sub3=c&sub4=d&sub5=e",
+			wantSubIDs: models.SubIDs{Sub1: "a", Sub2: "b", Sub3: "c", Sub4: "d", Sub5: "e"}},
+		{name: "aliases", query: "sub_id_1=sdk&subid2=legacy&aff_sub3=network",
+			wantSubIDs: models.SubIDs{Sub1: "sdk", Sub2: "legacy", Sub3: "network"}},
+		{name: "aff_sub is sub1", query: "aff_sub=x", wantSubIDs: models.SubIDs{Sub1: "x"}},
+		{name: "canonical name wins", query: "aff_sub=alias&sub1=canonical", wantSubIDs: models.SubIDs{Sub1: "canonical"}},
+		{name: "blank alias falls through", query: "sub1=%20%20&sub_id_1=fallback", wantSubIDs: models.SubIDs{Sub1: "fallback"}},
+		{name: "trimmed", query: "sub1=%20video_123%20", wantSubIDs: models.SubIDs{Sub1: "video_123"}},
+		{name: "decoded", query: "sub1=summer%20sale%26more", wantSubIDs: models.SubIDs{Sub1: "summer sale&more"}},
+		{name: "sub6 ignored", query: "sub6=x"},
+		{name: "truncated", query: "sub1=" + strings.Repeat("x", 300), wantSubIDs: models.SubIDs{Sub1: strings.Repeat("x", MaxSubIDLength)}},
+		{name: "truncated on a rune boundary", query: "sub1=x" + url.QueryEscape(arabic),
+			wantSubIDs: models.SubIDs{Sub1: "x" + strings.Repeat("م", 127)}},
+
+		{name: "passthrough", query: "utm_source=tiktok&utm_campaign=spring&gclid=abc", allowed: []string{"utm_source", "utm_campaign"},
+			wantCustom: map[string]string{"utm_source": "tiktok", "utm_campaign": "spring"}},
+		{name: "passthrough is case-sensitive", query: "UTM_SOURCE=tiktok", allowed: []string{"utm_source"}},
+		{name: "blank passthrough skipped", query: "utm_source=", allowed: []string{"utm_source"}},
+		{name: "passthrough truncated", query: "utm_source=" + strings.Repeat("y", 300), allowed: []string{"utm_source"},
+			wantCustom: map[string]string{"utm_source": strings.Repeat("y", maxPassthroughValueLength)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatalf("bad test query: %v", err)
+			}
+
+			params := ExtractTrackingParams(query, tt.allowed)
+			if params.SubIDs != tt.wantSubIDs {
+				t.Errorf("SubIDs = %+v, want %+v", params.SubIDs, tt.wantSubIDs)
+			}
+			for n := 1; n <= models.MaxSubIDs; n++ {
+				if !utf8.ValidString(params.SubIDs.Get(n)) {
+					t.Errorf("sub%d is not valid UTF-8", n)
+				}
+			}
+
+			if len(params.Custom) != len(tt.wantCustom) {
+				t.Fatalf("Custom = %v, want %v", params.Custom, tt.wantCustom)
+			}
+			for name, want := range tt.wantCustom {
+				if params.Custom[name] != want {
+					t.Errorf("Custom[%s] = %q, want %q", name, params.Custom[name], want)
+				}
+			}
+		})
+	}
+}
+
+func TestTrackingParamsApplyToClick(t *testing.T) {
+	params := &TrackingParams{
+		SubIDs: models.SubIDs{Sub1: "video_123", Sub5: "creative_b"},
+		Custom: map[string]string{"utm_source": "tiktok"},
+	}
+
+	var click models.Click
+	params.ApplyToClick(&click)
+	if click.SubIDs != params.SubIDs {
+		t.Errorf("SubIDs = %+v, want %+v", click.SubIDs, params.SubIDs)
+	}
+	var custom map[string]string
+	if err := json.Unmarshal(click.CustomParams, &custom); err != nil || custom["utm_source"] != "tiktok" {
+		t.Errorf("CustomParams = %s (%v)", click.CustomParams, err)
+	}
+
+	var empty models.Click
+	(&TrackingParams{}).ApplyToClick(&empty)
+	if empty.CustomParams != nil {
+		t.Errorf("CustomParams = %s, want none", empty.CustomParams)
+	}
+	(*TrackingParams)(nil).ApplyToClick(&empty) // Must not panic
+}
+
+func TestNormalizePassthroughParams(t *testing.T) {
+	tests := []struct {
+		name    string
+		params  []string
+		want    string
+		wantErr bool
+	}{
+		{name: "empty", params: nil, want: ""},
+		{name: "list", params: []string{"utm_source", " utm_campaign ", "gclid"}, want: "utm_source,utm_campaign,gclid"},
+		{name: "duplicates and blanks dropped", params: []string{"a", "", "a", "b"}, want: "a,b"},
+		{name: "max", params: []string{"p1", "p2", "p3", "p4", "p5", "p6", "p7", "p8", "p9", "p10"}, want: "p1,p2,p3,p4,p5,p6,p7,p8,p9,p10"},
+
+		{name: "too many", params: []string{"p1", "p2", "p3", "p4", "p5", "p6", "p7", "p8", "p9", "p10", "p11"}, wantErr: true},
+		{name: "leading digit", params: []string{"1utm"}, wantErr: true},
+		{name: "punctuation", params: []string{"utm-source"}, wantErr: true},
+		{name: "too long", params: []string{"p" + strings.Repeat("x", 50)}, wantErr: true},
+		{name: "built-in macro", params: []string{"click_id"}, wantErr: true},
+		{name: "built-in macro in another case", params: []string{"Country"}, wantErr: true},
+		{name: "sub-ID", params: []string{"sub1"}, wantErr: true},
+		{name: "sub-ID alias", params: []string{"AFF_SUB2"}, wantErr: true},
+		{name: "deep link parameter", params: []string{DeepLinkQueryParam}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NormalizePassthroughParams(tt.params)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("NormalizePassthroughParams = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateTrackingTemplate(t *testing.T) {
+	allowed := []string{"utm_source"}
+
+	tests := []struct {
+		name     string
+		template string
+		wantErr  bool
+	}{
+		{"empty uses the default", "", false},
+		{"default", DefaultTrackingTemplate, false},
+		{"every built-in", "cid={click_id}&aff={aff_id}&o={offer_id}&uo={user_offer_id}&p={promoter_id}&c={country}&d={device}&os={os}&ts={timestamp}&s1={sub1}&s2={sub2}&s3={sub3}&s4={sub4}&s5={sub5}", false},
+		{"passthrough macro", "src={utm_source}", false},
+		{"static values", "network=afftok&click_id={click_id}", false},
+		{"macro in a key", "{sub1}=1", false},
+
+		{"leading question mark", "?click_id={click_id}", true},
+		{"full URL", "https://example.com/?click_id={click_id}", true},
+		{"unknown macro", "x={clickid}", true},
+		{"passthrough not whitelisted", "c={utm_campaign}", true},
+		{"unbalanced braces", "x={click_id", true},
+		{"bad escape", "x=%zz&click_id={click_id}", true},
+		{"semicolon", "a=1;b={click_id}", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateTrackingTemplate(tt.template, allowed); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateTrackingTemplate(%q) error = %v, wantErr %v", tt.template, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBuildDestinationURL(t *testing.T) {
+	macros := map[string]string{
+		"click_id":   "c1",
+		"aff_id":     "c1",
+		"sub1":       "summer sale&x=1",
+		"sub2":       "",
+		"country":    "SA",
+		"utm_source": "تيك توك",
+	}
+
+	tests := []struct {
+		name        string
+		destination string
+		template    string
+		want        string
+	}{
+		{"default template", "https://shop.example.com/p", "",
+			"https://shop.example.com/p?click_id=c1&aff_id=c1"},
+		{"existing query", "https://shop.example.com/p?ref=1", "cid={click_id}",
+			"https://shop.example.com/p?ref=1&cid=c1"},
+		{"trailing question mark", "https://shop.example.com/p?", "cid={click_id}",
+			"https://shop.example.com/p?cid=c1"},
+		{"trailing ampersand", "https://shop.example.com/p?ref=1&", "cid={click_id}",
+			"https://shop.example.com/p?ref=1&cid=c1"},
+		{"fragment kept last", "https://shop.example.com/p#reviews", "cid={click_id}",
+			"https://shop.example.com/p?cid=c1#reviews"},
+		{"query and fragment", "https://shop.example.com/p?ref=1#top", "cid={click_id}",
+			"https://shop.example.com/p?ref=1&cid=c1#top"},
+		{"values escaped", "https://shop.example.com/", "s1={sub1}",
+			"https://shop.example.com/?s1=summer+sale%26x%3D1"},
+		{"unicode escaped", "https://shop.example.com/", "src={utm_source}",
+			"https://shop.example.com/?src=%D8%AA%D9%8A%D9%83+%D8%AA%D9%88%D9%83"},
+		{"empty and unknown values render empty", "https://shop.example.com/", "s2={sub2}&x={nope}",
+			"https://shop.example.com/?s2=&x="},
+		{"destination macros expanded and escaped", "https://shop.example.com/{country}/?s={sub1}", "cid={click_id}",
+			"https://shop.example.com/SA/?s=summer+sale%26x%3D1&cid=c1"},
+		{"unknown destination macro left alone", "https://shop.example.com/{nope}", "cid={click_id}",
+			"https://shop.example.com/{nope}?cid=c1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := BuildDestinationURL(tt.destination, tt.template, macros)
+			if got != tt.want {
+				t.Errorf("BuildDestinationURL = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -30,6 +30,10 @@ const (
 	WALEventPostback   WALEventType = "postback"
 	WALEventAPIEvent   WALEventType = "api_event"
 	WALEventEdgeEvent  WALEventType = "edge_event"
+
+	// WALEventAck marks an earlier entry as processed. WAL files are append-only,
+	// so acks are written as their own entries and folded in when reading.
+	WALEventAck WALEventType = "ack"
 )
 
 // WALEntryStatus represents the status of a WAL entry
@@ -170,35 +174,58 @@ func (w *WALService) Append(eventType WALEventType, tenantID string, data map[st
 		Attempts:  0,
 	}
 
+	if err := w.writeEntry(entry); err != nil {
+		return nil, err
+	}
+
+	atomic.AddInt64(&w.totalEntries, 1)
+	atomic.AddInt64(&w.pendingEntries, 1)
+
+	return entry, nil
+}
+
+// writeEntry checksums, writes and fsyncs an entry. Caller holds w.mu.
+func (w *WALService) writeEntry(entry *WALEntry) error {
 	// Calculate checksum
 	entry.Checksum = w.calculateChecksum(entry)
 
 	// Serialize entry
 	line, err := json.Marshal(entry)
 	if err != nil {
-		return nil, fmt.Errorf("failed to serialize WAL entry: %w", err)
+		return fmt.Errorf("failed to serialize WAL entry: %w", err)
 	}
 
 	// Check if we need to rotate
 	if err := w.checkRotation(); err != nil {
-		return nil, err
+		return err
 	}
 
 	// Write to file with newline
 	line = append(line, '\n')
 	if _, err := w.currentFile.Write(line); err != nil {
-		return nil, fmt.Errorf("failed to write WAL entry: %w", err)
+		return fmt.Errorf("failed to write WAL entry: %w", err)
 	}
 
 	// Fsync for durability
 	if err := w.currentFile.Sync(); err != nil {
-		return nil, fmt.Errorf("failed to sync WAL: %w", err)
+		return fmt.Errorf("failed to sync WAL: %w", err)
 	}
 
-	atomic.AddInt64(&w.totalEntries, 1)
-	atomic.AddInt64(&w.pendingEntries, 1)
+	return nil
+}
 
-	return entry, nil
+// appendAck records that an entry has been processed so it isn't replayed
+func (w *WALService) appendAck(entryID string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.writeEntry(&WALEntry{
+		ID:        uuid.New().String(),
+		EventType: WALEventAck,
+		Status:    WALStatusProcessed,
+		Timestamp: time.Now().UTC(),
+		Data:      map[string]interface{}{"entry_id": entryID},
+	})
 }
 
 // MarkProcessed marks an entry as processed
@@ -213,13 +240,14 @@ func (w *WALService) MarkFailed(entryID string, errorMsg string) error {
 
 // updateEntryStatus updates the status of a WAL entry
 func (w *WALService) updateEntryStatus(entryID string, status WALEntryStatus, errorMsg string) error {
-	// In a production system, we'd update the entry in place or use a separate status file
-	// For simplicity, we track status in memory and compact periodically
-	
+	// Processed entries get an ack entry; failed entries stay pending for replay
 	switch status {
 	case WALStatusProcessed:
+		if err := w.appendAck(entryID); err != nil {
+			return err
+		}
 		atomic.AddInt64(&w.processedCount, 1)
-		atomic.AddInt64(&w.pendingEntries, -1)
+		w.decrementPending()
 	case WALStatusFailed:
 		atomic.AddInt64(&w.failedCount, 1)
 	case WALStatusReplayed:
@@ -229,6 +257,16 @@ func (w *WALService) updateEntryStatus(entryID string, status WALEntryStatus, er
 	return nil
 }
 
+// decrementPending lowers the pending gauge without going below zero
+func (w *WALService) decrementPending() {
+	for {
+		pending := atomic.LoadInt64(&w.pendingEntries)
+		if pending <= 0 || atomic.CompareAndSwapInt64(&w.pendingEntries, pending, pending-1) {
+			return
+		}
+	}
+}
+
 // ============================================
 // FILE MANAGEMENT
 // ============================================
@@ -283,7 +321,9 @@ func (w *WALService) loadSequence() error {
 		return err
 	}
 
-	var maxSeq int64
+	acked := w.readAcks(files)
+
+	var maxSeq, pending int64
 	for _, file := range files {
 		entries, err := w.readWALFile(file)
 		if err != nil {
@@ -293,13 +333,41 @@ func (w *WALService) loadSequence() error {
 			if entry.Sequence > maxSeq {
 				maxSeq = entry.Sequence
 			}
+			if isPendingEntry(entry, acked) {
+				pending++
+			}
 		}
 	}
 
 	w.sequence = maxSeq
+	w.pendingEntries = pending
 	return nil
 }
 
+// readAcks collects the IDs of acknowledged entries across WAL files
+func (w *WALService) readAcks(files []string) map[string]bool {
+	acked := make(map[string]bool)
+	for _, file := range files {
+		entries, err := w.readWALFile(file)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if entry.EventType == WALEventAck {
+				if id, ok := entry.Data["entry_id"].(string); ok {
+					acked[id] = true
+				}
+			}
+		}
+	}
+	return acked
+}
+
+// isPendingEntry reports whether an entry still needs processing
+func isPendingEntry(entry *WALEntry, acked map[string]bool) bool {
+	return entry.EventType != WALEventAck && entry.Status == WALStatusPending && !acked[entry.ID]
+}
+
 // listWALFiles lists all WAL files in the directory
 func (w *WALService) listWALFiles() ([]string, error) {
 	entries, err := os.ReadDir(w.walDir)
@@ -419,6 +487,7 @@ func (w *WALService) Replay(processor func(entry *WALEntry) error) (int, int, er
 	}
 
 	var replayed, failed int
+	acked := w.readAcks(files)
 
 	for _, file := range files {
 		entries, err := w.readWALFile(file)
@@ -427,7 +496,7 @@ func (w *WALService) Replay(processor func(entry *WALEntry) error) (int, int, er
 		}
 
 		for _, entry := range entries {
-			if entry.Status != WALStatusPending {
+			if !isPendingEntry(entry, acked) {
 				continue
 			}
 
@@ -435,9 +504,14 @@ func (w *WALService) Replay(processor func(entry *WALEntry) error) (int, int, er
 				entry.Status = WALStatusFailed
 				entry.Error = err.Error()
 				failed++
-			} else {
-				entry.Status = WALStatusReplayed
-				replayed++
+				continue
+			}
+
+			entry.Status = WALStatusReplayed
+			replayed++
+			acked[entry.ID] = true
+			if err := w.appendAck(entry.ID); err == nil {
+				w.decrementPending()
 			}
 		}
 	}
@@ -454,6 +528,7 @@ func (w *WALService) GetPendingEntries() ([]*WALEntry, error) {
 	}
 
 	var pending []*WALEntry
+	acked := w.readAcks(files)
 
 	for _, file := range files {
 		entries, err := w.readWALFile(file)
@@ -462,7 +537,7 @@ func (w *WALService) GetPendingEntries() ([]*WALEntry, error) {
 		}
 
 		for _, entry := range entries {
-			if entry.Status == WALStatusPending {
+			if isPendingEntry(entry, acked) {
 				pending = append(pending, entry)
 			}
 		}
@@ -475,7 +550,10 @@ func (w *WALService) GetPendingEntries() ([]*WALEntry, error) {
 // COMPACTION
 // ============================================
 
-// Compact removes processed entries from WAL files
+// Compact removes WAL files whose entries have all been processed. Files are
+// removed oldest first and compaction stops at the first file with pending
+// entries, so acks for those entries (always in the same or a later file) are
+// never removed before the entries themselves.
 func (w *WALService) Compact() error {
 	w.mu.Lock()
 	defer w.mu.Unlock()
@@ -485,30 +563,32 @@ func (w *WALService) Compact() error {
 		return err
 	}
 
+	acked := w.readAcks(files)
+
 	for _, file := range files {
-		// Skip current file
+		// Stop at the current file
 		if file == w.currentPath {
-			continue
+			break
 		}
 
 		entries, err := w.readWALFile(file)
 		if err != nil {
-			continue
+			break
 		}
 
 		// Check if all entries are processed
 		allProcessed := true
 		for _, entry := range entries {
-			if entry.Status == WALStatusPending {
+			if isPendingEntry(entry, acked) {
 				allProcessed = false
 				break
 			}
 		}
 
-		// Remove file if all entries are processed
-		if allProcessed {
-			os.Remove(file)
+		if !allProcessed {
+			break
 		}
+		os.Remove(file)
 	}
 
 	return nil
@@ -0,0 +1,130 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestWALService(t *testing.T, dir string) *WALService {
+	t.Helper()
+	config := DefaultWALConfig()
+	config.Dir = dir
+	w, err := NewWALService(config)
+	if err != nil {
+		t.Fatalf("NewWALService: %v", err)
+	}
+	return w
+}
+
+func TestIsPendingEntry(t *testing.T) {
+	acked := map[string]bool{"done": true}
+	tests := []struct {
+		name  string
+		entry WALEntry
+		want  bool
+	}{
+		{"pending", WALEntry{ID: "a", EventType: WALEventClick, Status: WALStatusPending}, true},
+		{"acked", WALEntry{ID: "done", EventType: WALEventClick, Status: WALStatusPending}, false},
+		{"processed", WALEntry{ID: "b", EventType: WALEventClick, Status: WALStatusProcessed}, false},
+		{"failed status is not pending", WALEntry{ID: "c", EventType: WALEventConversion, Status: WALStatusFailed}, false},
+		{"ack entry", WALEntry{ID: "d", EventType: WALEventAck, Status: WALStatusPending}, false},
+	}
+	for _, tt := range tests {
+		if got := isPendingEntry(&tt.entry, acked); got != tt.want {
+			t.Errorf("%s: isPendingEntry = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestWALAckSurvivesRestart(t *testing.T) {
+	dir := t.TempDir()
+	w := newTestWALService(t, dir)
+
+	first, err := w.Append(WALEventClick, "tenant", map[string]interface{}{"n": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _ := w.Append(WALEventConversion, "tenant", map[string]interface{}{"n": 2})
+	third, _ := w.Append(WALEventClick, "", map[string]interface{}{"n": 3})
+	if first.Sequence >= second.Sequence || second.Sequence >= third.Sequence {
+		t.Errorf("sequences not increasing: %d, %d, %d", first.Sequence, second.Sequence, third.Sequence)
+	}
+
+	if err := w.MarkProcessed(first.ID); err != nil {
+		t.Fatal(err)
+	}
+	w.MarkFailed(second.ID, "database down") // Stays pending
+	w.Stop()
+
+	// A fresh service sees the ack, the surviving entries and the sequence
+	w = newTestWALService(t, dir)
+	defer w.Stop()
+
+	pending, err := w.GetPendingEntries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 2 || pending[0].ID != second.ID || pending[1].ID != third.ID {
+		t.Fatalf("pending after restart = %d entries, want %s and %s", len(pending), second.ID, third.ID)
+	}
+	if pending[0].TenantID != "tenant" || pending[0].Data["n"] != float64(2) {
+		t.Errorf("entry not restored: %+v", pending[0])
+	}
+	if w.pendingEntries != 2 || w.sequence != third.Sequence {
+		t.Errorf("restored pending = %d, sequence = %d; want 2, %d", w.pendingEntries, w.sequence, third.Sequence)
+	}
+
+	// Replay acks what succeeds and leaves failures for the next pass
+	replayed, failed, err := w.Replay(func(entry *WALEntry) error {
+		if entry.ID == third.ID {
+			return os.ErrDeadlineExceeded
+		}
+		return nil
+	})
+	if err != nil || replayed != 1 || failed != 1 {
+		t.Fatalf("Replay = %d, %d, %v; want 1, 1, nil", replayed, failed, err)
+	}
+	pending, _ = w.GetPendingEntries()
+	if len(pending) != 1 || pending[0].ID != third.ID {
+		t.Errorf("pending after replay = %d entries, want only %s", len(pending), third.ID)
+	}
+}
+
+func TestWALChecksumRejectsTampering(t *testing.T) {
+	dir := t.TempDir()
+	w := newTestWALService(t, dir)
+	entry, err := w.Append(WALEventConversion, "", map[string]interface{}{"amount": 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Stop()
+
+	if !w.verifyChecksum(entry) {
+		t.Fatal("fresh entry fails its checksum")
+	}
+	tampered := *entry
+	tampered.Timestamp = entry.Timestamp.Add(time.Nanosecond)
+	if w.verifyChecksum(&tampered) {
+		t.Error("checksum ignores the timestamp")
+	}
+
+	// Edit the amount on disk; the entry is dropped as corrupt, not replayed
+	files, _ := filepath.Glob(filepath.Join(dir, "wal_*.log"))
+	if len(files) != 1 {
+		t.Fatalf("found %d WAL files, want 1", len(files))
+	}
+	raw, _ := os.ReadFile(files[0])
+	os.WriteFile(files[0], []byte(strings.Replace(string(raw), `"amount":100`, `"amount":999`, 1)), 0o644)
+
+	w = newTestWALService(t, dir)
+	defer w.Stop()
+	if pending, _ := w.GetPendingEntries(); len(pending) != 0 {
+		t.Errorf("tampered entry still pending: %+v", pending[0])
+	}
+	if w.corruptionCount == 0 {
+		t.Error("corruption not counted")
+	}
+}
@@ -0,0 +1,761 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+)
+
+// ============================================
+// WEBHOOK STEP CONDITIONS
+// ============================================
+
+// A step's Conditions column decides whether the step fires. It is evaluated
+// against the same TemplateContext the TemplateEngine renders with, and may be:
+//
+//	"conversion.amount > 5000 AND click.country in [SA, KW]"   expression string
+//	{"field": "offer.category", "op": "==", "value": "fintech"} single comparison
+//	{"all": [...]} / {"any": [...]}                             AND / OR group
+//	[...]                                                       implicit AND
+//
+// Group members may themselves be any of the forms above. Expressions support
+// parentheses and AND/OR (also && and ||); AND binds tighter than OR.
+//
+// A field that is missing from the context, or null, compares as null:
+//
+//	field == null, field in [null, ...]       true
+//	field != null, field == x, field in [x]   false
+//	field != x, field not_in [x]              true
+//	>, >=, <, <=, contains                    false
+
+// Condition operators
+const (
+	ConditionOpEq       = "=="
+	ConditionOpNe       = "!="
+	ConditionOpGt       = ">"
+	ConditionOpGte      = ">="
+	ConditionOpLt       = "<"
+	ConditionOpLte      = "<="
+	ConditionOpIn       = "in"
+	ConditionOpNotIn    = "not_in"
+	ConditionOpContains = "contains"
+	ConditionOpAll      = "all"
+	ConditionOpAny      = "any"
+)
+
+// conditionRoots are the context prefixes a condition field may reference
+var conditionRoots = map[string]bool{
+	"click":      true,
+	"conversion": true,
+	"user_offer": true,
+	"offer":      true,
+	"user":       true,
+	"postback":   true,
+	"custom":     true,
+}
+
+// conditionSystemFields are the top-level system values in TemplateContext
+var conditionSystemFields = map[string]bool{
+	"timestamp":      true,
+	"timestamp_iso":  true,
+	"correlation_id": true,
+	"task_id":        true,
+}
+
+// WebhookCondition is a parsed condition tree. Groups use Op all/any with
+// Children; comparisons use Field, Op and Value (a list for in/not_in).
+type WebhookCondition struct {
+	Op       string
+	Field    string
+	Value    interface{}
+	Children []*WebhookCondition
+}
+
+// ============================================
+// PARSING
+// ============================================
+
+// ParseWebhookConditions parses a step's Conditions JSON. Empty, null or empty
+// collections return nil, meaning the step always fires.
+func ParseWebhookConditions(raw []byte) (*WebhookCondition, error) {
+	if len(strings.TrimSpace(string(raw))) == 0 {
+		return nil, nil
+	}
+
+	var data interface{}
+	decoder := json.NewDecoder(strings.NewReader(string(raw)))
+	decoder.UseNumber()
+	if err := decoder.Decode(&data); err != nil {
+		return nil, fmt.Errorf("conditions must be valid JSON: %w", err)
+	}
+
+	return parseConditionNode(data)
+}
+
+// ValidateWebhookConditions checks that a step's Conditions parse and only
+// reference known context fields
+func ValidateWebhookConditions(raw []byte) error {
+	cond, err := ParseWebhookConditions(raw)
+	if err != nil {
+		return err
+	}
+	if cond == nil {
+		return nil
+	}
+	return cond.validateFields()
+}
+
+// ValidateWebhookSteps validates the conditions of every step in a pipeline
+// and rejects step URLs the outbound client would refuse for the pipeline's
+// tenant (templated URLs are checked when the step runs)
+func ValidateWebhookSteps(steps []models.WebhookStep, tenantID string) error {
+	for i := range steps {
+		name := steps[i].Name
+		if name == "" {
+			name = strconv.Itoa(i)
+		}
+		if err := ValidateWebhookConditions(steps[i].Conditions); err != nil {
+			return fmt.Errorf("step %s: invalid conditions: %w", name, err)
+		}
+		if !strings.Contains(steps[i].URL, "{{") {
+			if err := GetOutboundClient().ValidateURL(steps[i].URL, tenantID); err != nil {
+				return fmt.Errorf("step %s: invalid URL: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// parseConditionNode parses one decoded JSON node
+func parseConditionNode(data interface{}) (*WebhookCondition, error) {
+	switch v := data.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		if strings.TrimSpace(v) == "" {
+			return nil, nil
+		}
+		return parseConditionExpression(v)
+	case []interface{}:
+		return parseConditionGroup(ConditionOpAll, v)
+	case map[string]interface{}:
+		return parseConditionObject(v)
+	default:
+		return nil, fmt.Errorf("unsupported condition of type %T", data)
+	}
+}
+
+// parseConditionGroup parses the members of an all/any group
+func parseConditionGroup(op string, members []interface{}) (*WebhookCondition, error) {
+	group := &WebhookCondition{Op: op}
+	for _, member := range members {
+		child, err := parseConditionNode(member)
+		if err != nil {
+			return nil, err
+		}
+		if child != nil {
+			group.Children = append(group.Children, child)
+		}
+	}
+	if len(group.Children) == 0 {
+		return nil, nil
+	}
+	if len(group.Children) == 1 {
+		return group.Children[0], nil
+	}
+	return group, nil
+}
+
+// parseConditionObject parses a group object or a single comparison object
+func parseConditionObject(obj map[string]interface{}) (*WebhookCondition, error) {
+	if len(obj) == 0 {
+		return nil, nil
+	}
+
+	for _, key := range []string{"all", "and", "any", "or"} {
+		members, ok := obj[key]
+		if !ok {
+			continue
+		}
+		if len(obj) != 1 {
+			return nil, fmt.Errorf("group %q must be the only key in its object", key)
+		}
+		list, ok := members.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("group %q must be a list", key)
+		}
+		op := ConditionOpAll
+		if key == "any" || key == "or" {
+			op = ConditionOpAny
+		}
+		return parseConditionGroup(op, list)
+	}
+
+	field, _ := obj["field"].(string)
+	opName, _ := obj["op"].(string)
+	if field == "" || opName == "" {
+		return nil, fmt.Errorf("condition object needs field and op, or an all/any group")
+	}
+
+	op, ok := normalizeConditionOp(opName)
+	if !ok {
+		return nil, fmt.Errorf("unknown operator %q", opName)
+	}
+
+	value, ok := obj["value"]
+	if !ok {
+		return nil, fmt.Errorf("condition on %s is missing a value", field)
+	}
+
+	return newComparison(field, op, normalizeConditionValue(value))
+}
+
+// newComparison builds a comparison node, checking the value fits the operator
+func newComparison(field, op string, value interface{}) (*WebhookCondition, error) {
+	_, isList := value.([]interface{})
+	switch op {
+	case ConditionOpIn, ConditionOpNotIn:
+		if !isList {
+			value = []interface{}{value}
+		}
+	case ConditionOpGt, ConditionOpGte, ConditionOpLt, ConditionOpLte:
+		if _, ok := toConditionNumber(value); !ok {
+			return nil, fmt.Errorf("operator %s on %s needs a numeric value", op, field)
+		}
+	default:
+		if isList {
+			return nil, fmt.Errorf("operator %s on %s does not take a list", op, field)
+		}
+	}
+	return &WebhookCondition{Op: op, Field: field, Value: value}, nil
+}
+
+// normalizeConditionOp maps operator spellings to the canonical operator
+func normalizeConditionOp(op string) (string, bool) {
+	switch strings.ToLower(strings.TrimSpace(op)) {
+	case "==", "=", "eq":
+		return ConditionOpEq, true
+	case "!=", "<>", "ne":
+		return ConditionOpNe, true
+	case ">", "gt":
+		return ConditionOpGt, true
+	case ">=", "gte":
+		return ConditionOpGte, true
+	case "<", "lt":
+		return ConditionOpLt, true
+	case "<=", "lte":
+		return ConditionOpLte, true
+	case "in":
+		return ConditionOpIn, true
+	case "not_in", "not in", "nin":
+		return ConditionOpNotIn, true
+	case "contains":
+		return ConditionOpContains, true
+	}
+	return "", false
+}
+
+// normalizeConditionValue converts json.Number values to float64
+func normalizeConditionValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		if f, err := v.Float64(); err == nil {
+			return f
+		}
+		return v.String()
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			list[i] = normalizeConditionValue(item)
+		}
+		return list
+	}
+	return value
+}
+
+// ============================================
+// EXPRESSION PARSER
+// ============================================
+
+// conditionToken is a lexical token of a condition expression
+type conditionToken struct {
+	text   string
+	quoted bool
+}
+
+// conditionParser is a recursive-descent parser over expression tokens
+type conditionParser struct {
+	tokens []conditionToken
+	pos    int
+}
+
+// parseConditionExpression parses an expression string
+func parseConditionExpression(expr string) (*WebhookCondition, error) {
+	tokens, err := tokenizeCondition(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &conditionParser{tokens: tokens}
+	cond, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if !p.done() {
+		return nil, fmt.Errorf("unexpected %q in condition", p.peek().text)
+	}
+	return cond, nil
+}
+
+// tokenizeCondition splits an expression into tokens
+func tokenizeCondition(expr string) ([]conditionToken, error) {
+	var tokens []conditionToken
+	i := 0
+	for i < len(expr) {
+		ch := expr[i]
+		switch {
+		case ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r':
+			i++
+		case ch == '(' || ch == ')' || ch == '[' || ch == ']' || ch == ',':
+			tokens = append(tokens, conditionToken{text: string(ch)})
+			i++
+		case ch == '"' || ch == '\'':
+			end := strings.IndexByte(expr[i+1:], ch)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string in condition")
+			}
+			tokens = append(tokens, conditionToken{text: expr[i+1 : i+1+end], quoted: true})
+			i += end + 2
+		case strings.ContainsRune("=!<>&|", rune(ch)):
+			j := i + 1
+			for j < len(expr) && strings.ContainsRune("=<>&|", rune(expr[j])) {
+				j++
+			}
+			tokens = append(tokens, conditionToken{text: expr[i:j]})
+			i = j
+		default:
+			j := i
+			for j < len(expr) && !strings.ContainsRune(" \t\n\r()[],\"'=!<>&|", rune(expr[j])) {
+				j++
+			}
+			tokens = append(tokens, conditionToken{text: expr[i:j]})
+			i = j
+		}
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("empty condition")
+	}
+	return tokens, nil
+}
+
+func (p *conditionParser) done() bool {
+	return p.pos >= len(p.tokens)
+}
+
+func (p *conditionParser) peek() conditionToken {
+	if p.done() {
+		return conditionToken{}
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *conditionParser) next() conditionToken {
+	tok := p.peek()
+	p.pos++
+	return tok
+}
+
+// isKeyword reports whether the next token is the given unquoted keyword
+func (p *conditionParser) isKeyword(words ...string) bool {
+	tok := p.peek()
+	if p.done() || tok.quoted {
+		return false
+	}
+	for _, w := range words {
+		if strings.EqualFold(tok.text, w) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *conditionParser) expect(text string) error {
+	if tok := p.next(); tok.quoted || tok.text != text {
+		return fmt.Errorf("expected %q in condition", text)
+	}
+	return nil
+}
+
+// parseOr parses: and { (OR | ||) and }
+func (p *conditionParser) parseOr() (*WebhookCondition, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	group := &WebhookCondition{Op: ConditionOpAny, Children: []*WebhookCondition{left}}
+	for p.isKeyword("or", "||") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		group.Children = append(group.Children, right)
+	}
+	if len(group.Children) == 1 {
+		return left, nil
+	}
+	return group, nil
+}
+
+// parseAnd parses: primary { (AND | &&) primary }
+func (p *conditionParser) parseAnd() (*WebhookCondition, error) {
+	left, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	group := &WebhookCondition{Op: ConditionOpAll, Children: []*WebhookCondition{left}}
+	for p.isKeyword("and", "&&") {
+		p.next()
+		right, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		group.Children = append(group.Children, right)
+	}
+	if len(group.Children) == 1 {
+		return left, nil
+	}
+	return group, nil
+}
+
+// parsePrimary parses a parenthesised expression or a comparison
+func (p *conditionParser) parsePrimary() (*WebhookCondition, error) {
+	if p.done() {
+		return nil, fmt.Errorf("condition ends unexpectedly")
+	}
+	if p.isKeyword("(") {
+		p.next()
+		cond, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return cond, nil
+	}
+	return p.parseComparison()
+}
+
+// parseComparison parses: field op value
+func (p *conditionParser) parseComparison() (*WebhookCondition, error) {
+	fieldTok := p.next()
+	if fieldTok.quoted || fieldTok.text == "" || strings.ContainsAny(fieldTok.text, "()[],") {
+		return nil, fmt.Errorf("expected a field name, got %q", fieldTok.text)
+	}
+
+	opText := p.next().text
+	if strings.EqualFold(opText, "not") && p.isKeyword("in") {
+		p.next()
+		opText = "not in"
+	}
+	op, ok := normalizeConditionOp(opText)
+	if !ok {
+		return nil, fmt.Errorf("unknown operator %q after %s", opText, fieldTok.text)
+	}
+
+	value, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	return newComparison(fieldTok.text, op, value)
+}
+
+// parseValue parses a scalar or a [a, b, ...] list
+func (p *conditionParser) parseValue() (interface{}, error) {
+	if p.done() {
+		return nil, fmt.Errorf("condition is missing a value")
+	}
+	if !p.isKeyword("[") {
+		return p.parseScalar()
+	}
+
+	p.next()
+	list := []interface{}{}
+	for !p.isKeyword("]") {
+		if p.done() {
+			return nil, fmt.Errorf("unterminated list in condition")
+		}
+		item, err := p.parseScalar()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, item)
+		if p.isKeyword(",") {
+			p.next()
+		}
+	}
+	p.next()
+	return list, nil
+}
+
+// parseScalar parses a quoted string, number, boolean, null or bare word
+func (p *conditionParser) parseScalar() (interface{}, error) {
+	tok := p.next()
+	if tok.quoted {
+		return tok.text, nil
+	}
+	if tok.text == "" || strings.ContainsAny(tok.text, "()[],") {
+		return nil, fmt.Errorf("expected a value, got %q", tok.text)
+	}
+	switch strings.ToLower(tok.text) {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	}
+	if f, err := strconv.ParseFloat(tok.text, 64); err == nil {
+		return f, nil
+	}
+	return tok.text, nil
+}
+
+// ============================================
+// EVALUATION
+// ============================================
+
+// Evaluate reports whether the condition holds for the context. Missing and
+// null fields compare as null (see the package comment above).
+func (c *WebhookCondition) Evaluate(ctx *TemplateContext) bool {
+	if c == nil {
+		return true
+	}
+	return c.evaluate(ctx.ToMap())
+}
+
+func (c *WebhookCondition) evaluate(values map[string]interface{}) bool {
+	switch c.Op {
+	case ConditionOpAll:
+		for _, child := range c.Children {
+			if !child.evaluate(values) {
+				return false
+			}
+		}
+		return true
+	case ConditionOpAny:
+		for _, child := range c.Children {
+			if child.evaluate(values) {
+				return true
+			}
+		}
+		return false
+	}
+
+	actual, found := lookupConditionField(values, c.Field)
+	if !found {
+		actual = nil
+	}
+
+	switch c.Op {
+	case ConditionOpEq:
+		return conditionValuesEqual(actual, c.Value)
+	case ConditionOpNe:
+		return !conditionValuesEqual(actual, c.Value)
+	case ConditionOpGt, ConditionOpGte, ConditionOpLt, ConditionOpLte:
+		a, ok := toConditionNumber(actual)
+		if !ok {
+			return false
+		}
+		b, _ := toConditionNumber(c.Value)
+		switch c.Op {
+		case ConditionOpGt:
+			return a > b
+		case ConditionOpGte:
+			return a >= b
+		case ConditionOpLt:
+			return a < b
+		default:
+			return a <= b
+		}
+	case ConditionOpIn, ConditionOpNotIn:
+		in := false
+		for _, item := range c.Value.([]interface{}) {
+			if conditionValuesEqual(actual, item) {
+				in = true
+				break
+			}
+		}
+		return in == (c.Op == ConditionOpIn)
+	case ConditionOpContains:
+		if actual == nil {
+			return false
+		}
+		if list, ok := actual.([]interface{}); ok {
+			for _, item := range list {
+				if conditionValuesEqual(item, c.Value) {
+					return true
+				}
+			}
+			return false
+		}
+		return strings.Contains(
+			strings.ToLower(conditionString(actual)),
+			strings.ToLower(conditionString(c.Value)),
+		)
+	}
+	return false
+}
+
+// String renders the condition back as an expression, used as the skip reason
+func (c *WebhookCondition) String() string {
+	if c == nil {
+		return ""
+	}
+	switch c.Op {
+	case ConditionOpAll, ConditionOpAny:
+		sep := " AND "
+		if c.Op == ConditionOpAny {
+			sep = " OR "
+		}
+		parts := make([]string, len(c.Children))
+		for i, child := range c.Children {
+			parts[i] = child.String()
+			if len(child.Children) > 0 {
+				parts[i] = "(" + parts[i] + ")"
+			}
+		}
+		return strings.Join(parts, sep)
+	}
+
+	op := c.Op
+	if op == ConditionOpNotIn {
+		op = "not in"
+	}
+	if list, ok := c.Value.([]interface{}); ok {
+		items := make([]string, len(list))
+		for i, item := range list {
+			items[i] = conditionString(item)
+			if item == nil {
+				items[i] = "null"
+			}
+		}
+		return fmt.Sprintf("%s %s [%s]", c.Field, op, strings.Join(items, ", "))
+	}
+	if s, ok := c.Value.(string); ok {
+		return fmt.Sprintf("%s %s %q", c.Field, op, s)
+	}
+	if c.Value == nil {
+		return fmt.Sprintf("%s %s null", c.Field, op)
+	}
+	return fmt.Sprintf("%s %s %s", c.Field, op, conditionString(c.Value))
+}
+
+// validateFields checks every comparison references a known context field
+func (c *WebhookCondition) validateFields() error {
+	if len(c.Children) > 0 {
+		for _, child := range c.Children {
+			if err := child.validateFields(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if conditionSystemFields[c.Field] {
+		return nil
+	}
+	root, rest, ok := strings.Cut(c.Field, ".")
+	if !ok || rest == "" || !conditionRoots[root] {
+		return fmt.Errorf("unknown field %q (use click., conversion., user_offer., offer., user., postback. or custom.)", c.Field)
+	}
+	return nil
+}
+
+// ============================================
+// HELPERS
+// ============================================
+
+// lookupConditionField resolves a dotted field from the flattened context,
+// walking into nested maps when the field goes deeper than one level
+func lookupConditionField(values map[string]interface{}, field string) (interface{}, bool) {
+	if v, ok := values[field]; ok {
+		return v, v != nil
+	}
+
+	parts := strings.Split(field, ".")
+	for i := len(parts) - 1; i > 0; i-- {
+		current, ok := values[strings.Join(parts[:i], ".")]
+		if !ok {
+			continue
+		}
+		for _, part := range parts[i:] {
+			m, ok := current.(map[string]interface{})
+			if !ok {
+				return nil, false
+			}
+			if current, ok = m[part]; !ok {
+				return nil, false
+			}
+		}
+		return current, current != nil
+	}
+	return nil, false
+}
+
+// conditionValuesEqual compares numerically when both sides are numbers and
+// case-insensitively as strings otherwise, so "SA" matches "sa". Null only
+// equals null.
+func conditionValuesEqual(actual, expected interface{}) bool {
+	if expected == nil || actual == nil {
+		return actual == nil && expected == nil
+	}
+	if a, ok := toConditionNumber(actual); ok {
+		if b, ok := toConditionNumber(expected); ok {
+			return a == b
+		}
+	}
+	return strings.EqualFold(conditionString(actual), conditionString(expected))
+}
+
+// toConditionNumber converts numeric values and numeric strings to float64
+func toConditionNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// conditionString formats a value for string comparison
+func conditionString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case nil:
+		return ""
+	}
+	return fmt.Sprintf("%v", value)
+}
@@ -0,0 +1,176 @@
+package services
+
+import (
+	"testing"
+)
+
+func TestParseWebhookConditions(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		want    string // Rendered condition, "" for always fires
+		wantErr bool
+	}{
+		{name: "empty", raw: ``},
+		{name: "null", raw: `null`},
+		{name: "empty string", raw: `"  "`},
+		{name: "empty list", raw: `[]`},
+		{name: "empty object", raw: `{}`},
+		{name: "expression", raw: `"conversion.amount > 5000"`, want: `conversion.amount > 5000`},
+		{name: "expression with list", raw: `"click.country in [SA, KW]"`, want: `click.country in [SA, KW]`},
+		{name: "not in", raw: `"click.country not in ['SA']"`, want: `click.country not in [SA]`},
+		{name: "and binds tighter than or", raw: `"a.x == 1 OR a.y == 2 AND a.z == 3"`, want: `a.x == 1 OR (a.y == 2 AND a.z == 3)`},
+		{name: "parentheses", raw: `"(a.x == 1 || a.y == 2) && a.z == 3"`, want: `(a.x == 1 OR a.y == 2) AND a.z == 3`},
+		{name: "quoted value", raw: `"offer.category == 'fin tech'"`, want: `offer.category == "fin tech"`},
+		{name: "null literal", raw: `"click.sub1 == null"`, want: `click.sub1 == null`},
+		{name: "object", raw: `{"field": "offer.category", "op": "eq", "value": "fintech"}`, want: `offer.category == "fintech"`},
+		{name: "null in list", raw: `"click.sub1 in [a, null]"`, want: `click.sub1 in [a, null]`},
+		{name: "object scalar in", raw: `{"field": "click.country", "op": "in", "value": "SA"}`, want: `click.country in [SA]`},
+		{name: "any group", raw: `{"any": [{"field": "a.x", "op": ">", "value": 1}, "a.y < 2"]}`, want: `a.x > 1 OR a.y < 2`},
+		{name: "implicit and", raw: `["a.x >= 1", "a.y <= 2"]`, want: `a.x >= 1 AND a.y <= 2`},
+		{name: "single member group", raw: `{"all": ["a.x != 1"]}`, want: `a.x != 1`},
+
+		{name: "invalid JSON", raw: `{`, wantErr: true},
+		{name: "number", raw: `42`, wantErr: true},
+		{name: "unknown operator", raw: `"a.x ~ 1"`, wantErr: true},
+		{name: "missing value", raw: `"a.x =="`, wantErr: true},
+		{name: "numeric op needs number", raw: `"a.x > abc"`, wantErr: true},
+		{name: "list for scalar op", raw: `"a.x == [1, 2]"`, wantErr: true},
+		{name: "unbalanced parenthesis", raw: `"(a.x == 1"`, wantErr: true},
+		{name: "trailing tokens", raw: `"a.x == 1 a.y"`, wantErr: true},
+		{name: "unterminated string", raw: `"a.x == 'abc"`, wantErr: true},
+		{name: "unterminated list", raw: `"a.x in [1, 2"`, wantErr: true},
+		{name: "group with extra keys", raw: `{"all": [], "field": "a.x"}`, wantErr: true},
+		{name: "group not a list", raw: `{"any": "a.x == 1"}`, wantErr: true},
+		{name: "object without op", raw: `{"field": "a.x", "value": 1}`, wantErr: true},
+		{name: "object without value", raw: `{"field": "a.x", "op": "=="}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cond, err := ParseWebhookConditions([]byte(tt.raw))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %q", cond.String())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := cond.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateWebhookConditions(t *testing.T) {
+	tests := []struct {
+		raw     string
+		wantErr bool
+	}{
+		{`"click.country == SA"`, false},
+		{`"sub1 == x"`, true},
+		{`"timestamp > 0"`, false},
+		{`"custom.anything.nested == 1"`, false},
+		{`"clicks.country == SA"`, true},
+		{`"conversion == 1"`, true},
+		{`["click.country == SA", {"any": ["bogus.field == 1"]}]`, true},
+	}
+
+	for _, tt := range tests {
+		err := ValidateWebhookConditions([]byte(tt.raw))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ValidateWebhookConditions(%s) error = %v, wantErr %v", tt.raw, err, tt.wantErr)
+		}
+	}
+}
+
+func TestWebhookConditionEvaluate(t *testing.T) {
+	ctx := NewTemplateContext()
+	ctx.Click = map[string]interface{}{
+		"country": "SA",
+		"sub1":    nil,
+		"tags":    []interface{}{"vip", "new"},
+	}
+	ctx.Conversion = map[string]interface{}{
+		"amount": 7500,
+		"status": "approved",
+		"meta":   map[string]interface{}{"source": "shopify"},
+	}
+	ctx.Offer = map[string]interface{}{
+		"category": "FinTech",
+		"payout":   "12.5",
+	}
+
+	tests := []struct {
+		name string
+		raw  string
+		want bool
+	}{
+		{"no conditions", ``, true},
+
+		// Comparisons on present fields
+		{"eq case-insensitive", `"click.country == sa"`, true},
+		{"eq mismatch", `"click.country == KW"`, false},
+		{"ne", `"click.country != KW"`, true},
+		{"gt", `"conversion.amount > 5000"`, true},
+		{"gte boundary", `"conversion.amount >= 7500"`, true},
+		{"lt", `"conversion.amount < 7500"`, false},
+		{"lte", `"conversion.amount <= 7500"`, true},
+		{"numeric string", `"offer.payout > 12"`, true},
+		{"numeric equality", `"offer.payout == 12.50"`, true},
+		{"gt on non-number", `"click.country > 1"`, false},
+		{"in", `"click.country in [KW, SA]"`, true},
+		{"not in", `"click.country not in [KW, SA]"`, false},
+		{"contains substring", `"offer.category contains tech"`, true},
+		{"contains list item", `"click.tags contains VIP"`, true},
+		{"contains list miss", `"click.tags contains old"`, false},
+		{"nested field", `"conversion.meta.source == shopify"`, true},
+		{"nested field miss", `"conversion.meta.source == zid"`, false},
+
+		// Missing fields compare as null
+		{"missing eq", `"click.region == riyadh"`, false},
+		{"missing eq empty string", `{"field": "click.region", "op": "==", "value": ""}`, false},
+		{"missing ne", `"click.region != riyadh"`, true},
+		{"missing eq null", `"click.region == null"`, true},
+		{"missing ne null", `"click.region != null"`, false},
+		{"missing in", `"click.region in [riyadh]"`, false},
+		{"missing in with null", `"click.region in [riyadh, null]"`, true},
+		{"missing not in", `"click.region not in [riyadh]"`, true},
+		{"missing gt", `"conversion.fee > 0"`, false},
+		{"missing lt", `"conversion.fee < 0"`, false},
+		{"missing contains", `{"field": "click.region", "op": "contains", "value": ""}`, false},
+		{"missing nested", `"conversion.meta.campaign != x"`, true},
+		{"missing under scalar", `"click.country.code == null"`, true},
+
+		// Null fields behave like missing ones
+		{"null eq null", `"click.sub1 == null"`, true},
+		{"null ne null", `"click.sub1 != null"`, false},
+		{"null ne value", `"click.sub1 != abc"`, true},
+		{"null eq value", `"click.sub1 == abc"`, false},
+		{"present ne null", `"click.country != null"`, true},
+		{"present eq null", `"click.country == null"`, false},
+
+		// Groups
+		{"and", `"click.country == SA AND conversion.amount > 5000"`, true},
+		{"and short", `"click.country == SA AND conversion.amount > 9000"`, false},
+		{"or", `"click.country == KW OR conversion.amount > 5000"`, true},
+		{"precedence", `"click.country == KW OR click.country == SA AND conversion.amount > 9000"`, false},
+		{"any group", `{"any": ["click.country == KW", "offer.category == fintech"]}`, true},
+		{"implicit and", `["click.country == SA", "click.region == null"]`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cond, err := ParseWebhookConditions([]byte(tt.raw))
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			if got := cond.Evaluate(ctx); got != tt.want {
+				t.Errorf("Evaluate(%s) = %v, want %v", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
@@ -36,8 +36,9 @@ type WebhookQueueService struct {
 	metrics *webhookQueueMetrics
 
 	// State
-	running bool
-	mutex   sync.RWMutex
+	running   bool
+	redisOnly bool // Set on shutdown: enqueue straight to Redis
+	mutex     sync.RWMutex
 }
 
 // webhookQueueMetrics tracks queue metrics
@@ -91,6 +92,9 @@ func (q *WebhookQueueService) EnqueuePrimary(task *models.WebhookTask) error {
 
 	task.Queue = models.WebhookQueuePrimary
 	task.CreatedAt = time.Now()
+	if q.redisOnly {
+		return q.enqueueRedis(RedisKeyPrimaryQueue, task)
+	}
 
 	// Try L1 (in-memory) first
 	select {
@@ -114,6 +118,9 @@ func (q *WebhookQueueService) EnqueueFailover(task *models.WebhookTask) error {
 	}
 
 	task.Queue = models.WebhookQueueFailover
+	if q.redisOnly {
+		return q.enqueueRedis(RedisKeyFailoverQueue, task)
+	}
 
 	// Try L1 first
 	select {
@@ -137,6 +144,9 @@ func (q *WebhookQueueService) EnqueueDLQ(task *models.WebhookTask) error {
 	}
 
 	task.Queue = models.WebhookQueueDLQ
+	if q.redisOnly {
+		return q.enqueueRedis(RedisKeyDLQQueue, task)
+	}
 
 	// Try L1 first
 	select {
@@ -402,6 +412,51 @@ func (q *WebhookQueueService) Stop() {
 	q.running = false
 }
 
+// SpillToRedis moves the tasks left in the in-memory queues to their Redis
+// queues, where the workers pick them up after a restart, and sends later
+// tasks straight there. Returns the tasks moved and those lost (Redis
+// unavailable).
+func (q *WebhookQueueService) SpillToRedis() (int, int) {
+	q.mutex.Lock()
+	q.redisOnly = true
+	q.mutex.Unlock()
+
+	queues := []struct {
+		key   string
+		ch    chan *models.WebhookTask
+		count *int64
+	}{
+		{RedisKeyPrimaryQueue, q.primaryQueue, &q.metrics.PrimaryQueueSize},
+		{RedisKeyFailoverQueue, q.failoverQueue, &q.metrics.FailoverQueueSize},
+		{RedisKeyDLQQueue, q.dlqQueue, &q.metrics.DLQQueueSize},
+	}
+
+	moved, lost := 0, 0
+	for _, queue := range queues {
+		for done := false; !done; {
+			select {
+			case task := <-queue.ch:
+				atomic.AddInt64(queue.count, -1)
+				if cache.RedisClient == nil {
+					lost++
+					fmt.Printf("[WebhookQueue] Undrained task lost (no Redis): id=%s execution=%s step=%d\n",
+						task.ID, task.ExecutionID, task.StepIndex)
+					continue
+				}
+				if err := q.enqueueRedis(queue.key, task); err != nil {
+					lost++
+					fmt.Printf("[WebhookQueue] Undrained task lost: id=%s execution=%s: %v\n", task.ID, task.ExecutionID, err)
+					continue
+				}
+				moved++
+			default:
+				done = true
+			}
+		}
+	}
+	return moved, lost
+}
+
 // ============================================
 // TASK HELPERS
 // ============================================
@@ -1,6 +1,7 @@
 package services
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"sync"
@@ -53,10 +54,39 @@ func (s *WebhookService) Stop() {
 	s.queueService.Stop()
 }
 
+// Drain stops the workers (letting in-flight deliveries finish until ctx
+// expires), then moves queued tasks to Redis so they are delivered after the
+// restart. Tasks emitted later, e.g. by other draining services, go straight
+// to Redis.
+func (s *WebhookService) Drain(ctx context.Context) (int, error) {
+	stopped := make(chan struct{})
+	go func() {
+		s.workerPool.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		fmt.Println("[WebhookService] Deadline reached with deliveries in flight")
+	}
+
+	moved, lost := s.queueService.SpillToRedis()
+	if moved > 0 {
+		fmt.Printf("[WebhookService] %d queued tasks moved to Redis for delivery after restart\n", moved)
+	}
+	return moved + lost, nil
+}
+
 // ============================================
 // PIPELINE MANAGEMENT
 // ============================================
 
+// TenantID returns the tenant whose egress allowlist applies to a pipeline
+// of the given advertiser
+func (s *WebhookService) TenantID(advertiserID *uuid.UUID) string {
+	return webhookTenantID(s.db, advertiserID)
+}
+
 // CreatePipeline creates a new webhook pipeline
 func (s *WebhookService) CreatePipeline(pipeline *models.WebhookPipeline) error {
 	if pipeline.ID == uuid.Nil {
@@ -64,6 +94,9 @@ func (s *WebhookService) CreatePipeline(pipeline *models.WebhookPipeline) error
 	}
 
 	// Validate steps
+	if err := ValidateWebhookSteps(pipeline.Steps, s.TenantID(pipeline.AdvertiserID)); err != nil {
+		return err
+	}
 	for i := range pipeline.Steps {
 		if pipeline.Steps[i].ID == uuid.Nil {
 			pipeline.Steps[i].ID = uuid.New()
@@ -77,6 +110,10 @@ func (s *WebhookService) CreatePipeline(pipeline *models.WebhookPipeline) error
 
 // UpdatePipeline updates an existing pipeline
 func (s *WebhookService) UpdatePipeline(pipeline *models.WebhookPipeline) error {
+	if err := ValidateWebhookSteps(pipeline.Steps, s.TenantID(pipeline.AdvertiserID)); err != nil {
+		return err
+	}
+
 	return s.db.Transaction(func(tx *gorm.DB) error {
 		// Update pipeline
 		if err := tx.Save(pipeline).Error; err != nil {
@@ -172,10 +209,13 @@ func (s *WebhookService) ListPipelines(
 // WEBHOOK TRIGGERING
 // ============================================
 
-// TriggerWebhook triggers webhooks for a specific event
+// TriggerWebhook triggers webhooks for a specific event. The correlation ID is
+// carried by every execution so it can be traced back to the originating
+// record; one is generated if empty.
 func (s *WebhookService) TriggerWebhook(
 	triggerType models.WebhookTriggerType,
 	triggerID string,
+	correlationID string,
 	advertiserID *uuid.UUID,
 	offerID *uuid.UUID,
 	payload map[string]interface{},
@@ -190,7 +230,9 @@ func (s *WebhookService) TriggerWebhook(
 		return nil // No webhooks configured
 	}
 
-	correlationID := uuid.New().String()[:8]
+	if correlationID == "" {
+		correlationID = uuid.New().String()[:8]
+	}
 
 	// Create tasks for each pipeline
 	for _, pipeline := range pipelines {
@@ -205,6 +247,7 @@ func (s *WebhookService) TriggerWebhook(
 			pipeline.Priority,
 		)
 		task.CorrelationID = correlationID
+		task.TriggerID = triggerID
 
 		// Enqueue task
 		if err := s.queueService.EnqueuePrimary(task); err != nil {
@@ -289,8 +332,22 @@ func (s *WebhookService) findMatchingPipelines(
 // ============================================
 
 // TriggerClickWebhook triggers webhooks for a click event
-func (s *WebhookService) TriggerClickWebhook(click *models.Click, userOffer *models.UserOffer, offer *models.Offer) error {
-	payload := map[string]interface{}{
+func (s *WebhookService) TriggerClickWebhook(click *models.Click, userOffer *models.UserOffer, offer *models.Offer, correlationID string) error {
+	payload := clickTriggerPayload(click, userOffer, offer)
+
+	return s.TriggerWebhook(
+		models.WebhookTriggerClick,
+		click.ID.String(),
+		correlationID,
+		offerAdvertiserID(offer),
+		&offer.ID,
+		payload,
+	)
+}
+
+// clickTriggerPayload builds the click trigger payload
+func clickTriggerPayload(click *models.Click, userOffer *models.UserOffer, offer *models.Offer) map[string]interface{} {
+	return map[string]interface{}{
 		"click": map[string]interface{}{
 			"id":         click.ID.String(),
 			"ip":         click.IPAddress,
@@ -301,7 +358,14 @@ func (s *WebhookService) TriggerClickWebhook(click *models.Click, userOffer *mod
 			"country":    click.Country,
 			"city":       click.City,
 			"clicked_at": click.ClickedAt,
+			"sub1":       click.Sub1,
+			"sub2":       click.Sub2,
+			"sub3":       click.Sub3,
+			"sub4":       click.Sub4,
+			"sub5":       click.Sub5,
 		},
+		"sub_ids": click.SubIDs.ToMap(),
+		"custom":  clickCustomParams(click),
 		"user_offer": map[string]interface{}{
 			"id":      userOffer.ID.String(),
 			"user_id": userOffer.UserID.String(),
@@ -311,33 +375,49 @@ func (s *WebhookService) TriggerClickWebhook(click *models.Click, userOffer *mod
 			"title": offer.Title,
 		},
 	}
+}
 
-	// Use NetworkID as advertiser reference
-	var advertiserID *uuid.UUID
-	if offer.NetworkID != nil {
-		advertiserID = offer.NetworkID
+// clickCustomParams decodes a click's passthrough parameters for payloads
+func clickCustomParams(click *models.Click) map[string]interface{} {
+	params := make(map[string]interface{})
+	if len(click.CustomParams) > 0 {
+		_ = json.Unmarshal(click.CustomParams, &params)
 	}
+	return params
+}
+
+// TriggerConversionWebhook triggers webhooks for a conversion event
+func (s *WebhookService) TriggerConversionWebhook(conversion *models.Conversion, userOffer *models.UserOffer, offer *models.Offer, correlationID string) error {
+	payload := conversionTriggerPayload(conversion, userOffer, offer)
 
 	return s.TriggerWebhook(
-		models.WebhookTriggerClick,
-		click.ID.String(),
-		advertiserID,
+		models.WebhookTriggerConversion,
+		conversion.ID.String(),
+		correlationID,
+		offerAdvertiserID(offer),
 		&offer.ID,
 		payload,
 	)
 }
 
-// TriggerConversionWebhook triggers webhooks for a conversion event
-func (s *WebhookService) TriggerConversionWebhook(conversion *models.Conversion, userOffer *models.UserOffer, offer *models.Offer) error {
-	payload := map[string]interface{}{
+// conversionTriggerPayload builds the conversion trigger payload
+func conversionTriggerPayload(conversion *models.Conversion, userOffer *models.UserOffer, offer *models.Offer) map[string]interface{} {
+	return map[string]interface{}{
 		"conversion": map[string]interface{}{
 			"id":                     conversion.ID.String(),
+			"click_id":               conversion.ClickID,
 			"external_conversion_id": conversion.ExternalConversionID,
 			"amount":                 conversion.Amount,
 			"status":                 conversion.Status,
 			"network_id":             conversion.NetworkID,
 			"converted_at":           conversion.ConvertedAt,
+			"sub1":                   conversion.Sub1,
+			"sub2":                   conversion.Sub2,
+			"sub3":                   conversion.Sub3,
+			"sub4":                   conversion.Sub4,
+			"sub5":                   conversion.Sub5,
 		},
+		"sub_ids": conversion.SubIDs.ToMap(),
 		"user_offer": map[string]interface{}{
 			"id":      userOffer.ID.String(),
 			"user_id": userOffer.UserID.String(),
@@ -347,24 +427,10 @@ func (s *WebhookService) TriggerConversionWebhook(conversion *models.Conversion,
 			"title": offer.Title,
 		},
 	}
-
-	// Use NetworkID as advertiser reference
-	var advertiserID *uuid.UUID
-	if offer.NetworkID != nil {
-		advertiserID = offer.NetworkID
-	}
-
-	return s.TriggerWebhook(
-		models.WebhookTriggerConversion,
-		conversion.ID.String(),
-		advertiserID,
-		&offer.ID,
-		payload,
-	)
 }
 
 // TriggerPostbackWebhook triggers webhooks for a postback event
-func (s *WebhookService) TriggerPostbackWebhook(postbackData map[string]interface{}, advertiserID *uuid.UUID, offerID *uuid.UUID) error {
+func (s *WebhookService) TriggerPostbackWebhook(postbackData map[string]interface{}, advertiserID *uuid.UUID, offerID *uuid.UUID, correlationID string) error {
 	payload := map[string]interface{}{
 		"postback": postbackData,
 	}
@@ -377,6 +443,7 @@ func (s *WebhookService) TriggerPostbackWebhook(postbackData map[string]interfac
 	return s.TriggerWebhook(
 		models.WebhookTriggerPostback,
 		triggerID,
+		correlationID,
 		advertiserID,
 		offerID,
 		payload,
@@ -384,7 +451,7 @@ func (s *WebhookService) TriggerPostbackWebhook(postbackData map[string]interfac
 }
 
 // TriggerJoinOfferWebhook triggers webhooks when a user joins an offer
-func (s *WebhookService) TriggerJoinOfferWebhook(userOffer *models.UserOffer, offer *models.Offer, user map[string]interface{}) error {
+func (s *WebhookService) TriggerJoinOfferWebhook(userOffer *models.UserOffer, offer *models.Offer, user map[string]interface{}, correlationID string) error {
 	payload := map[string]interface{}{
 		"user_offer": map[string]interface{}{
 			"id":             userOffer.ID.String(),
@@ -400,21 +467,116 @@ func (s *WebhookService) TriggerJoinOfferWebhook(userOffer *models.UserOffer, of
 		"user": user,
 	}
 
-	// Use NetworkID as advertiser reference
-	var advertiserID *uuid.UUID
-	if offer.NetworkID != nil {
-		advertiserID = offer.NetworkID
-	}
-
 	return s.TriggerWebhook(
 		models.WebhookTriggerJoinOffer,
 		userOffer.ID.String(),
-		advertiserID,
+		correlationID,
+		offerAdvertiserID(offer),
 		&offer.ID,
 		payload,
 	)
 }
 
+// offerAdvertiserID is the advertiser whose pipelines an offer's events run
+// through. Offers created before advertiser accounts only carry a network.
+func offerAdvertiserID(offer *models.Offer) *uuid.UUID {
+	if offer.AdvertiserID != nil {
+		return offer.AdvertiserID
+	}
+	return offer.NetworkID
+}
+
+// ============================================
+// EVENT EMITTERS
+// ============================================
+
+// The Emit* functions fire triggers in the background so click redirects and
+// postback responses never wait on pipeline lookup. The correlation ID is the
+// ID of the originating click or conversion (or user offer for joins).
+
+// EmitClick fires click webhooks for a newly recorded click
+func (s *WebhookService) EmitClick(click models.Click) {
+	go func() {
+		userOffer, offer, err := s.loadUserOffer(click.UserOfferID)
+		if err == nil {
+			err = s.TriggerClickWebhook(&click, userOffer, offer, click.ID.String())
+		}
+		s.logEmitError(models.WebhookTriggerClick, click.ID.String(), err)
+	}()
+}
+
+// EmitConversion fires conversion webhooks for a newly recorded conversion
+func (s *WebhookService) EmitConversion(conversion models.Conversion) {
+	go func() {
+		userOffer, offer, err := s.loadUserOffer(conversion.UserOfferID)
+		if err == nil {
+			err = s.TriggerConversionWebhook(&conversion, userOffer, offer, conversion.ID.String())
+		}
+		s.logEmitError(models.WebhookTriggerConversion, conversion.ID.String(), err)
+	}()
+}
+
+// EmitPostback fires postback webhooks for an accepted postback. The
+// correlation ID is the conversion it created or updated.
+func (s *WebhookService) EmitPostback(postbackData map[string]interface{}, conversionID, userOfferID uuid.UUID) {
+	go func() {
+		_, offer, err := s.loadUserOffer(userOfferID)
+		if err == nil {
+			data := make(map[string]interface{}, len(postbackData)+1)
+			for k, v := range postbackData {
+				data[k] = v
+			}
+			data["id"] = conversionID.String()
+			err = s.TriggerPostbackWebhook(data, offerAdvertiserID(offer), &offer.ID, conversionID.String())
+		}
+		s.logEmitError(models.WebhookTriggerPostback, conversionID.String(), err)
+	}()
+}
+
+// EmitJoinOffer fires join-offer webhooks for a newly created user offer
+func (s *WebhookService) EmitJoinOffer(userOffer models.UserOffer, offer models.Offer) {
+	go func() {
+		var user models.AfftokUser
+		err := s.db.Select("id", "username").First(&user, "id = ?", userOffer.UserID).Error
+		if err == nil {
+			err = s.TriggerJoinOfferWebhook(&userOffer, &offer, map[string]interface{}{
+				"id":       user.ID.String(),
+				"username": user.Username,
+			}, userOffer.ID.String())
+		}
+		s.logEmitError(models.WebhookTriggerJoinOffer, userOffer.ID.String(), err)
+	}()
+}
+
+// loadUserOffer loads a user offer with its offer for trigger payloads
+func (s *WebhookService) loadUserOffer(userOfferID uuid.UUID) (*models.UserOffer, *models.Offer, error) {
+	var userOffer models.UserOffer
+	if err := s.db.Preload("Offer").First(&userOffer, "id = ?", userOfferID).Error; err != nil {
+		return nil, nil, fmt.Errorf("user offer not found: %w", err)
+	}
+	if userOffer.Offer == nil {
+		return nil, nil, fmt.Errorf("offer not found for user offer %s", userOfferID)
+	}
+	return &userOffer, userOffer.Offer, nil
+}
+
+// logEmitError logs a failed background trigger
+func (s *WebhookService) logEmitError(triggerType models.WebhookTriggerType, correlationID string, err error) {
+	if err == nil {
+		return
+	}
+	s.observability.Log(LogEvent{
+		Category:      "webhook_trigger_error",
+		Level:         LogLevelError,
+		Message:       "Failed to trigger webhooks",
+		CorrelationID: correlationID,
+		Metadata: map[string]interface{}{
+			"trigger_type": string(triggerType),
+			"error":        err.Error(),
+		},
+	})
+}
+
 // ============================================
 // EXECUTION LOGS
 // ============================================
@@ -601,9 +763,24 @@ func (s *WebhookService) TestStep(step *models.WebhookStep, testPayload map[stri
 	if conversion, ok := testPayload["conversion"].(map[string]interface{}); ok {
 		ctx.Conversion = conversion
 	}
+	if userOffer, ok := testPayload["user_offer"].(map[string]interface{}); ok {
+		ctx.UserOffer = userOffer
+	}
+	if offer, ok := testPayload["offer"].(map[string]interface{}); ok {
+		ctx.Offer = offer
+	}
+	if user, ok := testPayload["user"].(map[string]interface{}); ok {
+		ctx.User = user
+	}
+	if postback, ok := testPayload["postback"].(map[string]interface{}); ok {
+		ctx.Postback = postback
+	}
 	if custom, ok := testPayload["custom"].(map[string]interface{}); ok {
 		ctx.Custom = custom
 	}
+	if subIDs, ok := testPayload["sub_ids"].(map[string]interface{}); ok {
+		ctx.SubIDs = subIDs
+	}
 
 	task := &models.WebhookTask{
 		ID:            ctx.TaskID,
@@ -613,6 +790,21 @@ func (s *WebhookService) TestStep(step *models.WebhookStep, testPayload map[stri
 		CorrelationID: ctx.CorrelationID,
 	}
 
+	// Scope the request to the pipeline's tenant, like a real delivery
+	if step.PipelineID != uuid.Nil {
+		var pipeline models.WebhookPipeline
+		if err := s.db.Select("id", "advertiser_id").First(&pipeline, "id = ?", step.PipelineID).Error; err == nil {
+			task.AdvertiserID = pipeline.AdvertiserID
+		}
+	}
+
+	if err := ValidateWebhookConditions(step.Conditions); err != nil {
+		return nil, fmt.Errorf("invalid conditions: %w", err)
+	}
+	if skipped := s.workerPool.evaluateStepConditions(step, ctx, task); skipped != nil {
+		return skipped, nil
+	}
+
 	return s.workerPool.executeStep(step, ctx, task, 0), nil
 }
 
@@ -651,6 +843,7 @@ func (s *WebhookService) GetMetrics() *models.WebhookMetrics {
 		AvgLatencyMs:      avgLatency,
 		StepSuccessCount:  workerMetrics.StepsSucceeded,
 		StepFailureCount:  workerMetrics.StepsFailed,
+		StepSkippedCount:  workerMetrics.StepsSkipped,
 		PendingTasks:      pendingCount,
 		RunningTasks:      runningCount,
 		QueueSizes:        queueSizes,
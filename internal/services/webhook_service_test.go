@@ -0,0 +1,192 @@
+package services
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+)
+
+// queuedContext builds the worker's template context for a payload after the
+// JSON round trip it takes through the task queue
+func queuedContext(t *testing.T, payload map[string]interface{}) *TemplateContext {
+	t.Helper()
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	task := &models.WebhookTask{ID: "task-1", CorrelationID: "corr-1"}
+	if err := json.Unmarshal(raw, &task.Payload); err != nil {
+		t.Fatal(err)
+	}
+	return (&WebhookWorkerPool{}).buildTemplateContext(task)
+}
+
+func TestClickCustomParams(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want int
+	}{
+		{"none", ``, 0},
+		{"params", `{"utm_source":"tiktok","gclid":"abc"}`, 2},
+		{"malformed", `{"utm_source":`, 0},
+		{"not an object", `["a"]`, 0},
+	}
+	for _, tt := range tests {
+		click := &models.Click{}
+		if tt.raw != "" {
+			click.CustomParams = datatypes.JSON(tt.raw)
+		}
+		got := clickCustomParams(click)
+		if got == nil || len(got) != tt.want {
+			t.Errorf("%s: clickCustomParams = %v, want %d entries", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestClickTriggerPayloadConditions(t *testing.T) {
+	offer := &models.Offer{ID: uuid.New(), Title: "Summer Sale"}
+	userOffer := &models.UserOffer{ID: uuid.New(), UserID: uuid.New(), OfferID: offer.ID}
+	click := &models.Click{
+		ID:           uuid.New(),
+		UserOfferID:  userOffer.ID,
+		IPAddress:    "203.0.113.7",
+		Device:       "mobile",
+		Country:      "SA",
+		ClickedAt:    time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC),
+		SubIDs:       models.SubIDs{Sub1: "camp-7", Sub3: "creative-b"},
+		CustomParams: datatypes.JSON(`{"utm_source":"tiktok"}`),
+	}
+
+	ctx := queuedContext(t, clickTriggerPayload(click, userOffer, offer))
+	if ctx.TaskID != "task-1" || ctx.CorrelationID != "corr-1" {
+		t.Errorf("task IDs not carried: %q, %q", ctx.TaskID, ctx.CorrelationID)
+	}
+	if ctx.Click["id"] != click.ID.String() || ctx.UserOffer["user_id"] != userOffer.UserID.String() || ctx.Offer["title"] != "Summer Sale" {
+		t.Errorf("payload sections missing: click %v, user offer %v, offer %v", ctx.Click, ctx.UserOffer, ctx.Offer)
+	}
+
+	tests := []struct {
+		condition string
+		want      bool
+	}{
+		{`"click.country == SA"`, true},
+		{`"click.device in [mobile, tablet]"`, true},
+		{`"click.sub1 == camp-7 AND click.sub3 == creative-b"`, true},
+		{`"click.sub2 == null"`, false}, // Empty sub-IDs are "", not missing
+		{`"click.sub2 == ''"`, true},
+		{`"custom.utm_source == tiktok"`, true},
+		{`"custom.gclid == null"`, true},
+		{`"offer.id == ` + offer.ID.String() + `"`, true},
+		{`"conversion.amount > 0"`, false},
+	}
+	for _, tt := range tests {
+		cond, err := ParseWebhookConditions([]byte(tt.condition))
+		if err != nil {
+			t.Fatalf("parse %s: %v", tt.condition, err)
+		}
+		if got := cond.Evaluate(ctx); got != tt.want {
+			t.Errorf("Evaluate(%s) = %v, want %v", tt.condition, got, tt.want)
+		}
+	}
+}
+
+func TestConversionTriggerPayloadConditions(t *testing.T) {
+	clickID := uuid.New()
+	offer := &models.Offer{ID: uuid.New(), Title: "Summer Sale"}
+	userOffer := &models.UserOffer{ID: uuid.New(), UserID: uuid.New(), OfferID: offer.ID}
+	conversion := &models.Conversion{
+		ID:                   uuid.New(),
+		UserOfferID:          userOffer.ID,
+		ClickID:              &clickID,
+		ExternalConversionID: "order-1001",
+		Amount:               7500,
+		Status:               models.ConversionStatusApproved,
+		SubIDs:               models.SubIDs{Sub1: "camp-7"},
+	}
+
+	ctx := queuedContext(t, conversionTriggerPayload(conversion, userOffer, offer))
+
+	tests := []struct {
+		condition string
+		want      bool
+	}{
+		{`"conversion.amount > 5000"`, true},
+		{`"conversion.amount >= 7500 AND conversion.status == approved"`, true},
+		{`"conversion.click_id == ` + clickID.String() + `"`, true},
+		{`"conversion.network_id == null"`, true},
+		{`"conversion.external_conversion_id == order-1001"`, true},
+		{`"conversion.sub1 == camp-7"`, true},
+		{`"click.country == SA"`, false},
+	}
+	for _, tt := range tests {
+		cond, err := ParseWebhookConditions([]byte(tt.condition))
+		if err != nil {
+			t.Fatalf("parse %s: %v", tt.condition, err)
+		}
+		if got := cond.Evaluate(ctx); got != tt.want {
+			t.Errorf("Evaluate(%s) = %v, want %v", tt.condition, got, tt.want)
+		}
+	}
+
+	// A conversion without a click carries an explicit null
+	conversion.ClickID = nil
+	ctx = queuedContext(t, conversionTriggerPayload(conversion, userOffer, offer))
+	if cond, _ := ParseWebhookConditions([]byte(`"conversion.click_id == null"`)); !cond.Evaluate(ctx) {
+		t.Error("missing click_id is not null")
+	}
+}
+
+func TestOfferAdvertiserID(t *testing.T) {
+	advertiser, network := uuid.New(), uuid.New()
+
+	tests := []struct {
+		name  string
+		offer *models.Offer
+		want  *uuid.UUID
+	}{
+		{"advertiser only", &models.Offer{AdvertiserID: &advertiser}, &advertiser},
+		{"network only", &models.Offer{NetworkID: &network}, &network},
+		{"advertiser wins over network", &models.Offer{AdvertiserID: &advertiser, NetworkID: &network}, &advertiser},
+		{"neither", &models.Offer{}, nil},
+	}
+	for _, tt := range tests {
+		if got := offerAdvertiserID(tt.offer); got != tt.want {
+			t.Errorf("%s: offerAdvertiserID = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// Triggers for an advertiser's offer look up that advertiser's pipelines even
+// when the offer has no network
+func TestTriggersMatchOfferAdvertiser(t *testing.T) {
+	advertiser := uuid.New()
+	offer := &models.Offer{ID: uuid.New(), AdvertiserID: &advertiser}
+	userOffer := &models.UserOffer{ID: uuid.New(), UserID: uuid.New(), OfferID: offer.ID}
+	want := "advertiser_id = '" + advertiser.String() + "'"
+
+	triggers := map[string]func(s *WebhookService) error{
+		"click": func(s *WebhookService) error {
+			return s.TriggerClickWebhook(&models.Click{ID: uuid.New()}, userOffer, offer, "")
+		},
+		"conversion": func(s *WebhookService) error {
+			return s.TriggerConversionWebhook(&models.Conversion{ID: uuid.New()}, userOffer, offer, "")
+		},
+		"join_offer": func(s *WebhookService) error {
+			return s.TriggerJoinOfferWebhook(userOffer, offer, nil, "")
+		},
+	}
+	for name, trigger := range triggers {
+		db, rec := dryRunDB(t)
+		if err := trigger(&WebhookService{db: db}); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if rec.find(want) == "" {
+			t.Errorf("%s: no lookup of the advertiser's pipelines in %q", name, rec.statements)
+		}
+	}
+}
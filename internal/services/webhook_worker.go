@@ -28,7 +28,7 @@ type WebhookWorkerPool struct {
 	signingService  *WebhookSigningService
 	templateEngine  *TemplateEngine
 	observability   *ObservabilityService
-	httpClient      *http.Client
+	httpClient      *OutboundClient
 
 	// Worker counts
 	primaryWorkers  int
@@ -55,6 +55,7 @@ type webhookWorkerMetrics struct {
 	StepsExecuted    int64
 	StepsSucceeded   int64
 	StepsFailed      int64
+	StepsSkipped     int64
 	TotalLatencyMs   int64
 	ActiveWorkers    int64
 }
@@ -70,14 +71,7 @@ func NewWebhookWorkerPool(db *gorm.DB) *WebhookWorkerPool {
 		signingService:  NewWebhookSigningService(),
 		templateEngine:  NewTemplateEngine(),
 		observability:   NewObservabilityService(),
-		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
-			Transport: &http.Transport{
-				MaxIdleConns:        100,
-				MaxIdleConnsPerHost: 10,
-				IdleConnTimeout:     90 * time.Second,
-			},
-		},
+		httpClient:      GetOutboundClient(),
 		primaryWorkers:  cpuCount * 4,
 		failoverWorkers: cpuCount * 2,
 		dlqWorkers:      cpuCount,
@@ -256,6 +250,7 @@ func (p *WebhookWorkerPool) processTask(task *models.WebhookTask) {
 			ID:            task.ExecutionID,
 			PipelineID:    task.PipelineID,
 			TriggerType:   pipeline.TriggerType,
+			TriggerID:     task.TriggerID,
 			CorrelationID: task.CorrelationID,
 			Status:        models.WebhookExecutionRunning,
 			TotalSteps:    len(pipeline.Steps),
@@ -276,16 +271,21 @@ func (p *WebhookWorkerPool) processTask(task *models.WebhookTask) {
 
 	// Execute steps starting from current step
 	success := true
+	refused := false
 	for i := task.StepIndex; i < len(pipeline.Steps); i++ {
 		step := pipeline.Steps[i]
-		
-		stepResult := p.executeStep(&step, ctx, task, i)
+
+		stepResult := p.evaluateStepConditions(&step, ctx, task)
+		if stepResult == nil {
+			stepResult = p.executeStep(&step, ctx, task, i)
+		}
 		
 		// Store step result
 		p.storeStepResult(&execution, &step, stepResult, i, task.Attempts)
 
-		if !stepResult.Success {
+		if !stepResult.Success && !stepResult.Skipped {
 			success = false
+			refused = refused || stepResult.Refused
 			task.LastError = stepResult.Error
 
 			if step.StopOnFailure {
@@ -317,6 +317,8 @@ func (p *WebhookWorkerPool) processTask(task *models.WebhookTask) {
 			CorrelationID: task.CorrelationID,
 			DurationMs:    durationMs,
 		})
+	} else if refused {
+		p.moveToDLQ(task)
 	} else {
 		p.handleTaskError(task, fmt.Errorf(task.LastError))
 	}
@@ -378,6 +380,9 @@ type StepExecutionResult struct {
 	ResponseBody string
 	Error        string
 	DurationMs   int64
+	Skipped      bool   // Conditions not met; the step didn't run
+	SkipReason   string // The unmet conditions
+	Refused      bool   // Refused by the outbound policy; retrying won't help
 }
 
 // executeStep executes a single webhook step
@@ -466,8 +471,9 @@ func (p *WebhookWorkerPool) executeStep(
 		task.Attempts,
 	)
 
-	// Set timeout
-	httpCtx, cancel := context.WithTimeout(context.Background(), time.Duration(step.TimeoutMs)*time.Millisecond)
+	// Set timeout, scoped to the advertiser's egress allowlist
+	tenantCtx := WithOutboundTenant(context.Background(), webhookTenantID(p.db, task.AdvertiserID))
+	httpCtx, cancel := context.WithTimeout(tenantCtx, time.Duration(step.TimeoutMs)*time.Millisecond)
 	defer cancel()
 	req = req.WithContext(httpCtx)
 
@@ -477,6 +483,7 @@ func (p *WebhookWorkerPool) executeStep(
 
 	if err != nil {
 		result.Error = fmt.Sprintf("request failed: %v", err)
+		result.Refused = IsOutboundPolicyError(err)
 		atomic.AddInt64(&p.metrics.StepsFailed, 1)
 		
 		p.observability.Log(LogEvent{
@@ -543,6 +550,45 @@ func (p *WebhookWorkerPool) executeStep(
 	return result
 }
 
+// evaluateStepConditions checks a step's Conditions against the context.
+// It returns a skipped result when they are not met, or nil if the step
+// should run. Skipped steps don't fail the pipeline; Success stays false
+// since nothing was delivered.
+func (p *WebhookWorkerPool) evaluateStepConditions(
+	step *models.WebhookStep,
+	ctx *TemplateContext,
+	task *models.WebhookTask,
+) *StepExecutionResult {
+	cond, err := ParseWebhookConditions(step.Conditions)
+	if err != nil {
+		// Conditions are validated on save; a bad one here fails the step
+		atomic.AddInt64(&p.metrics.StepsFailed, 1)
+		return &StepExecutionResult{Error: fmt.Sprintf("invalid step conditions: %v", err)}
+	}
+	if cond == nil || cond.Evaluate(ctx) {
+		return nil
+	}
+
+	atomic.AddInt64(&p.metrics.StepsSkipped, 1)
+
+	p.observability.Log(LogEvent{
+		Category:      "webhook_step_skipped",
+		Level:         LogLevelInfo,
+		Message:       "Webhook step skipped, conditions not met",
+		CorrelationID: task.CorrelationID,
+		Metadata: map[string]interface{}{
+			"task_id":    task.ID,
+			"step_id":    step.ID.String(),
+			"conditions": cond.String(),
+		},
+	})
+
+	return &StepExecutionResult{
+		Skipped:    true,
+		SkipReason: "conditions not met: " + cond.String(),
+	}
+}
+
 // ============================================
 // HELPER FUNCTIONS
 // ============================================
@@ -575,6 +621,9 @@ func (p *WebhookWorkerPool) buildTemplateContext(task *models.WebhookTask) *Temp
 	if custom, ok := task.Payload["custom"].(map[string]interface{}); ok {
 		ctx.Custom = custom
 	}
+	if subIDs, ok := task.Payload["sub_ids"].(map[string]interface{}); ok {
+		ctx.SubIDs = subIDs
+	}
 
 	return ctx
 }
@@ -616,6 +665,43 @@ func (p *WebhookWorkerPool) handleTaskError(task *models.WebhookTask, err error)
 	}
 }
 
+// moveToDLQ moves a task straight to the DLQ without retrying it, for steps
+// the outbound policy refused
+func (p *WebhookWorkerPool) moveToDLQ(task *models.WebhookTask) {
+	atomic.AddInt64(&p.metrics.TasksFailed, 1)
+
+	p.db.Model(&models.WebhookExecution{}).
+		Where("id = ?", task.ExecutionID).
+		Updates(map[string]interface{}{
+			"attempts":   task.Attempts + 1,
+			"last_error": task.LastError,
+		})
+
+	if err := p.queueService.EnqueueDLQ(task); err != nil {
+		fmt.Printf("[WebhookWorker] Failed to move task %s to DLQ: %v\n", task.ID, err)
+	}
+
+	p.observability.Log(LogEvent{
+		Category:      "webhook_refused",
+		Level:         LogLevelWarn,
+		Message:       "Webhook step refused by outbound policy, moved to DLQ",
+		CorrelationID: task.CorrelationID,
+		Metadata: map[string]interface{}{
+			"task_id": task.ID,
+			"error":   task.LastError,
+		},
+	})
+}
+
+// webhookTenantID returns the tenant of a webhook's advertiser, or the
+// default tenant for platform pipelines
+func webhookTenantID(db *gorm.DB, advertiserID *uuid.UUID) string {
+	if advertiserID == nil {
+		return models.DefaultTenantID.String()
+	}
+	return GetTenantService(db).AdvertiserTenantID(*advertiserID).String()
+}
+
 // storeStepResult stores the result of a step execution
 func (p *WebhookWorkerPool) storeStepResult(
 	execution *models.WebhookExecution,
@@ -625,7 +711,9 @@ func (p *WebhookWorkerPool) storeStepResult(
 	attempt int,
 ) {
 	status := models.WebhookExecutionSuccess
-	if !result.Success {
+	if result.Skipped {
+		status = models.WebhookExecutionSkipped
+	} else if !result.Success {
 		status = models.WebhookExecutionFailed
 	}
 
@@ -641,6 +729,7 @@ func (p *WebhookWorkerPool) storeStepResult(
 		ResponseCode: result.StatusCode,
 		ResponseBody: result.ResponseBody,
 		ErrorMessage: result.Error,
+		SkipReason:   result.SkipReason,
 		DurationMs:   result.DurationMs,
 		StartedAt:    &now,
 		CompletedAt:  &now,
@@ -694,6 +783,7 @@ func (p *WebhookWorkerPool) GetMetrics() *webhookWorkerMetrics {
 		StepsExecuted:  atomic.LoadInt64(&p.metrics.StepsExecuted),
 		StepsSucceeded: atomic.LoadInt64(&p.metrics.StepsSucceeded),
 		StepsFailed:    atomic.LoadInt64(&p.metrics.StepsFailed),
+		StepsSkipped:   atomic.LoadInt64(&p.metrics.StepsSkipped),
 		TotalLatencyMs: atomic.LoadInt64(&p.metrics.TotalLatencyMs),
 		ActiveWorkers:  atomic.LoadInt64(&p.metrics.ActiveWorkers),
 	}
@@ -415,6 +415,24 @@ func StopAllPools() {
 	}
 }
 
+// DrainAllPools waits for the pools' queues to empty (or ctx to expire),
+// then stops them. Returns the number of tasks left queued.
+func DrainAllPools(ctx context.Context) (int, error) {
+	undrained := 0
+	for _, pool := range []*WorkerPool{clickPool, postbackPool, analyticsPool, loggingPool} {
+		if pool == nil {
+			continue
+		}
+		waitUntil(ctx, func() bool { return pool.QueueLength() == 0 })
+		if remaining := pool.QueueLength(); remaining > 0 {
+			fmt.Printf("[WorkerPool:%s] %d tasks left queued at shutdown\n", pool.name, remaining)
+			undrained += remaining
+		}
+		pool.Stop()
+	}
+	return undrained, nil
+}
+
 // GetAllPoolStats returns stats for all pools
 func GetAllPoolStats() map[string]interface{} {
 	stats := make(map[string]interface{})
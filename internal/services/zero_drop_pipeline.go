@@ -0,0 +1,240 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// ============================================
+// ZERO-DROP INGEST PIPELINE
+// ============================================
+
+// ZeroDropPipeline is the WAL-first write path for clicks and conversions.
+// Submit appends the event to the WAL (fsynced) so the request can be
+// acknowledged, then hands it to the Redis stream consumer. If Redis is down
+// the event goes to the failover queue; if the database is down the consumer
+// moves it to the failover queue, which retries with backoff. Anything still
+// unacknowledged in the WAL is replayed by crash recovery on startup.
+type ZeroDropPipeline struct {
+	mu            sync.RWMutex
+	walService    *WALService
+	producer      *StreamProducer
+	consumer      *StreamConsumer
+	failoverQueue *FailoverQueue
+	zeroDropMode  *ZeroDropMode
+	processors    map[WALEventType]EventProcessor
+}
+
+// EventProcessor persists one event's data; it must be idempotent since
+// events can be delivered more than once
+type EventProcessor func(data map[string]interface{}) error
+
+// ZeroDropPipelineMetrics tracks where events went
+type ZeroDropPipelineMetrics struct {
+	Submitted     int64
+	Streamed      int64
+	FailedOver    int64
+	WALOnly       int64 // Stream and failover queue both unavailable; replayed on recovery
+	Processed     int64
+	ProcessErrors int64
+}
+
+var zeroDropPipelineMetrics = &ZeroDropPipelineMetrics{}
+
+// GetZeroDropPipelineMetrics returns pipeline metrics
+func GetZeroDropPipelineMetrics() *ZeroDropPipelineMetrics {
+	return &ZeroDropPipelineMetrics{
+		Submitted:     atomic.LoadInt64(&zeroDropPipelineMetrics.Submitted),
+		Streamed:      atomic.LoadInt64(&zeroDropPipelineMetrics.Streamed),
+		FailedOver:    atomic.LoadInt64(&zeroDropPipelineMetrics.FailedOver),
+		WALOnly:       atomic.LoadInt64(&zeroDropPipelineMetrics.WALOnly),
+		Processed:     atomic.LoadInt64(&zeroDropPipelineMetrics.Processed),
+		ProcessErrors: atomic.LoadInt64(&zeroDropPipelineMetrics.ProcessErrors),
+	}
+}
+
+var (
+	zeroDropPipelineInstance *ZeroDropPipeline
+	zeroDropPipelineOnce     sync.Once
+)
+
+// GetZeroDropPipeline returns the global zero-drop pipeline
+func GetZeroDropPipeline() *ZeroDropPipeline {
+	zeroDropPipelineOnce.Do(func() {
+		zeroDropPipelineInstance = &ZeroDropPipeline{
+			walService:    GetWALService(),
+			producer:      GetStreamProducer(),
+			consumer:      GetStreamConsumer(),
+			failoverQueue: GetFailoverQueue(),
+			zeroDropMode:  GetZeroDropMode(),
+			processors:    make(map[WALEventType]EventProcessor),
+		}
+	})
+	return zeroDropPipelineInstance
+}
+
+// RegisterProcessor sets the persistence function for an event type
+func (p *ZeroDropPipeline) RegisterProcessor(eventType WALEventType, processor EventProcessor) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.processors[eventType] = processor
+}
+
+// attach routes the stream consumer, failover queue and crash recovery to the
+// registered processors
+func (p *ZeroDropPipeline) attach(recovery *CrashRecoveryEngine) {
+	p.consumer.RegisterHandler(StreamClicks, p.handleStreamMessage)
+	p.consumer.RegisterHandler(StreamConversions, p.handleStreamMessage)
+	p.failoverQueue.SetSendCallback(p.handleFailoverEvent)
+
+	recovery.SetClickHandler(func(data map[string]interface{}) error {
+		return p.Process(WALEventClick, data)
+	})
+	recovery.SetConversionHandler(func(data map[string]interface{}) error {
+		return p.Process(WALEventConversion, data)
+	})
+}
+
+// IsWALFirst reports whether writes for the tenant go through the WAL
+// (zero-drop mode) instead of straight to the database
+func (p *ZeroDropPipeline) IsWALFirst(tenantID string) bool {
+	if p.walService == nil || !p.zeroDropMode.IsEnabledForTenant(tenantID) {
+		return false
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return len(p.processors) > 0
+}
+
+// Submit durably records an event and queues it for persistence. Once it
+// returns nil the event is in the WAL and the request can be acknowledged; on
+// error nothing was written and the caller should write synchronously.
+func (p *ZeroDropPipeline) Submit(tenantID string, eventType WALEventType, data map[string]interface{}) error {
+	entry, err := p.walService.Append(eventType, tenantID, data)
+	if err != nil {
+		return fmt.Errorf("WAL append failed: %w", err)
+	}
+	atomic.AddInt64(&zeroDropPipelineMetrics.Submitted, 1)
+
+	if p.consumer.IsRunning() {
+		msg := &StreamMessage{
+			ID:       entry.ID,
+			WALID:    entry.ID,
+			Type:     string(eventType),
+			TenantID: tenantID,
+			Data:     data,
+		}
+		if err := p.producer.Publish(context.Background(), streamForEvent(eventType), msg); err == nil {
+			atomic.AddInt64(&zeroDropPipelineMetrics.Streamed, 1)
+			return nil
+		}
+	}
+
+	if p.enqueueFailover(entry.ID, tenantID, eventType, data) == nil {
+		return nil
+	}
+
+	// Still durable: crash recovery replays the WAL entry
+	atomic.AddInt64(&zeroDropPipelineMetrics.WALOnly, 1)
+	fmt.Printf("[ZeroDrop] Stream and failover queue unavailable, %s %s kept in WAL\n", eventType, entry.ID)
+	return nil
+}
+
+// Process persists an event with its registered processor
+func (p *ZeroDropPipeline) Process(eventType WALEventType, data map[string]interface{}) error {
+	p.mu.RLock()
+	processor, ok := p.processors[eventType]
+	p.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("no processor registered for %s events", eventType)
+	}
+
+	if err := processor(data); err != nil {
+		atomic.AddInt64(&zeroDropPipelineMetrics.ProcessErrors, 1)
+		return err
+	}
+	atomic.AddInt64(&zeroDropPipelineMetrics.Processed, 1)
+	return nil
+}
+
+// handleStreamMessage persists a stream message; if that fails (database
+// down) the event moves to the failover queue so the stream keeps flowing
+func (p *ZeroDropPipeline) handleStreamMessage(ctx context.Context, msg *StreamMessage) error {
+	eventType := WALEventType(msg.Type)
+	err := p.Process(eventType, msg.Data)
+	if err == nil {
+		return nil
+	}
+
+	fmt.Printf("[ZeroDrop] Persisting %s %s failed, moving to failover queue: %v\n", eventType, msg.ID, err)
+	if qErr := p.enqueueFailover(msg.WALID, msg.TenantID, eventType, msg.Data); qErr != nil {
+		return err // Leave unacked; the consumer reclaims it later
+	}
+
+	// The failover queue now owns the WAL entry; don't let the consumer ack it
+	msg.WALID = ""
+	return nil
+}
+
+// handleFailoverEvent persists a failover queue event
+func (p *ZeroDropPipeline) handleFailoverEvent(event *FailoverQueueEvent) error {
+	return p.Process(WALEventType(event.Type), event.Data)
+}
+
+// enqueueFailover hands a WAL'd event to the failover queue
+func (p *ZeroDropPipeline) enqueueFailover(walID, tenantID string, eventType WALEventType, data map[string]interface{}) error {
+	err := p.failoverQueue.Enqueue(&FailoverQueueEvent{
+		ID:       walID,
+		Type:     string(eventType),
+		TenantID: tenantID,
+		Data:     data,
+		Source:   "backend",
+		WALID:    walID,
+	})
+	if err == nil {
+		atomic.AddInt64(&zeroDropPipelineMetrics.FailedOver, 1)
+	}
+	return err
+}
+
+// streamForEvent maps a WAL event type to its Redis stream
+func streamForEvent(eventType WALEventType) string {
+	switch eventType {
+	case WALEventClick:
+		return StreamClicks
+	case WALEventConversion:
+		return StreamConversions
+	case WALEventPostback:
+		return StreamPostbacks
+	}
+	return StreamEdgeEvents
+}
+
+// ============================================
+// EVENT ENCODING
+// ============================================
+
+// toEventData converts a record to the JSON map stored in the WAL and streams
+func toEventData(v interface{}) (map[string]interface{}, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var data map[string]interface{}
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// fromEventData decodes event data back into a record
+func fromEventData(data map[string]interface{}, v interface{}) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, v)
+}
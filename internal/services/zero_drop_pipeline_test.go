@@ -0,0 +1,127 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/google/uuid"
+)
+
+func TestStreamForEvent(t *testing.T) {
+	tests := map[WALEventType]string{
+		WALEventClick:      StreamClicks,
+		WALEventConversion: StreamConversions,
+		WALEventPostback:   StreamPostbacks,
+		WALEventEdgeEvent:  StreamEdgeEvents,
+		WALEventAPIEvent:   StreamEdgeEvents,
+	}
+	for eventType, want := range tests {
+		if got := streamForEvent(eventType); got != want {
+			t.Errorf("streamForEvent(%s) = %q, want %q", eventType, got, want)
+		}
+	}
+}
+
+func TestConversionEventRoundTrip(t *testing.T) {
+	clickID := uuid.New()
+	convertedAt := time.Date(2026, 5, 1, 10, 30, 0, 123000000, time.UTC)
+	event := &ConversionEvent{
+		Conversion: &models.Conversion{
+			ID:                   uuid.New(),
+			UserOfferID:          uuid.New(),
+			ClickID:              &clickID,
+			ExternalConversionID: "order-1001",
+			Amount:               4990,
+			Commission:           500,
+			Currency:             "SAR",
+			Status:               models.ConversionStatusPending,
+			ConvertedAt:          convertedAt,
+		},
+		OfferID:  uuid.New(),
+		UserID:   uuid.New(),
+		Source:   models.ConversionSourcePostback,
+		Postback: map[string]interface{}{"external_id": "order-1001", "amount": float64(4990)},
+	}
+
+	data, err := toEventData(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := data["status_update"]; ok {
+		t.Error("empty status update was encoded")
+	}
+
+	var decoded ConversionEvent
+	if err := fromEventData(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	c := decoded.Conversion
+	if c == nil || c.ID != event.Conversion.ID || c.UserOfferID != event.Conversion.UserOfferID ||
+		c.ClickID == nil || *c.ClickID != clickID || c.ExternalConversionID != "order-1001" ||
+		c.Amount != 4990 || c.Commission != 500 || c.Currency != "SAR" || !c.ConvertedAt.Equal(convertedAt) {
+		t.Errorf("conversion did not survive the round trip: %+v", c)
+	}
+	if decoded.OfferID != event.OfferID || decoded.UserID != event.UserID || decoded.Source != event.Source {
+		t.Errorf("event fields lost: %+v", decoded)
+	}
+	if decoded.Postback["external_id"] != "order-1001" {
+		t.Errorf("postback payload lost: %v", decoded.Postback)
+	}
+	if decoded.StatusUpdate != nil {
+		t.Error("status update appeared from nowhere")
+	}
+
+	update := &ConversionEvent{
+		Source: models.ConversionSourcePostback,
+		StatusUpdate: &ConversionStatusUpdate{
+			ExternalID:  "order-1001",
+			UserOfferID: event.Conversion.UserOfferID,
+			Status:      "refunded",
+			QueuedAt:    convertedAt,
+		},
+	}
+	data, _ = toEventData(update)
+	decoded = ConversionEvent{}
+	if err := fromEventData(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	su := decoded.StatusUpdate
+	if decoded.Conversion != nil || su == nil || su.ExternalID != "order-1001" ||
+		su.UserOfferID != event.Conversion.UserOfferID || su.Status != "refunded" || !su.QueuedAt.Equal(convertedAt) {
+		t.Errorf("status update did not survive the round trip: %+v", su)
+	}
+}
+
+func TestZeroDropPipelineProcess(t *testing.T) {
+	p := &ZeroDropPipeline{processors: make(map[WALEventType]EventProcessor)}
+
+	if err := p.Process(WALEventClick, nil); err == nil {
+		t.Error("processed an event without a processor")
+	}
+	if p.IsWALFirst("") {
+		t.Error("WAL-first without a WAL")
+	}
+
+	errDown := errors.New("database down")
+	var got map[string]interface{}
+	p.RegisterProcessor(WALEventClick, func(data map[string]interface{}) error {
+		got = data
+		return nil
+	})
+	p.RegisterProcessor(WALEventConversion, func(map[string]interface{}) error { return errDown })
+
+	before := GetZeroDropPipelineMetrics()
+	if err := p.Process(WALEventClick, map[string]interface{}{"id": "c1"}); err != nil || got["id"] != "c1" {
+		t.Errorf("Process(click) = %v, data %v", err, got)
+	}
+	if err := p.Process(WALEventConversion, nil); !errors.Is(err, errDown) {
+		t.Errorf("Process(conversion) = %v, want %v", err, errDown)
+	}
+	after := GetZeroDropPipelineMetrics()
+	if after.Processed != before.Processed+1 || after.ProcessErrors != before.ProcessErrors+1 {
+		t.Errorf("metrics moved by %d processed, %d errors; want 1, 1",
+			after.Processed-before.Processed, after.ProcessErrors-before.ProcessErrors)
+	}
+}
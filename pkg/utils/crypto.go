@@ -0,0 +1,91 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// encryptedSecretPrefix marks the format of values produced by EncryptSecret
+const encryptedSecretPrefix = "v1:"
+
+var (
+	secretsKey     []byte
+	secretsKeyOnce sync.Once
+)
+
+// ErrInvalidEncryptedSecret is returned when a stored secret can't be decrypted
+var ErrInvalidEncryptedSecret = errors.New("invalid encrypted secret")
+
+// loadSecretsKey derives the AES-256 key from SECRETS_ENCRYPTION_KEY. It is read
+// lazily so values loaded from .env at startup are picked up.
+func loadSecretsKey() []byte {
+	secretsKeyOnce.Do(func() {
+		key := os.Getenv("SECRETS_ENCRYPTION_KEY")
+		if key == "" {
+			fmt.Println("WARNING: SECRETS_ENCRYPTION_KEY not set, deriving secrets key from JWT_SECRET")
+			key = string(jwtSecret)
+		}
+		sum := sha256.Sum256([]byte(key))
+		secretsKey = sum[:]
+	})
+	return secretsKey
+}
+
+// EncryptSecret encrypts a secret for storage using AES-256-GCM
+func EncryptSecret(plaintext string) (string, error) {
+	block, err := aes.NewCipher(loadSecretsKey())
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+
+	sealed := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
+	return encryptedSecretPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// DecryptSecret decrypts a value produced by EncryptSecret
+func DecryptSecret(encrypted string) (string, error) {
+	if !strings.HasPrefix(encrypted, encryptedSecretPrefix) {
+		return "", ErrInvalidEncryptedSecret
+	}
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(encrypted, encryptedSecretPrefix))
+	if err != nil {
+		return "", ErrInvalidEncryptedSecret
+	}
+
+	block, err := aes.NewCipher(loadSecretsKey())
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return "", ErrInvalidEncryptedSecret
+	}
+
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", ErrInvalidEncryptedSecret
+	}
+	return string(plaintext), nil
+}
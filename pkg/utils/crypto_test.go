@@ -0,0 +1,80 @@
+package utils
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestEncryptSecretRoundTrip(t *testing.T) {
+	for _, plaintext := range []string{
+		"",
+		"shpss_0123456789abcdef",
+		"unicode: مرحبا",
+		strings.Repeat("x", 4096),
+	} {
+		encrypted, err := EncryptSecret(plaintext)
+		if err != nil {
+			t.Fatalf("EncryptSecret: %v", err)
+		}
+		if !strings.HasPrefix(encrypted, encryptedSecretPrefix) {
+			t.Errorf("encrypted value %q lacks the %q prefix", encrypted, encryptedSecretPrefix)
+		}
+		if plaintext != "" && strings.Contains(encrypted, plaintext) {
+			t.Error("encrypted value contains the plaintext")
+		}
+
+		decrypted, err := DecryptSecret(encrypted)
+		if err != nil {
+			t.Fatalf("DecryptSecret: %v", err)
+		}
+		if decrypted != plaintext {
+			t.Errorf("round trip = %q, want %q", decrypted, plaintext)
+		}
+	}
+}
+
+func TestEncryptSecretUsesFreshNonces(t *testing.T) {
+	a, _ := EncryptSecret("same secret")
+	b, _ := EncryptSecret("same secret")
+	if a == b {
+		t.Error("encrypting the same secret twice gave the same value")
+	}
+}
+
+func TestDecryptSecretRejectsTampering(t *testing.T) {
+	encrypted, err := EncryptSecret("shpss_0123456789abcdef")
+	if err != nil {
+		t.Fatalf("EncryptSecret: %v", err)
+	}
+	sealed, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(encrypted, encryptedSecretPrefix))
+
+	flip := func(i int) string {
+		tampered := append([]byte(nil), sealed...)
+		tampered[i] ^= 0x01
+		return encryptedSecretPrefix + base64.StdEncoding.EncodeToString(tampered)
+	}
+
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{"empty", ""},
+		{"missing prefix", strings.TrimPrefix(encrypted, encryptedSecretPrefix)},
+		{"unknown version", "v2:" + strings.TrimPrefix(encrypted, encryptedSecretPrefix)},
+		{"not base64", encryptedSecretPrefix + "!!!"},
+		{"shorter than a nonce", encryptedSecretPrefix + base64.StdEncoding.EncodeToString([]byte("short"))},
+		{"nonce flipped", flip(0)},
+		{"ciphertext flipped", flip(len(sealed) / 2)},
+		{"tag flipped", flip(len(sealed) - 1)},
+		{"truncated", encryptedSecretPrefix + base64.StdEncoding.EncodeToString(sealed[:len(sealed)-1])},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := DecryptSecret(tt.value); err != ErrInvalidEncryptedSecret {
+				t.Errorf("DecryptSecret error = %v, want ErrInvalidEncryptedSecret", err)
+			}
+		})
+	}
+}
@@ -12,11 +12,12 @@ import (
 )
 
 type Claims struct {
-	UserID   uuid.UUID `json:"user_id"`
-	Username string    `json:"username"`
-	Email    string    `json:"email"`
-	Role     string    `json:"role"`
-	TokenID  string    `json:"jti,omitempty"` // Unique token ID for revocation
+	UserID    uuid.UUID `json:"user_id"`
+	Username  string    `json:"username"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	TokenID   string    `json:"jti,omitempty"` // Unique token ID for revocation
+	SessionID string    `json:"sid,omitempty"` // Login session shared by an access/refresh pair
 	jwt.RegisteredClaims
 }
 
@@ -47,18 +48,65 @@ func generateTokenID() string {
 	return hex.EncodeToString(b)
 }
 
+// TokenPair is an access/refresh token pair bound to one login session
+type TokenPair struct {
+	AccessToken      string
+	RefreshToken     string
+	SessionID        string
+	AccessTokenID    string
+	RefreshTokenID   string
+	AccessExpiresAt  time.Time
+	RefreshExpiresAt time.Time
+}
+
 // GenerateToken generates a JWT token for a user with enhanced security
 func GenerateToken(userID uuid.UUID, username, email, role string) (string, error) {
+	token, _, _, err := generateAccessToken(userID, username, email, role, "")
+	return token, err
+}
+
+// GenerateRefreshToken generates a refresh token with enhanced security
+func GenerateRefreshToken(userID uuid.UUID) (string, error) {
+	token, _, _, err := generateRefreshToken(userID, "")
+	return token, err
+}
+
+// GenerateTokenPair generates an access and refresh token for a session
+func GenerateTokenPair(userID uuid.UUID, username, email, role, sessionID string) (*TokenPair, error) {
+	accessToken, accessID, accessExp, err := generateAccessToken(userID, username, email, role, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, refreshID, refreshExp, err := generateRefreshToken(userID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:      accessToken,
+		RefreshToken:     refreshToken,
+		SessionID:        sessionID,
+		AccessTokenID:    accessID,
+		RefreshTokenID:   refreshID,
+		AccessExpiresAt:  accessExp,
+		RefreshExpiresAt: refreshExp,
+	}, nil
+}
+
+// generateAccessToken signs an access token and returns it with its ID and expiry
+func generateAccessToken(userID uuid.UUID, username, email, role, sessionID string) (string, string, time.Time, error) {
 	now := time.Now()
 	expirationTime := now.Add(AccessTokenExpiry)
 	tokenID := generateTokenID()
 
 	claims := &Claims{
-		UserID:   userID,
-		Username: username,
-		Email:    email,
-		Role:     role,
-		TokenID:  tokenID,
+		UserID:    userID,
+		Username:  username,
+		Email:     email,
+		Role:      role,
+		TokenID:   tokenID,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
 			IssuedAt:  jwt.NewNumericDate(now),
@@ -72,21 +120,22 @@ func GenerateToken(userID uuid.UUID, username, email, role string) (string, erro
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	tokenString, err := token.SignedString(jwtSecret)
 	if err != nil {
-		return "", err
+		return "", "", time.Time{}, err
 	}
 
-	return tokenString, nil
+	return tokenString, tokenID, expirationTime, nil
 }
 
-// GenerateRefreshToken generates a refresh token with enhanced security
-func GenerateRefreshToken(userID uuid.UUID) (string, error) {
+// generateRefreshToken signs a refresh token and returns it with its ID and expiry
+func generateRefreshToken(userID uuid.UUID, sessionID string) (string, string, time.Time, error) {
 	now := time.Now()
 	expirationTime := now.Add(RefreshTokenExpiry)
 	tokenID := generateTokenID()
 
 	claims := &Claims{
-		UserID:  userID,
-		TokenID: tokenID,
+		UserID:    userID,
+		TokenID:   tokenID,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
 			IssuedAt:  jwt.NewNumericDate(now),
@@ -100,10 +149,10 @@ func GenerateRefreshToken(userID uuid.UUID) (string, error) {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	tokenString, err := token.SignedString(jwtSecret)
 	if err != nil {
-		return "", err
+		return "", "", time.Time{}, err
 	}
 
-	return tokenString, nil
+	return tokenString, tokenID, expirationTime, nil
 }
 
 // ValidateToken validates a JWT token and returns the claims with enhanced security checks
@@ -0,0 +1,116 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+func TestGenerateTokenPair(t *testing.T) {
+	userID := uuid.New()
+	pair, err := GenerateTokenPair(userID, "promoter", "p@example.com", "promoter", "session-1")
+	if err != nil {
+		t.Fatalf("GenerateTokenPair: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		token       string
+		wantID      string
+		wantRefresh bool
+		wantExpiry  time.Time
+	}{
+		{"access", pair.AccessToken, pair.AccessTokenID, false, pair.AccessExpiresAt},
+		{"refresh", pair.RefreshToken, pair.RefreshTokenID, true, pair.RefreshExpiresAt},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := ValidateToken(tt.token)
+			if err != nil {
+				t.Fatalf("ValidateToken: %v", err)
+			}
+			if claims.UserID != userID {
+				t.Errorf("UserID = %s, want %s", claims.UserID, userID)
+			}
+			if claims.SessionID != "session-1" {
+				t.Errorf("SessionID = %q, want session-1", claims.SessionID)
+			}
+			if claims.TokenID != tt.wantID {
+				t.Errorf("TokenID = %q, want %q", claims.TokenID, tt.wantID)
+			}
+			if IsRefreshToken(claims) != tt.wantRefresh {
+				t.Errorf("IsRefreshToken = %v, want %v", IsRefreshToken(claims), tt.wantRefresh)
+			}
+			if !GetTokenExpiry(claims).Equal(tt.wantExpiry.Truncate(time.Second)) {
+				t.Errorf("expiry = %v, want %v", GetTokenExpiry(claims), tt.wantExpiry)
+			}
+		})
+	}
+
+	if pair.AccessTokenID == pair.RefreshTokenID {
+		t.Error("access and refresh tokens share an ID")
+	}
+}
+
+func TestValidateTokenRejects(t *testing.T) {
+	valid, err := GenerateToken(uuid.New(), "u", "u@example.com", "promoter")
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+
+	sign := func(method jwt.SigningMethod, key interface{}, claims *Claims) string {
+		token, err := jwt.NewWithClaims(method, claims).SignedString(key)
+		if err != nil {
+			t.Fatalf("SignedString: %v", err)
+		}
+		return token
+	}
+	claimsWith := func(mutate func(*Claims)) *Claims {
+		now := time.Now()
+		claims := &Claims{
+			UserID: uuid.New(),
+			RegisteredClaims: jwt.RegisteredClaims{
+				ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+				IssuedAt:  jwt.NewNumericDate(now),
+				Issuer:    "afftok",
+				Subject:   "subject-padding-for-length",
+			},
+		}
+		mutate(claims)
+		return claims
+	}
+
+	parts := strings.Split(valid, ".")
+	tampered := parts[0] + "." + parts[1] + "x." + parts[2]
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"too short", "abc"},
+		{"too long", strings.Repeat("a", 1001)},
+		{"tampered payload", tampered},
+		{"wrong secret", sign(jwt.SigningMethodHS256, []byte("another-secret-another-secret-xx"), claimsWith(func(*Claims) {}))},
+		{"HS512", sign(jwt.SigningMethodHS512, jwtSecret, claimsWith(func(*Claims) {}))},
+		{"unknown issuer", sign(jwt.SigningMethodHS256, jwtSecret, claimsWith(func(c *Claims) { c.Issuer = "someone-else" }))},
+		{"expired", sign(jwt.SigningMethodHS256, jwtSecret, claimsWith(func(c *Claims) {
+			c.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+		}))},
+		{"not yet valid", sign(jwt.SigningMethodHS256, jwtSecret, claimsWith(func(c *Claims) {
+			c.NotBefore = jwt.NewNumericDate(time.Now().Add(time.Hour))
+		}))},
+		{"nil user", sign(jwt.SigningMethodHS256, jwtSecret, claimsWith(func(c *Claims) { c.UserID = uuid.Nil }))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ValidateToken(tt.token); err == nil {
+				t.Error("ValidateToken accepted the token")
+			}
+		})
+	}
+}
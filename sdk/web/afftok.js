@@ -514,6 +514,13 @@
       }
     }
 
+    // HMAC-SHA256(apiKey, "apiKey|advertiserId|timestamp|nonce"), hex. Test vector
+    // (also checked by the backend's SDK ingest tests):
+    //   apiKey       afftok_live_sk_test_vector_0001
+    //   advertiserId 5f0c6b2e-8d1a-4c3b-9e7f-2a4d6c8b0e1f
+    //   timestamp    1767225600000
+    //   nonce        Zk3Qp9LmT2vX8rWc
+    //   signature    c58f1a73ee486bbde53189ac4b0cff9c4461044681c676721aab89685e3773e3
     async _generateSignature(timestamp, nonce) {
       const dataToSign = `${this.options.apiKey}|${this.options.advertiserId}|${timestamp}|${nonce}`;
       return await hmacSha256(this.options.apiKey, dataToSign);